@@ -28,8 +28,14 @@ func main() {
 		log.Fatalf("Ошибка аутентификации в API: %v", err)
 	}
 
+	var rwClient *remnawave.Client
+	if strings.TrimSpace(cfg.RemnawaveAPIURL) != "" && strings.TrimSpace(cfg.RemnawaveAPIToken) != "" {
+		rwClient = remnawave.NewClient(cfg.RemnawaveAPIURL, cfg.RemnawaveAPIToken)
+	}
+
 	svc := service.NewService(apiClient, cfg.EffectiveBrand())
 	botService := bot.NewService(svc, cfg)
+	botService.SetRemnawaveClient(rwClient)
 	botHandler := bot.NewBotHandler(botService)
 
 	settings := telebot.Settings{
@@ -59,11 +65,10 @@ func main() {
 	botHandler.RegisterHandlers(b)
 
 	go apiClient.StartSessionRefresher()
+	go botService.StartTrafficAlerts(b)
+	go botService.StartPaymentConfirmations(b)
+	go botService.StartGiftRefunds(b)
 
-	var rwClient *remnawave.Client
-	if strings.TrimSpace(cfg.RemnawaveAPIURL) != "" && strings.TrimSpace(cfg.RemnawaveAPIToken) != "" {
-		rwClient = remnawave.NewClient(cfg.RemnawaveAPIURL, cfg.RemnawaveAPIToken)
-	}
 	web.Start(cfg, svc, rwClient)
 
 	log.Println("Бот запущен и готов к работе...")
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ryabkov82/vpnbot/internal/config"
+	"github.com/ryabkov82/vpnbot/internal/infrastructure/api"
+	"github.com/ryabkov82/vpnbot/internal/service"
+)
+
+func main() {
+	os.Exit(run())
+}
+
+func run() int {
+	configPath := flag.String("config", "", "path to vpnbot JSON config")
+	userA := flag.Int("user-a", 0, "SHM user_id of the first duplicate")
+	userB := flag.Int("user-b", 0, "SHM user_id of the second duplicate")
+	survivor := flag.Int("survivor", 0, "SHM user_id that survives (default: suggested)")
+	apply := flag.Bool("apply", false, "apply the merge (default: dry run)")
+	force := flag.Bool("force", false, "apply even if the absorbed user has balance/services/pays")
+	operator := flag.String("operator", "", "operator name for the merge journal")
+	flag.Parse()
+
+	path := strings.TrimSpace(*configPath)
+	if path == "" || *userA <= 0 || *userB <= 0 {
+		fmt.Fprintln(os.Stderr, "usage: shm-user-merge -config /path/to/config.json -user-a ID -user-b ID [-survivor ID] [-apply [-force]] [-operator NAME]")
+		return 2
+	}
+
+	cfg, err := config.LoadFromFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "shm-user-merge: %v\n", err)
+		return 1
+	}
+	apiClient := api.NewAPIClient(cfg)
+	if err := apiClient.Authenticate(); err != nil {
+		fmt.Fprintf(os.Stderr, "shm-user-merge: authenticate: %v\n", err)
+		return 1
+	}
+	svc := service.NewService(apiClient, cfg.EffectiveBrand())
+
+	op := strings.TrimSpace(*operator)
+	if op == "" {
+		op = "cli"
+	}
+	res, err := svc.MergeUsers(service.UserMergeRequest{
+		UserAID:    *userA,
+		UserBID:    *userB,
+		SurvivorID: *survivor,
+		DryRun:     !*apply,
+		Force:      *force,
+		Operator:   op,
+	})
+	if res != nil {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(res)
+	}
+	if errors.Is(err, service.ErrMergeNeedsForce) {
+		fmt.Fprintln(os.Stderr, "shm-user-merge: absorbed user has balance/services/pays; review warnings and rerun with -force")
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "shm-user-merge: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ryabkov82/vpnbot/internal/config"
+	"github.com/ryabkov82/vpnbot/internal/vouchers"
+)
+
+func main() {
+	os.Exit(run())
+}
+
+func run() int {
+	configPath := flag.String("config", "", "path to vpnbot JSON config (brand.id and vouchers.secret)")
+	kind := flag.String("kind", "balance", "voucher kind: balance (rubles) or service (service_id)")
+	value := flag.Int("value", 0, "rubles for balance vouchers, service_id for service vouchers")
+	count := flag.Int("count", 1, fmt.Sprintf("number of codes (1..%d)", vouchers.MaxBatchSize))
+	expires := flag.String("expires", "", "last valid day YYYY-MM-DD (Moscow time)")
+	batch := flag.Uint("batch", 0, "batch number (0 = random); the same batch number re-issues the same codes")
+	output := flag.String("output", "", "CSV file (default stdout)")
+	flag.Parse()
+
+	fail := func(err error) int {
+		fmt.Fprintf(os.Stderr, "voucher-mint: %v\n", err)
+		return 1
+	}
+	if strings.TrimSpace(*configPath) == "" || strings.TrimSpace(*expires) == "" || *value <= 0 {
+		fmt.Fprintln(os.Stderr, "usage: voucher-mint -config config.json -kind balance|service -value N -expires YYYY-MM-DD [-count N] [-batch N] [-output codes.csv]")
+		return 2
+	}
+	cfg, err := config.LoadFromFile(*configPath)
+	if err != nil {
+		return fail(err)
+	}
+	codec, err := vouchers.NewCodec(cfg.Vouchers.Secret)
+	if err != nil {
+		return fail(fmt.Errorf("vouchers.secret: %w", err))
+	}
+	k, err := vouchers.ParseKind(*kind)
+	if err != nil {
+		return fail(err)
+	}
+	exp, err := vouchers.ParseExpiry(strings.TrimSpace(*expires))
+	if err != nil {
+		return fail(fmt.Errorf("-expires: %w", err))
+	}
+	if *batch > vouchers.MaxBatch {
+		return fail(fmt.Errorf("-batch must be <= %d", vouchers.MaxBatch))
+	}
+
+	brandID := cfg.BrandID()
+	list, codes, err := codec.Mint(brandID, vouchers.BatchSpec{Kind: k, Value: *value, ExpiresOn: exp, Count: *count, Batch: uint32(*batch)})
+	if err != nil {
+		return fail(err)
+	}
+
+	var w io.Writer = os.Stdout
+	if p := strings.TrimSpace(*output); p != "" {
+		f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			return fail(err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := vouchers.WriteCSV(w, brandID, list, codes); err != nil {
+		return fail(err)
+	}
+	fmt.Fprintf(os.Stderr, "voucher-mint: brand=%s kind=%s value=%d batch=%06x count=%d expires=%s\n",
+		brandID, k, *value, list[0].Batch, len(list), exp.Format("2006-01-02"))
+	return 0
+}
@@ -0,0 +1,171 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/payments"
+	"github.com/ryabkov82/vpnbot/internal/service"
+	"gopkg.in/telebot.v3"
+)
+
+// topupPresetAmounts — суммы на кнопках выбора; выходящие за лимиты провайдера не показываются.
+var topupPresetAmounts = []float64{100, 300, 500, 1000}
+
+// topupLocale — бот русскоязычный: провайдеры и подписи берутся для ru.
+const topupLocale = "ru"
+
+// topupAmountOptions — кнопки сумм: сначала сумма к оплате (не меньше минимума провайдера), затем пресеты.
+func topupAmountOptions(p payments.Provider, forecast float64) []float64 {
+	min, max := p.AmountLimits()
+	var out []float64
+	if forecast > 0 && !math.IsInf(forecast, 0) {
+		amt := math.Ceil(forecast*100) / 100
+		if amt < min {
+			amt = min
+		}
+		if payments.AmountAllowed(p, amt) {
+			out = append(out, amt)
+		}
+	}
+	for _, a := range topupPresetAmounts {
+		if a >= min && a <= max && (len(out) == 0 || out[0] != a) {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+func formatTopupAmount(a float64) string {
+	return strconv.FormatFloat(a, 'f', -1, 64)
+}
+
+// topupProviderRows — кнопки пополнения из реестра бренда. YooKassa открывается в Telegram WebApp SHM,
+// остальные провайдеры — через выбор суммы (/topup|<id>).
+func (s *Service) topupProviderRows(menu *telebot.ReplyMarkup, userID int) []telebot.Row {
+	if s.config == nil {
+		return nil
+	}
+	reg, err := payments.RegistryFromConfig(s.config)
+	if err != nil {
+		log.Printf("topup: payment registry: %v", err)
+		return nil
+	}
+	var rows []telebot.Row
+	for _, p := range reg.Available(s.config.BrandID(), topupLocale) {
+		label := "✚ " + p.DisplayName(topupLocale)
+		if p.ID() == payments.ProviderYooKassa {
+			payURL, err := telegramPaymentsWebAppURL(s.config.API.BaseURL, userID, s.config.PaymentProfile(), s.config.YooKassaPaySystem(), s.config.BrandID())
+			if err != nil {
+				log.Printf("topup: telegram payments webapp url: %v", err)
+				continue
+			}
+			rows = append(rows, menu.Row(menu.WebApp(label, &telebot.WebApp{URL: payURL})))
+			continue
+		}
+		rows = append(rows, menu.Row(menu.Data(label, "/topup", p.ID())))
+	}
+	return rows
+}
+
+func (s *Service) topupProvider(c telebot.Context, providerID string) (payments.Provider, bool) {
+	reg, err := payments.RegistryFromConfig(s.config)
+	if err == nil && s.config != nil {
+		var p payments.Provider
+		if p, err = reg.Get(providerID, s.config.BrandID()); err == nil {
+			return p, true
+		}
+	}
+	log.Printf("topup: provider %q: %v", providerID, err)
+	_ = c.Send("⚠️ Этот способ оплаты сейчас недоступен.")
+	return nil, false
+}
+
+// handleTopupProvider — выбор суммы для провайдера.
+func (s *Service) handleTopupProvider(c telebot.Context, providerID string) error {
+	p, ok := s.topupProvider(c, providerID)
+	if !ok {
+		return nil
+	}
+	bal, err := s.service.GetUserBalance(c.Chat().ID)
+	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			return s.showRegistrationMenu(c)
+		}
+		log.Printf("topup: баланс: %v", err)
+		return c.Send("Ошибка системы, попробуйте позже")
+	}
+
+	menu := &telebot.ReplyMarkup{}
+	var rows []telebot.Row
+	var row []telebot.Btn
+	for _, a := range topupAmountOptions(p, bal.Forecast) {
+		row = append(row, menu.Data(formatTopupAmount(a)+" ₽", "/topup", p.ID(), formatTopupAmount(a)))
+		if len(row) == 2 {
+			rows = append(rows, menu.Row(row...))
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, menu.Row(row...))
+	}
+	rows = append(rows, menu.Row(menu.Data("⇦ Назад", "/balance")))
+	menu.Inline(rows...)
+
+	min, max := p.AmountLimits()
+	var text strings.Builder
+	fmt.Fprintf(&text, "%s\n\n", p.DisplayName(topupLocale))
+	if d := p.Description(topupLocale); d != "" {
+		fmt.Fprintf(&text, "%s\n\n", d)
+	}
+	fmt.Fprintf(&text, "Выберите сумму пополнения (от %s до %s ₽).", formatTopupAmount(min), formatTopupAmount(max))
+	return c.Send(text.String(), menu)
+}
+
+// handleTopupAmount — ссылка на оплату выбранной суммы у провайдера.
+func (s *Service) handleTopupAmount(c telebot.Context, providerID, amountRaw string) error {
+	p, ok := s.topupProvider(c, providerID)
+	if !ok {
+		return nil
+	}
+	amount, err := strconv.ParseFloat(amountRaw, 64)
+	if err != nil || !payments.AmountAllowed(p, amount) {
+		return c.Send("⚠️ Недопустимая сумма пополнения.")
+	}
+	bal, err := s.service.GetUserBalance(c.Chat().ID)
+	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			return s.showRegistrationMenu(c)
+		}
+		log.Printf("topup: баланс: %v", err)
+		return c.Send("Ошибка системы, попробуйте позже")
+	}
+	ts := time.Now().Unix()
+	payURL, err := p.PaymentURL(payments.PaymentRequest{
+		BaseURL: s.config.API.BaseURL,
+		UserID:  bal.ID,
+		Amount:  amount,
+		TS:      ts,
+		BrandID: s.config.BrandID(),
+	})
+	if err != nil {
+		log.Printf("topup: %s payment url: %v", p.ID(), err)
+		return c.Send("⚠️ Не удалось создать ссылку на оплату. Попробуйте позже или обратитесь в поддержку.")
+	}
+	// Намерение нужно для подтверждения зачисления; без него оплата всё равно работает.
+	if _, err := s.service.CreatePaymentIntent(bal.ID, amount, p.ID(), s.config.BrandID(), ts); err != nil {
+		log.Printf("topup: payment intent user=%d: %v", bal.ID, err)
+	}
+	menu := &telebot.ReplyMarkup{}
+	menu.Inline(
+		menu.Row(menu.URL("Перейти к оплате", payURL)),
+		menu.Row(menu.Data("⇦ Назад", "/balance")),
+	)
+	return c.Send(fmt.Sprintf("Пополнение на %s ₽ (%s).\n\nПосле оплаты баланс обновится автоматически.",
+		formatTopupAmount(amount), p.DisplayName(topupLocale)), menu)
+}
@@ -0,0 +1,59 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ryabkov82/vpnbot/internal/config"
+	"github.com/ryabkov82/vpnbot/internal/payments"
+	"gopkg.in/telebot.v3"
+)
+
+func topupTestCfg() *config.Config {
+	cfg := &config.Config{}
+	cfg.API.BaseURL = "https://bill.example"
+	cfg.Brand.ID = "vff"
+	cfg.Brand.PaymentProfile = "vff_profile"
+	cfg.Brand.YooKassaPaySystem = "yookassa"
+	return cfg
+}
+
+func TestTopupAmountOptions(t *testing.T) {
+	cfg := topupTestCfg()
+	cfg.Brand.PaymentProviders = []config.PaymentProvider{{ID: "paymaster", MinAmount: 200, MaxAmount: 600}}
+	reg, err := payments.RegistryFromConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := reg.Get("paymaster", "vff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := topupAmountOptions(p, 0); !reflect.DeepEqual(got, []float64{300, 500}) {
+		t.Fatalf("no forecast: %v", got)
+	}
+	if got := topupAmountOptions(p, 149.999); !reflect.DeepEqual(got, []float64{200, 300, 500}) {
+		t.Fatalf("forecast below min: %v", got)
+	}
+	if got := topupAmountOptions(p, 300); !reflect.DeepEqual(got, []float64{300, 500}) {
+		t.Fatalf("forecast equals preset: %v", got)
+	}
+	if got := topupAmountOptions(p, 5000); !reflect.DeepEqual(got, []float64{300, 500}) {
+		t.Fatalf("forecast above max: %v", got)
+	}
+}
+
+func TestTopupProviderRows(t *testing.T) {
+	s := NewService(nil, topupTestCfg())
+	menu := &telebot.ReplyMarkup{}
+	rows := s.topupProviderRows(menu, 42)
+	if len(rows) != 2 {
+		t.Fatalf("rows=%d", len(rows))
+	}
+	if rows[0][0].WebApp == nil || rows[0][0].Text != "✚ Банковская карта" {
+		t.Fatalf("yookassa row: %+v", rows[0][0])
+	}
+	if rows[1][0].Text != "✚ Криптовалюта" || rows[1][0].Unique != "/topup" || rows[1][0].Data != "cryptocloud" {
+		t.Fatalf("cryptocloud row: %+v", rows[1][0])
+	}
+}
@@ -8,8 +8,12 @@ func botMenuCommands() []telebot.Command {
 		{Text: "/start", Description: "Начало работы с ботом"},
 		{Text: "/account", Description: "Личный кабинет (NEW)"},
 		{Text: "/balance", Description: "Баланс"},
+		{Text: "/history", Description: "История списаний"},
+		{Text: "/redeem", Description: "Активировать подарочный код"},
+		{Text: "/gift", Description: "Подарить подписку"},
 		{Text: "/list", Description: "Список ключей доступа"},
 		{Text: "/pricelist", Description: "Новый ключ"},
+		{Text: "/status", Description: "Статус серверов"},
 		{Text: "/help", Description: "Помощь по использованию бота"},
 	}
 }
@@ -0,0 +1,66 @@
+package bot
+
+import (
+	"errors"
+	"log"
+
+	"github.com/ryabkov82/vpnbot/internal/app/web"
+	"github.com/ryabkov82/vpnbot/internal/config"
+	"github.com/ryabkov82/vpnbot/internal/connectapp"
+	"github.com/ryabkov82/vpnbot/internal/service"
+	"gopkg.in/telebot.v3"
+)
+
+type connectAppButton struct {
+	Text string
+	URL  string
+}
+
+// connectAppButtons — кнопки «Открыть в …» для всех клиентов реестра. ОС в боте неизвестна,
+// поэтому выбор платформы и ссылку установки показывает страница /connect/open.
+func connectAppButtons(cfg *config.Config, shmUserID, userServiceID int) []connectAppButton {
+	var out []connectAppButton
+	for _, a := range connectapp.All() {
+		u, err := web.BuildConnectAppOpenURL(cfg, nil, shmUserID, userServiceID, a.ID)
+		if err != nil {
+			log.Printf("connect apps: %s: %v", a.ID, err)
+			continue
+		}
+		out = append(out, connectAppButton{Text: "📲 " + a.Name, URL: u})
+	}
+	return out
+}
+
+func (s *Service) handleConnectApps(c telebot.Context, serviceID string) error {
+	us, user, err := s.loadOwnedUserService(c.Chat().ID, serviceID)
+	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			return s.showRegistrationMenu(c)
+		}
+		if errors.Is(err, service.ErrUserServiceUnavailable) {
+			return c.Send("⚠️ Услуга не найдена или недоступна")
+		}
+		log.Printf("Ошибка при проверке услуги: %v", err)
+		return c.Send("⚠️ Произошла ошибка при получении информации по услуге")
+	}
+	if s.isPremiumAntiBlock(us) {
+		return s.replyPremiumPlainKeyBlocked(c, us)
+	}
+
+	buttons := connectAppButtons(s.config, user.ID, us.ServiceID)
+	if len(buttons) == 0 {
+		return c.Send("⚠️ Открытие в приложении сейчас недоступно. Используйте ссылку подписки.")
+	}
+	menu := &telebot.ReplyMarkup{}
+	var rows []telebot.Row
+	for i := 0; i < len(buttons); i += 2 {
+		row := telebot.Row{menu.URL(buttons[i].Text, buttons[i].URL)}
+		if i+1 < len(buttons) {
+			row = append(row, menu.URL(buttons[i+1].Text, buttons[i+1].URL))
+		}
+		rows = append(rows, row)
+	}
+	rows = append(rows, menu.Row(menu.Data("⇦ Назад", "/list", "")))
+	menu.Inline(rows...)
+	return c.Send("Выберите приложение — подписка добавится в него автоматически:", menu)
+}
@@ -0,0 +1,36 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ryabkov82/vpnbot/internal/config"
+)
+
+func TestConnectAppButtons(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Brand = config.BrandConfig{ID: "vff", Name: "VFF", PublicBaseURL: "https://vff.example"}
+	cfg.WebSales.OrderTokenSecret = "order-token-secret-order-token-sec"
+
+	buttons := connectAppButtons(cfg, 10, 336)
+	if len(buttons) != 6 {
+		t.Fatalf("want 6 apps, got %d", len(buttons))
+	}
+	for _, b := range buttons {
+		if !strings.HasPrefix(b.URL, "https://vff.example/connect/open?app=") || !strings.Contains(b.URL, "&token=") {
+			t.Fatalf("%+v", b)
+		}
+	}
+	if buttons[0].Text != "📲 Happ" {
+		t.Fatalf("first %+v", buttons[0])
+	}
+}
+
+func TestConnectAppButtons_NoBaseURL(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Brand = config.BrandConfig{ID: "vff"}
+	cfg.WebSales.OrderTokenSecret = "order-token-secret-order-token-sec"
+	if got := connectAppButtons(cfg, 10, 336); len(got) != 0 {
+		t.Fatalf("%+v", got)
+	}
+}
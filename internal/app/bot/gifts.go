@@ -0,0 +1,507 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/telebot.v3"
+
+	"github.com/ryabkov82/vpnbot/internal/attribution"
+	"github.com/ryabkov82/vpnbot/internal/models"
+	"github.com/ryabkov82/vpnbot/internal/service"
+)
+
+// giftRefundsInterval — как часто возвращать покупателям деньги за незабранные подарки.
+const giftRefundsInterval = 5 * time.Minute
+
+// giftsListLimit — сколько последних подарков показывать в /gifts.
+const giftsListLimit = 10
+
+const giftDisabledText = "🎁 Подарки сейчас недоступны."
+
+func (h *BotHandler) handleGift(c telebot.Context) error {
+	return h.service.handleGift(c, strings.TrimSpace(c.Message().Payload))
+}
+
+func (h *BotHandler) handleGifts(c telebot.Context) error {
+	return h.service.handleGifts(c)
+}
+
+func (h *BotHandler) handleContact(c telebot.Context) error {
+	return h.service.handleGiftContact(c)
+}
+
+// giftTarget — получатель в callback-данных: "" — любой по ссылке, "@name" — username, "c<id>" — Telegram ID из контакта.
+func giftTarget(username string, chatID int64) string {
+	if chatID > 0 {
+		return "c" + strconv.FormatInt(chatID, 10)
+	}
+	if u := service.NormalizeTelegramUsername(username); u != "" {
+		return "@" + u
+	}
+	return ""
+}
+
+func parseGiftTarget(t string) (username string, chatID int64) {
+	if rest, ok := strings.CutPrefix(t, "c"); ok {
+		id, _ := strconv.ParseInt(rest, 10, 64)
+		return "", id
+	}
+	return service.NormalizeTelegramUsername(t), 0
+}
+
+// validTelegramUsername — 5–32 символа: латиница, цифры и подчёркивание.
+func validTelegramUsername(u string) bool {
+	if len(u) < 5 || len(u) > 32 {
+		return false
+	}
+	for _, r := range u {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+func giftRecipientLabel(username string, chatID int64) string {
+	switch {
+	case chatID > 0:
+		return "выбранному контакту"
+	case username != "":
+		return "@" + username
+	}
+	return ""
+}
+
+// handleGift — /gift [@username]: выбор услуги в подарок.
+func (s *Service) handleGift(c telebot.Context, payload string) error {
+	if !s.config.Gifts.Enabled {
+		return c.Send(giftDisabledText)
+	}
+	username := service.NormalizeTelegramUsername(payload)
+	if username != "" && !validTelegramUsername(username) {
+		return c.Send("⚠️ Укажите username получателя, например: /gift @friend")
+	}
+	return s.showGiftCatalog(c, username, 0)
+}
+
+// handleGiftContact — пользователь прислал контакт: подарок будет адресован этому Telegram-аккаунту.
+func (s *Service) handleGiftContact(c telebot.Context) error {
+	if !s.config.Gifts.Enabled || c.Message() == nil || c.Message().Contact == nil {
+		return nil
+	}
+	contact := c.Message().Contact
+	if contact.UserID <= 0 {
+		return c.Send("⚠️ У этого контакта нет Telegram. Отправьте ссылку на подарок вручную: /gift")
+	}
+	if contact.UserID == c.Sender().ID {
+		return c.Send("⚠️ Подарок нельзя адресовать самому себе.")
+	}
+	return s.showGiftCatalog(c, "", contact.UserID)
+}
+
+func (s *Service) showGiftCatalog(c telebot.Context, username string, chatID int64) error {
+	user, err := s.service.GetUser(c.Sender().ID)
+	if err != nil {
+		return c.Send("⚠️ Не удалось получить данные пользователя")
+	}
+	if user == nil {
+		return s.showRegistrationMenu(c)
+	}
+	services, err := s.service.GetServices()
+	if err != nil {
+		log.Printf("gift: services: %v", err)
+		return c.Send("⚠️ Не удалось загрузить список услуг. Попробуйте позже.")
+	}
+	target := giftTarget(username, chatID)
+	trialID := s.config.Features.Trial.BaseServiceID
+	var rows [][]telebot.InlineButton
+	for _, svc := range services {
+		if svc.Cost <= 0 || (trialID > 0 && svc.ServiceID == trialID) {
+			continue
+		}
+		rows = append(rows, []telebot.InlineButton{{
+			Text: fmt.Sprintf("🎁 %s — %s", svc.Name, models.FormatRubAmount(svc.Cost)),
+			Data: "/gift_svc|" + strconv.Itoa(svc.ServiceID) + "|" + target,
+		}})
+	}
+	rows = append(rows, []telebot.InlineButton{{Text: "📦 Мои подарки", Data: "/gifts"}}, []telebot.InlineButton{{Text: "⇦ Назад", Data: "/menu"}})
+	return s.editOrSend(c, giftCatalogText(giftRecipientLabel(username, chatID), len(rows) > 2), rows)
+}
+
+func giftCatalogText(recipient string, any bool) string {
+	if !any {
+		return "🎁 Сейчас нет услуг, которые можно подарить."
+	}
+	var b strings.Builder
+	b.WriteString("🎁 Выберите услугу в подарок")
+	if recipient != "" {
+		b.WriteString(" для " + recipient)
+	}
+	b.WriteString(".\n\nСтоимость спишется с вашего баланса, а вы получите ссылку для получателя.")
+	if recipient == "" {
+		b.WriteString("\nЧтобы подарок мог забрать только конкретный человек, отправьте /gift @username или поделитесь его контактом.")
+	}
+	return b.String()
+}
+
+// handleGiftService — подтверждение покупки подарка.
+func (s *Service) handleGiftService(c telebot.Context, serviceIDStr, target string) error {
+	if !s.config.Gifts.Enabled {
+		return c.Send(giftDisabledText)
+	}
+	sid, err := strconv.Atoi(serviceIDStr)
+	if err != nil {
+		return c.Send("⚠️ Некорректная услуга")
+	}
+	svc, err := s.service.GetServiceByID(sid)
+	if err != nil || svc == nil || !orderServiceCategoryAllowed(s.config, svc) {
+		return c.Send("⚠️ Эту услугу нельзя подарить.")
+	}
+	user, err := s.service.GetUser(c.Sender().ID)
+	if err != nil || user == nil {
+		return c.Send("⚠️ Не удалось получить данные пользователя")
+	}
+	bal, err := s.service.GetUserBalanceByUserID(user.ID)
+	if err != nil || bal == nil {
+		return c.Send("⚠️ Не удалось получить баланс")
+	}
+	username, chatID := parseGiftTarget(target)
+	text := giftConfirmText(svc.Name, svc.Cost, bal.Balance, giftRecipientLabel(username, chatID), s.config.Gifts.RefundAfter())
+	rows := [][]telebot.InlineButton{}
+	if bal.Balance+0.005 >= svc.Cost {
+		rows = append(rows, []telebot.InlineButton{{Text: "✅ Подарить", Data: "/gift_buy|" + serviceIDStr + "|" + target}})
+	} else {
+		rows = append(rows, []telebot.InlineButton{{Text: "💰 Пополнить баланс", Data: "/balance"}})
+	}
+	rows = append(rows, []telebot.InlineButton{{Text: "⇦ Назад", Data: "/gift"}})
+	return s.editOrSend(c, text, rows)
+}
+
+func giftConfirmText(name string, cost, balance float64, recipient string, ttl time.Duration) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "🎁 Подарок: «%s»\nСтоимость: %s\nВаш баланс: %s\n", strings.TrimSpace(name), models.FormatRubAmount(cost), models.FormatRubAmount(balance))
+	if recipient != "" {
+		b.WriteString("Получатель: " + recipient + "\n")
+	}
+	if balance+0.005 < cost {
+		fmt.Fprintf(&b, "\nНе хватает %s — пополните баланс.", models.FormatRubAmount(cost-balance))
+		return b.String()
+	}
+	fmt.Fprintf(&b, "\nСтоимость спишется с баланса сразу. Если подарок не заберут за %d ч, деньги вернутся.", int(ttl.Hours()))
+	return b.String()
+}
+
+// handleGiftBuy — списание с баланса и ссылка для получателя.
+func (s *Service) handleGiftBuy(c telebot.Context, serviceIDStr, target string) error {
+	if !s.config.Gifts.Enabled {
+		return c.Send(giftDisabledText)
+	}
+	sid, err := strconv.Atoi(serviceIDStr)
+	if err != nil {
+		return c.Send("⚠️ Некорректная услуга")
+	}
+	// Повторное нажатие, пока идёт списание, не создаёт второй подарок.
+	key := fmt.Sprintf("gift:%d", c.Chat().ID)
+	s.serviceBuyMu.Lock()
+	if _, busy := s.serviceBuyInFlight[key]; busy {
+		s.serviceBuyMu.Unlock()
+		return nil
+	}
+	s.serviceBuyInFlight[key] = struct{}{}
+	s.serviceBuyMu.Unlock()
+	defer func() {
+		s.serviceBuyMu.Lock()
+		delete(s.serviceBuyInFlight, key)
+		s.serviceBuyMu.Unlock()
+	}()
+
+	user, err := s.service.GetUser(c.Sender().ID)
+	if err != nil || user == nil {
+		return c.Send("⚠️ Не удалось получить данные пользователя")
+	}
+	username, chatID := parseGiftTarget(target)
+	g, err := s.service.CreateGift(service.GiftRequest{
+		BuyerID:           user.ID,
+		ServiceID:         sid,
+		RecipientUsername: username,
+		RecipientChatID:   chatID,
+		TTL:               s.config.Gifts.RefundAfter(),
+		PaySystem:         s.config.Gifts.SHMPaySystem(),
+	})
+	if err != nil {
+		if !errors.Is(err, service.ErrGiftInsufficientBalance) && !errors.Is(err, service.ErrServiceCategoryDenied) {
+			log.Printf("gift: user_id=%d service=%d: %v", user.ID, sid, err)
+		}
+		return c.Send(giftErrorText(err))
+	}
+	link := s.giftLink(c, user.ID, g.ID)
+	rows := [][]telebot.InlineButton{
+		{{Text: "📤 Переслать ссылку", URL: "https://t.me/share/url?url=" + url.QueryEscape(link) + "&text=" + url.QueryEscape("🎁 Вам подарок: "+g.ServiceName)}},
+		{{Text: "📦 Мои подарки", Data: "/gifts"}},
+	}
+	if err := s.editOrSend(c, giftCreatedText(g, link), rows); err != nil {
+		return err
+	}
+	// Получатель из контакта уже может быть в боте — сообщаем ему сразу.
+	if g.RecipientChatID > 0 {
+		if _, err := c.Bot().Send(telebot.ChatID(g.RecipientChatID), giftCardText(g), giftClaimMarkup(user.ID, g.ID)); err != nil {
+			log.Printf("gift: notify recipient chat=%d: %v", g.RecipientChatID, err)
+		}
+	}
+	return nil
+}
+
+func (s *Service) giftLink(c telebot.Context, buyerID int, giftID string) string {
+	bot := strings.TrimPrefix(strings.TrimSpace(s.config.Telegram.BotUsername), "@")
+	if bot == "" && c.Bot() != nil && c.Bot().Me != nil {
+		bot = c.Bot().Me.Username
+	}
+	return "https://t.me/" + bot + "?start=" + service.GiftStartParam(buyerID, giftID)
+}
+
+func giftExpiry(g *service.Gift) string {
+	return g.ExpiresAt.In(botMoscow).Format("02.01.2006 15:04")
+}
+
+func giftCreatedText(g *service.Gift, link string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "🎁 Подарок «%s» оплачен: с баланса списано %s.\n\nПерешлите получателю ссылку:\n%s\n\n", g.ServiceName, models.FormatRubAmount(g.Amount), link)
+	if label := giftRecipientLabel(g.RecipientUsername, g.RecipientChatID); label != "" {
+		b.WriteString("Забрать подарок сможет только получатель: " + label + ".\n")
+	}
+	fmt.Fprintf(&b, "Если подарок не заберут до %s (МСК), деньги вернутся на баланс.", giftExpiry(g))
+	return b.String()
+}
+
+func giftCardText(g *service.Gift) string {
+	return fmt.Sprintf("🎁 Вам подарили «%s»!\n\nНажмите «Получить подарок» — услуга будет оформлена на ваш аккаунт. Подарок действует до %s (МСК).", g.ServiceName, giftExpiry(g))
+}
+
+func giftClaimMarkup(buyerID int, giftID string) *telebot.ReplyMarkup {
+	return &telebot.ReplyMarkup{InlineKeyboard: [][]telebot.InlineButton{{{Text: "🎁 Получить подарок", Data: "/gift_claim|" + strconv.Itoa(buyerID) + "|" + giftID}}}}
+}
+
+func isGiftStartPayload(payload string) bool {
+	_, _, ok := service.ParseGiftStartParam(payload)
+	return ok
+}
+
+// handleGiftStart — /start gift_…: карточка подарка. Для нового пользователя запоминается
+// first-touch канала gift, чтобы регистрация при получении подарка записала, кто подарил.
+func (s *Service) handleGiftStart(c telebot.Context, payload string, capturedAt time.Time) error {
+	buyerID, giftID, _ := service.ParseGiftStartParam(payload)
+	g, err := s.service.PeekGift(buyerID, giftID)
+	if err != nil {
+		if !errors.Is(err, service.ErrGiftNotFound) {
+			log.Printf("gift start: buyer=%d gift=%s: %v", buyerID, giftID, err)
+		}
+		return c.Send(giftErrorText(err))
+	}
+	user, err := s.service.GetUser(c.Chat().ID)
+	if err != nil {
+		log.Println("Ошибка проверки пользователя:", err)
+		return c.Send("Ошибка системы, попробуйте позже")
+	}
+	if user == nil {
+		rec, aerr := buildGiftRegistrationAttribution(s.config, payload, buyerID, capturedAt)
+		if aerr != nil {
+			log.Println("Ошибка attribution при /start:", aerr)
+			return c.Send("Ошибка системы, попробуйте позже")
+		}
+		// Ссылка подарка важнее ранее сохранённого start-параметра.
+		s.clearTelegramAttribution(c.Chat().ID)
+		s.rememberTelegramAttribution(c.Chat().ID, rec, capturedAt)
+	} else {
+		s.clearTelegramAttribution(c.Chat().ID)
+	}
+	switch {
+	case user != nil && g.RecipientUserID == user.ID:
+		return c.Send(giftClaimText(g), &telebot.ReplyMarkup{InlineKeyboard: giftClaimedRows(g, buyerID)})
+	case user != nil && user.ID == buyerID:
+		return c.Send(giftErrorText(service.ErrGiftOwn))
+	case g.Status == service.GiftRefunding || g.Status == service.GiftRefunded:
+		return c.Send(giftErrorText(service.ErrGiftExpired))
+	case g.RecipientUserID != 0:
+		return c.Send(giftErrorText(service.ErrGiftTaken))
+	}
+	return c.Send(giftCardText(g), giftClaimMarkup(buyerID, g.ID))
+}
+
+// handleGiftClaim — «Получить подарок»: регистрирует нового пользователя (канал gift) и выдаёт подарок.
+func (s *Service) handleGiftClaim(c telebot.Context, buyerIDStr, giftID string) error {
+	buyerID, err := strconv.Atoi(buyerIDStr)
+	if err != nil {
+		return nil
+	}
+	if cb := c.Callback(); cb != nil {
+		_ = c.Respond()
+	}
+	chatID := c.Chat().ID
+	user, err := s.service.GetUser(chatID)
+	if err != nil {
+		return c.Send("⚠️ Не удалось получить данные пользователя")
+	}
+	if user == nil {
+		now := time.Now()
+		rec, ok := s.peekTelegramAttribution(chatID, now)
+		if !ok || rec.FirstTouch.RegistrationChannel != attribution.RegistrationChannelGift || rec.FirstTouch.GiftedByUserID != buyerID {
+			rec, err = buildGiftRegistrationAttribution(s.config, service.GiftStartParam(buyerID, giftID), buyerID, now)
+			if err != nil {
+				log.Println("Ошибка attribution при получении подарка:", err)
+				return c.Send("⚠️ Ошибка регистрации. Пожалуйста, попробуйте позже.")
+			}
+		}
+		user, err = s.registerTelegramUser(c, rec)
+		if err != nil || user == nil {
+			return c.Send("⚠️ Ошибка регистрации. Пожалуйста, попробуйте позже.")
+		}
+	}
+	g, err := s.service.ClaimGift(service.GiftClaimRequest{
+		BuyerID:           buyerID,
+		GiftID:            giftID,
+		RecipientUserID:   user.ID,
+		RecipientUsername: c.Sender().Username,
+		RecipientChatID:   c.Sender().ID,
+		PaySystem:         s.config.Gifts.SHMPaySystem(),
+	})
+	if err != nil {
+		log.Printf("gift claim: buyer=%d gift=%s user_id=%d: %v", buyerID, giftID, user.ID, err)
+		return c.Send(giftErrorText(err))
+	}
+	if err := c.Send(giftClaimText(g), &telebot.ReplyMarkup{InlineKeyboard: giftClaimedRows(g, buyerID)}); err != nil {
+		log.Printf("gift claim: send: %v", err)
+	}
+	if g.Status == service.GiftClaimed {
+		s.notifyGiftBuyer(c.Bot(), buyerID, fmt.Sprintf("🎁 Ваш подарок «%s» получен. Спасибо!", g.ServiceName))
+	}
+	return nil
+}
+
+func giftClaimText(g *service.Gift) string {
+	switch g.Status {
+	case service.GiftClaimed, service.GiftOrdering:
+		return fmt.Sprintf("🎁 Подарок получен: услуга «%s» оформлена на ваш аккаунт. Ключ доступа — в разделе «Ключи» (/list).", g.ServiceName)
+	case service.GiftExistingUnpaid:
+		return fmt.Sprintf("🎁 На баланс зачислено %s, но услугу «%s» автоматически оформить не удалось: у вас есть неоплаченная услуга. Напишите в поддержку — поможем оформить подарок.", models.FormatRubAmount(g.Amount), g.ServiceName)
+	}
+	return fmt.Sprintf("🎁 На баланс зачислено %s, но оформить услугу «%s» не удалось. Нажмите «Получить подарок» ещё раз или напишите в поддержку.", models.FormatRubAmount(g.Amount), g.ServiceName)
+}
+
+func giftClaimedRows(g *service.Gift, buyerID int) [][]telebot.InlineButton {
+	if g.Status == service.GiftOrderFailed {
+		return giftClaimMarkup(buyerID, g.ID).InlineKeyboard
+	}
+	return [][]telebot.InlineButton{{{Text: "🔑 Мои ключи", Data: "/list"}, {Text: "☰ Меню", Data: "/menu"}}}
+}
+
+// giftErrorText — сообщение пользователю по ошибке подарка (nil — общий сбой).
+func giftErrorText(err error) string {
+	switch {
+	case errors.Is(err, service.ErrGiftNotFound):
+		return "⚠️ Подарок не найден. Проверьте ссылку."
+	case errors.Is(err, service.ErrGiftExpired):
+		return "⌛ Срок получения подарка истёк, деньги возвращаются отправителю."
+	case errors.Is(err, service.ErrGiftTaken):
+		return "⚠️ Этот подарок уже получен."
+	case errors.Is(err, service.ErrGiftNotForYou):
+		return "⚠️ Этот подарок предназначен другому пользователю Telegram."
+	case errors.Is(err, service.ErrGiftOwn):
+		return "⚠️ Это ваш подарок — перешлите ссылку получателю."
+	case errors.Is(err, service.ErrGiftInsufficientBalance):
+		return "⚠️ На балансе не хватает средств для подарка. Пополните баланс: /balance"
+	case errors.Is(err, service.ErrServiceCategoryDenied):
+		return "⚠️ Эту услугу нельзя подарить."
+	}
+	return "⚠️ Не удалось выполнить операцию с подарком. Попробуйте позже."
+}
+
+// handleGifts — /gifts: последние подарки покупателя и их статус.
+func (s *Service) handleGifts(c telebot.Context) error {
+	user, err := s.service.GetUser(c.Sender().ID)
+	if err != nil || user == nil {
+		return c.Send("⚠️ Не удалось получить данные пользователя")
+	}
+	list, err := s.service.UserGifts(user.ID)
+	if err != nil {
+		log.Printf("gifts: user_id=%d: %v", user.ID, err)
+		return c.Send("⚠️ Не удалось получить список подарков")
+	}
+	rows := [][]telebot.InlineButton{{{Text: "🎁 Подарить", Data: "/gift"}}, {{Text: "⇦ Назад", Data: "/menu"}}}
+	return s.editOrSend(c, giftsListText(list, func(g service.Gift) string { return s.giftLink(c, user.ID, g.ID) }), rows)
+}
+
+func giftsListText(list []service.Gift, link func(service.Gift) string) string {
+	if len(list) == 0 {
+		return "🎁 Вы ещё не дарили подписки. Подарить: /gift"
+	}
+	var b strings.Builder
+	b.WriteString("🎁 Ваши подарки:\n")
+	for i, g := range list {
+		if i == giftsListLimit {
+			break
+		}
+		fmt.Fprintf(&b, "\n%s «%s» — %s\n", g.CreatedAt.In(botMoscow).Format("02.01.2006"), g.ServiceName, models.FormatRubAmount(g.Amount))
+		switch g.Status {
+		case service.GiftPending, service.GiftDebiting:
+			fmt.Fprintf(&b, "Ждёт получателя до %s (МСК): %s\n", giftExpiry(&g), link(g))
+		case service.GiftClaimed, service.GiftOrdering, service.GiftClaiming, service.GiftOrderFailed, service.GiftExistingUnpaid:
+			b.WriteString("Получен ✅\n")
+		case service.GiftRefunding, service.GiftRefunded:
+			b.WriteString("Не забран — деньги возвращены на баланс ↩️\n")
+		}
+	}
+	return b.String()
+}
+
+// StartGiftRefunds периодически возвращает покупателям деньги за подарки, которые не забрали в срок,
+// и сообщает об этом в Telegram. Блокирует; запускать в отдельной goroutine.
+func (s *Service) StartGiftRefunds(sender trafficAlertSender) {
+	if !s.config.Gifts.Enabled {
+		return
+	}
+	ticker := time.NewTicker(giftRefundsInterval)
+	defer ticker.Stop()
+	for {
+		<-ticker.C
+		s.runGiftRefunds(sender)
+	}
+}
+
+func (s *Service) runGiftRefunds(sender trafficAlertSender) {
+	// Очередь возвратов живёт в памяти: после рестарта её восстанавливают списания подарков в SHM.
+	if err := s.service.DiscoverGiftBuyers(s.config.Gifts.SHMPaySystem(), s.config.Gifts.RefundAfter()); err != nil {
+		log.Printf("gift refunds: поиск подарков: %v", err)
+	}
+	for _, buyerID := range s.service.PendingGiftBuyers() {
+		refunded, err := s.service.RefundExpiredGifts(buyerID, s.config.Gifts.SHMPaySystem())
+		if err != nil {
+			log.Printf("gift refunds: user %d: %v", buyerID, err)
+		}
+		for _, g := range refunded {
+			s.notifyGiftBuyer(sender, buyerID, giftRefundedText(g))
+		}
+	}
+}
+
+func giftRefundedText(g service.Gift) string {
+	return fmt.Sprintf("↩️ Подарок «%s» не забрали вовремя — %s вернулись на ваш баланс.", g.ServiceName, models.FormatRubAmount(g.Amount))
+}
+
+func (s *Service) notifyGiftBuyer(sender trafficAlertSender, buyerID int, text string) {
+	if sender == nil {
+		return
+	}
+	buyer, err := s.service.GetUserByID(buyerID)
+	if err != nil || buyer == nil || buyer.Settings.Telegram.ChatID <= 0 {
+		return
+	}
+	if _, err := sender.Send(telebot.ChatID(buyer.Settings.Telegram.ChatID), text); err != nil {
+		log.Printf("gift: notify buyer %d: %v", buyerID, err)
+	}
+}
@@ -0,0 +1,65 @@
+package bot
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/service"
+)
+
+func TestGiftTargetRoundTrip(t *testing.T) {
+	if got := giftTarget("@Friend_01", 0); got != "@friend_01" {
+		t.Fatal(got)
+	}
+	if u, id := parseGiftTarget(giftTarget("", 12345)); u != "" || id != 12345 {
+		t.Fatalf("%q %d", u, id)
+	}
+	if u, id := parseGiftTarget(""); u != "" || id != 0 {
+		t.Fatalf("%q %d", u, id)
+	}
+	if validTelegramUsername("ab") || validTelegramUsername("bad-name") || !validTelegramUsername("friend_01") {
+		t.Fatal("username validation")
+	}
+	if !isGiftStartPayload("gift_42_abcd") || isGiftStartPayload("gift_x_abcd") || isGiftStartPayload("link_abc") {
+		t.Fatal("start payload detection")
+	}
+}
+
+func TestGiftTexts(t *testing.T) {
+	g := &service.Gift{ID: "abcd", ServiceName: "Premium 1m", Amount: 450, RecipientUsername: "friend",
+		ExpiresAt: time.Date(2026, 10, 21, 9, 0, 0, 0, time.UTC), Status: service.GiftPending}
+	got := giftCreatedText(g, "https://t.me/vpnbot?start=gift_42_abcd")
+	for _, want := range []string{"«Premium 1m» оплачен: с баланса списано 450 ₽", "start=gift_42_abcd", "только получатель: @friend", "до 21.10.2026 12:00 (МСК)"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("missing %q in %q", want, got)
+		}
+	}
+	if got := giftConfirmText("Premium 1m", 450, 100, "", 72*time.Hour); !strings.Contains(got, "Не хватает 350 ₽") {
+		t.Fatal(got)
+	}
+	if got := giftConfirmText("Premium 1m", 450, 500, "@friend", 72*time.Hour); !strings.Contains(got, "Получатель: @friend") || !strings.Contains(got, "за 72 ч") {
+		t.Fatal(got)
+	}
+	failed := *g
+	failed.Status = service.GiftOrderFailed
+	if got := giftClaimText(&failed); !strings.Contains(got, "зачислено 450 ₽") {
+		t.Fatal(got)
+	}
+	if rows := giftClaimedRows(&failed, 42); rows[0][0].Data != "/gift_claim|42|abcd" {
+		t.Fatalf("%+v", rows)
+	}
+	unpaid := *g
+	unpaid.Status = service.GiftExistingUnpaid
+	if got := giftClaimText(&unpaid); !strings.Contains(got, "неоплаченная услуга") || !strings.Contains(got, "поддержку") {
+		t.Fatal(got)
+	}
+	if got := giftErrorText(errors.Join(errors.New("x"), service.ErrGiftNotForYou)); !strings.Contains(got, "другому пользователю") {
+		t.Fatal(got)
+	}
+	list := giftsListText([]service.Gift{*g, {ServiceName: "Basic", Amount: 100, Status: service.GiftRefunded}}, func(service.Gift) string { return "LINK" })
+	if !strings.Contains(list, "Ждёт получателя до 21.10.2026 12:00 (МСК): LINK") || !strings.Contains(list, "деньги возвращены") {
+		t.Fatal(list)
+	}
+}
@@ -26,6 +26,13 @@ func (h *BotHandler) RegisterHandlers(bot *telebot.Bot) {
 	bot.Handle("/pricelist", h.handlePricelist)
 	bot.Handle("/balance", h.handleBalance)
 	bot.Handle("/account", h.handleAccount)
+	bot.Handle("/status", h.handleStatus)
+	bot.Handle("/history", h.handleHistory)
+	bot.Handle("/redeem", h.handleRedeem)
+	bot.Handle("/gift", h.handleGift)
+	bot.Handle("/gifts", h.handleGifts)
+	// Контакт получателя подарка
+	bot.Handle(telebot.OnContact, h.handleContact)
 	// Callback-кнопки
 	bot.Handle(telebot.OnCallback, h.handleCallbacks)
 	/*
@@ -108,6 +115,10 @@ func (h *BotHandler) handleAccount(c telebot.Context) error {
 	return h.service.handleAccount(c)
 }
 
+func (h *BotHandler) handleStatus(c telebot.Context) error {
+	return h.service.handleStatus(c)
+}
+
 func (h *BotHandler) handlePays(c telebot.Context) error {
 	return h.service.handlePays(c)
 }
@@ -159,6 +170,16 @@ func (h *BotHandler) handleCallbacks(c telebot.Context) error {
 		return h.handleDeleteConfirmed(c, serviceIDStr)
 	case "/pricelist":
 		return h.handlePricelist(c)
+	case "/status":
+		return h.handleStatus(c)
+	case "/topup":
+		switch len(parts) {
+		case 2:
+			return h.service.handleTopupProvider(c, parts[1])
+		case 3:
+			return h.service.handleTopupAmount(c, parts[1], parts[2])
+		}
+		return nil
 	case "/serviceorder":
 		// Старые inline-кнопки: ведём на preview, а не на мгновенный заказ
 		if len(parts) < 2 {
@@ -175,13 +196,127 @@ func (h *BotHandler) handleCallbacks(c telebot.Context) error {
 			return nil
 		}
 		return h.handleServiceBuy(c, parts[1])
+	case "order_pay":
+		if len(parts) < 3 {
+			return nil
+		}
+		return h.service.handleOrderPay(c, parts[1], parts[2])
 	case "/help":
 		return h.handleHelp(c)
 	case "/pays":
 		return h.handlePays(c)
+	case "/history":
+		if len(parts) < 2 {
+			return h.handleHistory(c)
+		}
+		return h.service.handleHistory(c, parts[1])
+	case "/ledger":
+		if len(parts) < 2 {
+			return h.service.handleLedger(c, "0")
+		}
+		return h.service.handleLedger(c, parts[1])
+	case "/gift":
+		return h.service.handleGift(c, "")
+	case "/gifts":
+		return h.handleGifts(c)
+	case "/gift_svc":
+		if len(parts) < 3 {
+			return nil
+		}
+		return h.service.handleGiftService(c, parts[1], parts[2])
+	case "/gift_buy":
+		if len(parts) < 3 {
+			return nil
+		}
+		return h.service.handleGiftBuy(c, parts[1], parts[2])
+	case "/gift_claim":
+		if len(parts) < 3 {
+			return nil
+		}
+		return h.service.handleGiftClaim(c, parts[1], parts[2])
+	case "/autorenew":
+		if len(parts) < 3 {
+			return nil
+		}
+		return h.service.handleAutoRenew(c, parts[1], parts[2])
+	case "/renew":
+		if len(parts) < 2 {
+			return nil
+		}
+		return h.service.handleRenew(c, parts[1])
+	case "/renew_ok":
+		if len(parts) < 3 {
+			return nil
+		}
+		return h.service.handleRenewConfirmed(c, parts[1], parts[2])
+	case "/plan":
+		if len(parts) < 2 {
+			return nil
+		}
+		return h.service.handlePlanChange(c, parts[1])
+	case "/plan_q":
+		if len(parts) < 3 {
+			return nil
+		}
+		return h.service.handlePlanQuote(c, parts[1], parts[2])
+	case "/plan_ok":
+		if len(parts) < 4 {
+			return nil
+		}
+		return h.service.handlePlanConfirmed(c, parts[1], parts[2], parts[3])
+	case "/receipt":
+		if len(parts) < 2 {
+			return nil
+		}
+		return h.service.handleReceipt(c, parts[1])
+	case "/statement":
+		if len(parts) < 2 {
+			return nil
+		}
+		return h.service.handleStatement(c, parts[1])
 	case "/show_mz_keys":
 		serviceIDStr := parts[1]
 		return h.handleShowMZ(c, serviceIDStr)
+	case "/connect_apps":
+		if len(parts) < 2 {
+			return nil
+		}
+		return h.service.handleConnectApps(c, parts[1])
+	case "/devices":
+		if len(parts) < 2 {
+			return nil
+		}
+		return h.service.handlePremiumDevices(c, parts[1])
+	case "/device_del":
+		if len(parts) < 3 {
+			return nil
+		}
+		return h.service.handlePremiumDeviceDelete(c, parts[1], parts[2])
+	case "/devices_clear":
+		if len(parts) < 2 {
+			return nil
+		}
+		return h.service.handlePremiumDevicesClear(c, parts[1])
+	case "/devices_clear_ok":
+		if len(parts) < 2 {
+			return nil
+		}
+		return h.service.handlePremiumDevicesClearConfirmed(c, parts[1])
+	case "/premium_usage":
+		if len(parts) < 2 {
+			return nil
+		}
+		return h.service.handlePremiumUsage(c, parts[1])
+	case "/premium_reset":
+		if len(parts) < 2 {
+			return nil
+		}
+		return h.service.handlePremiumReset(c, parts[1])
+	case "/premium_reset_ok":
+		if len(parts) < 3 {
+			return nil
+		}
+		return h.service.handlePremiumResetConfirmed(c, parts[1], parts[2])
 	default:
 		return c.Respond(&telebot.CallbackResponse{
 			Text: "Неизвестная команда",
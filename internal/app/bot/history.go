@@ -0,0 +1,140 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"gopkg.in/telebot.v3"
+
+	"github.com/ryabkov82/vpnbot/internal/models"
+	"github.com/ryabkov82/vpnbot/internal/service"
+)
+
+// Размер страницы /history (списания) и журнала баланса в боте.
+const (
+	historyPageSize = 5
+	ledgerPageSize  = 10
+)
+
+func (h *BotHandler) handleHistory(c telebot.Context) error {
+	return h.service.handleHistory(c, "0")
+}
+
+// handleHistory — списания за услуги страницами (новые сначала): что, за какой период, цена, скидка, бонусы.
+func (s *Service) handleHistory(c telebot.Context, offsetStr string) error {
+	offset, _ := strconv.Atoi(strings.TrimSpace(offsetStr))
+	user, err := s.service.GetUser(c.Sender().ID)
+	if err != nil || user == nil {
+		return c.Send("⚠️ Не удалось получить данные пользователя")
+	}
+	list, total, err := s.service.UserWithdrawals(user.ID, historyPageSize, offset)
+	if err != nil {
+		log.Printf("history: user_id=%d: %v", user.ID, err)
+		return c.Send("⚠️ Не удалось получить историю списаний")
+	}
+	rows := historyPager("/history", total, offset, historyPageSize)
+	rows = append(rows, []telebot.InlineButton{{Text: "📒 Движение баланса", Data: "/ledger|0"}}, []telebot.InlineButton{{Text: "⇦ Назад", Data: "/menu"}})
+	return s.editOrSend(c, withdrawalsHistoryText(list, total, offset), rows)
+}
+
+// handleLedger — платежи и списания одной лентой с балансом после каждой операции.
+func (s *Service) handleLedger(c telebot.Context, offsetStr string) error {
+	offset, _ := strconv.Atoi(strings.TrimSpace(offsetStr))
+	user, err := s.service.GetUser(c.Sender().ID)
+	if err != nil || user == nil {
+		return c.Send("⚠️ Не удалось получить данные пользователя")
+	}
+	l, err := s.service.UserLedger(user.ID)
+	if err != nil {
+		log.Printf("ledger: user_id=%d: %v", user.ID, err)
+		return c.Send("⚠️ Не удалось получить движение баланса")
+	}
+	rows := historyPager("/ledger", len(l.Entries), offset, ledgerPageSize)
+	rows = append(rows, []telebot.InlineButton{{Text: "🧾 Списания", Data: "/history|0"}, {Text: "☰ Платежи", Data: "/pays"}}, []telebot.InlineButton{{Text: "⇦ Назад", Data: "/menu"}})
+	return s.editOrSend(c, ledgerText(l, offset, ledgerPageSize), rows)
+}
+
+// editOrSend — листание страниц правит то же сообщение, команда присылает новое.
+func (s *Service) editOrSend(c telebot.Context, text string, rows [][]telebot.InlineButton) error {
+	markup := &telebot.ReplyMarkup{InlineKeyboard: rows}
+	if cb := c.Callback(); cb != nil && cb.Message != nil && cb.Message.Photo == nil {
+		_ = c.Respond()
+		return c.Edit(text, markup)
+	}
+	return c.Send(text, markup)
+}
+
+// historyPager — кнопки «назад/вперёд» для страницы offset из total записей.
+func historyPager(cmd string, total, offset, limit int) [][]telebot.InlineButton {
+	var row []telebot.InlineButton
+	if offset > 0 {
+		prev := offset - limit
+		if prev < 0 {
+			prev = 0
+		}
+		row = append(row, telebot.InlineButton{Text: "◀ Новее", Data: cmd + "|" + strconv.Itoa(prev)})
+	}
+	if offset+limit < total {
+		row = append(row, telebot.InlineButton{Text: "Старее ▶", Data: cmd + "|" + strconv.Itoa(offset+limit)})
+	}
+	if len(row) == 0 {
+		return nil
+	}
+	return [][]telebot.InlineButton{row}
+}
+
+func withdrawalsHistoryText(list []models.WithdrawItem, total, offset int) string {
+	if total == 0 {
+		return "🧾 Списаний пока нет."
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "🧾 Списания %d–%d из %d\n", offset+1, offset+len(list), total)
+	for _, w := range list {
+		date := w.WithdrawDate
+		if strings.TrimSpace(date) == "" {
+			date = w.CreateDate
+		}
+		fmt.Fprintf(&b, "\n📅 %s — %s\n", date, strings.TrimSpace(w.Name))
+		if w.Months > 0 {
+			period := strconv.FormatFloat(w.Months, 'f', -1, 64) + " мес."
+			if end := strings.TrimSpace(w.EndDate); end != "" {
+				period += " (до " + end + ")"
+			}
+			b.WriteString("Период: " + period + "\n")
+		}
+		details := []string{"цена " + models.FormatRubAmount(w.Cost)}
+		if w.Discount > 0 {
+			details = append(details, "скидка "+strconv.FormatFloat(w.Discount, 'f', -1, 64)+"%")
+		}
+		if w.Bonus > 0 {
+			details = append(details, "бонусами "+models.FormatRubAmount(w.Bonus))
+		}
+		b.WriteString(strings.Join(details, ", ") + "\n")
+		b.WriteString("Списано: " + models.FormatRubAmount(w.Total) + "\n")
+	}
+	return b.String()
+}
+
+func ledgerText(l *service.Ledger, offset, limit int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "📒 Движение баланса\nСейчас на балансе: %s\n", models.FormatRubAmount(l.Balance))
+	if len(l.Entries) == 0 {
+		b.WriteString("\nОпераций пока нет.")
+		return b.String()
+	}
+	for i := offset; i < len(l.Entries) && i < offset+limit; i++ {
+		e := l.Entries[i]
+		what := "пополнение"
+		sign := "+"
+		if e.Kind == service.LedgerWithdraw {
+			what, sign = "списание", ""
+			if e.Withdrawal != nil && strings.TrimSpace(e.Withdrawal.Name) != "" {
+				what = strings.TrimSpace(e.Withdrawal.Name)
+			}
+		}
+		fmt.Fprintf(&b, "\n%s %s%s — %s\n   баланс: %s", e.At.In(botMoscow).Format("02.01.2006 15:04"), sign, models.FormatRubAmount(e.Amount), what, models.FormatRubAmount(e.BalanceAfter))
+	}
+	return b.String()
+}
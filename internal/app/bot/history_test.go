@@ -0,0 +1,45 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/models"
+	"github.com/ryabkov82/vpnbot/internal/service"
+)
+
+func TestWithdrawalsHistoryText(t *testing.T) {
+	list := []models.WithdrawItem{{Name: "Premium", Months: 1, EndDate: "2026-11-01", Cost: 250, Discount: 10, Bonus: 25, Total: 200, WithdrawDate: "2026-10-01 12:00:00"}}
+	got := withdrawalsHistoryText(list, 7, 5)
+	for _, want := range []string{"Списания 6–6 из 7", "2026-10-01 12:00:00 — Premium", "Период: 1 мес. (до 2026-11-01)", "цена 250 ₽, скидка 10%, бонусами 25 ₽", "Списано: 200 ₽"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("missing %q in %q", want, got)
+		}
+	}
+	if got := withdrawalsHistoryText(nil, 0, 0); got != "🧾 Списаний пока нет." {
+		t.Fatal(got)
+	}
+}
+
+func TestHistoryPager(t *testing.T) {
+	if rows := historyPager("/history", 3, 0, 5); rows != nil {
+		t.Fatalf("%+v", rows)
+	}
+	rows := historyPager("/history", 12, 5, 5)
+	if len(rows) != 1 || rows[0][0].Data != "/history|0" || rows[0][1].Data != "/history|10" {
+		t.Fatalf("%+v", rows)
+	}
+}
+
+func TestLedgerText(t *testing.T) {
+	at := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	l := &service.Ledger{Balance: 101, Entries: []service.LedgerEntry{
+		{Kind: service.LedgerWithdraw, At: at, Amount: -199, BalanceAfter: 101, Withdrawal: &models.WithdrawItem{Name: "VPN"}},
+		{Kind: service.LedgerPay, At: at.Add(-time.Hour), Amount: 300, BalanceAfter: 300},
+	}}
+	got := ledgerText(l, 0, 10)
+	if !strings.Contains(got, "Сейчас на балансе: 101 ₽") || !strings.Contains(got, "01.10.2026 12:00 -199 ₽ — VPN\n   баланс: 101 ₽") || !strings.Contains(got, "+300 ₽ — пополнение") {
+		t.Fatal(got)
+	}
+}
@@ -0,0 +1,130 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/app/web"
+	"github.com/ryabkov82/vpnbot/internal/models"
+	"github.com/ryabkov82/vpnbot/internal/payments"
+	"github.com/ryabkov82/vpnbot/internal/service"
+	"gopkg.in/telebot.v3"
+)
+
+// orderPayOption — кнопка доплаты недостающей суммы через провайдера.
+type orderPayOption struct {
+	ProviderID string
+	Label      string
+	Amount     float64
+}
+
+// orderPayOptions — провайдеры бренда, через которые можно доплатить shortfall (сумма подгоняется под минимум провайдера).
+func orderPayOptions(providers []payments.Provider, shortfall float64) []orderPayOption {
+	var out []orderPayOption
+	for _, p := range providers {
+		amount, ok := payments.FitAmount(p, shortfall)
+		if !ok {
+			continue
+		}
+		out = append(out, orderPayOption{ProviderID: p.ID(), Label: p.DisplayName(topupLocale), Amount: amount})
+	}
+	return out
+}
+
+func orderPayText(svc *models.Service, balance, shortfall float64) string {
+	return fmt.Sprintf("Услуга «%s» стоит %s, на балансе %s.\n\nДоплатите %s — после зачисления услуга будет заказана автоматически.",
+		strings.TrimSpace(svc.Name), models.FormatRubAmount(svc.Cost), models.FormatRubAmount(balance), models.FormatRubAmount(shortfall))
+}
+
+// offerOrderPayment — если баланса не хватает, вместо заказа «NOT PAID» предлагает доплату с автоматическим заказом.
+// false — доплата не нужна или невозможна, заказ идёт прежним путём.
+func (s *Service) offerOrderPayment(c telebot.Context, svc *models.Service) (bool, error) {
+	bal, err := s.service.GetUserBalance(c.Chat().ID)
+	if err != nil {
+		return false, nil
+	}
+	shortfall, needsTopUp, invalid := web.OrderTopupShortfall(svc.Cost, bal.Balance)
+	if !needsTopUp || invalid {
+		return false, nil
+	}
+	reg, err := payments.RegistryFromConfig(s.config)
+	if err != nil {
+		log.Printf("order pay: payment registry: %v", err)
+		return false, nil
+	}
+	options := orderPayOptions(reg.Available(s.config.BrandID(), topupLocale), shortfall)
+	if len(options) == 0 {
+		return false, nil
+	}
+	menu := &telebot.ReplyMarkup{}
+	var rows []telebot.Row
+	for _, o := range options {
+		label := fmt.Sprintf("💳 %s — %s", o.Label, models.FormatRubAmount(o.Amount))
+		rows = append(rows, menu.Row(menu.Data(label, "order_pay", strconv.Itoa(svc.ServiceID), o.ProviderID)))
+	}
+	rows = append(rows, menu.Row(menu.Data("⇦ Назад", "/pricelist")))
+	menu.Inline(rows...)
+	return true, c.Send(orderPayText(svc, bal.Balance, shortfall), menu)
+}
+
+// handleOrderPay — ссылка на доплату и намерение заказа: услуга будет заказана после зачисления.
+func (s *Service) handleOrderPay(c telebot.Context, serviceID, providerID string) error {
+	p, ok := s.topupProvider(c, providerID)
+	if !ok {
+		return nil
+	}
+	sid, err := strconv.Atoi(serviceID)
+	if err != nil {
+		return c.Send("⚠️ Некорректная услуга")
+	}
+	svc, err := s.service.GetServiceByID(sid)
+	if err != nil || svc == nil || !orderServiceCategoryAllowed(s.config, svc) {
+		log.Printf("order pay: service %s: %v", serviceID, err)
+		return c.Send("⚠️ Услуга не найдена")
+	}
+	bal, err := s.service.GetUserBalance(c.Chat().ID)
+	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			return s.showRegistrationMenu(c)
+		}
+		log.Printf("order pay: баланс: %v", err)
+		return c.Send("Ошибка системы, попробуйте позже")
+	}
+	shortfall, needsTopUp, invalid := web.OrderTopupShortfall(svc.Cost, bal.Balance)
+	if !needsTopUp {
+		// Баланс пополнили другим способом — заказываем сразу.
+		return s.handleServiceOrder(c, serviceID)
+	}
+	amount, ok := payments.FitAmount(p, shortfall)
+	if invalid || !ok {
+		return c.Send("⚠️ Недопустимая сумма пополнения.")
+	}
+
+	ts := time.Now().Unix()
+	payURL, err := p.PaymentURL(payments.PaymentRequest{
+		BaseURL: s.config.API.BaseURL,
+		UserID:  bal.ID,
+		Amount:  amount,
+		TS:      ts,
+		BrandID: s.config.BrandID(),
+	})
+	if err != nil {
+		log.Printf("order pay: %s payment url: %v", p.ID(), err)
+		return c.Send("⚠️ Не удалось создать ссылку на оплату. Попробуйте позже или обратитесь в поддержку.")
+	}
+	if _, err := s.service.CreateOrderIntent(bal.ID, svc.ServiceID, svc.Name, amount, p.ID(), s.config.BrandID(), ts); err != nil {
+		log.Printf("order pay: order intent user=%d service=%d: %v", bal.ID, svc.ServiceID, err)
+		return c.Send("⚠️ Не удалось оформить заказ. Попробуйте позже или обратитесь в поддержку.")
+	}
+	menu := &telebot.ReplyMarkup{}
+	menu.Inline(
+		menu.Row(menu.URL("Перейти к оплате", payURL)),
+		menu.Row(menu.Data("⇦ Назад", "/pricelist")),
+	)
+	return c.Send(fmt.Sprintf("Доплата %s за «%s» (%s).\n\nПосле зачисления услуга будет заказана автоматически — пришлю сообщение.",
+		models.FormatRubAmount(amount), strings.TrimSpace(svc.Name), p.DisplayName(topupLocale)), menu)
+}
@@ -0,0 +1,48 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ryabkov82/vpnbot/internal/config"
+	"github.com/ryabkov82/vpnbot/internal/models"
+	"github.com/ryabkov82/vpnbot/internal/payments"
+	appService "github.com/ryabkov82/vpnbot/internal/service"
+)
+
+func TestOrderPayOptions_FitsProviderLimits(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Brand.ID = "vff"
+	cfg.Brand.PaymentProviders = []config.PaymentProvider{
+		{ID: "yookassa"},
+		{ID: "paymaster", MinAmount: 200, MaxAmount: 2000},
+		{ID: "cryptocloud", MaxAmount: 100},
+	}
+	reg, err := payments.RegistryFromConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := orderPayOptions(reg.Available("vff", topupLocale), 150)
+	if len(got) != 2 || got[0].ProviderID != "yookassa" || got[0].Amount != 150 || got[1].ProviderID != "paymaster" || got[1].Amount != 200 {
+		t.Fatalf("%+v", got)
+	}
+
+	text := orderPayText(&models.Service{Name: "Premium", Cost: 450}, 300, 150)
+	if !strings.Contains(text, "«Premium» стоит 450 ₽, на балансе 300 ₽") || !strings.Contains(text, "Доплатите 150 ₽") {
+		t.Fatal(text)
+	}
+}
+
+func TestPaymentConfirmationText_OrderResult(t *testing.T) {
+	in := appService.PaymentIntent{Amount: 150, ServiceID: 7, ServiceName: "Premium", OrderStatus: appService.OrderIntentOrdered}
+	if got := paymentConfirmationText(in); !strings.Contains(got, "150 ₽") || !strings.Contains(got, "«Premium» заказана") {
+		t.Fatal(got)
+	}
+	in.OrderStatus = appService.OrderIntentFailed
+	if got := paymentConfirmationText(in); !strings.Contains(got, "Не удалось заказать услугу «Premium»") {
+		t.Fatal(got)
+	}
+	if got := paymentConfirmationText(appService.PaymentIntent{Amount: 300}); strings.Contains(got, "услуг") {
+		t.Fatal(got)
+	}
+}
@@ -0,0 +1,127 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/email"
+	"github.com/ryabkov82/vpnbot/internal/models"
+	appService "github.com/ryabkov82/vpnbot/internal/service"
+	"gopkg.in/telebot.v3"
+)
+
+// paymentConfirmationsInterval — как часто проверять зачисление по выданным ссылкам на оплату.
+const paymentConfirmationsInterval = 20 * time.Second
+
+// orderResultEmailSender — письмо об итоге заказа, если Telegram недоступен; подменяется в тестах.
+var orderResultEmailSender = email.SendOrderResultEmail
+
+// StartPaymentConfirmations периодически сопоставляет ссылки на оплату (бот и кабинет) с платежами SHM,
+// заказывает оплаченные услуги и сообщает в Telegram о зачислении. Блокирует; запускать в отдельной goroutine.
+func (s *Service) StartPaymentConfirmations(sender trafficAlertSender) {
+	ticker := time.NewTicker(paymentConfirmationsInterval)
+	defer ticker.Stop()
+	for {
+		<-ticker.C
+		s.runPaymentConfirmations(sender)
+	}
+}
+
+// runPaymentConfirmations — один проход по пользователям с незавершёнными намерениями оплаты
+// (включая выданные до рестарта — их находит DiscoverPaymentIntentUsers по новым платежам SHM).
+// Намерение отмечается как сообщённое до отправки, поэтому подтверждение уходит не больше одного раза.
+func (s *Service) runPaymentConfirmations(sender trafficAlertSender) {
+	if err := s.service.DiscoverPaymentIntentUsers(); err != nil {
+		log.Printf("payment confirmations: поиск намерений: %v", err)
+	}
+	for _, userID := range s.service.PendingPaymentIntentUsers() {
+		if _, err := s.service.FulfillOrderIntents(userID); err != nil {
+			log.Printf("payment confirmations: user %d заказ: %v", userID, err)
+			continue
+		}
+		claimed, err := s.service.ClaimCreditedPaymentIntents(userID)
+		if err != nil {
+			log.Printf("payment confirmations: user %d: %v", userID, err)
+			continue
+		}
+		if len(claimed) == 0 {
+			continue
+		}
+		user, err := s.service.GetUserByID(userID)
+		if err != nil || user == nil {
+			log.Printf("payment confirmations: пользователь %d: %v", userID, err)
+			continue
+		}
+		for _, in := range claimed {
+			s.sendPaymentConfirmation(sender, user, in)
+		}
+	}
+}
+
+// sendPaymentConfirmation — Telegram; итог заказа услуги при отсутствии Telegram уходит на email.
+func (s *Service) sendPaymentConfirmation(sender trafficAlertSender, user *models.User, in appService.PaymentIntent) {
+	if chatID := user.Settings.Telegram.ChatID; chatID > 0 && sender != nil {
+		_, err := sender.Send(telebot.ChatID(chatID), paymentConfirmationText(in),
+			&telebot.SendOptions{ReplyMarkup: paymentConfirmationMenu(in)})
+		if err == nil {
+			return
+		}
+		log.Printf("payment confirmations: user %d intent %s: %v", user.ID, in.ID, err)
+	}
+	if in.ServiceID <= 0 {
+		return
+	}
+	to := strings.TrimSpace(user.Settings.Web.Email)
+	if to == "" || !email.IsConfigured(s.config) {
+		return
+	}
+	if err := orderResultEmailSender(s.config, to, orderResultTitle(in), paymentConfirmationText(in)); err != nil {
+		log.Printf("payment confirmations: user %d intent %s email: %v", user.ID, in.ID, err)
+	}
+}
+
+func paymentConfirmationText(in appService.PaymentIntent) string {
+	text := fmt.Sprintf("✅ Платёж зачислен на баланс: %s.", models.FormatRubAmount(in.Amount))
+	if in.ServiceID <= 0 {
+		return text
+	}
+	name := in.ServiceName
+	if name == "" {
+		name = "Тариф"
+	}
+	switch in.OrderStatus {
+	case appService.OrderIntentOrdered:
+		text += fmt.Sprintf("\n\nУслуга «%s» заказана и будет активирована автоматически.", name)
+	case appService.OrderIntentExistingUnpaid:
+		text += fmt.Sprintf("\n\nУ вас уже была услуга «%s», ожидающая оплаты, — новая не создана, оплата пойдёт на неё.", name)
+	case appService.OrderIntentExpired:
+		text += "\n\nОплата пришла позже срока ссылки, поэтому услуга не заказана автоматически. Деньги остались на балансе — выберите тариф в каталоге."
+	default:
+		text += fmt.Sprintf("\n\n⚠️ Не удалось заказать услугу «%s». Деньги остались на балансе — попробуйте заказать её из каталога или напишите в поддержку.", name)
+	}
+	return text
+}
+
+func orderResultTitle(in appService.PaymentIntent) string {
+	switch in.OrderStatus {
+	case appService.OrderIntentOrdered, appService.OrderIntentExistingUnpaid:
+		return "услуга заказана"
+	}
+	return "платёж зачислен"
+}
+
+func paymentConfirmationMenu(in appService.PaymentIntent) *telebot.ReplyMarkup {
+	menu := &telebot.ReplyMarkup{}
+	switch {
+	case in.UserServiceID > 0:
+		menu.Inline(menu.Row(menu.Data("📋 Услуга", "/service", strconv.Itoa(in.UserServiceID))))
+	case in.ServiceID > 0:
+		menu.Inline(menu.Row(menu.Data("🛒 Тарифы", "/pricelist"), menu.Data("💰 Баланс", "/balance")))
+	default:
+		menu.Inline(menu.Row(menu.Data("💰 Баланс", "/balance")))
+	}
+	return menu
+}
@@ -0,0 +1,68 @@
+package bot
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/config"
+	"github.com/ryabkov82/vpnbot/internal/infrastructure/api"
+	appService "github.com/ryabkov82/vpnbot/internal/service"
+)
+
+func TestRunPaymentConfirmations_SendsOnceWhenCredited(t *testing.T) {
+	var mu sync.Mutex
+	settings := map[string]interface{}{"telegram": map[string]interface{}{"chat_id": 77}}
+	pays := `[]`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/shm/v1/admin/user/pay":
+			_, _ = io.WriteString(w, `{"data":`+pays+`}`)
+		case r.URL.Path == "/shm/v1/admin/user" && r.Method == http.MethodGet:
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": []interface{}{
+				map[string]interface{}{"user_id": 7, "login": "@77", "settings": settings},
+			}})
+		case r.URL.Path == "/shm/v1/admin/user" && r.Method == http.MethodPost:
+			var body map[string]interface{}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			settings = body["settings"].(map[string]interface{})
+		default:
+			t.Fatalf("unexpected SHM %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	cfg := &config.Config{}
+	core := appService.NewService(&api.APIClient{ServerURL: srv.URL, HTTPClient: srv.Client()}, cfg.Brand)
+	botSvc := NewService(core, cfg)
+	sender := &recordingSender{}
+
+	created := time.Now()
+	if _, err := core.CreatePaymentIntent(7, 300, "yookassa", "vff", created.Unix()); err != nil {
+		t.Fatal(err)
+	}
+	botSvc.runPaymentConfirmations(sender)
+	if len(sender.sent) != 0 {
+		t.Fatalf("nothing credited yet: %v", sender.sent)
+	}
+
+	mu.Lock()
+	pays = `[{"id":55,"user_id":7,"money":300,"date":"` + created.In(time.FixedZone("MSK", 3*60*60)).Format("2006-01-02 15:04:05") + `"}]`
+	mu.Unlock()
+	botSvc.runPaymentConfirmations(sender)
+	botSvc.runPaymentConfirmations(sender)
+	if len(sender.sent) != 1 || !strings.HasPrefix(sender.sent[0], "77:") || !strings.Contains(sender.sent[0], "300 ₽") {
+		t.Fatalf("sent %v", sender.sent)
+	}
+	if got := core.PendingPaymentIntentUsers(); len(got) != 0 {
+		t.Fatalf("pending %v", got)
+	}
+}
@@ -0,0 +1,155 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+
+	"gopkg.in/telebot.v3"
+
+	"github.com/ryabkov82/vpnbot/internal/models"
+	"github.com/ryabkov82/vpnbot/internal/service"
+)
+
+// planQuoteText — расчёт смены тарифа перед подтверждением.
+func planQuoteText(q *service.PlanChangeQuote) string {
+	days := math.Round(q.Remaining.Hours()/24*10) / 10
+	text := fmt.Sprintf("🔀 Смена тарифа «%s» → «%s»\n\nНеиспользованные %s дн. текущего тарифа — это %s, они вернутся на баланс.\nНовый тариф стоит %s, на балансе %s.",
+		q.FromName, q.ToName, strconv.FormatFloat(days, 'f', -1, 64),
+		models.FormatRubAmount(q.Credit), models.FormatRubAmount(q.Cost), models.FormatRubAmount(q.Balance))
+	switch {
+	case q.Due() > 0:
+		text += fmt.Sprintf("\n\n💰 Не хватает %s — пополните баланс и вернитесь к смене тарифа.", models.FormatRubAmount(q.Due()))
+	case q.Leftover() > 0:
+		text += fmt.Sprintf("\n\n%s из зачтённой суммы останется на балансе.", models.FormatRubAmount(q.Leftover()))
+	}
+	return text
+}
+
+// planChangeErrorText — сообщение пользователю об ошибке смены тарифа.
+func planChangeErrorText(err error) string {
+	switch {
+	case errors.Is(err, service.ErrUserServiceUnavailable):
+		return "⚠️ Услуга не найдена или недоступна"
+	case errors.Is(err, service.ErrServiceCategoryDenied), errors.Is(err, service.ErrPlanChangeUnavailable):
+		return "⚠️ На этот тариф сейчас перейти нельзя."
+	case errors.Is(err, service.ErrPlanChangeStale):
+		return "ℹ️ Услуга уже изменилась — откройте смену тарифа ещё раз."
+	case errors.Is(err, service.ErrPlanChangeInsufficientBalance):
+		return "💰 На балансе недостаточно средств для смены тарифа. Пополните баланс и попробуйте снова."
+	case errors.Is(err, service.ErrPlanChangeInProgress):
+		return "⏳ Смена тарифа для этой услуги уже выполняется. Если это затянулось — напишите в поддержку."
+	case errors.Is(err, service.ErrPlanChangeOrderFailed):
+		return "⚠️ Не удалось подключить новый тариф. Зачисление за остаток отменено, текущая услуга работает."
+	case errors.Is(err, service.ErrPlanChangeRetireFailed):
+		return "⚠️ Новый тариф подключён, но старая услуга ещё не снята. Повторите смену тарифа — она завершится."
+	}
+	return "⚠️ Не удалось сменить тариф. Попробуйте позже."
+}
+
+func planChangeErrorExpected(err error) bool {
+	return errors.Is(err, service.ErrUserServiceUnavailable) || errors.Is(err, service.ErrServiceCategoryDenied) ||
+		errors.Is(err, service.ErrPlanChangeUnavailable) || errors.Is(err, service.ErrPlanChangeStale) ||
+		errors.Is(err, service.ErrPlanChangeInsufficientBalance)
+}
+
+// handlePlanChange — /plan|<user_service_id>: тарифы бренда, на которые можно перейти.
+func (s *Service) handlePlanChange(c telebot.Context, serviceID string) error {
+	if !s.config.PlanChange.Enabled {
+		return nil
+	}
+	user, usID, ok, err := s.renewalUser(c, serviceID)
+	if !ok {
+		return err
+	}
+	us, err := s.service.GetOwnedUserServiceByUserID(user.ID, strconv.Itoa(usID))
+	if err == nil && us.Status != "ACTIVE" {
+		err = service.ErrPlanChangeUnavailable
+	}
+	if err != nil {
+		if !planChangeErrorExpected(err) {
+			log.Printf("plan change: user_id=%d user_service_id=%d: %v", user.ID, usID, err)
+		}
+		return c.Send(planChangeErrorText(err))
+	}
+	services, err := s.service.GetServices()
+	if err != nil {
+		log.Printf("plan change: services: %v", err)
+		return c.Send("⚠️ Не удалось загрузить список услуг. Попробуйте позже.")
+	}
+	trialID := s.config.Features.Trial.BaseServiceID
+	var rows [][]telebot.InlineButton
+	for _, svc := range services {
+		if svc.Cost <= 0 || svc.ServiceID == us.BaseServiceID || (trialID > 0 && svc.ServiceID == trialID) {
+			continue
+		}
+		rows = append(rows, []telebot.InlineButton{{
+			Text: fmt.Sprintf("%s — %s", svc.Name, models.FormatRubAmount(svc.Cost)),
+			Data: "/plan_q|" + serviceID + "|" + strconv.Itoa(svc.ServiceID),
+		}})
+	}
+	text := fmt.Sprintf("🔀 Выберите новый тариф для «%s».\n\nНеиспользованные дни текущего тарифа пересчитаем и зачтём в оплату нового.", us.Name)
+	if len(rows) == 0 {
+		text = "🔀 Других тарифов сейчас нет."
+	}
+	rows = append(rows, []telebot.InlineButton{{Text: "⇦ Назад", Data: "/service|" + serviceID}})
+	return s.editOrSend(c, text, rows)
+}
+
+// handlePlanQuote — /plan_q|<user_service_id>|<service_id>: расчёт с кнопкой подтверждения.
+func (s *Service) handlePlanQuote(c telebot.Context, serviceID, targetID string) error {
+	if !s.config.PlanChange.Enabled {
+		return nil
+	}
+	user, usID, ok, err := s.renewalUser(c, serviceID)
+	if !ok {
+		return err
+	}
+	sID, _ := strconv.Atoi(targetID)
+	q, err := s.service.QuotePlanChange(user.ID, usID, sID)
+	if err != nil {
+		if !planChangeErrorExpected(err) {
+			log.Printf("plan quote: user_id=%d user_service_id=%d service_id=%s: %v", user.ID, usID, targetID, err)
+		}
+		return c.Send(planChangeErrorText(err))
+	}
+	back := []telebot.InlineButton{{Text: "⇦ Назад", Data: "/plan|" + serviceID}}
+	if q.Due() > 0 {
+		return s.editOrSend(c, planQuoteText(q), [][]telebot.InlineButton{{{Text: "💰 Пополнить баланс", Data: "/balance"}}, back})
+	}
+	rows := [][]telebot.InlineButton{
+		{{Text: "✅ Перейти на «" + q.ToName + "»", Data: "/plan_ok|" + serviceID + "|" + targetID + "|" + q.Expire}},
+		back,
+	}
+	return s.editOrSend(c, planQuoteText(q), rows)
+}
+
+// handlePlanConfirmed — /plan_ok|<user_service_id>|<service_id>|<expire>: смена тарифа по подтверждённому расчёту.
+func (s *Service) handlePlanConfirmed(c telebot.Context, serviceID, targetID, expire string) error {
+	if !s.config.PlanChange.Enabled {
+		return nil
+	}
+	user, usID, ok, err := s.renewalUser(c, serviceID)
+	if !ok {
+		return err
+	}
+	sID, _ := strconv.Atoi(targetID)
+	pc, err := s.service.ChangePlan(service.PlanChangeRequest{
+		UserID:        user.ID,
+		UserServiceID: usID,
+		ServiceID:     sID,
+		Expire:        expire,
+		PaySystem:     s.config.PlanChange.SHMPaySystem(),
+	})
+	if err != nil {
+		if !planChangeErrorExpected(err) {
+			log.Printf("plan change: user_id=%d user_service_id=%d service_id=%d: %v", user.ID, usID, sID, err)
+		}
+		return c.Send(planChangeErrorText(err))
+	}
+	text := fmt.Sprintf("✅ Тариф сменён на «%s». За неиспользованные дни зачтено %s.", pc.ToName, models.FormatRubAmount(pc.Credit))
+	rows := [][]telebot.InlineButton{{{Text: "🔑 К услуге", Data: "/service|" + strconv.Itoa(pc.NewUserServiceID)}}}
+	return s.editOrSend(c, text, rows)
+}
@@ -0,0 +1,37 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/service"
+)
+
+func TestPlanQuoteText(t *testing.T) {
+	q := &service.PlanChangeQuote{FromName: "Basic 1m", ToName: "Premium 1m", Remaining: 15 * 24 * time.Hour, Credit: 150, Cost: 450, Balance: 100}
+	got := planQuoteText(q)
+	for _, want := range []string{"«Basic 1m» → «Premium 1m»", "15 дн.", "150 ₽", "Не хватает 200 ₽"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("missing %q in %q", want, got)
+		}
+	}
+
+	q.Cost = 100
+	if got := planQuoteText(q); !strings.Contains(got, "50 ₽ из зачтённой суммы") || strings.Contains(got, "Не хватает") {
+		t.Fatalf("%q", got)
+	}
+}
+
+func TestPlanChangeErrorText(t *testing.T) {
+	if got := planChangeErrorText(service.ErrPlanChangeStale); !strings.Contains(got, "изменилась") {
+		t.Fatal(got)
+	}
+	if got := planChangeErrorText(fmt.Errorf("wrap: %w", service.ErrPlanChangeOrderFailed)); !strings.Contains(got, "отменено") {
+		t.Fatal(got)
+	}
+	if planChangeErrorExpected(service.ErrPlanChangeRetireFailed) {
+		t.Fatal("retire failure must be logged")
+	}
+}
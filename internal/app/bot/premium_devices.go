@@ -0,0 +1,205 @@
+package bot
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/app/web"
+	"github.com/ryabkov82/vpnbot/internal/infrastructure/remnawave"
+	"github.com/ryabkov82/vpnbot/internal/models"
+	"github.com/ryabkov82/vpnbot/internal/service"
+	"gopkg.in/telebot.v3"
+)
+
+const premiumDevicesTimeout = 8 * time.Second
+
+// SetRemnawaveClient подключает Remnawave API для управления устройствами premium-услуг (nil — выключено).
+func (s *Service) SetRemnawaveClient(rw *remnawave.Client) {
+	s.remnawave = rw
+}
+
+// hwidDeviceRef — короткая ссылка на устройство для callback data (лимит Telegram 64 байта).
+func hwidDeviceRef(hwid string) string {
+	sum := sha256.Sum256([]byte(hwid))
+	return hex.EncodeToString(sum[:5])
+}
+
+// hwidDeviceTitle — «модель · платформа версия»; без данных — «Устройство N».
+func hwidDeviceTitle(d remnawave.HWIDDevice, n int) string {
+	var parts []string
+	if d.DeviceModel != "" {
+		parts = append(parts, d.DeviceModel)
+	}
+	if p := strings.TrimSpace(d.Platform + " " + d.OSVersion); p != "" {
+		parts = append(parts, p)
+	}
+	if len(parts) == 0 {
+		return fmt.Sprintf("Устройство %d", n)
+	}
+	return strings.Join(parts, " · ")
+}
+
+// premiumDevicesView — текст и клавиатура подменю «Устройства».
+func premiumDevicesView(userServiceID, limit int, devs []remnawave.HWIDDevice) (string, *telebot.ReplyMarkup) {
+	var text strings.Builder
+	text.WriteString("<b>Устройства</b>")
+	if limit > 0 {
+		text.WriteString(fmt.Sprintf(": %d из %d", len(devs), limit))
+	}
+	if len(devs) == 0 {
+		text.WriteString("\n\nПодключённых устройств нет.")
+	} else {
+		text.WriteString("\n")
+		for i, d := range devs {
+			text.WriteString(fmt.Sprintf("\n%d. %s", i+1, html.EscapeString(hwidDeviceTitle(d, i+1))))
+			if !d.CreatedAt.IsZero() {
+				text.WriteString(fmt.Sprintf(" — с %s", d.CreatedAt.In(time.FixedZone("MSK", 3*60*60)).Format("02.01.2006")))
+			}
+		}
+		text.WriteString("\n\nОтвяжите устройство, чтобы освободить слот для нового.")
+	}
+
+	usID := strconv.Itoa(userServiceID)
+	menu := &telebot.ReplyMarkup{}
+	var rows []telebot.Row
+	for i, d := range devs {
+		rows = append(rows, menu.Row(
+			menu.Data("🗑 "+hwidDeviceTitle(d, i+1), "/device_del", usID, hwidDeviceRef(d.HWID)),
+		))
+	}
+	if len(devs) > 1 {
+		rows = append(rows, menu.Row(menu.Data("🧹 Отвязать все", "/devices_clear", usID)))
+	}
+	rows = append(rows, menu.Row(menu.Data("⇦ Назад", "/service", usID)))
+	menu.Inline(rows...)
+	return text.String(), menu
+}
+
+// premiumDevicesTarget — владение услугой, premium-проверка и пользователь Remnawave us_<id>.
+// При ошибке сообщение пользователю уже отправлено.
+func (s *Service) premiumDevicesTarget(ctx context.Context, c telebot.Context, serviceID string) (*models.UserService, int, *remnawave.User, bool) {
+	us, _, err := s.loadOwnedUserService(c.Chat().ID, serviceID)
+	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			_ = s.showRegistrationMenu(c)
+			return nil, 0, nil, false
+		}
+		if errors.Is(err, service.ErrUserServiceUnavailable) {
+			_ = c.Send("⚠️ Услуга не найдена или недоступна")
+			return nil, 0, nil, false
+		}
+		log.Printf("premium devices: проверка услуги: %v", err)
+		_ = c.Send("⚠️ Произошла ошибка при получении информации по услуге")
+		return nil, 0, nil, false
+	}
+	if !s.isPremiumAntiBlock(us) {
+		_ = c.Send("⚠️ Управление устройствами доступно только для premium-услуг")
+		return nil, 0, nil, false
+	}
+	top, err := us.ParseTopConfig()
+	if err != nil {
+		log.Printf("premium devices: ParseTopConfig us=%d: %v", us.ServiceID, err)
+		_ = c.Send("⚠️ Произошла ошибка при получении информации по услуге")
+		return nil, 0, nil, false
+	}
+	if s.remnawave == nil {
+		_ = c.Send("⚠️ Управление устройствами временно недоступно")
+		return nil, 0, nil, false
+	}
+	username := web.PremiumRemnawaveUsername(us)
+	user, err := s.remnawave.GetUserByUsername(ctx, username)
+	if err != nil {
+		log.Printf("premium devices: remnawave user %s: %v", username, err)
+		_ = c.Send("⚠️ Не удалось получить список устройств. Попробуйте позже.")
+		return nil, 0, nil, false
+	}
+	return us, top.Remnawave.HWIDDeviceLimit, user, true
+}
+
+func (s *Service) sendPremiumDevices(c telebot.Context, us *models.UserService, limit int, devs []remnawave.HWIDDevice) error {
+	text, menu := premiumDevicesView(us.ServiceID, limit, devs)
+	return c.Send(text, &telebot.SendOptions{ParseMode: telebot.ModeHTML, ReplyMarkup: menu})
+}
+
+func (s *Service) handlePremiumDevices(c telebot.Context, serviceID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), premiumDevicesTimeout)
+	defer cancel()
+	us, limit, user, ok := s.premiumDevicesTarget(ctx, c, serviceID)
+	if !ok {
+		return nil
+	}
+	devs, err := s.remnawave.GetUserHWIDDevices(ctx, *user)
+	if err != nil {
+		log.Printf("premium devices: remnawave hwid user=%s: %v", user.Username, err)
+		return c.Send("⚠️ Не удалось получить список устройств. Попробуйте позже.")
+	}
+	return s.sendPremiumDevices(c, us, limit, devs)
+}
+
+// handlePremiumDeviceDelete — отвязка одного устройства; ref сверяется с актуальным списком,
+// поэтому устаревшая кнопка не отвяжет другое устройство.
+func (s *Service) handlePremiumDeviceDelete(c telebot.Context, serviceID, ref string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), premiumDevicesTimeout)
+	defer cancel()
+	us, limit, user, ok := s.premiumDevicesTarget(ctx, c, serviceID)
+	if !ok {
+		return nil
+	}
+	devs, err := s.remnawave.GetUserHWIDDevices(ctx, *user)
+	if err != nil {
+		log.Printf("premium devices: remnawave hwid user=%s: %v", user.Username, err)
+		return c.Send("⚠️ Не удалось получить список устройств. Попробуйте позже.")
+	}
+	hwid := ""
+	for _, d := range devs {
+		if hwidDeviceRef(d.HWID) == ref {
+			hwid = d.HWID
+			break
+		}
+	}
+	if hwid == "" {
+		_ = c.Send("Устройство уже отвязано.")
+		return s.sendPremiumDevices(c, us, limit, devs)
+	}
+	left, err := s.remnawave.DeleteUserHWIDDevice(ctx, *user, hwid)
+	if err != nil {
+		log.Printf("premium devices: remnawave delete user=%s: %v", user.Username, err)
+		return c.Send("⚠️ Не удалось отвязать устройство. Попробуйте позже.")
+	}
+	log.Printf("premium devices: deleted device user=%s", user.Username)
+	_ = c.Send("✅ Устройство отвязано.")
+	return s.sendPremiumDevices(c, us, limit, left)
+}
+
+func (s *Service) handlePremiumDevicesClear(c telebot.Context, serviceID string) error {
+	menu := &telebot.ReplyMarkup{}
+	menu.Inline(
+		menu.Row(menu.Data("🧹 Да, отвязать все", "/devices_clear_ok", serviceID)),
+		menu.Row(menu.Data("⇦ Назад", "/devices", serviceID)),
+	)
+	return c.Send("Отвязать все устройства? Подключиться заново можно будет с любого устройства в пределах лимита.", menu)
+}
+
+func (s *Service) handlePremiumDevicesClearConfirmed(c telebot.Context, serviceID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), premiumDevicesTimeout)
+	defer cancel()
+	us, limit, user, ok := s.premiumDevicesTarget(ctx, c, serviceID)
+	if !ok {
+		return nil
+	}
+	if err := s.remnawave.DeleteAllUserHWIDDevices(ctx, *user); err != nil {
+		log.Printf("premium devices: remnawave delete-all user=%s: %v", user.Username, err)
+		return c.Send("⚠️ Не удалось отвязать устройства. Попробуйте позже.")
+	}
+	log.Printf("premium devices: deleted all devices user=%s", user.Username)
+	_ = c.Send("✅ Все устройства отвязаны.")
+	return s.sendPremiumDevices(c, us, limit, nil)
+}
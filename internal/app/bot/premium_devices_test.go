@@ -0,0 +1,51 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/infrastructure/remnawave"
+)
+
+func TestHWIDDeviceTitle(t *testing.T) {
+	cases := []struct {
+		d    remnawave.HWIDDevice
+		want string
+	}{
+		{remnawave.HWIDDevice{DeviceModel: "iPhone15,2", Platform: "iOS", OSVersion: "17.4"}, "iPhone15,2 · iOS 17.4"},
+		{remnawave.HWIDDevice{Platform: "Windows"}, "Windows"},
+		{remnawave.HWIDDevice{HWID: "x"}, "Устройство 2"},
+	}
+	for _, tc := range cases {
+		if got := hwidDeviceTitle(tc.d, 2); got != tc.want {
+			t.Fatalf("got %q want %q", got, tc.want)
+		}
+	}
+}
+
+func TestPremiumDevicesView(t *testing.T) {
+	devs := []remnawave.HWIDDevice{
+		{HWID: strings.Repeat("a", 64), DeviceModel: "<Pixel>", CreatedAt: time.Date(2026, 9, 1, 22, 0, 0, 0, time.UTC)},
+		{HWID: "hw-b", Platform: "Windows"},
+	}
+	text, menu := premiumDevicesView(123456, 3, devs)
+	if !strings.Contains(text, "2 из 3") || !strings.Contains(text, "&lt;Pixel&gt; — с 02.09.2026") {
+		t.Fatalf("text %q", text)
+	}
+	if len(menu.InlineKeyboard) != 4 {
+		t.Fatalf("rows %d", len(menu.InlineKeyboard))
+	}
+	del := menu.InlineKeyboard[0][0]
+	if del.Unique != "/device_del" || del.Data != "123456|"+hwidDeviceRef(devs[0].HWID) || len(del.Unique)+len(del.Data) > 62 {
+		t.Fatalf("callback %+v", del)
+	}
+	if clear := menu.InlineKeyboard[2][0]; clear.Unique != "/devices_clear" || clear.Data != "123456" {
+		t.Fatalf("clear %+v", clear)
+	}
+
+	text, menu = premiumDevicesView(7, 0, nil)
+	if !strings.Contains(text, "Подключённых устройств нет") || strings.Contains(text, " из ") || len(menu.InlineKeyboard) != 1 {
+		t.Fatalf("empty view %q rows=%d", text, len(menu.InlineKeyboard))
+	}
+}
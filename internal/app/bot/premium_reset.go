@@ -0,0 +1,111 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/app/web"
+	"github.com/ryabkov82/vpnbot/internal/models"
+	"github.com/ryabkov82/vpnbot/internal/service"
+	"gopkg.in/telebot.v3"
+)
+
+// premiumResetMenu — подменю сброса: оно же шаг подтверждения перед вызовом Remnawave.
+func premiumResetMenu(userServiceID int, trafficResetAllowed bool) (string, *telebot.ReplyMarkup) {
+	usID := strconv.Itoa(userServiceID)
+	text := "<b>Сброс подписки</b>\n\n" +
+		"Если ссылка подключения попала к посторонним, сбросьте её: старая ссылка перестанет работать, " +
+		"новую нужно будет заново добавить в Happ на своих устройствах. Сброс доступен раз в сутки."
+	menu := &telebot.ReplyMarkup{}
+	rows := []telebot.Row{
+		menu.Row(menu.Data("🔄 Да, сбросить ссылку", "/premium_reset_ok", usID, string(web.PremiumResetSubscription))),
+	}
+	if trafficResetAllowed {
+		text += "\n\nСброс трафика обнуляет использованный объём; доступен раз в неделю."
+		rows = append(rows, menu.Row(menu.Data("♻️ Сбросить трафик", "/premium_reset_ok", usID, string(web.PremiumResetTraffic))))
+	}
+	rows = append(rows, menu.Row(menu.Data("⇦ Назад", "/service", usID)))
+	menu.Inline(rows...)
+	return text, menu
+}
+
+// loadOwnedPremiumService — ownership-проверка и premium-проверка; при ошибке ответ уже отправлен,
+// notPremium — текст для не-premium услуги.
+func (s *Service) loadOwnedPremiumService(c telebot.Context, serviceID, notPremium string) (*models.UserService, bool) {
+	us, _, err := s.loadOwnedUserService(c.Chat().ID, serviceID)
+	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			_ = s.showRegistrationMenu(c)
+			return nil, false
+		}
+		if errors.Is(err, service.ErrUserServiceUnavailable) {
+			_ = c.Send("⚠️ Услуга не найдена или недоступна")
+			return nil, false
+		}
+		log.Printf("premium: проверка услуги: %v", err)
+		_ = c.Send("⚠️ Произошла ошибка при получении информации по услуге")
+		return nil, false
+	}
+	if !s.isPremiumAntiBlock(us) {
+		_ = c.Send(notPremium)
+		return nil, false
+	}
+	return us, true
+}
+
+func (s *Service) handlePremiumReset(c telebot.Context, serviceID string) error {
+	us, ok := s.loadOwnedPremiumService(c, serviceID, "⚠️ Сброс доступен только для premium-услуг")
+	if !ok {
+		return nil
+	}
+	top, _ := us.ParseTopConfig()
+	text, menu := premiumResetMenu(us.ServiceID, web.PremiumTrafficResetAllowed(top))
+	return c.Send(text, &telebot.SendOptions{ParseMode: telebot.ModeHTML, ReplyMarkup: menu})
+}
+
+func (s *Service) handlePremiumResetConfirmed(c telebot.Context, serviceID, rawAction string) error {
+	action, err := web.ParsePremiumResetAction(rawAction)
+	if err != nil {
+		return nil
+	}
+	us, ok := s.loadOwnedPremiumService(c, serviceID, "⚠️ Сброс доступен только для premium-услуг")
+	if !ok {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), premiumDevicesTimeout)
+	defer cancel()
+	_, err = web.RunPremiumReset(ctx, s.config, s.remnawave, us, web.PremiumResetInput{Action: action, Source: "bot"})
+	if err != nil {
+		var cd *web.PremiumResetCooldownError
+		switch {
+		case errors.As(err, &cd):
+			return c.Send(fmt.Sprintf("⏳ Повторный сброс будет доступен %s (МСК).",
+				cd.RetryAt.In(time.FixedZone("MSK", 3*60*60)).Format("02.01.2006 15:04")))
+		case errors.Is(err, web.ErrPremiumResetNotAllowed):
+			return c.Send("⚠️ Тариф не позволяет выполнить этот сброс")
+		case errors.Is(err, web.ErrPremiumResetUnavailable):
+			return c.Send("⚠️ Сброс временно недоступен. Обратитесь в поддержку.")
+		}
+		log.Printf("premium reset: us=%d action=%s: %v", us.ServiceID, action, err)
+		return c.Send("⚠️ Не удалось выполнить сброс. Попробуйте позже или обратитесь в поддержку.")
+	}
+
+	if action == web.PremiumResetTraffic {
+		return c.Send("✅ Трафик сброшен.")
+	}
+	// Страница подключения запрашивает happ-link заново, поэтому откроет уже новую ссылку.
+	menu := &telebot.ReplyMarkup{}
+	var rows []telebot.Row
+	if u := strings.TrimSpace(s.buildPremiumConnectURL(us.ServiceID, c.Chat().ID)); u != "" {
+		rows = append(rows, menu.Row(menu.WebApp("Показать данные для подключения", &telebot.WebApp{URL: u})))
+	}
+	rows = append(rows, menu.Row(menu.Data("⇦ Назад", "/service", strconv.Itoa(us.ServiceID))))
+	menu.Inline(rows...)
+	return c.Send("✅ Ссылка подписки сброшена. Добавьте новую конфигурацию в Happ на своих устройствах.", menu)
+}
@@ -0,0 +1,21 @@
+package bot
+
+import "testing"
+
+func TestPremiumResetMenu(t *testing.T) {
+	_, menu := premiumResetMenu(501, false)
+	if len(menu.InlineKeyboard) != 2 {
+		t.Fatalf("rows %d", len(menu.InlineKeyboard))
+	}
+	if b := menu.InlineKeyboard[0][0]; b.Unique != "/premium_reset_ok" || b.Data != "501|subscription" {
+		t.Fatalf("%+v", b)
+	}
+
+	text, menu := premiumResetMenu(501, true)
+	if len(menu.InlineKeyboard) != 3 || menu.InlineKeyboard[1][0].Data != "501|traffic" {
+		t.Fatalf("rows %+v", menu.InlineKeyboard)
+	}
+	if text == "" {
+		t.Fatal("empty text")
+	}
+}
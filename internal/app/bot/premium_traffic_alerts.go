@@ -0,0 +1,171 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/app/web"
+	"github.com/ryabkov82/vpnbot/internal/email"
+	"github.com/ryabkov82/vpnbot/internal/models"
+	"gopkg.in/telebot.v3"
+)
+
+// trafficAlertSender — отправка сообщения в Telegram (*telebot.Bot); в тестах подменяется.
+type trafficAlertSender interface {
+	Send(to telebot.Recipient, what interface{}, opts ...interface{}) (*telebot.Message, error)
+}
+
+// trafficAlertEmailSender — письмо, если Telegram недоступен; подменяется в тестах.
+var trafficAlertEmailSender = email.SendPremiumTrafficAlertEmail
+
+// StartTrafficAlerts периодически проверяет расход трафика активных Premium-услуг и предупреждает владельцев
+// при пересечении порогов traffic_alerts.thresholds_percent. Блокирует; запускать в отдельной goroutine.
+func (s *Service) StartTrafficAlerts(sender trafficAlertSender) {
+	if !s.config.TrafficAlerts.Enabled {
+		return
+	}
+	if s.remnawave == nil {
+		log.Printf("traffic alerts: remnawave не настроен, уведомления отключены")
+		return
+	}
+	ticker := time.NewTicker(s.config.TrafficAlerts.Interval())
+	defer ticker.Stop()
+	for {
+		s.runTrafficAlerts(sender, time.Now())
+		<-ticker.C
+	}
+}
+
+// trafficAlertThreshold — наибольший порог (%), который достигнут при использовании used из limit; 0 — ни одного.
+func trafficAlertThreshold(thresholds []int, used, limit int64) int {
+	if limit <= 0 {
+		return 0
+	}
+	pct := used * 100 / limit
+	hit := 0
+	for _, t := range thresholds {
+		if pct >= int64(t) {
+			hit = t
+		}
+	}
+	return hit
+}
+
+// runTrafficAlerts — один проход по активным услугам. Порог отмечается в SHM до отправки,
+// поэтому в одном периоде уведомление о пороге уходит не больше одного раза, в том числе после рестарта.
+func (s *Service) runTrafficAlerts(sender trafficAlertSender, now time.Time) {
+	list, err := s.service.ListActiveUserServices()
+	if err != nil {
+		log.Printf("traffic alerts: список услуг: %v", err)
+		return
+	}
+	thresholds := s.config.TrafficAlerts.Thresholds()
+	for i := range list {
+		us := &list[i]
+		if !s.isPremiumAntiBlock(us) {
+			continue
+		}
+		top, err := us.ParseTopConfig()
+		if err != nil || top.Remnawave.TrafficLimitBytes <= 0 {
+			continue
+		}
+		s.checkTrafficAlert(sender, us, top.Remnawave, thresholds, now)
+	}
+}
+
+func (s *Service) checkTrafficAlert(sender trafficAlertSender, us *models.UserService, rw models.UserServiceTopConfigRemnawave, thresholds []int, now time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), premiumDevicesTimeout)
+	defer cancel()
+
+	username := web.PremiumRemnawaveUsername(us)
+	user, err := s.remnawave.GetUserByUsername(ctx, username)
+	if err != nil {
+		log.Printf("traffic alerts: remnawave user %s: %v", username, err)
+		return
+	}
+	start, end, ok := web.PremiumTrafficPeriod(us, rw.TrafficLimitStrategy, user.LastTrafficResetAt, now)
+	if !ok {
+		return
+	}
+	stats, err := s.remnawave.GetUserBandwidthStats(ctx, *user, start, end)
+	if err != nil {
+		log.Printf("traffic alerts: usage %s: %v", username, err)
+		return
+	}
+	threshold := trafficAlertThreshold(thresholds, stats.UsedBytes, rw.TrafficLimitBytes)
+	if threshold == 0 {
+		return
+	}
+	claimed, err := s.service.ClaimTrafficAlert(us.UserID, us.ServiceID, start.Format(time.RFC3339), threshold)
+	if err != nil {
+		log.Printf("traffic alerts: us=%d отметка порога %d%%: %v", us.ServiceID, threshold, err)
+		return
+	}
+	if !claimed {
+		return
+	}
+	s.sendTrafficAlert(sender, us, threshold, stats.UsedBytes, rw.TrafficLimitBytes)
+}
+
+// trafficAlertText — текст для Telegram; threshold ≥ 100 — лимит исчерпан.
+func trafficAlertText(us *models.UserService, threshold int, used, limit int64) string {
+	var b strings.Builder
+	if threshold >= 100 {
+		b.WriteString("⛔️ <b>Трафик исчерпан</b>\n\n")
+	} else {
+		fmt.Fprintf(&b, "⚠️ <b>Использовано %d%% трафика</b>\n\n", threshold)
+	}
+	fmt.Fprintf(&b, "Услуга: %s\nИспользовано: %s из %s",
+		html.EscapeString(us.Name), web.BytesHumanRu(used), web.BytesHumanRu(limit))
+	if threshold >= 100 {
+		b.WriteString("\n\nПодключение будет ограничено до начала следующего периода.")
+	}
+	b.WriteString("\n\nЕсли трафика не хватает, выберите тариф с большим объёмом.")
+	return b.String()
+}
+
+func trafficAlertMenu(userServiceID int) *telebot.ReplyMarkup {
+	menu := &telebot.ReplyMarkup{}
+	menu.Inline(menu.Row(
+		menu.Data("🛒 Тарифы", "/pricelist"),
+		menu.Data("📊 Трафик", "/premium_usage", strconv.Itoa(userServiceID)),
+	))
+	return menu
+}
+
+// sendTrafficAlert — Telegram, если пользователь пришёл из бота, иначе (или при ошибке доставки) email.
+func (s *Service) sendTrafficAlert(sender trafficAlertSender, us *models.UserService, threshold int, used, limit int64) {
+	user, err := s.service.GetUserByID(us.UserID)
+	if err != nil || user == nil {
+		log.Printf("traffic alerts: us=%d пользователь %d: %v", us.ServiceID, us.UserID, err)
+		return
+	}
+	if chatID := user.Settings.Telegram.ChatID; chatID > 0 && sender != nil {
+		_, err := sender.Send(telebot.ChatID(chatID), trafficAlertText(us, threshold, used, limit),
+			&telebot.SendOptions{ParseMode: telebot.ModeHTML, ReplyMarkup: trafficAlertMenu(us.ServiceID)})
+		if err == nil {
+			log.Printf("traffic alerts: us=%d порог %d%% → telegram", us.ServiceID, threshold)
+			return
+		}
+		log.Printf("traffic alerts: us=%d telegram: %v", us.ServiceID, err)
+	}
+	to := strings.TrimSpace(user.Settings.Web.Email)
+	if to == "" || !email.IsConfigured(s.config) {
+		return
+	}
+	upgradeURL := ""
+	if base := s.config.PublicBaseURL(); base != "" {
+		upgradeURL = base + "/buy"
+	}
+	if err := trafficAlertEmailSender(s.config, to, us.Name, threshold,
+		web.BytesHumanRu(used), web.BytesHumanRu(limit), upgradeURL); err != nil {
+		log.Printf("traffic alerts: us=%d email: %v", us.ServiceID, err)
+		return
+	}
+	log.Printf("traffic alerts: us=%d порог %d%% → email", us.ServiceID, threshold)
+}
@@ -0,0 +1,116 @@
+package bot
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/config"
+	"github.com/ryabkov82/vpnbot/internal/infrastructure/api"
+	"github.com/ryabkov82/vpnbot/internal/infrastructure/remnawave"
+	appService "github.com/ryabkov82/vpnbot/internal/service"
+	"gopkg.in/telebot.v3"
+)
+
+type recordingSender struct {
+	mu   sync.Mutex
+	sent []string
+}
+
+func (r *recordingSender) Send(to telebot.Recipient, what interface{}, _ ...interface{}) (*telebot.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent = append(r.sent, to.Recipient()+":"+what.(string))
+	return &telebot.Message{}, nil
+}
+
+func TestTrafficAlertThreshold(t *testing.T) {
+	th := []int{80, 100}
+	for _, c := range []struct {
+		used, limit int64
+		want        int
+	}{{79, 100, 0}, {80, 100, 80}, {99, 100, 80}, {150, 100, 100}, {5, 0, 0}} {
+		if got := trafficAlertThreshold(th, c.used, c.limit); got != c.want {
+			t.Fatalf("%d/%d: got %d want %d", c.used, c.limit, got, c.want)
+		}
+	}
+}
+
+// newTrafficAlertsSHM — SHM с одной premium-услугой 501 (user 7, chat 77); settings сохраняются между запросами.
+func newTrafficAlertsSHM(t *testing.T) *api.APIClient {
+	t.Helper()
+	var mu sync.Mutex
+	settings := map[string]interface{}{"telegram": map[string]interface{}{"chat_id": 77}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/shm/v1/admin/user/service":
+			_, _ = io.WriteString(w, `{"data":[
+				{"user_service_id":501,"user_id":7,"name":"Premium","status":"ACTIVE","config":"{\"remnawave\":{\"internal_squad_name\":\"premium-squad\",\"traffic_limit_bytes\":1000,\"traffic_limit_strategy\":\"DAY\"}}"},
+				{"user_service_id":502,"user_id":7,"name":"Basic","status":"ACTIVE","config":"{\"remnawave\":{\"internal_squad_name\":\"basic\"}}"}]}`)
+		case r.URL.Path == "/shm/v1/admin/user" && r.Method == http.MethodGet:
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": []interface{}{
+				map[string]interface{}{"user_id": 7, "login": "@77", "settings": settings},
+			}})
+		case r.URL.Path == "/shm/v1/admin/user" && r.Method == http.MethodPost:
+			var body map[string]interface{}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			settings = body["settings"].(map[string]interface{})
+		default:
+			t.Fatalf("unexpected SHM %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return &api.APIClient{ServerURL: srv.URL, HTTPClient: srv.Client()}
+}
+
+func TestRunTrafficAlerts_SendsOncePerThreshold(t *testing.T) {
+	var used int64 = 850
+	var mu sync.Mutex
+	rwSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.URL.Path == "/api/users/by-username/us_501":
+			_, _ = io.WriteString(w, `{"response":{"id":9,"uuid":"u-9","username":"us_501"}}`)
+		case strings.HasPrefix(r.URL.Path, "/api/bandwidth-stats/users/u-9"):
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"response": map[string]interface{}{"series": []interface{}{map[string]interface{}{"total": used}}}})
+		default:
+			t.Fatalf("unexpected remnawave %s", r.URL.Path)
+		}
+	}))
+	t.Cleanup(rwSrv.Close)
+
+	cfg := &config.Config{PremiumSquadName: "premium-squad"}
+	botSvc := NewService(appService.NewService(newTrafficAlertsSHM(t), cfg.Brand), cfg)
+	botSvc.SetRemnawaveClient(remnawave.NewClient(rwSrv.URL, "tok"))
+	sender := &recordingSender{}
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	botSvc.runTrafficAlerts(sender, now)
+	botSvc.runTrafficAlerts(sender, now.Add(30*time.Minute))
+	if len(sender.sent) != 1 || !strings.HasPrefix(sender.sent[0], "77:") || !strings.Contains(sender.sent[0], "80%") {
+		t.Fatalf("sent %v", sender.sent)
+	}
+
+	mu.Lock()
+	used = 1200
+	mu.Unlock()
+	botSvc.runTrafficAlerts(sender, now.Add(time.Hour))
+	if len(sender.sent) != 2 || !strings.Contains(sender.sent[1], "Трафик исчерпан") {
+		t.Fatalf("sent %v", sender.sent)
+	}
+
+	// Новые сутки (DAY) — новый период, пороги снова доступны.
+	botSvc.runTrafficAlerts(sender, now.Add(24*time.Hour))
+	if len(sender.sent) != 3 {
+		t.Fatalf("sent %v", sender.sent)
+	}
+}
@@ -0,0 +1,97 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/ryabkov82/vpnbot/internal/app/web"
+	"gopkg.in/telebot.v3"
+)
+
+// premiumUsageDays — окно истории в боте: 14 символов спарклайна помещаются в строку на телефоне.
+const premiumUsageDays = 14
+
+var sparkTicks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline — текстовый график: высота символа пропорциональна значению, 0 — нижний символ.
+func sparkline(values []int64) string {
+	var max int64
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+	var b strings.Builder
+	for _, v := range values {
+		i := 0
+		if max > 0 && v > 0 {
+			i = int(v * int64(len(sparkTicks)-1) / max)
+			if i == 0 {
+				i = 1
+			}
+		}
+		b.WriteRune(sparkTicks[i])
+	}
+	return b.String()
+}
+
+// premiumUsageText — сообщение «Трафик»: спарклайн по дням, итог и топ нод.
+func premiumUsageText(h *web.PremiumUsageHistory) string {
+	values := make([]int64, 0, len(h.Series))
+	var peak web.PremiumUsageDay
+	for _, d := range h.Series {
+		values = append(values, d.Bytes)
+		if d.Bytes > peak.Bytes {
+			peak = d
+		}
+	}
+	var text strings.Builder
+	fmt.Fprintf(&text, "<b>Трафик за %d дн.</b>\n\n", h.Days)
+	fmt.Fprintf(&text, "<code>%s</code>\n", sparkline(values))
+	fmt.Fprintf(&text, "%s — %s\n\n", premiumUsageDate(h.From), premiumUsageDate(h.To))
+	fmt.Fprintf(&text, "Всего: <b>%s</b>", html.EscapeString(h.TotalHuman))
+	if peak.Bytes > 0 {
+		fmt.Fprintf(&text, "\nМаксимум: %s (%s)", web.BytesHumanRu(peak.Bytes), premiumUsageDate(peak.Date))
+	}
+	if len(h.Nodes) > 0 {
+		text.WriteString("\n\n<b>По серверам:</b>")
+		for _, n := range h.Nodes {
+			name := n.Name
+			if n.CountryCode != "" {
+				name = n.CountryCode + " · " + name
+			}
+			fmt.Fprintf(&text, "\n• %s — %s", html.EscapeString(name), html.EscapeString(n.Human))
+		}
+	}
+	return text.String()
+}
+
+// premiumUsageDate: "2026-10-18" → "18.10".
+func premiumUsageDate(date string) string {
+	p := strings.Split(date, "-")
+	if len(p) != 3 {
+		return date
+	}
+	return p[2] + "." + p[1]
+}
+
+func (s *Service) handlePremiumUsage(c telebot.Context, serviceID string) error {
+	us, ok := s.loadOwnedPremiumService(c, serviceID, "⚠️ Статистика трафика доступна только для premium-услуг")
+	if !ok {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), premiumDevicesTimeout)
+	defer cancel()
+	h, err := web.LoadPremiumUsageHistory(ctx, s.remnawave, us, premiumUsageDays)
+	if err != nil {
+		log.Printf("premium usage: us=%d: %v", us.ServiceID, err)
+		return c.Send("⚠️ Статистика трафика временно недоступна. Попробуйте позже.")
+	}
+	menu := &telebot.ReplyMarkup{}
+	menu.Inline(menu.Row(menu.Data("⇦ Назад", "/service", strconv.Itoa(us.ServiceID))))
+	return c.Send(premiumUsageText(h), &telebot.SendOptions{ParseMode: telebot.ModeHTML, ReplyMarkup: menu})
+}
@@ -0,0 +1,31 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ryabkov82/vpnbot/internal/app/web"
+)
+
+func TestSparkline(t *testing.T) {
+	if got := sparkline([]int64{0, 1, 50, 100}); got != "▁▂▄█" {
+		t.Fatalf("got %q", got)
+	}
+	if got := sparkline([]int64{0, 0}); got != "▁▁" {
+		t.Fatalf("zeros: %q", got)
+	}
+}
+
+func TestPremiumUsageText(t *testing.T) {
+	h := &web.PremiumUsageHistory{
+		Days: 3, From: "2026-10-16", To: "2026-10-18", TotalHuman: "3 КБ",
+		Series: []web.PremiumUsageDay{{Date: "2026-10-16", Bytes: 1024}, {Date: "2026-10-17"}, {Date: "2026-10-18", Bytes: 2048}},
+		Nodes:  []web.PremiumUsageNode{{Name: "NL<1>", CountryCode: "NL", Human: "3 КБ"}},
+	}
+	text := premiumUsageText(h)
+	for _, want := range []string{"<code>▄▁█</code>", "16.10 — 18.10", "Максимум: 2 КБ (18.10)", "NL · NL&lt;1&gt; — 3 КБ"} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("missing %q in:\n%s", want, text)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package bot
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/ryabkov82/vpnbot/internal/proxylink"
+)
+
+// marzbanLinkCaption — подпись к QR отдельного ключа Marzban: протокол/транспорт, локация и сам URI.
+// Нераспознанные ссылки показываются как есть с общим заголовком.
+func marzbanLinkCaption(raw string) string {
+	raw = strings.TrimSpace(raw)
+	title := "Ключ"
+	if l, err := proxylink.Parse(raw); err == nil {
+		title = l.Label()
+		if r := strings.TrimSpace(l.Remark); r != "" {
+			title += " · " + r
+		}
+	}
+	return fmt.Sprintf("<b>%s:</b>\n<code>%s</code>", html.EscapeString(title), html.EscapeString(raw))
+}
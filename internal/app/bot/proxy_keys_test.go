@@ -0,0 +1,27 @@
+package bot
+
+import "testing"
+
+func TestMarzbanLinkCaption(t *testing.T) {
+	cases := []struct {
+		raw, want string
+	}{
+		{
+			"vless://id@h:443?security=reality&type=tcp&pbk=k#NL",
+			"<b>VLESS Reality TCP · NL:</b>\n<code>vless://id@h:443?security=reality&amp;type=tcp&amp;pbk=k#NL</code>",
+		},
+		{
+			"ss://YWVzLTI1Ni1nY206cHdAMS4yLjMuNDo4Mzg4",
+			"<b>Shadowsocks:</b>\n<code>ss://YWVzLTI1Ni1nY206cHdAMS4yLjMuNDo4Mzg4</code>",
+		},
+		{
+			"tuic://x",
+			"<b>Ключ:</b>\n<code>tuic://x</code>",
+		},
+	}
+	for _, c := range cases {
+		if got := marzbanLinkCaption(c.raw); got != c.want {
+			t.Fatalf("%q:\n got %q\nwant %q", c.raw, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package bot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/telebot.v3"
+
+	"github.com/ryabkov82/vpnbot/internal/models"
+	"github.com/ryabkov82/vpnbot/internal/receipts"
+	"github.com/ryabkov82/vpnbot/internal/service"
+)
+
+// statementMonthLayout — месяц выписки в callback-данных (/statement|2026-10).
+const statementMonthLayout = "2006-01"
+
+var botMoscow = time.FixedZone("MSK", 3*3600)
+
+// paysKeyboard — кнопки списка платежей: каждая открывает квитанцию; ниже выписки за текущий и прошлый месяц (МСК).
+func paysKeyboard(visible []models.UserPay, now time.Time) [][]telebot.InlineButton {
+	var rows [][]telebot.InlineButton
+	for _, pay := range visible {
+		rows = append(rows, []telebot.InlineButton{{
+			Text: fmt.Sprintf("Дата: %s, Сумма: %s", pay.Date, models.FormatRubAmount(pay.Money)),
+			Data: "/receipt|" + strconv.Itoa(pay.ID),
+		}})
+	}
+	cur := now.In(botMoscow)
+	cur = time.Date(cur.Year(), cur.Month(), 1, 12, 0, 0, 0, botMoscow)
+	prev := cur.AddDate(0, -1, 0)
+	rows = append(rows, []telebot.InlineButton{
+		{Text: "📄 Выписка " + statementMonthLabel(prev), Data: "/statement|" + prev.Format(statementMonthLayout)},
+		{Text: "📄 Выписка " + statementMonthLabel(cur), Data: "/statement|" + cur.Format(statementMonthLayout)},
+	})
+	return rows
+}
+
+func statementMonthLabel(t time.Time) string {
+	return t.Format("01.2006")
+}
+
+// handleReceipt отправляет квитанцию по платежу PDF-документом.
+func (s *Service) handleReceipt(c telebot.Context, payIDStr string) error {
+	payID, err := strconv.Atoi(strings.TrimSpace(payIDStr))
+	if err != nil || payID <= 0 {
+		return c.Respond(&telebot.CallbackResponse{Text: "Платёж не найден"})
+	}
+	user, err := s.service.GetUser(c.Sender().ID)
+	if err != nil || user == nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "⚠️ Не удалось получить данные пользователя"})
+	}
+	doc, err := s.service.PaymentReceipt(user.ID, payID)
+	if errors.Is(err, service.ErrPaymentNotFound) {
+		return c.Respond(&telebot.CallbackResponse{Text: "Платёж не найден"})
+	}
+	if err != nil {
+		log.Printf("receipt: user_id=%d pay_id=%d: %v", user.ID, payID, err)
+		return c.Respond(&telebot.CallbackResponse{Text: "⚠️ Не удалось сформировать квитанцию"})
+	}
+	return s.sendDocument(c, doc)
+}
+
+// handleStatement отправляет выписку за месяц YYYY-MM PDF-документом.
+func (s *Service) handleStatement(c telebot.Context, monthStr string) error {
+	month, err := time.ParseInLocation(statementMonthLayout, strings.TrimSpace(monthStr), botMoscow)
+	if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Неверный месяц"})
+	}
+	user, err := s.service.GetUser(c.Sender().ID)
+	if err != nil || user == nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "⚠️ Не удалось получить данные пользователя"})
+	}
+	doc, err := s.service.MonthlyStatement(user.ID, month.Add(12*time.Hour))
+	if err != nil {
+		log.Printf("statement: user_id=%d month=%s: %v", user.ID, monthStr, err)
+		return c.Respond(&telebot.CallbackResponse{Text: "⚠️ Не удалось сформировать выписку"})
+	}
+	return s.sendDocument(c, doc)
+}
+
+func (s *Service) sendDocument(c telebot.Context, doc *receipts.Document) error {
+	body, err := receipts.PDF(doc)
+	if err != nil {
+		log.Printf("document %s: render: %v", doc.Filename, err)
+		return c.Respond(&telebot.CallbackResponse{Text: "⚠️ Не удалось сформировать документ"})
+	}
+	if c.Callback() != nil {
+		_ = c.Respond()
+	}
+	return c.Send(&telebot.Document{
+		File:     telebot.FromReader(bytes.NewReader(body)),
+		FileName: doc.Filename + ".pdf",
+		MIME:     "application/pdf",
+		Caption:  doc.Title,
+	})
+}
@@ -0,0 +1,20 @@
+package bot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/models"
+)
+
+func TestPaysKeyboard_ReceiptAndStatementButtons(t *testing.T) {
+	// 31.10 22:30 UTC — уже ноябрь по Москве.
+	now := time.Date(2026, 10, 31, 22, 30, 0, 0, time.UTC)
+	rows := paysKeyboard([]models.UserPay{{ID: 5, Date: "2026-10-01 12:00:00", Money: 300}}, now)
+	if len(rows) != 2 || rows[0][0].Data != "/receipt|5" {
+		t.Fatalf("%+v", rows)
+	}
+	if rows[1][0].Data != "/statement|2026-10" || rows[1][1].Data != "/statement|2026-11" || rows[1][1].Text != "📄 Выписка 11.2026" {
+		t.Fatalf("%+v", rows[1])
+	}
+}
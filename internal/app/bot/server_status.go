@@ -0,0 +1,61 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/app/web"
+	"gopkg.in/telebot.v3"
+)
+
+var publicStatusIcons = map[string]string{
+	web.PublicStatusOnline:   "🟢",
+	web.PublicStatusDegraded: "🟡",
+	web.PublicStatusOffline:  "🔴",
+}
+
+// publicStatusText — сообщение /status: итог и строка на каждую локацию.
+func publicStatusText(st *web.PublicStatus) string {
+	var b strings.Builder
+	b.WriteString("<b>Статус серверов</b>\n\n")
+	switch st.Status {
+	case web.PublicStatusOperational:
+		b.WriteString("✅ Все локации работают")
+	case web.PublicStatusOutage:
+		b.WriteString("⛔️ Серверы недоступны, мы уже разбираемся")
+	default:
+		b.WriteString("⚠️ На части локаций есть проблемы")
+	}
+	if len(st.Locations) > 0 {
+		b.WriteString("\n")
+	}
+	for _, l := range st.Locations {
+		fmt.Fprintf(&b, "\n%s %s", publicStatusIcons[l.Status], html.EscapeString(l.Name))
+		if l.Status == web.PublicStatusDegraded {
+			fmt.Fprintf(&b, " — %d из %d серверов", l.NodesOnline, l.NodesTotal)
+		}
+	}
+	fmt.Fprintf(&b, "\n\n<i>Обновлено: %s МСК</i>", st.UpdatedAt.In(time.FixedZone("MSK", 3*60*60)).Format("15:04"))
+	return b.String()
+}
+
+func (s *Service) handleStatus(c telebot.Context) error {
+	ctx, cancel := context.WithTimeout(context.Background(), premiumDevicesTimeout)
+	defer cancel()
+	st, err := web.LoadPublicStatus(ctx, s.config, s.remnawave)
+	if err != nil {
+		log.Printf("status: %v", err)
+		return c.Send("⚠️ Статус серверов временно недоступен. Попробуйте позже.")
+	}
+	menu := &telebot.ReplyMarkup{}
+	row := []telebot.Btn{menu.Data("🔄 Обновить", "/status")}
+	if base := s.config.PublicBaseURL(); base != "" {
+		row = append(row, menu.URL("🌐 Страница статуса", base+"/status"))
+	}
+	menu.Inline(menu.Row(row...))
+	return c.Send(publicStatusText(st), &telebot.SendOptions{ParseMode: telebot.ModeHTML, ReplyMarkup: menu})
+}
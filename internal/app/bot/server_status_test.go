@@ -0,0 +1,27 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/app/web"
+)
+
+func TestPublicStatusText(t *testing.T) {
+	st := &web.PublicStatus{
+		Status:    web.PublicStatusDegraded,
+		UpdatedAt: time.Date(2026, 10, 18, 9, 5, 0, 0, time.UTC),
+		Locations: []web.PublicStatusLocation{
+			{Name: "Германия", Status: web.PublicStatusOnline, NodesOnline: 1, NodesTotal: 1},
+			{Name: "Нидерланды", Status: web.PublicStatusDegraded, NodesOnline: 1, NodesTotal: 2},
+			{Name: "<Финляндия>", Status: web.PublicStatusOffline, NodesTotal: 1},
+		},
+	}
+	text := publicStatusText(st)
+	for _, want := range []string{"⚠️ На части локаций", "🟢 Германия\n", "🟡 Нидерланды — 1 из 2 серверов", "🔴 &lt;Финляндия&gt;", "Обновлено: 12:05 МСК"} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("missing %q in:\n%s", want, text)
+		}
+	}
+}
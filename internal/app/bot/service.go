@@ -14,7 +14,9 @@ import (
 	"time"
 
 	"github.com/ryabkov82/vpnbot/internal/app/web"
+	"github.com/ryabkov82/vpnbot/internal/attribution"
 	"github.com/ryabkov82/vpnbot/internal/config"
+	"github.com/ryabkov82/vpnbot/internal/infrastructure/remnawave"
 	"github.com/ryabkov82/vpnbot/internal/models"
 	"github.com/ryabkov82/vpnbot/internal/registrationevent"
 	"github.com/ryabkov82/vpnbot/internal/service"
@@ -24,8 +26,9 @@ import (
 
 // Service содержит бизнес-логику обработки команд
 type Service struct {
-	service *service.Service
-	config  *config.Config
+	service   *service.Service
+	config    *config.Config
+	remnawave *remnawave.Client
 
 	serviceBuyMu       sync.Mutex
 	serviceBuyInFlight map[string]struct{}
@@ -69,6 +72,16 @@ func (s *Service) handleStart(c telebot.Context) error {
 		payload = strings.TrimSpace(c.Message().Payload)
 	}
 
+	// Обратная привязка web → Telegram (deep link из личного кабинета).
+	if isTelegramConnectStartPayload(payload) {
+		return s.handleTelegramConnectStart(c, payload)
+	}
+
+	// Ссылка подарка (t.me/<bot>?start=gift_…).
+	if s.config.Gifts.Enabled && isGiftStartPayload(payload) {
+		return s.handleGiftStart(c, payload, capturedAt)
+	}
+
 	trialCfg := s.config.Features.Trial
 	if trialCfg.Enabled && trialCfg.RequireStartParam && payload != "" {
 		allowed := false
@@ -233,34 +246,13 @@ func (s *Service) handleBalance(c telebot.Context) error {
 		return c.Send("Ошибка системы, попробуйте позже")
 	}
 
-	apiBase := ""
-	paymentProfile := ""
-	yookassaPS := ""
-	brandID := ""
-	if s.config != nil {
-		apiBase = s.config.API.BaseURL
-		paymentProfile = s.config.PaymentProfile()
-		yookassaPS = s.config.YooKassaPaySystem()
-		brandID = s.config.BrandID()
-	}
-	payURL, err := telegramPaymentsWebAppURL(apiBase, userBalance.ID, paymentProfile, yookassaPS, brandID)
-	if err != nil {
-		log.Printf("handleBalance: telegram payments webapp url: %v", err)
-		return c.Send("Ошибка системы, попробуйте позже")
-	}
-
 	menu := &telebot.ReplyMarkup{}
-	btnPay := menu.WebApp("✚ Пополнить баланс", &telebot.WebApp{URL: payURL})
-
-	btnPays := menu.Data("☰ История платежей", "/pays")
-
-	btnBack := menu.Data("⇦ Назад", "/menu")
-
-	menu.Inline(
-		menu.Row(btnPay),
-		menu.Row(btnPays),
-		menu.Row(btnBack),
+	rows := s.topupProviderRows(menu, userBalance.ID)
+	rows = append(rows,
+		menu.Row(menu.Data("☰ История платежей", "/pays"), menu.Data("🧾 Списания", "/history")),
+		menu.Row(menu.Data("⇦ Назад", "/menu")),
 	)
+	menu.Inline(rows...)
 
 	msg := fmt.Sprintf("💰 *Баланс*: %.2f\n\nНеобходимо оплатить: *%.2f*", userBalance.Balance, userBalance.Forecast)
 
@@ -502,6 +494,9 @@ func (s *Service) handleServiceOrder(c telebot.Context, serviceID string) error
 		log.Printf("handleServiceOrder: service %d category %q not allowed", svc.ServiceID, svc.Category)
 		return c.Send("⚠️ Услуга не найдена")
 	}
+	if handled, err := s.offerOrderPayment(c, svc); handled {
+		return err
+	}
 
 	_, err = s.service.ServiceOrder(c.Chat().ID, serviceID)
 
@@ -637,7 +632,7 @@ func (s *Service) handleService(c telebot.Context, serviceID string) error {
 		}
 	}
 
-	us, _, err := s.loadOwnedUserService(c.Chat().ID, serviceID)
+	us, user, err := s.loadOwnedUserService(c.Chat().ID, serviceID)
 	if err != nil {
 		if errors.Is(err, service.ErrUserNotFound) {
 			return s.showRegistrationMenu(c)
@@ -677,6 +672,17 @@ func (s *Service) handleService(c telebot.Context, serviceID string) error {
 
 	text.WriteString(fmt.Sprintf("\n\n<b>Статус</b>: %s", status))
 
+	// Автопродление и следующее списание — только для услуг со следующим периодом.
+	var renewal *service.RenewalPreview
+	if us.Status == "ACTIVE" || us.Status == "BLOCK" {
+		if p, err := s.service.RenewalPreview(user.ID, us.ServiceID); err != nil {
+			log.Printf("renewal preview: user_id=%d user_service_id=%d: %v", user.ID, us.ServiceID, err)
+		} else {
+			renewal = p
+			text.WriteString(renewalCardText(renewal))
+		}
+	}
+
 	// Создаем inline-клавиатуру
 	menu := &telebot.ReplyMarkup{}
 	var rows []telebot.Row
@@ -692,6 +698,14 @@ func (s *Service) handleService(c telebot.Context, serviceID string) error {
 							URL: premiumURL,
 						}),
 					))
+					if s.remnawave != nil {
+						rows = append(rows, menu.Row(
+							menu.Data("📱 Устройства", "/devices", fmt.Sprint(us.ServiceID)),
+							menu.Data("🔄 Сброс", "/premium_reset", fmt.Sprint(us.ServiceID)),
+						), menu.Row(
+							menu.Data("📊 Трафик", "/premium_usage", fmt.Sprint(us.ServiceID)),
+						))
+					}
 				} else {
 					text.WriteString("\n\nПодключение временно недоступно. Обратитесь в поддержку.")
 					if strings.TrimSpace(s.config.Telegram.SupportChat) != "" {
@@ -707,6 +721,9 @@ func (s *Service) handleService(c telebot.Context, serviceID string) error {
 					}),
 					menu.Data("Показать ссылку подписки", "/show_mz_keys", fmt.Sprint(us.ServiceID)),
 				))
+				rows = append(rows, menu.Row(
+					menu.Data("📲 Открыть в приложении", "/connect_apps", fmt.Sprint(us.ServiceID)),
+				))
 			}
 
 		} else {
@@ -724,6 +741,11 @@ func (s *Service) handleService(c telebot.Context, serviceID string) error {
 		))
 	}
 
+	rows = append(rows, renewalCardRows(menu, renewal)...)
+	if s.config.PlanChange.Enabled && us.Status == "ACTIVE" {
+		rows = append(rows, menu.Row(menu.Data("🔀 Сменить тариф", "/plan", fmt.Sprint(us.ServiceID))))
+	}
+
 	// Третий ряд (удаление для всех кроме PROGRESS)
 	if us.Status != "PROGRESS" {
 		rows = append(rows, menu.Row(
@@ -824,30 +846,25 @@ func (s *Service) handleShowMZ(c telebot.Context, serviceID string) error {
 		return err
 	}
 
-	link := userKey.Links[0]
-
-	qrBytes, err = service.GenerateQRCode(userKey.SubscriptionURL)
-	if err != nil {
-		log.Printf("Ошибка генерации QR-кода: %v", err)
-		return c.Send("⚠️ Не удалось создать QR-код")
-	}
-
-	caption := ""
-	if strings.HasPrefix(link, "ss") {
-		caption = fmt.Sprintf("<b>ShadowSocks:</b>\n<code>%s</code>", link)
-	} else {
-		caption = fmt.Sprintf("<b>VLESS TCP:</b>\n<code>%s</code>", link)
-	}
-
-	photo = &telebot.Photo{
-		File:    telebot.FromReader(bytes.NewReader(qrBytes)),
-		Caption: caption,
+	// Каждый ключ — отдельным QR: протокол и транспорт берём из разбора URI, локацию — из remark.
+	for _, link := range userKey.Links {
+		if strings.TrimSpace(link) == "" {
+			continue
+		}
+		qrBytes, err = service.GenerateQRCode(link)
+		if err != nil {
+			log.Printf("Ошибка генерации QR-кода: %v", err)
+			return c.Send("⚠️ Не удалось создать QR-код")
+		}
+		photo = &telebot.Photo{
+			File:    telebot.FromReader(bytes.NewReader(qrBytes)),
+			Caption: marzbanLinkCaption(link),
+		}
+		if err := c.Send(photo, &telebot.SendOptions{ParseMode: telebot.ModeHTML}); err != nil {
+			return err
+		}
 	}
-
-	return c.Send(photo, &telebot.SendOptions{
-		ParseMode: telebot.ModeHTML,
-	})
-
+	return nil
 }
 
 func (s *Service) handleShowQR(c telebot.Context, serviceID string) error {
@@ -983,6 +1000,16 @@ func (s *Service) handleRegister(c telebot.Context) error {
 		rec = organic
 	}
 
+	if _, err := s.registerTelegramUser(c, rec); err != nil {
+		return c.Send("⚠️ Ошибка регистрации. Пожалуйста, попробуйте позже.")
+	}
+	return s.showMainMenu(c)
+}
+
+// registerTelegramUser создаёт пользователя SHM для отправителя с first-touch rec (канал telegram или gift)
+// и пишет событие регистрации. Возвращает созданного пользователя; nil без ошибки — запись не нашлась сразу после создания.
+func (s *Service) registerTelegramUser(c telebot.Context, rec attribution.Record) (*models.User, error) {
+	chatID := c.Chat().ID
 	user := c.Sender()
 	userID := fmt.Sprintf("%d", user.ID)
 
@@ -1008,29 +1035,29 @@ func (s *Service) handleRegister(c telebot.Context) error {
 		},
 	}
 
-	err = s.service.RegisterUserWithAttribution(regData, rec)
-	if err != nil {
+	if err := s.service.RegisterUserWithAttribution(regData, rec); err != nil {
 		log.Println("Ошибка регистрации:", err)
-		return c.Send("⚠️ Ошибка регистрации. Пожалуйста, попробуйте позже.")
+		return nil, err
 	}
 
+	channel := string(rec.FirstTouch.RegistrationChannel)
 	createdUser, lookupErr := s.service.GetUser(chatID)
 	if lookupErr != nil || createdUser == nil {
 		slog.Warn("registration event skipped",
 			"brand_id", s.config.BrandID(),
-			"registration_channel", "telegram",
+			"registration_channel", channel,
 			"reason", "post_create_lookup_failed",
 		)
 	} else if err := registrationevent.Emit(slog.Default(), s.config.BrandID(), createdUser.ID, rec); err != nil {
 		slog.Warn("registration event skipped",
 			"brand_id", s.config.BrandID(),
-			"registration_channel", "telegram",
+			"registration_channel", channel,
 			"reason", "emit_failed",
 		)
 	}
 
 	s.clearTelegramAttribution(chatID)
-	return s.showMainMenu(c)
+	return createdUser, nil
 }
 
 func (s *Service) handleHelp(c telebot.Context) error {
@@ -1112,6 +1139,9 @@ func (s *Service) handlePays(c telebot.Context) error {
 
 	visible := models.VisibleUserPays(pays)
 	caption := paysListCaption(visible, len(pays))
+	if len(visible) > 0 {
+		caption += "\n\nНажмите на платёж, чтобы получить квитанцию (PDF)."
+	}
 	backBtn := telebot.InlineButton{Text: "⇦ Назад", Data: "/menu"}
 	backRow := []telebot.InlineButton{backBtn}
 
@@ -1124,16 +1154,7 @@ func (s *Service) handlePays(c telebot.Context) error {
 		)
 	}
 
-	var inlineKeys [][]telebot.InlineButton
-	for _, pay := range visible {
-		btn := telebot.InlineButton{
-			Text: fmt.Sprintf("Дата: %s, Сумма: %s", pay.Date, models.FormatRubAmount(pay.Money)),
-			Data: "/menu",
-		}
-		inlineKeys = append(inlineKeys, []telebot.InlineButton{btn})
-	}
-
-	inlineKeys = append(inlineKeys, backRow)
+	inlineKeys := append(paysKeyboard(visible, time.Now()), backRow)
 
 	return c.Send(
 		s.logoPhoto(caption),
@@ -0,0 +1,164 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"gopkg.in/telebot.v3"
+
+	"github.com/ryabkov82/vpnbot/internal/models"
+	"github.com/ryabkov82/vpnbot/internal/service"
+)
+
+// renewalCardText — блок продления в карточке услуги (HTML): автопродление, дата и сумма списания, нехватка баланса.
+func renewalCardText(p *service.RenewalPreview) string {
+	if p == nil || !p.Renewable() {
+		return ""
+	}
+	var b strings.Builder
+	if p.AutoRenew {
+		b.WriteString("\n\n<b>Автопродление</b>: включено")
+	} else {
+		b.WriteString("\n\n<b>Автопродление</b>: выключено — по окончании срока услуга не продлится")
+	}
+	switch {
+	case p.Status == "BLOCK":
+		fmt.Fprintf(&b, "\n<b>Продление</b>: %s после пополнения баланса", models.FormatRubAmount(p.Amount))
+	case p.AutoRenew && !p.NextChargeAt.IsZero():
+		fmt.Fprintf(&b, "\n<b>Следующее списание</b>: %s — %s", p.NextChargeAt.In(botMoscow).Format("02.01.2006"), models.FormatRubAmount(p.Amount))
+	}
+	if !p.Covered() && (p.AutoRenew || p.Status == "BLOCK") {
+		fmt.Fprintf(&b, "\n\n⚠️ На балансе не хватает %s для следующего периода — пополните баланс заранее.", models.FormatRubAmount(p.Shortfall()))
+	}
+	return b.String()
+}
+
+// renewalCardRows — кнопки автопродления и «Продлить сейчас» для карточки услуги.
+func renewalCardRows(menu *telebot.ReplyMarkup, p *service.RenewalPreview) []telebot.Row {
+	if p == nil || !p.Renewable() {
+		return nil
+	}
+	id := strconv.Itoa(p.UserServiceID)
+	toggle := menu.Data("🔁 Выключить автопродление", "/autorenew", id, "off")
+	if !p.AutoRenew {
+		toggle = menu.Data("🔁 Включить автопродление", "/autorenew", id, "on")
+	}
+	rows := []telebot.Row{menu.Row(toggle)}
+	if p.Covered() {
+		rows = append(rows, menu.Row(menu.Data("⚡ Продлить сейчас", "/renew", id)))
+	} else if p.Status == "ACTIVE" {
+		rows = append(rows, menu.Row(menu.Data("💰 Пополнить баланс", "/balance", "")))
+	}
+	return rows
+}
+
+// renewConfirmText — подтверждение продления с баланса.
+func renewConfirmText(p *service.RenewalPreview) string {
+	return fmt.Sprintf("Продлить «%s» сейчас?\n\nС баланса будет списано %s (на балансе %s). Новый период начнётся после текущего — оплаченные дни не пропадут.",
+		p.Name, models.FormatRubAmount(p.Amount), models.FormatRubAmount(p.Balance))
+}
+
+// renewalErrorText — сообщение пользователю об ошибке автопродления или продления.
+func renewalErrorText(err error) string {
+	switch {
+	case errors.Is(err, service.ErrUserServiceUnavailable):
+		return "⚠️ Услуга не найдена или недоступна"
+	case errors.Is(err, service.ErrRenewalStale):
+		return "ℹ️ Услуга уже изменилась — откройте её карточку ещё раз."
+	case errors.Is(err, service.ErrRenewalUnavailable):
+		return "⚠️ Эту услугу сейчас нельзя продлить."
+	case errors.Is(err, service.ErrRenewalInsufficientBalance):
+		return "💰 На балансе недостаточно средств для продления. Пополните баланс и попробуйте снова."
+	}
+	return "⚠️ Не удалось выполнить операцию. Попробуйте позже."
+}
+
+func renewalErrorExpected(err error) bool {
+	return errors.Is(err, service.ErrUserServiceUnavailable) || errors.Is(err, service.ErrRenewalStale) ||
+		errors.Is(err, service.ErrRenewalUnavailable) || errors.Is(err, service.ErrRenewalInsufficientBalance)
+}
+
+// renewalUser — SHM-пользователь и user_service_id из callback; ok=false — ответ пользователю уже отправлен.
+func (s *Service) renewalUser(c telebot.Context, serviceID string) (*models.User, int, bool, error) {
+	usID, err := strconv.Atoi(serviceID)
+	if err != nil || usID <= 0 {
+		return nil, 0, false, c.Send("⚠️ Услуга не найдена или недоступна")
+	}
+	user, err := s.service.GetUser(c.Chat().ID)
+	if err != nil {
+		log.Printf("renewal: get user chat=%d: %v", c.Chat().ID, err)
+		return nil, 0, false, c.Send("⚠️ Не удалось получить данные пользователя")
+	}
+	if user == nil {
+		return nil, 0, false, s.showRegistrationMenu(c)
+	}
+	return user, usID, true, nil
+}
+
+// handleAutoRenew — /autorenew|<user_service_id>|on|off: переключает автопродление и заново показывает карточку.
+func (s *Service) handleAutoRenew(c telebot.Context, serviceID, state string) error {
+	user, usID, ok, err := s.renewalUser(c, serviceID)
+	if !ok {
+		return err
+	}
+	if _, err := s.service.SetAutoRenew(user.ID, usID, state == "on"); err != nil {
+		if !renewalErrorExpected(err) {
+			log.Printf("autorenew: user_id=%d user_service_id=%d: %v", user.ID, usID, err)
+		}
+		return c.Send(renewalErrorText(err))
+	}
+	return s.handleService(c, serviceID)
+}
+
+// handleRenew — /renew|<user_service_id>: предпросмотр продления с кнопкой подтверждения.
+func (s *Service) handleRenew(c telebot.Context, serviceID string) error {
+	user, usID, ok, err := s.renewalUser(c, serviceID)
+	if !ok {
+		return err
+	}
+	p, err := s.service.RenewalPreview(user.ID, usID)
+	if err == nil && !p.Renewable() {
+		err = service.ErrRenewalUnavailable
+	}
+	if err != nil {
+		if !renewalErrorExpected(err) {
+			log.Printf("renew preview: user_id=%d user_service_id=%d: %v", user.ID, usID, err)
+		}
+		return c.Send(renewalErrorText(err))
+	}
+	back := []telebot.InlineButton{{Text: "⇦ Назад", Data: "/service|" + serviceID}}
+	if !p.Covered() {
+		text := fmt.Sprintf("💰 Для продления «%s» нужно %s, на балансе %s. Не хватает %s.",
+			p.Name, models.FormatRubAmount(p.Amount), models.FormatRubAmount(p.Balance), models.FormatRubAmount(p.Shortfall()))
+		return s.editOrSend(c, text, [][]telebot.InlineButton{{{Text: "💰 Пополнить баланс", Data: "/balance"}}, back})
+	}
+	rows := [][]telebot.InlineButton{
+		{{Text: "✅ Продлить за " + models.FormatRubAmount(p.Amount), Data: "/renew_ok|" + serviceID + "|" + p.Expire}},
+		back,
+	}
+	return s.editOrSend(c, renewConfirmText(p), rows)
+}
+
+// handleRenewConfirmed — /renew_ok|<user_service_id>|<expire>: продление; expire из предпросмотра защищает от повторного нажатия.
+func (s *Service) handleRenewConfirmed(c telebot.Context, serviceID, expire string) error {
+	user, usID, ok, err := s.renewalUser(c, serviceID)
+	if !ok {
+		return err
+	}
+	p, err := s.service.RenewUserService(user.ID, usID, expire)
+	if err != nil {
+		if !renewalErrorExpected(err) {
+			log.Printf("renew: user_id=%d user_service_id=%d: %v", user.ID, usID, err)
+		}
+		return c.Send(renewalErrorText(err))
+	}
+	until := p.Expire
+	if !p.NextChargeAt.IsZero() {
+		until = p.NextChargeAt.In(botMoscow).Format("02.01.2006 15:04")
+	}
+	rows := [][]telebot.InlineButton{{{Text: "🔑 К услуге", Data: "/service|" + serviceID}}}
+	return s.editOrSend(c, fmt.Sprintf("✅ Услуга «%s» продлена до %s.", p.Name, until), rows)
+}
@@ -0,0 +1,49 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/telebot.v3"
+
+	"github.com/ryabkov82/vpnbot/internal/service"
+)
+
+func TestRenewalCardText(t *testing.T) {
+	p := &service.RenewalPreview{UserServiceID: 501, Status: "ACTIVE", Amount: 450, Balance: 100, AutoRenew: true,
+		NextChargeAt: time.Date(2026, 11, 1, 10, 0, 0, 0, botMoscow)}
+	got := renewalCardText(p)
+	for _, want := range []string{"включено", "01.11.2026 — 450 ₽", "не хватает 350 ₽"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("missing %q in %q", want, got)
+		}
+	}
+	rows := renewalCardRows(&telebot.ReplyMarkup{}, p)
+	if len(rows) != 2 || rows[0][0].Data != "501|off" || rows[1][0].Unique != "/balance" {
+		t.Fatalf("%+v", rows)
+	}
+
+	p.AutoRenew, p.Balance = false, 500
+	got = renewalCardText(p)
+	if !strings.Contains(got, "выключено") || strings.Contains(got, "не хватает") || strings.Contains(got, "Следующее списание") {
+		t.Fatalf("%q", got)
+	}
+	rows = renewalCardRows(&telebot.ReplyMarkup{}, p)
+	if len(rows) != 2 || rows[0][0].Data != "501|on" || rows[1][0].Unique != "/renew" {
+		t.Fatalf("%+v", rows)
+	}
+
+	if renewalCardText(&service.RenewalPreview{Status: "NOT PAID", Amount: 450}) != "" {
+		t.Fatal("not paid service has no renewal block")
+	}
+}
+
+func TestRenewalErrorText(t *testing.T) {
+	if got := renewalErrorText(service.ErrRenewalInsufficientBalance); !strings.Contains(got, "недостаточно") {
+		t.Fatal(got)
+	}
+	if got := renewalErrorText(service.ErrRenewalStale); !strings.Contains(got, "изменилась") {
+		t.Fatal(got)
+	}
+}
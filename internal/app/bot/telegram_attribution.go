@@ -48,6 +48,25 @@ func buildTelegramRegistrationAttribution(
 	)
 }
 
+// buildGiftRegistrationAttribution — first-touch для регистрации по ссылке подарка: канал gift и покупатель.
+func buildGiftRegistrationAttribution(cfg *config.Config, startParam string, giftedBy int, capturedAt time.Time) (attribution.Record, error) {
+	domain, err := telegramRegistrationDomainFromConfig(cfg)
+	if err != nil {
+		return attribution.Record{}, err
+	}
+	return attribution.NewFirstTouch(
+		attribution.ServerContext{
+			RegistrationChannel: attribution.RegistrationChannelGift,
+			RegistrationDomain:  domain,
+			CapturedAt:          capturedAt,
+			GiftedByUserID:      giftedBy,
+		},
+		attribution.MarketingInput{
+			TelegramStartParam: startParam,
+		},
+	)
+}
+
 func telegramRegistrationDomainFromConfig(cfg *config.Config) (string, error) {
 	if cfg == nil {
 		return "", errTelegramAttributionPublicBaseURL
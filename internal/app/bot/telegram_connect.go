@@ -0,0 +1,83 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/ryabkov82/vpnbot/internal/app/web"
+	"github.com/ryabkov82/vpnbot/internal/models"
+	"github.com/ryabkov82/vpnbot/internal/service"
+
+	"gopkg.in/telebot.v3"
+)
+
+// errTelegramConnectLinkInvalid — start-параметр link_… не прошёл проверку подписи/срока/бренда.
+var errTelegramConnectLinkInvalid = errors.New("telegram connect link invalid")
+
+func isTelegramConnectStartPayload(payload string) bool {
+	return strings.HasPrefix(strings.TrimSpace(payload), web.TelegramConnectStartPrefix)
+}
+
+// telegramConnectResultMessage — текст пользователю по результату обратной привязки (nil = успех).
+func telegramConnectResultMessage(err error) string {
+	switch {
+	case err == nil:
+		return "✅ Telegram привязан к вашему личному кабинету. Услуги и баланс кабинета теперь доступны в боте."
+	case errors.Is(err, errTelegramConnectLinkInvalid):
+		return "⚠️ Ссылка для привязки недействительна или устарела. Откройте личный кабинет на сайте и нажмите «Подключить Telegram» ещё раз."
+	case errors.Is(err, service.ErrTelegramUsedByOtherAccount):
+		return "⚠️ Этот Telegram уже зарегистрирован в боте как отдельный аккаунт. Автоматическое объединение недоступно — напишите в поддержку, мы поможем объединить аккаунты."
+	case errors.Is(err, service.ErrTelegramAlreadyLinked):
+		return "⚠️ К этому личному кабинету уже привязан другой Telegram-аккаунт. Обратитесь в поддержку."
+	default:
+		return "⚠️ Не удалось привязать Telegram. Попробуйте позже или обратитесь в поддержку."
+	}
+}
+
+// telegramInfoFromSender — блок settings.telegram в том же формате, что при регистрации в боте.
+func telegramInfoFromSender(user *telebot.User) models.TelegramInfo {
+	if user == nil {
+		return models.TelegramInfo{}
+	}
+	return models.TelegramInfo{
+		UserID:       fmt.Sprintf("%d", user.ID),
+		Username:     user.Username,
+		Login:        user.Username,
+		FirstName:    user.FirstName,
+		LastName:     user.LastName,
+		LanguageCode: user.LanguageCode,
+		IsPremium:    user.IsPremium,
+		ChatID:       user.ID,
+	}
+}
+
+// connectTelegramFromStart проверяет подписанный start-параметр из web-кабинета и привязывает chat к web-пользователю.
+func (s *Service) connectTelegramFromStart(chatID int64, sender *telebot.User, payload string) error {
+	secret := strings.TrimSpace(s.config.WebSales.OrderTokenSecret)
+	userID, err := web.VerifyTelegramConnectStartParam(secret, s.config.BrandID(), payload)
+	if err != nil {
+		log.Printf("telegram connect: invalid start param: %v", err)
+		return errTelegramConnectLinkInvalid
+	}
+	tg := telegramInfoFromSender(sender)
+	tg.ChatID = chatID
+	if _, err := s.service.LinkTelegramForWebUser(userID, tg); err != nil {
+		log.Printf("telegram connect: user_id=%d: %v", userID, err)
+		return err
+	}
+	return nil
+}
+
+func (s *Service) handleTelegramConnectStart(c telebot.Context, payload string) error {
+	err := s.connectTelegramFromStart(c.Chat().ID, c.Sender(), payload)
+	if sendErr := c.Send(telegramConnectResultMessage(err)); sendErr != nil {
+		log.Printf("telegram connect: send result: %v", sendErr)
+	}
+	if err != nil {
+		return nil
+	}
+	s.clearTelegramAttribution(c.Chat().ID)
+	return s.showMainMenu(c)
+}
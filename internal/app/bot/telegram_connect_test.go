@@ -0,0 +1,54 @@
+package bot
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/app/web"
+	"github.com/ryabkov82/vpnbot/internal/config"
+	"github.com/ryabkov82/vpnbot/internal/service"
+)
+
+func TestIsTelegramConnectStartPayload(t *testing.T) {
+	if !isTelegramConnectStartPayload("link_abc") {
+		t.Fatal("link_ prefix")
+	}
+	for _, p := range []string{"", "promo", "trial_link_x"} {
+		if isTelegramConnectStartPayload(p) {
+			t.Fatalf("%q must not be a connect payload", p)
+		}
+	}
+}
+
+func TestTelegramConnectResultMessage(t *testing.T) {
+	if !strings.Contains(telegramConnectResultMessage(nil), "Telegram привязан") {
+		t.Fatal("success text")
+	}
+	if !strings.Contains(telegramConnectResultMessage(service.ErrTelegramUsedByOtherAccount), "поддержку") {
+		t.Fatal("conflict must point to support")
+	}
+	if !strings.Contains(telegramConnectResultMessage(errTelegramConnectLinkInvalid), "устарела") {
+		t.Fatal("invalid link text")
+	}
+	if telegramConnectResultMessage(errors.New("x")) == telegramConnectResultMessage(nil) {
+		t.Fatal("generic error must differ from success")
+	}
+}
+
+func TestConnectTelegramFromStart_RejectsForeignBrandParam(t *testing.T) {
+	secret := strings.Repeat("c", 40)
+	cfg := &config.Config{}
+	cfg.Brand.ID = "vff"
+	cfg.WebSales.OrderTokenSecret = secret
+	s := NewService(nil, cfg)
+	param, err := web.CreateTelegramConnectStartParam(secret, "fc", 5, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Сервис SHM не вызывается: подпись другого бренда отклоняется раньше.
+	if err := s.connectTelegramFromStart(100, nil, param); !errors.Is(err, errTelegramConnectLinkInvalid) {
+		t.Fatalf("err=%v", err)
+	}
+}
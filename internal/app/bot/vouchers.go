@@ -0,0 +1,82 @@
+package bot
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"gopkg.in/telebot.v3"
+
+	"github.com/ryabkov82/vpnbot/internal/models"
+	"github.com/ryabkov82/vpnbot/internal/service"
+	"github.com/ryabkov82/vpnbot/internal/vouchers"
+)
+
+const redeemUsageText = "🎁 Отправьте подарочный код командой:\n/redeem XXXXX-XXXXX-XXXXX-XXXXX-XXXXX"
+
+func (h *BotHandler) handleRedeem(c telebot.Context) error {
+	return h.service.handleRedeem(c)
+}
+
+// handleRedeem — /redeem CODE: активация подарочного кода (деньги на баланс или заказ услуги).
+func (s *Service) handleRedeem(c telebot.Context) error {
+	if !s.config.Vouchers.Enabled() {
+		return c.Send("Подарочные коды сейчас не принимаются.")
+	}
+	code := strings.TrimSpace(c.Message().Payload)
+	if code == "" {
+		return c.Send(redeemUsageText)
+	}
+	user, err := s.service.GetUser(c.Sender().ID)
+	if err != nil || user == nil {
+		return c.Send("⚠️ Не удалось получить данные пользователя")
+	}
+	res, err := s.service.RedeemVoucher(service.VoucherRedeemRequest{
+		UserID:    user.ID,
+		Code:      code,
+		Secret:    s.config.Vouchers.Secret,
+		PaySystem: s.config.Vouchers.SHMPaySystem(),
+	})
+	if err != nil {
+		text := redeemErrorText(err)
+		if strings.HasPrefix(text, "⚠️") {
+			log.Printf("redeem: user_id=%d: %v", user.ID, err)
+		}
+		return c.Send(text)
+	}
+	markup := &telebot.ReplyMarkup{InlineKeyboard: [][]telebot.InlineButton{{{Text: "💰 Баланс", Data: "/balance"}, {Text: "☰ Меню", Data: "/menu"}}}}
+	return c.Send(redeemResultText(res), markup)
+}
+
+// redeemResultText — итог активации для пользователя.
+func redeemResultText(r *service.VoucherRedemption) string {
+	prefix := "🎁 "
+	if r.Duplicate {
+		prefix = "🎁 Этот код уже активирован вами. "
+	}
+	switch r.Status {
+	case service.VoucherOrdered, service.VoucherOrdering:
+		return prefix + "Услуга «" + r.ServiceName + "» оформлена по подарочному коду. Ключ появится в /list."
+	case service.VoucherOrderFailed:
+		return prefix + "На баланс зачислено " + models.FormatRubAmount(r.Amount) + ", но заказать услугу «" + r.ServiceName + "» не удалось. Отправьте код ещё раз или напишите в поддержку."
+	case service.VoucherExistingUnpaid:
+		return prefix + "На баланс зачислено " + models.FormatRubAmount(r.Amount) + ", но услугу «" + r.ServiceName + "» автоматически оформить не удалось: у вас есть неоплаченная услуга. Напишите в поддержку."
+	}
+	return prefix + "На баланс зачислено " + models.FormatRubAmount(r.Amount) + "."
+}
+
+func redeemErrorText(err error) string {
+	switch {
+	case errors.Is(err, vouchers.ErrMalformed):
+		return "❌ Код введён не полностью — проверьте все 25 символов."
+	case errors.Is(err, vouchers.ErrInvalid):
+		return "❌ Код не найден — проверьте, нет ли опечатки."
+	case errors.Is(err, service.ErrVoucherExpired):
+		return "⌛ Срок действия кода истёк."
+	case errors.Is(err, service.ErrVoucherUsed):
+		return "❌ Этот код уже активирован."
+	case errors.Is(err, service.ErrServiceCategoryDenied):
+		return "⚠️ Услуга по этому коду недоступна. Напишите в поддержку."
+	}
+	return "⚠️ Не удалось активировать код. Попробуйте позже."
+}
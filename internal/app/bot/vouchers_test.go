@@ -0,0 +1,38 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ryabkov82/vpnbot/internal/service"
+	"github.com/ryabkov82/vpnbot/internal/vouchers"
+)
+
+func TestRedeemResultText(t *testing.T) {
+	if got := redeemResultText(&service.VoucherRedemption{Status: service.VoucherCredited, Amount: 500}); got != "🎁 На баланс зачислено 500 ₽." {
+		t.Fatal(got)
+	}
+	got := redeemResultText(&service.VoucherRedemption{Status: service.VoucherOrderFailed, Amount: 450, ServiceName: "Premium 1m", Duplicate: true})
+	for _, want := range []string{"уже активирован вами", "зачислено 450 ₽", "«Premium 1m» не удалось"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("missing %q in %q", want, got)
+		}
+	}
+	got = redeemResultText(&service.VoucherRedemption{Status: service.VoucherExistingUnpaid, Amount: 450, ServiceName: "Premium 1m"})
+	if !strings.Contains(got, "неоплаченная услуга") || strings.Contains(got, "оформлена") {
+		t.Fatal(got)
+	}
+}
+
+func TestRedeemErrorText(t *testing.T) {
+	if got := redeemErrorText(fmt.Errorf("decode: %w", vouchers.ErrInvalid)); !strings.Contains(got, "опечатки") {
+		t.Fatal(got)
+	}
+	if got := redeemErrorText(&service.ServiceCategoryDeniedError{ServiceID: 7}); !strings.Contains(got, "недоступна") {
+		t.Fatal(got)
+	}
+	if got := redeemErrorText(service.ErrVoucherUsed); got != "❌ Этот код уже активирован." {
+		t.Fatal(got)
+	}
+}
@@ -80,7 +80,7 @@ func TestAccountLoginStart_IdentityMismatch_NoEmailSent(t *testing.T) {
 	rl := newLeadRateLimiter(50, time.Hour, 50, time.Hour)
 	st := &stubAccountWeb{findUserByWebEmailErr: appService.ErrUserIdentityMismatch}
 	rec := httptest.NewRecorder()
-	serveAccountLoginStart(cfg, st, rl).ServeHTTP(rec,
+	serveAccountLoginStartWithCodes(cfg, st, rl, nil).ServeHTTP(rec,
 		httptest.NewRequest(http.MethodPost, "/api/account/login/start", strings.NewReader(`{"email":"x@y.zz","website":""}`)))
 	if rec.Code != http.StatusOK {
 		t.Fatalf("code=%d body=%s", rec.Code, rec.Body.String())
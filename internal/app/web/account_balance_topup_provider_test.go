@@ -0,0 +1,97 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/config"
+)
+
+func postAccountTopup(t *testing.T, cfg *config.Config, target, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	serveAccountBalanceTopup(cfg, &stubAccountWeb{}).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, target, strings.NewReader(body)))
+	return rec
+}
+
+func TestServeAccountBalanceTopup_ProviderFromRegistry(t *testing.T) {
+	cfg := orderStartTestCfg()
+	cfg.API.BaseURL = "https://bill.fix.test"
+	cfg.Brand.PaymentProviders = []config.PaymentProvider{
+		{ID: "yookassa"},
+		{ID: "paymaster", PaySystem: "paymaster_vff", MinAmount: 100, MaxAmount: 2000},
+	}
+	tok, err := CreateAccountToken(cfg.WebSales.OrderTokenSecret, "vff", "a@b.c", 701, "web_xx", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := postAccountTopup(t, cfg, "/api/account/balance/topup?provider=paymaster", `{"token":"`+tok+`","amount":150}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%d %s", rec.Code, rec.Body.String())
+	}
+	var out accountBalanceTopupOKJSON
+	if err := json.NewDecoder(rec.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Provider != "paymaster" || !strings.Contains(out.PaymentURL, "/shm/pay_systems/paymaster.cgi") || !strings.Contains(out.PaymentURL, "ps=paymaster_vff") {
+		t.Fatalf("%#v", out)
+	}
+
+	rec = postAccountTopup(t, cfg, "/api/account/balance/topup", `{"token":"`+tok+`","amount":150,"provider":"paymaster"}`)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"provider":"paymaster"`) {
+		t.Fatalf("provider in body: %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = postAccountTopup(t, cfg, "/api/account/balance/topup?provider=paymaster", `{"token":"`+tok+`","amount":60}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("below provider min: %d", rec.Code)
+	}
+	assertJSONErrorField(t, rec.Body.String(), "invalid_amount")
+
+	rec = postAccountTopup(t, cfg, "/api/account/balance/topup?provider=cryptocloud", `{"token":"`+tok+`","amount":150}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("unconfigured provider: %d", rec.Code)
+	}
+	assertJSONErrorField(t, rec.Body.String(), "unknown_provider")
+
+	rec = postAccountTopup(t, cfg, "/api/account/balance/topup", `{"token":"`+tok+`","amount":150}`)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"provider":"yookassa"`) {
+		t.Fatalf("default provider: %d %s", rec.Code, rec.Body.String())
+	}
+	// По умолчанию — первый провайдер из списка, который страница показала на языке пользователя.
+	rec = postAccountTopup(t, cfg, "/api/account/balance/topup?lang=en", `{"token":"`+tok+`","amount":150}`)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"provider":"paymaster"`) {
+		t.Fatalf("default EN provider: %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRenderedAccountSession_PaymentMethodsFromRegistry(t *testing.T) {
+	cfg := orderStartTestCfg()
+	cfg.Brand.PaymentProviders = []config.PaymentProvider{
+		{ID: "paymaster", Names: map[string]string{"ru": "СБП", "en": "Fast payments"}, Descriptions: map[string]string{"ru": "Оплата по QR"}},
+		{ID: "yookassa"},
+	}
+	ru := mustRenderAccountSessionHTML(t, cfg, accountLocaleRU)
+	for _, needle := range []string{
+		`name="topup-payment-method" value="paymaster" checked`,
+		`name="topup-payment-method" value="yookassa">`,
+		`СБП`,
+		`Оплата по QR`,
+	} {
+		if !strings.Contains(ru, needle) {
+			t.Fatalf("RU session missing %q", needle)
+		}
+	}
+	if strings.Contains(ru, `value="cryptocloud"`) || strings.Contains(ru, "Оплата через Trybit") {
+		t.Fatal("unconfigured cryptocloud must not be rendered")
+	}
+	en := mustRenderAccountSessionHTML(t, cfg, accountLocaleEN)
+	if !strings.Contains(en, `value="paymaster" checked`) || !strings.Contains(en, "Fast payments") || strings.Contains(en, `value="yookassa"`) {
+		t.Fatal("EN session must list only EN-capable providers")
+	}
+}
@@ -8,7 +8,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ryabkov82/vpnbot/internal/config"
 	"github.com/ryabkov82/vpnbot/internal/models"
+	"github.com/ryabkov82/vpnbot/internal/payments"
 )
 
 func TestServeAccountServiceOrder_EN_CryptoPaymentURLUsesRUBAmount(t *testing.T) {
@@ -68,7 +70,7 @@ func TestServeAccountServiceOrder_EN_CryptoPaymentURLUsesRUBAmount(t *testing.T)
 	}
 }
 
-func TestServeAccountServiceOrder_EN_CryptoPaymentURLFailed(t *testing.T) {
+func TestServeAccountServiceOrder_EN_PaymentURLFailed(t *testing.T) {
 	cfg := orderStartTestCfg()
 	cfg.API.BaseURL = ""
 	tok, err := CreateAccountToken(cfg.WebSales.OrderTokenSecret, "vff", "en@buy.com", 9, "web_en9", time.Hour)
@@ -89,7 +91,68 @@ func TestServeAccountServiceOrder_EN_CryptoPaymentURLFailed(t *testing.T) {
 	if rec.Code != http.StatusInternalServerError {
 		t.Fatalf("want 500 got %d %s", rec.Code, rec.Body.String())
 	}
-	assertJSONErrorField(t, rec.Body.String(), "crypto_payment_url_failed")
+	assertJSONErrorField(t, rec.Body.String(), "payment_url_failed")
+}
+
+func TestServeAccountServiceOrder_EN_ProviderFromRegistry(t *testing.T) {
+	cfg := orderStartTestCfg()
+	cfg.API.BaseURL = "https://bill.fix.test"
+	cfg.Brand.PaymentProviders = []config.PaymentProvider{
+		{ID: "yookassa"},
+		{ID: "paymaster", PaySystem: "paymaster_vff", MinAmount: 100, MaxAmount: 2000},
+	}
+	tok, err := CreateAccountToken(cfg.WebSales.OrderTokenSecret, "vff", "en@buy.com", 3382, "web_en2", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	order := func(target, body string, forecast float64) (*stubAccountWeb, *httptest.ResponseRecorder) {
+		st := &stubAccountWeb{
+			svcByID:         map[int]*models.Service{3: {ServiceID: 3, AllowToOrder: 1, Cost: 60}},
+			serviceOrderRet: &models.UserService{ServiceID: 339, BaseServiceID: 3, Status: "NOT PAID"},
+			balance:         &models.UserBalance{Forecast: forecast},
+		}
+		rec := httptest.NewRecorder()
+		serveAccountServiceOrder(cfg, st).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, target, strings.NewReader(body)))
+		return st, rec
+	}
+
+	// yookassa только для ru: по умолчанию EN берёт paymaster, сумма поднимается до его минимума.
+	_, rec := order("/api/account/service/order?lang=en", `{"token":"`+tok+`","service_id":3}`, 60)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%d %s", rec.Code, rec.Body.String())
+	}
+	var out accountServiceOrderOKJSON
+	if err := json.NewDecoder(rec.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Provider != "paymaster" || out.Amount != 100 ||
+		!strings.Contains(out.PaymentURL, "ps=paymaster_vff") || !strings.Contains(out.PaymentURL, "amount=100") {
+		t.Fatalf("%#v", out)
+	}
+	if strings.Contains(strings.ToLower(out.Message), "crypto") {
+		t.Fatalf("message: %q", out.Message)
+	}
+
+	_, rec = order("/api/account/service/order?lang=en", `{"token":"`+tok+`","service_id":3}`, 2500)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("above provider max: %d %s", rec.Code, rec.Body.String())
+	}
+	assertJSONErrorField(t, rec.Body.String(), "invalid_payment_amount")
+
+	st, rec := order("/api/account/service/order?lang=en&provider=cryptocloud", `{"token":"`+tok+`","service_id":3}`, 60)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("unconfigured provider: %d %s", rec.Code, rec.Body.String())
+	}
+	assertJSONErrorField(t, rec.Body.String(), "unknown_provider")
+	if st.serviceOrderCalls != 0 {
+		t.Fatalf("order must not be created for unknown provider, calls=%d", st.serviceOrderCalls)
+	}
+
+	cfg.Brand.PaymentProviders = []config.PaymentProvider{{ID: "yookassa"}}
+	_, rec = order("/api/account/service/order?lang=en", `{"token":"`+tok+`","service_id":3}`, 60)
+	if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), `"payment_url":"http`) {
+		t.Fatalf("no EN provider: %d %s", rec.Code, rec.Body.String())
+	}
 }
 
 func TestServeAccountServiceOrder_RU_NoCryptoPaymentURL(t *testing.T) {
@@ -135,7 +198,7 @@ func TestRenderedAccountSession_EN_DefaultCryptoPaymentMethod(t *testing.T) {
 	}
 	for _, needle := range []string{
 		"Cryptocurrency",
-		"/api/account/balance/topup/cryptocloud",
+		"/api/account/balance/topup?provider=",
 		"cfg.lang === 'en'",
 		"formatTopupAmountInput",
 		"setTopupCustomAmount",
@@ -156,7 +219,7 @@ func TestRenderedAccountSession_EN_DefaultCryptoPaymentMethod(t *testing.T) {
 }
 
 func TestAccountServiceOrderMessageEN_CryptoExplainsRUB(t *testing.T) {
-	msg := accountServiceOrderMessageEN(false, false, 150, true)
+	msg := accountServiceOrderMessageEN(false, false, 150, payments.ProviderCryptoCloud)
 	if !strings.Contains(msg, "150 RUB") || !strings.Contains(strings.ToLower(msg), "crypto") {
 		t.Fatalf("msg: %q", msg)
 	}
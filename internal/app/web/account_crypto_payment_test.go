@@ -52,7 +52,7 @@ func TestRenderedAccountSessionPageIncludesCryptoPaymentMethod(t *testing.T) {
 		`Оплата через Trybit: USDT, TON и другие доступные валюты`,
 		`При частичной оплате доступ может не активироваться автоматически. Если платеж не зачислился, обратитесь в поддержку.`,
 		`function selectedTopupBalanceURL()`,
-		`/api/account/balance/topup/cryptocloud`,
+		`/api/account/balance/topup?provider=`,
 		`var topupEndpoint = selectedTopupBalanceURL()`,
 		`non_json_response`,
 		`topup non-json response`,
@@ -70,7 +70,7 @@ func TestRenderedAccountSessionPageIncludesCryptoPaymentMethod(t *testing.T) {
 	if strings.Contains(en, "Bank card") || strings.Contains(en, "Card payment via the current payment gateway") {
 		t.Fatal("EN session must not show bank card payment method")
 	}
-	if !strings.Contains(en, "Cryptocurrency") || !strings.Contains(en, "/api/account/balance/topup/cryptocloud") {
+	if !strings.Contains(en, "Cryptocurrency") || !strings.Contains(en, "/api/account/balance/topup?provider=") {
 		t.Fatal("EN session must show crypto-only top-up flow")
 	}
 	if strings.Contains(en, `value="yookassa"`) {
@@ -0,0 +1,258 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/ryabkov82/vpnbot/internal/config"
+	"github.com/ryabkov82/vpnbot/internal/email"
+	"github.com/ryabkov82/vpnbot/internal/models"
+	appService "github.com/ryabkov82/vpnbot/internal/service"
+	"github.com/ryabkov82/vpnbot/internal/webuser"
+)
+
+// accountEmailChangeApp — смена и отвязка web-email поверх accountWebApp.
+type accountEmailChangeApp interface {
+	accountWebApp
+	ChangeWebEmail(userID int, currentEmail, newEmail string) (*models.User, error)
+	UnlinkWebEmail(userID int, currentEmail string) (*models.User, error)
+}
+
+type accountEmailChangeReqJSON struct {
+	Token    string `json:"token"`
+	NewEmail string `json:"new_email"`
+}
+
+type accountEmailUnlinkReqJSON struct {
+	Token string `json:"token"`
+}
+
+// serveAccountEmailChange — POST /api/account/email/change: письмо с подписанной ссылкой на новый адрес.
+// Сама смена выполняется только после перехода по ссылке (/account/email/confirm).
+func serveAccountEmailChange(cfg *config.Config, app accountEmailChangeApp, rl *leadRateLimiter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/account/email/change" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed")
+			return
+		}
+		if !webSalesTokenFlowAvailable(cfg) {
+			writeJSONError(w, http.StatusNotFound, "not_found")
+			return
+		}
+
+		const maxBody = 1 << 20
+		dec := json.NewDecoder(io.LimitReader(r.Body, maxBody))
+		var req accountEmailChangeReqJSON
+		if err := dec.Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "bad_request")
+			return
+		}
+
+		claims, user, err := authenticateWebAccount(cfg, app, strings.TrimSpace(req.Token))
+		if err != nil {
+			writeAccountAuthError(w, err)
+			return
+		}
+		curEmail, err := webuser.NormalizeEmail(claims.Email)
+		if err != nil {
+			writeJSONError(w, http.StatusUnauthorized, "invalid_token")
+			return
+		}
+		newEmail, err := webuser.NormalizeEmail(req.NewEmail)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid_email")
+			return
+		}
+		if newEmail == curEmail {
+			writeJSONError(w, http.StatusBadRequest, "email_unchanged")
+			return
+		}
+
+		ipKey := ClientIPFromRequest(r)
+		if ipKey == "" {
+			ipKey = "unknown"
+		}
+		if rl != nil && !rl.allow(ipKey, strconv.Itoa(user.ID)) {
+			writeJSONError(w, http.StatusTooManyRequests, "rate_limited")
+			return
+		}
+		if !email.IsConfigured(cfg) {
+			writeJSONError(w, http.StatusServiceUnavailable, "email_unavailable")
+			return
+		}
+
+		other, err := app.FindUserByWebEmail(newEmail)
+		if err != nil && !errors.Is(err, appService.ErrUserIdentityMismatch) {
+			slog.Error("account email change", "stage", "find_user_by_web_login", "user_id", user.ID, "err", err)
+			writeJSONError(w, http.StatusInternalServerError, "internal_error")
+			return
+		}
+		if err != nil || (other != nil && other.ID != user.ID) {
+			writeJSONError(w, http.StatusConflict, accountErrorEmailAlreadyLinked)
+			return
+		}
+
+		secret := strings.TrimSpace(cfg.WebSales.OrderTokenSecret)
+		tok, err := CreateAccountEmailChangeToken(secret, cfgBrandID(cfg), user.ID, curEmail, newEmail, cfg)
+		if err != nil {
+			slog.Error("account email change", "stage", "create_token", "user_id", user.ID, "err", err)
+			writeJSONError(w, http.StatusInternalServerError, "internal_error")
+			return
+		}
+		base := strings.TrimRight(strings.TrimSpace(publicOrderBaseURL(cfg, r)), "/")
+		if base == "" {
+			writeJSONError(w, http.StatusInternalServerError, "internal_error")
+			return
+		}
+		confirmURL := base + "/account/email/confirm?token=" + url.QueryEscape(tok)
+		if err := email.SendAccountEmailChangeConfirmEmail(cfg, newEmail, confirmURL); err != nil {
+			if errors.Is(err, email.ErrNotConfigured) {
+				writeJSONError(w, http.StatusServiceUnavailable, "email_unavailable")
+				return
+			}
+			slog.Error("account email change", "stage", "send_confirm_email", "err", err)
+			writeJSONError(w, http.StatusInternalServerError, "email_send_failed")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "email_sent"})
+	}
+}
+
+// serveAccountEmailChangeConfirm — GET /account/email/confirm?token=: применяет смену email,
+// уведомляет прежний адрес и выдаёт новую сессию (старые токены кабинета перестают проходить валидацию).
+func serveAccountEmailChangeConfirm(cfg *config.Config, app accountEmailChangeApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/account/email/confirm" && r.URL.Path != "/account/email/confirm/" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !webSalesTokenFlowAvailable(cfg) {
+			http.NotFound(w, r)
+			return
+		}
+
+		const title = "Смена email"
+		notice := func(status int, text string) {
+			body, err := standaloneLinkNoticePage(cfg, title, text)
+			if err != nil {
+				slog.Error("account email confirm", "stage", "render_page", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Set("Cache-Control", "no-store")
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.WriteHeader(status)
+			_, _ = w.Write(body)
+		}
+
+		secret := strings.TrimSpace(cfg.WebSales.OrderTokenSecret)
+		claims, err := VerifyAccountEmailChangeToken(secret, cfgBrandID(cfg), strings.TrimSpace(r.URL.Query().Get("token")))
+		if err != nil {
+			if errors.Is(err, ErrAccountTokenExpired) {
+				notice(http.StatusOK, "Срок действия ссылки истёк. Запросите смену email в личном кабинете ещё раз.")
+				return
+			}
+			notice(http.StatusOK, "Ссылка недействительна. Запросите смену email в личном кабинете ещё раз.")
+			return
+		}
+
+		u, err := app.ChangeWebEmail(claims.ShmUserID, claims.OldEmail, claims.NewEmail)
+		switch {
+		case err == nil:
+		case errors.Is(err, appService.ErrWebEmailUsedByOtherAccount), errors.Is(err, appService.ErrUserIdentityMismatch):
+			slog.Warn("account email confirm: new email taken", "user_id", claims.ShmUserID)
+			notice(http.StatusOK, "Этот email уже используется другим аккаунтом. Укажите другой адрес или напишите в поддержку.")
+			return
+		case errors.Is(err, appService.ErrWebEmailNotLinked), errors.Is(err, appService.ErrWebEmailUnchanged):
+			notice(http.StatusOK, "Email уже изменён или ссылка устарела. Войдите в личный кабинет заново.")
+			return
+		default:
+			slog.Error("account email confirm", "stage", "change_web_email", "user_id", claims.ShmUserID, "err", err)
+			notice(http.StatusOK, "Не удалось сменить email. Попробуйте позже или напишите в поддержку.")
+			return
+		}
+
+		if err := email.SendAccountEmailChangedNotice(cfg, claims.OldEmail, claims.NewEmail); err != nil {
+			slog.Warn("account email confirm: notify old address", "user_id", u.ID, "err", err)
+		}
+
+		acTok, err := CreateAccountToken(secret, cfgBrandID(cfg), claims.NewEmail, u.ID, u.Login, accountTokenTTL(cfg))
+		if err != nil {
+			slog.Error("account email confirm", "stage", "create_session_token", "user_id", u.ID, "err", err)
+			http.Redirect(w, r, "/account", http.StatusFound)
+			return
+		}
+		http.Redirect(w, r, "/account/session?token="+url.QueryEscape(acTok), http.StatusFound)
+	}
+}
+
+// serveAccountEmailUnlink — POST /api/account/email/unlink: отвязка email от Telegram-пользователя.
+func serveAccountEmailUnlink(cfg *config.Config, app accountEmailChangeApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/account/email/unlink" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed")
+			return
+		}
+		if !webSalesTokenFlowAvailable(cfg) {
+			writeJSONError(w, http.StatusNotFound, "not_found")
+			return
+		}
+
+		const maxBody = 1 << 20
+		dec := json.NewDecoder(io.LimitReader(r.Body, maxBody))
+		var req accountEmailUnlinkReqJSON
+		if err := dec.Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "bad_request")
+			return
+		}
+
+		claims, user, err := authenticateWebAccount(cfg, app, strings.TrimSpace(req.Token))
+		if err != nil {
+			writeAccountAuthError(w, err)
+			return
+		}
+		if !isWebLinkedTelegramUser(cfg, user) {
+			writeJSONError(w, http.StatusConflict, "email_unlink_primary")
+			return
+		}
+
+		if _, err := app.UnlinkWebEmail(user.ID, claims.Email); err != nil {
+			switch {
+			case errors.Is(err, appService.ErrWebEmailUnlinkPrimary):
+				writeJSONError(w, http.StatusConflict, "email_unlink_primary")
+			case errors.Is(err, appService.ErrWebEmailNotLinked), errors.Is(err, appService.ErrUserIdentityMismatch):
+				writeJSONError(w, http.StatusUnauthorized, "invalid_token")
+			default:
+				slog.Error("account email unlink: UnlinkWebEmail", "user_id", user.ID, "err", err)
+				writeJSONError(w, http.StatusInternalServerError, "internal_error")
+			}
+			return
+		}
+		if err := email.SendAccountEmailChangedNotice(cfg, claims.Email, ""); err != nil {
+			slog.Warn("account email unlink: notify address", "user_id", user.ID, "err", err)
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	}
+}
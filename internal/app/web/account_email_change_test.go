@@ -0,0 +1,206 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/models"
+	appService "github.com/ryabkov82/vpnbot/internal/service"
+	"github.com/ryabkov82/vpnbot/internal/webuser"
+)
+
+type stubAccountEmailChange struct {
+	stubAccountWeb
+
+	changeCalls int
+	changeArgs  [2]string
+	changeRet   *models.User
+	changeErr   error
+
+	unlinkCalls int
+	unlinkRet   *models.User
+	unlinkErr   error
+}
+
+func (s *stubAccountEmailChange) ChangeWebEmail(userID int, currentEmail, newEmail string) (*models.User, error) {
+	s.changeCalls++
+	s.changeArgs = [2]string{currentEmail, newEmail}
+	return s.changeRet, s.changeErr
+}
+
+func (s *stubAccountEmailChange) UnlinkWebEmail(userID int, currentEmail string) (*models.User, error) {
+	s.unlinkCalls++
+	return s.unlinkRet, s.unlinkErr
+}
+
+func TestAccountEmailChangeToken_RoundTrip(t *testing.T) {
+	cfg := orderStartTestCfg()
+	secret := cfg.WebSales.OrderTokenSecret
+	tok, err := CreateAccountEmailChangeToken(secret, "vff", 5, "old@x.test", "new@x.test", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := VerifyAccountEmailChangeToken(secret, "vff", tok)
+	if err != nil || c.ShmUserID != 5 || c.OldEmail != "old@x.test" || c.NewEmail != "new@x.test" {
+		t.Fatalf("claims %#v err=%v", c, err)
+	}
+	if _, err := VerifyAccountEmailChangeToken(secret, "fc", tok); !errors.Is(err, ErrAccountTokenBrand) {
+		t.Fatalf("brand: %v", err)
+	}
+	linkTok, _ := CreateAccountLinkEmailToken(secret, "vff", 5, 9, "new@x.test", cfg)
+	if _, err := VerifyAccountEmailChangeToken(secret, "vff", linkTok); !errors.Is(err, ErrAccountTokenType) {
+		t.Fatalf("type: %v", err)
+	}
+}
+
+func TestServeAccountEmailChange_SendsConfirmToNewAddress(t *testing.T) {
+	cfg := orderStartTestCfg()
+	var to []string
+	var msg []byte
+	patchSMTP(t, func(addr string, a smtp.Auth, from string, rcpt []string, m []byte) error {
+		to, msg = rcpt, m
+		return nil
+	})
+	tok, _ := CreateAccountToken(cfg.WebSales.OrderTokenSecret, "vff", "old@x.test", 5, "web_old", time.Hour)
+	app := &stubAccountEmailChange{}
+	rec := httptest.NewRecorder()
+	serveAccountEmailChange(cfg, app, newLeadRateLimiter(10, time.Minute, 10, time.Minute)).ServeHTTP(rec,
+		httptest.NewRequest(http.MethodPost, "/api/account/email/change", strings.NewReader(`{"token":"`+tok+`","new_email":"New@X.test"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%d %s", rec.Code, rec.Body.String())
+	}
+	if len(to) != 1 || to[0] != "new@x.test" {
+		t.Fatalf("rcpt %v", to)
+	}
+	s := string(msg)
+	i := strings.Index(s, "/account/email/confirm?token=")
+	if i < 0 {
+		t.Fatalf("confirm link missing: %s", s)
+	}
+	raw := strings.Fields(s[i+len("/account/email/confirm?token="):])[0]
+	confirmTok, _ := url.QueryUnescape(raw)
+	c, err := VerifyAccountEmailChangeToken(cfg.WebSales.OrderTokenSecret, "vff", confirmTok)
+	if err != nil || c.OldEmail != "old@x.test" || c.NewEmail != "new@x.test" {
+		t.Fatalf("claims %#v err=%v", c, err)
+	}
+	if app.changeCalls != 0 {
+		t.Fatal("change must wait for confirmation")
+	}
+}
+
+func TestServeAccountEmailChange_Rejects(t *testing.T) {
+	cfg := orderStartTestCfg()
+	patchSMTP(t, func(string, smtp.Auth, string, []string, []byte) error {
+		t.Fatal("must not send")
+		return nil
+	})
+	tok, _ := CreateAccountToken(cfg.WebSales.OrderTokenSecret, "vff", "old@x.test", 5, "web_old", time.Hour)
+	post := func(app *stubAccountEmailChange, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		serveAccountEmailChange(cfg, app, nil).ServeHTTP(rec,
+			httptest.NewRequest(http.MethodPost, "/api/account/email/change", strings.NewReader(body)))
+		return rec
+	}
+	rec := post(&stubAccountEmailChange{}, `{"token":"`+tok+`","new_email":"old@x.test"}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("unchanged: %d", rec.Code)
+	}
+	assertJSONErrorField(t, rec.Body.String(), "email_unchanged")
+
+	taken := &stubAccountEmailChange{stubAccountWeb: stubAccountWeb{findUserByWebEmailRet: &models.User{ID: 99}}}
+	rec = post(taken, `{"token":"`+tok+`","new_email":"taken@x.test"}`)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("taken: %d", rec.Code)
+	}
+	assertJSONErrorField(t, rec.Body.String(), accountErrorEmailAlreadyLinked)
+
+	rec = post(&stubAccountEmailChange{}, `{"token":"bad","new_email":"n@x.test"}`)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("bad token: %d", rec.Code)
+	}
+}
+
+func TestServeAccountEmailChangeConfirm_AppliesAndNotifiesOld(t *testing.T) {
+	cfg := orderStartTestCfg()
+	var to []string
+	patchSMTP(t, func(addr string, a smtp.Auth, from string, rcpt []string, m []byte) error {
+		to = rcpt
+		return nil
+	})
+	ctok, _ := CreateAccountEmailChangeToken(cfg.WebSales.OrderTokenSecret, "vff", 5, "old@x.test", "new@x.test", cfg)
+	app := &stubAccountEmailChange{changeRet: &models.User{ID: 5, Login: "web_new"}}
+	rec := httptest.NewRecorder()
+	serveAccountEmailChangeConfirm(cfg, app).ServeHTTP(rec,
+		httptest.NewRequest(http.MethodGet, "/account/email/confirm?token="+url.QueryEscape(ctok), nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("%d %s", rec.Code, rec.Body.String())
+	}
+	if app.changeArgs[0] != "old@x.test" || app.changeArgs[1] != "new@x.test" {
+		t.Fatalf("args %v", app.changeArgs)
+	}
+	if len(to) != 1 || to[0] != "old@x.test" {
+		t.Fatalf("notice rcpt %v", to)
+	}
+	loc, _ := url.Parse(rec.Header().Get("Location"))
+	c, err := ParseAndVerifyAccountToken(cfg.WebSales.OrderTokenSecret, "vff", loc.Query().Get("token"))
+	if err != nil || c.Email != "new@x.test" || c.Login != "web_new" {
+		t.Fatalf("session %#v err=%v", c, err)
+	}
+}
+
+func TestServeAccountEmailChangeConfirm_Conflict(t *testing.T) {
+	cfg := orderStartTestCfg()
+	ctok, _ := CreateAccountEmailChangeToken(cfg.WebSales.OrderTokenSecret, "vff", 5, "old@x.test", "new@x.test", cfg)
+	app := &stubAccountEmailChange{changeErr: appService.ErrWebEmailUsedByOtherAccount}
+	rec := httptest.NewRecorder()
+	serveAccountEmailChangeConfirm(cfg, app).ServeHTTP(rec,
+		httptest.NewRequest(http.MethodGet, "/account/email/confirm?token="+url.QueryEscape(ctok), nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "уже используется") {
+		t.Fatalf("%d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestServeAccountEmailUnlink(t *testing.T) {
+	cfg := orderStartTestCfg()
+	var to []string
+	patchSMTP(t, func(addr string, a smtp.Auth, from string, rcpt []string, m []byte) error {
+		to = rcpt
+		return nil
+	})
+	tok, _ := CreateAccountToken(cfg.WebSales.OrderTokenSecret, "vff", "tg@x.test", 5, "@5", time.Hour)
+	post := func(app *stubAccountEmailChange) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		serveAccountEmailUnlink(cfg, app).ServeHTTP(rec,
+			httptest.NewRequest(http.MethodPost, "/api/account/email/unlink", strings.NewReader(`{"token":"`+tok+`"}`)))
+		return rec
+	}
+
+	// Чистый web-пользователь: login2 не web_ → отвязывать нечего.
+	rec := post(&stubAccountEmailChange{})
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("primary: %d", rec.Code)
+	}
+	assertJSONErrorField(t, rec.Body.String(), "email_unlink_primary")
+
+	wl, err := webuser.WebLoginFromEmailWithPrefix("tg@x.test", "web_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	linked := &stubAccountEmailChange{stubAccountWeb: stubAccountWeb{validateWebAccountRet: &models.User{
+		ID: 5, Login: "@5", Login2: wl,
+		Settings: models.UserSettings{BrandID: "vff", Telegram: models.TelegramInfo{ChatID: 5}, Web: models.WebInfo{Email: "tg@x.test"}},
+	}}, unlinkRet: &models.User{ID: 5}}
+	rec = post(linked)
+	if rec.Code != http.StatusOK || linked.unlinkCalls != 1 {
+		t.Fatalf("%d %s", rec.Code, rec.Body.String())
+	}
+	if len(to) != 1 || to[0] != "tg@x.test" {
+		t.Fatalf("notice rcpt %v", to)
+	}
+}
@@ -5,11 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"html/template"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"strings"
 
 	"github.com/ryabkov82/vpnbot/internal/config"
+	"github.com/ryabkov82/vpnbot/internal/payments"
 )
 
 type accountLocale string
@@ -47,6 +49,9 @@ type accountI18n struct {
 	LoginEmailLinked  string
 	LoginEmailLabel   string
 	LoginSubmitBtn    string
+	LoginCodeHint     string
+	LoginCodeLabel    string
+	LoginCodeBtn      string
 	LoginGoogleOr     string
 	LoginGoogleBtn    string
 	LoginNetworkError string
@@ -68,6 +73,9 @@ type accountI18n struct {
 	TopUpResultFallback string
 	RefreshBalanceBtn   string
 	OpenPaymentBtn      string
+	// VoucherCodeLabel / VoucherRedeemBtn — форма активации подарочного кода.
+	VoucherCodeLabel string
+	VoucherRedeemBtn string
 
 	// Tabs
 	TabServices string
@@ -88,6 +96,8 @@ type accountI18n struct {
 	PaymentsHeading     string
 	PaymentsRefreshBtn  string
 	PaymentsPlaceholder string
+	// PaymentsStatementBtn — скачать выписку за выбранный месяц (PDF).
+	PaymentsStatementBtn string
 
 	// Help tab
 	HelpHeading string
@@ -112,10 +122,6 @@ type accountI18n struct {
 
 	// Payment methods (server-rendered block)
 	PaymentMethodHeading         string
-	PaymentMethodCard            string
-	PaymentMethodCardDesc        string
-	PaymentMethodCrypto          string
-	PaymentMethodCryptoDesc      string
 	PaymentMethodTrybitWarn      string
 	PaymentMethodSupportLabel    string
 	PaymentMethodSupportTelegram string
@@ -286,6 +292,9 @@ func accountI18nRU(brandName string) accountI18n {
 		LoginEmailLinked:  "Этот email уже привязан к другому аккаунту. Войдите с другим email или обратитесь в поддержку.",
 		LoginEmailLabel:   "Email",
 		LoginSubmitBtn:    "Получить ссылку для входа",
+		LoginCodeHint:     "Или введите 6-значный код из письма:",
+		LoginCodeLabel:    "Код из письма",
+		LoginCodeBtn:      "Войти по коду",
 		LoginGoogleOr:     "или",
 		LoginGoogleBtn:    "Войти с Google",
 		LoginNetworkError: "Сеть недоступна",
@@ -305,6 +314,8 @@ func accountI18nRU(brandName string) accountI18n {
 		TopUpResultFallback: "Если страница оплаты не открылась автоматически, нажмите «Открыть оплату».",
 		RefreshBalanceBtn:   "Обновить баланс",
 		OpenPaymentBtn:      "Открыть оплату",
+		VoucherCodeLabel:    "Подарочный код",
+		VoucherRedeemBtn:    "Активировать код",
 
 		TabServices: "Мои услуги",
 		TabBuy:      "Купить VPN",
@@ -317,9 +328,10 @@ func accountI18nRU(brandName string) accountI18n {
 		BuyNewServiceDesc:  "Выберите тариф ниже. Оплату можно провести по ссылке — баланс пополнится согласно выбранной сумме, неоплаченная услуга активируется при достатке средств.",
 		PostDeleteBuyHint:  "Теперь можно выбрать другой тариф.",
 
-		PaymentsHeading:     "История платежей",
-		PaymentsRefreshBtn:  "Обновить",
-		PaymentsPlaceholder: "Откройте вкладку, чтобы загрузить историю платежей.",
+		PaymentsHeading:      "История платежей",
+		PaymentsRefreshBtn:   "Обновить",
+		PaymentsPlaceholder:  "Откройте вкладку, чтобы загрузить историю платежей.",
+		PaymentsStatementBtn: "Выписка за месяц",
 
 		HelpHeading: "Как подключить VPN",
 		HelpStep1:   "Перейдите во вкладку «Купить VPN» и выберите тариф.",
@@ -341,10 +353,6 @@ func accountI18nRU(brandName string) accountI18n {
 		TopUpCurrencyNote:      "",
 
 		PaymentMethodHeading:         "Способ оплаты",
-		PaymentMethodCard:            "Банковская карта",
-		PaymentMethodCardDesc:        "Оплата картой через текущий платежный шлюз",
-		PaymentMethodCrypto:          "Криптовалюта",
-		PaymentMethodCryptoDesc:      "Оплата через Trybit: USDT, TON и другие доступные валюты",
 		PaymentMethodTrybitWarn:      "При частичной оплате доступ может не активироваться автоматически. Если платеж не зачислился, обратитесь в поддержку.",
 		PaymentMethodSupportLabel:    "Поддержка:",
 		PaymentMethodSupportTelegram: "Telegram",
@@ -375,6 +383,9 @@ func accountI18nEN(brandName string) accountI18n {
 		LoginEmailLinked:  "This email is already linked to another account. Sign in with another email or contact support.",
 		LoginEmailLabel:   "Email",
 		LoginSubmitBtn:    "Get sign-in link",
+		LoginCodeHint:     "Or enter the 6-digit code from the email:",
+		LoginCodeLabel:    "Code from email",
+		LoginCodeBtn:      "Sign in with code",
 		LoginGoogleOr:     "or",
 		LoginGoogleBtn:    "Sign in with Google",
 		LoginNetworkError: "Network is unavailable",
@@ -394,6 +405,8 @@ func accountI18nEN(brandName string) accountI18n {
 		TopUpResultFallback: "If the payment page did not open automatically, click “Open payment”.",
 		RefreshBalanceBtn:   "Refresh balance",
 		OpenPaymentBtn:      "Open payment",
+		VoucherCodeLabel:    "Gift code",
+		VoucherRedeemBtn:    "Redeem code",
 
 		TabServices: "My services",
 		TabBuy:      "Buy VPN",
@@ -407,9 +420,10 @@ func accountI18nEN(brandName string) accountI18n {
 		PostDeleteBuyHint:    "You can now choose another plan.",
 		CatalogPricingNotice: "Prices are shown in USD for convenience. Internal balance is maintained in RUB. The final crypto invoice is calculated from the internal RUB amount by the payment provider.",
 
-		PaymentsHeading:     "Payment history",
-		PaymentsRefreshBtn:  "Refresh",
-		PaymentsPlaceholder: "Open this tab to load payment history.",
+		PaymentsHeading:      "Payment history",
+		PaymentsRefreshBtn:   "Refresh",
+		PaymentsPlaceholder:  "Open this tab to load payment history.",
+		PaymentsStatementBtn: "Monthly statement",
 
 		HelpHeading: "How to connect VPN",
 		HelpStep1:   "Open the “Buy VPN” tab and choose a plan.",
@@ -431,10 +445,6 @@ func accountI18nEN(brandName string) accountI18n {
 		TopUpCurrencyNote:      "Prices are shown in USD for convenience. Your internal balance is RUB-based. The crypto invoice will show the final equivalent amount on the payment provider page.",
 
 		PaymentMethodHeading:         "Payment method",
-		PaymentMethodCard:            "Bank card",
-		PaymentMethodCardDesc:        "Card payment via the current payment gateway",
-		PaymentMethodCrypto:          "Cryptocurrency",
-		PaymentMethodCryptoDesc:      "Payment via Trybit: USDT, TON and other available currencies",
 		PaymentMethodTrybitWarn:      "If the payment is partial, access may not activate automatically. If the payment is not credited, contact support.",
 		PaymentMethodSupportLabel:    "Support:",
 		PaymentMethodSupportTelegram: "Telegram",
@@ -443,82 +453,149 @@ func accountI18nEN(brandName string) accountI18n {
 
 func (i accountI18n) jsMessages() map[string]string {
 	return map[string]string{
-		"buyBtn":                   pickJS(i, "Купить", "Buy"),
-		"buyCreating":              pickJS(i, "Создаем...", "Creating..."),
-		"buyCreatingService":       pickJS(i, "Создаем услугу…", "Creating service…"),
-		"buyAwaitPayment":          pickJS(i, "Ожидает оплаты", "Waiting for payment"),
-		"catalogLoadFail":          pickJS(i, "Не удалось загрузить тарифы", "Failed to load plans"),
-		"catalogPlanFallback":      pickJS(i, "Тариф", "Plan"),
-		"catalogMonthsSuffix":      pickJS(i, " мес.", " mo."),
-		"networkError":             pickJS(i, "Сеть недоступна", "Network is unavailable. Check your connection and try again."),
-		"networkErrorRetry":        pickJS(i, "Сеть недоступна. Проверьте подключение и попробуйте ещё раз.", "Network is unavailable. Check your connection and try again."),
-		"genericError":             pickJS(i, "Ошибка", "Error"),
-		"orderError":               pickJS(i, "Ошибка заказа", "Order failed"),
-		"connectPopupBlocked":      pickJS(i, "Не удалось открыть страницу подключения. Разрешите всплывающие окна и попробуйте ещё раз.", "Could not open the connection page. Allow pop-ups and try again."),
-		"connectLoading":           pickJS(i, "Открываем страницу подключения...", "Opening connection page..."),
-		"connectNotReady":          pickJS(i, "Подключение пока недоступно", "Connection is not available yet"),
-		"topupAmountRequired":      pickJS(i, "Укажите сумму", "Enter an amount"),
-		"topupAmountInvalid":       pickJS(i, "Сумма 50–10 000 ₽, до 2 знаков после запятой", "Amount must be 50–10,000, up to 2 decimal places"),
-		"trybitInvoiceFailed":      pickJS(i, "Не удалось создать счет Trybit. Попробуйте позже или обратитесь в поддержку.", "Could not create a crypto payment link. Please try again or contact support."),
-		"cryptoPaymentLinkFailed":  pickJS(i, "Не удалось создать ссылку на крипто-оплату. Попробуйте позже или обратитесь в поддержку.", "Could not create a crypto payment link. Please try again or contact support."),
-		"paymentInvoiceFailed":     pickJS(i, "Не удалось создать счет на оплату. Попробуйте позже или обратитесь в поддержку.", "Failed to create a payment invoice. Try again later or contact support."),
-		"paymentLinkUnavailable":   pickJS(i, "Ссылка на оплату недоступна", "Payment link is not available"),
-		"paymentsLoading":          pickJS(i, "Загружаем платежи…", "Loading payments…"),
-		"paymentsEmpty":            pickJS(i, "Оплаченных платежей пока нет.", "No paid payments yet."),
-		"paymentsLoadFailed":       pickJS(i, "Не удалось загрузить историю платежей. Попробуйте позже.", "Failed to load payment history. Try again later."),
-		"signedInAs":               pickJS(i, "Вы вошли как ", "Signed in as "),
-		"telegramPrefix":           pickJS(i, "Telegram: ", "Telegram: "),
-		"telegramIDPrefix":         pickJS(i, "Telegram: ID ", "Telegram: ID "),
-		"serviceFallback":          pickJS(i, "Услуга", "Service"),
-		"statusLabel":              pickJS(i, "Статус: ", "Status: "),
-		"untilLabel":               pickJS(i, "До: ", "Until: "),
-		"connectBtn":               pickJS(i, "Подключить", "Connect"),
-		"connectPremiumBtn":        pickJS(i, "Подключить Premium", "Connect Premium"),
-		"premiumHappHint":          pickJS(i, "Для Premium используйте приложение Happ.", "For Premium, use the Happ app."),
-		"premiumTariffHint":        pickJS(i, "Для сетей с блокировками. Подключение через Happ.", "Premium connection via Happ app."),
-		"autorenewHint":            pickJS(i, "Для автопродления заранее пополните баланс.", "Top up your balance in advance for automatic renewal."),
-		"notPaidHint1":             pickJS(i, "Пополните баланс — услуга будет активирована автоматически, когда средств будет достаточно.", "Top up your balance — the service will activate automatically when there are enough funds."),
-		"notPaidHint2":             pickJS(i, "Если хотите выбрать другой тариф, сначала отмените эту услугу.", "If you want to choose another plan, cancel this service first."),
-		"blockedHint":              pickJS(i, "Пополните баланс — услуга будет продлена автоматически, когда средств будет достаточно.", "Top up your balance — the service will renew automatically when there are enough funds."),
-		"topUpForActivation":       pickJS(i, "Пополнить для активации", "Top up for activation"),
-		"topUpForRenewal":          pickJS(i, "Пополнить для продления", "Top up for renewal"),
-		"cancelService":            pickJS(i, "Отменить услугу", "Cancel service"),
-		"cancelDeleting":           pickJS(i, "Удаляем...", "Deleting..."),
-		"deleteConfirm":            pickJS(i, "Удалить услугу «{name}»? После удаления можно будет выбрать другой тариф.", `Delete service "{name}"? After deletion, you can choose another plan.`),
-		"deleteError":              pickJS(i, "Ошибка удаления", "Failed to delete service"),
-		"deleteSuccessFallback":    pickJS(i, "Услуга удалена. Теперь можно выбрать другой тариф.", "Service deleted. You can now choose another plan."),
-		"progressCreating":         pickJS(i, "Услуга создаётся. Обычно это занимает до 1–2 минут.", "Service is being created. This usually takes 1–2 minutes."),
-		"progressDeleting":         pickJS(i, "Услуга удаляется. Обычно это занимает до 1–2 минут.", "Service is being deleted. This usually takes 1–2 minutes."),
-		"progressGeneric":          pickJS(i, "Выполняется операция с услугой. Обычно это занимает до 1–2 минут.", "Service operation in progress. This usually takes 1–2 minutes."),
-		"progressAutoRefresh":      pickJS(i, "Страница обновится автоматически.", "The page updates automatically."),
-		"goToMyServices":           pickJS(i, "Перейти к моим услугам", "Go to my services"),
-		"goToPayment":              pickJS(i, "Перейти к оплате", "Go to payment"),
-		"dupUnpaidFallback":        pickJS(i, "У вас уже есть услуга, ожидающая оплаты: {name}. Новая выбранная услуга не создана. Пополните баланс — после поступления оплаты ожидающая услуга активируется автоматически.", "You already have a service awaiting payment: {name}. The newly selected service was not created. Top up your balance — the pending service will activate automatically after payment."),
-		"neutralUnpaidFallback":    pickJS(i, "Услуга ожидает оплаты. Пополните баланс — после поступления оплаты услуга активируется автоматически.", "The service is awaiting payment. Top up your balance — the service will activate automatically after payment."),
-		"svcPayPageOpened":         pickJS(i, "Страница оплаты открыта в новой вкладке. После оплаты вернитесь в кабинет и обновите список услуг.", "The payment page opened in a new tab. After payment, return to your account and refresh the services list."),
-		"svcPayAfterPay":           pickJS(i, "После оплаты баланс будет пополнен. Если средств достаточно, услуга активируется автоматически.", "After payment, your balance will be topped up. If funds are sufficient, the service will activate automatically."),
-		"svcPayFallback":           pickJS(i, "Если страница оплаты не открылась автоматически, нажмите «Открыть оплату».", "If the payment page did not open automatically, click “Open payment”."),
-		"refreshServices":          pickJS(i, "Обновить услуги", "Refresh services"),
-		"openPayment":              pickJS(i, "Открыть оплату", "Open payment"),
-		"catalogLoading":           pickJS(i, "Загрузка тарифов…", "Loading plans…"),
-		"sessionInvalidLink":       pickJS(i, "Ссылка недействительна или устарела.", "This sign-in link is invalid or expired."),
-		"sessionInvalidLinkAction": pickJS(i, "Запросить новую ссылку для входа", "Request a new sign-in link"),
-		"paymentsPlaceholder":      pickJS(i, "Откройте вкладку, чтобы загрузить историю платежей.", "Open this tab to load payment history."),
-		"logoutRedirect":           pickJS(i, "/account?logged_out=1", "/account?logged_out=1&lang=en"),
-		"loginPagePath":            pickJS(i, "/account", "/account?lang=en"),
-		"errInvalidToken":          pickJS(i, "Недействительная сессия", "Invalid session"),
-		"errInvalidAmount":         pickJS(i, "Неверная сумма", "Invalid amount"),
-		"errPaymentURLFailed":      pickJS(i, "Не удалось создать ссылку на оплату", "Failed to create payment link"),
-		"errRateLimited":           pickJS(i, "Слишком частые запросы", "Too many requests"),
-		"errInvalidEmail":          pickJS(i, "Неверный email", "Invalid email"),
-		"errEmailUnavailable":      pickJS(i, "Отправка email недоступна", "Email delivery unavailable"),
-		"errInternal":              pickJS(i, "Внутренняя ошибка", "Internal error"),
-		"errForbidden":             pickJS(i, "Доступ запрещён", "Access denied"),
-		"errActiveCannotDelete":    pickJS(i, "Активную услугу нельзя удалить", "Active service cannot be deleted"),
-		"errDeleteFailed":          pickJS(i, "Не удалось удалить услугу", "Failed to delete service"),
-		"errServiceNotFound":       pickJS(i, "Тариф не найден", "Plan not found"),
-		"errOrderFailed":           pickJS(i, "Не удалось создать заказ", "Failed to create order"),
-		"errNonJSONResponse":       pickJS(i, "Неожиданный ответ сервера", "Unexpected server response"),
+		"buyBtn":                    pickJS(i, "Купить", "Buy"),
+		"buyCreating":               pickJS(i, "Создаем...", "Creating..."),
+		"buyCreatingService":        pickJS(i, "Создаем услугу…", "Creating service…"),
+		"buyAwaitPayment":           pickJS(i, "Ожидает оплаты", "Waiting for payment"),
+		"cardCheckoutBtn":           pickJS(i, "Оплатить картой", "Pay by card"),
+		"buyPayOrderBtn":            pickJS(i, "Оплатить и оформить", "Pay and order"),
+		"cardCheckoutOpened":        pickJS(i, "Страница оплаты открыта в новой вкладке. После оплаты услуга активируется автоматически.", "The payment page has opened in a new tab. Your plan will activate automatically after payment."),
+		"catalogLoadFail":           pickJS(i, "Не удалось загрузить тарифы", "Failed to load plans"),
+		"catalogPlanFallback":       pickJS(i, "Тариф", "Plan"),
+		"catalogMonthsSuffix":       pickJS(i, " мес.", " mo."),
+		"networkError":              pickJS(i, "Сеть недоступна", "Network is unavailable. Check your connection and try again."),
+		"networkErrorRetry":         pickJS(i, "Сеть недоступна. Проверьте подключение и попробуйте ещё раз.", "Network is unavailable. Check your connection and try again."),
+		"genericError":              pickJS(i, "Ошибка", "Error"),
+		"orderError":                pickJS(i, "Ошибка заказа", "Order failed"),
+		"connectPopupBlocked":       pickJS(i, "Не удалось открыть страницу подключения. Разрешите всплывающие окна и попробуйте ещё раз.", "Could not open the connection page. Allow pop-ups and try again."),
+		"connectLoading":            pickJS(i, "Открываем страницу подключения...", "Opening connection page..."),
+		"connectNotReady":           pickJS(i, "Подключение пока недоступно", "Connection is not available yet"),
+		"topupAmountRequired":       pickJS(i, "Укажите сумму", "Enter an amount"),
+		"topupAmountInvalid":        pickJS(i, "Сумма 50–10 000 ₽, до 2 знаков после запятой", "Amount must be 50–10,000, up to 2 decimal places"),
+		"trybitInvoiceFailed":       pickJS(i, "Не удалось создать счет Trybit. Попробуйте позже или обратитесь в поддержку.", "Could not create a crypto payment link. Please try again or contact support."),
+		"cryptoPaymentLinkFailed":   pickJS(i, "Не удалось создать ссылку на крипто-оплату. Попробуйте позже или обратитесь в поддержку.", "Could not create a crypto payment link. Please try again or contact support."),
+		"paymentInvoiceFailed":      pickJS(i, "Не удалось создать счет на оплату. Попробуйте позже или обратитесь в поддержку.", "Failed to create a payment invoice. Try again later or contact support."),
+		"paymentLinkUnavailable":    pickJS(i, "Ссылка на оплату недоступна", "Payment link is not available"),
+		"paymentsLoading":           pickJS(i, "Загружаем платежи…", "Loading payments…"),
+		"paymentsEmpty":             pickJS(i, "Оплаченных платежей пока нет.", "No paid payments yet."),
+		"paymentsReceipt":           pickJS(i, "Квитанция", "Receipt"),
+		"voucherRedeemed":           pickJS(i, "Код активирован.", "Code redeemed."),
+		"voucherMalformed":          pickJS(i, "Код введён не полностью — проверьте все 25 символов.", "The code is incomplete — check all 25 characters."),
+		"voucherInvalid":            pickJS(i, "Код не найден — проверьте опечатки.", "Code not found — check for typos."),
+		"voucherExpired":            pickJS(i, "Срок действия кода истёк.", "This code has expired."),
+		"voucherUsed":               pickJS(i, "Этот код уже активирован.", "This code has already been redeemed."),
+		"voucherServiceUnavailable": pickJS(i, "Услуга по этому коду недоступна. Обратитесь в поддержку.", "The service for this code is unavailable. Please contact support."),
+		"voucherRateLimited":        pickJS(i, "Слишком много попыток. Попробуйте позже.", "Too many attempts. Try again later."),
+		"voucherFailed":             pickJS(i, "Не удалось активировать код. Попробуйте позже.", "Could not redeem the code. Try again later."),
+		"paymentsLoadFailed":        pickJS(i, "Не удалось загрузить историю платежей. Попробуйте позже.", "Failed to load payment history. Try again later."),
+		"signedInAs":                pickJS(i, "Вы вошли как ", "Signed in as "),
+		"telegramPrefix":            pickJS(i, "Telegram: ", "Telegram: "),
+		"telegramIDPrefix":          pickJS(i, "Telegram: ID ", "Telegram: ID "),
+		"connectTelegramBtn":        pickJS(i, "Подключить Telegram", "Connect Telegram"),
+		"connectTelegramHint":       pickJS(i, "Откройте бота и нажмите «Старт» — Telegram будет привязан к этому кабинету.", "Open the bot and press Start to link Telegram to this account."),
+		"errTelegramAlreadyLinked":  pickJS(i, "Telegram уже привязан", "Telegram is already linked"),
+		"errTelegramUnavailable":    pickJS(i, "Привязка Telegram сейчас недоступна", "Telegram linking is unavailable right now"),
+		"changeEmailBtn":            pickJS(i, "Сменить email", "Change email"),
+		"changeEmailPlaceholder":    pickJS(i, "Новый email", "New email"),
+		"changeEmailSubmit":         pickJS(i, "Отправить подтверждение", "Send confirmation"),
+		"changeEmailSent":           pickJS(i, "Мы отправили ссылку на новый адрес. Email сменится после перехода по ней.", "We sent a link to the new address. The email changes after you open it."),
+		"unlinkEmailBtn":            pickJS(i, "Отвязать email", "Unlink email"),
+		"unlinkEmailConfirm":        pickJS(i, "Отвязать email от Telegram-аккаунта? Входить на сайт с ним будет нельзя.", "Unlink this email from your Telegram account? You will no longer be able to sign in with it."),
+		"errEmailUnchanged":         pickJS(i, "Это текущий email", "This is your current email"),
+		"errEmailAlreadyLinked":     pickJS(i, "Этот email уже используется другим аккаунтом", "This email is already used by another account"),
+		"errEmailUnlinkPrimary":     pickJS(i, "Этот email — основной вход в кабинет, его нельзя отвязать", "This email is your main sign-in and cannot be unlinked"),
+		"privacyExportBtn":          pickJS(i, "Скачать мои данные", "Download my data"),
+		"privacyDeleteBtn":          pickJS(i, "Удалить аккаунт", "Delete account"),
+		"privacyDeleteConfirm":      pickJS(i, "Удалить аккаунт? Мы отправим письмо для подтверждения.", "Delete your account? We will send a confirmation email."),
+		"privacyEmailSent":          pickJS(i, "Мы отправили письмо со ссылкой подтверждения на ваш email.", "We sent a confirmation link to your email."),
+		"serviceFallback":           pickJS(i, "Услуга", "Service"),
+		"statusLabel":               pickJS(i, "Статус: ", "Status: "),
+		"untilLabel":                pickJS(i, "До: ", "Until: "),
+		"connectBtn":                pickJS(i, "Подключить", "Connect"),
+		"connectPremiumBtn":         pickJS(i, "Подключить Premium", "Connect Premium"),
+		"openInAppBtn":              pickJS(i, "Открыть в приложении", "Open in app"),
+		"openInAppInstall":          pickJS(i, "установить", "install"),
+		"keysBtn":                   pickJS(i, "QR и ключи", "QR & keys"),
+		"keysSubscription":          pickJS(i, "Ссылка подписки", "Subscription link"),
+		"usageHistoryBtn":           pickJS(i, "Трафик по дням", "Daily traffic"),
+		"usageHistoryTitle":         pickJS(i, "Трафик за 30 дней", "Traffic, last 30 days"),
+		"usageHistoryUnavailable":   pickJS(i, "Статистика трафика временно недоступна.", "Traffic statistics are temporarily unavailable."),
+		"copyBtn":                   pickJS(i, "Копировать", "Copy"),
+		"copiedMsg":                 pickJS(i, "Скопировано", "Copied"),
+		"showQrBtn":                 pickJS(i, "QR", "QR"),
+		"premiumHappHint":           pickJS(i, "Для Premium используйте приложение Happ.", "For Premium, use the Happ app."),
+		"premiumTariffHint":         pickJS(i, "Для сетей с блокировками. Подключение через Happ.", "Premium connection via Happ app."),
+		"autorenewHint":             pickJS(i, "Для автопродления заранее пополните баланс.", "Top up your balance in advance for automatic renewal."),
+		"autorenewOffHint":          pickJS(i, "Автопродление выключено — по окончании срока услуга не продлится.", "Auto-renewal is off — the service will not renew when the period ends."),
+		"autorenewToggle":           pickJS(i, "Автопродление", "Auto-renewal"),
+		"autorenewFailed":           pickJS(i, "Не удалось изменить автопродление. Попробуйте позже.", "Could not change auto-renewal. Try again later."),
+		"renewalNext":               pickJS(i, "Следующее списание {date}: {amount}", "Next charge on {date}: {amount}"),
+		"renewalOnTopup":            pickJS(i, "Продление после пополнения: {amount}", "Renews after top-up: {amount}"),
+		"renewalShortfall":          pickJS(i, "На следующий период не хватает {amount} — пополните баланс.", "Your balance is {amount} short of the next period — please top up."),
+		"renewNowBtn":               pickJS(i, "Продлить сейчас", "Renew now"),
+		"renewNowConfirm":           pickJS(i, "Продлить «{name}» сейчас? С баланса будет списано {amount}.", `Renew "{name}" now? {amount} will be charged to your balance.`),
+		"renewing":                  pickJS(i, "Продлеваем...", "Renewing..."),
+		"renewedFallback":           pickJS(i, "Услуга продлена.", "The service is renewed."),
+		"errRenewalStale":           pickJS(i, "Услуга уже изменилась — обновите страницу.", "The service has changed — refresh the page."),
+		"errRenewalUnavailable":     pickJS(i, "Эту услугу сейчас нельзя продлить.", "This service cannot be renewed right now."),
+		"errInsufficientBalance":    pickJS(i, "Недостаточно средств на балансе.", "Insufficient balance."),
+		"errRenewalFailed":          pickJS(i, "Не удалось продлить услугу. Попробуйте позже.", "Could not renew the service. Try again later."),
+		"planChangeBtn":             pickJS(i, "Сменить тариф", "Change plan"),
+		"planChangeSelect":          pickJS(i, "Выберите новый тариф", "Choose a new plan"),
+		"planChangeNoPlans":         pickJS(i, "Других тарифов сейчас нет.", "No other plans are available right now."),
+		"planChangeConfirmBtn":      pickJS(i, "Сменить тариф", "Change plan"),
+		"planChanging":              pickJS(i, "Меняем тариф...", "Changing plan..."),
+		"planChangedFallback":       pickJS(i, "Тариф сменён.", "The plan is changed."),
+		"errPlanChangeUnavailable":  pickJS(i, "На этот тариф сейчас перейти нельзя.", "You cannot switch to this plan right now."),
+		"errPlanChangeStale":        pickJS(i, "Услуга уже изменилась — обновите страницу и пересчитайте.", "The service has changed — refresh the page and get a new quote."),
+		"errPlanChangeInProgress":   pickJS(i, "Смена тарифа для этой услуги уже выполняется. Обратитесь в поддержку, если это затянулось.", "A plan change for this service is already in progress. Contact support if it takes too long."),
+		"errPlanChangeRetireFailed": pickJS(i, "Новый тариф подключён, но старая услуга ещё не снята. Повторите смену — она завершится.", "The new plan is active, but the old service is not removed yet. Repeat the change to finish it."),
+		"errPlanChangeOrderFailed":  pickJS(i, "Не удалось подключить новый тариф. Зачисление за остаток отменено, текущая услуга работает.", "Could not order the new plan. The credit was reversed and your current service still works."),
+		"errPlanChangeFailed":       pickJS(i, "Не удалось сменить тариф. Попробуйте позже.", "Could not change the plan. Try again later."),
+		"notPaidHint1":              pickJS(i, "Пополните баланс — услуга будет активирована автоматически, когда средств будет достаточно.", "Top up your balance — the service will activate automatically when there are enough funds."),
+		"notPaidHint2":              pickJS(i, "Если хотите выбрать другой тариф, сначала отмените эту услугу.", "If you want to choose another plan, cancel this service first."),
+		"blockedHint":               pickJS(i, "Пополните баланс — услуга будет продлена автоматически, когда средств будет достаточно.", "Top up your balance — the service will renew automatically when there are enough funds."),
+		"topUpForActivation":        pickJS(i, "Пополнить для активации", "Top up for activation"),
+		"topUpForRenewal":           pickJS(i, "Пополнить для продления", "Top up for renewal"),
+		"cancelService":             pickJS(i, "Отменить услугу", "Cancel service"),
+		"cancelDeleting":            pickJS(i, "Удаляем...", "Deleting..."),
+		"deleteConfirm":             pickJS(i, "Удалить услугу «{name}»? После удаления можно будет выбрать другой тариф.", `Delete service "{name}"? After deletion, you can choose another plan.`),
+		"deleteError":               pickJS(i, "Ошибка удаления", "Failed to delete service"),
+		"deleteSuccessFallback":     pickJS(i, "Услуга удалена. Теперь можно выбрать другой тариф.", "Service deleted. You can now choose another plan."),
+		"progressCreating":          pickJS(i, "Услуга создаётся. Обычно это занимает до 1–2 минут.", "Service is being created. This usually takes 1–2 minutes."),
+		"progressDeleting":          pickJS(i, "Услуга удаляется. Обычно это занимает до 1–2 минут.", "Service is being deleted. This usually takes 1–2 minutes."),
+		"progressGeneric":           pickJS(i, "Выполняется операция с услугой. Обычно это занимает до 1–2 минут.", "Service operation in progress. This usually takes 1–2 minutes."),
+		"progressAutoRefresh":       pickJS(i, "Страница обновится автоматически.", "The page updates automatically."),
+		"goToMyServices":            pickJS(i, "Перейти к моим услугам", "Go to my services"),
+		"goToPayment":               pickJS(i, "Перейти к оплате", "Go to payment"),
+		"dupUnpaidFallback":         pickJS(i, "У вас уже есть услуга, ожидающая оплаты: {name}. Новая выбранная услуга не создана. Пополните баланс — после поступления оплаты ожидающая услуга активируется автоматически.", "You already have a service awaiting payment: {name}. The newly selected service was not created. Top up your balance — the pending service will activate automatically after payment."),
+		"neutralUnpaidFallback":     pickJS(i, "Услуга ожидает оплаты. Пополните баланс — после поступления оплаты услуга активируется автоматически.", "The service is awaiting payment. Top up your balance — the service will activate automatically after payment."),
+		"svcPayPageOpened":          pickJS(i, "Страница оплаты открыта в новой вкладке. После оплаты вернитесь в кабинет и обновите список услуг.", "The payment page opened in a new tab. After payment, return to your account and refresh the services list."),
+		"svcPayAfterPay":            pickJS(i, "После оплаты баланс будет пополнен. Если средств достаточно, услуга активируется автоматически.", "After payment, your balance will be topped up. If funds are sufficient, the service will activate automatically."),
+		"svcPayFallback":            pickJS(i, "Если страница оплаты не открылась автоматически, нажмите «Открыть оплату».", "If the payment page did not open automatically, click “Open payment”."),
+		"refreshServices":           pickJS(i, "Обновить услуги", "Refresh services"),
+		"openPayment":               pickJS(i, "Открыть оплату", "Open payment"),
+		"catalogLoading":            pickJS(i, "Загрузка тарифов…", "Loading plans…"),
+		"sessionInvalidLink":        pickJS(i, "Ссылка недействительна или устарела.", "This sign-in link is invalid or expired."),
+		"sessionInvalidLinkAction":  pickJS(i, "Запросить новую ссылку для входа", "Request a new sign-in link"),
+		"paymentsPlaceholder":       pickJS(i, "Откройте вкладку, чтобы загрузить историю платежей.", "Open this tab to load payment history."),
+		"logoutRedirect":            pickJS(i, "/account?logged_out=1", "/account?logged_out=1&lang=en"),
+		"loginPagePath":             pickJS(i, "/account", "/account?lang=en"),
+		"errInvalidToken":           pickJS(i, "Недействительная сессия", "Invalid session"),
+		"errInvalidAmount":          pickJS(i, "Неверная сумма", "Invalid amount"),
+		"errPaymentURLFailed":       pickJS(i, "Не удалось создать ссылку на оплату", "Failed to create payment link"),
+		"errRateLimited":            pickJS(i, "Слишком частые запросы", "Too many requests"),
+		"errInvalidEmail":           pickJS(i, "Неверный email", "Invalid email"),
+		"errInvalidCode":            pickJS(i, "Неверный или устаревший код", "Invalid or expired code"),
+		"errCodeAttempts":           pickJS(i, "Слишком много неверных попыток. Запросите новый код.", "Too many wrong attempts. Request a new code."),
+		"errEmailUnavailable":       pickJS(i, "Отправка email недоступна", "Email delivery unavailable"),
+		"errInternal":               pickJS(i, "Внутренняя ошибка", "Internal error"),
+		"errForbidden":              pickJS(i, "Доступ запрещён", "Access denied"),
+		"errActiveCannotDelete":     pickJS(i, "Активную услугу нельзя удалить", "Active service cannot be deleted"),
+		"errDeleteFailed":           pickJS(i, "Не удалось удалить услугу", "Failed to delete service"),
+		"errServiceNotFound":        pickJS(i, "Тариф не найден", "Plan not found"),
+		"errOrderFailed":            pickJS(i, "Не удалось создать заказ", "Failed to create order"),
+		"errNonJSONResponse":        pickJS(i, "Неожиданный ответ сервера", "Unexpected server response"),
 	}
 }
 
@@ -611,6 +688,8 @@ type accountSessionPageData struct {
 	I18nJSON                template.JS
 	BalanceCurrency         string
 	SiteURL                 string
+	// VouchersEnabled — показать форму активации подарочного кода под балансом.
+	VouchersEnabled bool
 }
 
 func buildAccountTopupPaymentMethodsHTML(cfg *config.Config, i accountI18n, locale accountLocale) template.HTML {
@@ -626,53 +705,54 @@ func buildAccountTopupPaymentMethodsHTML(cfg *config.Config, i accountI18n, loca
 			template.HTMLEscapeString(i.PaymentMethodSupportTelegram),
 		)
 	}
-	cryptoOnly := locale == accountLocaleEN
-	cryptoChecked := ""
-	yooChecked := " checked"
-	if cryptoOnly {
-		cryptoChecked = " checked"
-		yooChecked = ""
-	}
-	cryptoBlock := fmt.Sprintf(`<div class="col-12%s">
-									<label class="d-block h-100 rounded-3 border border-secondary p-3 bg-body">
-										<input class="form-check-input me-2" type="radio" name="topup-payment-method" value="cryptocloud"%s>
-										<span class="fw-semibold">%s</span>
-										<span class="d-block small text-secondary mt-1">%s</span>
-									</label>
-								</div>`,
-		map[bool]string{true: "", false: " col-sm-6"}[cryptoOnly],
-		cryptoChecked,
-		template.HTMLEscapeString(i.PaymentMethodCrypto),
-		template.HTMLEscapeString(i.PaymentMethodCryptoDesc),
-	)
-	methodCols := cryptoBlock
-	if !cryptoOnly {
-		yooBlock := fmt.Sprintf(`<div class="col-12 col-sm-6">
+	// Способы оплаты — из реестра провайдеров бренда; первый доступный для локали выбран по умолчанию.
+	var providers []payments.Provider
+	if reg, err := payments.RegistryFromConfig(cfg); err != nil {
+		slog.Error("account session: payment registry", "err", err)
+	} else {
+		providers = reg.Available(cfg.BrandID(), string(locale))
+	}
+	colClass := "col-12"
+	if len(providers) > 1 {
+		colClass = "col-12 col-sm-6"
+	}
+	var methodCols strings.Builder
+	warn := ""
+	for idx, p := range providers {
+		checked := ""
+		if idx == 0 {
+			checked = " checked"
+		}
+		if p.ID() == payments.ProviderCryptoCloud {
+			warn = fmt.Sprintf(`<div class="alert alert-warning py-2 small mt-3 mb-2">%s</div>`, template.HTMLEscapeString(i.PaymentMethodTrybitWarn))
+		}
+		fmt.Fprintf(&methodCols, `<div class="%s">
 									<label class="d-block h-100 rounded-3 border border-secondary p-3 bg-body">
-										<input class="form-check-input me-2" type="radio" name="topup-payment-method" value="yookassa"%s>
+										<input class="form-check-input me-2" type="radio" name="topup-payment-method" value="%s"%s>
 										<span class="fw-semibold">%s</span>
 										<span class="d-block small text-secondary mt-1">%s</span>
 									</label>
 								</div>`,
-			yooChecked,
-			template.HTMLEscapeString(i.PaymentMethodCard),
-			template.HTMLEscapeString(i.PaymentMethodCardDesc),
+			colClass,
+			template.HTMLEscapeString(p.ID()),
+			checked,
+			template.HTMLEscapeString(p.DisplayName(string(locale))),
+			template.HTMLEscapeString(p.Description(string(locale))),
 		)
-		methodCols = yooBlock + cryptoBlock
 	}
 	block := fmt.Sprintf(`<div class="mb-3" id="topup-payment-methods" role="radiogroup" aria-label="%s">
 							<div class="small text-secondary mb-2">%s</div>
 							<div class="row g-2">
 								%s
 							</div>
-							<div class="alert alert-warning py-2 small mt-3 mb-2">%s</div>
+							%s
 							%s
 							%s
 						</div>`,
 		template.HTMLEscapeString(i.PaymentMethodHeading),
 		template.HTMLEscapeString(i.PaymentMethodHeading),
-		methodCols,
-		template.HTMLEscapeString(i.PaymentMethodTrybitWarn),
+		methodCols.String(),
+		warn,
 		supportHTML,
 		note,
 	)
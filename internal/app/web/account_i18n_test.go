@@ -338,7 +338,7 @@ func TestRenderedAccountSession_EN(t *testing.T) {
 		">Payments</button>",
 		">Help</button>",
 		"Cryptocurrency",
-		"/api/account/balance/topup/cryptocloud",
+		"/api/account/balance/topup?provider=",
 		"Top up internal balance",
 		"150 (≈ $2)",
 		"300 (≈ $4)",
@@ -523,7 +523,7 @@ func TestServeAccountLoginStart_LangENMagicLink(t *testing.T) {
 	cfg := orderStartTestCfg()
 	cfg.Brand.PublicBaseURL = "https://shop.example"
 	rl := newLeadRateLimiter(50, time.Hour, 50, time.Hour)
-	h := serveAccountLoginStart(cfg, &stubAccountWeb{}, rl)
+	h := serveAccountLoginStartWithCodes(cfg, &stubAccountWeb{}, rl, nil)
 	body := `{"email":"u@test.com","website":"","lang":"en"}`
 	req := httptest.NewRequest(http.MethodPost, "/api/account/login/start", strings.NewReader(body))
 	rec := httptest.NewRecorder()
@@ -546,7 +546,7 @@ func TestServeAccountLoginStart_UnknownLangFallbackRU(t *testing.T) {
 	cfg := orderStartTestCfg()
 	cfg.Brand.PublicBaseURL = "https://shop.example"
 	rl := newLeadRateLimiter(50, time.Hour, 50, time.Hour)
-	h := serveAccountLoginStart(cfg, &stubAccountWeb{}, rl)
+	h := serveAccountLoginStartWithCodes(cfg, &stubAccountWeb{}, rl, nil)
 	body := `{"email":"u@test.com","website":"","lang":"fr"}`
 	req := httptest.NewRequest(http.MethodPost, "/api/account/login/start", strings.NewReader(body))
 	rec := httptest.NewRecorder()
@@ -0,0 +1,249 @@
+package web
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/config"
+	"github.com/ryabkov82/vpnbot/internal/webuser"
+)
+
+const (
+	accountLoginCodeDigits      = 6
+	accountLoginCodeMaxAttempts = 5
+	accountLoginCodeDefaultTTL  = 10 * time.Minute
+)
+
+var (
+	// ErrAccountLoginCodeInvalid — код не найден, истёк, уже использован или не совпал.
+	ErrAccountLoginCodeInvalid = errors.New("invalid account login code")
+	// ErrAccountLoginCodeExhausted — исчерпан лимит попыток; код аннулирован.
+	ErrAccountLoginCodeExhausted = errors.New("account login code attempts exhausted")
+)
+
+// accountLoginCodeEntry — выданный код: хранится только HMAC кода и magic-токен, который код «открывает».
+type accountLoginCodeEntry struct {
+	codeMAC  []byte
+	token    string
+	exp      time.Time
+	attempts int
+}
+
+// accountLoginCodeStore — in-memory одноразовые коды входа (ключ: бренд + email).
+// Новый код для того же email заменяет предыдущий; успешная проверка удаляет запись (защита от повтора).
+type accountLoginCodeStore struct {
+	mu          sync.Mutex
+	entries     map[string]*accountLoginCodeEntry
+	maxAttempts int
+	nowFunc     func() time.Time
+}
+
+func newAccountLoginCodeStore() *accountLoginCodeStore {
+	return &accountLoginCodeStore{
+		entries:     map[string]*accountLoginCodeEntry{},
+		maxAttempts: accountLoginCodeMaxAttempts,
+		nowFunc:     time.Now,
+	}
+}
+
+func (s *accountLoginCodeStore) now() time.Time {
+	if s.nowFunc != nil {
+		return s.nowFunc()
+	}
+	return time.Now()
+}
+
+func accountLoginCodeKey(brandID, normEmail string) string {
+	return strings.TrimSpace(brandID) + "|" + strings.ToLower(strings.TrimSpace(normEmail))
+}
+
+func accountLoginCodeMAC(secret, brandID, normEmail, code string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write([]byte("account_login_code|" + accountLoginCodeKey(brandID, normEmail) + "|" + code))
+	return mac.Sum(nil)
+}
+
+// generateAccountLoginCode — криптографически случайный код из accountLoginCodeDigits цифр.
+func generateAccountLoginCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", accountLoginCodeDigits, n.Int64()), nil
+}
+
+// issue выдаёт новый код для brand+email, привязанный к уже подписанному magic-токену.
+func (s *accountLoginCodeStore) issue(secret, brandID, normEmail, token string, ttl time.Duration) (string, error) {
+	if strings.TrimSpace(secret) == "" {
+		return "", ErrAccountTokenEmptySecret
+	}
+	if strings.TrimSpace(brandID) == "" {
+		return "", ErrAccountTokenBrand
+	}
+	if ttl <= 0 {
+		ttl = accountLoginCodeDefaultTTL
+	}
+	code, err := generateAccountLoginCode()
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.pruneLocked(now)
+	s.entries[accountLoginCodeKey(brandID, normEmail)] = &accountLoginCodeEntry{
+		codeMAC: accountLoginCodeMAC(secret, brandID, normEmail, code),
+		token:   token,
+		exp:     now.Add(ttl),
+	}
+	return code, nil
+}
+
+// drop аннулирует выданный код (например, если письмо не ушло).
+func (s *accountLoginCodeStore) drop(brandID, normEmail string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, accountLoginCodeKey(brandID, normEmail))
+}
+
+// verify проверяет код (constant-time) и при успехе одноразово возвращает magic-токен.
+// Каждая неудачная попытка учитывается; после maxAttempts запись удаляется.
+func (s *accountLoginCodeStore) verify(secret, brandID, normEmail, code string) (string, error) {
+	code = strings.TrimSpace(code)
+	if strings.TrimSpace(secret) == "" || strings.TrimSpace(brandID) == "" || !accountLoginCodeWellFormed(code) {
+		return "", ErrAccountLoginCodeInvalid
+	}
+	key := accountLoginCodeKey(brandID, normEmail)
+	got := accountLoginCodeMAC(secret, brandID, normEmail, code)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.entries[key]
+	if e == nil {
+		return "", ErrAccountLoginCodeInvalid
+	}
+	if !s.now().Before(e.exp) {
+		delete(s.entries, key)
+		return "", ErrAccountLoginCodeInvalid
+	}
+	if !hmac.Equal(got, e.codeMAC) {
+		e.attempts++
+		if e.attempts >= s.maxAttempts {
+			delete(s.entries, key)
+			return "", ErrAccountLoginCodeExhausted
+		}
+		return "", ErrAccountLoginCodeInvalid
+	}
+	delete(s.entries, key)
+	return e.token, nil
+}
+
+func (s *accountLoginCodeStore) pruneLocked(now time.Time) {
+	for k, e := range s.entries {
+		if !now.Before(e.exp) {
+			delete(s.entries, k)
+		}
+	}
+}
+
+func accountLoginCodeWellFormed(code string) bool {
+	if len(code) != accountLoginCodeDigits {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// accountLoginCodeTTL — TTL кода из письма; не дольше TTL самого magic-токена.
+func accountLoginCodeTTL(cfg *config.Config) time.Duration {
+	ttl := accountLoginCodeDefaultTTL
+	if cfg != nil && cfg.WebSales.LoginCodeTTLMinutes > 0 {
+		ttl = time.Duration(cfg.WebSales.LoginCodeTTLMinutes) * time.Minute
+	}
+	if tokTTL := accountTokenTTL(cfg); tokTTL > 0 && tokTTL < ttl {
+		ttl = tokTTL
+	}
+	return ttl
+}
+
+type accountLoginVerifyReqJSON struct {
+	Email string `json:"email"`
+	Code  string `json:"code"`
+}
+
+type accountLoginVerifyOKJSON struct {
+	Status string `json:"status"`
+	Token  string `json:"token"`
+}
+
+// serveAccountLoginVerify — POST /api/account/login/verify: email + код из письма → тот же токен, что в magic link
+// (дальше обычный POST /api/account/session/start).
+func serveAccountLoginVerify(cfg *config.Config, rl *leadRateLimiter, codes *accountLoginCodeStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/account/login/verify" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed")
+			return
+		}
+		if !webSalesTokenFlowAvailable(cfg) || codes == nil {
+			writeJSONError(w, http.StatusNotFound, "not_found")
+			return
+		}
+
+		const maxBody = 1 << 20
+		dec := json.NewDecoder(io.LimitReader(r.Body, maxBody))
+		var req accountLoginVerifyReqJSON
+		if err := dec.Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "bad_request")
+			return
+		}
+
+		normEmail, err := webuser.NormalizeEmail(req.Email)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid_email")
+			return
+		}
+
+		ipKey := ClientIPFromRequest(r)
+		if ipKey == "" {
+			ipKey = "unknown"
+		}
+		if !rl.allow(ipKey, strings.ToLower(normEmail)) {
+			writeJSONError(w, http.StatusTooManyRequests, "rate_limited")
+			return
+		}
+
+		secret := strings.TrimSpace(cfg.WebSales.OrderTokenSecret)
+		tok, err := codes.verify(secret, cfgBrandID(cfg), normEmail, req.Code)
+		if err != nil {
+			if errors.Is(err, ErrAccountLoginCodeExhausted) {
+				slog.Warn("account login verify: attempts exhausted")
+				writeJSONError(w, http.StatusBadRequest, "code_attempts_exhausted")
+				return
+			}
+			writeJSONError(w, http.StatusBadRequest, "invalid_code")
+			return
+		}
+
+		writeJSON(w, http.StatusOK, accountLoginVerifyOKJSON{Status: "ok", Token: tok})
+	}
+}
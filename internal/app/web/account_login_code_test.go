@@ -0,0 +1,193 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/models"
+)
+
+const loginCodeTestSecret = "order-token-secret-order-token-sec"
+
+func TestAccountLoginCodeStore_SingleUse(t *testing.T) {
+	s := newAccountLoginCodeStore()
+	code, err := s.issue(loginCodeTestSecret, "vff", "a@b.c", "tok-1", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !accountLoginCodeWellFormed(code) {
+		t.Fatalf("code=%q", code)
+	}
+	tok, err := s.verify(loginCodeTestSecret, "vff", "a@b.c", code)
+	if err != nil || tok != "tok-1" {
+		t.Fatalf("tok=%q err=%v", tok, err)
+	}
+	if _, err := s.verify(loginCodeTestSecret, "vff", "a@b.c", code); !errors.Is(err, ErrAccountLoginCodeInvalid) {
+		t.Fatalf("replay must fail, err=%v", err)
+	}
+}
+
+func TestAccountLoginCodeStore_Expired(t *testing.T) {
+	now := time.Date(2026, 8, 1, 12, 0, 0, 0, time.UTC)
+	s := newAccountLoginCodeStore()
+	s.nowFunc = func() time.Time { return now }
+	code, err := s.issue(loginCodeTestSecret, "vff", "a@b.c", "tok", 10*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(10 * time.Minute)
+	if _, err := s.verify(loginCodeTestSecret, "vff", "a@b.c", code); !errors.Is(err, ErrAccountLoginCodeInvalid) {
+		t.Fatalf("expired code must fail, err=%v", err)
+	}
+}
+
+func TestAccountLoginCodeStore_AttemptsExhausted(t *testing.T) {
+	s := newAccountLoginCodeStore()
+	code, err := s.issue(loginCodeTestSecret, "vff", "a@b.c", "tok", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrong := "000000"
+	if wrong == code {
+		wrong = "111111"
+	}
+	for i := 1; i < accountLoginCodeMaxAttempts; i++ {
+		if _, err := s.verify(loginCodeTestSecret, "vff", "a@b.c", wrong); !errors.Is(err, ErrAccountLoginCodeInvalid) {
+			t.Fatalf("attempt %d: err=%v", i, err)
+		}
+	}
+	if _, err := s.verify(loginCodeTestSecret, "vff", "a@b.c", wrong); !errors.Is(err, ErrAccountLoginCodeExhausted) {
+		t.Fatalf("last attempt: err=%v", err)
+	}
+	if _, err := s.verify(loginCodeTestSecret, "vff", "a@b.c", code); !errors.Is(err, ErrAccountLoginCodeInvalid) {
+		t.Fatalf("correct code after exhaustion must fail, err=%v", err)
+	}
+}
+
+func TestAccountLoginCodeStore_BrandBound(t *testing.T) {
+	s := newAccountLoginCodeStore()
+	code, err := s.issue(loginCodeTestSecret, "vff", "a@b.c", "tok", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.verify(loginCodeTestSecret, "fc", "a@b.c", code); err == nil {
+		t.Fatal("code must not verify under another brand")
+	}
+	if tok, err := s.verify(loginCodeTestSecret, "vff", "a@b.c", code); err != nil || tok != "tok" {
+		t.Fatalf("tok=%q err=%v", tok, err)
+	}
+}
+
+func TestAccountLoginCodeStore_ReissueReplacesPrevious(t *testing.T) {
+	s := newAccountLoginCodeStore()
+	first, err := s.issue(loginCodeTestSecret, "vff", "a@b.c", "tok-1", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.issue(loginCodeTestSecret, "vff", "a@b.c", "tok-2", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		if _, err := s.verify(loginCodeTestSecret, "vff", "a@b.c", first); err == nil {
+			t.Fatal("previous code must be replaced")
+		}
+	}
+	if tok, err := s.verify(loginCodeTestSecret, "vff", "a@b.c", second); err != nil || tok != "tok-2" {
+		t.Fatalf("tok=%q err=%v", tok, err)
+	}
+}
+
+func TestAccountLoginCodeTTL(t *testing.T) {
+	cfg := orderStartTestCfg()
+	if got := accountLoginCodeTTL(cfg); got != accountLoginCodeDefaultTTL {
+		t.Fatalf("default ttl=%v", got)
+	}
+	cfg.WebSales.LoginCodeTTLMinutes = 5
+	if got := accountLoginCodeTTL(cfg); got != 5*time.Minute {
+		t.Fatalf("ttl=%v", got)
+	}
+}
+
+var loginCodeInMailRe = regexp.MustCompile(`код на странице входа: (\d{6})`)
+
+func TestServeAccountLoginVerify_CodeFromMailOpensSameToken(t *testing.T) {
+	var gotMail []byte
+	patchSMTP(t, func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		gotMail = append([]byte(nil), msg...)
+		return nil
+	})
+	cfg := orderStartTestCfg()
+	codes := newAccountLoginCodeStore()
+	st := &stubAccountWeb{findUserByWebEmailRet: &models.User{ID: 77, Login: "web_x"}}
+	start := serveAccountLoginStartWithCodes(cfg, st, newLeadRateLimiter(50, time.Hour, 50, time.Hour), codes)
+	rec := httptest.NewRecorder()
+	start.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/account/login/start", strings.NewReader(`{"email":"a@b.c"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("start code=%d body=%s", rec.Code, rec.Body.String())
+	}
+	linkTok := extractMagicLinkTokenFromSMTPMsg(t, gotMail)
+	m := loginCodeInMailRe.FindSubmatch(gotMail)
+	if m == nil {
+		t.Fatalf("code missing in mail: %s", gotMail)
+	}
+
+	verify := serveAccountLoginVerify(cfg, newLeadRateLimiter(50, time.Hour, 50, time.Hour), codes)
+	rec = httptest.NewRecorder()
+	verify.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/account/login/verify",
+		strings.NewReader(`{"email":"A@b.c","code":"`+string(m[1])+`"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("verify code=%d body=%s", rec.Code, rec.Body.String())
+	}
+	var out accountLoginVerifyOKJSON
+	if err := json.NewDecoder(rec.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Status != "ok" || out.Token != linkTok {
+		t.Fatalf("out=%+v want link token", out)
+	}
+
+	rec = httptest.NewRecorder()
+	verify.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/account/login/verify",
+		strings.NewReader(`{"email":"a@b.c","code":"`+string(m[1])+`"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("replay code=%d", rec.Code)
+	}
+	assertJSONErrorField(t, rec.Body.String(), "invalid_code")
+}
+
+func TestServeAccountLoginVerify_RateLimited(t *testing.T) {
+	cfg := orderStartTestCfg()
+	h := serveAccountLoginVerify(cfg, newLeadRateLimiter(1, time.Hour, 50, time.Hour), newAccountLoginCodeStore())
+	for i, want := range []int{http.StatusBadRequest, http.StatusTooManyRequests} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/account/login/verify",
+			strings.NewReader(`{"email":"a@b.c","code":"123456"}`)))
+		if rec.Code != want {
+			t.Fatalf("req %d: code=%d want %d", i, rec.Code, want)
+		}
+	}
+}
+
+func TestServeAccountLoginVerify_MethodAndFlowGuards(t *testing.T) {
+	cfg := orderStartTestCfg()
+	h := serveAccountLoginVerify(cfg, newLeadRateLimiter(50, time.Hour, 50, time.Hour), newAccountLoginCodeStore())
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/account/login/verify", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("code=%d", rec.Code)
+	}
+	cfg.WebSales.OrderTokenSecret = ""
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/account/login/verify", strings.NewReader(`{}`)))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("code=%d", rec.Code)
+	}
+}
@@ -44,23 +44,6 @@ func accountSessionPageTemplate() (*template.Template, error) {
 	return accountSessionPageTmpl, accountSessionPageTmplErr
 }
 
-const accountTopupPaymentEndpointJSStub = `		function selectedTopupBalanceURL() {
-			return '/api/account/balance/topup';
-		}`
-
-const accountTopupPaymentEndpointJS = `		function selectedTopupBalanceURL() {
-			var cfg = window.VFF_ACCOUNT || {};
-			if (cfg.lang === 'en') {
-				return '/api/account/balance/topup/cryptocloud';
-			}
-			var picked = document.querySelector('input[name="topup-payment-method"]:checked');
-			var method = picked ? String(picked.value || '').trim() : '';
-			if (method === 'cryptocloud') {
-				return '/api/account/balance/topup/cryptocloud';
-			}
-			return '/api/account/balance/topup';
-		}`
-
 func renderedAccountLoginPageHTML(cfg *config.Config, locale accountLocale) ([]byte, error) {
 	brandName, landingURL, err := accountBrandIdentity(cfg)
 	if err != nil {
@@ -126,18 +109,13 @@ func renderedAccountSessionPageHTML(cfg *config.Config, locale accountLocale, r
 		I18nJSON:                marshalAccountI18nJS(i18n),
 		BalanceCurrency:         accountCurrencyDisplay(locale),
 		SiteURL:                 landingURL,
+		VouchersEnabled:         cfg.Vouchers.Enabled(),
 	}
 	var buf bytes.Buffer
 	if err := tmpl.Execute(&buf, data); err != nil {
 		return nil, err
 	}
-	body := withAccountTopupPaymentMethods(buf.Bytes())
-	return body, nil
-}
-
-func withAccountTopupPaymentMethods(body []byte) []byte {
-	body = bytes.ReplaceAll(body, []byte(accountTopupPaymentEndpointJSStub), []byte(accountTopupPaymentEndpointJS))
-	return body
+	return buf.Bytes(), nil
 }
 
 //go:embed static/account/link_invalid.html
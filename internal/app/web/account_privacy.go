@@ -0,0 +1,335 @@
+package web
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"html"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/config"
+	"github.com/ryabkov82/vpnbot/internal/email"
+	appService "github.com/ryabkov82/vpnbot/internal/service"
+	"github.com/ryabkov82/vpnbot/internal/webuser"
+)
+
+// accountPrivacyApp — выгрузка и удаление данных поверх accountWebApp.
+type accountPrivacyApp interface {
+	accountWebApp
+	ExportUserData(userID int) (*appService.UserDataExport, error)
+	DeleteUserAccount(userID int, email string) (*appService.AccountDeletionReport, error)
+}
+
+type accountPrivacyReqJSON struct {
+	Token        string `json:"token"`
+	ConfirmToken string `json:"confirm_token"`
+}
+
+type accountDeleteOKJSON struct {
+	Status      string `json:"status"`
+	FailedSteps int    `json:"failed_steps"`
+}
+
+// sendAccountPrivacyConfirm — шаг 1 privacy request: письмо со ссылкой подтверждения на email кабинета.
+func sendAccountPrivacyConfirm(w http.ResponseWriter, r *http.Request, cfg *config.Config, app accountPrivacyApp, rl *leadRateLimiter, action, rawToken string) {
+	claims, user, err := authenticateWebAccount(cfg, app, strings.TrimSpace(rawToken))
+	if err != nil {
+		writeAccountAuthError(w, err)
+		return
+	}
+	normEmail, err := webuser.NormalizeEmail(claims.Email)
+	if err != nil {
+		writeJSONError(w, http.StatusUnauthorized, "invalid_token")
+		return
+	}
+	ipKey := ClientIPFromRequest(r)
+	if ipKey == "" {
+		ipKey = "unknown"
+	}
+	if rl != nil && !rl.allow(ipKey, action+":"+strconv.Itoa(user.ID)) {
+		writeJSONError(w, http.StatusTooManyRequests, "rate_limited")
+		return
+	}
+	if !email.IsConfigured(cfg) {
+		writeJSONError(w, http.StatusServiceUnavailable, "email_unavailable")
+		return
+	}
+
+	tok, err := CreateAccountPrivacyToken(strings.TrimSpace(cfg.WebSales.OrderTokenSecret), cfgBrandID(cfg), action, user.ID, normEmail, claims.Login, cfg)
+	if err != nil {
+		slog.Error("account privacy", "stage", "create_token", "action", action, "user_id", user.ID, "err", err)
+		writeJSONError(w, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	base := strings.TrimRight(strings.TrimSpace(publicOrderBaseURL(cfg, r)), "/")
+	if base == "" {
+		writeJSONError(w, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	confirmURL := base + "/api/account/export?format=zip&confirm_token=" + url.QueryEscape(tok)
+	if action == AccountPrivacyActionDelete {
+		confirmURL = base + "/account/delete/confirm?token=" + url.QueryEscape(tok)
+	}
+	if err := email.SendAccountPrivacyConfirmEmail(cfg, normEmail, confirmURL, action == AccountPrivacyActionDelete); err != nil {
+		if errors.Is(err, email.ErrNotConfigured) {
+			writeJSONError(w, http.StatusServiceUnavailable, "email_unavailable")
+			return
+		}
+		slog.Error("account privacy", "stage", "send_confirm_email", "action", action, "err", err)
+		writeJSONError(w, http.StatusInternalServerError, "email_send_failed")
+		return
+	}
+	slog.Info("account privacy request", "action", action, "user_id", user.ID)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "email_sent"})
+}
+
+// verifyAccountPrivacyConfirm проверяет ссылку из письма и что email всё ещё привязан к тому же пользователю.
+func verifyAccountPrivacyConfirm(cfg *config.Config, app accountPrivacyApp, action, raw string) (*AccountPrivacyClaims, error) {
+	claims, err := VerifyAccountPrivacyToken(strings.TrimSpace(cfg.WebSales.OrderTokenSecret), cfgBrandID(cfg), action, strings.TrimSpace(raw))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := app.ValidateWebAccountUser(claims.ShmUserID, claims.Login, claims.Email); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// serveAccountExport — POST /api/account/export {token}: письмо со ссылкой; GET ?confirm_token=&format=json|zip: выгрузка.
+func serveAccountExport(cfg *config.Config, app accountPrivacyApp, rl *leadRateLimiter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/account/export" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost && r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET, POST")
+			writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed")
+			return
+		}
+		if !webSalesTokenFlowAvailable(cfg) {
+			writeJSONError(w, http.StatusNotFound, "not_found")
+			return
+		}
+
+		if r.Method == http.MethodPost {
+			const maxBody = 1 << 20
+			dec := json.NewDecoder(io.LimitReader(r.Body, maxBody))
+			var req accountPrivacyReqJSON
+			if err := dec.Decode(&req); err != nil {
+				writeJSONError(w, http.StatusBadRequest, "bad_request")
+				return
+			}
+			sendAccountPrivacyConfirm(w, r, cfg, app, rl, AccountPrivacyActionExport, req.Token)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		claims, err := verifyAccountPrivacyConfirm(cfg, app, AccountPrivacyActionExport, r.URL.Query().Get("confirm_token"))
+		if err != nil {
+			writeAccountAuthError(w, err)
+			return
+		}
+		exp, err := app.ExportUserData(claims.ShmUserID)
+		if err != nil {
+			slog.Error("account export: ExportUserData", "user_id", claims.ShmUserID, "err", err)
+			writeJSONError(w, http.StatusInternalServerError, "export_failed")
+			return
+		}
+
+		stamp := time.Now().UTC().Format("20060102")
+		name := "account-" + strconv.Itoa(claims.ShmUserID) + "-" + stamp
+		if !strings.EqualFold(strings.TrimSpace(r.URL.Query().Get("format")), "zip") {
+			w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.json"`)
+			writeJSON(w, http.StatusOK, exp)
+			return
+		}
+		body, err := accountExportZIP(exp)
+		if err != nil {
+			slog.Error("account export: zip", "user_id", claims.ShmUserID, "err", err)
+			writeJSONError(w, http.StatusInternalServerError, "export_failed")
+			return
+		}
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.zip"`)
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(body)
+	}
+}
+
+// accountExportZIP раскладывает выгрузку по файлам: account.json целиком плюс отдельные разделы.
+func accountExportZIP(exp *appService.UserDataExport) ([]byte, error) {
+	files := []struct {
+		name string
+		v    interface{}
+	}{
+		{"account.json", exp},
+		{"profile.json", struct {
+			Profile     appService.UserDataExportProfile    `json:"profile"`
+			Identities  appService.UserDataExportIdentities `json:"identities"`
+			Attribution interface{}                         `json:"attribution"`
+		}{exp.Profile, exp.Identities, exp.Attribution}},
+		{"settings.json", exp.Settings},
+		{"services.json", exp.Services},
+		{"payments.json", exp.Payments},
+		{"withdrawals.json", exp.Withdrawals},
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return nil, err
+		}
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(f.v); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// performAccountDeletion — шаг 2: удаление после подтверждения письмом; уведомляет поддержку.
+func performAccountDeletion(cfg *config.Config, app accountPrivacyApp, claims *AccountPrivacyClaims) (*appService.AccountDeletionReport, error) {
+	rep, err := app.DeleteUserAccount(claims.ShmUserID, claims.Email)
+	if rep != nil {
+		failed := rep.FailedSteps
+		if err != nil && failed == 0 {
+			failed = 1
+		}
+		accountDeletionTelegramNotifier(cfg, claims.Email, claims.ShmUserID, claims.Login, failed)
+	}
+	return rep, err
+}
+
+// serveAccountDelete — POST /api/account/delete: {token} → письмо подтверждения; {confirm_token} → удаление.
+func serveAccountDelete(cfg *config.Config, app accountPrivacyApp, rl *leadRateLimiter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/account/delete" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed")
+			return
+		}
+		if !webSalesTokenFlowAvailable(cfg) {
+			writeJSONError(w, http.StatusNotFound, "not_found")
+			return
+		}
+
+		const maxBody = 1 << 20
+		dec := json.NewDecoder(io.LimitReader(r.Body, maxBody))
+		var req accountPrivacyReqJSON
+		if err := dec.Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "bad_request")
+			return
+		}
+		if strings.TrimSpace(req.ConfirmToken) == "" {
+			sendAccountPrivacyConfirm(w, r, cfg, app, rl, AccountPrivacyActionDelete, req.Token)
+			return
+		}
+
+		claims, err := verifyAccountPrivacyConfirm(cfg, app, AccountPrivacyActionDelete, req.ConfirmToken)
+		if err != nil {
+			writeAccountAuthError(w, err)
+			return
+		}
+		rep, err := performAccountDeletion(cfg, app, claims)
+		if err != nil {
+			slog.Error("account delete: DeleteUserAccount", "user_id", claims.ShmUserID, "err", err)
+			writeJSONError(w, http.StatusInternalServerError, "delete_failed")
+			return
+		}
+		writeJSON(w, http.StatusOK, accountDeleteOKJSON{Status: "deleted", FailedSteps: rep.FailedSteps})
+	}
+}
+
+// serveAccountDeleteConfirmPage — /account/delete/confirm: GET показывает кнопку подтверждения
+// (переход по ссылке сам по себе ничего не удаляет), POST выполняет удаление.
+func serveAccountDeleteConfirmPage(cfg *config.Config, app accountPrivacyApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/account/delete/confirm" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodPost {
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !webSalesTokenFlowAvailable(cfg) {
+			http.NotFound(w, r)
+			return
+		}
+
+		const title = "Удаление аккаунта"
+		render := func(body []byte, err error) {
+			if err != nil {
+				slog.Error("account delete confirm", "stage", "render_page", "err", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Set("Cache-Control", "no-store")
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write(body)
+		}
+
+		raw := r.URL.Query().Get("token")
+		if r.Method == http.MethodPost {
+			r.Body = http.MaxBytesReader(w, r.Body, 1<<16)
+			raw = r.PostFormValue("token")
+		}
+		claims, err := verifyAccountPrivacyConfirm(cfg, app, AccountPrivacyActionDelete, raw)
+		if err != nil {
+			render(standaloneLinkNoticePage(cfg, title, "Ссылка недействительна, устарела или аккаунт уже удалён. Запросите удаление в личном кабинете ещё раз."))
+			return
+		}
+
+		if r.Method == http.MethodGet {
+			render(accountDeleteConfirmFormPage(cfg, title, raw, claims.Email))
+			return
+		}
+
+		rep, err := performAccountDeletion(cfg, app, claims)
+		if err != nil {
+			slog.Error("account delete confirm: DeleteUserAccount", "user_id", claims.ShmUserID, "err", err)
+			render(standaloneLinkNoticePage(cfg, title, "Не удалось удалить аккаунт. Мы передали запрос в поддержку — с вами свяжутся."))
+			return
+		}
+		msg := "Аккаунт удалён: услуги отменены, личные данные обезличены, вход в кабинет больше недоступен."
+		if rep.FailedSteps > 0 {
+			msg = "Аккаунт обезличен, но часть услуг не удалось отменить автоматически. Поддержка завершит удаление вручную."
+		}
+		render(standaloneLinkNoticePage(cfg, title, msg))
+	}
+}
+
+func accountDeleteConfirmFormPage(cfg *config.Config, title, token, emailAddr string) ([]byte, error) {
+	page, err := standaloneLinkNoticePage(cfg, title,
+		"Аккаунт "+emailAddr+" будет удалён: услуги отменятся, личные данные будут обезличены, вход в кабинет станет невозможен. Неизрасходованный баланс автоматически не возвращается.")
+	if err != nil {
+		return nil, err
+	}
+	form := `<form method="post" action="/account/delete/confirm" class="mb-3">
+<input type="hidden" name="token" value="` + html.EscapeString(token) + `">
+<button type="submit" class="btn btn-danger">Удалить аккаунт</button>
+</form>
+`
+	return bytes.Replace(page, []byte(`<p><a href="/account">`), []byte(form+`<p><a href="/account">`), 1), nil
+}
@@ -0,0 +1,182 @@
+package web
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/config"
+	"github.com/ryabkov82/vpnbot/internal/models"
+	appService "github.com/ryabkov82/vpnbot/internal/service"
+)
+
+type stubAccountPrivacy struct {
+	stubAccountWeb
+
+	export      *appService.UserDataExport
+	exportCalls int
+
+	deleteRep    *appService.AccountDeletionReport
+	deleteErr    error
+	deleteCalls  int
+	deleteEmail  string
+	deleteUserID int
+}
+
+func (s *stubAccountPrivacy) ExportUserData(userID int) (*appService.UserDataExport, error) {
+	s.exportCalls++
+	return s.export, nil
+}
+
+func (s *stubAccountPrivacy) DeleteUserAccount(userID int, email string) (*appService.AccountDeletionReport, error) {
+	s.deleteCalls++
+	s.deleteUserID = userID
+	s.deleteEmail = email
+	return s.deleteRep, s.deleteErr
+}
+
+func capturePrivacyConfirmURL(t *testing.T, marker string) *string {
+	t.Helper()
+	var link string
+	patchSMTP(t, func(addr string, a smtp.Auth, from string, rcpt []string, m []byte) error {
+		s := string(m)
+		i := strings.Index(s, marker)
+		if i < 0 {
+			t.Fatalf("confirm link %q missing: %s", marker, s)
+		}
+		link = strings.Fields(s[i:])[0]
+		return nil
+	})
+	return &link
+}
+
+func TestAccountPrivacyToken_ActionBound(t *testing.T) {
+	cfg := orderStartTestCfg()
+	secret := cfg.WebSales.OrderTokenSecret
+	tok, err := CreateAccountPrivacyToken(secret, "vff", AccountPrivacyActionExport, 5, "me@x.test", "web_me", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, err := VerifyAccountPrivacyToken(secret, "vff", AccountPrivacyActionExport, tok); err != nil || c.ShmUserID != 5 {
+		t.Fatalf("claims %#v err=%v", c, err)
+	}
+	if _, err := VerifyAccountPrivacyToken(secret, "vff", AccountPrivacyActionDelete, tok); !errors.Is(err, ErrAccountTokenType) {
+		t.Fatalf("export token must not confirm delete: %v", err)
+	}
+	if _, err := CreateAccountPrivacyToken(secret, "vff", "purge", 5, "me@x.test", "web_me", cfg); err == nil {
+		t.Fatal("unknown action must fail")
+	}
+}
+
+func TestServeAccountExport_EmailThenZIP(t *testing.T) {
+	cfg := orderStartTestCfg()
+	link := capturePrivacyConfirmURL(t, "/api/account/export?")
+	tok, _ := CreateAccountToken(cfg.WebSales.OrderTokenSecret, "vff", "me@x.test", 5, "web_me", time.Hour)
+	app := &stubAccountPrivacy{export: &appService.UserDataExport{
+		Profile:  appService.UserDataExportProfile{UserID: 5, Login: "web_me"},
+		Settings: json.RawMessage(`{"brand_id":"vff"}`),
+		Payments: []models.UserPay{{ID: 1, Money: 100}},
+	}}
+	h := serveAccountExport(cfg, app, nil)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/account/export", strings.NewReader(`{"token":"`+tok+`"}`)))
+	if rec.Code != http.StatusOK || app.exportCalls != 0 {
+		t.Fatalf("%d %s calls=%d", rec.Code, rec.Body.String(), app.exportCalls)
+	}
+
+	u, err := url.Parse(*link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, u.RequestURI(), nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/zip" {
+		t.Fatalf("%d %q %s", rec.Code, rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+	zr, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := map[string]bool{}
+	for _, f := range zr.File {
+		names[f.Name] = true
+	}
+	for _, want := range []string{"account.json", "profile.json", "settings.json", "services.json", "payments.json", "withdrawals.json"} {
+		if !names[want] {
+			t.Fatalf("zip missing %s: %v", want, names)
+		}
+	}
+
+	// Токен кабинета вместо подтверждения из письма не подходит.
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/account/export?confirm_token="+url.QueryEscape(tok), nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("session token as confirm: %d", rec.Code)
+	}
+}
+
+func TestServeAccountDelete_ConfirmAndNotify(t *testing.T) {
+	cfg := orderStartTestCfg()
+	link := capturePrivacyConfirmURL(t, "/account/delete/confirm?")
+	var notified []string
+	old := accountDeletionTelegramNotifier
+	accountDeletionTelegramNotifier = func(_ *config.Config, email string, userID int, login string, failed int) {
+		notified = append(notified, email)
+	}
+	t.Cleanup(func() { accountDeletionTelegramNotifier = old })
+
+	tok, _ := CreateAccountToken(cfg.WebSales.OrderTokenSecret, "vff", "me@x.test", 5, "web_me", time.Hour)
+	app := &stubAccountPrivacy{deleteRep: &appService.AccountDeletionReport{UserID: 5}}
+	rec := httptest.NewRecorder()
+	serveAccountDelete(cfg, app, nil).ServeHTTP(rec,
+		httptest.NewRequest(http.MethodPost, "/api/account/delete", strings.NewReader(`{"token":"`+tok+`"}`)))
+	if rec.Code != http.StatusOK || app.deleteCalls != 0 {
+		t.Fatalf("%d %s", rec.Code, rec.Body.String())
+	}
+
+	u, _ := url.Parse(*link)
+	confirm := u.Query().Get("token")
+
+	// GET только показывает форму.
+	page := httptest.NewRecorder()
+	serveAccountDeleteConfirmPage(cfg, app).ServeHTTP(page, httptest.NewRequest(http.MethodGet, u.RequestURI(), nil))
+	if page.Code != http.StatusOK || !strings.Contains(page.Body.String(), `<form method="post"`) || app.deleteCalls != 0 {
+		t.Fatalf("confirm page %d calls=%d", page.Code, app.deleteCalls)
+	}
+
+	rec = httptest.NewRecorder()
+	serveAccountDelete(cfg, app, nil).ServeHTTP(rec,
+		httptest.NewRequest(http.MethodPost, "/api/account/delete", strings.NewReader(`{"confirm_token":"`+confirm+`"}`)))
+	if rec.Code != http.StatusOK || app.deleteCalls != 1 || app.deleteEmail != "me@x.test" || app.deleteUserID != 5 {
+		t.Fatalf("%d %s %#v", rec.Code, rec.Body.String(), app)
+	}
+	if len(notified) != 1 || notified[0] != "me@x.test" {
+		t.Fatalf("support notify %v", notified)
+	}
+}
+
+func TestServeAccountDeleteConfirmPage_PostDeletes(t *testing.T) {
+	cfg := orderStartTestCfg()
+	old := accountDeletionTelegramNotifier
+	accountDeletionTelegramNotifier = func(*config.Config, string, int, string, int) {}
+	t.Cleanup(func() { accountDeletionTelegramNotifier = old })
+
+	confirm, _ := CreateAccountPrivacyToken(cfg.WebSales.OrderTokenSecret, "vff", AccountPrivacyActionDelete, 5, "me@x.test", "web_me", cfg)
+	app := &stubAccountPrivacy{deleteRep: &appService.AccountDeletionReport{UserID: 5}}
+	req := httptest.NewRequest(http.MethodPost, "/account/delete/confirm", strings.NewReader(url.Values{"token": {confirm}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	serveAccountDeleteConfirmPage(cfg, app).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || app.deleteCalls != 1 || !strings.Contains(rec.Body.String(), "Аккаунт удалён") {
+		t.Fatalf("%d calls=%d %s", rec.Code, app.deleteCalls, rec.Body.String())
+	}
+}
@@ -0,0 +1,99 @@
+package web
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ryabkov82/vpnbot/internal/config"
+	"github.com/ryabkov82/vpnbot/internal/connectapp"
+	"github.com/ryabkov82/vpnbot/internal/models"
+	appService "github.com/ryabkov82/vpnbot/internal/service"
+)
+
+type accountConnectAppJSON struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	OpenURL    string `json:"open_url"`
+	InstallURL string `json:"install_url,omitempty"`
+}
+
+type accountServiceAppsOKJSON struct {
+	Status   string                  `json:"status"`
+	Platform string                  `json:"platform,omitempty"`
+	Apps     []accountConnectAppJSON `json:"apps,omitempty"`
+	Message  string                  `json:"message,omitempty"`
+}
+
+// serveAccountServiceApps — GET /api/account/service/apps: кнопки «Открыть в приложении» для услуги
+// с учётом ОС из User-Agent. Premium anti-block получает только приложения с шифрованным deep link (premiumConnectApps).
+func serveAccountServiceApps(cfg *config.Config, app accountWebApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/account/service/apps" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed")
+			return
+		}
+		if !webSalesTokenFlowAvailable(cfg) {
+			writeJSONError(w, http.StatusNotFound, "not_found")
+			return
+		}
+
+		rawTok := strings.TrimSpace(r.URL.Query().Get("token"))
+		userSvcID, err := strconv.Atoi(strings.TrimSpace(r.URL.Query().Get("user_service_id")))
+		if err != nil || userSvcID <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "bad_request")
+			return
+		}
+		claims, _, err := authenticateWebAccount(cfg, app, rawTok)
+		if err != nil {
+			writeAccountAuthError(w, err)
+			return
+		}
+		us, err := app.GetOwnedUserServiceByUserID(claims.UserID, strconv.Itoa(userSvcID))
+		if err != nil {
+			if errors.Is(err, appService.ErrUserServiceUnavailable) {
+				writeJSONError(w, http.StatusForbidden, "forbidden")
+				return
+			}
+			slog.Error("account apps: GetOwnedUserServiceByUserID", "err", err)
+			writeJSONError(w, http.StatusInternalServerError, "internal_error")
+			return
+		}
+
+		platform := connectapp.DetectPlatform(r.Header.Get("User-Agent"))
+		notReady := accountServiceAppsOKJSON{Status: "not_ready", Message: "Подключение пока недоступно"}
+		if !strings.EqualFold(strings.TrimSpace(us.Status), "ACTIVE") {
+			writeJSON(w, http.StatusOK, notReady)
+			return
+		}
+
+		out := accountServiceAppsOKJSON{Status: "ok", Platform: string(platform)}
+		apps := connectapp.ForPlatform(platform)
+		if models.IsPremiumAntiBlockUserService(us, cfg.PremiumSquadName) {
+			apps = premiumConnectApps(platform)
+		} else if !strings.HasPrefix(strings.TrimSpace(us.Category), "vpn-mz-") {
+			writeJSON(w, http.StatusOK, notReady)
+			return
+		}
+		for _, a := range apps {
+			u, err := BuildConnectAppOpenURL(cfg, r, claims.UserID, userSvcID, a.ID)
+			if err != nil {
+				slog.Warn("account apps: open url", "err", err, "app", a.ID)
+				continue
+			}
+			out.Apps = append(out.Apps, accountConnectAppJSON{ID: a.ID, Name: a.Name, OpenURL: u, InstallURL: a.InstallURL(platform)})
+		}
+		if len(out.Apps) == 0 {
+			writeJSON(w, http.StatusOK, notReady)
+			return
+		}
+		writeJSON(w, http.StatusOK, out)
+	}
+}
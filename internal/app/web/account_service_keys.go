@@ -0,0 +1,162 @@
+package web
+
+import (
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ryabkov82/vpnbot/internal/config"
+	"github.com/ryabkov82/vpnbot/internal/models"
+	"github.com/ryabkov82/vpnbot/internal/proxylink"
+	appService "github.com/ryabkov82/vpnbot/internal/service"
+)
+
+// accountServiceKeysApp — ключи Marzban услуги поверх accountWebApp.
+type accountServiceKeysApp interface {
+	accountWebApp
+	GetUserKeyMarzbanByUserID(userID int, userServiceID string) (*models.UserKeyMarzban, error)
+}
+
+// accountServiceKeyJSON — один ключ подписки: разобранные параметры, подпись и QR (PNG base64).
+// Нераспознанная ссылка отдаётся с protocol="" и label="Ключ".
+type accountServiceKeyJSON struct {
+	URI       string `json:"uri"`
+	Label     string `json:"label"`
+	Protocol  string `json:"protocol,omitempty"`
+	Server    string `json:"server,omitempty"`
+	Port      int    `json:"port,omitempty"`
+	Transport string `json:"transport,omitempty"`
+	Security  string `json:"security,omitempty"`
+	Remark    string `json:"remark,omitempty"`
+	QRPNG     string `json:"qr_png_base64,omitempty"`
+}
+
+type accountServiceKeysOKJSON struct {
+	Status          string                  `json:"status"`
+	SubscriptionURL string                  `json:"subscription_url,omitempty"`
+	Keys            []accountServiceKeyJSON `json:"keys,omitempty"`
+	// ConvertedURLs — ссылки /sub/<token> для клиентов: auto (по User-Agent), base64, clash, singbox.
+	ConvertedURLs map[string]string `json:"subscription_urls,omitempty"`
+	Message       string            `json:"message,omitempty"`
+}
+
+// accountServiceKeyFromLink разбирает URI ключа для ответа API (без QR).
+func accountServiceKeyFromLink(raw string) accountServiceKeyJSON {
+	raw = strings.TrimSpace(raw)
+	out := accountServiceKeyJSON{URI: raw, Label: "Ключ"}
+	l, err := proxylink.Parse(raw)
+	if err != nil {
+		return out
+	}
+	out.Label = l.Label()
+	out.Protocol = string(l.Protocol)
+	out.Server = l.Server
+	out.Port = l.Port
+	out.Transport = l.Transport
+	out.Security = l.Security
+	out.Remark = l.Remark
+	return out
+}
+
+// serveAccountServiceKeys — GET /api/account/service/keys: все ключи Marzban услуги с протоколом, локацией и QR.
+// Для Premium anti-block прямые ключи не выдаются (только защищённая страница Happ), как и в боте.
+func serveAccountServiceKeys(cfg *config.Config, app accountServiceKeysApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/account/service/keys" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed")
+			return
+		}
+		if !webSalesTokenFlowAvailable(cfg) {
+			writeJSONError(w, http.StatusNotFound, "not_found")
+			return
+		}
+
+		rawTok := strings.TrimSpace(r.URL.Query().Get("token"))
+		userSvcID, err := strconv.Atoi(strings.TrimSpace(r.URL.Query().Get("user_service_id")))
+		if err != nil || userSvcID <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "bad_request")
+			return
+		}
+
+		claims, _, err := authenticateWebAccount(cfg, app, rawTok)
+		if err != nil {
+			writeAccountAuthError(w, err)
+			return
+		}
+
+		us, err := app.GetOwnedUserServiceByUserID(claims.UserID, strconv.Itoa(userSvcID))
+		if err != nil {
+			if errors.Is(err, appService.ErrUserServiceUnavailable) {
+				writeJSONError(w, http.StatusForbidden, "forbidden")
+				return
+			}
+			slog.Error("account keys: GetOwnedUserServiceByUserID", "err", err)
+			writeJSONError(w, http.StatusInternalServerError, "internal_error")
+			return
+		}
+		if models.IsPremiumAntiBlockUserService(us, cfg.PremiumSquadName) {
+			writeJSONError(w, http.StatusForbidden, "premium_keys_unavailable")
+			return
+		}
+		if !strings.EqualFold(strings.TrimSpace(us.Status), "ACTIVE") || !strings.HasPrefix(strings.TrimSpace(us.Category), "vpn-mz-") {
+			writeJSON(w, http.StatusOK, accountServiceKeysOKJSON{
+				Status:  "not_ready",
+				Message: "Подключение пока недоступно",
+			})
+			return
+		}
+
+		key, err := app.GetUserKeyMarzbanByUserID(claims.UserID, strconv.Itoa(userSvcID))
+		if err != nil {
+			if errors.Is(err, appService.ErrUserServiceUnavailable) {
+				writeJSONError(w, http.StatusForbidden, "forbidden")
+				return
+			}
+			slog.Error("account keys: GetUserKeyMarzbanByUserID", "err", err, "user_id", claims.UserID, "user_service_id", userSvcID)
+			writeJSONError(w, http.StatusBadGateway, "keys_unavailable")
+			return
+		}
+
+		out := accountServiceKeysOKJSON{Status: "ok", SubscriptionURL: strings.TrimSpace(key.SubscriptionURL)}
+		// Ссылки всех форматов строятся из одной конфигурации: при ошибке converted_urls не отдаются целиком,
+		// а не частично, в зависимости от порядка обхода.
+		for _, f := range []struct{ name, format string }{
+			{"auto", ""},
+			{subscriptionFormatBase64, subscriptionFormatBase64},
+			{subscriptionFormatClash, subscriptionFormatClash},
+			{subscriptionFormatSingBox, subscriptionFormatSingBox},
+		} {
+			u, err := BuildAccountSubscriptionURL(cfg, r, claims.UserID, userSvcID, f.format)
+			if err != nil {
+				slog.Warn("account keys: subscription url", "err", err, "format", f.name)
+				out.ConvertedURLs = nil
+				break
+			}
+			if out.ConvertedURLs == nil {
+				out.ConvertedURLs = make(map[string]string, 4)
+			}
+			out.ConvertedURLs[f.name] = u
+		}
+		for _, link := range key.Links {
+			if strings.TrimSpace(link) == "" {
+				continue
+			}
+			k := accountServiceKeyFromLink(link)
+			if png, err := appService.GenerateQRCode(k.URI); err == nil {
+				k.QRPNG = base64.StdEncoding.EncodeToString(png)
+			} else {
+				slog.Warn("account keys: qr", "err", err)
+			}
+			out.Keys = append(out.Keys, k)
+		}
+		writeJSON(w, http.StatusOK, out)
+	}
+}
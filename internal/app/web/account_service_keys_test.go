@@ -0,0 +1,148 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/models"
+)
+
+type stubAccountKeys struct {
+	*stubAccountWeb
+	keyErr   error
+	keyCalls int
+}
+
+func (s *stubAccountKeys) GetUserKeyMarzbanByUserID(userID int, userServiceID string) (*models.UserKeyMarzban, error) {
+	s.keyCalls++
+	if s.keyErr != nil {
+		return nil, s.keyErr
+	}
+	us, err := s.GetOwnedUserServiceByUserID(userID, userServiceID)
+	if err != nil {
+		return nil, err
+	}
+	k := us.KeyMarzban
+	return &k, nil
+}
+
+func keysTestRequest(t *testing.T, st *stubAccountKeys, usid int) *httptest.ResponseRecorder {
+	t.Helper()
+	cfg := categoryTestCfg("vpn-mz-main")
+	cfg.PremiumSquadName = "premium-squad"
+	tok, _ := CreateAccountToken(cfg.WebSales.OrderTokenSecret, "vff", "me@test.com", 10, "web_aa", time.Hour)
+	rec := httptest.NewRecorder()
+	serveAccountServiceKeys(cfg, st).ServeHTTP(rec,
+		httptest.NewRequest(http.MethodGet, "/api/account/service/keys?token="+tok+"&user_service_id="+strconv.Itoa(usid), nil))
+	return rec
+}
+
+func TestServeAccountServiceKeys_ListsEveryLink(t *testing.T) {
+	st := &stubAccountKeys{stubAccountWeb: &stubAccountWeb{single: map[int]*models.UserService{
+		336: {
+			UserID: 10, ServiceID: 336, Status: "ACTIVE", Category: "vpn-mz-main",
+			KeyMarzban: models.UserKeyMarzban{
+				SubscriptionURL: "https://sub.example/s",
+				Links: []string{
+					"vless://id@nl.example:443?security=reality&type=tcp&pbk=k#NL",
+					"ss://YWVzLTI1Ni1nY206cHdAMS4yLjMuNDo4Mzg4#LV",
+					"unknown://x",
+				},
+			},
+		},
+	}}}
+	rec := keysTestRequest(t, st, 336)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("want 200 got %d: %s", rec.Code, rec.Body.String())
+	}
+	var out accountServiceKeysOKJSON
+	if err := json.NewDecoder(rec.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Status != "ok" || out.SubscriptionURL != "https://sub.example/s" || len(out.Keys) != 3 {
+		t.Fatalf("%+v", out)
+	}
+	if k := out.Keys[0]; k.Label != "VLESS Reality TCP" || k.Remark != "NL" || k.Server != "nl.example" || k.QRPNG == "" {
+		t.Fatalf("vless: %+v", k)
+	}
+	if k := out.Keys[1]; k.Label != "Shadowsocks" || k.Remark != "LV" || k.Protocol != "shadowsocks" {
+		t.Fatalf("ss: %+v", k)
+	}
+	if k := out.Keys[2]; k.Label != "Ключ" || k.Protocol != "" || k.URI != "unknown://x" {
+		t.Fatalf("unknown: %+v", k)
+	}
+	if len(out.ConvertedURLs) != 4 {
+		t.Fatalf("converted urls %v", out.ConvertedURLs)
+	}
+	if u := out.ConvertedURLs["clash"]; !strings.Contains(u, "/sub/") || !strings.HasSuffix(u, "?format=clash") {
+		t.Fatalf("clash url %q", u)
+	}
+	if u := out.ConvertedURLs["auto"]; strings.Contains(u, "format=") {
+		t.Fatalf("auto url %q", u)
+	}
+	// QR у каждого ключа свой.
+	if out.Keys[0].QRPNG == out.Keys[1].QRPNG {
+		t.Fatal("qr reused across links")
+	}
+}
+
+func TestServeAccountServiceKeys_ForeignServiceForbidden(t *testing.T) {
+	st := &stubAccountKeys{stubAccountWeb: &stubAccountWeb{single: map[int]*models.UserService{
+		336: {UserID: 11, ServiceID: 336, Status: "ACTIVE", Category: "vpn-mz-main",
+			KeyMarzban: models.UserKeyMarzban{Links: []string{"vless://id@h:1#X"}}},
+	}}}
+	rec := keysTestRequest(t, st, 336)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("want 403 got %d", rec.Code)
+	}
+	assertJSONErrorField(t, rec.Body.String(), "forbidden")
+	if st.keyCalls != 0 || strings.Contains(rec.Body.String(), "vless://") {
+		t.Fatalf("keys leaked: calls=%d body=%s", st.keyCalls, rec.Body.String())
+	}
+}
+
+func TestServeAccountServiceKeys_PremiumBlocked(t *testing.T) {
+	st := &stubAccountKeys{stubAccountWeb: &stubAccountWeb{single: map[int]*models.UserService{
+		337: {UserID: 10, ServiceID: 337, Status: "ACTIVE", Category: "vpn-mz-main",
+			ConfigRaw:  `{"remnawave":{"internal_squad_name":"premium-squad"}}`,
+			KeyMarzban: models.UserKeyMarzban{Links: []string{"vless://id@h:1#X"}}},
+	}}}
+	rec := keysTestRequest(t, st, 337)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("want 403 got %d: %s", rec.Code, rec.Body.String())
+	}
+	assertJSONErrorField(t, rec.Body.String(), "premium_keys_unavailable")
+	if st.keyCalls != 0 {
+		t.Fatalf("keys fetched for premium: %d", st.keyCalls)
+	}
+}
+
+func TestServeAccountServiceKeys_NotActive(t *testing.T) {
+	st := &stubAccountKeys{stubAccountWeb: &stubAccountWeb{single: map[int]*models.UserService{
+		336: {UserID: 10, ServiceID: 336, Status: "BLOCK", Category: "vpn-mz-main"},
+	}}}
+	rec := keysTestRequest(t, st, 336)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"not_ready"`) || st.keyCalls != 0 {
+		t.Fatalf("%d %s calls=%d", rec.Code, rec.Body.String(), st.keyCalls)
+	}
+}
+
+func TestServeAccountServiceKeys_UpstreamError(t *testing.T) {
+	st := &stubAccountKeys{
+		stubAccountWeb: &stubAccountWeb{single: map[int]*models.UserService{
+			336: {UserID: 10, ServiceID: 336, Status: "ACTIVE", Category: "vpn-mz-main"},
+		}},
+		keyErr: errors.New("storage down"),
+	}
+	rec := keysTestRequest(t, st, 336)
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("want 502 got %d", rec.Code)
+	}
+	assertJSONErrorField(t, rec.Body.String(), "keys_unavailable")
+}
@@ -3,6 +3,8 @@ package web
 import (
 	"fmt"
 	"math"
+
+	"github.com/ryabkov82/vpnbot/internal/payments"
 )
 
 const (
@@ -11,9 +13,9 @@ const (
 	accountServiceOrderCreatedNoPaymentMessageEN = "Service created. It will activate automatically if your balance is sufficient."
 )
 
-func accountServiceOrderMessage(locale accountLocale, existingUnpaid, noPaymentNeeded bool, amount float64, hasCryptoURL bool) string {
+func accountServiceOrderMessage(locale accountLocale, existingUnpaid, noPaymentNeeded bool, amount float64, paymentProvider string) string {
 	if locale == accountLocaleEN {
-		return accountServiceOrderMessageEN(existingUnpaid, noPaymentNeeded, amount, hasCryptoURL)
+		return accountServiceOrderMessageEN(existingUnpaid, noPaymentNeeded, amount, paymentProvider)
 	}
 	if existingUnpaid {
 		return accountServiceOrderExistingUnpaidMessage
@@ -24,18 +26,26 @@ func accountServiceOrderMessage(locale accountLocale, existingUnpaid, noPaymentN
 	return accountServiceOrderPendingMessage
 }
 
-func accountServiceOrderMessageEN(existingUnpaid, noPaymentNeeded bool, amount float64, hasCryptoURL bool) string {
+// accountServiceOrderMessageEN — paymentProvider задан, если в ответе есть ссылка на оплату у этого провайдера.
+func accountServiceOrderMessageEN(existingUnpaid, noPaymentNeeded bool, amount float64, paymentProvider string) string {
 	if existingUnpaid {
 		return accountServiceOrderExistingUnpaidMessageEN
 	}
 	if noPaymentNeeded {
 		return accountServiceOrderCreatedNoPaymentMessageEN
 	}
-	if hasCryptoURL {
+	switch paymentProvider {
+	case "":
+	case payments.ProviderCryptoCloud:
 		return fmt.Sprintf(
 			"Service is awaiting payment. Pay via crypto using the link. The invoice is calculated from %s RUB (internal balance currency).",
 			formatServiceOrderRUBAmountEN(amount),
 		)
+	default:
+		return fmt.Sprintf(
+			"Service is awaiting payment. Pay using the link. The invoice is calculated from %s RUB (internal balance currency).",
+			formatServiceOrderRUBAmountEN(amount),
+		)
 	}
 	return accountServiceOrderPendingMessageEN
 }
@@ -0,0 +1,147 @@
+package web
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ryabkov82/vpnbot/internal/config"
+	"github.com/ryabkov82/vpnbot/internal/models"
+	appService "github.com/ryabkov82/vpnbot/internal/service"
+)
+
+// Размеры QR для /api/account/service/qr (пиксели).
+const (
+	accountQRDefaultSize = 256
+	accountQRMinSize     = 128
+	accountQRMaxSize     = 1024
+)
+
+// parseAccountQRSize — size из query; пусто → 256, вне 128–1024 или не число → ошибка.
+func parseAccountQRSize(raw string) (int, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return accountQRDefaultSize, true
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < accountQRMinSize || n > accountQRMaxSize {
+		return 0, false
+	}
+	return n, true
+}
+
+// serveAccountServiceQR — GET /api/account/service/qr: QR-код subscription URL (по умолчанию) или ссылки links[link]
+// в PNG (format=png, по умолчанию) или SVG. Владение и Premium-блокировка — как в /api/account/service/connect и в боте.
+func serveAccountServiceQR(cfg *config.Config, app accountServiceKeysApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/account/service/qr" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed")
+			return
+		}
+		if !webSalesTokenFlowAvailable(cfg) {
+			writeJSONError(w, http.StatusNotFound, "not_found")
+			return
+		}
+
+		q := r.URL.Query()
+		userSvcID, err := strconv.Atoi(strings.TrimSpace(q.Get("user_service_id")))
+		if err != nil || userSvcID <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "bad_request")
+			return
+		}
+		size, ok := parseAccountQRSize(q.Get("size"))
+		if !ok {
+			writeJSONError(w, http.StatusBadRequest, "bad_size")
+			return
+		}
+		format := strings.ToLower(strings.TrimSpace(q.Get("format")))
+		if format == "" {
+			format = "png"
+		}
+		if format != "png" && format != "svg" {
+			writeJSONError(w, http.StatusBadRequest, "bad_format")
+			return
+		}
+		linkIdx := -1
+		if raw := strings.TrimSpace(q.Get("link")); raw != "" {
+			linkIdx, err = strconv.Atoi(raw)
+			if err != nil || linkIdx < 0 {
+				writeJSONError(w, http.StatusBadRequest, "bad_request")
+				return
+			}
+		}
+
+		claims, _, err := authenticateWebAccount(cfg, app, strings.TrimSpace(q.Get("token")))
+		if err != nil {
+			writeAccountAuthError(w, err)
+			return
+		}
+		us, err := app.GetOwnedUserServiceByUserID(claims.UserID, strconv.Itoa(userSvcID))
+		if err != nil {
+			if errors.Is(err, appService.ErrUserServiceUnavailable) {
+				writeJSONError(w, http.StatusForbidden, "forbidden")
+				return
+			}
+			slog.Error("account qr: GetOwnedUserServiceByUserID", "err", err)
+			writeJSONError(w, http.StatusInternalServerError, "internal_error")
+			return
+		}
+		if models.IsPremiumAntiBlockUserService(us, cfg.PremiumSquadName) {
+			writeJSONError(w, http.StatusForbidden, "premium_keys_unavailable")
+			return
+		}
+		if !strings.EqualFold(strings.TrimSpace(us.Status), "ACTIVE") || !strings.HasPrefix(strings.TrimSpace(us.Category), "vpn-mz-") {
+			writeJSONError(w, http.StatusConflict, "not_ready")
+			return
+		}
+		key, err := app.GetUserKeyMarzbanByUserID(claims.UserID, strconv.Itoa(userSvcID))
+		if err != nil {
+			slog.Error("account qr: GetUserKeyMarzbanByUserID", "err", err, "user_id", claims.UserID, "user_service_id", userSvcID)
+			writeJSONError(w, http.StatusBadGateway, "keys_unavailable")
+			return
+		}
+
+		text := strings.TrimSpace(key.SubscriptionURL)
+		if linkIdx >= 0 {
+			if linkIdx >= len(key.Links) {
+				writeJSONError(w, http.StatusNotFound, "link_not_found")
+				return
+			}
+			text = strings.TrimSpace(key.Links[linkIdx])
+		}
+		if text == "" {
+			writeJSONError(w, http.StatusConflict, "not_ready")
+			return
+		}
+
+		var (
+			body        []byte
+			contentType string
+		)
+		if format == "svg" {
+			body, err = appService.GenerateQRCodeSVG(text, size)
+			contentType = "image/svg+xml"
+		} else {
+			body, err = appService.GenerateQRCodePNG(text, size)
+			contentType = "image/png"
+		}
+		if err != nil {
+			slog.Error("account qr: generate", "err", err)
+			writeJSONError(w, http.StatusInternalServerError, "internal_error")
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(body)
+	}
+}
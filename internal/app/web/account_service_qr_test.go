@@ -0,0 +1,73 @@
+package web
+
+import (
+	"bytes"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func qrRequest(t *testing.T, st *stubAccountKeys, userID int, query string) *httptest.ResponseRecorder {
+	t.Helper()
+	cfg := categoryTestCfg("vpn-mz-main")
+	cfg.PremiumSquadName = "premium-squad"
+	tok, _ := CreateAccountToken(cfg.WebSales.OrderTokenSecret, "vff", "me@test.com", userID, "web_aa", time.Hour)
+	rec := httptest.NewRecorder()
+	serveAccountServiceQR(cfg, st).ServeHTTP(rec,
+		httptest.NewRequest(http.MethodGet, "/api/account/service/qr?token="+tok+"&"+query, nil))
+	return rec
+}
+
+func TestServeAccountServiceQR_SubscriptionPNG(t *testing.T) {
+	rec := qrRequest(t, connectTestStub(), 10, "user_service_id=336&size=300")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/png" || rec.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("%d %v", rec.Code, rec.Header())
+	}
+	img, err := png.Decode(bytes.NewReader(rec.Body.Bytes()))
+	if err != nil || img.Bounds().Dx() != 300 {
+		t.Fatalf("png: %v", err)
+	}
+}
+
+func TestServeAccountServiceQR_LinkSVG(t *testing.T) {
+	rec := qrRequest(t, connectTestStub(), 10, "user_service_id=336&link=1&format=svg")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/svg+xml" ||
+		!strings.HasPrefix(rec.Body.String(), "<svg") || !strings.Contains(rec.Body.String(), `width="256"`) {
+		t.Fatalf("%d %.100s", rec.Code, rec.Body.String())
+	}
+	if rec := qrRequest(t, connectTestStub(), 10, "user_service_id=336&link=5"); rec.Code != http.StatusNotFound {
+		t.Fatalf("missing link: %d", rec.Code)
+	}
+}
+
+func TestServeAccountServiceQR_BadParams(t *testing.T) {
+	for _, q := range []string{
+		"user_service_id=336&size=64",
+		"user_service_id=336&size=5000",
+		"user_service_id=336&format=gif",
+		"user_service_id=336&link=-1",
+		"user_service_id=0",
+	} {
+		if rec := qrRequest(t, connectTestStub(), 10, q); rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: want 400 got %d", q, rec.Code)
+		}
+	}
+}
+
+func TestServeAccountServiceQR_OwnershipAndPremium(t *testing.T) {
+	st := connectTestStub()
+	if rec := qrRequest(t, st, 11, "user_service_id=336"); rec.Code != http.StatusForbidden {
+		t.Fatalf("foreign: %d", rec.Code)
+	}
+	rec := qrRequest(t, st, 10, "user_service_id=337")
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("premium: %d", rec.Code)
+	}
+	assertJSONErrorField(t, rec.Body.String(), "premium_keys_unavailable")
+	if st.keyCalls != 0 {
+		t.Fatalf("keys fetched: %d", st.keyCalls)
+	}
+}
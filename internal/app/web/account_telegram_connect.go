@@ -0,0 +1,164 @@
+package web
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/config"
+)
+
+// TelegramConnectStartPrefix — префикс payload команды /start для обратной привязки web → Telegram.
+const TelegramConnectStartPrefix = "link_"
+
+// Telegram ограничивает start-параметр 64 символами [A-Za-z0-9_-], поэтому вместо JSON-токена
+// используется компактный бинарный формат: user_id (4 байта) | exp (4 байта) | HMAC[:16],
+// закодированный base64url без паддинга (32 символа). Brand участвует в HMAC, но не в payload.
+const (
+	telegramConnectPayloadLen = 8
+	telegramConnectMACLen     = 16
+)
+
+func telegramConnectMAC(secret, brandID string, payload []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write([]byte("account_telegram_connect|" + strings.TrimSpace(brandID) + "|"))
+	_, _ = mac.Write(payload)
+	return mac.Sum(nil)[:telegramConnectMACLen]
+}
+
+func accountTelegramConnectTTL(cfg *config.Config) time.Duration {
+	if cfg != nil && cfg.WebSales.TelegramConnectTokenTTLMinutes > 0 {
+		return time.Duration(cfg.WebSales.TelegramConnectTokenTTLMinutes) * time.Minute
+	}
+	return 15 * time.Minute
+}
+
+// CreateTelegramConnectStartParam — подписанный start-параметр для t.me/<bot>?start=... (web-пользователь userID).
+func CreateTelegramConnectStartParam(secret, brandID string, userID int, ttl time.Duration) (string, error) {
+	if strings.TrimSpace(secret) == "" {
+		return "", ErrAccountTokenEmptySecret
+	}
+	brandID, err := requireAccountTokenBrandID(brandID)
+	if err != nil {
+		return "", err
+	}
+	if userID <= 0 || int64(userID) > int64(^uint32(0)) {
+		return "", errors.New("invalid telegram connect user id")
+	}
+	buf := make([]byte, telegramConnectPayloadLen, telegramConnectPayloadLen+telegramConnectMACLen)
+	binary.BigEndian.PutUint32(buf[0:4], uint32(userID))
+	binary.BigEndian.PutUint32(buf[4:8], uint32(time.Now().Add(ttl).Unix()))
+	buf = append(buf, telegramConnectMAC(secret, brandID, buf)...)
+	return TelegramConnectStartPrefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// VerifyTelegramConnectStartParam проверяет start-параметр и возвращает SHM user_id web-пользователя.
+func VerifyTelegramConnectStartParam(secret, expectedBrandID, param string) (int, error) {
+	if strings.TrimSpace(secret) == "" {
+		return 0, ErrAccountTokenEmptySecret
+	}
+	expectedBrandID, err := requireAccountTokenBrandID(expectedBrandID)
+	if err != nil {
+		return 0, err
+	}
+	raw, ok := strings.CutPrefix(strings.TrimSpace(param), TelegramConnectStartPrefix)
+	if !ok {
+		return 0, ErrAccountTokenType
+	}
+	buf, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil || len(buf) != telegramConnectPayloadLen+telegramConnectMACLen {
+		return 0, ErrAccountTokenMalformed
+	}
+	payload, sig := buf[:telegramConnectPayloadLen], buf[telegramConnectPayloadLen:]
+	if !hmac.Equal(sig, telegramConnectMAC(secret, expectedBrandID, payload)) {
+		return 0, ErrAccountTokenSignature
+	}
+	if int64(binary.BigEndian.Uint32(payload[4:8])) <= time.Now().Unix() {
+		return 0, ErrAccountTokenExpired
+	}
+	userID := int(binary.BigEndian.Uint32(payload[0:4]))
+	if userID <= 0 {
+		return 0, ErrAccountTokenMalformed
+	}
+	return userID, nil
+}
+
+// telegramBotUsername — username бота из конфигурации без @.
+func telegramBotUsername(cfg *config.Config) string {
+	if cfg == nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.TrimSpace(cfg.Telegram.BotUsername), "@")
+}
+
+type accountTelegramConnectReqJSON struct {
+	Token string `json:"token"`
+}
+
+type accountTelegramConnectOKJSON struct {
+	Status string `json:"status"`
+	URL    string `json:"url"`
+}
+
+// serveAccountTelegramConnect — POST /api/account/telegram/connect: deep link в бота для привязки Telegram
+// к текущему web-аккаунту. Запись settings.telegram выполняет бот после /start link_<token>.
+func serveAccountTelegramConnect(cfg *config.Config, app accountWebApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/account/telegram/connect" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed")
+			return
+		}
+		if !webSalesTokenFlowAvailable(cfg) {
+			writeJSONError(w, http.StatusNotFound, "not_found")
+			return
+		}
+
+		const maxBody = 1 << 20
+		dec := json.NewDecoder(io.LimitReader(r.Body, maxBody))
+		var req accountTelegramConnectReqJSON
+		if err := dec.Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "bad_request")
+			return
+		}
+
+		claims, user, err := authenticateWebAccount(cfg, app, strings.TrimSpace(req.Token))
+		if err != nil {
+			writeAccountAuthError(w, err)
+			return
+		}
+		if user.Settings.Telegram.ChatID > 0 {
+			writeJSONError(w, http.StatusConflict, "telegram_already_linked")
+			return
+		}
+		bot := telegramBotUsername(cfg)
+		if bot == "" {
+			writeJSONError(w, http.StatusServiceUnavailable, "telegram_unavailable")
+			return
+		}
+
+		param, err := CreateTelegramConnectStartParam(strings.TrimSpace(cfg.WebSales.OrderTokenSecret), cfgBrandID(cfg), claims.UserID, accountTelegramConnectTTL(cfg))
+		if err != nil {
+			slog.Error("account telegram connect: CreateTelegramConnectStartParam", "err", err)
+			writeJSONError(w, http.StatusInternalServerError, "internal_error")
+			return
+		}
+		writeJSON(w, http.StatusOK, accountTelegramConnectOKJSON{
+			Status: "ok",
+			URL:    "https://t.me/" + url.PathEscape(bot) + "?start=" + param,
+		})
+	}
+}
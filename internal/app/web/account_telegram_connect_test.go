@@ -0,0 +1,141 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/models"
+)
+
+func TestTelegramConnectStartParam_RoundTrip(t *testing.T) {
+	secret := strings.Repeat("s", 40)
+	param, err := CreateTelegramConnectStartParam(secret, "fc", 123456, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(param, TelegramConnectStartPrefix) || len(param) > 64 {
+		t.Fatalf("param=%q len=%d", param, len(param))
+	}
+	for _, r := range param {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			t.Fatalf("char %q not allowed in Telegram start param", r)
+		}
+	}
+	uid, err := VerifyTelegramConnectStartParam(secret, "fc", param)
+	if err != nil || uid != 123456 {
+		t.Fatalf("uid=%d err=%v", uid, err)
+	}
+}
+
+func TestTelegramConnectStartParam_Rejects(t *testing.T) {
+	secret := strings.Repeat("s", 40)
+	param, err := CreateTelegramConnectStartParam(secret, "vff", 7, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyTelegramConnectStartParam(secret, "fc", param); !errors.Is(err, ErrAccountTokenSignature) {
+		t.Fatalf("other brand: %v", err)
+	}
+	if _, err := VerifyTelegramConnectStartParam(strings.Repeat("x", 40), "vff", param); !errors.Is(err, ErrAccountTokenSignature) {
+		t.Fatalf("other secret: %v", err)
+	}
+	tampered := param[:len(param)-1] + "A"
+	if tampered == param {
+		tampered = param[:len(param)-1] + "B"
+	}
+	if _, err := VerifyTelegramConnectStartParam(secret, "vff", tampered); err == nil {
+		t.Fatal("tampered param must fail")
+	}
+	expired, err := CreateTelegramConnectStartParam(secret, "vff", 7, -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyTelegramConnectStartParam(secret, "vff", expired); !errors.Is(err, ErrAccountTokenExpired) {
+		t.Fatalf("expired: %v", err)
+	}
+	if _, err := VerifyTelegramConnectStartParam(secret, "vff", "promo"); !errors.Is(err, ErrAccountTokenType) {
+		t.Fatalf("no prefix: %v", err)
+	}
+}
+
+func TestServeAccountTelegramConnect_ReturnsDeepLink(t *testing.T) {
+	cfg := orderStartTestCfg()
+	cfg.Telegram.BotUsername = "@vff_test_bot"
+	tok, err := CreateAccountToken(cfg.WebSales.OrderTokenSecret, "vff", "web@test.com", 31, "web_x", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	serveAccountTelegramConnect(cfg, &stubAccountWeb{}).ServeHTTP(rec,
+		httptest.NewRequest(http.MethodPost, "/api/account/telegram/connect", strings.NewReader(`{"token":"`+tok+`"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%d %s", rec.Code, rec.Body.String())
+	}
+	var out accountTelegramConnectOKJSON
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(out.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Host != "t.me" || u.Path != "/vff_test_bot" {
+		t.Fatalf("url=%q", out.URL)
+	}
+	uid, err := VerifyTelegramConnectStartParam(cfg.WebSales.OrderTokenSecret, "vff", u.Query().Get("start"))
+	if err != nil || uid != 31 {
+		t.Fatalf("uid=%d err=%v", uid, err)
+	}
+}
+
+func TestServeAccountTelegramConnect_AlreadyLinked(t *testing.T) {
+	cfg := orderStartTestCfg()
+	cfg.Telegram.BotUsername = "vff_test_bot"
+	tok, err := CreateAccountToken(cfg.WebSales.OrderTokenSecret, "vff", "web@test.com", 31, "web_x", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := &stubAccountWeb{validateWebAccountRet: &models.User{ID: 31, Login: "web_x", Settings: models.UserSettings{
+		BrandID:  "vff",
+		Telegram: models.TelegramInfo{ChatID: 555},
+		Web:      models.WebInfo{Email: "web@test.com"},
+	}}}
+	rec := httptest.NewRecorder()
+	serveAccountTelegramConnect(cfg, st).ServeHTTP(rec,
+		httptest.NewRequest(http.MethodPost, "/api/account/telegram/connect", strings.NewReader(`{"token":"`+tok+`"}`)))
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("%d %s", rec.Code, rec.Body.String())
+	}
+	assertJSONErrorField(t, rec.Body.String(), "telegram_already_linked")
+}
+
+func TestServeAccountTelegramConnect_NoBotUsername(t *testing.T) {
+	cfg := orderStartTestCfg()
+	tok, err := CreateAccountToken(cfg.WebSales.OrderTokenSecret, "vff", "web@test.com", 31, "web_x", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	serveAccountTelegramConnect(cfg, &stubAccountWeb{}).ServeHTTP(rec,
+		httptest.NewRequest(http.MethodPost, "/api/account/telegram/connect", strings.NewReader(`{"token":"`+tok+`"}`)))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("%d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestServeAccountTelegramConnect_InvalidToken(t *testing.T) {
+	cfg := orderStartTestCfg()
+	cfg.Telegram.BotUsername = "vff_test_bot"
+	rec := httptest.NewRecorder()
+	serveAccountTelegramConnect(cfg, &stubAccountWeb{}).ServeHTTP(rec,
+		httptest.NewRequest(http.MethodPost, "/api/account/telegram/connect", strings.NewReader(`{"token":"bad"}`)))
+	if rec.Code == http.StatusOK {
+		t.Fatalf("must reject invalid token: %s", rec.Body.String())
+	}
+}
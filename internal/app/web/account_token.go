@@ -18,6 +18,19 @@ const (
 	accountTokenTypSignup       = "account_signup"
 	accountTokenTypTelegramLink = "account_telegram_link"
 	accountTokenTypLinkEmail    = "account_link_email"
+	accountTokenTypEmailChange  = "account_email_change"
+	accountTokenTypPrivacy      = "account_privacy"
+	accountTokenTypSubscription = "account_subscription"
+)
+
+// accountSubscriptionTokenTTL — ссылка /sub/<token> живёт долго: клиенты обновляют подписку годами.
+// Доступ фактически ограничен проверкой владения услугой на каждый запрос.
+const accountSubscriptionTokenTTL = 365 * 24 * time.Hour
+
+// Действия privacy request, подтверждаемые письмом.
+const (
+	AccountPrivacyActionExport = "export"
+	AccountPrivacyActionDelete = "delete"
 )
 
 // AccountTokenClaims — magic-link личного кабинета.
@@ -61,6 +74,36 @@ type AccountLinkEmailClaims struct {
 	Exp            int64  `json:"exp"`
 }
 
+// AccountEmailChangeClaims — подтверждение смены email из письма на новый адрес.
+type AccountEmailChangeClaims struct {
+	Typ       string `json:"typ"`
+	BrandID   string `json:"brand_id"`
+	ShmUserID int    `json:"shm_user_id"`
+	OldEmail  string `json:"old_email"`
+	NewEmail  string `json:"new_email"`
+	Exp       int64  `json:"exp"`
+}
+
+// AccountPrivacyClaims — подтверждение выгрузки или удаления данных из письма на email кабинета.
+type AccountPrivacyClaims struct {
+	Typ       string `json:"typ"`
+	BrandID   string `json:"brand_id"`
+	Action    string `json:"action"`
+	ShmUserID int    `json:"shm_user_id"`
+	Email     string `json:"email"`
+	Login     string `json:"login"`
+	Exp       int64  `json:"exp"`
+}
+
+// AccountSubscriptionClaims — подписанная ссылка подписки /sub/<token> на одну услугу.
+type AccountSubscriptionClaims struct {
+	Typ           string `json:"typ"`
+	BrandID       string `json:"brand_id"`
+	ShmUserID     int    `json:"shm_user_id"`
+	UserServiceID int    `json:"user_service_id"`
+	Exp           int64  `json:"exp"`
+}
+
 var (
 	ErrAccountTokenMalformed   = errors.New("malformed account token")
 	ErrAccountTokenSignature   = errors.New("invalid account token signature")
@@ -317,6 +360,165 @@ func VerifyAccountLinkEmailToken(secret, expectedBrandID, token string) (*Accoun
 	return &claims, nil
 }
 
+// CreateAccountEmailChangeToken — ссылка подтверждения смены email (TTL как у письма привязки).
+func CreateAccountEmailChangeToken(secret, brandID string, shmUserID int, oldEmail, newEmail string, cfg *config.Config) (string, error) {
+	if strings.TrimSpace(secret) == "" {
+		return "", ErrAccountTokenEmptySecret
+	}
+	brandID, err := requireAccountTokenBrandID(brandID)
+	if err != nil {
+		return "", err
+	}
+	oldEmail = strings.TrimSpace(oldEmail)
+	newEmail = strings.TrimSpace(newEmail)
+	if shmUserID <= 0 || oldEmail == "" || newEmail == "" {
+		return "", errors.New("invalid email-change token fields")
+	}
+	payload := AccountEmailChangeClaims{
+		Typ:       accountTokenTypEmailChange,
+		BrandID:   brandID,
+		ShmUserID: shmUserID,
+		OldEmail:  oldEmail,
+		NewEmail:  newEmail,
+		Exp:       time.Now().Add(accountLinkEmailMagicTTL(cfg)).Unix(),
+	}
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	return signAndEncodeAccountPayload(secret, payloadJSON)
+}
+
+// VerifyAccountEmailChangeToken проверяет ссылку подтверждения смены email.
+func VerifyAccountEmailChangeToken(secret, expectedBrandID, token string) (*AccountEmailChangeClaims, error) {
+	payloadJSON, err := verifyAccountMagicTokenPayload(secret, token)
+	if err != nil {
+		return nil, err
+	}
+	var claims AccountEmailChangeClaims
+	if err := json.Unmarshal(payloadJSON, &claims); err != nil {
+		return nil, ErrAccountTokenMalformed
+	}
+	if claims.Typ != accountTokenTypEmailChange {
+		return nil, ErrAccountTokenType
+	}
+	if err := matchAccountTokenBrand(claims.BrandID, expectedBrandID); err != nil {
+		return nil, err
+	}
+	if claims.Exp <= time.Now().Unix() {
+		return nil, ErrAccountTokenExpired
+	}
+	if claims.ShmUserID <= 0 || strings.TrimSpace(claims.OldEmail) == "" || strings.TrimSpace(claims.NewEmail) == "" {
+		return nil, ErrAccountTokenMalformed
+	}
+	return &claims, nil
+}
+
+// CreateAccountPrivacyToken — ссылка подтверждения privacy request (TTL как у письма привязки).
+func CreateAccountPrivacyToken(secret, brandID, action string, shmUserID int, normEmail, login string, cfg *config.Config) (string, error) {
+	if strings.TrimSpace(secret) == "" {
+		return "", ErrAccountTokenEmptySecret
+	}
+	brandID, err := requireAccountTokenBrandID(brandID)
+	if err != nil {
+		return "", err
+	}
+	if (action != AccountPrivacyActionExport && action != AccountPrivacyActionDelete) ||
+		shmUserID <= 0 || strings.TrimSpace(normEmail) == "" || strings.TrimSpace(login) == "" {
+		return "", errors.New("invalid privacy token fields")
+	}
+	payload := AccountPrivacyClaims{
+		Typ:       accountTokenTypPrivacy,
+		BrandID:   brandID,
+		Action:    action,
+		ShmUserID: shmUserID,
+		Email:     strings.TrimSpace(normEmail),
+		Login:     strings.TrimSpace(login),
+		Exp:       time.Now().Add(accountLinkEmailMagicTTL(cfg)).Unix(),
+	}
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	return signAndEncodeAccountPayload(secret, payloadJSON)
+}
+
+// VerifyAccountPrivacyToken проверяет ссылку privacy request и ожидаемое действие.
+func VerifyAccountPrivacyToken(secret, expectedBrandID, action, token string) (*AccountPrivacyClaims, error) {
+	payloadJSON, err := verifyAccountMagicTokenPayload(secret, token)
+	if err != nil {
+		return nil, err
+	}
+	var claims AccountPrivacyClaims
+	if err := json.Unmarshal(payloadJSON, &claims); err != nil {
+		return nil, ErrAccountTokenMalformed
+	}
+	if claims.Typ != accountTokenTypPrivacy || claims.Action != action {
+		return nil, ErrAccountTokenType
+	}
+	if err := matchAccountTokenBrand(claims.BrandID, expectedBrandID); err != nil {
+		return nil, err
+	}
+	if claims.Exp <= time.Now().Unix() {
+		return nil, ErrAccountTokenExpired
+	}
+	if claims.ShmUserID <= 0 || strings.TrimSpace(claims.Email) == "" || strings.TrimSpace(claims.Login) == "" {
+		return nil, ErrAccountTokenMalformed
+	}
+	return &claims, nil
+}
+
+// CreateAccountSubscriptionToken — токен ссылки подписки для услуги SHM-пользователя.
+func CreateAccountSubscriptionToken(secret, brandID string, shmUserID, userServiceID int, ttl time.Duration) (string, error) {
+	if strings.TrimSpace(secret) == "" {
+		return "", ErrAccountTokenEmptySecret
+	}
+	brandID, err := requireAccountTokenBrandID(brandID)
+	if err != nil {
+		return "", err
+	}
+	if shmUserID <= 0 || userServiceID <= 0 {
+		return "", errors.New("invalid subscription token fields")
+	}
+	payload := AccountSubscriptionClaims{
+		Typ:           accountTokenTypSubscription,
+		BrandID:       brandID,
+		ShmUserID:     shmUserID,
+		UserServiceID: userServiceID,
+		Exp:           time.Now().Add(ttl).Unix(),
+	}
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	return signAndEncodeAccountPayload(secret, payloadJSON)
+}
+
+// VerifyAccountSubscriptionToken проверяет подпись, тип, бренд и срок токена подписки.
+func VerifyAccountSubscriptionToken(secret, expectedBrandID, token string) (*AccountSubscriptionClaims, error) {
+	payloadJSON, err := verifyAccountMagicTokenPayload(secret, token)
+	if err != nil {
+		return nil, err
+	}
+	var claims AccountSubscriptionClaims
+	if err := json.Unmarshal(payloadJSON, &claims); err != nil {
+		return nil, ErrAccountTokenMalformed
+	}
+	if claims.Typ != accountTokenTypSubscription {
+		return nil, ErrAccountTokenType
+	}
+	if err := matchAccountTokenBrand(claims.BrandID, expectedBrandID); err != nil {
+		return nil, err
+	}
+	if claims.Exp <= time.Now().Unix() {
+		return nil, ErrAccountTokenExpired
+	}
+	if claims.ShmUserID <= 0 || claims.UserServiceID <= 0 {
+		return nil, ErrAccountTokenMalformed
+	}
+	return &claims, nil
+}
+
 // ParseAndVerifyAccountToken проверяет подпись, бренд и срок токена кабинета.
 func ParseAndVerifyAccountToken(secret, expectedBrandID, token string) (*AccountTokenClaims, error) {
 	payloadJSON, err := verifyAccountMagicTokenPayload(secret, token)
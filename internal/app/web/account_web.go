@@ -17,6 +17,7 @@ import (
 	"github.com/ryabkov82/vpnbot/internal/email"
 	"github.com/ryabkov82/vpnbot/internal/models"
 	"github.com/ryabkov82/vpnbot/internal/payments"
+	"github.com/ryabkov82/vpnbot/internal/receipts"
 	appService "github.com/ryabkov82/vpnbot/internal/service"
 	"github.com/ryabkov82/vpnbot/internal/webuser"
 )
@@ -44,6 +45,18 @@ type accountWebApp interface {
 	GetServiceByID(serviceID int) (*models.Service, error)
 	ServiceOrderByUserID(userID int, serviceID int) (*models.UserService, error)
 	DeleteUserServiceByUserID(userID int, userServiceID string) error
+	CreatePaymentIntent(userID int, amount float64, provider, brandID string, ts int64) (*appService.PaymentIntent, error)
+	CreateOrderIntent(userID, serviceID int, serviceName string, amount float64, provider, brandID string, ts int64) (*appService.PaymentIntent, error)
+	FulfillOrderIntents(userID int) ([]appService.PaymentIntent, error)
+	PaymentReceipt(userID, payID int) (*receipts.Document, error)
+	MonthlyStatement(userID int, month time.Time) (*receipts.Document, error)
+	UserWithdrawals(userID, limit, offset int) ([]models.WithdrawItem, int, error)
+	UserLedger(userID int) (*appService.Ledger, error)
+	RedeemVoucher(req appService.VoucherRedeemRequest) (*appService.VoucherRedemption, error)
+	SetAutoRenew(userID, userServiceID int, enabled bool) (*appService.RenewalPreview, error)
+	RenewUserService(userID, userServiceID int, expectedExpire string) (*appService.RenewalPreview, error)
+	QuotePlanChange(userID, userServiceID, serviceID int) (*appService.PlanChangeQuote, error)
+	ChangePlan(req appService.PlanChangeRequest) (*appService.PlanChange, error)
 }
 
 type accountLoginStartRequestJSON struct {
@@ -63,7 +76,9 @@ type accountLoginStartOKJSON struct {
 	Status string `json:"status"`
 }
 
-func serveAccountLoginStart(cfg *config.Config, app accountWebApp, rl *leadRateLimiter) http.HandlerFunc {
+// serveAccountLoginStartWithCodes — POST /api/account/login/start: письмо со ссылкой входа; при codes != nil
+// письмо дополнительно содержит одноразовый 6-значный код для POST /api/account/login/verify.
+func serveAccountLoginStartWithCodes(cfg *config.Config, app accountWebApp, rl *leadRateLimiter, codes *accountLoginCodeStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != "/api/account/login/start" {
 			http.NotFound(w, r)
@@ -164,12 +179,25 @@ func serveAccountLoginStart(cfg *config.Config, app accountWebApp, rl *leadRateL
 			loginURL += "&lang=en"
 		}
 
-		if err := email.SendAccountLoginEmail(cfg, normEmail, loginURL); err != nil {
+		code := ""
+		if codes != nil {
+			code, err = codes.issue(secret, brandID, normEmail, magicTok, accountLoginCodeTTL(cfg))
+			if err != nil {
+				slog.Error("account login start: login code", "err", err)
+				writeJSONError(w, http.StatusInternalServerError, "internal_error")
+				return
+			}
+		}
+
+		if err := email.SendAccountLoginEmailWithCode(cfg, normEmail, loginURL, code); err != nil {
+			if codes != nil {
+				codes.drop(brandID, normEmail)
+			}
 			if errors.Is(err, email.ErrNotConfigured) {
 				writeJSONError(w, http.StatusServiceUnavailable, "email_unavailable")
 				return
 			}
-			slog.Error("account login start: SendAccountLoginEmail", "err", err)
+			slog.Error("account login start: SendAccountLoginEmailWithCode", "err", err)
 			writeJSONError(w, http.StatusInternalServerError, "email_send_failed")
 			return
 		}
@@ -359,6 +387,7 @@ type accountServicesUserJSON struct {
 	TelegramLinked   bool    `json:"telegram_linked"`
 	TelegramUsername string  `json:"telegram_username,omitempty"`
 	TelegramChatID   int64   `json:"telegram_chat_id,omitempty"`
+	EmailUnlinkable  bool    `json:"email_unlinkable"`
 }
 
 type accountServicesRowJSON struct {
@@ -374,6 +403,11 @@ type accountServicesRowJSON struct {
 	Badges        []string `json:"badges"`
 	CanConnect    bool     `json:"can_connect"`
 	Cost          float64  `json:"cost,omitempty"`
+	AutoRenew     bool     `json:"auto_renew"`
+	// Renewal — следующее продление (nil, если у услуги нет следующего периода).
+	Renewal *accountRenewalJSON `json:"renewal,omitempty"`
+	// CanChangePlan — для услуги доступна смена тарифа с пересчётом (config.plan_change).
+	CanChangePlan bool `json:"can_change_plan"`
 }
 
 type accountServicesOKJSON struct {
@@ -384,6 +418,7 @@ type accountServicesOKJSON struct {
 const accountPaymentsLimit = 20
 
 type accountPaymentRowJSON struct {
+	ID          int     `json:"id"`
 	Date        string  `json:"date"`
 	Amount      float64 `json:"amount"`
 	AmountText  string  `json:"amount_text"`
@@ -433,6 +468,7 @@ func serveAccountPayments(cfg *config.Config, app accountWebApp) http.HandlerFun
 		for i := range visible {
 			p := visible[i]
 			out = append(out, accountPaymentRowJSON{
+				ID:          p.ID,
 				Date:        p.Date,
 				Amount:      p.Money,
 				AmountText:  models.FormatRubAmount(p.Money),
@@ -505,10 +541,14 @@ func serveAccountServices(cfg *config.Config, app accountWebApp) http.HandlerFun
 				ConnectApp:    conn,
 				Badges:        badges,
 				CanConnect:    accountDashboardCanShowConnect(cfg, *us),
+				AutoRenew:     us.AutoRenew(),
+				CanChangePlan: cfg.PlanChange.Enabled && us.Status == "ACTIVE",
 			}
-			if pay := dashboardTariffCostForUserService(app, us); pay > 0 {
+			pay := dashboardTariffCostForUserService(app, us)
+			if pay > 0 {
 				row.Cost = pay
 			}
+			row.Renewal = accountRenewalFromPreview(appService.BuildRenewalPreview(us, pay, balance))
 			out = append(out, row)
 		}
 
@@ -524,6 +564,7 @@ func serveAccountServices(cfg *config.Config, app accountWebApp) http.HandlerFun
 			userJSON.TelegramLinked = linked
 			userJSON.TelegramUsername = uname
 			userJSON.TelegramChatID = chatID
+			userJSON.EmailUnlinkable = isWebLinkedTelegramUser(cfg, shmUser)
 		}
 
 		writeJSON(w, http.StatusOK, accountServicesOKJSON{
@@ -673,20 +714,56 @@ func accountOrderPaymentFromSHMForecast(forecastRaw float64) (amount float64, ne
 }
 
 type accountBalanceTopupRequestJSON struct {
-	Token  string  `json:"token"`
-	Amount float64 `json:"amount"`
+	Token    string  `json:"token"`
+	Amount   float64 `json:"amount"`
+	Provider string  `json:"provider"`
 }
 
 type accountBalanceTopupOKJSON struct {
 	Status     string  `json:"status"`
+	Provider   string  `json:"provider"`
 	Amount     float64 `json:"amount"`
 	PaymentURL string  `json:"payment_url"`
 	Message    string  `json:"message"`
+	IntentID   string  `json:"intent_id,omitempty"`
 }
 
+// accountTopupMessage — пояснение после создания платежа; у криптооплаты свои оговорки про частичную оплату.
+func accountTopupMessage(providerID string) string {
+	if providerID == payments.ProviderCryptoCloud {
+		return accountBalanceCryptoTopupMessage
+	}
+	return accountBalanceTopupMessage
+}
+
+// serveAccountBalanceTopup — POST /api/account/balance/topup?provider=<id>: ссылка на оплату у провайдера
+// из реестра бренда (brand.payment_providers). Без provider — первый доступный для локали ru.
 func serveAccountBalanceTopup(cfg *config.Config, app accountWebApp) http.HandlerFunc {
+	return serveAccountBalanceTopupProvider(cfg, app, "/api/account/balance/topup", "")
+}
+
+// accountPaymentProvider — провайдер из пути, ?provider= или тела запроса; по умолчанию первый доступный бренду
+// для локали locale.
+func accountPaymentProvider(cfg *config.Config, reg *payments.Registry, r *http.Request, fixedProvider, reqProvider string, locale accountLocale) (payments.Provider, error) {
+	providerID := fixedProvider
+	if providerID == "" {
+		providerID = strings.TrimSpace(r.URL.Query().Get("provider"))
+	}
+	if providerID == "" {
+		providerID = strings.TrimSpace(reqProvider)
+	}
+	if providerID == "" {
+		if list := reg.Available(cfg.BrandID(), string(locale)); len(list) > 0 {
+			providerID = list[0].ID()
+		}
+	}
+	return reg.Get(providerID, cfg.BrandID())
+}
+
+// serveAccountBalanceTopupProvider — общий обработчик; fixedProvider задаёт провайдера для legacy-путей.
+func serveAccountBalanceTopupProvider(cfg *config.Config, app accountWebApp, path, fixedProvider string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path != "/api/account/balance/topup" {
+		if r.URL.Path != path {
 			http.NotFound(w, r)
 			return
 		}
@@ -714,32 +791,45 @@ func serveAccountBalanceTopup(cfg *config.Config, app accountWebApp) http.Handle
 			return
 		}
 
-		if !accountTopupAmountValid(req.Amount) {
-			writeJSONError(w, http.StatusBadRequest, "invalid_amount")
+		reg, err := payments.RegistryFromConfig(cfg)
+		if err != nil {
+			slog.Error("account balance topup: payment registry", "err", err)
+			writeJSONError(w, http.StatusInternalServerError, "payment_url_failed")
+			return
+		}
+		provider, err := accountPaymentProvider(cfg, reg, r, fixedProvider, req.Provider, resolveAccountLocale(r))
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "unknown_provider")
 			return
 		}
 
-		baseURL := ""
-		paySystem := ""
-		brandID := ""
-		if cfg != nil {
-			baseURL = cfg.API.BaseURL
-			paySystem = cfg.YooKassaPaySystem()
-			brandID = cfg.BrandID()
+		if !payments.AmountAllowed(provider, req.Amount) {
+			writeJSONError(w, http.StatusBadRequest, "invalid_amount")
+			return
 		}
+
 		amountRounded := math.Round(req.Amount*100) / 100
-		paymentURL, err := payments.BuildYooKassaPaymentURL(baseURL, claims.UserID, amountRounded, time.Now().Unix(), paySystem, brandID)
+		ts := time.Now().Unix()
+		paymentURL, err := provider.PaymentURL(payments.PaymentRequest{
+			BaseURL: cfg.API.BaseURL,
+			UserID:  claims.UserID,
+			Amount:  amountRounded,
+			TS:      ts,
+			BrandID: cfg.BrandID(),
+		})
 		if err != nil {
-			slog.Error("account balance topup: BuildYooKassaPaymentURL", "err", err)
+			slog.Error("account balance topup: payment url", "provider", provider.ID(), "err", err)
 			writeJSONError(w, http.StatusInternalServerError, "payment_url_failed")
 			return
 		}
 
 		writeJSON(w, http.StatusOK, accountBalanceTopupOKJSON{
 			Status:     "payment_required",
+			Provider:   provider.ID(),
 			Amount:     amountRounded,
 			PaymentURL: paymentURL,
-			Message:    accountBalanceTopupMessage,
+			Message:    accountTopupMessage(provider.ID()),
+			IntentID:   recordPaymentIntent(cfg, app, claims.UserID, amountRounded, provider.ID(), ts),
 		})
 	}
 }
@@ -781,13 +871,14 @@ func serveAccountCatalogServices(cfg *config.Config, app accountWebApp) http.Han
 
 		locale := resolveAccountLocale(r)
 		out := buildPublicServiceRowsFromList(cfg, list, locale)
-		writeJSON(w, http.StatusOK, publicServicesListJSON{Services: out})
+		writeJSON(w, http.StatusOK, publicServicesListJSON{Services: out, CardCheckout: cfg.CardCheckout.Enabled()})
 	}
 }
 
 type accountServiceOrderReqJSON struct {
 	Token     string `json:"token"`
 	ServiceID int    `json:"service_id"`
+	Provider  string `json:"provider"`
 }
 
 type accountServiceOrderOKJSON struct {
@@ -797,11 +888,52 @@ type accountServiceOrderOKJSON struct {
 	UserServiceStatus   string  `json:"user_service_status"`
 	Amount              float64 `json:"amount"`
 	PaymentURL          string  `json:"payment_url"`
+	Provider            string  `json:"provider,omitempty"`
 	Message             string  `json:"message"`
 	ExistingUnpaid      bool    `json:"existing_unpaid"`
 	RequestedServiceID  int     `json:"requested_service_id"`
 	ReturnedServiceID   int     `json:"returned_service_id"`
 	ReturnedServiceName string  `json:"returned_service_name"`
+	IntentID            string  `json:"intent_id,omitempty"`
+}
+
+// accountOrderableService — услуга каталога, доступная к заказу в кабинете; иначе пишет ошибку и возвращает false.
+func accountOrderableService(w http.ResponseWriter, cfg *config.Config, app accountWebApp, serviceID int) (*models.Service, bool) {
+	if serviceID <= 0 {
+		writeJSONError(w, http.StatusBadRequest, "invalid_service")
+		return nil, false
+	}
+
+	if tid := trialBaseServiceID(cfg); tid > 0 && serviceID == tid {
+		writeJSONError(w, http.StatusNotFound, "service_not_found")
+		return nil, false
+	}
+
+	svc, err := app.GetServiceByID(serviceID)
+	if err != nil {
+		if isServiceNotFoundErr(err) {
+			writeJSONError(w, http.StatusNotFound, "service_not_found")
+			return nil, false
+		}
+		slog.Error("account service order: GetServiceByID", "err", err)
+		writeJSONError(w, http.StatusInternalServerError, "internal_error")
+		return nil, false
+	}
+	if svc == nil {
+		writeJSONError(w, http.StatusNotFound, "service_not_found")
+		return nil, false
+	}
+	if svc.AllowToOrder != 1 {
+		writeJSONError(w, http.StatusNotFound, "service_not_found")
+		return nil, false
+	}
+	// Защитная проверка категории перед заказом (даже если GetServiceByID уже фильтрует):
+	// услуга другой категории неотличима от отсутствующей.
+	if !models.ServiceCategoryAllowed(cfgServiceCategory(cfg), svc.Category) {
+		writeJSONError(w, http.StatusNotFound, "service_not_found")
+		return nil, false
+	}
+	return svc, true
 }
 
 func serveAccountServiceOrder(cfg *config.Config, app accountWebApp) http.HandlerFunc {
@@ -834,39 +966,31 @@ func serveAccountServiceOrder(cfg *config.Config, app accountWebApp) http.Handle
 			return
 		}
 
-		if req.ServiceID <= 0 {
-			writeJSONError(w, http.StatusBadRequest, "invalid_service")
+		svc, ok := accountOrderableService(w, cfg, app, req.ServiceID)
+		if !ok {
 			return
 		}
 
-		if tid := trialBaseServiceID(cfg); tid > 0 && req.ServiceID == tid {
-			writeJSONError(w, http.StatusNotFound, "service_not_found")
-			return
-		}
-
-		svc, err := app.GetServiceByID(req.ServiceID)
-		if err != nil {
-			if isServiceNotFoundErr(err) {
-				writeJSONError(w, http.StatusNotFound, "service_not_found")
+		// EN-кабинет получает ссылку на оплату сразу: провайдер и его лимиты — из реестра бренда, как у /balance/topup.
+		// Провайдер проверяется до заказа, чтобы неверный выбор не оставлял новую неоплаченную услугу.
+		locale := resolveAccountLocale(r)
+		var provider payments.Provider
+		if locale == accountLocaleEN {
+			reg, err := payments.RegistryFromConfig(cfg)
+			if err != nil {
+				slog.Error("account service order: payment registry", "err", err)
+				writeJSONError(w, http.StatusInternalServerError, "payment_url_failed")
 				return
 			}
-			slog.Error("account service order: GetServiceByID", "err", err)
-			writeJSONError(w, http.StatusInternalServerError, "internal_error")
-			return
-		}
-		if svc == nil {
-			writeJSONError(w, http.StatusNotFound, "service_not_found")
-			return
-		}
-		if svc.AllowToOrder != 1 {
-			writeJSONError(w, http.StatusNotFound, "service_not_found")
-			return
-		}
-		// Защитная проверка категории перед заказом (даже если GetServiceByID уже фильтрует):
-		// услуга другой категории неотличима от отсутствующей.
-		if !models.ServiceCategoryAllowed(cfgServiceCategory(cfg), svc.Category) {
-			writeJSONError(w, http.StatusNotFound, "service_not_found")
-			return
+			provider, err = accountPaymentProvider(cfg, reg, r, "", req.Provider, accountLocaleEN)
+			if err != nil {
+				if strings.TrimSpace(req.Provider) != "" || strings.TrimSpace(r.URL.Query().Get("provider")) != "" {
+					writeJSONError(w, http.StatusBadRequest, "unknown_provider")
+					return
+				}
+				// У бренда нет провайдера для EN: заказ без ссылки, пополнение — через /balance/topup.
+				provider = nil
+			}
 		}
 
 		order, err := app.ServiceOrderByUserID(claims.UserID, svc.ServiceID)
@@ -891,6 +1015,10 @@ func serveAccountServiceOrder(cfg *config.Config, app accountWebApp) http.Handle
 			forecastRaw = bal.Forecast
 		}
 		amount, needsTopUp, badAmt := accountOrderPaymentFromSHMForecast(forecastRaw)
+		if provider != nil && forecastRaw > 0 {
+			amount, needsTopUp = payments.FitAmount(provider, forecastRaw)
+			badAmt = !needsTopUp
+		}
 		if badAmt {
 			writeJSONError(w, http.StatusBadRequest, "invalid_payment_amount")
 			return
@@ -899,24 +1027,28 @@ func serveAccountServiceOrder(cfg *config.Config, app accountWebApp) http.Handle
 		orderStatus := strings.TrimSpace(order.Status)
 		existingUnpaid := strings.EqualFold(orderStatus, "NOT PAID") && order.BaseServiceID != svc.ServiceID
 		noPaymentNeeded := !needsTopUp
-		locale := resolveAccountLocale(r)
 
-		paymentURL := ""
-		if locale == accountLocaleEN && needsTopUp && amount > 0 {
-			baseURL := ""
-			if cfg != nil {
-				baseURL = cfg.API.BaseURL
-			}
+		paymentURL, intentID, providerID := "", "", ""
+		if provider != nil && needsTopUp {
+			ts := time.Now().Unix()
 			var err error
-			paymentURL, err = payments.BuildCryptoCloudPaymentURL(baseURL, claims.UserID, amount, time.Now().Unix())
+			paymentURL, err = provider.PaymentURL(payments.PaymentRequest{
+				BaseURL: cfg.API.BaseURL,
+				UserID:  claims.UserID,
+				Amount:  amount,
+				TS:      ts,
+				BrandID: cfg.BrandID(),
+			})
 			if err != nil {
-				slog.Error("account service order: BuildCryptoCloudPaymentURL", "err", err, "user_id", claims.UserID, "amount", amount)
-				writeJSONError(w, http.StatusInternalServerError, "crypto_payment_url_failed")
+				slog.Error("account service order: payment url", "err", err, "provider", provider.ID(), "user_id", claims.UserID, "amount", amount)
+				writeJSONError(w, http.StatusInternalServerError, "payment_url_failed")
 				return
 			}
+			providerID = provider.ID()
+			intentID = recordPaymentIntent(cfg, app, claims.UserID, amount, providerID, ts)
 		}
 
-		msg := accountServiceOrderMessage(locale, existingUnpaid, noPaymentNeeded, amount, paymentURL != "")
+		msg := accountServiceOrderMessage(locale, existingUnpaid, noPaymentNeeded, amount, providerID)
 
 		retName := strings.TrimSpace(order.Name)
 		if retName == "" {
@@ -934,11 +1066,13 @@ func serveAccountServiceOrder(cfg *config.Config, app accountWebApp) http.Handle
 			UserServiceStatus:   orderStatus,
 			Amount:              amount,
 			PaymentURL:          paymentURL,
+			Provider:            providerID,
 			Message:             msg,
 			ExistingUnpaid:      existingUnpaid,
 			RequestedServiceID:  req.ServiceID,
 			ReturnedServiceID:   order.BaseServiceID,
 			ReturnedServiceName: retName,
+			IntentID:            intentID,
 		})
 	}
 }
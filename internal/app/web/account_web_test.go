@@ -16,6 +16,7 @@ import (
 	"github.com/ryabkov82/vpnbot/internal/attribution"
 	"github.com/ryabkov82/vpnbot/internal/config"
 	"github.com/ryabkov82/vpnbot/internal/models"
+	"github.com/ryabkov82/vpnbot/internal/receipts"
 	appService "github.com/ryabkov82/vpnbot/internal/service"
 	"github.com/ryabkov82/vpnbot/internal/webuser"
 )
@@ -79,6 +80,117 @@ type stubAccountWeb struct {
 	validateWebAccountCalls int
 	validateWebAccountErr   error
 	validateWebAccountRet   *models.User
+
+	intents     []appService.PaymentIntent
+	intentsErr  error
+	intentErr   error
+	intentCalls []appService.PaymentIntent
+
+	receipt        *receipts.Document
+	receiptErr     error
+	receiptPayID   int
+	statementMonth time.Time
+
+	withdrawals     []models.WithdrawItem
+	withdrawalsPage [2]int // limit, offset последнего вызова
+	ledger          *appService.Ledger
+	historyErr      error
+
+	voucherRet  *appService.VoucherRedemption
+	voucherErr  error
+	voucherReqs []appService.VoucherRedeemRequest
+
+	renewalRet   *appService.RenewalPreview
+	renewalErr   error
+	autoRenewArg []interface{} // userID, userServiceID, enabled последнего вызова
+	renewArg     []interface{} // userID, userServiceID, expectedExpire последнего вызова
+
+	planQuote    *appService.PlanChangeQuote
+	planQuoteArg [3]int // userID, userServiceID, serviceID последнего расчёта
+	planRet      *appService.PlanChange
+	planErr      error
+	planReqs     []appService.PlanChangeRequest
+}
+
+func (s *stubAccountWeb) QuotePlanChange(userID, userServiceID, serviceID int) (*appService.PlanChangeQuote, error) {
+	s.planQuoteArg = [3]int{userID, userServiceID, serviceID}
+	return s.planQuote, s.planErr
+}
+
+func (s *stubAccountWeb) ChangePlan(req appService.PlanChangeRequest) (*appService.PlanChange, error) {
+	s.planReqs = append(s.planReqs, req)
+	return s.planRet, s.planErr
+}
+
+func (s *stubAccountWeb) RedeemVoucher(req appService.VoucherRedeemRequest) (*appService.VoucherRedemption, error) {
+	s.voucherReqs = append(s.voucherReqs, req)
+	return s.voucherRet, s.voucherErr
+}
+
+func (s *stubAccountWeb) SetAutoRenew(userID, userServiceID int, enabled bool) (*appService.RenewalPreview, error) {
+	s.autoRenewArg = []interface{}{userID, userServiceID, enabled}
+	return s.renewalRet, s.renewalErr
+}
+
+func (s *stubAccountWeb) RenewUserService(userID, userServiceID int, expectedExpire string) (*appService.RenewalPreview, error) {
+	s.renewArg = []interface{}{userID, userServiceID, expectedExpire}
+	return s.renewalRet, s.renewalErr
+}
+
+func (s *stubAccountWeb) UserWithdrawals(userID, limit, offset int) ([]models.WithdrawItem, int, error) {
+	s.withdrawalsPage = [2]int{limit, offset}
+	if s.historyErr != nil {
+		return nil, 0, s.historyErr
+	}
+	return s.withdrawals, len(s.withdrawals), nil
+}
+
+func (s *stubAccountWeb) UserLedger(userID int) (*appService.Ledger, error) {
+	if s.historyErr != nil {
+		return nil, s.historyErr
+	}
+	return s.ledger, nil
+}
+
+func (s *stubAccountWeb) PaymentReceipt(userID, payID int) (*receipts.Document, error) {
+	s.receiptPayID = payID
+	return s.receipt, s.receiptErr
+}
+
+func (s *stubAccountWeb) MonthlyStatement(userID int, month time.Time) (*receipts.Document, error) {
+	s.statementMonth = month
+	return s.receipt, s.receiptErr
+}
+
+func (s *stubAccountWeb) CreatePaymentIntent(userID int, amount float64, provider, brandID string, ts int64) (*appService.PaymentIntent, error) {
+	if s.intentErr != nil {
+		return nil, s.intentErr
+	}
+	in := appService.PaymentIntent{
+		ID: "pi_" + strconv.Itoa(len(s.intentCalls)+1), Amount: amount, Provider: provider, BrandID: brandID, TS: ts,
+		Status: appService.PaymentIntentProcessing,
+	}
+	s.intentCalls = append(s.intentCalls, in)
+	return &in, nil
+}
+
+func (s *stubAccountWeb) CreateOrderIntent(userID, serviceID int, serviceName string, amount float64, provider, brandID string, ts int64) (*appService.PaymentIntent, error) {
+	if s.intentErr != nil {
+		return nil, s.intentErr
+	}
+	in := appService.PaymentIntent{
+		ID: "pi_" + strconv.Itoa(len(s.intentCalls)+1), Amount: amount, Provider: provider, BrandID: brandID, TS: ts,
+		Status: appService.PaymentIntentProcessing, ServiceID: serviceID, ServiceName: serviceName, OrderStatus: appService.OrderIntentAwaiting,
+	}
+	s.intentCalls = append(s.intentCalls, in)
+	return &in, nil
+}
+
+func (s *stubAccountWeb) FulfillOrderIntents(userID int) ([]appService.PaymentIntent, error) {
+	if s.intentsErr != nil {
+		return nil, s.intentsErr
+	}
+	return s.intents, nil
 }
 
 func (s *stubAccountWeb) ValidateWebAccountUser(userID int, tokenLogin, tokenEmail string) (*models.User, error) {
@@ -257,7 +369,7 @@ func TestServeAccountLoginStart_Honeypot(t *testing.T) {
 	})
 	cfg := orderStartTestCfg()
 	rl := newLeadRateLimiter(50, time.Hour, 50, time.Hour)
-	h := serveAccountLoginStart(cfg, &stubAccountWeb{}, rl)
+	h := serveAccountLoginStartWithCodes(cfg, &stubAccountWeb{}, rl, nil)
 	body := `{"email":"a@b.c","website":"x"}`
 	req := httptest.NewRequest(http.MethodPost, "/api/account/login/start", strings.NewReader(body))
 	rec := httptest.NewRecorder()
@@ -281,7 +393,7 @@ func TestServeAccountLoginStart_EmptyWebLoginPrefix_InternalErrorNoSideEffects(t
 	cfg.Brand.WebUserLoginPrefix = ""
 	rl := newLeadRateLimiter(50, time.Hour, 50, time.Hour)
 	st := &stubAccountWeb{}
-	h := serveAccountLoginStart(cfg, st, rl)
+	h := serveAccountLoginStartWithCodes(cfg, st, rl, nil)
 	req := httptest.NewRequest(http.MethodPost, "/api/account/login/start", strings.NewReader(`{"email":"a@b.c","website":""}`))
 	rec := httptest.NewRecorder()
 	h.ServeHTTP(rec, req)
@@ -310,7 +422,7 @@ func TestServeAccountLoginStart_UnknownEmailSendsSignupTokenMail_NoWebUserYet(t
 	cfg.Brand.PublicBaseURL = "https://connect.vpn-for-friends.com"
 	rl := newLeadRateLimiter(50, time.Hour, 50, time.Hour)
 	st := &stubAccountWeb{}
-	h := serveAccountLoginStart(cfg, st, rl)
+	h := serveAccountLoginStartWithCodes(cfg, st, rl, nil)
 	rawEmail := `nouser@test.com`
 	normEmail, err := webuser.NormalizeEmail(rawEmail)
 	if err != nil {
@@ -396,7 +508,7 @@ func TestServeAccountLoginStart_KnownEmailSendsMail(t *testing.T) {
 	wantLogin := webuser.WebLoginFromEmail(wantNorm)
 	u := &models.User{ID: 511, Login: wantLogin}
 	st := &stubAccountWeb{findUserByWebEmailRet: u}
-	h := serveAccountLoginStart(cfg, st, rl)
+	h := serveAccountLoginStartWithCodes(cfg, st, rl, nil)
 	body := `{
 		"email":"` + em + `",
 		"website":"",
@@ -450,7 +562,7 @@ func TestServeAccountLoginStart_PrefersSettingsWebEmailUser(t *testing.T) {
 		userByLogin:           nil,
 	}
 	rec := httptest.NewRecorder()
-	serveAccountLoginStart(cfg, st, rl).ServeHTTP(rec,
+	serveAccountLoginStartWithCodes(cfg, st, rl, nil).ServeHTTP(rec,
 		httptest.NewRequest(http.MethodPost, "/api/account/login/start", strings.NewReader(`{"email":"`+em+`","website":""}`)))
 	if rec.Code != http.StatusOK {
 		t.Fatalf("%d %s", rec.Code, rec.Body.String())
@@ -483,13 +595,13 @@ func TestServeAccountLoginStart_KnownAndUnknownSameJSONBody(t *testing.T) {
 		t.Fatal(err)
 	}
 	kLogin := webuser.WebLoginFromEmail(kNorm)
-	serveAccountLoginStart(cfg, &stubAccountWeb{findUserByWebEmailRet: &models.User{ID: 77, Login: kLogin}}, rl).ServeHTTP(kRec,
+	serveAccountLoginStartWithCodes(cfg, &stubAccountWeb{findUserByWebEmailRet: &models.User{ID: 77, Login: kLogin}}, rl, nil).ServeHTTP(kRec,
 		httptest.NewRequest(http.MethodPost, "/api/account/login/start", strings.NewReader(`{"email":"`+knownEmail+`","website":""}`)))
 	if kRec.Code != http.StatusOK {
 		t.Fatalf("known email: %s", kRec.Body.String())
 	}
 	uRec := httptest.NewRecorder()
-	serveAccountLoginStart(cfg, &stubAccountWeb{}, rl).ServeHTTP(uRec,
+	serveAccountLoginStartWithCodes(cfg, &stubAccountWeb{}, rl, nil).ServeHTTP(uRec,
 		httptest.NewRequest(http.MethodPost, "/api/account/login/start", strings.NewReader(`{"email":"unknowntest@test.com","website":""}`)))
 	if uRec.Code != http.StatusOK {
 		t.Fatalf("unknown email: %s", uRec.Body.String())
@@ -510,7 +622,7 @@ func TestServeAccountLoginStart_SMTPError(t *testing.T) {
 		t.Fatal(err)
 	}
 	st := &stubAccountWeb{findUserByWebEmailRet: &models.User{ID: 1, Login: webuser.WebLoginFromEmail(un)}}
-	h := serveAccountLoginStart(cfg, st, rl)
+	h := serveAccountLoginStartWithCodes(cfg, st, rl, nil)
 	req := httptest.NewRequest(http.MethodPost, "/api/account/login/start", strings.NewReader(`{"email":"u@test.com","website":""}`))
 	rec := httptest.NewRecorder()
 	h.ServeHTTP(rec, req)
@@ -850,7 +962,7 @@ func TestServeAccountLoginStart_OrganicAttribution(t *testing.T) {
 	rl := newLeadRateLimiter(50, time.Hour, 50, time.Hour)
 	body := `{"email":"organic@test.com","website":"","landing_path":"","referrer":"","utm_source":"","utm_medium":"","utm_campaign":"","utm_content":"","utm_term":""}`
 	rec := httptest.NewRecorder()
-	serveAccountLoginStart(cfg, &stubAccountWeb{}, rl).ServeHTTP(rec,
+	serveAccountLoginStartWithCodes(cfg, &stubAccountWeb{}, rl, nil).ServeHTTP(rec,
 		httptest.NewRequest(http.MethodPost, "/api/account/login/start", strings.NewReader(body)))
 	if rec.Code != http.StatusOK {
 		t.Fatalf("%d %s", rec.Code, rec.Body.String())
@@ -885,7 +997,7 @@ func TestServeAccountLoginStart_MalformedOptionalMarketingStillOK(t *testing.T)
 	}
 	body := string(bodyBytes)
 	rec := httptest.NewRecorder()
-	serveAccountLoginStart(cfg, &stubAccountWeb{}, rl).ServeHTTP(rec,
+	serveAccountLoginStartWithCodes(cfg, &stubAccountWeb{}, rl, nil).ServeHTTP(rec,
 		httptest.NewRequest(http.MethodPost, "/api/account/login/start", strings.NewReader(body)))
 	if rec.Code != http.StatusOK {
 		t.Fatalf("optional marketing must not block: %d %s body_in=%s", rec.Code, rec.Body.String(), body[:min(200, len(body))])
@@ -911,7 +1023,7 @@ func TestServeAccountLoginStart_InvalidPublicBaseURL_NoSMTP(t *testing.T) {
 		strings.NewReader(`{"email":"new@test.com","website":""}`))
 	req.Host = "evil.example"
 	rec := httptest.NewRecorder()
-	serveAccountLoginStart(cfg, st, rl).ServeHTTP(rec, req)
+	serveAccountLoginStartWithCodes(cfg, st, rl, nil).ServeHTTP(rec, req)
 	if rec.Code != http.StatusInternalServerError {
 		t.Fatalf("want 500 got %d %s", rec.Code, rec.Body.String())
 	}
@@ -0,0 +1,103 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/ryabkov82/vpnbot/internal/config"
+	"github.com/ryabkov82/vpnbot/internal/service"
+)
+
+// adminUserMergeApp — admin merge дублей Telegram/web (stub в тестах).
+type adminUserMergeApp interface {
+	MergeUsers(req service.UserMergeRequest) (*service.UserMergeResult, error)
+}
+
+// adminUserMergeRequestJSON — тело POST /api/admin/users/merge; dry_run по умолчанию true.
+type adminUserMergeRequestJSON struct {
+	UserAID    int    `json:"user_a_id"`
+	UserBID    int    `json:"user_b_id"`
+	SurvivorID int    `json:"survivor_user_id"`
+	DryRun     *bool  `json:"dry_run"`
+	Force      bool   `json:"force"`
+	Operator   string `json:"operator"`
+}
+
+type adminUserMergeOKJSON struct {
+	Status string `json:"status"`
+	*service.UserMergeResult
+}
+
+func serveAdminUserMerge(cfg *config.Config, app adminUserMergeApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/admin/users/merge" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed")
+			return
+		}
+
+		wantTok := ""
+		if cfg != nil {
+			wantTok = cfg.Admin.Token
+		}
+		if !adminTokenMatches(wantTok, r.Header.Get("X-Admin-Token")) {
+			writeJSONError(w, http.StatusForbidden, "forbidden")
+			return
+		}
+
+		const maxBody = 1 << 20
+		dec := json.NewDecoder(io.LimitReader(r.Body, maxBody))
+		var req adminUserMergeRequestJSON
+		if err := dec.Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "bad_request")
+			return
+		}
+		if req.UserAID <= 0 || req.UserBID <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "invalid_user_id")
+			return
+		}
+		dryRun := req.DryRun == nil || *req.DryRun
+
+		res, err := app.MergeUsers(service.UserMergeRequest{
+			UserAID:    req.UserAID,
+			UserBID:    req.UserBID,
+			SurvivorID: req.SurvivorID,
+			DryRun:     dryRun,
+			Force:      req.Force,
+			Operator:   req.Operator,
+		})
+		switch {
+		case err == nil:
+			status := "planned"
+			if res.Applied {
+				status = "merged"
+			}
+			writeJSON(w, http.StatusOK, adminUserMergeOKJSON{Status: status, UserMergeResult: res})
+		case errors.Is(err, service.ErrMergeNeedsForce):
+			writeJSON(w, http.StatusConflict, struct {
+				Error string `json:"error"`
+				*service.UserMergeResult
+			}{Error: "force_required", UserMergeResult: res})
+		case errors.Is(err, service.ErrMergeSameUser):
+			writeJSONError(w, http.StatusBadRequest, "same_user")
+		case errors.Is(err, service.ErrMergeInvalidSurvivor):
+			writeJSONError(w, http.StatusBadRequest, "invalid_survivor")
+		case errors.Is(err, service.ErrUserNotFound):
+			writeJSONError(w, http.StatusNotFound, "user_not_found")
+		case errors.Is(err, service.ErrMergeIdentityUnsupported):
+			writeJSONError(w, http.StatusConflict, "identity_unsupported")
+		case errors.Is(err, service.ErrMergeSurvivorOccupied):
+			writeJSONError(w, http.StatusConflict, "survivor_occupied")
+		default:
+			slog.Error("admin user merge: MergeUsers", "err", err, "user_a_id", req.UserAID, "user_b_id", req.UserBID)
+			writeJSONError(w, http.StatusInternalServerError, "merge_failed")
+		}
+	}
+}
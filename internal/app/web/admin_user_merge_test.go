@@ -0,0 +1,105 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ryabkov82/vpnbot/internal/service"
+)
+
+type stubAdminUserMergeApp struct {
+	res  *service.UserMergeResult
+	err  error
+	last service.UserMergeRequest
+	n    int
+}
+
+func (s *stubAdminUserMergeApp) MergeUsers(req service.UserMergeRequest) (*service.UserMergeResult, error) {
+	s.n++
+	s.last = req
+	return s.res, s.err
+}
+
+func postAdminUserMerge(t *testing.T, app adminUserMergeApp, token, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	h := serveAdminUserMerge(testAdminAccountCfg("secret"), app)
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/users/merge", strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("X-Admin-Token", token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServeAdminUserMerge_Forbidden(t *testing.T) {
+	app := &stubAdminUserMergeApp{}
+	rec := postAdminUserMerge(t, app, "wrong", `{"user_a_id":1,"user_b_id":2}`)
+	if rec.Code != http.StatusForbidden || app.n != 0 {
+		t.Fatalf("code=%d calls=%d", rec.Code, app.n)
+	}
+	assertJSONErrorField(t, rec.Body.String(), "forbidden")
+}
+
+func TestServeAdminUserMerge_DryRunDefault(t *testing.T) {
+	app := &stubAdminUserMergeApp{res: &service.UserMergeResult{
+		Plan:   service.UserMergePlan{Survivor: service.UserMergeSide{UserID: 2}, Absorbed: service.UserMergeSide{UserID: 1}},
+		DryRun: true,
+	}}
+	rec := postAdminUserMerge(t, app, "secret", `{"user_a_id":1,"user_b_id":2,"operator":"ops"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("code=%d body=%s", rec.Code, rec.Body.String())
+	}
+	if !app.last.DryRun || app.last.Operator != "ops" {
+		t.Fatalf("request %#v", app.last)
+	}
+	var got struct {
+		Status string `json:"status"`
+		Plan   struct {
+			Survivor struct {
+				UserID int `json:"user_id"`
+			} `json:"survivor"`
+		} `json:"plan"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != "planned" || got.Plan.Survivor.UserID != 2 {
+		t.Fatalf("body %s", rec.Body.String())
+	}
+}
+
+func TestServeAdminUserMerge_ApplyAndErrors(t *testing.T) {
+	app := &stubAdminUserMergeApp{res: &service.UserMergeResult{Applied: true}}
+	rec := postAdminUserMerge(t, app, "secret", `{"user_a_id":1,"user_b_id":2,"survivor_user_id":1,"dry_run":false,"force":true}`)
+	if rec.Code != http.StatusOK || app.last.DryRun || !app.last.Force || app.last.SurvivorID != 1 {
+		t.Fatalf("code=%d req=%#v", rec.Code, app.last)
+	}
+	if !strings.Contains(rec.Body.String(), `"status":"merged"`) {
+		t.Fatalf("body %s", rec.Body.String())
+	}
+
+	app = &stubAdminUserMergeApp{res: &service.UserMergeResult{}, err: service.ErrMergeNeedsForce}
+	rec = postAdminUserMerge(t, app, "secret", `{"user_a_id":1,"user_b_id":2,"dry_run":false}`)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("force: code=%d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), `"error":"force_required"`) || !strings.Contains(rec.Body.String(), `"plan"`) {
+		t.Fatalf("force body %s", rec.Body.String())
+	}
+
+	app = &stubAdminUserMergeApp{err: service.ErrMergeIdentityUnsupported}
+	rec = postAdminUserMerge(t, app, "secret", `{"user_a_id":1,"user_b_id":2}`)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("identity: code=%d", rec.Code)
+	}
+	assertJSONErrorField(t, rec.Body.String(), "identity_unsupported")
+
+	rec = postAdminUserMerge(t, app, "secret", `{"user_a_id":0,"user_b_id":2}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid id: code=%d", rec.Code)
+	}
+}
@@ -0,0 +1,277 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/config"
+	"github.com/ryabkov82/vpnbot/internal/models"
+	"github.com/ryabkov82/vpnbot/internal/payments"
+	"github.com/ryabkov82/vpnbot/internal/service"
+)
+
+// cardCheckoutProviderID — provider в ответе /api/account/checkout/card.
+const cardCheckoutProviderID = "card"
+
+// cardCheckoutNow — время проверки подписи webhook (подменяется в тестах).
+var cardCheckoutNow = time.Now
+
+// cardCheckoutWebhookApp — зачисление и заказ по оплаченной сессии (stub в тестах).
+type cardCheckoutWebhookApp interface {
+	FulfillCardCheckout(p service.CardCheckoutPayment) (*service.CardCheckoutResult, error)
+}
+
+type accountCardCheckoutRequestJSON struct {
+	Token      string `json:"token"`
+	PublicCode string `json:"public_code"`
+}
+
+type accountCardCheckoutOKJSON struct {
+	Status      string `json:"status"`
+	Provider    string `json:"provider"`
+	SessionID   string `json:"session_id"`
+	PaymentURL  string `json:"payment_url"`
+	Currency    string `json:"currency"`
+	AmountCents int64  `json:"amount_cents"`
+}
+
+// cardCheckoutService — услуга каталога бренда с международной ценой по public_code.
+func cardCheckoutService(list []models.Service, publicCode string) (*models.Service, bool) {
+	code := strings.TrimSpace(publicCode)
+	if code == "" {
+		return nil, false
+	}
+	for i := range list {
+		s := &list[i]
+		pc, enabled, currency, cents := servicePricingFields(s)
+		if strings.EqualFold(pc, code) && enabled && currency != "" && cents > 0 && s.Cost > 0 {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+// serveAccountCardCheckout — POST /api/account/checkout/card: сессия оплаты картой по международной цене услуги.
+// Деньги зачисляются только по подписанному webhook (/api/webhooks/card), не по возврату пользователя.
+func serveAccountCardCheckout(cfg *config.Config, app accountWebApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/account/checkout/card" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed")
+			return
+		}
+		if !webSalesTokenFlowAvailable(cfg) {
+			writeJSONError(w, http.StatusNotFound, "not_found")
+			return
+		}
+		if !cfg.CardCheckout.Enabled() {
+			writeJSONError(w, http.StatusServiceUnavailable, "card_checkout_unavailable")
+			return
+		}
+
+		var req accountCardCheckoutRequestJSON
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "bad_request")
+			return
+		}
+		claims, user, err := authenticateWebAccount(cfg, app, req.Token)
+		if err != nil {
+			writeAccountAuthError(w, err)
+			return
+		}
+
+		list, err := app.GetServices()
+		if err != nil {
+			slog.Error("card checkout: GetServices", "err", err)
+			writeJSONError(w, http.StatusInternalServerError, "services_unavailable")
+			return
+		}
+		svc, ok := cardCheckoutService(list, req.PublicCode)
+		if !ok {
+			writeJSONError(w, http.StatusNotFound, "service_not_found")
+			return
+		}
+		_, _, currency, cents := servicePricingFields(svc)
+
+		base := strings.TrimRight(strings.TrimSpace(cfg.PublicBaseURL()), "/")
+		if base == "" {
+			slog.Error("card checkout: public_base_url is empty")
+			writeJSONError(w, http.StatusInternalServerError, "payment_url_failed")
+			return
+		}
+		email := ""
+		if user != nil {
+			email = strings.TrimSpace(user.Settings.Web.Email)
+		}
+		brandID := cfg.BrandID()
+		publicCode := strings.TrimSpace(svc.Config.Pricing.PublicCode)
+		ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+		defer cancel()
+		client := payments.NewHTTPCheckoutClient(cfg.CardCheckout.APIBaseURL, cfg.CardCheckout.SecretKey)
+		sess, err := client.CreateCheckoutSession(ctx, payments.CheckoutParams{
+			PublicCode:    publicCode,
+			ProductName:   strings.TrimSpace(svc.Name),
+			Currency:      currency,
+			AmountCents:   cents,
+			CustomerEmail: email,
+			SuccessURL:    base + "/payment/return?provider=" + cardCheckoutProviderID,
+			CancelURL:     base + "/account?lang=en",
+			Metadata: map[string]string{
+				payments.CheckoutMetaBrandID:    brandID,
+				payments.CheckoutMetaUserID:     strconv.Itoa(claims.UserID),
+				payments.CheckoutMetaServiceID:  strconv.Itoa(svc.ServiceID),
+				payments.CheckoutMetaPublicCode: publicCode,
+			},
+			// Повторный клик в течение 10 минут возвращает ту же сессию.
+			IdempotencyKey: fmt.Sprintf("%s:%d:%s:%d", brandID, claims.UserID, publicCode, time.Now().Unix()/600),
+		})
+		if err != nil {
+			slog.Error("card checkout: create session", "user_id", claims.UserID, "public_code", publicCode, "err", err)
+			writeJSONError(w, http.StatusBadGateway, "payment_url_failed")
+			return
+		}
+		// Зачисление по webhook идёт в рублях (service.cost) — по этой сумме /payment/return его и найдёт.
+		recordPaymentIntent(cfg, app, claims.UserID, svc.Cost, cardCheckoutProviderID, time.Now().Unix())
+		writeJSON(w, http.StatusOK, accountCardCheckoutOKJSON{
+			Status:      "payment_required",
+			Provider:    cardCheckoutProviderID,
+			SessionID:   sess.ID,
+			PaymentURL:  sess.URL,
+			Currency:    strings.ToUpper(currency),
+			AmountCents: cents,
+		})
+	}
+}
+
+// serveCardCheckoutWebhook — POST /api/webhooks/card: подписанное событие провайдера.
+// На чужой бренд, неоплаченные сессии и прочие события отвечаем 200 (повтор не нужен);
+// на сбой SHM — 500, чтобы провайдер повторил доставку.
+func serveCardCheckoutWebhook(cfg *config.Config, app cardCheckoutWebhookApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/webhooks/card" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		secret := strings.TrimSpace(cfg.CardCheckout.WebhookSecret)
+		if secret == "" {
+			writeJSONError(w, http.StatusServiceUnavailable, "webhook disabled")
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "bad request")
+			return
+		}
+		ev, err := payments.VerifyCheckoutWebhook(body, r.Header.Get(payments.CheckoutSignatureHeader), secret, cardCheckoutNow())
+		if err != nil {
+			if errors.Is(err, payments.ErrCheckoutSignature) {
+				writeJSONError(w, http.StatusUnauthorized, "invalid signature")
+				return
+			}
+			writeJSONError(w, http.StatusBadRequest, "bad request")
+			return
+		}
+		sess := ev.Data.Object
+		if ev.Type != payments.CheckoutEventCompleted || !sess.Paid() {
+			writeJSON(w, http.StatusOK, map[string]string{"status": "ignored"})
+			return
+		}
+		meta := sess.Metadata
+		if !strings.EqualFold(strings.TrimSpace(meta[payments.CheckoutMetaBrandID]), cfg.BrandID()) {
+			slog.Info("card checkout webhook: other brand", "session_id", sess.ID, "brand_id", meta[payments.CheckoutMetaBrandID])
+			writeJSON(w, http.StatusOK, map[string]string{"status": "ignored"})
+			return
+		}
+		userID, _ := strconv.Atoi(meta[payments.CheckoutMetaUserID])
+		serviceID, _ := strconv.Atoi(meta[payments.CheckoutMetaServiceID])
+		if userID <= 0 || serviceID <= 0 || sess.ID == "" {
+			slog.Error("card checkout webhook: bad metadata", "session_id", sess.ID, "metadata", meta)
+			writeJSON(w, http.StatusOK, map[string]string{"status": "ignored"})
+			return
+		}
+
+		res, err := app.FulfillCardCheckout(service.CardCheckoutPayment{
+			SessionID:   sess.ID,
+			UserID:      userID,
+			ServiceID:   serviceID,
+			PublicCode:  meta[payments.CheckoutMetaPublicCode],
+			Currency:    sess.Currency,
+			AmountCents: sess.AmountTotal,
+			PaySystem:   cfg.CardCheckout.SHMPaySystem(),
+		})
+		if err != nil {
+			slog.Error("card checkout webhook: fulfill", "session_id", sess.ID, "user_id", userID, "err", err)
+			writeJSONError(w, http.StatusInternalServerError, "fulfill failed")
+			return
+		}
+		slog.Info("card checkout webhook: fulfilled", "session_id", sess.ID, "user_id", userID,
+			"status", res.Status, "reason", res.Reason, "credited", res.Credited, "user_service_id", res.UserServiceID, "duplicate", res.Duplicate)
+		// «review» сохранён в settings.card_checkouts — повтор доставки ничего не изменит, отвечаем 200.
+		writeJSON(w, http.StatusOK, res)
+	}
+}
+
+// adminCardCheckoutsApp — записи оплат картой пользователя для поддержки (stub в тестах).
+type adminCardCheckoutsApp interface {
+	CardCheckoutRecords(userID int) (map[string]interface{}, error)
+}
+
+// serveAdminCardCheckouts — GET /api/admin/card-checkouts?user_id=…[&status=review] (X-Admin-Token):
+// settings.card_checkouts пользователя, в том числе сессии, ждущие ручного разбора.
+func serveAdminCardCheckouts(cfg *config.Config, app adminCardCheckoutsApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/admin/card-checkouts" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed")
+			return
+		}
+		wantTok := ""
+		if cfg != nil {
+			wantTok = cfg.Admin.Token
+		}
+		if !adminTokenMatches(wantTok, r.Header.Get("X-Admin-Token")) {
+			writeJSONError(w, http.StatusForbidden, "forbidden")
+			return
+		}
+		userID, err := strconv.Atoi(strings.TrimSpace(r.URL.Query().Get("user_id")))
+		if err != nil || userID <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "invalid_user_id")
+			return
+		}
+		records, err := app.CardCheckoutRecords(userID)
+		if err != nil {
+			slog.Error("admin card checkouts", "user_id", userID, "err", err)
+			writeJSONError(w, http.StatusInternalServerError, "lookup_failed")
+			return
+		}
+		if status := strings.TrimSpace(r.URL.Query().Get("status")); status != "" {
+			for id, rec := range records {
+				if m, _ := rec.(map[string]interface{}); m == nil || m["status"] != status {
+					delete(records, id)
+				}
+			}
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"user_id": userID, "card_checkouts": records})
+	}
+}
@@ -0,0 +1,231 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/config"
+	"github.com/ryabkov82/vpnbot/internal/models"
+	"github.com/ryabkov82/vpnbot/internal/payments"
+	"github.com/ryabkov82/vpnbot/internal/payments/checkouttest"
+	"github.com/ryabkov82/vpnbot/internal/service"
+)
+
+func cardCheckoutTestCfg(t *testing.T) (*config.Config, *checkouttest.Server) {
+	t.Helper()
+	stub := checkouttest.NewServer("sk_test")
+	t.Cleanup(stub.Close)
+	cfg := orderStartTestCfg()
+	cfg.CardCheckout = config.CardCheckout{APIBaseURL: stub.URL, SecretKey: "sk_test", WebhookSecret: "whsec_test"}
+	return cfg, stub
+}
+
+func cardCheckoutCatalog() []models.Service {
+	return []models.Service{
+		{ServiceID: 3, Name: "Basic", Cost: 150, Period: 1, AllowToOrder: 1},
+		{ServiceID: 7, Name: "Premium 1 month", Cost: 450, Period: 1, AllowToOrder: 1, Config: &models.ServiceConfig{
+			Pricing: models.ServicePricingConfig{PublicCode: "premium-1m", InternationalEnabled: true, InternationalCurrency: "USD", InternationalAmountCents: 499},
+		}},
+	}
+}
+
+func postCardCheckout(t *testing.T, cfg *config.Config, st *stubAccountWeb, code string) *httptest.ResponseRecorder {
+	t.Helper()
+	tok, err := CreateAccountToken(cfg.WebSales.OrderTokenSecret, "vff", "en@buy.com", 3381, "web_en", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/account/checkout/card", strings.NewReader(`{"token":"`+tok+`","public_code":"`+code+`"}`))
+	serveAccountCardCheckout(cfg, st).ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServeAccountCardCheckout_CreatesSessionWithBrandMetadata(t *testing.T) {
+	cfg, stub := cardCheckoutTestCfg(t)
+	rec := postCardCheckout(t, cfg, &stubAccountWeb{shmServices: cardCheckoutCatalog()}, "premium-1m")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%d %s", rec.Code, rec.Body.String())
+	}
+	var out accountCardCheckoutOKJSON
+	if err := json.NewDecoder(rec.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Provider != "card" || out.Currency != "USD" || out.AmountCents != 499 || !strings.HasPrefix(out.PaymentURL, stub.URL) {
+		t.Fatalf("%+v", out)
+	}
+	sessions := stub.Sessions()
+	if len(sessions) != 1 {
+		t.Fatalf("sessions=%d", len(sessions))
+	}
+	s := sessions[0]
+	want := map[string]string{"brand_id": "vff", "user_id": "3381", "service_id": "7", "public_code": "premium-1m"}
+	for k, v := range want {
+		if s.Metadata[k] != v {
+			t.Fatalf("metadata %s=%q want %q (%v)", k, s.Metadata[k], v, s.Metadata)
+		}
+	}
+	if s.ID != out.SessionID || s.CustomerEmail != "en@buy.com" || s.SuccessURL != "https://shop.example/payment/return?provider=card" {
+		t.Fatalf("session %+v", s)
+	}
+}
+
+func TestServeAccountCardCheckout_Errors(t *testing.T) {
+	cfg, stub := cardCheckoutTestCfg(t)
+	st := &stubAccountWeb{shmServices: cardCheckoutCatalog()}
+
+	if rec := postCardCheckout(t, cfg, st, "basic"); rec.Code != http.StatusNotFound {
+		t.Fatalf("no international price: %d", rec.Code)
+	}
+	assertJSONErrorField(t, postCardCheckout(t, cfg, st, "missing").Body.String(), "service_not_found")
+
+	cfg.CardCheckout.SecretKey = "sk_wrong"
+	rec := postCardCheckout(t, cfg, st, "premium-1m")
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("provider error: %d", rec.Code)
+	}
+	assertJSONErrorField(t, rec.Body.String(), "payment_url_failed")
+
+	cfg.CardCheckout.WebhookSecret = ""
+	if rec := postCardCheckout(t, cfg, st, "premium-1m"); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("disabled: %d", rec.Code)
+	}
+	if len(stub.Sessions()) != 0 {
+		t.Fatal("no session must be created")
+	}
+}
+
+type stubCardCheckoutApp struct {
+	calls []service.CardCheckoutPayment
+	res   *service.CardCheckoutResult
+	err   error
+}
+
+func (s *stubCardCheckoutApp) FulfillCardCheckout(p service.CardCheckoutPayment) (*service.CardCheckoutResult, error) {
+	s.calls = append(s.calls, p)
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.res, nil
+}
+
+func stubCardCheckoutNow(t *testing.T, now time.Time) {
+	t.Helper()
+	prev := cardCheckoutNow
+	cardCheckoutNow = func() time.Time { return now }
+	t.Cleanup(func() { cardCheckoutNow = prev })
+}
+
+func cardWebhookEvent(t *testing.T, meta map[string]string, paid bool, secret string, at time.Time) (body []byte, sig string) {
+	t.Helper()
+	sess := payments.CheckoutSession{ID: "cs_test_1", Currency: "usd", AmountTotal: 499, PaymentStatus: "unpaid", Metadata: meta}
+	if paid {
+		sess.PaymentStatus = "paid"
+	}
+	body, err := checkouttest.EventPayload("evt_1", payments.CheckoutEventCompleted, sess, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body, payments.SignCheckoutPayload(secret, body, at)
+}
+
+func postCardWebhook(cfg *config.Config, app cardCheckoutWebhookApp, body []byte, sig string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/webhooks/card", strings.NewReader(string(body)))
+	req.Header.Set(payments.CheckoutSignatureHeader, sig)
+	serveCardCheckoutWebhook(cfg, app).ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServeCardCheckoutWebhook(t *testing.T) {
+	cfg, _ := cardCheckoutTestCfg(t)
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	stubCardCheckoutNow(t, now)
+	meta := map[string]string{"brand_id": "vff", "user_id": "3381", "service_id": "7", "public_code": "premium-1m"}
+
+	app := &stubCardCheckoutApp{res: &service.CardCheckoutResult{Status: service.CardCheckoutOrdered, Credited: 450, UserServiceID: 901}}
+	body, sig := cardWebhookEvent(t, meta, true, "whsec_test", now)
+	rec := postCardWebhook(cfg, app, body, sig)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"user_service_id":901`) {
+		t.Fatalf("%d %s", rec.Code, rec.Body.String())
+	}
+	if len(app.calls) != 1 {
+		t.Fatalf("calls=%d", len(app.calls))
+	}
+	if c := app.calls[0]; c.SessionID != "cs_test_1" || c.UserID != 3381 || c.ServiceID != 7 || c.PublicCode != "premium-1m" || c.AmountCents != 499 || c.PaySystem != "card_checkout" {
+		t.Fatalf("call %+v", c)
+	}
+
+	if rec := postCardWebhook(cfg, app, body, payments.SignCheckoutPayload("other", body, now)); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("bad signature: %d", rec.Code)
+	}
+	other := map[string]string{"brand_id": "fc", "user_id": "3381", "service_id": "7", "public_code": "premium-1m"}
+	body, sig = cardWebhookEvent(t, other, true, "whsec_test", now)
+	if rec := postCardWebhook(cfg, app, body, sig); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "ignored") {
+		t.Fatalf("other brand: %d %s", rec.Code, rec.Body.String())
+	}
+	body, sig = cardWebhookEvent(t, meta, false, "whsec_test", now)
+	if rec := postCardWebhook(cfg, app, body, sig); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "ignored") {
+		t.Fatalf("unpaid: %d %s", rec.Code, rec.Body.String())
+	}
+	if len(app.calls) != 1 {
+		t.Fatalf("ignored events must not fulfill: calls=%d", len(app.calls))
+	}
+
+	body, sig = cardWebhookEvent(t, meta, true, "whsec_test", now)
+	app.err = errors.New("shm down")
+	if rec := postCardWebhook(cfg, app, body, sig); rec.Code != http.StatusInternalServerError {
+		t.Fatalf("shm error must ask for retry: %d", rec.Code)
+	}
+	app.err = nil
+	app.res = &service.CardCheckoutResult{Status: service.CardCheckoutReview, Reason: service.CardCheckoutReasonCurrency}
+	if rec := postCardWebhook(cfg, app, body, sig); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"status":"review"`) || !strings.Contains(rec.Body.String(), "currency_mismatch") {
+		t.Fatalf("review: %d %s", rec.Code, rec.Body.String())
+	}
+
+	cfg.CardCheckout.WebhookSecret = ""
+	if rec := postCardWebhook(cfg, app, body, sig); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("disabled: %d", rec.Code)
+	}
+}
+
+type stubAdminCardCheckouts struct {
+	records map[string]interface{}
+	userID  int
+}
+
+func (s *stubAdminCardCheckouts) CardCheckoutRecords(userID int) (map[string]interface{}, error) {
+	s.userID = userID
+	return s.records, nil
+}
+
+func TestServeAdminCardCheckouts(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Admin.Token = "adm"
+	app := &stubAdminCardCheckouts{records: map[string]interface{}{
+		"cs_1": map[string]interface{}{"status": "ordered", "credited": 450.0},
+		"cs_2": map[string]interface{}{"status": "review", "reason": "currency_mismatch", "paid_currency": "EUR"},
+	}}
+	get := func(target, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("X-Admin-Token", token)
+		rec := httptest.NewRecorder()
+		serveAdminCardCheckouts(cfg, app).ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := get("/api/admin/card-checkouts?user_id=42", "wrong"); rec.Code != http.StatusForbidden {
+		t.Fatalf("token: %d", rec.Code)
+	}
+	if rec := get("/api/admin/card-checkouts?user_id=x", "adm"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("user_id: %d", rec.Code)
+	}
+	rec := get("/api/admin/card-checkouts?user_id=42&status=review", "adm")
+	if rec.Code != http.StatusOK || app.userID != 42 || !strings.Contains(rec.Body.String(), "cs_2") || strings.Contains(rec.Body.String(), "cs_1") {
+		t.Fatalf("%d %s", rec.Code, rec.Body.String())
+	}
+}
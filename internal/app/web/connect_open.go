@@ -0,0 +1,274 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/config"
+	"github.com/ryabkov82/vpnbot/internal/connectapp"
+	"github.com/ryabkov82/vpnbot/internal/infrastructure/remnawave"
+	"github.com/ryabkov82/vpnbot/internal/models"
+	appService "github.com/ryabkov82/vpnbot/internal/service"
+)
+
+//go:embed static/connect/open.html
+var connectOpenTemplateSrc string
+
+var (
+	connectOpenTmplOnce sync.Once
+	connectOpenTmpl     *template.Template
+	connectOpenTmplErr  error
+)
+
+type connectOpenInstallLink struct {
+	Title string
+	URL   string
+}
+
+type connectOpenPageData struct {
+	AppName       string
+	BrandName     string
+	DeepLink      template.URL
+	InstallURL    string
+	OtherInstalls []connectOpenInstallLink
+}
+
+var connectPlatformTitles = map[connectapp.Platform]string{
+	connectapp.PlatformIOS:     "iOS",
+	connectapp.PlatformAndroid: "Android",
+	connectapp.PlatformWindows: "Windows",
+	connectapp.PlatformMacOS:   "macOS",
+	connectapp.PlatformLinux:   "Linux",
+}
+
+var connectPlatformOrder = []connectapp.Platform{
+	connectapp.PlatformIOS, connectapp.PlatformAndroid, connectapp.PlatformWindows,
+	connectapp.PlatformMacOS, connectapp.PlatformLinux,
+}
+
+func connectOpenTemplate() (*template.Template, error) {
+	connectOpenTmplOnce.Do(func() {
+		connectOpenTmpl, connectOpenTmplErr = template.New("connect-open").Parse(connectOpenTemplateSrc)
+	})
+	return connectOpenTmpl, connectOpenTmplErr
+}
+
+// renderConnectOpenHTML — страница открытия приложения. deepLink обязан пройти connectapp.IsAllowedDeepLink:
+// только после этой проверки он помечается template.URL (иначе html/template заменил бы схему на #ZgotmplZ).
+func renderConnectOpenHTML(cfg *config.Config, a connectapp.App, deepLink string, p connectapp.Platform) ([]byte, error) {
+	if !connectapp.IsAllowedDeepLink(deepLink) {
+		return nil, errors.New("connect open: deep link is not allowed")
+	}
+	tmpl, err := connectOpenTemplate()
+	if err != nil {
+		return nil, err
+	}
+	data := connectOpenPageData{
+		AppName:    a.Name,
+		DeepLink:   template.URL(deepLink),
+		InstallURL: a.InstallURL(p),
+	}
+	if cfg != nil {
+		data.BrandName = strings.TrimSpace(cfg.EffectiveBrand().Name)
+	}
+	for _, op := range connectPlatformOrder {
+		if op == p || !a.Supports(op) {
+			continue
+		}
+		data.OtherInstalls = append(data.OtherInstalls, connectOpenInstallLink{Title: connectPlatformTitles[op], URL: a.InstallURL(op)})
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// BuildConnectAppOpenURL — ссылка /connect/open для кнопки «Открыть в приложении» (кабинет и бот).
+// Токен тот же, что у /sub/<token>: deep link собирается на сервере, URL подписки в ссылку не попадает.
+func BuildConnectAppOpenURL(cfg *config.Config, r *http.Request, shmUserID, userServiceID int, appID string) (string, error) {
+	if _, ok := connectapp.ByID(appID); !ok {
+		return "", errors.New("unknown connect app")
+	}
+	base := strings.TrimRight(publicOrderBaseURL(cfg, r), "/")
+	if base == "" {
+		return "", errors.New("public base url is not configured")
+	}
+	tok, err := CreateAccountSubscriptionToken(cfg.WebSales.OrderTokenSecret, cfgBrandID(cfg), shmUserID, userServiceID, accountSubscriptionTokenTTL)
+	if err != nil {
+		return "", err
+	}
+	q := url.Values{}
+	q.Set("app", appID)
+	q.Set("token", tok)
+	return base + "/connect/open?" + q.Encode(), nil
+}
+
+// connectAppSubscriptionURL — URL подписки для импорта в приложение: sing-box получает профиль JSON
+// через /sub/<token>?format=singbox, остальные клиенты — subscription_url Marzban.
+func connectAppSubscriptionURL(cfg *config.Config, r *http.Request, a connectapp.App, shmUserID, userServiceID int, key *models.UserKeyMarzban) (string, error) {
+	if a.SingBoxProfile {
+		return BuildAccountSubscriptionURL(cfg, r, shmUserID, userServiceID, subscriptionFormatSingBox)
+	}
+	if key == nil || strings.TrimSpace(key.SubscriptionURL) == "" {
+		return "", connectapp.ErrEmptySubscription
+	}
+	return strings.TrimSpace(key.SubscriptionURL), nil
+}
+
+// serveConnectOpen — GET /connect/open: универсальная страница открытия клиента.
+//   - ?app=<id>&token=<subscription token> — deep link строится для своей активной Marzban-услуги;
+//   - ?url=<deep link> — готовая ссылка, только happ:// (legacyRedirectApp).
+//
+// Premium anti-block открывается только в приложениях с шифрованным deep link (premiumConnectApps):
+// ссылка строится по подписке Remnawave.
+func serveConnectOpen(cfg *config.Config, app accountServiceKeysApp, rw *remnawave.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/connect/open" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		platform := connectapp.DetectPlatform(r.Header.Get("User-Agent"))
+		q := r.URL.Query()
+
+		var (
+			a        connectapp.App
+			deepLink string
+		)
+		if raw := strings.TrimSpace(q.Get("url")); raw != "" {
+			var ok bool
+			a, ok = legacyRedirectApp(raw)
+			if !ok {
+				http.Error(w, "Invalid app URL", http.StatusBadRequest)
+				return
+			}
+			deepLink = raw
+		} else {
+			var ok bool
+			a, ok = connectapp.ByID(q.Get("app"))
+			if !ok || !webSalesTokenFlowAvailable(cfg) {
+				http.NotFound(w, r)
+				return
+			}
+			link, status, msg := connectOpenDeepLink(cfg, r, app, rw, a, q.Get("token"))
+			if status != http.StatusOK {
+				writeConnectOpenNotice(w, cfg, status, msg)
+				return
+			}
+			deepLink = link
+		}
+
+		writeConnectOpenPage(w, cfg, a, deepLink, platform)
+	}
+}
+
+// legacyRedirectApp — приложение для готовой ссылки ?url=: только happ://, как у прежнего /redirect.html.
+// Готовая ссылка другого клиента несёт произвольный URL подписки, и страница с домена бренда импортировала бы
+// чужую подписку; такие клиенты открываются через ?app=&token=, где ссылку собирает сервер.
+func legacyRedirectApp(raw string) (connectapp.App, bool) {
+	a, ok := connectapp.ForDeepLink(raw)
+	if !ok || a.ID != connectapp.AppHapp {
+		return connectapp.App{}, false
+	}
+	return a, true
+}
+
+func writeConnectOpenPage(w http.ResponseWriter, cfg *config.Config, a connectapp.App, deepLink string, p connectapp.Platform) {
+	body, err := renderConnectOpenHTML(cfg, a, deepLink, p)
+	if err != nil {
+		slog.Error("connect open: render", "err", err, "app", a.ID)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
+
+func connectOpenDeepLink(cfg *config.Config, r *http.Request, app accountServiceKeysApp, rw *remnawave.Client, a connectapp.App, rawTok string) (string, int, string) {
+	const unavailable = "Подключение пока недоступно. Откройте личный кабинет или напишите в поддержку."
+	claims, err := VerifyAccountSubscriptionToken(cfg.WebSales.OrderTokenSecret, cfgBrandID(cfg), rawTok)
+	if err != nil {
+		return "", http.StatusUnauthorized, "Ссылка недействительна или устарела. Откройте её заново из личного кабинета или бота."
+	}
+	usID := strconv.Itoa(claims.UserServiceID)
+	us, err := app.GetOwnedUserServiceByUserID(claims.ShmUserID, usID)
+	if err != nil {
+		if !errors.Is(err, appService.ErrUserServiceUnavailable) {
+			slog.Error("connect open: GetOwnedUserServiceByUserID", "err", err)
+			return "", http.StatusInternalServerError, unavailable
+		}
+		return "", http.StatusForbidden, "Услуга не найдена или недоступна."
+	}
+	if models.IsPremiumAntiBlockUserService(us, cfg.PremiumSquadName) {
+		return premiumConnectOpenDeepLink(r, rw, a, us)
+	}
+	if !strings.EqualFold(strings.TrimSpace(us.Status), "ACTIVE") || !strings.HasPrefix(strings.TrimSpace(us.Category), "vpn-mz-") {
+		return "", http.StatusConflict, unavailable
+	}
+	key, err := app.GetUserKeyMarzbanByUserID(claims.ShmUserID, usID)
+	if err != nil {
+		slog.Error("connect open: GetUserKeyMarzbanByUserID", "err", err, "user_service_id", claims.UserServiceID)
+		return "", http.StatusBadGateway, unavailable
+	}
+	sub, err := connectAppSubscriptionURL(cfg, r, a, claims.ShmUserID, claims.UserServiceID, key)
+	if err != nil {
+		slog.Warn("connect open: subscription url", "err", err, "app", a.ID)
+		return "", http.StatusConflict, unavailable
+	}
+	link, err := a.DeepLink(sub, subscriptionProfileTitle(cfg))
+	if err != nil {
+		slog.Warn("connect open: deep link", "err", err, "app", a.ID)
+		return "", http.StatusBadGateway, unavailable
+	}
+	return link, http.StatusOK, ""
+}
+
+// premiumConnectOpenDeepLink — deep link premium-услуги: только приложения premiumConnectApps, подписка из Remnawave.
+func premiumConnectOpenDeepLink(r *http.Request, rw *remnawave.Client, a connectapp.App, us *models.UserService) (string, int, string) {
+	const unavailable = "Подключение пока недоступно. Откройте личный кабинет или напишите в поддержку."
+	if !a.Encrypted {
+		return "", http.StatusForbidden, "Для этой услуги подходят только приложения с защищённой ссылкой подписки, например Happ."
+	}
+	if !strings.EqualFold(strings.TrimSpace(us.Status), "ACTIVE") || rw == nil {
+		return "", http.StatusConflict, unavailable
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+	defer cancel()
+	link, err := premiumAppLink(ctx, rw, PremiumRemnawaveUsername(us), a)
+	if err != nil {
+		slog.Warn("connect open: premium deep link", "err", err, "app", a.ID, "user_service_id", us.ServiceID)
+		return "", http.StatusBadGateway, unavailable
+	}
+	return link, http.StatusOK, ""
+}
+
+func writeConnectOpenNotice(w http.ResponseWriter, cfg *config.Config, status int, msg string) {
+	body, err := standaloneLinkNoticePage(cfg, "Открытие приложения", msg)
+	if err != nil {
+		http.Error(w, msg, status)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
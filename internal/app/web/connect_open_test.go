@@ -0,0 +1,190 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/connectapp"
+	"github.com/ryabkov82/vpnbot/internal/infrastructure/remnawave"
+)
+
+const androidUA = "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36"
+
+func connectTestStub() *stubAccountKeys {
+	st := subscriptionTestStub()
+	st.single[336].KeyMarzban.SubscriptionURL = "https://mz.example/sub/abc"
+	return st
+}
+
+func connectOpenRequest(t *testing.T, st *stubAccountKeys, query string) *httptest.ResponseRecorder {
+	t.Helper()
+	return connectOpenRequestRW(t, st, nil, query)
+}
+
+func connectOpenRequestRW(t *testing.T, st *stubAccountKeys, rw *remnawave.Client, query string) *httptest.ResponseRecorder {
+	t.Helper()
+	cfg := categoryTestCfg("vpn-mz-main")
+	cfg.PremiumSquadName = "premium-squad"
+	req := httptest.NewRequest(http.MethodGet, "/connect/open?"+query, nil)
+	req.Header.Set("User-Agent", androidUA)
+	rec := httptest.NewRecorder()
+	serveConnectOpen(cfg, st, rw).ServeHTTP(rec, req)
+	return rec
+}
+
+func premiumSubscriptionRW(t *testing.T) *remnawave.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/subscriptions/by-username/us_337" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"response":{"subscriptionUrl":"https://rw.example/sub/xyz"}}`))
+	}))
+	t.Cleanup(srv.Close)
+	return remnawave.NewClient(srv.URL, "tok")
+}
+
+func subToken(t *testing.T, userID, usID int) string {
+	t.Helper()
+	tok, err := CreateAccountSubscriptionToken(categoryTestCfg("").WebSales.OrderTokenSecret, "vff", userID, usID, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tok
+}
+
+func TestServeConnectOpen_URLAllowList(t *testing.T) {
+	rec := connectOpenRequest(t, connectTestStub(), "url="+url.QueryEscape("happ://add/https://x.example/s"))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `href="happ://add/https://x.example/s"`) {
+		t.Fatalf("%d %s", rec.Code, rec.Body.String())
+	}
+	for _, bad := range []string{"javascript:alert(1)", "https://evil.example", "intent://x#Intent;end",
+		"hiddify://import/https://evil.example/s#A", "v2raytun://import/https://evil.example/s", "sing-box://import-remote-profile?url=https://evil.example/s"} {
+		rec := connectOpenRequest(t, connectTestStub(), "url="+url.QueryEscape(bad))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%q: want 400 got %d", bad, rec.Code)
+		}
+	}
+}
+
+func TestServeConnectOpen_TokenBuildsDeepLinkAndInstall(t *testing.T) {
+	rec := connectOpenRequest(t, connectTestStub(), "app=v2raytun&token="+subToken(t, 10, 336))
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%d %s", rec.Code, body)
+	}
+	if !strings.Contains(body, `href="v2raytun://import/https://mz.example/sub/abc"`) {
+		t.Fatalf("deep link missing: %s", body)
+	}
+	if !strings.Contains(body, "play.google.com/store/apps/details?id=com.v2raytun.android") {
+		t.Fatalf("android install link missing: %s", body)
+	}
+	if rec.Header().Get("Referrer-Policy") != "no-referrer" {
+		t.Fatal("referrer policy")
+	}
+}
+
+func TestServeConnectOpen_SingBoxUsesConvertedProfile(t *testing.T) {
+	rec := connectOpenRequest(t, connectTestStub(), "app=singbox&token="+subToken(t, 10, 336))
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.Contains(body, "sing-box://import-remote-profile?url=") ||
+		!strings.Contains(body, url.QueryEscape("/sub/")) || !strings.Contains(body, url.QueryEscape("format=singbox")) {
+		t.Fatalf("%d %s", rec.Code, body)
+	}
+	if strings.Contains(body, "mz.example") {
+		t.Fatalf("sing-box must not get marzban url: %s", body)
+	}
+}
+
+func TestServeConnectOpen_PremiumOnlyEncryptedApps(t *testing.T) {
+	st := connectTestStub()
+	rw := premiumSubscriptionRW(t)
+	rec := connectOpenRequestRW(t, st, rw, "app=happ&token="+subToken(t, 10, 337))
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.Contains(body, `href="happ://crypt4/`) {
+		t.Fatalf("%d %s", rec.Code, body)
+	}
+	if strings.Contains(body, "rw.example") {
+		t.Fatalf("premium subscription url leaked: %s", body)
+	}
+	if rec := connectOpenRequestRW(t, st, rw, "app=hiddify&token="+subToken(t, 10, 337)); rec.Code != http.StatusForbidden {
+		t.Fatalf("premium hiddify: %d", rec.Code)
+	}
+	if rec := connectOpenRequest(t, st, "app=happ&token="+subToken(t, 10, 337)); rec.Code != http.StatusConflict {
+		t.Fatalf("premium without remnawave: %d", rec.Code)
+	}
+	if st.keyCalls != 0 {
+		t.Fatalf("marzban keys fetched for premium: %d", st.keyCalls)
+	}
+}
+
+func TestServeConnectOpen_ForeignRejected(t *testing.T) {
+	st := connectTestStub()
+	if rec := connectOpenRequest(t, st, "app=hiddify&token="+subToken(t, 11, 336)); rec.Code != http.StatusForbidden {
+		t.Fatalf("foreign: %d", rec.Code)
+	}
+	if st.keyCalls != 0 {
+		t.Fatalf("keys fetched: %d", st.keyCalls)
+	}
+	if rec := connectOpenRequest(t, st, "app=unknown&token="+subToken(t, 10, 336)); rec.Code != http.StatusNotFound {
+		t.Fatalf("unknown app: %d", rec.Code)
+	}
+	if rec := connectOpenRequest(t, st, "app=hiddify&token=bad"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("bad token: %d", rec.Code)
+	}
+}
+
+func appsRequest(t *testing.T, usID string) accountServiceAppsOKJSON {
+	t.Helper()
+	cfg := categoryTestCfg("vpn-mz-main")
+	cfg.PremiumSquadName = "premium-squad"
+	cfg.PremiumConnectBaseURL = "https://premium.example/connect"
+	cfg.PremiumLinkSigningSecret = "premium-secret-premium-secret-xx"
+	tok, _ := CreateAccountToken(cfg.WebSales.OrderTokenSecret, "vff", "me@test.com", 10, "web_aa", time.Hour)
+	req := httptest.NewRequest(http.MethodGet, "/api/account/service/apps?token="+tok+"&user_service_id="+usID, nil)
+	req.Header.Set("User-Agent", androidUA)
+	rec := httptest.NewRecorder()
+	serveAccountServiceApps(cfg, connectTestStub()).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%d %s", rec.Code, rec.Body.String())
+	}
+	var out accountServiceAppsOKJSON
+	if err := json.NewDecoder(rec.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestServeAccountServiceApps_FiltersByPlatform(t *testing.T) {
+	out := appsRequest(t, "336")
+	if out.Status != "ok" || out.Platform != "android" || len(out.Apps) == 0 {
+		t.Fatalf("%+v", out)
+	}
+	for _, a := range out.Apps {
+		if a.ID == "streisand" || a.ID == "foxray" {
+			t.Fatalf("ios-only app offered: %+v", a)
+		}
+		if !strings.Contains(a.OpenURL, "/connect/open?app="+a.ID+"&token=") || a.InstallURL == "" {
+			t.Fatalf("%+v", a)
+		}
+	}
+}
+
+func TestServeAccountServiceApps_PremiumEncryptedAppsOnly(t *testing.T) {
+	out := appsRequest(t, "337")
+	if out.Status != "ok" || len(out.Apps) == 0 {
+		t.Fatalf("%+v", out)
+	}
+	for _, a := range out.Apps {
+		app, ok := connectapp.ByID(a.ID)
+		if !ok || !app.Encrypted || !strings.Contains(a.OpenURL, "/connect/open?app="+a.ID+"&token=") {
+			t.Fatalf("%+v", a)
+		}
+	}
+}
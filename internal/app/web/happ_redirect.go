@@ -1,41 +1,33 @@
 package web
 
 import (
-	_ "embed"
 	"net/http"
-	"strconv"
 	"strings"
-)
-
-//go:embed static/premium-connect/redirect.html
-var happRedirectHTML []byte
 
-const happURLSchemePrefix = "happ://"
-
-func isValidHappRedirectTarget(raw string) bool {
-	return strings.HasPrefix(strings.TrimSpace(raw), happURLSchemePrefix)
-}
+	"github.com/ryabkov82/vpnbot/internal/config"
+	"github.com/ryabkov82/vpnbot/internal/connectapp"
+)
 
-func serveHappRedirect(w http.ResponseWriter, r *http.Request) {
-	if r.URL.Path != "/redirect.html" {
-		http.NotFound(w, r)
-		return
-	}
-	if r.Method != http.MethodGet {
-		w.Header().Set("Allow", http.MethodGet)
-		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
-		return
-	}
+// serveAppRedirect — GET /redirect.html?url=<happ:// link>: адрес, на который ведут premium-connect и ранее выданные
+// ссылки. Принимается только happ:// (legacyRedirectApp), страница та же, что у /connect/open?url=.
+func serveAppRedirect(cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/redirect.html" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
 
-	target := strings.TrimSpace(r.URL.Query().Get("url"))
-	if !isValidHappRedirectTarget(target) {
-		http.Error(w, "Invalid Happ URL", http.StatusBadRequest)
-		return
+		target := strings.TrimSpace(r.URL.Query().Get("url"))
+		a, ok := legacyRedirectApp(target)
+		if !ok {
+			http.Error(w, "Invalid app URL", http.StatusBadRequest)
+			return
+		}
+		writeConnectOpenPage(w, cfg, a, target, connectapp.DetectPlatform(r.Header.Get("User-Agent")))
 	}
-
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	w.Header().Set("Cache-Control", "no-store")
-	w.Header().Set("Content-Length", strconv.Itoa(len(happRedirectHTML)))
-	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write(happRedirectHTML)
 }
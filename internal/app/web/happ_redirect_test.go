@@ -3,57 +3,44 @@ package web
 import (
 	"net/http"
 	"net/http/httptest"
-	"os"
-	"os/exec"
-	"regexp"
 	"strconv"
 	"strings"
 	"testing"
 )
 
-func TestServeHappRedirect_Valid(t *testing.T) {
-	rec := httptest.NewRecorder()
-	req := httptest.NewRequest(http.MethodGet, "/redirect.html?url=happ%3A%2F%2Fadd%2Ftest", nil)
-	serveHappRedirect(rec, req)
-	if rec.Code != http.StatusOK {
-		t.Fatalf("code=%d", rec.Code)
-	}
-	if ct := rec.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
-		t.Fatalf("Content-Type=%q", ct)
-	}
-	if cc := rec.Header().Get("Cache-Control"); cc != "no-store" {
-		t.Fatalf("Cache-Control=%q", cc)
-	}
-	body := rec.Body.Bytes()
-	if got := rec.Header().Get("Content-Length"); got != strconv.Itoa(len(body)) {
-		t.Fatalf("Content-Length=%q want %d", got, len(body))
+func TestServeAppRedirect_Valid(t *testing.T) {
+	cases := []struct {
+		target, app string
+	}{
+		{target: "happ%3A%2F%2Fadd%2Ftest", app: "Happ"},
+		{target: "happ%3A%2F%2Fcrypt4%2Fabc", app: "Happ"},
 	}
-	html := string(body)
-	for _, needle := range []string{
-		"<title>Открытие Happ</title>",
-		">Открытие Happ</h1>",
-		"Открываем приложение Happ…",
-		"Открыть Happ",
-		"id=\"open-happ-btn\"",
-		"URLSearchParams(window.location.search)",
-		"happ://",
-		"window.location.replace(target)",
-	} {
-		if !strings.Contains(html, needle) {
-			t.Fatalf("missing %q", needle)
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/redirect.html?url="+tc.target, nil)
+		req.Header.Set("User-Agent", androidUA)
+		serveAppRedirect(categoryTestCfg("")).ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: code=%d", tc.app, rec.Code)
 		}
-	}
-	if strings.Contains(html, "happ://add/test") {
-		t.Fatal("server must not interpolate query target into HTML")
-	}
-	for _, forbid := range []string{"vpn-for-friends.com", "friends-connect.club", "Friends Connect", "VPN for Friends"} {
-		if strings.Contains(html, forbid) {
-			t.Fatalf("helper must stay brand-generic: %q", forbid)
+		if ct := rec.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+			t.Fatalf("Content-Type=%q", ct)
+		}
+		if cc := rec.Header().Get("Cache-Control"); cc != "no-store" {
+			t.Fatalf("Cache-Control=%q", cc)
+		}
+		body := rec.Body.Bytes()
+		if got := rec.Header().Get("Content-Length"); got != strconv.Itoa(len(body)) {
+			t.Fatalf("Content-Length=%q want %d", got, len(body))
+		}
+		html := string(body)
+		if !strings.Contains(html, ">Открытие "+tc.app+"</h1>") || !strings.Contains(html, `id="open-app-btn"`) {
+			t.Fatalf("%s: %s", tc.app, html)
 		}
 	}
 }
 
-func TestServeHappRedirect_InvalidTargets(t *testing.T) {
+func TestServeAppRedirect_InvalidTargets(t *testing.T) {
 	cases := []struct {
 		name string
 		raw  string
@@ -64,28 +51,33 @@ func TestServeHappRedirect_InvalidTargets(t *testing.T) {
 		{name: "javascript", raw: "/redirect.html?url=javascript%3Aalert(1)"},
 		{name: "data", raw: "/redirect.html?url=data%3Atext%2Fhtml%2Ctest"},
 		{name: "happ_single_colon", raw: "/redirect.html?url=happ%3Atest"},
+		{name: "unknown_scheme", raw: "/redirect.html?url=intent%3A%2F%2Fx"},
+		// Готовые ссылки других клиентов несут произвольную подписку — только через /connect/open?app=&token=.
+		{name: "v2raytun", raw: "/redirect.html?url=v2raytun%3A%2F%2Fimport%2Fhttps%3A%2F%2Fevil.example%2Fs"},
+		{name: "hiddify", raw: "/redirect.html?url=hiddify%3A%2F%2Fimport%2Fhttps%3A%2F%2Fevil.example%2Fs"},
 	}
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
 			rec := httptest.NewRecorder()
-			serveHappRedirect(rec, httptest.NewRequest(http.MethodGet, tc.raw, nil))
+			serveAppRedirect(categoryTestCfg("")).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.raw, nil))
 			if rec.Code != http.StatusBadRequest {
 				t.Fatalf("code=%d", rec.Code)
 			}
 			body := rec.Body.String()
-			if !strings.Contains(body, "Invalid Happ URL") {
+			if !strings.Contains(body, "Invalid app URL") {
 				t.Fatalf("body=%q", body)
 			}
-			if strings.Contains(body, "Открытие Happ") || strings.Contains(body, "open-happ-btn") {
+			if strings.Contains(body, "open-app-btn") {
 				t.Fatal("helper HTML must not be served for invalid target")
 			}
 		})
 	}
 }
 
-func TestServeHappRedirect_MethodAndPath(t *testing.T) {
+func TestServeAppRedirect_MethodAndPath(t *testing.T) {
+	h := serveAppRedirect(categoryTestCfg(""))
 	rec := httptest.NewRecorder()
-	serveHappRedirect(rec, httptest.NewRequest(http.MethodPost, "/redirect.html?url=happ%3A%2F%2Fadd%2Ftest", nil))
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/redirect.html?url=happ%3A%2F%2Fadd%2Ftest", nil))
 	if rec.Code != http.StatusMethodNotAllowed {
 		t.Fatalf("POST code=%d", rec.Code)
 	}
@@ -94,33 +86,8 @@ func TestServeHappRedirect_MethodAndPath(t *testing.T) {
 	}
 
 	rec = httptest.NewRecorder()
-	serveHappRedirect(rec, httptest.NewRequest(http.MethodGet, "/redirect.html/extra?url=happ%3A%2F%2Fadd%2Ftest", nil))
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/redirect.html/extra?url=happ%3A%2F%2Fadd%2Ftest", nil))
 	if rec.Code != http.StatusNotFound {
 		t.Fatalf("extra path code=%d", rec.Code)
 	}
 }
-
-func TestHappRedirectInlineJS_NodeCheck(t *testing.T) {
-	node, err := exec.LookPath("node")
-	if err != nil {
-		t.Skip("node not installed")
-	}
-	re := regexp.MustCompile(`(?s)<script>(.*?)</script>`)
-	var parts []string
-	for _, m := range re.FindAllStringSubmatch(string(happRedirectHTML), -1) {
-		if len(m) > 1 && strings.TrimSpace(m[1]) != "" {
-			parts = append(parts, m[1])
-		}
-	}
-	if len(parts) == 0 {
-		t.Fatal("expected inline script")
-	}
-	path := t.TempDir() + "/happ-redirect-inline.js"
-	if err := os.WriteFile(path, []byte(strings.Join(parts, "\n")), 0o644); err != nil {
-		t.Fatal(err)
-	}
-	cmd := exec.Command(node, "--check", path)
-	if out, err := cmd.CombinedOutput(); err != nil {
-		t.Fatalf("node --check: %v\n%s", err, out)
-	}
-}
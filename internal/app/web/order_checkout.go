@@ -0,0 +1,154 @@
+package web
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/config"
+	"github.com/ryabkov82/vpnbot/internal/payments"
+)
+
+const (
+	accountServiceCheckoutPaymentMessage = "После оплаты услуга будет заказана автоматически — статус можно отслеживать на странице возврата из оплаты."
+	accountServiceCheckoutOrderedMessage = "Средств на балансе достаточно — услуга заказана."
+)
+
+// OrderTopupShortfall — сколько пополнить, чтобы хватило на услугу стоимостью cost при балансе balance
+// (с теми же ограничениями 50–10 000 ₽, что у /api/account/service/order). Используется и ботом.
+func OrderTopupShortfall(cost, balance float64) (amount float64, needsTopUp bool, invalid bool) {
+	return accountOrderPaymentFromSHMForecast(cost - balance)
+}
+
+type accountServiceCheckoutReqJSON struct {
+	Token     string `json:"token"`
+	ServiceID int    `json:"service_id"`
+	Provider  string `json:"provider"`
+}
+
+type accountServiceCheckoutOKJSON struct {
+	// Status — ordered (средств хватило, услуга заказана сразу) или payment_required.
+	Status        string  `json:"status"`
+	ServiceID     int     `json:"service_id"`
+	UserServiceID int     `json:"user_service_id,omitempty"`
+	Provider      string  `json:"provider,omitempty"`
+	Amount        float64 `json:"amount"`
+	PaymentURL    string  `json:"payment_url,omitempty"`
+	IntentID      string  `json:"intent_id,omitempty"`
+	Message       string  `json:"message"`
+}
+
+// serveAccountServiceCheckout — POST /api/account/service/checkout: покупка услуги в один шаг.
+// Если баланса не хватает, выдаёт ссылку на доплату недостающей суммы и запоминает намерение заказа;
+// услуга заказывается после зачисления (FulfillOrderIntents), а не сейчас — в SHM не остаётся «NOT PAID» услуг.
+func serveAccountServiceCheckout(cfg *config.Config, app accountWebApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/account/service/checkout" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed")
+			return
+		}
+		if !webSalesTokenFlowAvailable(cfg) {
+			writeJSONError(w, http.StatusNotFound, "not_found")
+			return
+		}
+
+		var req accountServiceCheckoutReqJSON
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "bad_request")
+			return
+		}
+		claims, _, err := authenticateWebAccount(cfg, app, req.Token)
+		if err != nil {
+			writeAccountAuthError(w, err)
+			return
+		}
+		svc, ok := accountOrderableService(w, cfg, app, req.ServiceID)
+		if !ok {
+			return
+		}
+
+		bal, err := app.GetUserBalanceByUserID(claims.UserID)
+		if err != nil || bal == nil {
+			slog.Error("account service checkout: GetUserBalanceByUserID", "user_id", claims.UserID, "err", err)
+			writeJSONError(w, http.StatusInternalServerError, "balance_failed")
+			return
+		}
+		shortfall, needsTopUp, badAmt := OrderTopupShortfall(svc.Cost, bal.Balance)
+		if badAmt {
+			writeJSONError(w, http.StatusBadRequest, "invalid_payment_amount")
+			return
+		}
+
+		if !needsTopUp {
+			order, err := app.ServiceOrderByUserID(claims.UserID, svc.ServiceID)
+			if err != nil || order == nil {
+				slog.Error("account service checkout: ServiceOrderByUserID", "user_id", claims.UserID, "service_id", svc.ServiceID, "err", err)
+				writeJSONError(w, http.StatusInternalServerError, "order_failed")
+				return
+			}
+			writeJSON(w, http.StatusOK, accountServiceCheckoutOKJSON{
+				Status:        "ordered",
+				ServiceID:     svc.ServiceID,
+				UserServiceID: order.ServiceID,
+				Message:       accountServiceCheckoutOrderedMessage,
+			})
+			return
+		}
+
+		reg, err := payments.RegistryFromConfig(cfg)
+		if err != nil {
+			slog.Error("account service checkout: payment registry", "err", err)
+			writeJSONError(w, http.StatusInternalServerError, "payment_url_failed")
+			return
+		}
+		provider, err := accountPaymentProvider(cfg, reg, r, "", req.Provider, resolveAccountLocale(r))
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "unknown_provider")
+			return
+		}
+		amount, ok := payments.FitAmount(provider, shortfall)
+		if !ok {
+			writeJSONError(w, http.StatusBadRequest, "invalid_payment_amount")
+			return
+		}
+
+		ts := time.Now().Unix()
+		paymentURL, err := provider.PaymentURL(payments.PaymentRequest{
+			BaseURL: cfg.API.BaseURL,
+			UserID:  claims.UserID,
+			Amount:  amount,
+			TS:      ts,
+			BrandID: cfg.BrandID(),
+		})
+		if err != nil {
+			slog.Error("account service checkout: payment url", "provider", provider.ID(), "err", err)
+			writeJSONError(w, http.StatusInternalServerError, "payment_url_failed")
+			return
+		}
+		// Без сохранённого намерения автоматического заказа не будет — ссылку в этом случае не отдаём.
+		in, err := app.CreateOrderIntent(claims.UserID, svc.ServiceID, strings.TrimSpace(svc.Name), amount, provider.ID(), cfg.BrandID(), ts)
+		if err != nil || in == nil {
+			slog.Error("account service checkout: CreateOrderIntent", "user_id", claims.UserID, "service_id", svc.ServiceID, "err", err)
+			writeJSONError(w, http.StatusInternalServerError, "order_intent_failed")
+			return
+		}
+
+		writeJSON(w, http.StatusOK, accountServiceCheckoutOKJSON{
+			Status:     "payment_required",
+			ServiceID:  svc.ServiceID,
+			Provider:   provider.ID(),
+			Amount:     amount,
+			PaymentURL: paymentURL,
+			IntentID:   in.ID,
+			Message:    accountServiceCheckoutPaymentMessage,
+		})
+	}
+}
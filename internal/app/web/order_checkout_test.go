@@ -0,0 +1,85 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/models"
+)
+
+func postServiceCheckout(t *testing.T, st *stubAccountWeb, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	cfg := orderStartTestCfg()
+	cfg.API.BaseURL = "https://bill.fix.test"
+	tok, err := CreateAccountToken(cfg.WebSales.OrderTokenSecret, "vff", "u@paid.com", 881, "w881", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	serveAccountServiceCheckout(cfg, st).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/account/service/checkout",
+		strings.NewReader(`{"token":"`+tok+`",`+body+`}`)))
+	return rec
+}
+
+func checkoutStub(balance float64) *stubAccountWeb {
+	return &stubAccountWeb{
+		svcByID: map[int]*models.Service{
+			3: {ServiceID: 3, Name: "1 мес", Cost: 200, Period: 1, AllowToOrder: 1},
+		},
+		serviceOrderRet: &models.UserService{ServiceID: 900, BaseServiceID: 3, Status: "ACTIVE"},
+		balance:         &models.UserBalance{Balance: balance},
+	}
+}
+
+func TestServeAccountServiceCheckout_ShortfallCreatesOrderIntent(t *testing.T) {
+	st := checkoutStub(80)
+	rec := postServiceCheckout(t, st, `"service_id":3`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%d %s", rec.Code, rec.Body.String())
+	}
+	var out accountServiceCheckoutOKJSON
+	if err := json.NewDecoder(rec.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Status != "payment_required" || out.Amount != 120 || out.Provider != "yookassa" || out.IntentID != "pi_1" || !strings.Contains(out.PaymentURL, "bill.fix.test") {
+		t.Fatalf("%+v", out)
+	}
+	if st.serviceOrderCalls != 0 {
+		t.Fatal("service must be ordered only after payment")
+	}
+	if len(st.intentCalls) != 1 || st.intentCalls[0].ServiceID != 3 || st.intentCalls[0].Amount != 120 {
+		t.Fatalf("%+v", st.intentCalls)
+	}
+
+	// Без сохранённого намерения ссылку не отдаём.
+	st = checkoutStub(80)
+	st.intentErr = errors.New("shm down")
+	rec = postServiceCheckout(t, st, `"service_id":3`)
+	if rec.Code != http.StatusInternalServerError || strings.Contains(rec.Body.String(), "payment_url") {
+		t.Fatalf("%d %s", rec.Code, rec.Body.String())
+	}
+	assertJSONErrorField(t, rec.Body.String(), "order_intent_failed")
+}
+
+func TestServeAccountServiceCheckout_EnoughBalanceOrdersNow(t *testing.T) {
+	st := checkoutStub(500)
+	rec := postServiceCheckout(t, st, `"service_id":3`)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"status":"ordered"`) || !strings.Contains(rec.Body.String(), `"user_service_id":900`) {
+		t.Fatalf("%d %s", rec.Code, rec.Body.String())
+	}
+	if st.serviceOrderCalls != 1 || len(st.intentCalls) != 0 {
+		t.Fatalf("orders=%d intents=%d", st.serviceOrderCalls, len(st.intentCalls))
+	}
+
+	if rec := postServiceCheckout(t, checkoutStub(0), `"service_id":4`); rec.Code != http.StatusNotFound {
+		t.Fatalf("unknown service: %d", rec.Code)
+	}
+	if rec := postServiceCheckout(t, checkoutStub(0), `"service_id":3,"provider":"nope"`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("unknown provider: %d", rec.Code)
+	}
+}
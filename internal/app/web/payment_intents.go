@@ -0,0 +1,121 @@
+package web
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/config"
+	"github.com/ryabkov82/vpnbot/internal/models"
+	appService "github.com/ryabkov82/vpnbot/internal/service"
+)
+
+// accountPaymentsPendingWindow — без intent= показываем последнее намерение не старше суток.
+const accountPaymentsPendingWindow = 24 * time.Hour
+
+// recordPaymentIntent сохраняет намерение оплаты для выданной ссылки; сбой не мешает оплате, только пишется в лог.
+func recordPaymentIntent(cfg *config.Config, app accountWebApp, userID int, amount float64, provider string, ts int64) string {
+	in, err := app.CreatePaymentIntent(userID, amount, provider, cfg.BrandID(), ts)
+	if err != nil {
+		slog.Error("payment intent: create", "user_id", userID, "provider", provider, "amount", amount, "err", err)
+		return ""
+	}
+	if in == nil {
+		return ""
+	}
+	return in.ID
+}
+
+type accountPaymentIntentJSON struct {
+	ID         string    `json:"id"`
+	Status     string    `json:"status"`
+	Amount     float64   `json:"amount"`
+	AmountText string    `json:"amount_text"`
+	Provider   string    `json:"provider"`
+	CreatedAt  time.Time `json:"created_at"`
+	CreditedAt string    `json:"credited_at,omitempty"`
+	// Поля намерения заказа (POST /api/account/service/checkout).
+	ServiceID     int    `json:"service_id,omitempty"`
+	ServiceName   string `json:"service_name,omitempty"`
+	OrderStatus   string `json:"order_status,omitempty"`
+	UserServiceID int    `json:"user_service_id,omitempty"`
+}
+
+type accountPaymentsPendingOKJSON struct {
+	// Status — статус выбранного намерения: processing, credited, failed; none — намерений нет.
+	Status  string                     `json:"status"`
+	Intent  *accountPaymentIntentJSON  `json:"intent,omitempty"`
+	Intents []accountPaymentIntentJSON `json:"intents"`
+}
+
+func paymentIntentJSON(in appService.PaymentIntent) accountPaymentIntentJSON {
+	return accountPaymentIntentJSON{
+		ID:         in.ID,
+		Status:     in.Status,
+		Amount:     in.Amount,
+		AmountText: models.FormatRubAmount(in.Amount),
+		Provider:   in.Provider,
+		CreatedAt:  in.CreatedAt,
+		CreditedAt: in.CreditedAt,
+
+		ServiceID:     in.ServiceID,
+		ServiceName:   in.ServiceName,
+		OrderStatus:   in.OrderStatus,
+		UserServiceID: in.UserServiceID,
+	}
+}
+
+// serveAccountPaymentsPending — GET /api/account/payments/pending?token=…[&intent=…]: статус оплаты по ссылкам,
+// выданным кабинетом и ботом (сопоставление с платежами SHM по сумме и времени). Оплаченные намерения заказа
+// здесь же доводятся до заказа услуги — страница возврата не ждёт фонового прохода бота.
+func serveAccountPaymentsPending(cfg *config.Config, app accountWebApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/account/payments/pending" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed")
+			return
+		}
+		if !webSalesTokenFlowAvailable(cfg) {
+			writeJSONError(w, http.StatusNotFound, "not_found")
+			return
+		}
+
+		raw := strings.TrimSpace(r.URL.Query().Get("token"))
+		claims, _, err := authenticateWebAccount(cfg, app, raw)
+		if err != nil {
+			writeAccountAuthError(w, err)
+			return
+		}
+
+		list, err := app.FulfillOrderIntents(claims.UserID)
+		if err != nil {
+			slog.Error("account payments pending: FulfillOrderIntents", "user_id", claims.UserID, "err", err)
+			writeJSONError(w, http.StatusInternalServerError, "payments_failed")
+			return
+		}
+
+		out := accountPaymentsPendingOKJSON{Status: "none", Intents: make([]accountPaymentIntentJSON, 0, len(list))}
+		for _, in := range list {
+			out.Intents = append(out.Intents, paymentIntentJSON(in))
+		}
+		want := strings.TrimSpace(r.URL.Query().Get("intent"))
+		for i := range out.Intents {
+			in := &out.Intents[i]
+			if want != "" && in.ID != want {
+				continue
+			}
+			if want == "" && time.Since(in.CreatedAt) > accountPaymentsPendingWindow {
+				continue
+			}
+			out.Intent, out.Status = in, in.Status
+			break
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, http.StatusOK, out)
+	}
+}
@@ -0,0 +1,98 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	appService "github.com/ryabkov82/vpnbot/internal/service"
+)
+
+func getPaymentsPending(t *testing.T, st *stubAccountWeb, query string) *httptest.ResponseRecorder {
+	t.Helper()
+	cfg := orderStartTestCfg()
+	tok, err := CreateAccountToken(cfg.WebSales.OrderTokenSecret, "vff", "a@b.c", 701, "web_xx", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	serveAccountPaymentsPending(cfg, st).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/account/payments/pending?token="+tok+query, nil))
+	return rec
+}
+
+func TestServeAccountPaymentsPending(t *testing.T) {
+	now := time.Now().UTC()
+	st := &stubAccountWeb{intents: []appService.PaymentIntent{
+		{ID: "new", Amount: 300, Provider: "yookassa", CreatedAt: now, Status: appService.PaymentIntentProcessing},
+		{ID: "old", Amount: 150, Provider: "card", CreatedAt: now.Add(-2 * time.Hour), Status: appService.PaymentIntentCredited, CreditedAt: "2026-10-18 10:00:00"},
+	}}
+
+	rec := getPaymentsPending(t, st, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%d %s", rec.Code, rec.Body.String())
+	}
+	var out accountPaymentsPendingOKJSON
+	if err := json.NewDecoder(rec.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Status != "processing" || out.Intent == nil || out.Intent.ID != "new" || len(out.Intents) != 2 {
+		t.Fatalf("%+v", out)
+	}
+
+	rec = getPaymentsPending(t, st, "&intent=old")
+	if !strings.Contains(rec.Body.String(), `"status":"credited"`) || !strings.Contains(rec.Body.String(), `"amount_text":"150 ₽"`) {
+		t.Fatalf("by id: %s", rec.Body.String())
+	}
+	if rec := getPaymentsPending(t, &stubAccountWeb{}, ""); !strings.Contains(rec.Body.String(), `"status":"none"`) {
+		t.Fatalf("empty: %s", rec.Body.String())
+	}
+
+	rec = getPaymentsPending(t, &stubAccountWeb{intentsErr: errors.New("shm down")}, "")
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("error: %d", rec.Code)
+	}
+	assertJSONErrorField(t, rec.Body.String(), "payments_failed")
+}
+
+func TestServeAccountBalanceTopup_RecordsPaymentIntent(t *testing.T) {
+	cfg := orderStartTestCfg()
+	cfg.API.BaseURL = "https://bill.fix.test"
+	tok, err := CreateAccountToken(cfg.WebSales.OrderTokenSecret, "vff", "a@b.c", 701, "web_xx", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := &stubAccountWeb{}
+	rec := httptest.NewRecorder()
+	serveAccountBalanceTopup(cfg, st).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/account/balance/topup",
+		strings.NewReader(`{"token":"`+tok+`","amount":150}`)))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"intent_id":"pi_1"`) {
+		t.Fatalf("%d %s", rec.Code, rec.Body.String())
+	}
+	if len(st.intentCalls) != 1 || st.intentCalls[0].Amount != 150 || st.intentCalls[0].Provider != "yookassa" || st.intentCalls[0].BrandID != "vff" {
+		t.Fatalf("%+v", st.intentCalls)
+	}
+
+	// Сбой записи намерения не мешает выдать ссылку на оплату.
+	st = &stubAccountWeb{intentErr: errors.New("shm down")}
+	rec = httptest.NewRecorder()
+	serveAccountBalanceTopup(cfg, st).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/account/balance/topup",
+		strings.NewReader(`{"token":"`+tok+`","amount":150}`)))
+	if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "intent_id") {
+		t.Fatalf("%d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestServePaymentReturn_PollsPendingStatus(t *testing.T) {
+	rec := httptest.NewRecorder()
+	servePaymentReturn(orderStartTestCfg()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/payment/return", nil))
+	body := rec.Body.String()
+	for _, want := range []string{"/api/account/payments/pending", `id="pay-status-text"`, "vff_account_token"} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("missing %q", want)
+		}
+	}
+}
@@ -34,8 +34,9 @@ func paymentReturnPageTemplate() (*template.Template, error) {
 	return paymentReturnPageTmpl, paymentReturnPageTmplErr
 }
 
-// servePaymentReturn — публичная страница возврата из YooKassa (без web-сессии).
-// Не утверждает, что платёж подтверждён или зачислен.
+// servePaymentReturn — публичная страница возврата из платёжной системы (без web-сессии).
+// Сам HTML не утверждает, что платёж зачислен: статус подгружает скрипт из /api/account/payments/pending
+// по токену кабинета, если он сохранён в браузере.
 func servePaymentReturn(cfg *config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		switch r.URL.Path {
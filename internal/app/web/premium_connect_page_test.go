@@ -196,6 +196,9 @@ func TestPremiumConnect_RoutesRegression(t *testing.T) {
 		"service_id",
 		"access_token",
 		`const redirectBase = '/redirect.html';`,
+		`id="svc-other-apps"`,
+		"applyOtherApps(happ.j.apps)",
+		"el.href = a.link;",
 	}
 
 	for _, path := range []string{
@@ -0,0 +1,191 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/config"
+	"github.com/ryabkov82/vpnbot/internal/infrastructure/remnawave"
+	"github.com/ryabkov82/vpnbot/internal/models"
+)
+
+type premiumDeviceJSON struct {
+	HWID        string `json:"hwid"`
+	Platform    string `json:"platform,omitempty"`
+	OSVersion   string `json:"os_version,omitempty"`
+	DeviceModel string `json:"device_model,omitempty"`
+	UserAgent   string `json:"user_agent,omitempty"`
+	CreatedAt   string `json:"created_at,omitempty"`
+}
+
+type premiumDevicesJSON struct {
+	ServiceID       int                 `json:"service_id"`
+	HWIDDeviceLimit int                 `json:"hwid_device_limit"`
+	Total           int                 `json:"total"`
+	Devices         []premiumDeviceJSON `json:"devices"`
+}
+
+type premiumDevicesDeleteRequest struct {
+	HWID string `json:"hwid"`
+	All  bool   `json:"all"`
+}
+
+// PremiumRemnawaveUsername — username пользователя Remnawave для услуги SHM (us_<user_service_id>).
+func PremiumRemnawaveUsername(us *models.UserService) string {
+	return fmt.Sprintf("us_%d", us.ServiceID)
+}
+
+func premiumDevicesResponse(us *models.UserService, limit int, devs []remnawave.HWIDDevice) premiumDevicesJSON {
+	out := premiumDevicesJSON{
+		ServiceID:       us.ServiceID,
+		HWIDDeviceLimit: limit,
+		Total:           len(devs),
+		Devices:         make([]premiumDeviceJSON, 0, len(devs)),
+	}
+	for _, d := range devs {
+		j := premiumDeviceJSON{
+			HWID:        d.HWID,
+			Platform:    d.Platform,
+			OSVersion:   d.OSVersion,
+			DeviceModel: d.DeviceModel,
+			UserAgent:   d.UserAgent,
+		}
+		if !d.CreatedAt.IsZero() {
+			j.CreatedAt = d.CreatedAt.UTC().Format(time.RFC3339)
+		}
+		out.Devices = append(out.Devices, j)
+	}
+	return out
+}
+
+// loadPremiumDevicesTarget — общая часть /api/premium/devices*: владение услугой по access_token,
+// проверка premium и поиск пользователя Remnawave us_<id>; возвращает лимит HWID из конфигурации услуги.
+// При ошибке ответ уже записан.
+func loadPremiumDevicesTarget(ctx context.Context, w http.ResponseWriter, r *http.Request, cfg *config.Config, app premiumAPIApp, rw *remnawave.Client, logPrefix string) (*models.UserService, int, *remnawave.User, bool) {
+	us, ok := loadPremiumUserServiceForRequest(w, r, cfg, app)
+	if !ok {
+		return nil, 0, nil, false
+	}
+
+	top, err := us.ParseTopConfig()
+	if err != nil {
+		log.Printf("%s ParseTopConfig: %v", logPrefix, err)
+		writeJSONError(w, http.StatusInternalServerError, "internal error")
+		return nil, 0, nil, false
+	}
+	if !models.UserServiceTopConfigIsPremium(top, cfg.PremiumSquadName) {
+		writePremiumForbidden(w)
+		return nil, 0, nil, false
+	}
+
+	if rw == nil {
+		writeJSONError(w, http.StatusServiceUnavailable, "remnawave unavailable")
+		return nil, 0, nil, false
+	}
+
+	username := PremiumRemnawaveUsername(us)
+	user, err := rw.GetUserByUsername(ctx, username)
+	if err != nil {
+		log.Printf("%s remnawave user %s: %v", logPrefix, username, err)
+		writeJSONError(w, http.StatusBadGateway, "devices unavailable")
+		return nil, 0, nil, false
+	}
+	return us, top.Remnawave.HWIDDeviceLimit, user, true
+}
+
+// servePremiumDevices — GET /api/premium/devices: устройства (HWID), занимающие слоты premium-услуги.
+func servePremiumDevices(cfg *config.Config, app premiumAPIApp, rw *remnawave.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/premium/devices" {
+			http.NotFound(w, r)
+			return
+		}
+
+		log.Printf("api/premium/devices: %s %s", r.Method, r.URL.Path)
+
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+		defer cancel()
+
+		us, limit, user, ok := loadPremiumDevicesTarget(ctx, w, r, cfg, app, rw, "api/premium/devices")
+		if !ok {
+			return
+		}
+
+		devs, err := rw.GetUserHWIDDevices(ctx, *user)
+		if err != nil {
+			log.Printf("api/premium/devices remnawave hwid user=%s: %v", user.Username, err)
+			writeJSONError(w, http.StatusBadGateway, "devices unavailable")
+			return
+		}
+
+		writeJSON(w, http.StatusOK, premiumDevicesResponse(us, limit, devs))
+	}
+}
+
+// servePremiumDevicesDelete — POST /api/premium/devices/delete: {"hwid":"..."} освобождает один слот,
+// {"all":true} — все. Ответ — оставшиеся устройства.
+func servePremiumDevicesDelete(cfg *config.Config, app premiumAPIApp, rw *remnawave.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/premium/devices/delete" {
+			http.NotFound(w, r)
+			return
+		}
+
+		log.Printf("api/premium/devices/delete: %s %s", r.Method, r.URL.Path)
+
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+
+		var body premiumDevicesDeleteRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&body); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+		body.HWID = strings.TrimSpace(body.HWID)
+		if body.All == (body.HWID != "") {
+			writeJSONError(w, http.StatusBadRequest, "hwid or all required")
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+		defer cancel()
+
+		us, limit, user, ok := loadPremiumDevicesTarget(ctx, w, r, cfg, app, rw, "api/premium/devices/delete")
+		if !ok {
+			return
+		}
+
+		var (
+			devs []remnawave.HWIDDevice
+			err  error
+		)
+		if body.All {
+			err = rw.DeleteAllUserHWIDDevices(ctx, *user)
+		} else {
+			devs, err = rw.DeleteUserHWIDDevice(ctx, *user, body.HWID)
+		}
+		if err != nil {
+			log.Printf("api/premium/devices/delete remnawave user=%s all=%t: %v", user.Username, body.All, err)
+			writeJSONError(w, http.StatusBadGateway, "devices unavailable")
+			return
+		}
+
+		log.Printf("api/premium/devices/delete ok user=%s all=%t", user.Username, body.All)
+
+		writeJSON(w, http.StatusOK, premiumDevicesResponse(us, limit, devs))
+	}
+}
@@ -0,0 +1,179 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/config"
+	"github.com/ryabkov82/vpnbot/internal/infrastructure/remnawave"
+	"github.com/ryabkov82/vpnbot/internal/models"
+	appService "github.com/ryabkov82/vpnbot/internal/service"
+)
+
+const premiumDevicesTestSecret = "premium-secret-premium-secret-xx"
+
+type stubPremiumApp struct {
+	userID   int
+	services map[int]*models.UserService
+}
+
+func (s stubPremiumApp) GetUser(int64) (*models.User, error) {
+	return &models.User{ID: s.userID}, nil
+}
+
+func (s stubPremiumApp) GetUserByID(id int) (*models.User, error) {
+	return &models.User{ID: id}, nil
+}
+
+func (s stubPremiumApp) GetOwnedUserServiceByUserID(userID int, usID string) (*models.UserService, error) {
+	id, _ := strconv.Atoi(usID)
+	if us, ok := s.services[id]; ok && userID == s.userID {
+		return us, nil
+	}
+	return nil, appService.ErrUserServiceUnavailable
+}
+
+func premiumDevicesTestCfg() *config.Config {
+	return &config.Config{PremiumSquadName: "premium-squad", PremiumLinkSigningSecret: premiumDevicesTestSecret}
+}
+
+func premiumDevicesTestApp() stubPremiumApp {
+	return stubPremiumApp{userID: 7, services: map[int]*models.UserService{
+		501: {ServiceID: 501, Status: "ACTIVE", ConfigRaw: `{"remnawave":{"internal_squad_name":"premium-squad","hwid_device_limit":3}}`},
+		502: {ServiceID: 502, Status: "ACTIVE", ConfigRaw: `{"remnawave":{"internal_squad_name":"basic-squad"}}`},
+	}}
+}
+
+func premiumDevicesQuery(t *testing.T, serviceID int) string {
+	t.Helper()
+	tok, err := CreatePremiumSHMAccessToken(premiumDevicesTestSecret, 7, serviceID, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := url.Values{}
+	q.Set("service_id", strconv.Itoa(serviceID))
+	q.Set("access_token", tok)
+	return q.Encode()
+}
+
+func newPremiumDevicesRemnawave(t *testing.T, deleted *[]string) *remnawave.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/users/by-username/us_501":
+			_, _ = w.Write([]byte(`{"response":{"id":9,"uuid":"u-501","username":"us_501"}}`))
+		case "/api/hwid/devices/u-501":
+			_, _ = w.Write([]byte(`{"response":{"total":2,"devices":[{"hwid":"hw-a","platform":"Android","deviceModel":"Pixel 8","createdAt":"2026-09-01T10:00:00Z"},{"hwid":"hw-b","platform":"Windows"}]}}`))
+		case "/api/hwid/devices/delete":
+			var body map[string]any
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			*deleted = append(*deleted, body["userUuid"].(string)+"/"+body["hwid"].(string))
+			_, _ = w.Write([]byte(`{"response":{"total":1,"devices":[{"hwid":"hw-b","platform":"Windows"}]}}`))
+		case "/api/hwid/devices/delete-all":
+			*deleted = append(*deleted, "all")
+			_, _ = w.Write([]byte(`{"response":{"total":0,"devices":[]}}`))
+		default:
+			t.Fatalf("unexpected %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return remnawave.NewClient(srv.URL, "tok")
+}
+
+func TestServePremiumDevices_List(t *testing.T) {
+	var deleted []string
+	h := servePremiumDevices(premiumDevicesTestCfg(), premiumDevicesTestApp(), newPremiumDevicesRemnawave(t, &deleted))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/premium/devices?"+premiumDevicesQuery(t, 501), nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("code %d body %s", rec.Code, rec.Body.String())
+	}
+	var got premiumDevicesJSON
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ServiceID != 501 || got.HWIDDeviceLimit != 3 || got.Total != 2 || len(got.Devices) != 2 {
+		t.Fatalf("%+v", got)
+	}
+	if got.Devices[0].HWID != "hw-a" || got.Devices[0].DeviceModel != "Pixel 8" || got.Devices[0].CreatedAt != "2026-09-01T10:00:00Z" {
+		t.Fatalf("%+v", got.Devices[0])
+	}
+}
+
+func TestServePremiumDevices_Forbidden(t *testing.T) {
+	var deleted []string
+	rw := newPremiumDevicesRemnawave(t, &deleted)
+	h := servePremiumDevices(premiumDevicesTestCfg(), premiumDevicesTestApp(), rw)
+
+	// Не premium-услуга.
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/premium/devices?"+premiumDevicesQuery(t, 502), nil))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("non-premium: code %d", rec.Code)
+	}
+
+	// Токен выписан на другую услугу.
+	tok, _ := CreatePremiumSHMAccessToken(premiumDevicesTestSecret, 7, 502, time.Hour)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/premium/devices?service_id=501&access_token="+url.QueryEscape(tok), nil))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("foreign token: code %d", rec.Code)
+	}
+}
+
+func TestServePremiumDevices_NoRemnawave(t *testing.T) {
+	h := servePremiumDevices(premiumDevicesTestCfg(), premiumDevicesTestApp(), nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/premium/devices?"+premiumDevicesQuery(t, 501), nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("code %d", rec.Code)
+	}
+}
+
+func TestServePremiumDevicesDelete(t *testing.T) {
+	var deleted []string
+	h := servePremiumDevicesDelete(premiumDevicesTestCfg(), premiumDevicesTestApp(), newPremiumDevicesRemnawave(t, &deleted))
+	target := "/api/premium/devices/delete?" + premiumDevicesQuery(t, 501)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, target, strings.NewReader(`{"hwid":"hw-a"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("code %d body %s", rec.Code, rec.Body.String())
+	}
+	var got premiumDevicesJSON
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Total != 1 || got.Devices[0].HWID != "hw-b" {
+		t.Fatalf("%+v", got)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, target, strings.NewReader(`{"all":true}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("all: code %d", rec.Code)
+	}
+	if len(deleted) != 2 || deleted[0] != "u-501/hw-a" || deleted[1] != "all" {
+		t.Fatalf("deleted %v", deleted)
+	}
+
+	for _, body := range []string{`{}`, `{"hwid":"x","all":true}`, `not json`} {
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, target, strings.NewReader(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: code %d", body, rec.Code)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != "POST" {
+		t.Fatalf("GET: code %d", rec.Code)
+	}
+}
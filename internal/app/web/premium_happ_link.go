@@ -2,13 +2,13 @@ package web
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"log"
 	"net/http"
 	"time"
 
 	"github.com/ryabkov82/vpnbot/internal/config"
-	"github.com/ryabkov82/vpnbot/internal/infrastructure/happ"
+	"github.com/ryabkov82/vpnbot/internal/connectapp"
 	"github.com/ryabkov82/vpnbot/internal/infrastructure/remnawave"
 	"github.com/ryabkov82/vpnbot/internal/models"
 )
@@ -53,17 +53,30 @@ func servePremiumHappLink(cfg *config.Config, app premiumAPIApp, rw *remnawave.C
 		ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
 		defer cancel()
 
-		username := fmt.Sprintf("us_%d", us.ServiceID)
+		username := PremiumRemnawaveUsername(us)
 		sub, err := rw.GetSubscriptionByUsername(ctx, username)
 		if err != nil {
-			log.Printf("api/premium/happ-link remnawave subscription user=%s: %v", username, err)
+			log.Printf("api/premium/happ-link user=%s: %v", username, err)
 			writeJSONError(w, http.StatusBadGateway, "failed to build happ link")
 			return
 		}
-
-		enc, err := happ.CreateCrypt4Link(sub.SubscriptionURL)
-		if err != nil {
-			log.Printf("api/premium/happ-link happ crypt4 user=%s: %v", username, err)
+		platform := connectapp.DetectPlatform(r.Header.Get("User-Agent"))
+		var enc string
+		apps := []premiumAppLinkJSON{}
+		for _, a := range premiumConnectApps(connectapp.PlatformUnknown) {
+			link, err := a.DeepLink(sub.SubscriptionURL, "")
+			if err != nil {
+				log.Printf("api/premium/happ-link user=%s app=%s: %v", username, a.ID, err)
+				continue
+			}
+			if a.ID == connectapp.AppHapp {
+				enc = link
+			}
+			if platform == connectapp.PlatformUnknown || a.Supports(platform) {
+				apps = append(apps, premiumAppLinkJSON{ID: a.ID, Name: a.Name, Link: link, InstallURL: a.InstallURL(platform)})
+			}
+		}
+		if enc == "" {
 			writeJSONError(w, http.StatusBadGateway, "failed to build happ link")
 			return
 		}
@@ -73,6 +86,38 @@ func servePremiumHappLink(cfg *config.Config, app premiumAPIApp, rw *remnawave.C
 		writeJSON(w, http.StatusOK, map[string]any{
 			"happ_link_available": true,
 			"happ_link":           enc,
+			"apps":                apps,
 		})
 	}
 }
+
+type premiumAppLinkJSON struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Link       string `json:"link"`
+	InstallURL string `json:"install_url,omitempty"`
+}
+
+// premiumConnectApps — приложения реестра, которым можно отдать premium-подписку: deep link шифруется
+// (connectapp.App.Encrypted), URL подписки Remnawave в ссылке не раскрывается.
+func premiumConnectApps(p connectapp.Platform) []connectapp.App {
+	var out []connectapp.App
+	for _, a := range connectapp.ForPlatform(p) {
+		if a.Encrypted {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// premiumAppLink — deep link приложения по текущей подписке us_<id>; только для premiumConnectApps.
+func premiumAppLink(ctx context.Context, rw *remnawave.Client, username string, a connectapp.App) (string, error) {
+	if !a.Encrypted {
+		return "", errors.New("premium: app deep link is not encrypted")
+	}
+	sub, err := rw.GetSubscriptionByUsername(ctx, username)
+	if err != nil {
+		return "", err
+	}
+	return a.DeepLink(sub.SubscriptionURL, "")
+}
@@ -0,0 +1,328 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/config"
+	"github.com/ryabkov82/vpnbot/internal/connectapp"
+	"github.com/ryabkov82/vpnbot/internal/infrastructure/remnawave"
+	"github.com/ryabkov82/vpnbot/internal/models"
+)
+
+// PremiumResetAction — самостоятельное действие пользователя над premium-подпиской.
+type PremiumResetAction string
+
+const (
+	// PremiumResetSubscription — новый short UUID в Remnawave; старая ссылка Happ перестаёт работать.
+	PremiumResetSubscription PremiumResetAction = "subscription"
+	// PremiumResetTraffic — обнуление использованного трафика (только если тариф разрешает).
+	PremiumResetTraffic PremiumResetAction = "traffic"
+)
+
+const (
+	premiumSubscriptionResetCooldown = 24 * time.Hour
+	premiumTrafficResetCooldown      = 7 * 24 * time.Hour
+)
+
+var (
+	ErrPremiumResetUnknownAction = errors.New("premium reset: unknown action")
+	ErrPremiumResetNotAllowed    = errors.New("premium reset: not allowed by plan")
+	ErrPremiumResetUnavailable   = errors.New("premium reset: remnawave unavailable")
+)
+
+// PremiumResetCooldownError — повтор раньше, чем позволяет cooldown.
+type PremiumResetCooldownError struct {
+	Action  PremiumResetAction
+	RetryAt time.Time
+}
+
+func (e *PremiumResetCooldownError) Error() string {
+	return fmt.Sprintf("premium reset %s: cooldown until %s", e.Action, e.RetryAt.UTC().Format(time.RFC3339))
+}
+
+// ParsePremiumResetAction разбирает action из запроса или callback data.
+func ParsePremiumResetAction(s string) (PremiumResetAction, error) {
+	switch PremiumResetAction(strings.ToLower(strings.TrimSpace(s))) {
+	case PremiumResetSubscription:
+		return PremiumResetSubscription, nil
+	case PremiumResetTraffic:
+		return PremiumResetTraffic, nil
+	}
+	return "", ErrPremiumResetUnknownAction
+}
+
+func (a PremiumResetAction) cooldown() time.Duration {
+	if a == PremiumResetTraffic {
+		return premiumTrafficResetCooldown
+	}
+	return premiumSubscriptionResetCooldown
+}
+
+// PremiumTrafficResetAllowed — тариф с лимитом трафика и флагом remnawave.traffic_reset_allowed.
+func PremiumTrafficResetAllowed(top models.UserServiceTopConfig) bool {
+	return top.Remnawave.TrafficResetAllowed && top.Remnawave.TrafficLimitBytes > 0
+}
+
+// premiumResetCooldowns — последние сбросы по услуге. Основной источник — subRevokedAt / lastTrafficResetAt
+// из Remnawave (переживают рестарт); локальная отметка закрывает двойные нажатия и версии API без этих полей.
+type premiumResetCooldowns struct {
+	mu      sync.Mutex
+	last    map[string]time.Time
+	nowFunc func() time.Time
+}
+
+func newPremiumResetCooldowns() *premiumResetCooldowns {
+	return &premiumResetCooldowns{last: map[string]time.Time{}, nowFunc: time.Now}
+}
+
+func (c *premiumResetCooldowns) now() time.Time {
+	if c.nowFunc != nil {
+		return c.nowFunc()
+	}
+	return time.Now()
+}
+
+func premiumResetKey(a PremiumResetAction, userServiceID int) string {
+	return string(a) + ":" + strconv.Itoa(userServiceID)
+}
+
+// reserve занимает слот до вызова API; при отказе возвращает время, когда можно повторить.
+func (c *premiumResetCooldowns) reserve(key string, remote time.Time, cooldown time.Duration) (prev time.Time, retryAt time.Time, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	prev = c.last[key]
+	last := prev
+	if remote.After(last) {
+		last = remote
+	}
+	if !last.IsZero() && now.Before(last.Add(cooldown)) {
+		return prev, last.Add(cooldown), false
+	}
+	c.last[key] = now
+	return prev, now.Add(cooldown), true
+}
+
+// release возвращает отметку, если вызов API не удался.
+func (c *premiumResetCooldowns) release(key string, prev time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if prev.IsZero() {
+		delete(c.last, key)
+		return
+	}
+	c.last[key] = prev
+}
+
+// premiumResetGuard общий для бота и web: оба работают в одном процессе.
+var premiumResetGuard = newPremiumResetCooldowns()
+
+// PremiumResetInput — кто и откуда запросил сброс (для аудита).
+type PremiumResetInput struct {
+	Action PremiumResetAction
+	Source string // "bot" | "web"
+	IP     string
+}
+
+// PremiumResetResult — итог сброса; HappLink заполнен после сброса подписки.
+type PremiumResetResult struct {
+	Action        PremiumResetAction
+	HappLink      string
+	NextAllowedAt time.Time
+}
+
+// premiumHappLink — crypt4-ссылка Happ по текущей подписке us_<id>.
+func premiumHappLink(ctx context.Context, rw *remnawave.Client, username string) (string, error) {
+	h, _ := connectapp.ByID(connectapp.AppHapp)
+	return premiumAppLink(ctx, rw, username, h)
+}
+
+// RunPremiumReset — общий сценарий сброса для бота и web: проверка тарифа и cooldown, вызов Remnawave,
+// запись аудита и уведомление поддержки. Владение услугой проверяет вызывающий.
+func RunPremiumReset(ctx context.Context, cfg *config.Config, rw *remnawave.Client, us *models.UserService, in PremiumResetInput) (*PremiumResetResult, error) {
+	if _, err := ParsePremiumResetAction(string(in.Action)); err != nil {
+		return nil, err
+	}
+	top, err := us.ParseTopConfig()
+	if err != nil || !models.UserServiceTopConfigIsPremium(top, cfg.PremiumSquadName) {
+		return nil, ErrPremiumResetNotAllowed
+	}
+	if in.Action == PremiumResetTraffic && !PremiumTrafficResetAllowed(top) {
+		return nil, ErrPremiumResetNotAllowed
+	}
+	if rw == nil {
+		return nil, ErrPremiumResetUnavailable
+	}
+
+	username := PremiumRemnawaveUsername(us)
+	user, err := rw.GetUserByUsername(ctx, username)
+	if err != nil {
+		return nil, fmt.Errorf("remnawave user %s: %w", username, err)
+	}
+	remote := user.SubRevokedAt
+	if in.Action == PremiumResetTraffic {
+		remote = user.LastTrafficResetAt
+	}
+	key := premiumResetKey(in.Action, us.ServiceID)
+	prev, next, ok := premiumResetGuard.reserve(key, remote, in.Action.cooldown())
+	if !ok {
+		return nil, &PremiumResetCooldownError{Action: in.Action, RetryAt: next}
+	}
+
+	res := &PremiumResetResult{Action: in.Action, NextAllowedAt: next}
+	var revoked *remnawave.Subscription
+	switch in.Action {
+	case PremiumResetSubscription:
+		if revoked, err = rw.RevokeUserSubscription(ctx, *user); err != nil {
+			premiumResetGuard.release(key, prev)
+			return nil, fmt.Errorf("remnawave revoke %s: %w", username, err)
+		}
+	case PremiumResetTraffic:
+		if err := rw.ResetUserTraffic(ctx, *user); err != nil {
+			premiumResetGuard.release(key, prev)
+			return nil, fmt.Errorf("remnawave reset traffic %s: %w", username, err)
+		}
+	}
+
+	slog.Info("premium reset audit",
+		"action", string(in.Action),
+		"source", in.Source,
+		"brand_id", cfgBrandID(cfg),
+		"shm_user_id", us.UserID,
+		"user_service_id", us.ServiceID,
+		"remnawave_user", username,
+		"ip", in.IP,
+	)
+	premiumResetTelegramNotifier(cfg, us, in)
+
+	if in.Action == PremiumResetSubscription {
+		// Ссылка пересобирается из новой подписки: старая crypt4-ссылка после revoke недействительна.
+		var link string
+		if revoked != nil && revoked.SubscriptionURL != "" {
+			h, _ := connectapp.ByID(connectapp.AppHapp)
+			link, err = h.DeepLink(revoked.SubscriptionURL, "")
+		} else {
+			link, err = premiumHappLink(ctx, rw, username)
+		}
+		if err != nil {
+			slog.Warn("premium reset: rebuild happ link", "user_service_id", us.ServiceID, "err", err)
+		} else {
+			res.HappLink = link
+		}
+	}
+	return res, nil
+}
+
+// premiumResetTelegramNotifier сообщает поддержке о самостоятельном сбросе. Подменяется в тестах.
+var premiumResetTelegramNotifier = sendPremiumResetTelegramImpl
+
+func sendPremiumResetTelegramImpl(cfg *config.Config, us *models.UserService, in PremiumResetInput) {
+	var b strings.Builder
+	if in.Action == PremiumResetTraffic {
+		b.WriteString("♻️ Premium: пользователь сбросил трафик\n\n")
+	} else {
+		b.WriteString("🔄 Premium: пользователь сбросил ссылку подписки\n\n")
+	}
+	b.WriteString("SHM user_id: ")
+	b.WriteString(strconv.Itoa(us.UserID))
+	b.WriteString("\nuser_service_id: ")
+	b.WriteString(strconv.Itoa(us.ServiceID))
+	b.WriteString("\nУслуга: ")
+	b.WriteString(strings.TrimSpace(us.Name))
+	b.WriteString("\nИсточник: ")
+	b.WriteString(in.Source)
+	if ip := strings.TrimSpace(in.IP); ip != "" {
+		b.WriteString("\nIP: ")
+		b.WriteString(ip)
+	}
+	postTelegramPlainTextMessageToChat(cfg, resolveSupportNotificationChatID(cfg), b.String(), "premium reset")
+}
+
+type premiumResetRequest struct {
+	Action  string `json:"action"`
+	Confirm bool   `json:"confirm"`
+}
+
+// servePremiumReset — POST /api/premium/reset {"action":"subscription"|"traffic","confirm":true}.
+// Без confirm=true ничего не сбрасывается: подтверждение показывает страница premium-connect.
+func servePremiumReset(cfg *config.Config, app premiumAPIApp, rw *remnawave.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/premium/reset" {
+			http.NotFound(w, r)
+			return
+		}
+
+		log.Printf("api/premium/reset: %s %s", r.Method, r.URL.Path)
+
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+
+		var body premiumResetRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&body); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+		action, err := ParsePremiumResetAction(body.Action)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid action")
+			return
+		}
+		if !body.Confirm {
+			writeJSONError(w, http.StatusBadRequest, "confirmation required")
+			return
+		}
+
+		us, ok := loadPremiumUserServiceForRequest(w, r, cfg, app)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+		defer cancel()
+
+		res, err := RunPremiumReset(ctx, cfg, rw, us, PremiumResetInput{Action: action, Source: "web", IP: ClientIPFromRequest(r)})
+		if err != nil {
+			var cd *PremiumResetCooldownError
+			switch {
+			case errors.As(err, &cd):
+				w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(cd.RetryAt).Seconds())+1))
+				writeJSON(w, http.StatusTooManyRequests, map[string]string{
+					"error":    "cooldown",
+					"retry_at": cd.RetryAt.UTC().Format(time.RFC3339),
+				})
+			case errors.Is(err, ErrPremiumResetNotAllowed):
+				writePremiumForbidden(w)
+			case errors.Is(err, ErrPremiumResetUnavailable):
+				writeJSONError(w, http.StatusServiceUnavailable, "remnawave unavailable")
+			default:
+				log.Printf("api/premium/reset service_id=%d action=%s: %v", us.ServiceID, action, err)
+				writeJSONError(w, http.StatusBadGateway, "reset failed")
+			}
+			return
+		}
+
+		resp := map[string]any{
+			"status":          "ok",
+			"action":          string(res.Action),
+			"next_allowed_at": res.NextAllowedAt.UTC().Format(time.RFC3339),
+		}
+		if res.Action == PremiumResetSubscription {
+			resp["happ_link_available"] = res.HappLink != ""
+			resp["happ_link"] = res.HappLink
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
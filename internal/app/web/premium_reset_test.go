@@ -0,0 +1,142 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/config"
+	"github.com/ryabkov82/vpnbot/internal/infrastructure/remnawave"
+	"github.com/ryabkov82/vpnbot/internal/models"
+)
+
+func premiumResetTestApp() stubPremiumApp {
+	return stubPremiumApp{userID: 7, services: map[int]*models.UserService{
+		501: {ServiceID: 501, UserID: 7, Name: "Premium", Status: "ACTIVE", ConfigRaw: `{"remnawave":{"internal_squad_name":"premium-squad","traffic_limit_bytes":1000,"traffic_reset_allowed":true}}`},
+		503: {ServiceID: 503, UserID: 7, Status: "ACTIVE", ConfigRaw: `{"remnawave":{"internal_squad_name":"premium-squad","traffic_limit_bytes":1000}}`},
+	}}
+}
+
+func newPremiumResetRemnawave(t *testing.T, userJSON string, calls *[]string) *remnawave.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/api/users/by-username/"):
+			name := strings.TrimPrefix(r.URL.Path, "/api/users/by-username/")
+			_, _ = w.Write([]byte(strings.ReplaceAll(userJSON, "{username}", name)))
+		case strings.HasSuffix(r.URL.Path, "/actions/revoke"):
+			*calls = append(*calls, "revoke")
+			_, _ = w.Write([]byte(`{"response":{"subscriptionUrl":"https://sub.example/new"}}`))
+		case strings.HasSuffix(r.URL.Path, "/actions/reset-traffic"):
+			*calls = append(*calls, "reset-traffic")
+			_, _ = w.Write([]byte(`{"response":{}}`))
+		default:
+			t.Fatalf("unexpected %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return remnawave.NewClient(srv.URL, "tok")
+}
+
+func stubPremiumResetNotifier(t *testing.T) *[]PremiumResetAction {
+	t.Helper()
+	var got []PremiumResetAction
+	prevNotifier, prevGuard := premiumResetTelegramNotifier, premiumResetGuard
+	premiumResetTelegramNotifier = func(_ *config.Config, _ *models.UserService, in PremiumResetInput) {
+		got = append(got, in.Action)
+	}
+	premiumResetGuard = newPremiumResetCooldowns()
+	t.Cleanup(func() { premiumResetTelegramNotifier, premiumResetGuard = prevNotifier, prevGuard })
+	return &got
+}
+
+func postPremiumReset(t *testing.T, h http.HandlerFunc, serviceID int, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/premium/reset?"+premiumDevicesQuery(t, serviceID), strings.NewReader(body)))
+	return rec
+}
+
+func TestServePremiumReset_SubscriptionRegeneratesHappLink(t *testing.T) {
+	notified := stubPremiumResetNotifier(t)
+	var calls []string
+	rw := newPremiumResetRemnawave(t, `{"response":{"id":9,"uuid":"u-501","username":"{username}"}}`, &calls)
+	h := servePremiumReset(premiumDevicesTestCfg(), premiumResetTestApp(), rw)
+
+	rec := postPremiumReset(t, h, 501, `{"action":"subscription","confirm":true}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("code %d body %s", rec.Code, rec.Body.String())
+	}
+	var got map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got["happ_link_available"] != true || !strings.HasPrefix(got["happ_link"].(string), "happ://crypt4/") {
+		t.Fatalf("%v", got)
+	}
+	if len(calls) != 1 || calls[0] != "revoke" || len(*notified) != 1 {
+		t.Fatalf("calls=%v notified=%v", calls, *notified)
+	}
+
+	// Повтор сразу — cooldown, Remnawave не вызывается.
+	rec = postPremiumReset(t, h, 501, `{"action":"subscription","confirm":true}`)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("repeat: code %d", rec.Code)
+	}
+	if len(calls) != 1 {
+		t.Fatalf("calls=%v", calls)
+	}
+}
+
+func TestServePremiumReset_RemoteCooldown(t *testing.T) {
+	stubPremiumResetNotifier(t)
+	var calls []string
+	recent := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	rw := newPremiumResetRemnawave(t, `{"response":{"id":9,"uuid":"u-501","username":"{username}","lastTrafficResetAt":"`+recent+`"}}`, &calls)
+	h := servePremiumReset(premiumDevicesTestCfg(), premiumResetTestApp(), rw)
+
+	rec := postPremiumReset(t, h, 501, `{"action":"traffic","confirm":true}`)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("code %d body %s", rec.Code, rec.Body.String())
+	}
+	if len(calls) != 0 {
+		t.Fatalf("calls=%v", calls)
+	}
+	// Сброс подписки считается отдельно.
+	if rec := postPremiumReset(t, h, 501, `{"action":"subscription","confirm":true}`); rec.Code != http.StatusOK {
+		t.Fatalf("subscription: code %d", rec.Code)
+	}
+}
+
+func TestServePremiumReset_TrafficRequiresPlan(t *testing.T) {
+	stubPremiumResetNotifier(t)
+	var calls []string
+	rw := newPremiumResetRemnawave(t, `{"response":{"id":9,"uuid":"u-503","username":"{username}"}}`, &calls)
+	h := servePremiumReset(premiumDevicesTestCfg(), premiumResetTestApp(), rw)
+
+	if rec := postPremiumReset(t, h, 503, `{"action":"traffic","confirm":true}`); rec.Code != http.StatusForbidden {
+		t.Fatalf("code %d", rec.Code)
+	}
+	if rec := postPremiumReset(t, h, 501, `{"action":"traffic","confirm":true}`); rec.Code != http.StatusOK {
+		t.Fatalf("allowed plan: code %d body %s", rec.Code, rec.Body.String())
+	}
+	if len(calls) != 1 || calls[0] != "reset-traffic" {
+		t.Fatalf("calls=%v", calls)
+	}
+}
+
+func TestServePremiumReset_BadRequests(t *testing.T) {
+	stubPremiumResetNotifier(t)
+	h := servePremiumReset(premiumDevicesTestCfg(), premiumResetTestApp(), nil)
+	for _, body := range []string{`{"action":"subscription"}`, `{"action":"all","confirm":true}`, `{`} {
+		if rec := postPremiumReset(t, h, 501, body); rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: code %d", body, rec.Code)
+		}
+	}
+	if rec := postPremiumReset(t, h, 501, `{"action":"subscription","confirm":true}`); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("no remnawave: code %d", rec.Code)
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"log"
 	"net/http"
 	"strconv"
@@ -30,6 +29,7 @@ type premiumServiceJSON struct {
 	TrafficUsedBytes      int64  `json:"traffic_used_bytes"`
 	TrafficUsedHuman      string `json:"traffic_used_human"`
 	TrafficUsedPercent    int    `json:"traffic_used_percent"`
+	TrafficResetAllowed   bool   `json:"traffic_reset_allowed"`
 }
 
 func writeJSON(w http.ResponseWriter, status int, v any) {
@@ -146,7 +146,7 @@ func applyRemnawaveUsage(ctx context.Context, rw *remnawave.Client, us *models.U
 		return
 	}
 
-	username := fmt.Sprintf("us_%d", us.ServiceID)
+	username := PremiumRemnawaveUsername(us)
 	user, err := rw.GetUserByUsername(ctx, username)
 	if err != nil {
 		log.Printf("api/premium/service remnawave user %s: %v", username, err)
@@ -214,6 +214,7 @@ func servePremiumService(cfg *config.Config, app premiumAPIApp, rw *remnawave.Cl
 			TrafficUsedBytes:      0,
 			TrafficUsedHuman:      "",
 			TrafficUsedPercent:    0,
+			TrafficResetAllowed:   PremiumTrafficResetAllowed(top),
 		}
 
 		ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
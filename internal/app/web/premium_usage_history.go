@@ -0,0 +1,238 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/config"
+	"github.com/ryabkov82/vpnbot/internal/infrastructure/remnawave"
+	"github.com/ryabkov82/vpnbot/internal/models"
+)
+
+const (
+	// PremiumUsageHistoryDefaultDays — интервал истории по умолчанию (?days не задан).
+	PremiumUsageHistoryDefaultDays = 30
+	premiumUsageHistoryMaxDays     = 90
+	premiumUsageHistoryTopNodes    = 5
+	// premiumUsageHistoryTTL — посуточные данные меняются медленно; кэш снимает повторные запросы
+	// при обновлении страницы и нажатиях в боте.
+	premiumUsageHistoryTTL = 5 * time.Minute
+)
+
+// ErrPremiumUsageHistoryUnavailable — Remnawave не настроен.
+var ErrPremiumUsageHistoryUnavailable = errors.New("premium usage history unavailable")
+
+// PremiumUsageDay — трафик за сутки (UTC, как в bandwidth-stats).
+type PremiumUsageDay struct {
+	Date  string `json:"date"`
+	Bytes int64  `json:"bytes"`
+}
+
+// PremiumUsageNode — трафик через ноду за весь интервал.
+type PremiumUsageNode struct {
+	Name        string `json:"name"`
+	CountryCode string `json:"country_code,omitempty"`
+	Bytes       int64  `json:"bytes"`
+	Human       string `json:"human"`
+}
+
+// PremiumUsageHistory — ответ /api/premium/usage/history; Series содержит ровно Days суток, пропуски — нули.
+type PremiumUsageHistory struct {
+	ServiceID  int                `json:"service_id"`
+	Days       int                `json:"days"`
+	From       string             `json:"from"`
+	To         string             `json:"to"`
+	TotalBytes int64              `json:"total_bytes"`
+	TotalHuman string             `json:"total_human"`
+	Series     []PremiumUsageDay  `json:"series"`
+	Nodes      []PremiumUsageNode `json:"nodes"`
+}
+
+// ParsePremiumUsageHistoryDays — значение ?days: пусто — по умолчанию, иначе 1..90 (больше — обрезается).
+func ParsePremiumUsageHistoryDays(raw string) (int, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return PremiumUsageHistoryDefaultDays, true
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 {
+		return 0, false
+	}
+	if n > premiumUsageHistoryMaxDays {
+		n = premiumUsageHistoryMaxDays
+	}
+	return n, true
+}
+
+type premiumUsageHistoryEntry struct {
+	h       *PremiumUsageHistory
+	expires time.Time
+}
+
+// premiumUsageHistoryCache — кэш по username:days, общий для web и бота (один процесс).
+type premiumUsageHistoryCache struct {
+	mu      sync.Mutex
+	entries map[string]premiumUsageHistoryEntry
+	ttl     time.Duration
+	nowFunc func() time.Time
+}
+
+func newPremiumUsageHistoryCache(ttl time.Duration) *premiumUsageHistoryCache {
+	return &premiumUsageHistoryCache{entries: map[string]premiumUsageHistoryEntry{}, ttl: ttl, nowFunc: time.Now}
+}
+
+func (c *premiumUsageHistoryCache) now() time.Time {
+	if c.nowFunc != nil {
+		return c.nowFunc()
+	}
+	return time.Now()
+}
+
+func (c *premiumUsageHistoryCache) get(key string) (*PremiumUsageHistory, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !c.now().Before(e.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return e.h, true
+}
+
+func (c *premiumUsageHistoryCache) put(key string, h *PremiumUsageHistory) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	for k, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = premiumUsageHistoryEntry{h: h, expires: now.Add(c.ttl)}
+}
+
+var premiumUsageHistoryStore = newPremiumUsageHistoryCache(premiumUsageHistoryTTL)
+
+// LoadPremiumUsageHistory — посуточный трафик premium-услуги за последние days суток (включая сегодня, UTC)
+// и топ нод. Ответ кэшируется на premiumUsageHistoryTTL; премиальность услуги проверяет вызывающий.
+func LoadPremiumUsageHistory(ctx context.Context, rw *remnawave.Client, us *models.UserService, days int) (*PremiumUsageHistory, error) {
+	if rw == nil {
+		return nil, ErrPremiumUsageHistoryUnavailable
+	}
+	if days < 1 {
+		days = PremiumUsageHistoryDefaultDays
+	}
+	if days > premiumUsageHistoryMaxDays {
+		days = premiumUsageHistoryMaxDays
+	}
+	username := PremiumRemnawaveUsername(us)
+	key := username + ":" + strconv.Itoa(days)
+	if h, ok := premiumUsageHistoryStore.get(key); ok {
+		return h, nil
+	}
+
+	user, err := rw.GetUserByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	end := premiumUsageHistoryStore.now().UTC()
+	start := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, -(days - 1))
+	raw, err := rw.GetUserBandwidthHistory(ctx, *user, start, end, premiumUsageHistoryTopNodes)
+	if err != nil {
+		return nil, err
+	}
+
+	h := buildPremiumUsageHistory(us.ServiceID, start, days, raw)
+	premiumUsageHistoryStore.put(key, h)
+	return h, nil
+}
+
+// buildPremiumUsageHistory раскладывает ответ Remnawave по сетке дней [start, start+days).
+func buildPremiumUsageHistory(serviceID int, start time.Time, days int, raw *remnawave.UserBandwidthHistory) *PremiumUsageHistory {
+	byDate := make(map[string]int64, len(raw.Days))
+	for _, d := range raw.Days {
+		byDate[d.Date] += d.Bytes
+	}
+	h := &PremiumUsageHistory{
+		ServiceID: serviceID,
+		Days:      days,
+		Series:    make([]PremiumUsageDay, 0, days),
+		Nodes:     make([]PremiumUsageNode, 0, len(raw.Nodes)),
+	}
+	for i := 0; i < days; i++ {
+		date := start.AddDate(0, 0, i).Format("2006-01-02")
+		b := byDate[date]
+		h.Series = append(h.Series, PremiumUsageDay{Date: date, Bytes: b})
+		h.TotalBytes += b
+	}
+	h.From = h.Series[0].Date
+	h.To = h.Series[len(h.Series)-1].Date
+	h.TotalHuman = BytesHumanRu(h.TotalBytes)
+	for _, n := range raw.Nodes {
+		h.Nodes = append(h.Nodes, PremiumUsageNode{Name: n.Name, CountryCode: n.CountryCode, Bytes: n.Bytes, Human: BytesHumanRu(n.Bytes)})
+	}
+	return h
+}
+
+// servePremiumUsageHistory — GET /api/premium/usage/history?days=30: посуточный трафик и разбивка по нодам.
+func servePremiumUsageHistory(cfg *config.Config, app premiumAPIApp, rw *remnawave.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/premium/usage/history" {
+			http.NotFound(w, r)
+			return
+		}
+
+		log.Printf("api/premium/usage/history: %s %s", r.Method, r.URL.Path)
+
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+
+		days, ok := ParsePremiumUsageHistoryDays(r.URL.Query().Get("days"))
+		if !ok {
+			writeJSONError(w, http.StatusBadRequest, "invalid days")
+			return
+		}
+
+		us, ok := loadPremiumUserServiceForRequest(w, r, cfg, app)
+		if !ok {
+			return
+		}
+		top, err := us.ParseTopConfig()
+		if err != nil {
+			log.Printf("api/premium/usage/history ParseTopConfig: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		if !models.UserServiceTopConfigIsPremium(top, cfg.PremiumSquadName) {
+			writePremiumForbidden(w)
+			return
+		}
+		if rw == nil {
+			writeJSONError(w, http.StatusServiceUnavailable, "remnawave unavailable")
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+		defer cancel()
+
+		h, err := LoadPremiumUsageHistory(ctx, rw, us, days)
+		if err != nil {
+			log.Printf("api/premium/usage/history us=%d: %v", us.ServiceID, err)
+			writeJSONError(w, http.StatusBadGateway, "usage unavailable")
+			return
+		}
+		writeJSON(w, http.StatusOK, h)
+	}
+}
@@ -0,0 +1,102 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/infrastructure/remnawave"
+)
+
+func newPremiumUsageHistoryRemnawave(t *testing.T, hits *int) *remnawave.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/api/users/by-username/"):
+			name := strings.TrimPrefix(r.URL.Path, "/api/users/by-username/")
+			_, _ = w.Write([]byte(`{"response":{"id":9,"uuid":"u-9","username":"` + name + `"}}`))
+		case r.URL.Path == "/api/bandwidth-stats/users/u-9":
+			*hits++
+			if r.URL.Query().Get("start") != "2026-10-16" || r.URL.Query().Get("end") != "2026-10-18" {
+				t.Fatalf("range %s", r.URL.RawQuery)
+			}
+			_, _ = w.Write([]byte(`{"response":{"categories":["2026-10-16","2026-10-18"],"sparklineData":[1024,2048],
+				"topNodes":[{"name":"NL-1","countryCode":"NL","total":3072}]}}`))
+		default:
+			t.Fatalf("unexpected %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return remnawave.NewClient(srv.URL, "tok")
+}
+
+func stubPremiumUsageHistoryStore(t *testing.T, now time.Time) *premiumUsageHistoryCache {
+	t.Helper()
+	prev := premiumUsageHistoryStore
+	premiumUsageHistoryStore = newPremiumUsageHistoryCache(premiumUsageHistoryTTL)
+	premiumUsageHistoryStore.nowFunc = func() time.Time { return now }
+	t.Cleanup(func() { premiumUsageHistoryStore = prev })
+	return premiumUsageHistoryStore
+}
+
+func TestServePremiumUsageHistory_FillsDaysAndCaches(t *testing.T) {
+	stubPremiumUsageHistoryStore(t, time.Date(2026, 10, 18, 15, 0, 0, 0, time.UTC))
+	var hits int
+	h := servePremiumUsageHistory(premiumDevicesTestCfg(), premiumDevicesTestApp(), newPremiumUsageHistoryRemnawave(t, &hits))
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/premium/usage/history?days=3&"+premiumDevicesQuery(t, 501), nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("code %d body %s", rec.Code, rec.Body.String())
+		}
+		var got PremiumUsageHistory
+		if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Days != 3 || len(got.Series) != 3 || got.Series[1].Bytes != 0 || got.Series[2].Bytes != 2048 {
+			t.Fatalf("%+v", got)
+		}
+		if got.From != "2026-10-16" || got.To != "2026-10-18" || got.TotalBytes != 3072 || len(got.Nodes) != 1 || got.Nodes[0].CountryCode != "NL" {
+			t.Fatalf("%+v", got)
+		}
+	}
+	if hits != 1 {
+		t.Fatalf("remnawave hits=%d, want cached", hits)
+	}
+}
+
+func TestPremiumUsageHistoryCacheExpires(t *testing.T) {
+	now := time.Date(2026, 10, 18, 15, 0, 0, 0, time.UTC)
+	c := newPremiumUsageHistoryCache(time.Minute)
+	c.nowFunc = func() time.Time { return now }
+	c.put("us_1:30", &PremiumUsageHistory{ServiceID: 1})
+	if _, ok := c.get("us_1:30"); !ok {
+		t.Fatal("expected hit")
+	}
+	now = now.Add(time.Minute)
+	if _, ok := c.get("us_1:30"); ok {
+		t.Fatal("expected expiry")
+	}
+}
+
+func TestServePremiumUsageHistory_BadRequests(t *testing.T) {
+	stubPremiumUsageHistoryStore(t, time.Now())
+	h := servePremiumUsageHistory(premiumDevicesTestCfg(), premiumDevicesTestApp(), nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/premium/usage/history?days=0&"+premiumDevicesQuery(t, 501), nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("days=0: code %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/premium/usage/history?"+premiumDevicesQuery(t, 501), nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("no remnawave: code %d", rec.Code)
+	}
+	if n, ok := ParsePremiumUsageHistoryDays("365"); !ok || n != premiumUsageHistoryMaxDays {
+		t.Fatalf("clamp: %d %v", n, ok)
+	}
+}
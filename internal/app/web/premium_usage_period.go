@@ -69,3 +69,39 @@ func PremiumBandwidthQueryRange(us *models.UserService, trafficLimitStrategy str
 
 	return periodStart.UTC(), endLocal.UTC(), true
 }
+
+// premiumNoResetEpoch — начало отсчёта для NO_RESET, если ручного сброса не было.
+var premiumNoResetEpoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// PremiumTrafficPeriod возвращает текущий период лимита трафика [start, end] в UTC по traffic_limit_strategy,
+// как его обнуляет Remnawave: DAY — сутки UTC, WEEK — неделя с понедельника UTC, MONTH — оплаченный период SHM
+// (см. PremiumBandwidthQueryRange), NO_RESET — без обнуления. Ручной сброс трафика (lastReset) внутри периода
+// начинает его заново. ok=false — стратегия не поддерживается или период не определить.
+func PremiumTrafficPeriod(us *models.UserService, trafficLimitStrategy string, lastReset, now time.Time) (startUTC, endUTC time.Time, ok bool) {
+	now = now.UTC()
+	switch strings.TrimSpace(strings.ToUpper(trafficLimitStrategy)) {
+	case "DAY":
+		startUTC = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		endUTC = now
+	case "WEEK":
+		day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		startUTC = day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+		endUTC = now
+	case "MONTH":
+		startUTC, endUTC, ok = PremiumBandwidthQueryRange(us, trafficLimitStrategy, now)
+		if !ok {
+			return time.Time{}, time.Time{}, false
+		}
+	case "NO_RESET":
+		startUTC, endUTC = premiumNoResetEpoch, now
+	default:
+		return time.Time{}, time.Time{}, false
+	}
+	if lastReset.After(startUTC) && lastReset.Before(endUTC) {
+		startUTC = lastReset.UTC()
+	}
+	if !endUTC.After(startUTC) {
+		return time.Time{}, time.Time{}, false
+	}
+	return startUTC, endUTC, true
+}
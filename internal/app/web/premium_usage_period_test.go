@@ -71,3 +71,31 @@ func TestPremiumBandwidthQueryRangeEmptyWindow(t *testing.T) {
 		t.Fatal("expected !ok when now before period start (end before start after cap)")
 	}
 }
+
+func TestPremiumTrafficPeriodStrategies(t *testing.T) {
+	us := &models.UserService{Expire: "2026-05-19 22:00:00", Period: "1.0000"}
+	now := time.Date(2026, 5, 15, 12, 0, 0, 0, time.UTC) // пятница
+
+	cases := []struct {
+		strategy  string
+		lastReset time.Time
+		wantStart time.Time
+	}{
+		{"DAY", time.Time{}, time.Date(2026, 5, 15, 0, 0, 0, 0, time.UTC)},
+		{"week", time.Time{}, time.Date(2026, 5, 11, 0, 0, 0, 0, time.UTC)},
+		{"MONTH", time.Time{}, time.Date(2026, 4, 19, 19, 0, 0, 0, time.UTC)},
+		{"MONTH", time.Date(2026, 5, 1, 8, 0, 0, 0, time.UTC), time.Date(2026, 5, 1, 8, 0, 0, 0, time.UTC)},
+		{"NO_RESET", time.Time{}, premiumNoResetEpoch},
+		// Сброс до начала периода не влияет.
+		{"DAY", time.Date(2026, 5, 14, 8, 0, 0, 0, time.UTC), time.Date(2026, 5, 15, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		start, end, ok := PremiumTrafficPeriod(us, c.strategy, c.lastReset, now)
+		if !ok || !start.Equal(c.wantStart) || !end.Equal(now) {
+			t.Fatalf("%s reset=%v: start=%v end=%v ok=%v", c.strategy, c.lastReset, start, end, ok)
+		}
+	}
+	if _, _, ok := PremiumTrafficPeriod(us, "", time.Time{}, now); ok {
+		t.Fatal("empty strategy must not be ok")
+	}
+}
@@ -38,6 +38,8 @@ type publicServiceJSON struct {
 
 type publicServicesListJSON struct {
 	Services []publicServiceJSON `json:"services"`
+	// CardCheckout — в кабинете доступна оплата картой по international-цене (только /api/account/catalog/services).
+	CardCheckout bool `json:"card_checkout,omitempty"`
 }
 
 // buildPublicServiceRowsFromList — публичные поля тарифов (BuildServicePreview), trial из cfg исключается.
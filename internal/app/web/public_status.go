@@ -0,0 +1,271 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/config"
+	"github.com/ryabkov82/vpnbot/internal/infrastructure/remnawave"
+)
+
+// Состояние локации и сервиса в целом (/api/public/status).
+const (
+	PublicStatusOnline   = "online"
+	PublicStatusDegraded = "degraded"
+	PublicStatusOffline  = "offline"
+
+	PublicStatusOperational = "operational"
+	PublicStatusOutage      = "outage"
+)
+
+// ErrPublicStatusUnavailable — Remnawave не настроен.
+var ErrPublicStatusUnavailable = errors.New("public status: remnawave unavailable")
+
+// PublicStatusLocation — локация (одна или несколько нод с одним названием из status_page.locations).
+type PublicStatusLocation struct {
+	Name        string `json:"name"`
+	CountryCode string `json:"country_code,omitempty"`
+	Status      string `json:"status"`
+	NodesOnline int    `json:"nodes_online"`
+	NodesTotal  int    `json:"nodes_total"`
+	UsersOnline int    `json:"users_online"`
+}
+
+// PublicStatus — снимок статуса серверов без внутренних имён нод и адресов.
+type PublicStatus struct {
+	Status    string                 `json:"status"`
+	UpdatedAt time.Time              `json:"updated_at"`
+	Locations []PublicStatusLocation `json:"locations"`
+}
+
+// buildPublicStatus группирует ноды по локациям. Выключенные вручную ноды (обслуживание) не показываются;
+// нода с остановленным xray считается недоступной.
+func buildPublicStatus(sp config.StatusPage, nodes []remnawave.Node, now time.Time) *PublicStatus {
+	byName := map[string]*PublicStatusLocation{}
+	for _, n := range nodes {
+		if n.IsDisabled {
+			continue
+		}
+		name, ok := sp.Location(n.Name)
+		if !ok {
+			continue
+		}
+		loc := byName[name]
+		if loc == nil {
+			loc = &PublicStatusLocation{Name: name}
+			byName[name] = loc
+		}
+		if loc.CountryCode == "" {
+			loc.CountryCode = n.CountryCode
+		}
+		loc.NodesTotal++
+		if n.Online() && n.IsXrayRunning {
+			loc.NodesOnline++
+			loc.UsersOnline += n.UsersOnline
+		}
+	}
+
+	out := &PublicStatus{Status: PublicStatusOperational, UpdatedAt: now.UTC(), Locations: make([]PublicStatusLocation, 0, len(byName))}
+	offline := 0
+	for _, loc := range byName {
+		switch {
+		case loc.NodesOnline == loc.NodesTotal:
+			loc.Status = PublicStatusOnline
+		case loc.NodesOnline == 0:
+			loc.Status = PublicStatusOffline
+			offline++
+		default:
+			loc.Status = PublicStatusDegraded
+		}
+		if loc.Status != PublicStatusOnline {
+			out.Status = PublicStatusDegraded
+		}
+		out.Locations = append(out.Locations, *loc)
+	}
+	if len(out.Locations) > 0 && offline == len(out.Locations) {
+		out.Status = PublicStatusOutage
+	}
+	sort.Slice(out.Locations, func(i, j int) bool { return out.Locations[i].Name < out.Locations[j].Name })
+	return out
+}
+
+// publicStatusCache — последний снимок статуса. Запрос к панели идёт без mutex, но одновременно только один
+// (refreshing), чтобы волна запросов /status не превращалась в волну запросов к панели.
+type publicStatusCache struct {
+	mu   sync.Mutex
+	snap *PublicStatus
+	at   time.Time
+	// refreshing — идёт обновление; канал закрывается по его завершении.
+	refreshing chan struct{}
+	nowFunc    func() time.Time
+}
+
+func (c *publicStatusCache) now() time.Time {
+	if c.nowFunc != nil {
+		return c.nowFunc()
+	}
+	return time.Now()
+}
+
+var publicStatusStore = &publicStatusCache{}
+
+// LoadPublicStatus — статус серверов из кэша (status_page.cache_seconds) или из Remnawave GET /api/nodes.
+// Пока идёт обновление или если панель не ответила, отдаётся последний снимок со своим updated_at;
+// ошибка возвращается, только когда снимка ещё нет.
+func LoadPublicStatus(ctx context.Context, cfg *config.Config, rw *remnawave.Client) (*PublicStatus, error) {
+	if rw == nil || cfg == nil {
+		return nil, ErrPublicStatusUnavailable
+	}
+	c := publicStatusStore
+	for {
+		c.mu.Lock()
+		now := c.now()
+		snap := c.snap
+		if snap != nil && now.Sub(c.at) < cfg.StatusPage.CacheTTL() {
+			c.mu.Unlock()
+			return snap, nil
+		}
+		if wait := c.refreshing; wait != nil {
+			c.mu.Unlock()
+			if snap != nil {
+				return snap, nil
+			}
+			select {
+			case <-wait:
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		done := make(chan struct{})
+		c.refreshing = done
+		c.mu.Unlock()
+
+		nodes, err := rw.GetNodes(ctx)
+
+		c.mu.Lock()
+		c.refreshing = nil
+		close(done)
+		if err == nil {
+			c.snap, c.at = buildPublicStatus(cfg.StatusPage, nodes, now), now
+			snap = c.snap
+		}
+		c.mu.Unlock()
+		if err != nil {
+			if snap == nil {
+				return nil, err
+			}
+			log.Printf("api/public/status: refresh failed, serving snapshot from %s: %v", snap.UpdatedAt.Format(time.RFC3339), err)
+		}
+		return snap, nil
+	}
+}
+
+// servePublicStatus — GET /api/public/status.
+func servePublicStatus(cfg *config.Config, rw *remnawave.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/public/status" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		if rw == nil {
+			writeJSONError(w, http.StatusServiceUnavailable, "remnawave unavailable")
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+		defer cancel()
+		st, err := LoadPublicStatus(ctx, cfg, rw)
+		if err != nil {
+			log.Printf("api/public/status: %v", err)
+			writeJSONError(w, http.StatusBadGateway, "status unavailable")
+			return
+		}
+		w.Header().Set("Cache-Control", "public, max-age=30")
+		writeJSON(w, http.StatusOK, st)
+	}
+}
+
+//go:embed static/status/index.html
+var statusPageTemplateSrc string
+
+var (
+	statusPageTmplOnce sync.Once
+	statusPageTmpl     *template.Template
+	statusPageTmplErr  error
+)
+
+type statusPageData struct {
+	PageTitle  string
+	BrandName  string
+	SupportURL string
+}
+
+func statusPageTemplate() (*template.Template, error) {
+	statusPageTmplOnce.Do(func() {
+		statusPageTmpl, statusPageTmplErr = template.New("status").Parse(statusPageTemplateSrc)
+	})
+	return statusPageTmpl, statusPageTmplErr
+}
+
+func renderedStatusPageHTML(cfg *config.Config) ([]byte, error) {
+	brandName, err := buyPageBrandName(cfg)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := statusPageTemplate()
+	if err != nil {
+		return nil, err
+	}
+	data := statusPageData{
+		PageTitle:  brandName + " — статус серверов",
+		BrandName:  brandName,
+		SupportURL: WebCabinetResolvedSupportURL(cfg),
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// serveStatusPage — GET /status: страница загружает /api/public/status и обновляется раз в минуту.
+func serveStatusPage(cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/status", "/status/":
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := renderedStatusPageHTML(cfg)
+		if err != nil {
+			log.Printf("status page render: %v", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(body)
+	}
+}
@@ -0,0 +1,177 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/config"
+	"github.com/ryabkov82/vpnbot/internal/infrastructure/remnawave"
+)
+
+const publicStatusNodesJSON = `{"response":[
+{"uuid":"n-1","name":"nl-ams-01","countryCode":"NL","isConnected":true,"isXrayRunning":true,"usersOnline":10},
+{"uuid":"n-2","name":"nl-ams-02","countryCode":"NL","isConnected":false,"usersOnline":0},
+{"uuid":"n-3","name":"de-fra-01","countryCode":"DE","isConnected":true,"isXrayRunning":true,"usersOnline":4},
+{"uuid":"n-4","name":"fi-hel-01","countryCode":"FI","isConnected":true,"isXrayRunning":false},
+{"uuid":"n-5","name":"us-maint","countryCode":"US","isConnected":false,"isDisabled":true},
+{"uuid":"n-6","name":"internal-test","countryCode":"RU","isConnected":true}
+]}`
+
+func newPublicStatusRemnawave(t *testing.T, hits *int) *remnawave.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/nodes" {
+			t.Fatalf("unexpected %s %s", r.Method, r.URL.Path)
+		}
+		*hits++
+		_, _ = w.Write([]byte(publicStatusNodesJSON))
+	}))
+	t.Cleanup(srv.Close)
+	return remnawave.NewClient(srv.URL, "tok")
+}
+
+func stubPublicStatusStore(t *testing.T, now *time.Time) {
+	t.Helper()
+	prev := publicStatusStore
+	publicStatusStore = &publicStatusCache{nowFunc: func() time.Time { return *now }}
+	t.Cleanup(func() { publicStatusStore = prev })
+}
+
+func publicStatusTestCfg() *config.Config {
+	cfg := orderStartTestCfg()
+	cfg.StatusPage = config.StatusPage{
+		Locations: map[string]string{
+			"nl-ams-01": "Нидерланды",
+			"nl-ams-02": "Нидерланды",
+			"de-fra-01": "Германия",
+			"fi-hel-01": "Финляндия",
+			"us-maint":  "США",
+		},
+		HideUnmapped: true,
+	}
+	return cfg
+}
+
+func TestServePublicStatus_GroupsLocationsAndCaches(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	stubPublicStatusStore(t, &now)
+	var hits int
+	h := servePublicStatus(publicStatusTestCfg(), newPublicStatusRemnawave(t, &hits))
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/public/status", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("code %d body %s", rec.Code, rec.Body.String())
+		}
+		if strings.Contains(rec.Body.String(), "nl-ams") || strings.Contains(rec.Body.String(), "internal-test") {
+			t.Fatalf("node names leaked: %s", rec.Body.String())
+		}
+		var got PublicStatus
+		if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != PublicStatusDegraded || len(got.Locations) != 3 {
+			t.Fatalf("%+v", got)
+		}
+		want := map[string]string{"Германия": PublicStatusOnline, "Нидерланды": PublicStatusDegraded, "Финляндия": PublicStatusOffline}
+		for _, l := range got.Locations {
+			if want[l.Name] != l.Status {
+				t.Fatalf("%s: %s", l.Name, l.Status)
+			}
+		}
+		if nl := got.Locations[1]; nl.Name != "Нидерланды" || nl.NodesOnline != 1 || nl.NodesTotal != 2 || nl.UsersOnline != 10 || nl.CountryCode != "NL" {
+			t.Fatalf("nl %+v", nl)
+		}
+	}
+	if hits != 1 {
+		t.Fatalf("remnawave hits=%d, want cached", hits)
+	}
+	now = now.Add(2 * time.Minute)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/public/status", nil))
+	if rec.Code != http.StatusOK || hits != 2 {
+		t.Fatalf("after ttl: code %d hits %d", rec.Code, hits)
+	}
+}
+
+func TestServePublicStatus_ServesSnapshotWhenPanelFails(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	stubPublicStatusStore(t, &now)
+	fail := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			http.Error(w, "down", http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte(publicStatusNodesJSON))
+	}))
+	t.Cleanup(srv.Close)
+	h := servePublicStatus(publicStatusTestCfg(), remnawave.NewClient(srv.URL, "tok"))
+	get := func() (*httptest.ResponseRecorder, PublicStatus) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/public/status", nil))
+		var got PublicStatus
+		_ = json.Unmarshal(rec.Body.Bytes(), &got)
+		return rec, got
+	}
+
+	fail = true
+	if rec, _ := get(); rec.Code != http.StatusBadGateway {
+		t.Fatalf("no snapshot: code %d", rec.Code)
+	}
+	fail = false
+	if rec, got := get(); rec.Code != http.StatusOK || !got.UpdatedAt.Equal(now) {
+		t.Fatalf("fresh: code %d %+v", rec.Code, got)
+	}
+	taken := now
+	now = now.Add(10 * time.Minute)
+	fail = true
+	if rec, got := get(); rec.Code != http.StatusOK || !got.UpdatedAt.Equal(taken) || len(got.Locations) != 3 {
+		t.Fatalf("stale snapshot: code %d %+v", rec.Code, got)
+	}
+}
+
+func TestBuildPublicStatus_UnmappedAndOutage(t *testing.T) {
+	nodes := []remnawave.Node{
+		{Name: "a", IsConnected: false},
+		{Name: "b", IsConnected: true, IsXrayRunning: false},
+	}
+	st := buildPublicStatus(config.StatusPage{}, nodes, time.Now())
+	if st.Status != PublicStatusOutage || len(st.Locations) != 2 || st.Locations[0].Name != "a" {
+		t.Fatalf("%+v", st)
+	}
+	if st := buildPublicStatus(config.StatusPage{}, nil, time.Now()); st.Status != PublicStatusOperational || len(st.Locations) != 0 {
+		t.Fatalf("empty: %+v", st)
+	}
+}
+
+func TestServePublicStatus_NoRemnawave(t *testing.T) {
+	rec := httptest.NewRecorder()
+	servePublicStatus(publicStatusTestCfg(), nil).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/public/status", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("code %d", rec.Code)
+	}
+}
+
+func TestStatusPage(t *testing.T) {
+	clearBuySupportURLEnv(t)
+	rec := httptest.NewRecorder()
+	serveStatusPage(orderStartTestCfg()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("code %d", rec.Code)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, "VPN for Friends — статус серверов") || !strings.Contains(body, "/api/public/status") {
+		t.Fatalf("body: %s", body)
+	}
+	for _, forbid := range []string{"Remnawave", "SHM"} {
+		if strings.Contains(body, forbid) {
+			t.Fatalf("status page must not contain %q", forbid)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package web
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/config"
+	"github.com/ryabkov82/vpnbot/internal/receipts"
+	appService "github.com/ryabkov82/vpnbot/internal/service"
+)
+
+// accountStatementMonthLayout — формат ?month= для выписки.
+const accountStatementMonthLayout = "2006-01"
+
+// serveAccountPaymentReceipt — GET /api/account/payments/{id}/receipt?token=…[&format=pdf|html]: квитанция по платежу.
+func serveAccountPaymentReceipt(cfg *config.Config, app accountWebApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rest, ok := strings.CutPrefix(r.URL.Path, "/api/account/payments/")
+		idStr, ok2 := strings.CutSuffix(rest, "/receipt")
+		payID, err := strconv.Atoi(idStr)
+		if !ok || !ok2 || err != nil || payID <= 0 {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed")
+			return
+		}
+		if !webSalesTokenFlowAvailable(cfg) {
+			writeJSONError(w, http.StatusNotFound, "not_found")
+			return
+		}
+
+		claims, _, err := authenticateWebAccount(cfg, app, strings.TrimSpace(r.URL.Query().Get("token")))
+		if err != nil {
+			writeAccountAuthError(w, err)
+			return
+		}
+		doc, err := app.PaymentReceipt(claims.UserID, payID)
+		if errors.Is(err, appService.ErrPaymentNotFound) {
+			writeJSONError(w, http.StatusNotFound, "payment_not_found")
+			return
+		}
+		if err != nil {
+			slog.Error("account receipt: PaymentReceipt", "user_id", claims.UserID, "pay_id", payID, "err", err)
+			writeJSONError(w, http.StatusInternalServerError, "receipt_failed")
+			return
+		}
+		writeAccountDocument(w, r, doc)
+	}
+}
+
+// serveAccountStatement — GET /api/account/statement?token=…&month=YYYY-MM[&format=pdf|html]: выписка за месяц (МСК).
+func serveAccountStatement(cfg *config.Config, app accountWebApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/account/statement" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed")
+			return
+		}
+		if !webSalesTokenFlowAvailable(cfg) {
+			writeJSONError(w, http.StatusNotFound, "not_found")
+			return
+		}
+
+		claims, _, err := authenticateWebAccount(cfg, app, strings.TrimSpace(r.URL.Query().Get("token")))
+		if err != nil {
+			writeAccountAuthError(w, err)
+			return
+		}
+		month, err := time.Parse(accountStatementMonthLayout, strings.TrimSpace(r.URL.Query().Get("month")))
+		if err != nil || month.Year() < 2000 {
+			writeJSONError(w, http.StatusBadRequest, "invalid_month")
+			return
+		}
+		// Месяц — календарный по Москве (как даты SHM); время суток не важно.
+		doc, err := app.MonthlyStatement(claims.UserID, time.Date(month.Year(), month.Month(), 1, 12, 0, 0, 0, shmMoscow))
+		if err != nil {
+			slog.Error("account statement: MonthlyStatement", "user_id", claims.UserID, "month", month.Format(accountStatementMonthLayout), "err", err)
+			writeJSONError(w, http.StatusInternalServerError, "statement_failed")
+			return
+		}
+		writeAccountDocument(w, r, doc)
+	}
+}
+
+// writeAccountDocument отдаёт документ вложением: PDF по умолчанию, ?format=html — страница для печати.
+func writeAccountDocument(w http.ResponseWriter, r *http.Request, doc *receipts.Document) {
+	html := strings.EqualFold(strings.TrimSpace(r.URL.Query().Get("format")), "html")
+	var (
+		body []byte
+		err  error
+	)
+	if html {
+		body, err = receipts.HTML(doc)
+	} else {
+		body, err = receipts.PDF(doc)
+	}
+	if err != nil {
+		slog.Error("account document: render", "file", doc.Filename, "err", err)
+		writeJSONError(w, http.StatusInternalServerError, "render_failed")
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	if html {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Disposition", `inline; filename="`+doc.Filename+`.html"`)
+	} else {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+doc.Filename+`.pdf"`)
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
@@ -0,0 +1,70 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/receipts"
+	appService "github.com/ryabkov82/vpnbot/internal/service"
+)
+
+func getAccountWithToken(t *testing.T, h func(*stubAccountWeb) http.HandlerFunc, st *stubAccountWeb, path, query string) *httptest.ResponseRecorder {
+	t.Helper()
+	cfg := orderStartTestCfg()
+	tok, err := CreateAccountToken(cfg.WebSales.OrderTokenSecret, "vff", "a@b.c", 701, "web_xx", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	h(st).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path+"?token="+tok+query, nil))
+	return rec
+}
+
+func TestServeAccountPaymentReceipt(t *testing.T) {
+	cfg := orderStartTestCfg()
+	h := func(st *stubAccountWeb) http.HandlerFunc { return serveAccountPaymentReceipt(cfg, st) }
+	st := &stubAccountWeb{receipt: &receipts.Document{Title: "Квитанция № 701-5", Brand: "VFF", Filename: "receipt-5"}}
+
+	rec := getAccountWithToken(t, h, st, "/api/account/payments/5/receipt", "")
+	if rec.Code != http.StatusOK || st.receiptPayID != 5 {
+		t.Fatalf("%d pay=%d %s", rec.Code, st.receiptPayID, rec.Body.String())
+	}
+	if rec.Header().Get("Content-Type") != "application/pdf" || !strings.Contains(rec.Header().Get("Content-Disposition"), `filename="receipt-5.pdf"`) || !strings.HasPrefix(rec.Body.String(), "%PDF-") {
+		t.Fatalf("pdf headers %v", rec.Header())
+	}
+	rec = getAccountWithToken(t, h, st, "/api/account/payments/5/receipt", "&format=html")
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") || !strings.Contains(rec.Body.String(), "Квитанция № 701-5") {
+		t.Fatalf("html %v", rec.Header())
+	}
+
+	if rec := getAccountWithToken(t, h, st, "/api/account/payments/x/receipt", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("bad id: %d", rec.Code)
+	}
+	rec = getAccountWithToken(t, h, &stubAccountWeb{receiptErr: appService.ErrPaymentNotFound}, "/api/account/payments/9/receipt", "")
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("foreign pay: %d", rec.Code)
+	}
+	assertJSONErrorField(t, rec.Body.String(), "payment_not_found")
+}
+
+func TestServeAccountStatement(t *testing.T) {
+	cfg := orderStartTestCfg()
+	h := func(st *stubAccountWeb) http.HandlerFunc { return serveAccountStatement(cfg, st) }
+	st := &stubAccountWeb{receipt: &receipts.Document{Title: "Выписка за октябрь 2026", Filename: "statement-2026-10"}}
+
+	rec := getAccountWithToken(t, h, st, "/api/account/statement", "&month=2026-10")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Header().Get("Content-Disposition"), "statement-2026-10.pdf") {
+		t.Fatalf("%d %v", rec.Code, rec.Header())
+	}
+	if st.statementMonth.Month() != time.October || st.statementMonth.Location() != shmMoscow {
+		t.Fatalf("month %v", st.statementMonth)
+	}
+	rec = getAccountWithToken(t, h, st, "/api/account/statement", "&month=10.2026")
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("bad month: %d", rec.Code)
+	}
+	assertJSONErrorField(t, rec.Body.String(), "invalid_month")
+}
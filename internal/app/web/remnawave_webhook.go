@@ -0,0 +1,395 @@
+package web
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/config"
+	"github.com/ryabkov82/vpnbot/internal/email"
+	"github.com/ryabkov82/vpnbot/internal/models"
+	"github.com/ryabkov82/vpnbot/internal/service"
+)
+
+const (
+	remnawaveWebhookSignatureHeader = "X-Remnawave-Signature"
+	// remnawaveWebhookMaxSkew — событие старше (или «из будущего») отклоняется как повтор.
+	remnawaveWebhookMaxSkew = 5 * time.Minute
+	remnawaveWebhookLogSize = 200
+)
+
+// События Remnawave, о которых сообщаем владельцу услуги.
+const (
+	RemnawaveEventUserExpired        = "user.expired"
+	RemnawaveEventUserDisabled       = "user.disabled"
+	RemnawaveEventUserLimited        = "user.limited"
+	RemnawaveEventUserFirstConnected = "user.first_connected"
+	RemnawaveEventHWIDDeviceAdded    = "user_hwid_devices.added"
+)
+
+// Статусы обработки в журнале событий.
+const (
+	remnawaveWebhookStatusNotified  = "notified"
+	remnawaveWebhookStatusIgnored   = "ignored"
+	remnawaveWebhookStatusUnmapped  = "unmapped"
+	remnawaveWebhookStatusNoContact = "no_contact"
+	remnawaveWebhookStatusDuplicate = "duplicate"
+	remnawaveWebhookStatusError     = "error"
+)
+
+var (
+	errRemnawaveWebhookStale     = errors.New("stale event")
+	errRemnawaveWebhookDuplicate = errors.New("duplicate event")
+)
+
+// remnawaveWebhookApp — владелец услуги по user_service_id (stub в тестах).
+type remnawaveWebhookApp interface {
+	GetUserServiceByID(userServiceID int) (*models.UserService, error)
+	GetUserByID(userID int) (*models.User, error)
+}
+
+// remnawaveWebhookPayload — конверт webhook Remnawave; data — пользователь (scope user)
+// или {"user": …, "hwidUserDevice": …} (scope user_hwid_devices).
+type remnawaveWebhookPayload struct {
+	Scope     string          `json:"scope"`
+	Event     string          `json:"event"`
+	Timestamp string          `json:"timestamp"`
+	Data      json.RawMessage `json:"data"`
+}
+
+type remnawaveWebhookUser struct {
+	Username string `json:"username"`
+}
+
+type remnawaveWebhookDevice struct {
+	HWID        string `json:"hwid"`
+	Platform    string `json:"platform"`
+	OSVersion   string `json:"osVersion"`
+	DeviceModel string `json:"deviceModel"`
+}
+
+type remnawaveWebhookData struct {
+	remnawaveWebhookUser
+	User   *remnawaveWebhookUser   `json:"user"`
+	Device *remnawaveWebhookDevice `json:"hwidUserDevice"`
+}
+
+// username — data.username для событий пользователя, data.user.username для событий устройств.
+func (d remnawaveWebhookData) username() string {
+	if d.User != nil && strings.TrimSpace(d.User.Username) != "" {
+		return strings.TrimSpace(d.User.Username)
+	}
+	return strings.TrimSpace(d.Username)
+}
+
+// RemnawaveWebhookEvent — запись журнала входящих событий (GET /api/admin/webhooks/remnawave/events).
+type RemnawaveWebhookEvent struct {
+	ReceivedAt    time.Time `json:"received_at"`
+	Event         string    `json:"event"`
+	Username      string    `json:"username,omitempty"`
+	UserServiceID int       `json:"user_service_id,omitempty"`
+	UserID        int       `json:"user_id,omitempty"`
+	Status        string    `json:"status"`
+	Channel       string    `json:"channel,omitempty"`
+	Detail        string    `json:"detail,omitempty"`
+}
+
+// remnawaveWebhookGuard — защита от повторов: окно по времени события и память подписей в пределах окна.
+type remnawaveWebhookGuard struct {
+	mu      sync.Mutex
+	seen    map[string]time.Time
+	nowFunc func() time.Time
+}
+
+func newRemnawaveWebhookGuard() *remnawaveWebhookGuard {
+	return &remnawaveWebhookGuard{seen: map[string]time.Time{}, nowFunc: time.Now}
+}
+
+func (g *remnawaveWebhookGuard) now() time.Time {
+	if g.nowFunc != nil {
+		return g.nowFunc()
+	}
+	return time.Now()
+}
+
+// admit принимает событие с подписью sig и временем sentAt ровно один раз.
+func (g *remnawaveWebhookGuard) admit(sig string, sentAt time.Time) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := g.now()
+	if sentAt.IsZero() || sentAt.Before(now.Add(-remnawaveWebhookMaxSkew)) || sentAt.After(now.Add(remnawaveWebhookMaxSkew)) {
+		return errRemnawaveWebhookStale
+	}
+	for k, exp := range g.seen {
+		if now.After(exp) {
+			delete(g.seen, k)
+		}
+	}
+	if _, dup := g.seen[sig]; dup {
+		return errRemnawaveWebhookDuplicate
+	}
+	// Подпись помнится, пока событие проходит проверку окна: дальше его отсекает время.
+	g.seen[sig] = now.Add(2 * remnawaveWebhookMaxSkew)
+	return nil
+}
+
+// remnawaveWebhookEventLog — кольцевой журнал последних событий в памяти процесса.
+type remnawaveWebhookEventLog struct {
+	mu      sync.Mutex
+	entries []RemnawaveWebhookEvent
+	next    int
+}
+
+func (l *remnawaveWebhookEventLog) add(e RemnawaveWebhookEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.entries) < remnawaveWebhookLogSize {
+		l.entries = append(l.entries, e)
+		return
+	}
+	l.entries[l.next] = e
+	l.next = (l.next + 1) % remnawaveWebhookLogSize
+}
+
+// record — журнал и строка лога по итогу обработки события.
+func (l *remnawaveWebhookEventLog) record(e RemnawaveWebhookEvent) {
+	slog.Info("remnawave webhook", "event", e.Event, "username", e.Username, "user_service_id", e.UserServiceID,
+		"user_id", e.UserID, "status", e.Status, "channel", e.Channel, "detail", e.Detail)
+	l.add(e)
+}
+
+// recent — события от новых к старым.
+func (l *remnawaveWebhookEventLog) recent() []RemnawaveWebhookEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := make([]RemnawaveWebhookEvent, 0, len(l.entries))
+	for i := len(l.entries) - 1; i >= 0; i-- {
+		out = append(out, l.entries[(l.next+i)%len(l.entries)])
+	}
+	return out
+}
+
+var (
+	remnawaveWebhookReplay = newRemnawaveWebhookGuard()
+	remnawaveWebhookLog    = &remnawaveWebhookEventLog{}
+)
+
+// remnawaveWebhookNotifier доставляет сообщение владельцу; возвращает канал ("" — нет контакта). Подменяется в тестах.
+var remnawaveWebhookNotifier = sendRemnawaveWebhookNotification
+
+func sendRemnawaveWebhookNotification(cfg *config.Config, user *models.User, title, text string) (string, error) {
+	if chatID := user.Settings.Telegram.ChatID; chatID > 0 {
+		postTelegramPlainTextMessageToChat(cfg, chatID, text, "remnawave webhook")
+		return "telegram", nil
+	}
+	if to := strings.TrimSpace(user.Settings.Web.Email); to != "" && email.IsConfigured(cfg) {
+		return "email", email.SendPremiumEventEmail(cfg, to, title, text)
+	}
+	return "", nil
+}
+
+// verifyRemnawaveSignature — hex(HMAC-SHA256(body, secret)) в X-Remnawave-Signature.
+func verifyRemnawaveSignature(secret string, body []byte, sig string) bool {
+	got, err := hex.DecodeString(strings.TrimSpace(sig))
+	if err != nil || len(got) != sha256.Size {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// parseRemnawaveWebhookTime — timestamp из подписанного тела: заголовки в HMAC не входят, и время из них
+// позволило бы переотправить перехваченное тело как свежее.
+func parseRemnawaveWebhookTime(payload string) time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, strings.TrimSpace(payload))
+	return t
+}
+
+// remnawaveUserServiceID: "us_<user_service_id>" → id; иначе 0.
+func remnawaveUserServiceID(username string) int {
+	raw, ok := strings.CutPrefix(username, "us_")
+	if !ok {
+		return 0
+	}
+	id, err := strconv.Atoi(raw)
+	if err != nil || id <= 0 {
+		return 0
+	}
+	return id
+}
+
+// remnawaveEventMessage — заголовок (тема письма) и текст уведомления; ok=false — событие не сообщаем.
+func remnawaveEventMessage(event string, us *models.UserService, dev *remnawaveWebhookDevice) (title, text string, ok bool) {
+	name := strings.TrimSpace(us.Name)
+	if name == "" {
+		name = "Premium"
+	}
+	switch event {
+	case RemnawaveEventUserExpired:
+		return "срок услуги истёк",
+			fmt.Sprintf("⌛️ Срок действия услуги «%s» истёк. Пополните баланс — услуга продлится автоматически, и VPN снова заработает.", name), true
+	case RemnawaveEventUserDisabled:
+		return "услуга отключена",
+			fmt.Sprintf("⛔️ Услуга «%s» отключена. Если это неожиданно, напишите в поддержку.", name), true
+	case RemnawaveEventUserLimited:
+		return "трафик исчерпан",
+			fmt.Sprintf("⛔️ По услуге «%s» исчерпан лимит трафика. Подключение ограничено до начала следующего периода; тариф с большим объёмом можно выбрать в каталоге.", name), true
+	case RemnawaveEventUserFirstConnected:
+		return "первое подключение",
+			fmt.Sprintf("✅ Услуга «%s» подключена: первое соединение прошло успешно.", name), true
+	case RemnawaveEventHWIDDeviceAdded:
+		what := "новое устройство"
+		if dev != nil {
+			var parts []string
+			if m := strings.TrimSpace(dev.DeviceModel); m != "" {
+				parts = append(parts, m)
+			}
+			if p := strings.TrimSpace(dev.Platform + " " + dev.OSVersion); p != "" {
+				parts = append(parts, p)
+			}
+			if len(parts) > 0 {
+				what += " (" + strings.Join(parts, ", ") + ")"
+			}
+		}
+		return "новое устройство",
+			fmt.Sprintf("📱 К услуге «%s» подключено %s. Если это не вы, отвяжите его в разделе «Устройства» и сбросьте ссылку подписки.", name, what), true
+	}
+	return "", "", false
+}
+
+// serveRemnawaveWebhook — POST /api/webhooks/remnawave: подписанные события панели → уведомления владельцам услуг.
+// Любое принятое (подписанное и свежее) событие отвечает 200, чтобы панель не повторяла доставку.
+func serveRemnawaveWebhook(cfg *config.Config, app remnawaveWebhookApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/webhooks/remnawave" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		secret := strings.TrimSpace(cfg.RemnawaveWebhookSecret)
+		if secret == "" {
+			writeJSONError(w, http.StatusServiceUnavailable, "webhook disabled")
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+		sig := r.Header.Get(remnawaveWebhookSignatureHeader)
+		if !verifyRemnawaveSignature(secret, body, sig) {
+			log.Printf("api/webhooks/remnawave: invalid signature from %s", ClientIPFromRequest(r))
+			writeJSONError(w, http.StatusUnauthorized, "invalid signature")
+			return
+		}
+		var p remnawaveWebhookPayload
+		if err := json.Unmarshal(body, &p); err != nil || strings.TrimSpace(p.Event) == "" {
+			writeJSONError(w, http.StatusBadRequest, "invalid payload")
+			return
+		}
+
+		entry := RemnawaveWebhookEvent{ReceivedAt: time.Now().UTC(), Event: p.Event}
+		var data remnawaveWebhookData
+		_ = json.Unmarshal(p.Data, &data)
+		entry.Username = data.username()
+
+		switch err := remnawaveWebhookReplay.admit(strings.ToLower(strings.TrimSpace(sig)), parseRemnawaveWebhookTime(p.Timestamp)); {
+		case errors.Is(err, errRemnawaveWebhookDuplicate):
+			entry.Status = remnawaveWebhookStatusDuplicate
+			remnawaveWebhookLog.record(entry)
+			writeJSON(w, http.StatusOK, map[string]string{"status": entry.Status})
+			return
+		case err != nil:
+			log.Printf("api/webhooks/remnawave: %s %s: %v", p.Event, entry.Username, err)
+			writeJSONError(w, http.StatusBadRequest, "stale event")
+			return
+		}
+
+		handleRemnawaveWebhookEvent(cfg, app, p.Event, data, &entry)
+		remnawaveWebhookLog.record(entry)
+		writeJSON(w, http.StatusOK, map[string]string{"status": entry.Status})
+	}
+}
+
+// handleRemnawaveWebhookEvent: us_<id> → услуга SHM → владелец → уведомление; итог пишется в entry.
+func handleRemnawaveWebhookEvent(cfg *config.Config, app remnawaveWebhookApp, event string, data remnawaveWebhookData, entry *RemnawaveWebhookEvent) {
+	if _, _, ok := remnawaveEventMessage(event, &models.UserService{}, nil); !ok {
+		entry.Status = remnawaveWebhookStatusIgnored
+		return
+	}
+	entry.UserServiceID = remnawaveUserServiceID(entry.Username)
+	if entry.UserServiceID == 0 {
+		entry.Status = remnawaveWebhookStatusUnmapped
+		return
+	}
+	us, err := app.GetUserServiceByID(entry.UserServiceID)
+	if err != nil {
+		entry.Status = remnawaveWebhookStatusUnmapped
+		if !errors.Is(err, service.ErrUserServiceUnavailable) {
+			entry.Status = remnawaveWebhookStatusError
+			entry.Detail = err.Error()
+		}
+		return
+	}
+	entry.UserID = us.UserID
+	user, err := app.GetUserByID(us.UserID)
+	if err != nil || user == nil {
+		entry.Status = remnawaveWebhookStatusError
+		entry.Detail = fmt.Sprintf("owner lookup: %v", err)
+		return
+	}
+
+	title, text, _ := remnawaveEventMessage(event, us, data.Device)
+	channel, err := remnawaveWebhookNotifier(cfg, user, title, text)
+	entry.Channel = channel
+	switch {
+	case err != nil:
+		entry.Status = remnawaveWebhookStatusError
+		entry.Detail = err.Error()
+	case channel == "":
+		entry.Status = remnawaveWebhookStatusNoContact
+	default:
+		entry.Status = remnawaveWebhookStatusNotified
+	}
+}
+
+// serveRemnawaveWebhookEvents — GET /api/admin/webhooks/remnawave/events: журнал последних событий (X-Admin-Token).
+func serveRemnawaveWebhookEvents(cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/admin/webhooks/remnawave/events" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed")
+			return
+		}
+		wantTok := ""
+		if cfg != nil {
+			wantTok = cfg.Admin.Token
+		}
+		if !adminTokenMatches(wantTok, r.Header.Get("X-Admin-Token")) {
+			writeJSONError(w, http.StatusForbidden, "forbidden")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"events": remnawaveWebhookLog.recent()})
+	}
+}
@@ -0,0 +1,190 @@
+package web
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/config"
+	"github.com/ryabkov82/vpnbot/internal/models"
+	"github.com/ryabkov82/vpnbot/internal/service"
+)
+
+const remnawaveWebhookTestSecret = "rw-webhook-secret"
+
+type stubRemnawaveWebhookApp struct {
+	services map[int]*models.UserService
+	users    map[int]*models.User
+}
+
+func (s stubRemnawaveWebhookApp) GetUserServiceByID(id int) (*models.UserService, error) {
+	if us, ok := s.services[id]; ok {
+		return us, nil
+	}
+	return nil, service.ErrUserServiceUnavailable
+}
+
+func (s stubRemnawaveWebhookApp) GetUserByID(id int) (*models.User, error) {
+	return s.users[id], nil
+}
+
+type sentRemnawaveNotice struct {
+	userID      int
+	title, text string
+}
+
+// stubRemnawaveWebhook — чистые журнал и защита от повторов, уведомления в срез.
+func stubRemnawaveWebhook(t *testing.T, now time.Time) *[]sentRemnawaveNotice {
+	t.Helper()
+	prevGuard, prevLog, prevNotifier := remnawaveWebhookReplay, remnawaveWebhookLog, remnawaveWebhookNotifier
+	remnawaveWebhookReplay = newRemnawaveWebhookGuard()
+	remnawaveWebhookReplay.nowFunc = func() time.Time { return now }
+	remnawaveWebhookLog = &remnawaveWebhookEventLog{}
+	var sent []sentRemnawaveNotice
+	remnawaveWebhookNotifier = func(_ *config.Config, u *models.User, title, text string) (string, error) {
+		if u.Settings.Telegram.ChatID == 0 {
+			return "", nil
+		}
+		sent = append(sent, sentRemnawaveNotice{userID: u.ID, title: title, text: text})
+		return "telegram", nil
+	}
+	t.Cleanup(func() {
+		remnawaveWebhookReplay, remnawaveWebhookLog, remnawaveWebhookNotifier = prevGuard, prevLog, prevNotifier
+	})
+	return &sent
+}
+
+func remnawaveWebhookTestApp() stubRemnawaveWebhookApp {
+	owner := &models.User{ID: 7}
+	owner.Settings.Telegram.ChatID = 7007
+	return stubRemnawaveWebhookApp{
+		services: map[int]*models.UserService{
+			501: {ServiceID: 501, UserID: 7, Name: "Premium"},
+			502: {ServiceID: 502, UserID: 8, Name: "Premium"},
+		},
+		users: map[int]*models.User{7: owner, 8: {ID: 8}},
+	}
+}
+
+// remnawaveWebhookRequest подписывает payload; без своего timestamp в тело подставляется sentAt (как у панели).
+func remnawaveWebhookRequest(t *testing.T, secret string, sentAt time.Time, payload string) *http.Request {
+	t.Helper()
+	if !strings.Contains(payload, `"timestamp"`) {
+		payload = `{"timestamp":"` + sentAt.UTC().Format(time.RFC3339) + `",` + strings.TrimPrefix(payload, "{")
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	r := httptest.NewRequest(http.MethodPost, "/api/webhooks/remnawave", strings.NewReader(payload))
+	r.Header.Set(remnawaveWebhookSignatureHeader, hex.EncodeToString(mac.Sum(nil)))
+	return r
+}
+
+func serveRemnawaveWebhookTest(t *testing.T, r *http.Request) (int, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	cfg := &config.Config{RemnawaveWebhookSecret: remnawaveWebhookTestSecret}
+	serveRemnawaveWebhook(cfg, remnawaveWebhookTestApp()).ServeHTTP(rec, r)
+	var body struct {
+		Status string `json:"status"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &body)
+	return rec.Code, body.Status
+}
+
+func TestServeRemnawaveWebhook_NotifiesOwnerOnce(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	sent := stubRemnawaveWebhook(t, now)
+	payload := `{"scope":"user_hwid_devices","event":"user_hwid_devices.added","timestamp":"2026-10-18T12:00:00Z",
+		"data":{"user":{"username":"us_501"},"hwidUserDevice":{"hwid":"h1","platform":"iOS","osVersion":"18.1","deviceModel":"iPhone 15"}}}`
+
+	code, status := serveRemnawaveWebhookTest(t, remnawaveWebhookRequest(t, remnawaveWebhookTestSecret, now, payload))
+	if code != http.StatusOK || status != remnawaveWebhookStatusNotified {
+		t.Fatalf("code %d status %q", code, status)
+	}
+	if len(*sent) != 1 || (*sent)[0].userID != 7 || !strings.Contains((*sent)[0].text, "(iPhone 15, iOS 18.1)") {
+		t.Fatalf("sent %+v", *sent)
+	}
+
+	code, status = serveRemnawaveWebhookTest(t, remnawaveWebhookRequest(t, remnawaveWebhookTestSecret, now, payload))
+	if code != http.StatusOK || status != remnawaveWebhookStatusDuplicate || len(*sent) != 1 {
+		t.Fatalf("replay: code %d status %q sent %d", code, status, len(*sent))
+	}
+}
+
+func TestServeRemnawaveWebhook_Rejects(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	sent := stubRemnawaveWebhook(t, now)
+	payload := `{"scope":"user","event":"user.expired","data":{"username":"us_501"}}`
+
+	if code, _ := serveRemnawaveWebhookTest(t, remnawaveWebhookRequest(t, "other", now, payload)); code != http.StatusUnauthorized {
+		t.Fatalf("bad signature: code %d", code)
+	}
+	stale := remnawaveWebhookRequest(t, remnawaveWebhookTestSecret, now.Add(-time.Hour), payload)
+	if code, _ := serveRemnawaveWebhookTest(t, stale); code != http.StatusBadRequest {
+		t.Fatalf("stale: code %d", code)
+	}
+	rec := httptest.NewRecorder()
+	serveRemnawaveWebhook(&config.Config{}, remnawaveWebhookTestApp()).ServeHTTP(rec,
+		remnawaveWebhookRequest(t, "", now, payload))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("no secret: code %d", rec.Code)
+	}
+	if len(*sent) != 0 {
+		t.Fatalf("sent %+v", *sent)
+	}
+}
+
+func TestServeRemnawaveWebhook_Statuses(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	stubRemnawaveWebhook(t, now)
+	cases := []struct{ payload, want string }{
+		{`{"event":"user.modified","data":{"username":"us_501"}}`, remnawaveWebhookStatusIgnored},
+		{`{"event":"user.limited","data":{"username":"alice"}}`, remnawaveWebhookStatusUnmapped},
+		{`{"event":"user.limited","data":{"username":"us_999"}}`, remnawaveWebhookStatusUnmapped},
+		{`{"event":"user.disabled","data":{"username":"us_502"}}`, remnawaveWebhookStatusNoContact},
+		{`{"event":"user.first_connected","data":{"username":"us_501"}}`, remnawaveWebhookStatusNotified},
+	}
+	for _, tc := range cases {
+		if code, status := serveRemnawaveWebhookTest(t, remnawaveWebhookRequest(t, remnawaveWebhookTestSecret, now, tc.payload)); code != http.StatusOK || status != tc.want {
+			t.Fatalf("%s: code %d status %q, want %q", tc.payload, code, status, tc.want)
+		}
+	}
+
+	cfg := &config.Config{}
+	cfg.Admin.Token = "adm"
+	rec := httptest.NewRecorder()
+	serveRemnawaveWebhookEvents(cfg).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/admin/webhooks/remnawave/events", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("no token: code %d", rec.Code)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/webhooks/remnawave/events", nil)
+	req.Header.Set("X-Admin-Token", "adm")
+	rec = httptest.NewRecorder()
+	serveRemnawaveWebhookEvents(cfg).ServeHTTP(rec, req)
+	var got struct {
+		Events []RemnawaveWebhookEvent `json:"events"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Events) != len(cases) || got.Events[0].Event != "user.first_connected" || got.Events[0].UserID != 7 || got.Events[0].Channel != "telegram" {
+		t.Fatalf("events %+v", got.Events)
+	}
+}
+
+func TestRemnawaveWebhookEventLogRing(t *testing.T) {
+	l := &remnawaveWebhookEventLog{}
+	for i := 1; i <= remnawaveWebhookLogSize+5; i++ {
+		l.add(RemnawaveWebhookEvent{UserServiceID: i})
+	}
+	got := l.recent()
+	if len(got) != remnawaveWebhookLogSize || got[0].UserServiceID != remnawaveWebhookLogSize+5 || got[len(got)-1].UserServiceID != 6 {
+		t.Fatalf("len %d first %d last %d", len(got), got[0].UserServiceID, got[len(got)-1].UserServiceID)
+	}
+}
@@ -19,7 +19,7 @@ func Start(cfg *config.Config, app *service.Service, rw *remnawave.Client) {
 	mux.HandleFunc("/premium-connect/", premiumH)
 	mux.HandleFunc("/premium-connect-test", premiumH)
 	mux.HandleFunc("/premium-connect-test/", premiumH)
-	mux.HandleFunc("/redirect.html", serveHappRedirect)
+	mux.HandleFunc("/redirect.html", serveAppRedirect(cfg))
 	buyH := serveBuy(cfg)
 	mux.HandleFunc("/buy", buyH)
 	mux.HandleFunc("/buy/", buyH)
@@ -28,12 +28,24 @@ func Start(cfg *config.Config, app *service.Service, rw *remnawave.Client) {
 	mux.HandleFunc("/apple-touch-icon.png", serveBrandAppleTouchIcon(cfg))
 	mux.HandleFunc("/api/premium/service", servePremiumService(cfg, app, rw))
 	mux.HandleFunc("/api/premium/happ-link", servePremiumHappLink(cfg, app, rw))
+	mux.HandleFunc("/api/premium/devices", servePremiumDevices(cfg, app, rw))
+	mux.HandleFunc("/api/premium/devices/delete", servePremiumDevicesDelete(cfg, app, rw))
+	mux.HandleFunc("/api/premium/reset", servePremiumReset(cfg, app, rw))
+	mux.HandleFunc("/api/premium/usage/history", servePremiumUsageHistory(cfg, app, rw))
 	mux.HandleFunc("/api/public/services", servePublicServices(cfg, app))
+	mux.HandleFunc("/api/public/status", servePublicStatus(cfg, rw))
+	mux.HandleFunc("/status", serveStatusPage(cfg))
+	mux.HandleFunc("/status/", serveStatusPage(cfg))
 	sharedLeadRL := newLeadRateLimiter(5, 15*time.Minute, 3, time.Hour)
 	accountLoginRL := newLeadRateLimiter(5, 15*time.Minute, 3, time.Hour)
 	mux.HandleFunc("/api/public/lead", servePublicLeadWithLimiter(cfg, app, sharedLeadRL))
 	mux.HandleFunc("/api/admin/web-order/test", serveAdminWebOrderTest(cfg, app))
 	mux.HandleFunc("/api/admin/account/test", serveAdminAccountTest(cfg, app))
+	mux.HandleFunc("/api/admin/users/merge", serveAdminUserMerge(cfg, app))
+	mux.HandleFunc("/api/admin/webhooks/remnawave/events", serveRemnawaveWebhookEvents(cfg))
+	mux.HandleFunc("/api/webhooks/remnawave", serveRemnawaveWebhook(cfg, app))
+	mux.HandleFunc("/api/webhooks/card", serveCardCheckoutWebhook(cfg, app))
+	mux.HandleFunc("/api/admin/card-checkouts", serveAdminCardCheckouts(cfg, app))
 
 	mux.HandleFunc("/account", serveAccount(cfg))
 	mux.HandleFunc("/account/", serveAccount(cfg))
@@ -48,7 +60,10 @@ func Start(cfg *config.Config, app *service.Service, rw *remnawave.Client) {
 	mux.HandleFunc("/account/link/confirm/", serveAccountLinkConfirm(cfg, app))
 	mux.HandleFunc("/account/session", serveAccountSession(cfg))
 	mux.HandleFunc("/account/session/", serveAccountSession(cfg))
-	mux.HandleFunc("/api/account/login/start", serveAccountLoginStart(cfg, app, accountLoginRL))
+	accountLoginCodes := newAccountLoginCodeStore()
+	accountLoginVerifyRL := newLeadRateLimiter(10, 15*time.Minute, 10, time.Hour)
+	mux.HandleFunc("/api/account/login/start", serveAccountLoginStartWithCodes(cfg, app, accountLoginRL, accountLoginCodes))
+	mux.HandleFunc("/api/account/login/verify", serveAccountLoginVerify(cfg, accountLoginVerifyRL, accountLoginCodes))
 	mux.HandleFunc("/api/account/link/login/start", serveAccountLinkLoginStart(cfg, app, accountLoginRL))
 	msStart := serveGoogleOAuthStart(cfg)
 	mux.HandleFunc("/api/account/google/start", msStart)
@@ -57,14 +72,40 @@ func Start(cfg *config.Config, app *service.Service, rw *remnawave.Client) {
 	mux.HandleFunc("/api/account/google/callback", cb)
 	mux.HandleFunc("/api/account/google/callback/", cb)
 	mux.HandleFunc("/api/account/session/start", serveAccountSessionStart(cfg, app))
+	mux.HandleFunc("/api/account/telegram/connect", serveAccountTelegramConnect(cfg, app))
+	accountEmailChangeRL := newLeadRateLimiter(5, 15*time.Minute, 3, time.Hour)
+	mux.HandleFunc("/api/account/email/change", serveAccountEmailChange(cfg, app, accountEmailChangeRL))
+	mux.HandleFunc("/account/email/confirm", serveAccountEmailChangeConfirm(cfg, app))
+	mux.HandleFunc("/api/account/email/unlink", serveAccountEmailUnlink(cfg, app))
+	accountPrivacyRL := newLeadRateLimiter(5, 15*time.Minute, 3, time.Hour)
+	mux.HandleFunc("/api/account/export", serveAccountExport(cfg, app, accountPrivacyRL))
+	mux.HandleFunc("/api/account/delete", serveAccountDelete(cfg, app, accountPrivacyRL))
+	mux.HandleFunc("/account/delete/confirm", serveAccountDeleteConfirmPage(cfg, app))
 	mux.HandleFunc("/api/account/services", serveAccountServices(cfg, app))
 	mux.HandleFunc("/api/account/catalog/services", serveAccountCatalogServices(cfg, app))
 	mux.HandleFunc("/api/account/payments", serveAccountPayments(cfg, app))
+	mux.HandleFunc("/api/account/payments/pending", serveAccountPaymentsPending(cfg, app))
+	mux.HandleFunc("/api/account/payments/", serveAccountPaymentReceipt(cfg, app))
+	mux.HandleFunc("/api/account/statement", serveAccountStatement(cfg, app))
+	mux.HandleFunc("/api/account/withdrawals", serveAccountWithdrawals(cfg, app))
+	mux.HandleFunc("/api/account/ledger", serveAccountLedger(cfg, app))
+	mux.HandleFunc("/api/account/voucher/redeem", serveAccountVoucherRedeem(cfg, app, newLeadRateLimiter(10, 15*time.Minute, 10, time.Hour)))
 	mux.HandleFunc("/api/account/service/connect", serveAccountServiceConnect(cfg, app))
+	mux.HandleFunc("/api/account/service/keys", serveAccountServiceKeys(cfg, app))
+	mux.HandleFunc("/api/account/service/apps", serveAccountServiceApps(cfg, app))
+	mux.HandleFunc("/api/account/service/qr", serveAccountServiceQR(cfg, app))
+	mux.HandleFunc("/sub/", serveSubscription(cfg, app))
+	mux.HandleFunc("/connect/open", serveConnectOpen(cfg, app, rw))
 	mux.HandleFunc("/api/account/service/order", serveAccountServiceOrder(cfg, app))
+	mux.HandleFunc("/api/account/service/checkout", serveAccountServiceCheckout(cfg, app))
 	mux.HandleFunc("/api/account/service/delete", serveAccountServiceDelete(cfg, app))
+	mux.HandleFunc("/api/account/service/autorenew", serveAccountServiceAutoRenew(cfg, app))
+	mux.HandleFunc("/api/account/service/renew", serveAccountServiceRenew(cfg, app))
+	mux.HandleFunc("/api/account/service/change/quote", serveAccountServiceChangeQuote(cfg, app))
+	mux.HandleFunc("/api/account/service/change", serveAccountServiceChange(cfg, app))
 	mux.HandleFunc("/api/account/balance/topup", serveAccountBalanceTopup(cfg, app))
 	mux.HandleFunc("/api/account/balance/topup/cryptocloud", serveAccountBalanceTopupCrypto(cfg, app))
+	mux.HandleFunc("/api/account/checkout/card", serveAccountCardCheckout(cfg, app))
 
 	port := strings.TrimSpace(cfg.WebPort)
 	if port == "" {
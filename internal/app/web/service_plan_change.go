@@ -0,0 +1,243 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ryabkov82/vpnbot/internal/config"
+	"github.com/ryabkov82/vpnbot/internal/models"
+	appService "github.com/ryabkov82/vpnbot/internal/service"
+)
+
+// accountPlanChangeQuoteJSON — ответ /api/account/service/change/quote: что зачтём, сколько стоит новый тариф и чего не хватает.
+type accountPlanChangeQuoteJSON struct {
+	UserServiceID int    `json:"user_service_id"`
+	FromName      string `json:"from_name"`
+	ServiceID     int    `json:"service_id"`
+	Name          string `json:"name"`
+	// Expire — передаётся обратно в /api/account/service/change: защита от смены по устаревшему расчёту.
+	Expire        string  `json:"expire"`
+	RemainingDays float64 `json:"remaining_days"`
+	Credit        float64 `json:"credit"`
+	CreditText    string  `json:"credit_text"`
+	Cost          float64 `json:"cost"`
+	CostText      string  `json:"cost_text"`
+	Balance       float64 `json:"balance"`
+	Covered       bool    `json:"covered"`
+	Due           float64 `json:"due,omitempty"`
+	DueText       string  `json:"due_text,omitempty"`
+	Leftover      float64 `json:"leftover,omitempty"`
+	LeftoverText  string  `json:"leftover_text,omitempty"`
+	Message       string  `json:"message"`
+}
+
+type accountPlanChangeReqJSON struct {
+	Token         string `json:"token"`
+	UserServiceID int    `json:"user_service_id"`
+	ServiceID     int    `json:"service_id"`
+	Expire        string `json:"expire"`
+}
+
+type accountPlanChangeOKJSON struct {
+	Status string `json:"status"`
+	// UserServiceID — новая услуга; PreviousUserServiceID — снятая.
+	UserServiceID         int     `json:"user_service_id"`
+	PreviousUserServiceID int     `json:"previous_user_service_id"`
+	ServiceID             int     `json:"service_id"`
+	Name                  string  `json:"name"`
+	Credit                float64 `json:"credit"`
+	Message               string  `json:"message"`
+}
+
+// accountPlanMoney — сумма в рублях на языке страницы.
+func accountPlanMoney(locale accountLocale, v float64) string {
+	if locale == accountLocaleEN {
+		return formatServiceOrderRUBAmountEN(v) + " RUB"
+	}
+	return models.FormatRubAmount(v)
+}
+
+func accountPlanChangeQuoteFrom(locale accountLocale, q *appService.PlanChangeQuote) accountPlanChangeQuoteJSON {
+	out := accountPlanChangeQuoteJSON{
+		UserServiceID: q.UserServiceID,
+		FromName:      q.FromName,
+		ServiceID:     q.ToServiceID,
+		Name:          q.ToName,
+		Expire:        q.Expire,
+		RemainingDays: math.Round(q.Remaining.Hours()/24*10) / 10,
+		Credit:        q.Credit,
+		CreditText:    accountPlanMoney(locale, q.Credit),
+		Cost:          q.Cost,
+		CostText:      accountPlanMoney(locale, q.Cost),
+		Balance:       q.Balance,
+		Covered:       q.Due() == 0,
+	}
+	if d := q.Due(); d > 0 {
+		out.Due, out.DueText = d, accountPlanMoney(locale, d)
+	}
+	if l := q.Leftover(); l > 0 {
+		out.Leftover, out.LeftoverText = l, accountPlanMoney(locale, l)
+	}
+	out.Message = accountPlanChangeQuoteMessage(locale, out)
+	return out
+}
+
+func accountPlanChangeQuoteMessage(locale accountLocale, q accountPlanChangeQuoteJSON) string {
+	days := strconv.FormatFloat(q.RemainingDays, 'f', -1, 64)
+	if locale == accountLocaleEN {
+		msg := fmt.Sprintf("The unused %s days of «%s» are worth %s and will be credited to your balance. «%s» costs %s.", days, q.FromName, q.CreditText, q.Name, q.CostText)
+		switch {
+		case q.Due > 0:
+			msg += " Your balance is " + q.DueText + " short — top up first."
+		case q.Leftover > 0:
+			msg += " " + q.LeftoverText + " of the credit will stay on your balance."
+		}
+		return msg
+	}
+	msg := fmt.Sprintf("Неиспользованные %s дн. тарифа «%s» — это %s, они вернутся на баланс. Тариф «%s» стоит %s.", days, q.FromName, q.CreditText, q.Name, q.CostText)
+	switch {
+	case q.Due > 0:
+		msg += " На балансе не хватает " + q.DueText + " — сначала пополните баланс."
+	case q.Leftover > 0:
+		msg += " " + q.LeftoverText + " из зачтённой суммы останется на балансе."
+	}
+	return msg
+}
+
+func accountPlanChangeMessage(locale accountLocale, pc *appService.PlanChange) string {
+	if locale == accountLocaleEN {
+		return fmt.Sprintf("The plan is changed to «%s». %s was credited for the unused days.", pc.ToName, accountPlanMoney(locale, pc.Credit))
+	}
+	return fmt.Sprintf("Тариф сменён на «%s». За неиспользованные дни зачтено %s.", pc.ToName, accountPlanMoney(locale, pc.Credit))
+}
+
+// accountPlanChangeError — HTTP-статус и код ошибки смены тарифа для API.
+func accountPlanChangeError(err error) (int, string) {
+	switch {
+	case errors.Is(err, appService.ErrUserServiceUnavailable):
+		return http.StatusForbidden, "forbidden"
+	case errors.Is(err, appService.ErrServiceCategoryDenied):
+		return http.StatusBadRequest, "invalid_service"
+	case errors.Is(err, appService.ErrPlanChangeUnavailable):
+		return http.StatusConflict, "plan_change_unavailable"
+	case errors.Is(err, appService.ErrPlanChangeStale):
+		return http.StatusConflict, "plan_change_stale"
+	case errors.Is(err, appService.ErrPlanChangeInsufficientBalance):
+		return http.StatusConflict, "insufficient_balance"
+	case errors.Is(err, appService.ErrPlanChangeInProgress):
+		return http.StatusConflict, "plan_change_in_progress"
+	case errors.Is(err, appService.ErrPlanChangeOrderFailed):
+		return http.StatusInternalServerError, "plan_change_order_failed"
+	case errors.Is(err, appService.ErrPlanChangeRetireFailed):
+		return http.StatusInternalServerError, "plan_change_retire_failed"
+	}
+	return http.StatusInternalServerError, "plan_change_failed"
+}
+
+// serveAccountServiceChangeQuote — GET /api/account/service/change/quote?token=…&user_service_id=…&service_id=…: расчёт смены тарифа.
+func serveAccountServiceChangeQuote(cfg *config.Config, app accountWebApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/account/service/change/quote" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed")
+			return
+		}
+		if !webSalesTokenFlowAvailable(cfg) || !cfg.PlanChange.Enabled {
+			writeJSONError(w, http.StatusNotFound, "not_found")
+			return
+		}
+
+		claims, _, err := authenticateWebAccount(cfg, app, strings.TrimSpace(r.URL.Query().Get("token")))
+		if err != nil {
+			writeAccountAuthError(w, err)
+			return
+		}
+		usID, err1 := strconv.Atoi(strings.TrimSpace(r.URL.Query().Get("user_service_id")))
+		sID, err2 := strconv.Atoi(strings.TrimSpace(r.URL.Query().Get("service_id")))
+		if err1 != nil || err2 != nil || usID <= 0 || sID <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "invalid_service")
+			return
+		}
+
+		q, err := app.QuotePlanChange(claims.UserID, usID, sID)
+		if err != nil {
+			code, name := accountPlanChangeError(err)
+			if code == http.StatusInternalServerError {
+				slog.Error("account plan change: QuotePlanChange", "user_id", claims.UserID, "user_service_id", usID, "service_id", sID, "err", err)
+			}
+			writeJSONError(w, code, name)
+			return
+		}
+		writeJSON(w, http.StatusOK, accountPlanChangeQuoteFrom(resolveAccountLocale(r), q))
+	}
+}
+
+// serveAccountServiceChange — POST /api/account/service/change {token, user_service_id, service_id, expire}: смена тарифа.
+func serveAccountServiceChange(cfg *config.Config, app accountWebApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/account/service/change" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed")
+			return
+		}
+		if !webSalesTokenFlowAvailable(cfg) || !cfg.PlanChange.Enabled {
+			writeJSONError(w, http.StatusNotFound, "not_found")
+			return
+		}
+
+		var req accountPlanChangeReqJSON
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<16)).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "bad_request")
+			return
+		}
+		claims, _, err := authenticateWebAccount(cfg, app, req.Token)
+		if err != nil {
+			writeAccountAuthError(w, err)
+			return
+		}
+		if req.UserServiceID <= 0 || req.ServiceID <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "invalid_service")
+			return
+		}
+
+		pc, err := app.ChangePlan(appService.PlanChangeRequest{
+			UserID:        claims.UserID,
+			UserServiceID: req.UserServiceID,
+			ServiceID:     req.ServiceID,
+			Expire:        req.Expire,
+			PaySystem:     cfg.PlanChange.SHMPaySystem(),
+		})
+		if err != nil {
+			code, name := accountPlanChangeError(err)
+			if code == http.StatusInternalServerError {
+				slog.Error("account plan change: ChangePlan", "user_id", claims.UserID, "user_service_id", req.UserServiceID, "service_id", req.ServiceID, "err", err)
+			}
+			writeJSONError(w, code, name)
+			return
+		}
+		writeJSON(w, http.StatusOK, accountPlanChangeOKJSON{
+			Status:                "changed",
+			UserServiceID:         pc.NewUserServiceID,
+			PreviousUserServiceID: pc.UserServiceID,
+			ServiceID:             pc.ToServiceID,
+			Name:                  pc.ToName,
+			Credit:                pc.Credit,
+			Message:               accountPlanChangeMessage(resolveAccountLocale(r), pc),
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/config"
+	appService "github.com/ryabkov82/vpnbot/internal/service"
+)
+
+func planChangeTestCfg() *config.Config {
+	cfg := orderStartTestCfg()
+	cfg.PlanChange.Enabled = true
+	return cfg
+}
+
+func TestServeAccountServiceChangeQuote(t *testing.T) {
+	cfg := planChangeTestCfg()
+	h := func(st *stubAccountWeb) http.HandlerFunc { return serveAccountServiceChangeQuote(cfg, st) }
+	st := &stubAccountWeb{planQuote: &appService.PlanChangeQuote{
+		UserServiceID: 336, FromName: "Basic 1m", ToServiceID: 7, ToName: "Premium 1m", Expire: "2026-11-01 10:00:00",
+		Remaining: 15 * 24 * time.Hour, Credit: 150, Cost: 450, Balance: 100,
+	}}
+	rec := getAccountWithToken(t, h, st, "/api/account/service/change/quote", "&user_service_id=336&service_id=7")
+	if rec.Code != http.StatusOK || st.planQuoteArg != [3]int{701, 336, 7} {
+		t.Fatalf("%d %s %v", rec.Code, rec.Body.String(), st.planQuoteArg)
+	}
+	var out accountPlanChangeQuoteJSON
+	if err := json.NewDecoder(rec.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Covered || out.Due != 200 || out.DueText != "200 ₽" || out.RemainingDays != 15 || out.Expire != "2026-11-01 10:00:00" ||
+		!strings.Contains(out.Message, "150 ₽") || !strings.Contains(out.Message, "не хватает 200 ₽") {
+		t.Fatalf("%+v", out)
+	}
+
+	if rec := getAccountWithToken(t, h, st, "/api/account/service/change/quote", "&user_service_id=336"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("no target: %d", rec.Code)
+	}
+	off := orderStartTestCfg()
+	if rec := getAccountWithToken(t, func(st *stubAccountWeb) http.HandlerFunc { return serveAccountServiceChangeQuote(off, st) }, st,
+		"/api/account/service/change/quote", "&user_service_id=336&service_id=7"); rec.Code != http.StatusNotFound {
+		t.Fatalf("disabled: %d", rec.Code)
+	}
+}
+
+func TestServeAccountServiceChange(t *testing.T) {
+	cfg := planChangeTestCfg()
+	st := &stubAccountWeb{planRet: &appService.PlanChange{
+		UserServiceID: 336, ToServiceID: 7, ToName: "Premium 1m", Credit: 150, NewUserServiceID: 901, Status: appService.PlanChangeDone,
+	}}
+	rec := postAccountRenewal(t, serveAccountServiceChange(cfg, st), "/api/account/service/change",
+		map[string]interface{}{"user_service_id": 336, "service_id": 7, "expire": "2026-11-01 10:00:00"})
+	if rec.Code != http.StatusOK || len(st.planReqs) != 1 {
+		t.Fatalf("%d %s", rec.Code, rec.Body.String())
+	}
+	if req := st.planReqs[0]; req.UserID != 701 || req.UserServiceID != 336 || req.ServiceID != 7 || req.Expire != "2026-11-01 10:00:00" || req.PaySystem != "plan_change" {
+		t.Fatalf("%+v", req)
+	}
+	var out accountPlanChangeOKJSON
+	if err := json.NewDecoder(rec.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Status != "changed" || out.UserServiceID != 901 || out.PreviousUserServiceID != 336 || !strings.Contains(out.Message, "Premium 1m") {
+		t.Fatalf("%+v", out)
+	}
+
+	for _, tc := range []struct {
+		err  error
+		code int
+		name string
+	}{
+		{appService.ErrPlanChangeStale, http.StatusConflict, "plan_change_stale"},
+		{appService.ErrPlanChangeInsufficientBalance, http.StatusConflict, "insufficient_balance"},
+		{appService.ErrPlanChangeUnavailable, http.StatusConflict, "plan_change_unavailable"},
+		{appService.ErrServiceCategoryDenied, http.StatusBadRequest, "invalid_service"},
+		{appService.ErrUserServiceUnavailable, http.StatusForbidden, "forbidden"},
+		{appService.ErrPlanChangeOrderFailed, http.StatusInternalServerError, "plan_change_order_failed"},
+		{appService.ErrPlanChangeRetireFailed, http.StatusInternalServerError, "plan_change_retire_failed"},
+	} {
+		rec := postAccountRenewal(t, serveAccountServiceChange(cfg, &stubAccountWeb{planErr: tc.err}), "/api/account/service/change",
+			map[string]interface{}{"user_service_id": 336, "service_id": 7})
+		if rec.Code != tc.code {
+			t.Fatalf("%v: %d", tc.err, rec.Code)
+		}
+		assertJSONErrorField(t, rec.Body.String(), tc.name)
+	}
+}
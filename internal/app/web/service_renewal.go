@@ -0,0 +1,212 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/ryabkov82/vpnbot/internal/config"
+	"github.com/ryabkov82/vpnbot/internal/models"
+	appService "github.com/ryabkov82/vpnbot/internal/service"
+)
+
+// accountRenewalJSON — следующее продление в строке /api/account/services и ответах autorenew/renew.
+type accountRenewalJSON struct {
+	// NextChargeAt — дата списания (окончание периода); пусто для заблокированной услуги — она продлится при пополнении.
+	NextChargeAt  string  `json:"next_charge_at,omitempty"`
+	Expire        string  `json:"expire"`
+	Amount        float64 `json:"amount"`
+	AmountText    string  `json:"amount_text"`
+	Covered       bool    `json:"covered"`
+	Shortfall     float64 `json:"shortfall,omitempty"`
+	ShortfallText string  `json:"shortfall_text,omitempty"`
+}
+
+// accountRenewalFromPreview — nil, если у услуги нет следующего периода.
+func accountRenewalFromPreview(p appService.RenewalPreview) *accountRenewalJSON {
+	if !p.Renewable() {
+		return nil
+	}
+	out := &accountRenewalJSON{
+		Expire:     p.Expire,
+		Amount:     p.Amount,
+		AmountText: models.FormatRubAmount(p.Amount),
+		Covered:    p.Covered(),
+	}
+	if p.Status == "ACTIVE" && !p.NextChargeAt.IsZero() {
+		out.NextChargeAt = p.NextChargeAt.Format("2006-01-02 15:04")
+	}
+	if d := p.Shortfall(); d > 0 {
+		out.Shortfall = d
+		out.ShortfallText = models.FormatRubAmount(d)
+	}
+	return out
+}
+
+// accountRenewalMessage — итог переключения автопродления или продления на языке страницы.
+func accountRenewalMessage(locale accountLocale, p *appService.RenewalPreview, renewed bool) string {
+	when := p.Expire
+	if !p.NextChargeAt.IsZero() {
+		when = p.NextChargeAt.Format("02.01.2006")
+	}
+	if locale == accountLocaleEN {
+		switch {
+		case renewed:
+			return "The service is renewed until " + when + "."
+		case !p.AutoRenew:
+			return "Auto-renewal is off. The service works until " + when + " and will not renew automatically."
+		case p.Renewable() && !p.Covered():
+			return "Auto-renewal is on. Your balance is " + formatServiceOrderRUBAmountEN(p.Shortfall()) + " RUB short of the next period — top up before " + when + "."
+		}
+		return "Auto-renewal is on."
+	}
+	switch {
+	case renewed:
+		return "Услуга продлена до " + when + "."
+	case !p.AutoRenew:
+		return "Автопродление выключено. Услуга работает до " + when + " и не продлится автоматически."
+	case p.Renewable() && !p.Covered():
+		return "Автопродление включено. На следующий период не хватает " + models.FormatRubAmount(p.Shortfall()) + " — пополните баланс до " + when + "."
+	}
+	return "Автопродление включено."
+}
+
+// accountRenewalError — HTTP-статус и код ошибки автопродления/продления для API.
+func accountRenewalError(err error) (int, string) {
+	switch {
+	case errors.Is(err, appService.ErrUserServiceUnavailable):
+		return http.StatusForbidden, "forbidden"
+	case errors.Is(err, appService.ErrRenewalStale):
+		return http.StatusConflict, "renewal_stale"
+	case errors.Is(err, appService.ErrRenewalUnavailable):
+		return http.StatusConflict, "renewal_unavailable"
+	case errors.Is(err, appService.ErrRenewalInsufficientBalance):
+		return http.StatusConflict, "insufficient_balance"
+	}
+	return http.StatusInternalServerError, "renewal_failed"
+}
+
+type accountServiceAutoRenewReqJSON struct {
+	Token         string `json:"token"`
+	UserServiceID int    `json:"user_service_id"`
+	Enabled       *bool  `json:"enabled"`
+}
+
+type accountServiceRenewReqJSON struct {
+	Token         string `json:"token"`
+	UserServiceID int    `json:"user_service_id"`
+	// Expire — renewal.expire из /api/account/services: защищает от двойного продления повторным нажатием.
+	Expire string `json:"expire"`
+}
+
+type accountServiceRenewalOKJSON struct {
+	Status        string              `json:"status"`
+	UserServiceID int                 `json:"user_service_id"`
+	AutoRenew     bool                `json:"auto_renew"`
+	Expire        string              `json:"expire"`
+	Renewal       *accountRenewalJSON `json:"renewal,omitempty"`
+	Message       string              `json:"message"`
+}
+
+func accountServiceRenewalOK(r *http.Request, status string, p *appService.RenewalPreview) accountServiceRenewalOKJSON {
+	return accountServiceRenewalOKJSON{
+		Status:        status,
+		UserServiceID: p.UserServiceID,
+		AutoRenew:     p.AutoRenew,
+		Expire:        p.Expire,
+		Renewal:       accountRenewalFromPreview(*p),
+		Message:       accountRenewalMessage(resolveAccountLocale(r), p, status == "renewed"),
+	}
+}
+
+// serveAccountServiceAutoRenew — POST /api/account/service/autorenew {token, user_service_id, enabled}.
+func serveAccountServiceAutoRenew(cfg *config.Config, app accountWebApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/account/service/autorenew" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed")
+			return
+		}
+		if !webSalesTokenFlowAvailable(cfg) {
+			writeJSONError(w, http.StatusNotFound, "not_found")
+			return
+		}
+
+		var req accountServiceAutoRenewReqJSON
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<16)).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "bad_request")
+			return
+		}
+		claims, _, err := authenticateWebAccount(cfg, app, req.Token)
+		if err != nil {
+			writeAccountAuthError(w, err)
+			return
+		}
+		if req.UserServiceID <= 0 || req.Enabled == nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid_service")
+			return
+		}
+
+		p, err := app.SetAutoRenew(claims.UserID, req.UserServiceID, *req.Enabled)
+		if err != nil {
+			code, name := accountRenewalError(err)
+			if code == http.StatusInternalServerError {
+				slog.Error("account autorenew: SetAutoRenew", "user_id", claims.UserID, "user_service_id", req.UserServiceID, "err", err)
+			}
+			writeJSONError(w, code, name)
+			return
+		}
+		writeJSON(w, http.StatusOK, accountServiceRenewalOK(r, "ok", p))
+	}
+}
+
+// serveAccountServiceRenew — POST /api/account/service/renew {token, user_service_id, expire}: продление с баланса.
+func serveAccountServiceRenew(cfg *config.Config, app accountWebApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/account/service/renew" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed")
+			return
+		}
+		if !webSalesTokenFlowAvailable(cfg) {
+			writeJSONError(w, http.StatusNotFound, "not_found")
+			return
+		}
+
+		var req accountServiceRenewReqJSON
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<16)).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "bad_request")
+			return
+		}
+		claims, _, err := authenticateWebAccount(cfg, app, req.Token)
+		if err != nil {
+			writeAccountAuthError(w, err)
+			return
+		}
+		if req.UserServiceID <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "invalid_service")
+			return
+		}
+
+		p, err := app.RenewUserService(claims.UserID, req.UserServiceID, req.Expire)
+		if err != nil {
+			code, name := accountRenewalError(err)
+			if code == http.StatusInternalServerError {
+				slog.Error("account renew: RenewUserService", "user_id", claims.UserID, "user_service_id", req.UserServiceID, "err", err)
+			}
+			writeJSONError(w, code, name)
+			return
+		}
+		writeJSON(w, http.StatusOK, accountServiceRenewalOK(r, "renewed", p))
+	}
+}
@@ -0,0 +1,115 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/models"
+	appService "github.com/ryabkov82/vpnbot/internal/service"
+)
+
+func postAccountRenewal(t *testing.T, h http.HandlerFunc, path string, body map[string]interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	cfg := orderStartTestCfg()
+	tok, err := CreateAccountToken(cfg.WebSales.OrderTokenSecret, "vff", "a@b.c", 701, "web_xx", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body["token"] = tok
+	raw, _ := json.Marshal(body)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(string(raw))))
+	return rec
+}
+
+func TestServeAccountServices_RenewalPreview(t *testing.T) {
+	st := &stubAccountWeb{
+		balance: &models.UserBalance{Balance: 100},
+		services: []models.UserService{{
+			Name: "1 мес", ServiceID: 336, BaseServiceID: 3, Status: "ACTIVE", Cost: "450",
+			Expire: "2026-11-01 10:00:00", Category: "vpn-mz-test", SettingsRaw: json.RawMessage(`{"auto_renew":false}`),
+		}},
+	}
+	rec := getAccountWithToken(t, func(st *stubAccountWeb) http.HandlerFunc { return serveAccountServices(orderStartTestCfg(), st) }, st, "/api/account/services", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%d %s", rec.Code, rec.Body.String())
+	}
+	var env accountServicesOKJSON
+	if err := json.NewDecoder(rec.Body).Decode(&env); err != nil {
+		t.Fatal(err)
+	}
+	row := env.Services[0]
+	if row.AutoRenew || row.Renewal == nil {
+		t.Fatalf("%+v", row)
+	}
+	if r := row.Renewal; r.NextChargeAt != "2026-11-01 10:00" || r.Amount != 450 || r.Covered || r.Shortfall != 350 || r.ShortfallText != "350 ₽" || r.Expire != "2026-11-01 10:00:00" {
+		t.Fatalf("%+v", r)
+	}
+}
+
+func TestServeAccountServiceAutoRenew(t *testing.T) {
+	cfg := orderStartTestCfg()
+	st := &stubAccountWeb{renewalRet: &appService.RenewalPreview{
+		UserServiceID: 336, Status: "ACTIVE", Expire: "2026-11-01 10:00:00", NextChargeAt: time.Date(2026, 11, 1, 10, 0, 0, 0, time.UTC),
+		Amount: 450, Balance: 100, AutoRenew: true,
+	}}
+	rec := postAccountRenewal(t, serveAccountServiceAutoRenew(cfg, st), "/api/account/service/autorenew", map[string]interface{}{"user_service_id": 336, "enabled": true})
+	if rec.Code != http.StatusOK || len(st.autoRenewArg) != 3 || st.autoRenewArg[0] != 701 || st.autoRenewArg[1] != 336 || st.autoRenewArg[2] != true {
+		t.Fatalf("%d %s %v", rec.Code, rec.Body.String(), st.autoRenewArg)
+	}
+	var out accountServiceRenewalOKJSON
+	if err := json.NewDecoder(rec.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !out.AutoRenew || out.Renewal == nil || out.Renewal.Covered || !strings.Contains(out.Message, "350 ₽") {
+		t.Fatalf("%+v", out)
+	}
+
+	if rec := postAccountRenewal(t, serveAccountServiceAutoRenew(cfg, st), "/api/account/service/autorenew", map[string]interface{}{"user_service_id": 336}); rec.Code != http.StatusBadRequest {
+		t.Fatalf("missing enabled: %d", rec.Code)
+	}
+	rec = postAccountRenewal(t, serveAccountServiceAutoRenew(cfg, &stubAccountWeb{renewalErr: appService.ErrUserServiceUnavailable}), "/api/account/service/autorenew", map[string]interface{}{"user_service_id": 9, "enabled": false})
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("foreign: %d", rec.Code)
+	}
+}
+
+func TestServeAccountServiceRenew(t *testing.T) {
+	cfg := orderStartTestCfg()
+	st := &stubAccountWeb{renewalRet: &appService.RenewalPreview{
+		UserServiceID: 336, Status: "ACTIVE", Expire: "2026-12-01 10:00:00", NextChargeAt: time.Date(2026, 12, 1, 10, 0, 0, 0, time.UTC),
+		Amount: 450, Balance: 550, AutoRenew: true,
+	}}
+	rec := postAccountRenewal(t, serveAccountServiceRenew(cfg, st), "/api/account/service/renew", map[string]interface{}{"user_service_id": 336, "expire": "2026-11-01 10:00:00"})
+	if rec.Code != http.StatusOK || st.renewArg[2] != "2026-11-01 10:00:00" {
+		t.Fatalf("%d %s %v", rec.Code, rec.Body.String(), st.renewArg)
+	}
+	var out accountServiceRenewalOKJSON
+	if err := json.NewDecoder(rec.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Status != "renewed" || out.Expire != "2026-12-01 10:00:00" || !strings.Contains(out.Message, "01.12.2026") {
+		t.Fatalf("%+v", out)
+	}
+
+	for _, tc := range []struct {
+		err  error
+		code int
+		name string
+	}{
+		{appService.ErrRenewalStale, http.StatusConflict, "renewal_stale"},
+		{appService.ErrRenewalUnavailable, http.StatusConflict, "renewal_unavailable"},
+		{appService.ErrRenewalInsufficientBalance, http.StatusConflict, "insufficient_balance"},
+		{appService.ErrUserServiceUnavailable, http.StatusForbidden, "forbidden"},
+	} {
+		rec := postAccountRenewal(t, serveAccountServiceRenew(cfg, &stubAccountWeb{renewalErr: tc.err}), "/api/account/service/renew", map[string]interface{}{"user_service_id": 336})
+		if rec.Code != tc.code {
+			t.Fatalf("%v: %d", tc.err, rec.Code)
+		}
+		assertJSONErrorField(t, rec.Body.String(), tc.name)
+	}
+}
@@ -0,0 +1,201 @@
+package web
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/ryabkov82/vpnbot/internal/config"
+	"github.com/ryabkov82/vpnbot/internal/models"
+	"github.com/ryabkov82/vpnbot/internal/proxylink"
+	appService "github.com/ryabkov82/vpnbot/internal/service"
+)
+
+// Форматы /sub/<token>.
+const (
+	subscriptionFormatBase64  = "base64"
+	subscriptionFormatClash   = "clash"
+	subscriptionFormatSingBox = "singbox"
+)
+
+// subscriptionProfileUpdateHours — Profile-Update-Interval для клиентов (часы).
+const subscriptionProfileUpdateHours = 12
+
+// subscriptionFormatFor выбирает формат: явный ?format= важнее User-Agent; по умолчанию base64.
+func subscriptionFormatFor(format, userAgent string) string {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "base64", "v2ray", "v2rayn":
+		return subscriptionFormatBase64
+	case "clash", "clash-meta", "clashmeta", "mihomo", "yaml":
+		return subscriptionFormatClash
+	case "singbox", "sing-box", "json":
+		return subscriptionFormatSingBox
+	}
+	ua := strings.ToLower(userAgent)
+	switch {
+	case strings.Contains(ua, "clash"), strings.Contains(ua, "mihomo"), strings.Contains(ua, "stash"):
+		return subscriptionFormatClash
+	case strings.Contains(ua, "sing-box"), strings.Contains(ua, "hiddify"),
+		strings.HasPrefix(ua, "sfa"), strings.HasPrefix(ua, "sfi"), strings.HasPrefix(ua, "sfm"):
+		return subscriptionFormatSingBox
+	}
+	return subscriptionFormatBase64
+}
+
+// subscriptionProfileTitle — название профиля в клиенте: имя бренда, иначе brand.id.
+func subscriptionProfileTitle(cfg *config.Config) string {
+	if cfg == nil {
+		return ""
+	}
+	if name := strings.TrimSpace(cfg.EffectiveBrand().Name); name != "" {
+		return name
+	}
+	return cfgBrandID(cfg)
+}
+
+// subscriptionUserInfo — значение Subscription-Userinfo; пусто, если срок услуги неизвестен.
+// Трафик Marzban-услуг в SHM не учитывается, поэтому upload/download/total = 0 (без лимита).
+func subscriptionUserInfo(us *models.UserService) string {
+	if us == nil {
+		return ""
+	}
+	exp, err := parseSHMExpireMoscow(us.Expire)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("upload=0; download=0; total=0; expire=%d", exp.Unix())
+}
+
+// BuildAccountSubscriptionURL — публичная ссылка /sub/<token> для услуги; format пустой — автоопределение клиентом.
+func BuildAccountSubscriptionURL(cfg *config.Config, r *http.Request, shmUserID, userServiceID int, format string) (string, error) {
+	base := strings.TrimRight(publicOrderBaseURL(cfg, r), "/")
+	if base == "" {
+		return "", errors.New("public base url is not configured")
+	}
+	tok, err := CreateAccountSubscriptionToken(cfg.WebSales.OrderTokenSecret, cfgBrandID(cfg), shmUserID, userServiceID, accountSubscriptionTokenTTL)
+	if err != nil {
+		return "", err
+	}
+	u := base + "/sub/" + url.PathEscape(tok)
+	if format != "" {
+		u += "?format=" + url.QueryEscape(format)
+	}
+	return u, nil
+}
+
+// serveSubscription — GET|HEAD /sub/<token>: ключи Marzban услуги в base64, Clash Meta YAML или sing-box JSON.
+// Владение услугой проверяется на каждый запрос, поэтому удалённая или чужая услуга перестаёт отдаваться сразу.
+func serveSubscription(cfg *config.Config, app accountServiceKeysApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rawTok, ok := strings.CutPrefix(r.URL.Path, "/sub/")
+		if !ok || rawTok == "" || strings.Contains(rawTok, "/") {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed")
+			return
+		}
+		if !webSalesTokenFlowAvailable(cfg) {
+			writeJSONError(w, http.StatusNotFound, "not_found")
+			return
+		}
+
+		claims, err := VerifyAccountSubscriptionToken(cfg.WebSales.OrderTokenSecret, cfgBrandID(cfg), rawTok)
+		if err != nil {
+			writeAccountAuthError(w, err)
+			return
+		}
+		usID := strconv.Itoa(claims.UserServiceID)
+		us, err := app.GetOwnedUserServiceByUserID(claims.ShmUserID, usID)
+		if err != nil {
+			if errors.Is(err, appService.ErrUserServiceUnavailable) {
+				writeJSONError(w, http.StatusForbidden, "forbidden")
+				return
+			}
+			slog.Error("subscription: GetOwnedUserServiceByUserID", "err", err)
+			writeJSONError(w, http.StatusInternalServerError, "internal_error")
+			return
+		}
+		if models.IsPremiumAntiBlockUserService(us, cfg.PremiumSquadName) {
+			writeJSONError(w, http.StatusForbidden, "premium_keys_unavailable")
+			return
+		}
+		if !strings.EqualFold(strings.TrimSpace(us.Status), "ACTIVE") || !strings.HasPrefix(strings.TrimSpace(us.Category), "vpn-mz-") {
+			writeJSONError(w, http.StatusConflict, "not_ready")
+			return
+		}
+		key, err := app.GetUserKeyMarzbanByUserID(claims.ShmUserID, usID)
+		if err != nil {
+			slog.Error("subscription: GetUserKeyMarzbanByUserID", "err", err, "user_id", claims.ShmUserID, "user_service_id", claims.UserServiceID)
+			writeJSONError(w, http.StatusBadGateway, "keys_unavailable")
+			return
+		}
+
+		title := subscriptionProfileTitle(cfg)
+		format := subscriptionFormatFor(r.URL.Query().Get("format"), r.Header.Get("User-Agent"))
+		var (
+			body        []byte
+			contentType string
+			ext         string
+			skipped     []*proxylink.Link
+		)
+		switch format {
+		case subscriptionFormatClash:
+			body, skipped = proxylink.RenderClashMeta(parseSubscriptionLinks(key.Links), title)
+			contentType, ext = "text/yaml; charset=utf-8", "yaml"
+		case subscriptionFormatSingBox:
+			body, skipped, err = proxylink.RenderSingBox(parseSubscriptionLinks(key.Links), title)
+			if err != nil {
+				slog.Error("subscription: render sing-box", "err", err)
+				writeJSONError(w, http.StatusInternalServerError, "internal_error")
+				return
+			}
+			contentType, ext = "application/json; charset=utf-8", "json"
+		default:
+			body = proxylink.RenderBase64(key.Links)
+			contentType, ext = "text/plain; charset=utf-8", "txt"
+		}
+		if len(skipped) > 0 {
+			slog.Info("subscription: links skipped for format", "format", format, "skipped", len(skipped), "user_service_id", claims.UserServiceID)
+		}
+
+		h := w.Header()
+		h.Set("Content-Type", contentType)
+		h.Set("Cache-Control", "no-store")
+		h.Set("Profile-Title", "base64:"+base64.StdEncoding.EncodeToString([]byte(title)))
+		h.Set("Profile-Update-Interval", strconv.Itoa(subscriptionProfileUpdateHours))
+		h.Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s.%s"`, cfgBrandID(cfg), ext))
+		if info := subscriptionUserInfo(us); info != "" {
+			h.Set("Subscription-Userinfo", info)
+		}
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodHead {
+			return
+		}
+		_, _ = w.Write(body)
+	}
+}
+
+// parseSubscriptionLinks разбирает ссылки подписки; нераспознанные пропускаются с записью в лог.
+func parseSubscriptionLinks(raws []string) []*proxylink.Link {
+	out := make([]*proxylink.Link, 0, len(raws))
+	for _, raw := range raws {
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		l, err := proxylink.Parse(raw)
+		if err != nil {
+			slog.Warn("subscription: unparsable link", "err", err)
+			continue
+		}
+		out = append(out, l)
+	}
+	return out
+}
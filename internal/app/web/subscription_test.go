@@ -0,0 +1,164 @@
+package web
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/models"
+)
+
+func TestSubscriptionFormatFor(t *testing.T) {
+	cases := []struct{ format, ua, want string }{
+		{"", "", subscriptionFormatBase64},
+		{"", "v2rayNG/1.8", subscriptionFormatBase64},
+		{"", "ClashMetaForAndroid/2.10", subscriptionFormatClash},
+		{"", "mihomo/1.18", subscriptionFormatClash},
+		{"", "SFA/1.9.0 (sing-box 1.9.0)", subscriptionFormatSingBox},
+		{"", "HiddifyNext/2.0", subscriptionFormatSingBox},
+		{"clash", "sing-box", subscriptionFormatClash},
+		{"sing-box", "", subscriptionFormatSingBox},
+		{"v2ray", "clash", subscriptionFormatBase64},
+		{"bogus", "", subscriptionFormatBase64},
+	}
+	for _, c := range cases {
+		if got := subscriptionFormatFor(c.format, c.ua); got != c.want {
+			t.Fatalf("%q/%q: got %q want %q", c.format, c.ua, got, c.want)
+		}
+	}
+}
+
+func TestAccountSubscriptionToken_RoundTripAndType(t *testing.T) {
+	secret := "order-token-secret-order-token-sec"
+	tok, err := CreateAccountSubscriptionToken(secret, "vff", 10, 336, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := VerifyAccountSubscriptionToken(secret, "vff", tok)
+	if err != nil || c.ShmUserID != 10 || c.UserServiceID != 336 {
+		t.Fatalf("%+v %v", c, err)
+	}
+	if _, err := VerifyAccountSubscriptionToken(secret, "other", tok); err != ErrAccountTokenBrand {
+		t.Fatalf("brand: %v", err)
+	}
+	acc, _ := CreateAccountToken(secret, "vff", "me@test.com", 10, "web_aa", time.Hour)
+	if _, err := VerifyAccountSubscriptionToken(secret, "vff", acc); err != ErrAccountTokenType {
+		t.Fatalf("type: %v", err)
+	}
+	old, _ := CreateAccountSubscriptionToken(secret, "vff", 10, 336, -time.Minute)
+	if _, err := VerifyAccountSubscriptionToken(secret, "vff", old); err != ErrAccountTokenExpired {
+		t.Fatalf("exp: %v", err)
+	}
+}
+
+func subscriptionTestStub() *stubAccountKeys {
+	return &stubAccountKeys{stubAccountWeb: &stubAccountWeb{single: map[int]*models.UserService{
+		336: {
+			UserID: 10, ServiceID: 336, Status: "ACTIVE", Category: "vpn-mz-main", Expire: "2026-12-01 00:00:00",
+			KeyMarzban: models.UserKeyMarzban{Links: []string{
+				"vless://uuid-1@nl.example:443?security=reality&type=tcp&pbk=PUB#NL",
+				"ss://YWVzLTI1Ni1nY206cHdAMS4yLjMuNDo4Mzg4#LV",
+			}},
+		},
+		337: {
+			UserID: 10, ServiceID: 337, Status: "ACTIVE", Category: "vpn-mz-main",
+			ConfigRaw: `{"remnawave":{"internal_squad_name":"premium-squad"}}`,
+		},
+	}}}
+}
+
+func subscriptionRequest(t *testing.T, st *stubAccountKeys, method string, userID, usID int, query, ua string) *httptest.ResponseRecorder {
+	t.Helper()
+	cfg := categoryTestCfg("vpn-mz-main")
+	cfg.PremiumSquadName = "premium-squad"
+	tok, err := CreateAccountSubscriptionToken(cfg.WebSales.OrderTokenSecret, "vff", userID, usID, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(method, "/sub/"+tok+query, nil)
+	if ua != "" {
+		req.Header.Set("User-Agent", ua)
+	}
+	rec := httptest.NewRecorder()
+	serveSubscription(cfg, st).ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServeSubscription_Base64WithHeaders(t *testing.T) {
+	rec := subscriptionRequest(t, subscriptionTestStub(), http.MethodGet, 10, 336, "", "v2rayNG/1.8")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("want 200 got %d: %s", rec.Code, rec.Body.String())
+	}
+	dec, err := base64.StdEncoding.DecodeString(rec.Body.String())
+	if err != nil || !strings.HasPrefix(string(dec), "vless://uuid-1@nl.example") || !strings.Contains(string(dec), "\nss://") {
+		t.Fatalf("%q %v", dec, err)
+	}
+	// 2026-12-01 00:00 MSK = 2026-11-30 21:00 UTC.
+	if got := rec.Header().Get("Subscription-Userinfo"); got != "upload=0; download=0; total=0; expire=1796072400" {
+		t.Fatalf("userinfo %q", got)
+	}
+	title, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(rec.Header().Get("Profile-Title"), "base64:"))
+	if string(title) != categoryTestCfg("").EffectiveBrand().Name {
+		t.Fatalf("title %q", title)
+	}
+	if rec.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("cache %q", rec.Header().Get("Cache-Control"))
+	}
+}
+
+func TestServeSubscription_ClashByUserAgent(t *testing.T) {
+	rec := subscriptionRequest(t, subscriptionTestStub(), http.MethodGet, 10, 336, "", "clash.meta")
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/yaml") {
+		t.Fatalf("%d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(rec.Body.String(), "reality-opts:") || !strings.Contains(rec.Body.String(), `name: "LV"`) {
+		t.Fatalf("%s", rec.Body.String())
+	}
+}
+
+func TestServeSubscription_SingBoxByQuery(t *testing.T) {
+	rec := subscriptionRequest(t, subscriptionTestStub(), http.MethodGet, 10, 336, "?format=singbox", "clash.meta")
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "application/json") {
+		t.Fatalf("%d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(rec.Body.String(), `"type": "shadowsocks"`) {
+		t.Fatalf("%s", rec.Body.String())
+	}
+}
+
+func TestServeSubscription_HeadHasNoBody(t *testing.T) {
+	rec := subscriptionRequest(t, subscriptionTestStub(), http.MethodHead, 10, 336, "", "")
+	if rec.Code != http.StatusOK || rec.Body.Len() != 0 || rec.Header().Get("Subscription-Userinfo") == "" {
+		t.Fatalf("%d len=%d", rec.Code, rec.Body.Len())
+	}
+}
+
+func TestServeSubscription_ForeignOwnerForbidden(t *testing.T) {
+	st := subscriptionTestStub()
+	rec := subscriptionRequest(t, st, http.MethodGet, 11, 336, "", "")
+	if rec.Code != http.StatusForbidden || st.keyCalls != 0 {
+		t.Fatalf("%d calls=%d", rec.Code, st.keyCalls)
+	}
+}
+
+func TestServeSubscription_PremiumBlocked(t *testing.T) {
+	st := subscriptionTestStub()
+	rec := subscriptionRequest(t, st, http.MethodGet, 10, 337, "", "")
+	if rec.Code != http.StatusForbidden || st.keyCalls != 0 {
+		t.Fatalf("%d calls=%d", rec.Code, st.keyCalls)
+	}
+	assertJSONErrorField(t, rec.Body.String(), "premium_keys_unavailable")
+}
+
+func TestServeSubscription_AccountTokenRejected(t *testing.T) {
+	cfg := categoryTestCfg("vpn-mz-main")
+	tok, _ := CreateAccountToken(cfg.WebSales.OrderTokenSecret, "vff", "me@test.com", 10, "web_aa", time.Hour)
+	rec := httptest.NewRecorder()
+	serveSubscription(cfg, subscriptionTestStub()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/sub/"+tok, nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("want 401 got %d", rec.Code)
+	}
+}
@@ -29,3 +29,26 @@ func sendAccountWebUserRegisteredTelegramImpl(cfg *config.Config, email string,
 	b.WriteString("\n\nПользователь подтвердил email и вошел в личный кабинет.")
 	postTelegramPlainTextMessage(cfg, b.String(), "account web-user registered")
 }
+
+// accountDeletionTelegramNotifier вызывается после самостоятельного удаления аккаунта из кабинета.
+// Подменяется в тестах.
+var accountDeletionTelegramNotifier = sendAccountDeletionTelegramImpl
+
+func sendAccountDeletionTelegramImpl(cfg *config.Config, email string, userID int, login string, failedSteps int) {
+	var b strings.Builder
+	b.WriteString("🗑 Account deleted by user\n\n")
+	b.WriteString("Email: ")
+	b.WriteString(strings.TrimSpace(email))
+	b.WriteString("\nSHM user_id: ")
+	b.WriteString(strconv.Itoa(userID))
+	b.WriteString("\nLogin: ")
+	b.WriteString(strings.TrimSpace(login))
+	if failedSteps > 0 {
+		b.WriteString("\n\n⚠️ Не выполнено шагов: ")
+		b.WriteString(strconv.Itoa(failedSteps))
+		b.WriteString(". Проверьте settings.deletion в SHM и завершите вручную.")
+	} else {
+		b.WriteString("\n\nУслуги отменены, личные данные обезличены, сессии отозваны.")
+	}
+	postTelegramPlainTextMessage(cfg, b.String(), "account deletion")
+}
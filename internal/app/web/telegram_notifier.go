@@ -80,13 +80,28 @@ func buildLeadTelegramMessage(cfg *config.Config, lead publicLead, serviceName,
 	return b.String(), nil
 }
 
-// postTelegramPlainTextMessage шлёт plain text в Telegram Bot API (без parse_mode).
+// resolveSupportNotificationChatID — чат поддержки; без него — тот же чат, что для заявок.
+func resolveSupportNotificationChatID(cfg *config.Config) int64 {
+	if cfg == nil {
+		return 0
+	}
+	if cfg.Telegram.SupportChatID != 0 {
+		return cfg.Telegram.SupportChatID
+	}
+	return cfg.Telegram.LeadsChatID
+}
+
+// postTelegramPlainTextMessage шлёт plain text в Telegram Bot API (без parse_mode) в чат заявок.
 // logPrefix — префикс для slog.Warn; токен в логи не пишется.
 func postTelegramPlainTextMessage(cfg *config.Config, text string, logPrefix string) {
+	postTelegramPlainTextMessageToChat(cfg, resolveLeadNotificationChatID(cfg), text, logPrefix)
+}
+
+// postTelegramPlainTextMessageToChat — как postTelegramPlainTextMessage, но в заданный чат (0 — пропуск).
+func postTelegramPlainTextMessageToChat(cfg *config.Config, chatID int64, text string, logPrefix string) {
 	if logPrefix == "" {
 		logPrefix = "telegram"
 	}
-	chatID := resolveLeadNotificationChatID(cfg)
 	if chatID == 0 {
 		return
 	}
@@ -0,0 +1,149 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/ryabkov82/vpnbot/internal/config"
+	"github.com/ryabkov82/vpnbot/internal/models"
+	appService "github.com/ryabkov82/vpnbot/internal/service"
+	"github.com/ryabkov82/vpnbot/internal/vouchers"
+)
+
+type accountVoucherRedeemReqJSON struct {
+	Token string `json:"token"`
+	Code  string `json:"code"`
+}
+
+type accountVoucherRedeemOKJSON struct {
+	// Status — credited (деньги на балансе), ordered (услуга заказана) или order_failed (деньги на балансе, заказ не удался).
+	Status        string  `json:"status"`
+	Kind          string  `json:"kind"`
+	Amount        float64 `json:"amount"`
+	AmountText    string  `json:"amount_text"`
+	ServiceID     int     `json:"service_id,omitempty"`
+	ServiceName   string  `json:"service_name,omitempty"`
+	UserServiceID int     `json:"user_service_id,omitempty"`
+	Duplicate     bool    `json:"duplicate,omitempty"`
+	Message       string  `json:"message"`
+}
+
+// accountVoucherMessage — итог активации кода для кабинета на языке страницы.
+func accountVoucherMessage(locale accountLocale, r *appService.VoucherRedemption) string {
+	if locale == accountLocaleEN {
+		prefix := ""
+		if r.Duplicate {
+			prefix = "You have already redeemed this code. "
+		}
+		switch r.Status {
+		case appService.VoucherOrdered, appService.VoucherOrdering:
+			return prefix + "The service “" + r.ServiceName + "” has been ordered with the gift code."
+		case appService.VoucherOrderFailed:
+			return prefix + formatServiceOrderRUBAmountEN(r.Amount) + " RUB was added to your balance, but the service “" + r.ServiceName + "” could not be ordered. Redeem the code again or contact support."
+		case appService.VoucherExistingUnpaid:
+			return prefix + formatServiceOrderRUBAmountEN(r.Amount) + " RUB was added to your balance, but the service “" + r.ServiceName + "” could not be ordered automatically because you have an unpaid service. Please contact support."
+		}
+		return prefix + formatServiceOrderRUBAmountEN(r.Amount) + " RUB was added to your balance."
+	}
+	prefix := ""
+	if r.Duplicate {
+		prefix = "Этот код уже активирован вами. "
+	}
+	switch r.Status {
+	case appService.VoucherOrdered, appService.VoucherOrdering:
+		return prefix + "Услуга «" + r.ServiceName + "» оформлена по подарочному коду."
+	case appService.VoucherOrderFailed:
+		return prefix + "На баланс зачислено " + models.FormatRubAmount(r.Amount) + ", но заказать услугу «" + r.ServiceName + "» не удалось. Активируйте код ещё раз или обратитесь в поддержку."
+	case appService.VoucherExistingUnpaid:
+		return prefix + "На баланс зачислено " + models.FormatRubAmount(r.Amount) + ", но услугу «" + r.ServiceName + "» автоматически оформить не удалось: у вас есть неоплаченная услуга. Обратитесь в поддержку."
+	}
+	return prefix + "На баланс зачислено " + models.FormatRubAmount(r.Amount) + "."
+}
+
+// accountVoucherError — HTTP-статус и код ошибки активации для API.
+func accountVoucherError(err error) (int, string) {
+	switch {
+	case errors.Is(err, vouchers.ErrMalformed):
+		return http.StatusBadRequest, "voucher_malformed"
+	case errors.Is(err, vouchers.ErrInvalid):
+		return http.StatusBadRequest, "voucher_invalid"
+	case errors.Is(err, appService.ErrVoucherExpired):
+		return http.StatusGone, "voucher_expired"
+	case errors.Is(err, appService.ErrVoucherUsed):
+		return http.StatusConflict, "voucher_used"
+	case errors.Is(err, appService.ErrServiceCategoryDenied):
+		return http.StatusConflict, "voucher_service_unavailable"
+	case errors.Is(err, appService.ErrVouchersDisabled):
+		return http.StatusNotFound, "not_found"
+	}
+	return http.StatusInternalServerError, "voucher_failed"
+}
+
+// serveAccountVoucherRedeem — POST /api/account/voucher/redeem {token, code}: активация подарочного кода.
+func serveAccountVoucherRedeem(cfg *config.Config, app accountWebApp, rl *leadRateLimiter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/account/voucher/redeem" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed")
+			return
+		}
+		if !webSalesTokenFlowAvailable(cfg) || !cfg.Vouchers.Enabled() {
+			writeJSONError(w, http.StatusNotFound, "not_found")
+			return
+		}
+
+		var req accountVoucherRedeemReqJSON
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<16)).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "bad_request")
+			return
+		}
+		claims, _, err := authenticateWebAccount(cfg, app, req.Token)
+		if err != nil {
+			writeAccountAuthError(w, err)
+			return
+		}
+		// Подбор кодов: лимит и по IP, и по пользователю.
+		ipKey := ClientIPFromRequest(r)
+		if ipKey == "" {
+			ipKey = "unknown"
+		}
+		if rl != nil && !rl.allow(ipKey, "voucher:"+strconv.Itoa(claims.UserID)) {
+			writeJSONError(w, http.StatusTooManyRequests, "rate_limited")
+			return
+		}
+
+		res, err := app.RedeemVoucher(appService.VoucherRedeemRequest{
+			UserID:    claims.UserID,
+			Code:      req.Code,
+			Secret:    cfg.Vouchers.Secret,
+			PaySystem: cfg.Vouchers.SHMPaySystem(),
+		})
+		if err != nil {
+			code, name := accountVoucherError(err)
+			if code == http.StatusInternalServerError {
+				slog.Error("account voucher: RedeemVoucher", "user_id", claims.UserID, "err", err)
+			}
+			writeJSONError(w, code, name)
+			return
+		}
+		writeJSON(w, http.StatusOK, accountVoucherRedeemOKJSON{
+			Status:        res.Status,
+			Kind:          res.Kind.String(),
+			Amount:        res.Amount,
+			AmountText:    models.FormatRubAmount(res.Amount),
+			ServiceID:     res.ServiceID,
+			ServiceName:   res.ServiceName,
+			UserServiceID: res.UserServiceID,
+			Duplicate:     res.Duplicate,
+			Message:       accountVoucherMessage(resolveAccountLocale(r), res),
+		})
+	}
+}
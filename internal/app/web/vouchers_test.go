@@ -0,0 +1,77 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	appService "github.com/ryabkov82/vpnbot/internal/service"
+	"github.com/ryabkov82/vpnbot/internal/vouchers"
+)
+
+func postVoucherRedeem(t *testing.T, h http.HandlerFunc, code string) *httptest.ResponseRecorder {
+	t.Helper()
+	cfg := orderStartTestCfg()
+	tok, err := CreateAccountToken(cfg.WebSales.OrderTokenSecret, "vff", "a@b.c", 701, "web_xx", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := json.Marshal(accountVoucherRedeemReqJSON{Token: tok, Code: code})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/account/voucher/redeem", strings.NewReader(string(body))))
+	return rec
+}
+
+func TestServeAccountVoucherRedeem(t *testing.T) {
+	cfg := orderStartTestCfg()
+	cfg.Vouchers.Secret = strings.Repeat("s", 32)
+	st := &stubAccountWeb{voucherRet: &appService.VoucherRedemption{Status: appService.VoucherCredited, Kind: vouchers.KindBalance, Amount: 500}}
+
+	rec := postVoucherRedeem(t, serveAccountVoucherRedeem(cfg, st, nil), "abcde-12345")
+	if rec.Code != http.StatusOK || len(st.voucherReqs) != 1 {
+		t.Fatalf("%d %s", rec.Code, rec.Body.String())
+	}
+	if r := st.voucherReqs[0]; r.UserID != 701 || r.Code != "abcde-12345" || r.Secret != cfg.Vouchers.Secret || r.PaySystem != "voucher" {
+		t.Fatalf("%+v", r)
+	}
+	var out accountVoucherRedeemOKJSON
+	if err := json.NewDecoder(rec.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Status != "credited" || out.Kind != "balance" || out.AmountText != "500 ₽" || !strings.Contains(out.Message, "500 ₽") {
+		t.Fatalf("%+v", out)
+	}
+
+	for _, tc := range []struct {
+		err  error
+		code int
+		name string
+	}{
+		{vouchers.ErrMalformed, http.StatusBadRequest, "voucher_malformed"},
+		{vouchers.ErrInvalid, http.StatusBadRequest, "voucher_invalid"},
+		{appService.ErrVoucherExpired, http.StatusGone, "voucher_expired"},
+		{appService.ErrVoucherUsed, http.StatusConflict, "voucher_used"},
+		{&appService.ServiceCategoryDeniedError{ServiceID: 7}, http.StatusConflict, "voucher_service_unavailable"},
+	} {
+		rec := postVoucherRedeem(t, serveAccountVoucherRedeem(cfg, &stubAccountWeb{voucherErr: tc.err}, nil), "x")
+		if rec.Code != tc.code {
+			t.Fatalf("%v: %d", tc.err, rec.Code)
+		}
+		assertJSONErrorField(t, rec.Body.String(), tc.name)
+	}
+
+	rl := newLeadRateLimiter(10, time.Hour, 1, time.Hour)
+	postVoucherRedeem(t, serveAccountVoucherRedeem(cfg, st, rl), "x")
+	rec = postVoucherRedeem(t, serveAccountVoucherRedeem(cfg, st, rl), "x")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("rate limit: %d", rec.Code)
+	}
+
+	cfg.Vouchers.Secret = ""
+	if rec := postVoucherRedeem(t, serveAccountVoucherRedeem(cfg, st, nil), "x"); rec.Code != http.StatusNotFound {
+		t.Fatalf("disabled: %d", rec.Code)
+	}
+}
@@ -0,0 +1,213 @@
+package web
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/config"
+	"github.com/ryabkov82/vpnbot/internal/models"
+	appService "github.com/ryabkov82/vpnbot/internal/service"
+)
+
+// Страница истории списаний и журнала баланса: limit по умолчанию и максимум.
+const (
+	accountHistoryDefaultLimit = 20
+	accountHistoryMaxLimit     = 100
+)
+
+type accountWithdrawalJSON struct {
+	ID            int64   `json:"id"`
+	Date          string  `json:"date"`
+	Name          string  `json:"name"`
+	ServiceID     int     `json:"service_id"`
+	UserServiceID int64   `json:"user_service_id"`
+	Months        float64 `json:"months"`
+	EndDate       string  `json:"end_date,omitempty"`
+	Cost          float64 `json:"cost"`
+	Discount      float64 `json:"discount"` // %, как в SHM
+	Bonus         float64 `json:"bonus"`    // оплачено бонусами, ₽
+	Total         float64 `json:"total"`    // списано с баланса, ₽
+	TotalText     string  `json:"total_text"`
+}
+
+type accountWithdrawalsOKJSON struct {
+	Withdrawals []accountWithdrawalJSON `json:"withdrawals"`
+	Total       int                     `json:"total"`
+	Limit       int                     `json:"limit"`
+	Offset      int                     `json:"offset"`
+}
+
+type accountLedgerEntryJSON struct {
+	Kind             string                 `json:"kind"`
+	Date             string                 `json:"date"`
+	Amount           float64                `json:"amount"`
+	AmountText       string                 `json:"amount_text"`
+	BalanceAfter     float64                `json:"balance_after"`
+	BalanceAfterText string                 `json:"balance_after_text"`
+	PayID            int                    `json:"pay_id,omitempty"`
+	PaySystemID      string                 `json:"pay_system_id,omitempty"`
+	Withdrawal       *accountWithdrawalJSON `json:"withdrawal,omitempty"`
+}
+
+type accountLedgerOKJSON struct {
+	Balance     float64                  `json:"balance"`
+	BalanceText string                   `json:"balance_text"`
+	Entries     []accountLedgerEntryJSON `json:"entries"`
+	Total       int                      `json:"total"`
+	Limit       int                      `json:"limit"`
+	Offset      int                      `json:"offset"`
+}
+
+func withdrawalJSON(w models.WithdrawItem) accountWithdrawalJSON {
+	date := w.WithdrawDate
+	if strings.TrimSpace(date) == "" {
+		date = w.CreateDate
+	}
+	return accountWithdrawalJSON{
+		ID:            w.WithdrawID,
+		Date:          date,
+		Name:          strings.TrimSpace(w.Name),
+		ServiceID:     w.ServiceID,
+		UserServiceID: w.UserServiceID,
+		Months:        w.Months,
+		EndDate:       w.EndDate,
+		Cost:          w.Cost,
+		Discount:      w.Discount,
+		Bonus:         w.Bonus,
+		Total:         w.Total,
+		TotalText:     models.FormatRubAmount(w.Total),
+	}
+}
+
+// accountHistoryPage разбирает ?limit=&offset=; false — параметры некорректны.
+func accountHistoryPage(r *http.Request) (limit, offset int, ok bool) {
+	limit, offset = accountHistoryDefaultLimit, 0
+	q := r.URL.Query()
+	if v := strings.TrimSpace(q.Get("limit")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > accountHistoryMaxLimit {
+			return 0, 0, false
+		}
+		limit = n
+	}
+	if v := strings.TrimSpace(q.Get("offset")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, 0, false
+		}
+		offset = n
+	}
+	return limit, offset, true
+}
+
+// serveAccountWithdrawals — GET /api/account/withdrawals?token=…[&limit=&offset=]: списания за услуги (новые сначала).
+func serveAccountWithdrawals(cfg *config.Config, app accountWebApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/account/withdrawals" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed")
+			return
+		}
+		if !webSalesTokenFlowAvailable(cfg) {
+			writeJSONError(w, http.StatusNotFound, "not_found")
+			return
+		}
+
+		claims, _, err := authenticateWebAccount(cfg, app, strings.TrimSpace(r.URL.Query().Get("token")))
+		if err != nil {
+			writeAccountAuthError(w, err)
+			return
+		}
+		limit, offset, ok := accountHistoryPage(r)
+		if !ok {
+			writeJSONError(w, http.StatusBadRequest, "invalid_page")
+			return
+		}
+		list, total, err := app.UserWithdrawals(claims.UserID, limit, offset)
+		if err != nil {
+			slog.Error("account withdrawals: UserWithdrawals", "user_id", claims.UserID, "err", err)
+			writeJSONError(w, http.StatusInternalServerError, "withdrawals_failed")
+			return
+		}
+		out := accountWithdrawalsOKJSON{Withdrawals: make([]accountWithdrawalJSON, 0, len(list)), Total: total, Limit: limit, Offset: offset}
+		for _, item := range list {
+			out.Withdrawals = append(out.Withdrawals, withdrawalJSON(item))
+		}
+		writeJSON(w, http.StatusOK, out)
+	}
+}
+
+// serveAccountLedger — GET /api/account/ledger?token=…[&limit=&offset=]: платежи и списания одной лентой
+// с балансом после каждой операции (новые сначала).
+func serveAccountLedger(cfg *config.Config, app accountWebApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/account/ledger" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed")
+			return
+		}
+		if !webSalesTokenFlowAvailable(cfg) {
+			writeJSONError(w, http.StatusNotFound, "not_found")
+			return
+		}
+
+		claims, _, err := authenticateWebAccount(cfg, app, strings.TrimSpace(r.URL.Query().Get("token")))
+		if err != nil {
+			writeAccountAuthError(w, err)
+			return
+		}
+		limit, offset, ok := accountHistoryPage(r)
+		if !ok {
+			writeJSONError(w, http.StatusBadRequest, "invalid_page")
+			return
+		}
+		ledger, err := app.UserLedger(claims.UserID)
+		if err != nil {
+			slog.Error("account ledger: UserLedger", "user_id", claims.UserID, "err", err)
+			writeJSONError(w, http.StatusInternalServerError, "ledger_failed")
+			return
+		}
+		out := accountLedgerOKJSON{
+			Balance:     ledger.Balance,
+			BalanceText: models.FormatRubAmount(ledger.Balance),
+			Entries:     []accountLedgerEntryJSON{},
+			Total:       len(ledger.Entries),
+			Limit:       limit,
+			Offset:      offset,
+		}
+		for i := offset; i < len(ledger.Entries) && i < offset+limit; i++ {
+			out.Entries = append(out.Entries, ledgerEntryJSON(ledger.Entries[i]))
+		}
+		writeJSON(w, http.StatusOK, out)
+	}
+}
+
+func ledgerEntryJSON(e appService.LedgerEntry) accountLedgerEntryJSON {
+	out := accountLedgerEntryJSON{
+		Kind:             e.Kind,
+		Date:             e.At.In(shmMoscow).Format(time.DateTime),
+		Amount:           e.Amount,
+		AmountText:       models.FormatRubAmount(e.Amount),
+		BalanceAfter:     e.BalanceAfter,
+		BalanceAfterText: models.FormatRubAmount(e.BalanceAfter),
+	}
+	if e.Pay != nil {
+		out.PayID, out.PaySystemID = e.Pay.ID, e.Pay.PaySystemID
+	}
+	if e.Withdrawal != nil {
+		wj := withdrawalJSON(*e.Withdrawal)
+		out.Withdrawal = &wj
+	}
+	return out
+}
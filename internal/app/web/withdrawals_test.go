@@ -0,0 +1,65 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/models"
+	appService "github.com/ryabkov82/vpnbot/internal/service"
+)
+
+func TestServeAccountWithdrawals(t *testing.T) {
+	cfg := orderStartTestCfg()
+	h := func(st *stubAccountWeb) http.HandlerFunc { return serveAccountWithdrawals(cfg, st) }
+	st := &stubAccountWeb{withdrawals: []models.WithdrawItem{{WithdrawID: 9, Name: "VPN", Months: 1, Cost: 249, Discount: 0, Bonus: 50, Total: 199, WithdrawDate: "2026-10-01 12:00:00"}}}
+
+	rec := getAccountWithToken(t, h, st, "/api/account/withdrawals", "&limit=5&offset=10")
+	if rec.Code != http.StatusOK || st.withdrawalsPage != [2]int{5, 10} {
+		t.Fatalf("%d %v %s", rec.Code, st.withdrawalsPage, rec.Body.String())
+	}
+	var out accountWithdrawalsOKJSON
+	if err := json.NewDecoder(rec.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Total != 1 || out.Withdrawals[0].Bonus != 50 || out.Withdrawals[0].TotalText != "199 ₽" || out.Withdrawals[0].Date != "2026-10-01 12:00:00" {
+		t.Fatalf("%+v", out)
+	}
+
+	rec = getAccountWithToken(t, h, st, "/api/account/withdrawals", "&limit=1000")
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("limit: %d", rec.Code)
+	}
+	assertJSONErrorField(t, rec.Body.String(), "invalid_page")
+	rec = getAccountWithToken(t, h, &stubAccountWeb{historyErr: errors.New("shm down")}, "/api/account/withdrawals", "")
+	assertJSONErrorField(t, rec.Body.String(), "withdrawals_failed")
+}
+
+func TestServeAccountLedger(t *testing.T) {
+	cfg := orderStartTestCfg()
+	h := func(st *stubAccountWeb) http.HandlerFunc { return serveAccountLedger(cfg, st) }
+	at := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	st := &stubAccountWeb{ledger: &appService.Ledger{Balance: 101, Entries: []appService.LedgerEntry{
+		{Kind: appService.LedgerWithdraw, At: at, Amount: -199, BalanceAfter: 101, Withdrawal: &models.WithdrawItem{WithdrawID: 2, Name: "VPN", Total: 199}},
+		{Kind: appService.LedgerPay, At: at.Add(-time.Hour), Amount: 300, BalanceAfter: 300, Pay: &models.UserPay{ID: 5, PaySystemID: "yookassa"}},
+	}}}
+
+	rec := getAccountWithToken(t, h, st, "/api/account/ledger", "&limit=1&offset=1")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%d %s", rec.Code, rec.Body.String())
+	}
+	var out accountLedgerOKJSON
+	if err := json.NewDecoder(rec.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Total != 2 || len(out.Entries) != 1 || out.Entries[0].PayID != 5 || out.Entries[0].Date != "2026-10-01 11:00:00" || out.BalanceText != "101 ₽" {
+		t.Fatalf("%+v", out)
+	}
+	rec = getAccountWithToken(t, h, st, "/api/account/ledger", "")
+	if !strings.Contains(rec.Body.String(), `"amount_text":"-199 ₽"`) || !strings.Contains(rec.Body.String(), `"withdrawal":{"id":2`) {
+		t.Fatal(rec.Body.String())
+	}
+}
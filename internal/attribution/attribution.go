@@ -44,12 +44,14 @@ const (
 	RegistrationChannelTelegram     RegistrationChannel = "telegram"
 	RegistrationChannelWebMagicLink RegistrationChannel = "web_magic_link"
 	RegistrationChannelWebGoogle    RegistrationChannel = "web_google"
+	// RegistrationChannelGift — Telegram-регистрация по ссылке подарка; кто подарил — FirstTouch.GiftedByUserID.
+	RegistrationChannelGift RegistrationChannel = "gift"
 )
 
 // Valid reports whether c is an approved user-creation channel.
 func (c RegistrationChannel) Valid() bool {
 	switch c {
-	case RegistrationChannelTelegram, RegistrationChannelWebMagicLink, RegistrationChannelWebGoogle:
+	case RegistrationChannelTelegram, RegistrationChannelWebMagicLink, RegistrationChannelWebGoogle, RegistrationChannelGift:
 		return true
 	default:
 		return false
@@ -75,7 +77,9 @@ type FirstTouch struct {
 	UTMContent          string              `json:"utm_content,omitempty"`
 	UTMTerm             string              `json:"utm_term,omitempty"`
 	TelegramStartParam  string              `json:"telegram_start_param,omitempty"`
-	CapturedAt          string              `json:"captured_at"`
+	// GiftedByUserID — SHM user_id покупателя подарка (только для канала gift).
+	GiftedByUserID int    `json:"gifted_by_user_id,omitempty"`
+	CapturedAt     string `json:"captured_at"`
 }
 
 // ServerContext holds server-derived fields. Invalid values fail NewFirstTouch.
@@ -83,6 +87,8 @@ type ServerContext struct {
 	RegistrationChannel RegistrationChannel
 	RegistrationDomain  string
 	CapturedAt          time.Time
+	// GiftedByUserID обязателен для канала gift и запрещён для остальных.
+	GiftedByUserID int
 }
 
 // MarketingInput holds untrusted client/Telegram marketing fields.
@@ -104,6 +110,7 @@ var (
 	errDomainRequired  = errors.New("attribution: registration domain is required")
 	errDomainInvalid   = errors.New("attribution: registration domain is invalid")
 	errCapturedAtZero  = errors.New("attribution: captured_at is required")
+	errGiftedBy        = errors.New("attribution: gifted_by_user_id must be set only for the gift channel")
 )
 
 // NewFirstTouch builds a versioned Record from server context and marketing input.
@@ -122,6 +129,9 @@ func NewFirstTouch(server ServerContext, marketing MarketingInput) (Record, erro
 	if server.CapturedAt.IsZero() {
 		return Record{}, errCapturedAtZero
 	}
+	if !validGiftedBy(server.RegistrationChannel, server.GiftedByUserID) {
+		return Record{}, errGiftedBy
+	}
 	captured := server.CapturedAt.UTC().Truncate(time.Second).Format(time.RFC3339)
 
 	ft := FirstTouch{
@@ -135,6 +145,7 @@ func NewFirstTouch(server ServerContext, marketing MarketingInput) (Record, erro
 		UTMContent:          sanitizeMarketingText(marketing.UTMContent, MaxUTMContentRunes),
 		UTMTerm:             sanitizeMarketingText(marketing.UTMTerm, MaxUTMTermRunes),
 		TelegramStartParam:  sanitizeMarketingText(marketing.TelegramStartParam, MaxTelegramStartParamRunes),
+		GiftedByUserID:      server.GiftedByUserID,
 		CapturedAt:          captured,
 	}
 	return Record{Version: SchemaVersion, FirstTouch: ft}, nil
@@ -146,7 +157,7 @@ func (r Record) Valid() bool {
 		return false
 	}
 	ft := r.FirstTouch
-	if !ft.RegistrationChannel.Valid() {
+	if !ft.RegistrationChannel.Valid() || !validGiftedBy(ft.RegistrationChannel, ft.GiftedByUserID) {
 		return false
 	}
 	dom, err := normalizeRegistrationDomain(ft.RegistrationDomain)
@@ -187,6 +198,14 @@ func (r Record) Valid() bool {
 	return true
 }
 
+// validGiftedBy — покупатель подарка указан ровно для канала gift.
+func validGiftedBy(c RegistrationChannel, userID int) bool {
+	if c == RegistrationChannelGift {
+		return userID > 0
+	}
+	return userID == 0
+}
+
 // IsOrganic reports a valid first-touch with no UTM and no Telegram start payload.
 // ReferrerHost alone does not disqualify organic. Absence of a Record is unknown
 // and is decided by storage callers, not by this method.
@@ -195,6 +214,9 @@ func (r Record) IsOrganic() bool {
 		return false
 	}
 	ft := r.FirstTouch
+	if ft.RegistrationChannel == RegistrationChannelGift {
+		return false
+	}
 	return ft.UTMSource == "" && ft.UTMMedium == "" && ft.UTMCampaign == "" &&
 		ft.UTMContent == "" && ft.UTMTerm == "" && ft.TelegramStartParam == ""
 }
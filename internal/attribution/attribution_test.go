@@ -13,6 +13,7 @@ func TestRegistrationChannelValid(t *testing.T) {
 		RegistrationChannelTelegram,
 		RegistrationChannelWebMagicLink,
 		RegistrationChannelWebGoogle,
+		RegistrationChannelGift,
 	} {
 		if !c.Valid() {
 			t.Fatalf("%q should be valid", c)
@@ -25,6 +26,25 @@ func TestRegistrationChannelValid(t *testing.T) {
 	}
 }
 
+func TestNewFirstTouch_GiftRequiresGiftedBy(t *testing.T) {
+	at := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	rec, err := NewFirstTouch(ServerContext{RegistrationChannel: RegistrationChannelGift, RegistrationDomain: "vpn.example.com", CapturedAt: at, GiftedByUserID: 42},
+		MarketingInput{TelegramStartParam: "gift_42_abc"})
+	if err != nil || !rec.Valid() || rec.FirstTouch.GiftedByUserID != 42 || rec.IsOrganic() {
+		t.Fatalf("%+v %v", rec, err)
+	}
+	raw, _ := json.Marshal(rec)
+	if !strings.Contains(string(raw), `"gifted_by_user_id":42`) {
+		t.Fatal(string(raw))
+	}
+	if _, err := NewFirstTouch(ServerContext{RegistrationChannel: RegistrationChannelGift, RegistrationDomain: "vpn.example.com", CapturedAt: at}, MarketingInput{}); err == nil {
+		t.Fatal("gift without gifted_by must fail")
+	}
+	if _, err := NewFirstTouch(ServerContext{RegistrationChannel: RegistrationChannelTelegram, RegistrationDomain: "vpn.example.com", CapturedAt: at, GiftedByUserID: 42}, MarketingInput{}); err == nil {
+		t.Fatal("gifted_by outside the gift channel must fail")
+	}
+}
+
 func TestNewFirstTouch_WebMagicLinkExact(t *testing.T) {
 	fixed := time.Date(2026, 7, 26, 18, 0, 0, 123456789, time.UTC)
 	rec, err := NewFirstTouch(ServerContext{
@@ -22,6 +22,28 @@ type BrandConfig struct {
 	// YooKassaPaySystem — имя ключа в SHM config.pay_systems для web/Telegram YooKassa overlay (ps=).
 	// Не путать с PaymentProfile (Telegram WebApp auth profile).
 	YooKassaPaySystem string `json:"yookassa_pay_system"`
+	// PaymentProviders — способы пополнения баланса (web и бот) в порядке показа.
+	// Пусто — YooKassa (yookassa_pay_system) и CryptoCloud, как до появления реестра.
+	PaymentProviders []PaymentProvider `json:"payment_providers"`
+}
+
+// PaymentProvider — платёжная система SHM (pay_systems/<path>.cgi, ps=<pay_system>) для пополнения баланса.
+// Пустые поля берутся из встроенного описания провайдера с тем же id (yookassa, cryptocloud), иначе:
+// pay_system = id, path = /shm/pay_systems/<id>.cgi, лимиты 50–10 000, валюта RUB, все локали и бренды.
+type PaymentProvider struct {
+	ID           string            `json:"id"`
+	PaySystem    string            `json:"pay_system"`
+	Path         string            `json:"path"`
+	Names        map[string]string `json:"names"`
+	Descriptions map[string]string `json:"descriptions"`
+	Currencies   []string          `json:"currencies"`
+	MinAmount    float64           `json:"min_amount"`
+	MaxAmount    float64           `json:"max_amount"`
+	Locales      []string          `json:"locales"`
+	// Brands — бренды, которым доступен провайдер (для общих конфигов); пусто — любой.
+	Brands []string `json:"brands"`
+	// SendBrandID — добавлять brand_id= в ссылку (нужно CGI, различающему бренды по return_url).
+	SendBrandID bool `json:"send_brand_id"`
 }
 
 var brandIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
@@ -250,6 +272,30 @@ func validateExplicitBrand(b BrandConfig) error {
 	if !shmPaySystemKeyPattern.MatchString(b.YooKassaPaySystem) {
 		return fmt.Errorf("brand.yookassa_pay_system %q is invalid: must match %s", b.YooKassaPaySystem, shmPaySystemKeyPattern.String())
 	}
+	return validatePaymentProviders(b.PaymentProviders)
+}
+
+func validatePaymentProviders(list []PaymentProvider) error {
+	seen := make(map[string]struct{}, len(list))
+	for i, p := range list {
+		id := strings.TrimSpace(p.ID)
+		if !shmPaySystemKeyPattern.MatchString(id) {
+			return fmt.Errorf("brand.payment_providers[%d].id %q is invalid: must match %s", i, p.ID, shmPaySystemKeyPattern.String())
+		}
+		if _, dup := seen[id]; dup {
+			return fmt.Errorf("brand.payment_providers: duplicate id %q", id)
+		}
+		seen[id] = struct{}{}
+		if ps := strings.TrimSpace(p.PaySystem); ps != "" && !shmPaySystemKeyPattern.MatchString(ps) {
+			return fmt.Errorf("brand.payment_providers[%d].pay_system %q is invalid: must match %s", i, p.PaySystem, shmPaySystemKeyPattern.String())
+		}
+		if path := strings.TrimSpace(p.Path); path != "" && !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("brand.payment_providers[%d].path %q must start with /", i, p.Path)
+		}
+		if p.MinAmount < 0 || p.MaxAmount < 0 || (p.MaxAmount > 0 && p.MinAmount > p.MaxAmount) {
+			return fmt.Errorf("brand.payment_providers[%d]: invalid amount limits %v–%v", i, p.MinAmount, p.MaxAmount)
+		}
+	}
 	return nil
 }
 
@@ -507,3 +507,26 @@ func TestNormalize_YooKassaPaySystemInvalidCharsFail(t *testing.T) {
 		}
 	}
 }
+
+func TestNormalize_PaymentProviders(t *testing.T) {
+	cfg := validExplicitBrandCfg()
+	cfg.Brand.PaymentProviders = []PaymentProvider{{ID: "yookassa"}, {ID: "paymaster", MinAmount: 100, MaxAmount: 5000}}
+	if err := cfg.Normalize(); err != nil {
+		t.Fatalf("valid providers: %v", err)
+	}
+	cases := map[string][]PaymentProvider{
+		"invalid id":     {{ID: "Pay Master"}},
+		"duplicate":      {{ID: "yookassa"}, {ID: "yookassa"}},
+		"pay_system":     {{ID: "x", PaySystem: "x/y"}},
+		"path":           {{ID: "x", Path: "shm/x.cgi"}},
+		"amount limits":  {{ID: "x", MinAmount: 500, MaxAmount: 100}},
+		"negative limit": {{ID: "x", MinAmount: -1}},
+	}
+	for name, list := range cases {
+		cfg := validExplicitBrandCfg()
+		cfg.Brand.PaymentProviders = list
+		if err := cfg.Normalize(); err == nil || !strings.Contains(err.Error(), "payment_providers") {
+			t.Fatalf("%s: want payment_providers error, got %v", name, err)
+		}
+	}
+}
@@ -6,7 +6,9 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
+	"time"
 )
 
 type TrialFeature struct {
@@ -33,6 +35,152 @@ type Payments struct {
 	Profile string `json:"profile"`
 }
 
+// TrafficAlerts — уведомления Premium-пользователей о расходе трафика.
+// ThresholdsPercent пуст → 80 и 100; IntervalMinutes ≤ 0 → 30.
+type TrafficAlerts struct {
+	Enabled           bool  `json:"enabled"`
+	ThresholdsPercent []int `json:"thresholds_percent"`
+	IntervalMinutes   int   `json:"interval_minutes"`
+}
+
+// Thresholds — пороги в процентах по возрастанию (значения ≤ 0 отбрасываются).
+func (t TrafficAlerts) Thresholds() []int {
+	var out []int
+	for _, p := range t.ThresholdsPercent {
+		if p > 0 {
+			out = append(out, p)
+		}
+	}
+	if len(out) == 0 {
+		return []int{80, 100}
+	}
+	sort.Ints(out)
+	return out
+}
+
+// Interval — период опроса Remnawave.
+func (t TrafficAlerts) Interval() time.Duration {
+	if t.IntervalMinutes <= 0 {
+		return 30 * time.Minute
+	}
+	return time.Duration(t.IntervalMinutes) * time.Minute
+}
+
+// StatusPage — публичный статус серверов (/status, /api/public/status, /status в боте).
+// Locations: имя ноды Remnawave → название локации для пользователей; несколько нод
+// с одним названием показываются одной локацией. CacheSeconds ≤ 0 → 60.
+type StatusPage struct {
+	Locations map[string]string `json:"locations"`
+	// HideUnmapped — не показывать ноды, которых нет в Locations.
+	HideUnmapped bool `json:"hide_unmapped"`
+	CacheSeconds int  `json:"cache_seconds"`
+}
+
+// Location — название локации для ноды; ok=false — ноду не показываем.
+func (p StatusPage) Location(nodeName string) (string, bool) {
+	if loc := strings.TrimSpace(p.Locations[nodeName]); loc != "" {
+		return loc, true
+	}
+	if p.HideUnmapped {
+		return "", false
+	}
+	return nodeName, true
+}
+
+// CacheTTL — время жизни снимка статуса нод.
+func (p StatusPage) CacheTTL() time.Duration {
+	if p.CacheSeconds <= 0 {
+		return time.Minute
+	}
+	return time.Duration(p.CacheSeconds) * time.Second
+}
+
+// CardCheckout — оплата картой по международной цене каталога (service.config.pricing)
+// через Checkout Session API в стиле Stripe. Пустой SecretKey — оплата выключена;
+// без WebhookSecret платежи не зачисляются. APIBaseURL пуст → https://api.stripe.com.
+type CardCheckout struct {
+	APIBaseURL    string `json:"api_base_url"`
+	SecretKey     string `json:"secret_key"`
+	WebhookSecret string `json:"webhook_secret"`
+	// PaySystem — pay_system_id платежа в SHM; пусто → "card_checkout".
+	PaySystem string `json:"pay_system"`
+}
+
+// Enabled — создание сессий оплаты разрешено.
+func (c CardCheckout) Enabled() bool {
+	return strings.TrimSpace(c.SecretKey) != "" && strings.TrimSpace(c.WebhookSecret) != ""
+}
+
+// SHMPaySystem — pay_system_id для зачисления в SHM.
+func (c CardCheckout) SHMPaySystem() string {
+	if ps := strings.TrimSpace(c.PaySystem); ps != "" {
+		return ps
+	}
+	return "card_checkout"
+}
+
+// Vouchers — подарочные коды (internal/vouchers). Secret — ключ подписи кодов (не короче 32 символов);
+// пусто — активация выключена. Коды одного секрета привязаны к brand.id.
+type Vouchers struct {
+	Secret string `json:"secret"`
+	// PaySystem — pay_system_id зачисления по коду в SHM; пусто → "voucher".
+	PaySystem string `json:"pay_system"`
+}
+
+// Enabled — активация кодов включена.
+func (v Vouchers) Enabled() bool {
+	return strings.TrimSpace(v.Secret) != ""
+}
+
+// SHMPaySystem — pay_system_id для зачисления в SHM.
+func (v Vouchers) SHMPaySystem() string {
+	if ps := strings.TrimSpace(v.PaySystem); ps != "" {
+		return ps
+	}
+	return "voucher"
+}
+
+// Gifts — подарки услуг в боте (/gift): покупатель оплачивает услугу с баланса, получатель забирает её по ссылке.
+type Gifts struct {
+	Enabled bool `json:"enabled"`
+	// RefundAfterHours — через сколько часов незабранный подарок возвращается на баланс покупателя; 0 → 72.
+	RefundAfterHours int `json:"refund_after_hours"`
+	// PaySystem — pay_system_id списания, зачисления получателю и возврата в SHM; пусто → "gift".
+	PaySystem string `json:"pay_system"`
+}
+
+// RefundAfter — срок жизни незабранного подарка.
+func (g Gifts) RefundAfter() time.Duration {
+	if g.RefundAfterHours <= 0 {
+		return 72 * time.Hour
+	}
+	return time.Duration(g.RefundAfterHours) * time.Hour
+}
+
+// SHMPaySystem — pay_system_id платежей подарка в SHM.
+func (g Gifts) SHMPaySystem() string {
+	if ps := strings.TrimSpace(g.PaySystem); ps != "" {
+		return ps
+	}
+	return "gift"
+}
+
+// PlanChange — смена тарифа с пересчётом: неиспользованное время текущей услуги зачисляется на баланс,
+// новая услуга заказывается, старая удаляется.
+type PlanChange struct {
+	Enabled bool `json:"enabled"`
+	// PaySystem — pay_system_id зачисления остатка (и его отмены при сбое заказа) в SHM; пусто → "plan_change".
+	PaySystem string `json:"pay_system"`
+}
+
+// SHMPaySystem — pay_system_id платежей смены тарифа в SHM.
+func (p PlanChange) SHMPaySystem() string {
+	if ps := strings.TrimSpace(p.PaySystem); ps != "" {
+		return ps
+	}
+	return "plan_change"
+}
+
 // Конфигурация
 type Config struct {
 	Env        string `json:"app_env"`
@@ -54,6 +202,8 @@ type Config struct {
 		LeadsChatID   int64  `json:"leads_chat_id"`
 		SupportChat   string `json:"support_chat"`
 		NewsChannel   string `json:"news_channel"`
+		// BotUsername — username бота без @ (deep link t.me/<bot>?start=... из web-кабинета)
+		BotUsername string `json:"bot_username"`
 	}
 	Features Features    `json:"features"`
 	Services ServicesCfg `json:"services"`
@@ -79,6 +229,10 @@ type Config struct {
 		TelegramLinkTokenTTLMinutes int `json:"telegram_link_token_ttl_minutes"`
 		// TTL письма подтверждения привязки email (account_link_email)
 		LinkConfirmEmailTTLMinutes int `json:"link_confirm_email_ttl_minutes"`
+		// TTL одноразового 6-значного кода входа из письма (по умолчанию 10 минут)
+		LoginCodeTTLMinutes int `json:"login_code_ttl_minutes"`
+		// TTL deep link «Подключить Telegram» из web-кабинета (t.me/<bot>?start=link_...)
+		TelegramConnectTokenTTLMinutes int `json:"telegram_connect_token_ttl_minutes"`
 	} `json:"web_sales"`
 
 	// WebAccount — вход в личный кабинет (OAuth и т.п.), без секретов по умолчанию.
@@ -102,6 +256,15 @@ type Config struct {
 
 	RemnawaveAPIURL   string `json:"remnawave_api_url"`
 	RemnawaveAPIToken string `json:"remnawave_api_token"`
+	// RemnawaveWebhookSecret — WEBHOOK_SECRET_HEADER панели; пусто — /api/webhooks/remnawave выключен.
+	RemnawaveWebhookSecret string `json:"remnawave_webhook_secret"`
+
+	TrafficAlerts TrafficAlerts `json:"traffic_alerts"`
+	StatusPage    StatusPage    `json:"status_page"`
+	CardCheckout  CardCheckout  `json:"card_checkout"`
+	Vouchers      Vouchers      `json:"vouchers"`
+	Gifts         Gifts         `json:"gifts"`
+	PlanChange    PlanChange    `json:"plan_change"`
 
 	// Brand — один активный бренд процесса. Секция обязательна: runtime требует
 	// явного brand (см. Config.Normalize). Legacy-конфиг без brand невалиден для
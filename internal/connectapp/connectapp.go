@@ -0,0 +1,222 @@
+// Package connectapp — реестр клиентских приложений для подключения по ссылке подписки:
+// deep link на импорт, ссылки установки по платформам и определение ОС по User-Agent.
+package connectapp
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/ryabkov82/vpnbot/internal/infrastructure/happ"
+)
+
+// Platform — ОС пользователя.
+type Platform string
+
+const (
+	PlatformIOS     Platform = "ios"
+	PlatformAndroid Platform = "android"
+	PlatformWindows Platform = "windows"
+	PlatformMacOS   Platform = "macos"
+	PlatformLinux   Platform = "linux"
+	PlatformUnknown Platform = "unknown"
+)
+
+// Идентификаторы приложений.
+const (
+	AppHapp      = "happ"
+	AppV2RayTun  = "v2raytun"
+	AppStreisand = "streisand"
+	AppHiddify   = "hiddify"
+	AppFoXray    = "foxray"
+	AppSingBox   = "singbox"
+)
+
+// ErrEmptySubscription — не передан URL подписки.
+var ErrEmptySubscription = errors.New("connectapp: empty subscription url")
+
+// App — клиент с deep link на импорт подписки.
+type App struct {
+	ID   string
+	Name string
+	// Install — ссылка установки по платформе; отсутствие ключа = платформа не поддерживается.
+	Install map[Platform]string
+	// SingBoxProfile — приложение импортирует профиль sing-box JSON, а не v2ray-подписку.
+	SingBoxProfile bool
+	// Encrypted — deep link не раскрывает URL подписки (допустим для Premium).
+	Encrypted bool
+
+	scheme string
+	build  func(subURL, name string) (string, error)
+}
+
+var registry = []App{
+	{
+		ID: AppHapp, Name: "Happ", scheme: "happ", Encrypted: true,
+		Install: map[Platform]string{
+			PlatformIOS:     "https://apps.apple.com/us/app/happ-proxy-utility/id6504287215",
+			PlatformAndroid: "https://play.google.com/store/apps/details?id=com.happproxy",
+			PlatformWindows: "https://github.com/Happ-proxy/happ-desktop/releases/latest/download/setup-Happ.x64.exe",
+			PlatformMacOS:   "https://apps.apple.com/us/app/happ-proxy-utility/id6504287215",
+		},
+		build: func(subURL, _ string) (string, error) { return happ.CreateCrypt4Link(subURL) },
+	},
+	{
+		ID: AppV2RayTun, Name: "v2RayTun", scheme: "v2raytun",
+		Install: map[Platform]string{
+			PlatformIOS:     "https://apps.apple.com/us/app/v2raytun/id6476628951",
+			PlatformAndroid: "https://play.google.com/store/apps/details?id=com.v2raytun.android",
+			PlatformWindows: "https://v2raytun.com/",
+			PlatformMacOS:   "https://apps.apple.com/us/app/v2raytun/id6476628951",
+		},
+		build: func(subURL, _ string) (string, error) { return "v2raytun://import/" + subURL, nil },
+	},
+	{
+		ID: AppStreisand, Name: "Streisand", scheme: "streisand",
+		Install: map[Platform]string{
+			PlatformIOS:   "https://apps.apple.com/us/app/streisand/id6450534064",
+			PlatformMacOS: "https://apps.apple.com/us/app/streisand/id6450534064",
+		},
+		build: func(subURL, name string) (string, error) {
+			return "streisand://import/" + subURL + fragment(name), nil
+		},
+	},
+	{
+		ID: AppHiddify, Name: "Hiddify", scheme: "hiddify",
+		Install: map[Platform]string{
+			PlatformIOS:     "https://apps.apple.com/us/app/hiddify-proxy-vpn/id6596777532",
+			PlatformAndroid: "https://play.google.com/store/apps/details?id=app.hiddify.com",
+			PlatformWindows: "https://github.com/hiddify/hiddify-app/releases/latest/download/Hiddify-Windows-Setup-x64.exe",
+			PlatformMacOS:   "https://github.com/hiddify/hiddify-app/releases/latest/download/Hiddify-MacOS.dmg",
+			PlatformLinux:   "https://github.com/hiddify/hiddify-app/releases/latest/download/Hiddify-Linux-x64.AppImage",
+		},
+		build: func(subURL, name string) (string, error) {
+			return "hiddify://import/" + subURL + fragment(name), nil
+		},
+	},
+	{
+		ID: AppFoXray, Name: "FoXray", scheme: "foxray",
+		Install: map[Platform]string{
+			PlatformIOS:   "https://apps.apple.com/us/app/foxray/id6448898396",
+			PlatformMacOS: "https://apps.apple.com/us/app/foxray/id6448898396",
+		},
+		build: func(subURL, name string) (string, error) {
+			return "foxray://yiguo.dev/sub/add/?url=" + url.QueryEscape(subURL) + fragment(name), nil
+		},
+	},
+	{
+		ID: AppSingBox, Name: "sing-box", scheme: "sing-box", SingBoxProfile: true,
+		Install: map[Platform]string{
+			PlatformIOS:     "https://apps.apple.com/us/app/sing-box-vt/id6673731168",
+			PlatformAndroid: "https://play.google.com/store/apps/details?id=io.nekohasekai.sfa",
+			PlatformMacOS:   "https://apps.apple.com/us/app/sing-box-vt/id6673731168",
+			PlatformWindows: "https://github.com/SagerNet/sing-box/releases/latest",
+			PlatformLinux:   "https://github.com/SagerNet/sing-box/releases/latest",
+		},
+		build: func(subURL, name string) (string, error) {
+			return "sing-box://import-remote-profile?url=" + url.QueryEscape(subURL) + fragment(name), nil
+		},
+	},
+}
+
+func fragment(name string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return ""
+	}
+	return "#" + url.PathEscape(name)
+}
+
+// All — все приложения в порядке показа.
+func All() []App {
+	return append([]App(nil), registry...)
+}
+
+// ByID ищет приложение по идентификатору.
+func ByID(id string) (App, bool) {
+	id = strings.ToLower(strings.TrimSpace(id))
+	for _, a := range registry {
+		if a.ID == id {
+			return a, true
+		}
+	}
+	return App{}, false
+}
+
+// ForPlatform — приложения с установкой под платформу; для PlatformUnknown — все.
+func ForPlatform(p Platform) []App {
+	if p == PlatformUnknown || p == "" {
+		return All()
+	}
+	var out []App
+	for _, a := range registry {
+		if a.Supports(p) {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// Supports сообщает, есть ли у приложения сборка под платформу.
+func (a App) Supports(p Platform) bool {
+	_, ok := a.Install[p]
+	return ok
+}
+
+// InstallURL — ссылка установки под платформу (пусто, если не поддерживается).
+func (a App) InstallURL(p Platform) string {
+	return a.Install[p]
+}
+
+// DeepLink строит ссылку импорта подписки; name — название профиля (где приложение его поддерживает).
+func (a App) DeepLink(subscriptionURL, name string) (string, error) {
+	sub := strings.TrimSpace(subscriptionURL)
+	if sub == "" {
+		return "", ErrEmptySubscription
+	}
+	u, err := url.Parse(sub)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return "", errors.New("connectapp: subscription url must be absolute http(s)")
+	}
+	return a.build(sub, name)
+}
+
+// IsAllowedDeepLink — схема ссылки принадлежит одному из приложений реестра (allow-list для страницы редиректа).
+func IsAllowedDeepLink(raw string) bool {
+	_, ok := ForDeepLink(raw)
+	return ok
+}
+
+// ForDeepLink возвращает приложение, которому принадлежит схема deep link.
+func ForDeepLink(raw string) (App, bool) {
+	raw = strings.TrimSpace(raw)
+	i := strings.Index(raw, "://")
+	if i <= 0 || len(raw) == i+3 || strings.ContainsAny(raw, "\r\n") {
+		return App{}, false
+	}
+	scheme := strings.ToLower(raw[:i])
+	for _, a := range registry {
+		if a.scheme == scheme {
+			return a, true
+		}
+	}
+	return App{}, false
+}
+
+// DetectPlatform определяет ОС по User-Agent; iPadOS с десктопным UA распознаётся как macOS.
+func DetectPlatform(userAgent string) Platform {
+	ua := strings.ToLower(userAgent)
+	switch {
+	case strings.Contains(ua, "iphone"), strings.Contains(ua, "ipad"), strings.Contains(ua, "ipod"):
+		return PlatformIOS
+	case strings.Contains(ua, "android"):
+		return PlatformAndroid
+	case strings.Contains(ua, "windows"):
+		return PlatformWindows
+	case strings.Contains(ua, "mac os x"), strings.Contains(ua, "macintosh"):
+		return PlatformMacOS
+	case strings.Contains(ua, "linux"), strings.Contains(ua, "x11"):
+		return PlatformLinux
+	}
+	return PlatformUnknown
+}
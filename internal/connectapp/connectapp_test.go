@@ -0,0 +1,88 @@
+package connectapp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectPlatform(t *testing.T) {
+	cases := map[string]Platform{
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit": PlatformIOS,
+		"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit":               PlatformAndroid,
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit":              PlatformWindows,
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit":        PlatformMacOS,
+		"Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:120.0) Gecko/20100101":   PlatformLinux,
+		"TelegramBot (like TwitterBot)":                                      PlatformUnknown,
+		"":                                                                   PlatformUnknown,
+	}
+	for ua, want := range cases {
+		if got := DetectPlatform(ua); got != want {
+			t.Fatalf("%q: got %s want %s", ua, got, want)
+		}
+	}
+}
+
+func TestDeepLinks(t *testing.T) {
+	sub := "https://sub.example/s/abc?x=1"
+	cases := map[string]string{
+		AppV2RayTun:  "v2raytun://import/https://sub.example/s/abc?x=1",
+		AppStreisand: "streisand://import/https://sub.example/s/abc?x=1#VFF%20VPN",
+		AppHiddify:   "hiddify://import/https://sub.example/s/abc?x=1#VFF%20VPN",
+		AppFoXray:    "foxray://yiguo.dev/sub/add/?url=https%3A%2F%2Fsub.example%2Fs%2Fabc%3Fx%3D1#VFF%20VPN",
+		AppSingBox:   "sing-box://import-remote-profile?url=https%3A%2F%2Fsub.example%2Fs%2Fabc%3Fx%3D1#VFF%20VPN",
+	}
+	for id, want := range cases {
+		a, ok := ByID(id)
+		if !ok {
+			t.Fatalf("no app %s", id)
+		}
+		got, err := a.DeepLink(sub, "VFF VPN")
+		if err != nil || got != want {
+			t.Fatalf("%s: got %q (%v) want %q", id, got, err, want)
+		}
+		if !IsAllowedDeepLink(got) {
+			t.Fatalf("%s: own link not allowed", id)
+		}
+	}
+	h, _ := ByID("HAPP")
+	got, err := h.DeepLink(sub, "")
+	if err != nil || !strings.HasPrefix(got, "happ://crypt4/") || !h.Encrypted {
+		t.Fatalf("happ %q %v", got, err)
+	}
+}
+
+func TestDeepLink_RejectsNonHTTP(t *testing.T) {
+	a, _ := ByID(AppHiddify)
+	for _, sub := range []string{"", "javascript:alert(1)", "ftp://x/y", "/relative"} {
+		if _, err := a.DeepLink(sub, ""); err == nil {
+			t.Fatalf("%q accepted", sub)
+		}
+	}
+}
+
+func TestIsAllowedDeepLink(t *testing.T) {
+	for raw, want := range map[string]bool{
+		"happ://crypt4/abc":     true,
+		"HIDDIFY://import/x":    true,
+		"https://evil.example":  false,
+		"javascript://alert(1)": false,
+		"intent://x":            false,
+		"happ://":               false,
+		"":                      false,
+	} {
+		if got := IsAllowedDeepLink(raw); got != want {
+			t.Fatalf("%q: got %v", raw, got)
+		}
+	}
+}
+
+func TestForPlatform(t *testing.T) {
+	for _, a := range ForPlatform(PlatformAndroid) {
+		if a.ID == AppStreisand || a.ID == AppFoXray {
+			t.Fatalf("%s offered on android", a.ID)
+		}
+	}
+	if len(ForPlatform(PlatformUnknown)) != len(All()) {
+		t.Fatal("unknown platform must list all apps")
+	}
+}
@@ -115,20 +115,27 @@ func buildRFC822(fromHeader, to, subject, body string) string {
 	return b.String()
 }
 
-// SendAccountLoginEmail — magic-link вход в личный кабинет.
-func SendAccountLoginEmail(cfg *config.Config, to, loginURL string) error {
+// SendAccountLoginEmailWithCode — magic-link входа в личный кабинет и (если code не пуст) одноразовый код для ввода на странице входа.
+func SendAccountLoginEmailWithCode(cfg *config.Config, to, loginURL, code string) error {
 	brand, err := brandDisplayName(cfg)
 	if err != nil {
 		return err
 	}
 	subject := brand + " — вход в личный кабинет"
+	codeBlock := ""
+	if c := strings.TrimSpace(code); c != "" {
+		codeBlock = fmt.Sprintf(`
+Или введите код на странице входа: %s
+Код действует несколько минут и может быть использован один раз.
+`, c)
+	}
 	body := fmt.Sprintf(`%s
 
 Для входа в личный кабинет откройте ссылку:
 %s
-
+%s
 Если вы не запрашивали вход, просто проигнорируйте это письмо.
-`, brand, strings.TrimSpace(loginURL))
+`, brand, strings.TrimSpace(loginURL), codeBlock)
 	return sendPlain(cfg, strings.TrimSpace(to), subject, body)
 }
 
@@ -150,3 +157,107 @@ func SendAccountLinkConfirmEmail(cfg *config.Config, to, confirmURL string) erro
 `, brand, strings.TrimSpace(confirmURL))
 	return sendPlain(cfg, strings.TrimSpace(to), subject, body)
 }
+
+// SendAccountEmailChangeConfirmEmail — письмо на новый адрес для подтверждения смены email кабинета.
+func SendAccountEmailChangeConfirmEmail(cfg *config.Config, to, confirmURL string) error {
+	brand, err := brandDisplayName(cfg)
+	if err != nil {
+		return err
+	}
+	subject := brand + " — подтвердите новый email"
+	body := fmt.Sprintf(`%s
+
+Подтвердите этот адрес, чтобы входить в личный кабинет с ним вместо прежнего email.
+
+Откройте ссылку:
+%s
+
+Если вы не запрашивали смену email, проигнорируйте письмо.
+`, brand, strings.TrimSpace(confirmURL))
+	return sendPlain(cfg, strings.TrimSpace(to), subject, body)
+}
+
+// SendAccountEmailChangedNotice — уведомление на прежний адрес после смены или отвязки email.
+// newEmail пуст → email отвязан от Telegram-аккаунта.
+func SendAccountEmailChangedNotice(cfg *config.Config, to, newEmail string) error {
+	brand, err := brandDisplayName(cfg)
+	if err != nil {
+		return err
+	}
+	subject := brand + " — email личного кабинета изменён"
+	what := "Этот адрес отвязан от вашего аккаунта: войти в личный кабинет с ним больше нельзя."
+	if ne := strings.TrimSpace(newEmail); ne != "" {
+		what = fmt.Sprintf("Email вашего личного кабинета изменён на %s. Войти с этим адресом больше нельзя.", ne)
+	}
+	body := fmt.Sprintf(`%s
+
+%s
+
+Если это сделали не вы, срочно напишите в поддержку.
+`, brand, what)
+	return sendPlain(cfg, strings.TrimSpace(to), subject, body)
+}
+
+// SendAccountPrivacyConfirmEmail — подтверждение выгрузки данных (deleteAccount=false) или удаления аккаунта.
+func SendAccountPrivacyConfirmEmail(cfg *config.Config, to, confirmURL string, deleteAccount bool) error {
+	brand, err := brandDisplayName(cfg)
+	if err != nil {
+		return err
+	}
+	subject := brand + " — выгрузка ваших данных"
+	what := "Чтобы скачать архив с данными вашего аккаунта (профиль, услуги, платежи и списания), откройте ссылку:"
+	if deleteAccount {
+		subject = brand + " — подтвердите удаление аккаунта"
+		what = "Вы запросили удаление аккаунта. Услуги будут отменены, личные данные обезличены, вход в кабинет станет невозможен. Неизрасходованный баланс не возвращается автоматически.\n\nЧтобы подтвердить удаление, откройте ссылку:"
+	}
+	body := fmt.Sprintf(`%s
+
+%s
+%s
+
+Если вы не отправляли этот запрос, проигнорируйте письмо.
+`, brand, what, strings.TrimSpace(confirmURL))
+	return sendPlain(cfg, strings.TrimSpace(to), subject, body)
+}
+
+// SendPremiumTrafficAlertEmail — предупреждение о расходе трафика Premium-услуги (percent ≥ 100 — лимит исчерпан).
+// upgradeURL пуст → без ссылки на каталог.
+func SendPremiumTrafficAlertEmail(cfg *config.Config, to, serviceName string, percent int, used, limit, upgradeURL string) error {
+	brand, err := brandDisplayName(cfg)
+	if err != nil {
+		return err
+	}
+	subject := fmt.Sprintf("%s — использовано %d%% трафика", brand, percent)
+	what := fmt.Sprintf("По услуге «%s» использовано %s из %s (%d%%).", strings.TrimSpace(serviceName), used, limit, percent)
+	if percent >= 100 {
+		subject = brand + " — трафик исчерпан"
+		what += " Лимит исчерпан: подключение будет ограничено до начала следующего периода."
+	}
+	body := brand + "\n\n" + what + "\n"
+	if u := strings.TrimSpace(upgradeURL); u != "" {
+		body += "\nТариф с большим объёмом трафика можно выбрать здесь:\n" + u + "\n"
+	}
+	return sendPlain(cfg, strings.TrimSpace(to), subject, body)
+}
+
+// SendPremiumEventEmail — уведомление о событии Premium-услуги из Remnawave (истекла, отключена, новое устройство…).
+func SendPremiumEventEmail(cfg *config.Config, to, title, text string) error {
+	brand, err := brandDisplayName(cfg)
+	if err != nil {
+		return err
+	}
+	subject := brand + " — " + strings.TrimSpace(title)
+	body := brand + "\n\n" + strings.TrimSpace(text) + "\n"
+	return sendPlain(cfg, strings.TrimSpace(to), subject, body)
+}
+
+// SendOrderResultEmail — итог автоматического заказа услуги после оплаты (для пользователей без Telegram).
+func SendOrderResultEmail(cfg *config.Config, to, title, text string) error {
+	brand, err := brandDisplayName(cfg)
+	if err != nil {
+		return err
+	}
+	subject := brand + " — " + strings.TrimSpace(title)
+	body := brand + "\n\n" + strings.TrimSpace(text) + "\n"
+	return sendPlain(cfg, strings.TrimSpace(to), subject, body)
+}
@@ -69,7 +69,7 @@ func forbidSendMail(t *testing.T) {
 func TestSendAccountLoginEmail_VFFUnchanged(t *testing.T) {
 	msg := captureSendMail(t)
 	cfg := configuredEmailCfg("VPN for Friends")
-	if err := SendAccountLoginEmail(cfg, "user@example.com", "https://example/session?token=x"); err != nil {
+	if err := SendAccountLoginEmailWithCode(cfg, "user@example.com", "https://example/session?token=x", ""); err != nil {
 		t.Fatal(err)
 	}
 	if !strings.Contains(*msg, "Subject: VPN for Friends — вход в личный кабинет\r\n") {
@@ -87,7 +87,7 @@ func TestSendAccountLoginEmail_FCBrandAware(t *testing.T) {
 	msg := captureSendMail(t)
 	cfg := configuredEmailCfg("Friends Connect")
 	cfg.Brand.ID = "fc"
-	if err := SendAccountLoginEmail(cfg, "user@example.com", "https://example/session?token=x"); err != nil {
+	if err := SendAccountLoginEmailWithCode(cfg, "user@example.com", "https://example/session?token=x", ""); err != nil {
 		t.Fatal(err)
 	}
 	if !strings.Contains(*msg, "Subject: Friends Connect — вход в личный кабинет\r\n") {
@@ -108,7 +108,7 @@ func TestSendAccountLoginEmail_TrimmedBrandName(t *testing.T) {
 	msg := captureSendMail(t)
 	cfg := configuredEmailCfg(" Friends Connect ")
 	cfg.Brand.ID = "fc"
-	if err := SendAccountLoginEmail(cfg, "user@example.com", "https://example/session?token=x"); err != nil {
+	if err := SendAccountLoginEmailWithCode(cfg, "user@example.com", "https://example/session?token=x", ""); err != nil {
 		t.Fatal(err)
 	}
 	if !strings.Contains(*msg, "Subject: Friends Connect — вход в личный кабинет\r\n") {
@@ -142,7 +142,7 @@ func TestSendAccountLoginEmail_ExplicitFromNamePriority(t *testing.T) {
 	cfg := configuredEmailCfg("Friends Connect")
 	cfg.Brand.ID = "fc"
 	cfg.Email.FromName = "Custom Sender"
-	if err := SendAccountLoginEmail(cfg, "user@example.com", "https://example/session?token=x"); err != nil {
+	if err := SendAccountLoginEmailWithCode(cfg, "user@example.com", "https://example/session?token=x", ""); err != nil {
 		t.Fatal(err)
 	}
 	if !strings.Contains(*msg, `From: "Custom Sender" <noreply@test.example>`) {
@@ -167,7 +167,7 @@ func TestSendAccountLoginEmail_FailClosedBrandName(t *testing.T) {
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			forbidSendMail(t)
-			err := SendAccountLoginEmail(tc.cfg, "user@example.com", "https://example/session?token=x")
+			err := SendAccountLoginEmailWithCode(tc.cfg, "user@example.com", "https://example/session?token=x", "")
 			if !errors.Is(err, ErrBrandNameRequired) {
 				t.Fatalf("err=%v", err)
 			}
@@ -179,7 +179,7 @@ func TestSendAccountLoginEmail_FromNameDoesNotBypassBrandName(t *testing.T) {
 	forbidSendMail(t)
 	cfg := configuredEmailCfg("")
 	cfg.Email.FromName = "Custom Sender"
-	err := SendAccountLoginEmail(cfg, "user@example.com", "https://example/session?token=x")
+	err := SendAccountLoginEmailWithCode(cfg, "user@example.com", "https://example/session?token=x", "")
 	if !errors.Is(err, ErrBrandNameRequired) {
 		t.Fatalf("err=%v", err)
 	}
@@ -218,3 +218,94 @@ func TestBrandDisplayName_FailClosed(t *testing.T) {
 		})
 	}
 }
+
+func TestSendAccountLoginEmailWithCode_IncludesLinkAndCode(t *testing.T) {
+	msg := captureSendMail(t)
+	cfg := configuredEmailCfg("VPN for Friends")
+	if err := SendAccountLoginEmailWithCode(cfg, "user@example.com", "https://example/session?token=x", "123456"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(*msg, "https://example/session?token=x") {
+		t.Fatalf("link: %s", *msg)
+	}
+	if !strings.Contains(*msg, "Или введите код на странице входа: 123456") {
+		t.Fatalf("code: %s", *msg)
+	}
+}
+
+func TestSendAccountLoginEmail_NoCodeBlock(t *testing.T) {
+	msg := captureSendMail(t)
+	cfg := configuredEmailCfg("VPN for Friends")
+	if err := SendAccountLoginEmailWithCode(cfg, "user@example.com", "https://example/session?token=x", ""); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(*msg, "введите код") {
+		t.Fatalf("unexpected code block: %s", *msg)
+	}
+}
+
+func TestSendAccountEmailChangeEmails(t *testing.T) {
+	msg := captureSendMail(t)
+	cfg := configuredEmailCfg("Friends Connect")
+	if err := SendAccountEmailChangeConfirmEmail(cfg, "new@example.com", "https://example/account/email/confirm?token=z"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(*msg, "Subject: Friends Connect — подтвердите новый email\r\n") || !strings.Contains(*msg, "token=z") {
+		t.Fatalf("confirm: %s", *msg)
+	}
+
+	if err := SendAccountEmailChangedNotice(cfg, "old@example.com", "new@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(*msg, "изменён на new@example.com") {
+		t.Fatalf("changed notice: %s", *msg)
+	}
+	if err := SendAccountEmailChangedNotice(cfg, "old@example.com", ""); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(*msg, "отвязан") {
+		t.Fatalf("unlink notice: %s", *msg)
+	}
+}
+
+func TestSendAccountPrivacyConfirmEmail(t *testing.T) {
+	msg := captureSendMail(t)
+	cfg := configuredEmailCfg("Friends Connect")
+	if err := SendAccountPrivacyConfirmEmail(cfg, "me@example.com", "https://example/api/account/export?confirm_token=e", false); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(*msg, "Subject: Friends Connect — выгрузка ваших данных\r\n") || !strings.Contains(*msg, "confirm_token=e") {
+		t.Fatalf("export: %s", *msg)
+	}
+	if err := SendAccountPrivacyConfirmEmail(cfg, "me@example.com", "https://example/account/delete/confirm?token=d", true); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(*msg, "Subject: Friends Connect — подтвердите удаление аккаунта\r\n") || !strings.Contains(*msg, "token=d") {
+		t.Fatalf("delete: %s", *msg)
+	}
+}
+
+func TestSendPremiumTrafficAlertEmail(t *testing.T) {
+	msg := captureSendMail(t)
+	cfg := configuredEmailCfg("VPN for Friends")
+	if err := SendPremiumTrafficAlertEmail(cfg, "user@example.com", "Premium", 100, "100 ГБ", "100 ГБ", "https://example/buy"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(*msg, "Subject: VPN for Friends — трафик исчерпан\r\n") {
+		t.Fatalf("subject: %s", *msg)
+	}
+	if !strings.Contains(*msg, "https://example/buy") || !strings.Contains(*msg, "(100%)") {
+		t.Fatalf("body: %s", *msg)
+	}
+}
+
+func TestSendPremiumEventEmail(t *testing.T) {
+	msg := captureSendMail(t)
+	cfg := configuredEmailCfg("VPN for Friends")
+	if err := SendPremiumEventEmail(cfg, " user@example.com ", "новое устройство", "К услуге подключено новое устройство."); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(*msg, "Subject: VPN for Friends — новое устройство\r\n") || !strings.Contains(*msg, "новое устройство.") {
+		t.Fatalf("msg: %s", *msg)
+	}
+}
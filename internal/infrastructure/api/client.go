@@ -423,6 +423,32 @@ func (c *APIClient) adminUserUpdate(method string, userID int, raw []byte) (resp
 	return respBody, resp.StatusCode, durationMs, nil
 }
 
+// PostAdminUserUpdateFields выполняет POST /shm/v1/admin/user с произвольным набором полей (login, login2, settings…).
+// Без проверки login2: вызывающий код сам перечитывает пользователя, если нужно убедиться в результате.
+func (c *APIClient) PostAdminUserUpdateFields(userID int, fields map[string]interface{}) error {
+	if userID <= 0 || len(fields) == 0 {
+		return fmt.Errorf("invalid update user fields")
+	}
+	payload := make(map[string]interface{}, len(fields)+1)
+	for k, v := range fields {
+		payload[k] = v
+	}
+	payload["user_id"] = userID
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	respBody, status, durMs, err := c.adminUserUpdate(http.MethodPost, userID, raw)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		slog.Error("shm admin user update", "stage", "post_fields_http_status", "user_id", userID, "status_code", status, "duration_ms", durMs, "body_bytes", len(respBody))
+		return fmt.Errorf("post admin user fields: HTTP %d (duration_ms=%d)", status, durMs)
+	}
+	return nil
+}
+
 // PostAdminUserUpdateSettings выполняет POST /shm/v1/admin/user и при наличии login2 обязательно проверяет,
 // что второй логин реально сохранился (GET по login2). Если после POST связка отсутствует — пробуем один PUT с тем же телом (некоторые билды SHM принимают login2 только там).
 func (c *APIClient) PostAdminUserUpdateSettings(userID int, login2 string, settingsObj map[string]interface{}) (*models.User, error) {
@@ -597,6 +623,72 @@ func (c *APIClient) GetUserServices(userID int) ([]models.UserService, error) {
 
 }
 
+// activeUserServicesPageSize — размер страницы при обходе всех активных услуг (фоновые задачи).
+const activeUserServicesPageSize = 500
+
+// maxActiveUserServicesPages — защита от зацикливания, если SHM игнорирует offset.
+const maxActiveUserServicesPages = 200
+
+// ListActiveUserServices постранично загружает все услуги со status=ACTIVE в категории бренда
+// (GET /shm/v1/admin/user/service?filter=…&limit=…&offset=…). Используется фоновыми задачами, не запросами пользователей.
+func (c *APIClient) ListActiveUserServices() ([]models.UserService, error) {
+	f := map[string]any{"status": "ACTIVE"}
+	expectedCategory := c.expectedServiceCategory()
+	if expectedCategory != "" {
+		f["category"] = expectedCategory
+	}
+	fb, err := json.Marshal(f)
+	if err != nil {
+		return nil, fmt.Errorf("marshal filter: %w", err)
+	}
+
+	var out []models.UserService
+	seen := make(map[int]struct{})
+	for page := 0; page < maxActiveUserServicesPages; page++ {
+		q := url.Values{}
+		q.Set("filter", string(fb))
+		q.Set("limit", strconv.Itoa(activeUserServicesPageSize))
+		q.Set("offset", strconv.Itoa(page*activeUserServicesPageSize))
+		req, err := http.NewRequest(http.MethodGet, c.ServerURL+"/shm/v1/admin/user/service?"+q.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := c.HTTPClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Data []models.UserService `json:"data"`
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("list active user services: API returned status %d", resp.StatusCode)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("decode active user services: %w", err)
+		}
+
+		added := 0
+		for _, us := range result.Data {
+			if _, dup := seen[us.ServiceID]; dup {
+				continue
+			}
+			seen[us.ServiceID] = struct{}{}
+			added++
+			if us.Status != "ACTIVE" || !models.ServiceCategoryAllowed(expectedCategory, us.Category) {
+				continue
+			}
+			out = append(out, us)
+		}
+		if len(result.Data) < activeUserServicesPageSize || added == 0 {
+			return out, nil
+		}
+	}
+	return nil, fmt.Errorf("list active user services: page limit exceeded")
+}
+
 func parsePositiveUserServiceID(userServiceID string) (int, error) {
 	s := strings.TrimSpace(userServiceID)
 	if s == "" {
@@ -678,6 +770,51 @@ func (c *APIClient) GetUserServiceByUserID(userID int, userServiceID string) (*m
 	return &us, nil
 }
 
+// GetUserServiceByID загружает user_service по одному user_service_id без привязки к владельцу
+// (входящие события, где известна только услуга). Ключи Marzban не подгружаются.
+// Нет услуги или категория вне активного бренда → ErrUserServiceUnavailable.
+func (c *APIClient) GetUserServiceByID(userServiceID int) (*models.UserService, error) {
+	if userServiceID <= 0 {
+		return nil, fmt.Errorf("invalid user service id")
+	}
+	f := map[string]any{"user_service_id": userServiceID}
+	expectedCategory := c.expectedServiceCategory()
+	if expectedCategory != "" {
+		f["category"] = expectedCategory
+	}
+	fb, err := json.Marshal(f)
+	if err != nil {
+		return nil, fmt.Errorf("marshal filter: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodGet,
+		fmt.Sprintf("%s/shm/v1/admin/user/service?filter=%s", c.ServerURL, url.QueryEscape(string(fb))), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("get user service: API returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Data []models.UserService `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	for _, us := range result.Data {
+		if us.ServiceID == userServiceID && us.UserID > 0 && models.ServiceCategoryAllowed(expectedCategory, us.Category) {
+			return &us, nil
+		}
+	}
+	return nil, ErrUserServiceUnavailable
+}
+
 func (c *APIClient) GetUserKeyMarzban(userID int, serviceID int) (*models.UserKeyMarzban, error) {
 
 	// Формируем URL для запроса
@@ -756,6 +893,80 @@ func (c *APIClient) DeleteUserService(userID int, serviceID string) error {
 	return nil
 }
 
+// UpdateUserService меняет поля user_service владельца: POST /shm/v1/admin/user/service
+// (settings, next). settings SHM заменяет целиком — передавайте полный объект.
+func (c *APIClient) UpdateUserService(userID, userServiceID int, fields map[string]interface{}) error {
+	if userID <= 0 || userServiceID <= 0 {
+		return fmt.Errorf("invalid user service")
+	}
+	body := map[string]interface{}{}
+	for k, v := range fields {
+		body[k] = v
+	}
+	body["user_id"] = userID
+	body["user_service_id"] = userServiceID
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, c.ServerURL+"/shm/v1/admin/user/service", bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("update user service: API status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// ProlongUserService продлевает услугу на следующий период с баланса пользователя:
+// PUT /shm/v1/admin/user/service/prolongate. Стоимость периода списывает SHM (обычный withdraw);
+// при нехватке средств SHM отвечает ошибкой и услугу не меняет.
+func (c *APIClient) ProlongUserService(userID, userServiceID int) (*models.UserService, error) {
+	if userID <= 0 || userServiceID <= 0 {
+		return nil, fmt.Errorf("invalid user service")
+	}
+	raw, err := json.Marshal(map[string]interface{}{"user_id": userID, "user_service_id": userServiceID})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPut, c.ServerURL+"/shm/v1/admin/user/service/prolongate", bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("prolong user service: API status %d", resp.StatusCode)
+	}
+	var result struct {
+		Data []models.UserService `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode prolonged user service: %w", err)
+	}
+	for i := range result.Data {
+		if result.Data[i].ServiceID == userServiceID {
+			return &result.Data[i], nil
+		}
+	}
+	return nil, nil
+}
+
 // expectedServiceCategory — разрешённая категория услуг из эффективного бренда (пустая строка = без ограничения).
 func (c *APIClient) expectedServiceCategory() string {
 	if c == nil || c.config == nil {
@@ -974,6 +1185,182 @@ func (c *APIClient) GetUserPays(userID int) ([]models.UserPay, error) {
 	return result.Data, nil
 }
 
+// recentUserPaysPageSize — размер страницы при обходе недавних платежей всех пользователей (фоновые задачи).
+const recentUserPaysPageSize = 500
+
+// maxRecentUserPaysPages — защита от зацикливания, если SHM игнорирует offset.
+const maxRecentUserPaysPages = 20
+
+// ListUserPaysSince постранично загружает платежи всех пользователей с date >= since
+// (GET /shm/v1/admin/user/pay?filter={"date":{">=":since}[,"pay_system_id":…]}&limit=…&offset=…); since — в формате SHM
+// "2006-01-02 15:04:05" (МСК), пустой paySystemID — любые платежи. Фильтр повторяется на нашей стороне.
+// Используется фоновыми задачами.
+func (c *APIClient) ListUserPaysSince(since, paySystemID string) ([]models.UserPay, error) {
+	since = strings.TrimSpace(since)
+	if since == "" {
+		return nil, fmt.Errorf("list pays: empty since")
+	}
+	f := map[string]any{"date": map[string]any{">=": since}}
+	paySystemID = strings.TrimSpace(paySystemID)
+	if paySystemID != "" {
+		f["pay_system_id"] = paySystemID
+	}
+	fb, err := json.Marshal(f)
+	if err != nil {
+		return nil, fmt.Errorf("marshal pays filter: %w", err)
+	}
+
+	var out []models.UserPay
+	seen := make(map[int]struct{})
+	for page := 0; page < maxRecentUserPaysPages; page++ {
+		q := url.Values{}
+		q.Set("filter", string(fb))
+		q.Set("limit", strconv.Itoa(recentUserPaysPageSize))
+		q.Set("offset", strconv.Itoa(page*recentUserPaysPageSize))
+		req, err := http.NewRequest(http.MethodGet, c.ServerURL+"/shm/v1/admin/user/pay?"+q.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := c.HTTPClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Data []models.UserPay `json:"data"`
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("list pays: API status %d", resp.StatusCode)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("decode pays: %w", err)
+		}
+
+		added := 0
+		for _, p := range result.Data {
+			if _, dup := seen[p.ID]; dup {
+				continue
+			}
+			seen[p.ID] = struct{}{}
+			added++
+			if p.UserID <= 0 || strings.TrimSpace(p.Date) < since || (paySystemID != "" && p.PaySystemID != paySystemID) {
+				continue
+			}
+			out = append(out, p)
+		}
+		if len(result.Data) < recentUserPaysPageSize || added == 0 {
+			return out, nil
+		}
+	}
+	return nil, fmt.Errorf("list pays: page limit exceeded")
+}
+
+// FindUserPayByUniqKey ищет платёж по uniq_key среди всех пользователей; при нескольких — самый ранний (меньший id).
+// nil без ошибки — платежа нет.
+func (c *APIClient) FindUserPayByUniqKey(uniqKey string) (*models.UserPay, error) {
+	pays, err := c.FindUserPaysByUniqKey(uniqKey)
+	if err != nil || len(pays) == 0 {
+		return nil, err
+	}
+	return &pays[0], nil
+}
+
+// FindUserPaysByUniqKey — все платежи с uniq_key среди всех пользователей (GET /shm/v1/admin/user/pay?filter={"uniq_key":…})
+// по возрастанию id.
+func (c *APIClient) FindUserPaysByUniqKey(uniqKey string) ([]models.UserPay, error) {
+	uniqKey = strings.TrimSpace(uniqKey)
+	if uniqKey == "" {
+		return nil, fmt.Errorf("find pay: empty uniq_key")
+	}
+	filterBytes, err := json.Marshal(map[string]any{"uniq_key": uniqKey})
+	if err != nil {
+		return nil, fmt.Errorf("marshal pay uniq_key filter: %w", err)
+	}
+	q := url.Values{}
+	q.Set("filter", string(filterBytes))
+	req, err := http.NewRequest(http.MethodGet, c.ServerURL+"/shm/v1/admin/user/pay?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("find pay: API status %d", resp.StatusCode)
+	}
+	var result struct {
+		Data []models.UserPay `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode pay by uniq_key: %w", err)
+	}
+	var out []models.UserPay
+	for _, p := range result.Data {
+		// Фильтр может быть проигнорирован старой версией SHM — сверяем ключ сами.
+		if p.UniqKey == uniqKey {
+			out = append(out, p)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+// AddUserPayment зачисляет платёж на баланс: PUT /shm/v1/admin/user/payment.
+// uniqKey — ключ идемпотентности платежа (user/pay.uniq_key); вызывающий сверяет его через FindUserPayByUniqKey.
+func (c *APIClient) AddUserPayment(userID int, money float64, paySystemID, uniqKey string, comment map[string]interface{}) error {
+	if userID <= 0 || money <= 0 {
+		return fmt.Errorf("invalid user payment")
+	}
+	return c.putUserPayment(userID, money, paySystemID, uniqKey, comment)
+}
+
+// DebitUserBalance списывает amount с баланса платежом с отрицательной суммой (тот же PUT /admin/user/payment).
+// Так деньги уходят без заказа услуги на этого пользователя; uniqKey защищает от повторного списания.
+func (c *APIClient) DebitUserBalance(userID int, amount float64, paySystemID, uniqKey string, comment map[string]interface{}) error {
+	if userID <= 0 || amount <= 0 {
+		return fmt.Errorf("invalid user debit")
+	}
+	return c.putUserPayment(userID, -amount, paySystemID, uniqKey, comment)
+}
+
+func (c *APIClient) putUserPayment(userID int, money float64, paySystemID, uniqKey string, comment map[string]interface{}) error {
+	body := map[string]interface{}{
+		"user_id":       userID,
+		"money":         money,
+		"pay_system_id": paySystemID,
+	}
+	if uniqKey != "" {
+		body["uniq_key"] = uniqKey
+	}
+	if comment != nil {
+		body["comment"] = comment
+	}
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPut, c.ServerURL+"/shm/v1/admin/user/payment", bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("add user payment: API status %d", resp.StatusCode)
+	}
+	return nil
+}
+
 // HasUserServiceWithdrawals возвращает true, если у пользователя есть хотя бы одно списание по услуге.
 func (c *APIClient) HasUserServiceWithdrawals(userID int, serviceID int) (bool, error) {
 	// Собираем filter={"user_id":19,"service_id":8} как query string
@@ -1023,3 +1410,75 @@ func (c *APIClient) HasUserServiceWithdrawals(userID int, serviceID int) (bool,
 
 	return len(result.Data) > 0, nil
 }
+
+// userWithdrawalsPageSize — размер страницы при загрузке всех списаний пользователя.
+const userWithdrawalsPageSize = 500
+
+// maxUserWithdrawalsPages — защита от зацикливания, если SHM игнорирует offset.
+const maxUserWithdrawalsPages = 100
+
+// GetUserWithdrawals постранично загружает все списания пользователя (GetUserWithdrawalsPage до неполной страницы).
+func (c *APIClient) GetUserWithdrawals(userID int) ([]models.WithdrawItem, error) {
+	var out []models.WithdrawItem
+	seen := make(map[int64]struct{})
+	for page := 0; page < maxUserWithdrawalsPages; page++ {
+		rows, err := c.GetUserWithdrawalsPage(userID, userWithdrawalsPageSize, page*userWithdrawalsPageSize)
+		if err != nil {
+			return nil, err
+		}
+		added := 0
+		for _, w := range rows {
+			if _, dup := seen[w.WithdrawID]; dup && w.WithdrawID != 0 {
+				continue
+			}
+			seen[w.WithdrawID] = struct{}{}
+			added++
+			out = append(out, w)
+		}
+		if len(rows) < userWithdrawalsPageSize || added == 0 {
+			return out, nil
+		}
+	}
+	return nil, fmt.Errorf("get user withdrawals: page limit exceeded")
+}
+
+// GetUserWithdrawalsPage возвращает одну страницу списаний пользователя:
+// GET /shm/v1/admin/user/service/withdraw?filter={"user_id":N}&limit=…&offset=….
+func (c *APIClient) GetUserWithdrawalsPage(userID, limit, offset int) ([]models.WithdrawItem, error) {
+	if limit <= 0 || offset < 0 {
+		return nil, fmt.Errorf("get user withdrawals: invalid page limit=%d offset=%d", limit, offset)
+	}
+	filterBytes, err := json.Marshal(map[string]any{"user_id": userID})
+	if err != nil {
+		return nil, fmt.Errorf("marshal user withdrawals filter: %w", err)
+	}
+
+	q := url.Values{}
+	q.Set("filter", string(filterBytes))
+	q.Set("limit", strconv.Itoa(limit))
+	q.Set("offset", strconv.Itoa(offset))
+
+	fullURL := c.ServerURL + "/shm/v1/admin/user/service/withdraw?" + q.Encode()
+	req, err := http.NewRequest(http.MethodGet, fullURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("get user withdrawals: API returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Data []models.WithdrawItem `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode user withdrawals: %w", err)
+	}
+	return result.Data, nil
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestListActiveUserServices_Paginates(t *testing.T) {
+	var offsets []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/shm/v1/admin/user/service" {
+			http.NotFound(w, r)
+			return
+		}
+		q := r.URL.Query()
+		if !strings.Contains(q.Get("filter"), `"status":"ACTIVE"`) {
+			t.Errorf("filter %q", q.Get("filter"))
+		}
+		offsets = append(offsets, q.Get("offset"))
+		offset, _ := strconv.Atoi(q.Get("offset"))
+		n := activeUserServicesPageSize
+		if offset > 0 {
+			n = 2
+		}
+		rows := make([]string, 0, n)
+		for i := 0; i < n; i++ {
+			status := "ACTIVE"
+			if offset > 0 && i == 1 {
+				status = "BLOCK"
+			}
+			rows = append(rows, fmt.Sprintf(`{"user_service_id":%d,"user_id":1,"status":%q}`, offset+i+1, status))
+		}
+		_, _ = io.WriteString(w, `{"data":[`+strings.Join(rows, ",")+`]}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	c := APIClient{ServerURL: srv.URL, HTTPClient: srv.Client()}
+	list, err := c.ListActiveUserServices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != activeUserServicesPageSize+1 {
+		t.Fatalf("got %d rows", len(list))
+	}
+	if strings.Join(offsets, ",") != "0,500" {
+		t.Fatalf("offsets %v", offsets)
+	}
+}
+
+func TestGetUserServiceByID(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.URL.Query().Get("filter"), `"user_service_id":50`) {
+			t.Errorf("filter %q", r.URL.Query().Get("filter"))
+		}
+		_, _ = io.WriteString(w, `{"data":[{"user_service_id":501,"user_id":7,"status":"ACTIVE"}]}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	c := APIClient{ServerURL: srv.URL, HTTPClient: srv.Client()}
+	us, err := c.GetUserServiceByID(501)
+	if err != nil || us.UserID != 7 {
+		t.Fatalf("us=%+v err=%v", us, err)
+	}
+	if _, err := c.GetUserServiceByID(502); err != ErrUserServiceUnavailable {
+		t.Fatalf("mismatched id: %v", err)
+	}
+}
@@ -240,3 +240,30 @@ func TestAPIClient_PostAdminUserUpdateSettings_Login2RecoveredAfterPUT(t *testin
 		t.Fatalf("post=%d put=%d gets=%d", posts, puts, verifyGets)
 	}
 }
+
+func TestAPIClient_PostAdminUserUpdateFields(t *testing.T) {
+	var got map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/shm/v1/admin/user" || r.Method != http.MethodPost {
+			http.NotFound(w, r)
+			return
+		}
+		raw, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(raw, &got); err != nil {
+			t.Fatal(err)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+
+	c := APIClient{ServerURL: srv.URL, HTTPClient: srv.Client()}
+	if err := c.PostAdminUserUpdateFields(9, map[string]interface{}{"login": "merged_9_to_3", "login2": "", "user_id": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if got["user_id"].(float64) != 9 || got["login"] != "merged_9_to_3" || got["login2"] != "" {
+		t.Fatalf("payload %#v", got)
+	}
+	if err := c.PostAdminUserUpdateFields(9, nil); err == nil {
+		t.Fatal("empty fields must fail")
+	}
+}
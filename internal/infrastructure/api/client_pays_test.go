@@ -80,3 +80,49 @@ func TestGetUserPays_StatusNotOK(t *testing.T) {
 		t.Fatalf("got %v", err)
 	}
 }
+
+func TestAddUserPayment(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.URL.Path != "/shm/v1/admin/user/payment" {
+			http.NotFound(w, r)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		got = string(b)
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+	c := APIClient{ServerURL: srv.URL, HTTPClient: srv.Client()}
+	if err := c.AddUserPayment(42, 450, "card_checkout", "card:cs_1", map[string]interface{}{"session_id": "cs_1"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"user_id":42`, `"money":450`, `"pay_system_id":"card_checkout"`, `"uniq_key":"card:cs_1"`, `"session_id":"cs_1"`} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("body %s: missing %s", got, want)
+		}
+	}
+	if err := c.AddUserPayment(42, 0, "x", "", nil); err == nil {
+		t.Fatal("zero money must fail")
+	}
+}
+
+func TestFindUserPayByUniqKey(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("filter"); !strings.Contains(got, `"uniq_key":"voucher:vff:`) {
+			t.Errorf("filter %q", got)
+		}
+		// Второй платёж — на случай, если SHM вернёт лишнее: ключ сверяется на клиенте.
+		_, _ = io.WriteString(w, `{"data":[{"id":1,"user_id":7,"uniq_key":"other"},{"id":2,"user_id":42,"money":300,"uniq_key":"voucher:vff:1"}]}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	c := APIClient{ServerURL: srv.URL, HTTPClient: srv.Client()}
+	p, err := c.FindUserPayByUniqKey("voucher:vff:1")
+	if err != nil || p == nil || p.ID != 2 || p.UserID != 42 {
+		t.Fatalf("%+v %v", p, err)
+	}
+	if p, err := c.FindUserPayByUniqKey("voucher:vff:404"); err != nil || p != nil {
+		t.Fatalf("missing: %+v %v", p, err)
+	}
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUpdateUserServiceAndProlong(t *testing.T) {
+	var bodies []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		body["method"] = r.Method
+		body["path"] = r.URL.Path
+		bodies = append(bodies, body)
+		if r.URL.Path == "/shm/v1/admin/user/service/prolongate" {
+			_, _ = io.WriteString(w, `{"data":[{"user_service_id":501,"user_id":42,"status":"ACTIVE","expire":"2026-12-01 10:00:00","next":-1,"settings":{"auto_renew":false}}]}`)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	c := APIClient{ServerURL: srv.URL, HTTPClient: srv.Client()}
+
+	if err := c.UpdateUserService(42, 501, map[string]interface{}{"next": -1, "user_id": 7}); err != nil {
+		t.Fatal(err)
+	}
+	us, err := c.ProlongUserService(42, 501)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if us == nil || us.Expire != "2026-12-01 10:00:00" || us.AutoRenew() {
+		t.Fatalf("%+v", us)
+	}
+	if b := bodies[0]; b["method"] != http.MethodPost || b["path"] != "/shm/v1/admin/user/service" || b["user_id"] != 42.0 || b["user_service_id"] != 501.0 || b["next"] != -1.0 {
+		t.Fatalf("update %+v", b)
+	}
+	if b := bodies[1]; b["method"] != http.MethodPut || b["user_service_id"] != 501.0 {
+		t.Fatalf("prolong %+v", b)
+	}
+	if err := c.UpdateUserService(0, 501, nil); err == nil {
+		t.Fatal("invalid user must fail before request")
+	}
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestGetUserWithdrawals_OK(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/shm/v1/admin/user/service/withdraw" {
+			http.NotFound(w, r)
+			return
+		}
+		if got := r.URL.Query().Get("filter"); !strings.Contains(got, `"user_id":42`) {
+			t.Errorf("unexpected filter query: %q", got)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"data":[{"withdraw_id":9,"user_id":42,"name":"VPN","total":199,"withdraw_date":"2026-01-01 10:00:00"}]}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	c := APIClient{ServerURL: srv.URL, HTTPClient: srv.Client()}
+	list, err := c.GetUserWithdrawals(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].WithdrawID != 9 || list[0].Total != 199 {
+		t.Fatalf("got %+v", list)
+	}
+}
+
+func TestGetUserWithdrawals_StatusNotOK(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	t.Cleanup(srv.Close)
+
+	c := APIClient{ServerURL: srv.URL, HTTPClient: srv.Client()}
+	if _, err := c.GetUserWithdrawals(42); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestGetUserWithdrawals_Paginates(t *testing.T) {
+	var offsets []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		offsets = append(offsets, q.Get("offset"))
+		if q.Get("limit") != strconv.Itoa(userWithdrawalsPageSize) {
+			t.Errorf("limit %q", q.Get("limit"))
+		}
+		offset, _ := strconv.Atoi(q.Get("offset"))
+		n := userWithdrawalsPageSize
+		if offset > 0 {
+			n = 3
+		}
+		rows := make([]string, 0, n)
+		for i := 0; i < n; i++ {
+			rows = append(rows, fmt.Sprintf(`{"withdraw_id":%d,"user_id":42,"total":1}`, offset+i+1))
+		}
+		_, _ = io.WriteString(w, `{"data":[`+strings.Join(rows, ",")+`]}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	c := APIClient{ServerURL: srv.URL, HTTPClient: srv.Client()}
+	list, err := c.GetUserWithdrawals(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != userWithdrawalsPageSize+3 || strings.Join(offsets, ",") != "0,500" {
+		t.Fatalf("rows=%d offsets=%v", len(list), offsets)
+	}
+	if _, err := c.GetUserWithdrawalsPage(42, 0, 0); err == nil {
+		t.Fatal("expected invalid page error")
+	}
+}
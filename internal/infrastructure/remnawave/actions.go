@@ -0,0 +1,47 @@
+package remnawave
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// RevokeUserSubscription выполняет POST /api/users/{uuid|id}/actions/revoke: Remnawave выдаёт новый
+// short UUID, старая ссылка подписки перестаёт работать. Возвращает новую подписку.
+func (c *Client) RevokeUserSubscription(ctx context.Context, user User) (*Subscription, error) {
+	if c == nil {
+		return nil, fmt.Errorf("remnawave: nil client")
+	}
+	id, err := bandwidthUserPathID(user)
+	if err != nil {
+		return nil, err
+	}
+	body, _, err := c.doJSON(ctx, http.MethodPost, "/api/users/"+id+"/actions/revoke", map[string]any{})
+	if err != nil {
+		return nil, err
+	}
+	var root map[string]any
+	if err := json.Unmarshal(body, &root); err != nil {
+		return nil, fmt.Errorf("remnawave revoke json: %w", err)
+	}
+	respObj, ok := root["response"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("remnawave revoke: missing response")
+	}
+	return &Subscription{SubscriptionURL: strings.TrimSpace(stringField(respObj, "subscriptionUrl"))}, nil
+}
+
+// ResetUserTraffic выполняет POST /api/users/{uuid|id}/actions/reset-traffic.
+func (c *Client) ResetUserTraffic(ctx context.Context, user User) error {
+	if c == nil {
+		return fmt.Errorf("remnawave: nil client")
+	}
+	id, err := bandwidthUserPathID(user)
+	if err != nil {
+		return err
+	}
+	_, _, err = c.doJSON(ctx, http.MethodPost, "/api/users/"+id+"/actions/reset-traffic", nil)
+	return err
+}
@@ -0,0 +1,55 @@
+package remnawave
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRevokeUserSubscription(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/users/u-1/actions/revoke" {
+			t.Fatalf("%s %s", r.Method, r.URL.Path)
+		}
+		_, _ = w.Write([]byte(`{"response":{"uuid":"u-1","shortUuid":"newshort","subscriptionUrl":"https://sub.example/newshort"}}`))
+	}))
+	defer srv.Close()
+	sub, err := NewClient(srv.URL, "tok").RevokeUserSubscription(context.Background(), User{ID: 1, UUID: "u-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.SubscriptionURL != "https://sub.example/newshort" {
+		t.Fatalf("%+v", sub)
+	}
+}
+
+func TestResetUserTraffic(t *testing.T) {
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/users/42/actions/reset-traffic" {
+			t.Fatalf("%s %s", r.Method, r.URL.Path)
+		}
+		called = true
+		_, _ = w.Write([]byte(`{"response":{"id":42}}`))
+	}))
+	defer srv.Close()
+	if err := NewClient(srv.URL, "tok").ResetUserTraffic(context.Background(), User{ID: 42}); err != nil || !called {
+		t.Fatalf("called=%v err=%v", called, err)
+	}
+}
+
+func TestGetUserByUsernameActionTimestamps(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"response":{"id":42,"uuid":"u-1","username":"us_42","subRevokedAt":"2026-10-01T12:00:00.000Z","lastTrafficResetAt":null}}`))
+	}))
+	defer srv.Close()
+	user, err := NewClient(srv.URL, "tok").GetUserByUsername(context.Background(), "us_42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !user.SubRevokedAt.Equal(time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)) || !user.LastTrafficResetAt.IsZero() {
+		t.Fatalf("%+v", user)
+	}
+}
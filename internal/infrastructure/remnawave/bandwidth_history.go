@@ -0,0 +1,122 @@
+package remnawave
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BandwidthDay — трафик за сутки (Date — YYYY-MM-DD, как в categories API).
+type BandwidthDay struct {
+	Date  string
+	Bytes int64
+}
+
+// BandwidthNode — трафик пользователя через одну ноду за весь интервал.
+type BandwidthNode struct {
+	Name        string
+	CountryCode string
+	Bytes       int64
+}
+
+// UserBandwidthHistory — посуточный ряд и разбивка по нодам из одного ответа bandwidth-stats.
+type UserBandwidthHistory struct {
+	Days  []BandwidthDay
+	Nodes []BandwidthNode
+}
+
+// GetUserBandwidthHistory выполняет тот же GET /api/bandwidth-stats/users/{uuid|id}, что GetUserBandwidthStats,
+// но сохраняет посуточный ряд (categories + series[].data) и список нод вместо одной суммы.
+func (c *Client) GetUserBandwidthHistory(ctx context.Context, user User, start, end time.Time, topNodes int) (*UserBandwidthHistory, error) {
+	if c == nil {
+		return nil, fmt.Errorf("remnawave: nil client")
+	}
+	if !end.After(start) {
+		return nil, fmt.Errorf("remnawave: invalid usage range")
+	}
+	id, err := bandwidthUserPathID(user)
+	if err != nil {
+		return nil, err
+	}
+	if topNodes <= 0 {
+		topNodes = defaultTopNodesLimit
+	}
+	q := url.Values{}
+	q.Set("topNodesLimit", strconv.Itoa(topNodes))
+	q.Set("start", start.UTC().Format(bandwidthQueryDateLayout))
+	q.Set("end", end.UTC().Format(bandwidthQueryDateLayout))
+	body, _, err := c.doGET(ctx, "/api/bandwidth-stats/users/"+id+"?"+q.Encode())
+	if err != nil {
+		return nil, err
+	}
+	return parseBandwidthHistory(body)
+}
+
+// parseBandwidthHistory: дни — из categories; значения — sparklineData, иначе сумма series[].data по индексу.
+// Ноды — topNodes, при их отсутствии — series[].total.
+func parseBandwidthHistory(body []byte) (*UserBandwidthHistory, error) {
+	var root map[string]any
+	if err := json.Unmarshal(body, &root); err != nil {
+		return nil, fmt.Errorf("remnawave bandwidth json: %w", err)
+	}
+	resp, ok := root["response"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("remnawave bandwidth: empty usage response")
+	}
+
+	cats, _ := resp["categories"].([]any)
+	values := make([]int64, len(cats))
+	if spark, ok := resp["sparklineData"].([]any); ok && len(spark) > 0 {
+		for i := 0; i < len(values) && i < len(spark); i++ {
+			values[i], _ = jsonNumberToInt64(spark[i])
+		}
+	} else if series, ok := resp["series"].([]any); ok {
+		for _, el := range series {
+			m, ok := el.(map[string]any)
+			if !ok {
+				continue
+			}
+			data, _ := m["data"].([]any)
+			for i := 0; i < len(values) && i < len(data); i++ {
+				n, _ := jsonNumberToInt64(data[i])
+				values[i] += n
+			}
+		}
+	}
+
+	out := &UserBandwidthHistory{Days: make([]BandwidthDay, 0, len(cats))}
+	for i, c := range cats {
+		date := strings.TrimSpace(fmt.Sprint(c))
+		if len(date) > len(bandwidthQueryDateLayout) {
+			date = date[:len(bandwidthQueryDateLayout)]
+		}
+		out.Days = append(out.Days, BandwidthDay{Date: date, Bytes: values[i]})
+	}
+
+	nodes, _ := resp["topNodes"].([]any)
+	if len(nodes) == 0 {
+		nodes, _ = resp["series"].([]any)
+	}
+	for _, el := range nodes {
+		m, ok := el.(map[string]any)
+		if !ok {
+			continue
+		}
+		total, ok := jsonNumberToInt64(m["total"])
+		if !ok {
+			continue
+		}
+		out.Nodes = append(out.Nodes, BandwidthNode{
+			Name:        strings.TrimSpace(stringField(m, "name")),
+			CountryCode: strings.TrimSpace(stringField(m, "countryCode")),
+			Bytes:       total,
+		})
+	}
+	sort.SliceStable(out.Nodes, func(i, j int) bool { return out.Nodes[i].Bytes > out.Nodes[j].Bytes })
+	return out, nil
+}
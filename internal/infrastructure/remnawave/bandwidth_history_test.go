@@ -0,0 +1,63 @@
+package remnawave
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseBandwidthHistorySparkline(t *testing.T) {
+	h, err := parseBandwidthHistory([]byte(`{"response":{
+		"categories":["2026-10-01","2026-10-02","2026-10-03"],
+		"sparklineData":[100,0,250.9],
+		"topNodes":[{"name":"NL-1","countryCode":"NL","total":50},{"name":"DE-1","countryCode":"DE","total":300}],
+		"series":[]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.Days) != 3 || h.Days[0].Date != "2026-10-01" || h.Days[0].Bytes != 100 || h.Days[2].Bytes != 250 {
+		t.Fatalf("days %+v", h.Days)
+	}
+	if len(h.Nodes) != 2 || h.Nodes[0].Name != "DE-1" || h.Nodes[0].CountryCode != "DE" {
+		t.Fatalf("nodes %+v", h.Nodes)
+	}
+}
+
+func TestParseBandwidthHistorySeriesFallback(t *testing.T) {
+	h, err := parseBandwidthHistory([]byte(`{"response":{
+		"categories":["2026-10-01T00:00:00.000Z","2026-10-02T00:00:00.000Z"],
+		"series":[{"name":"NL-1","total":30,"data":[10,20]},{"name":"FI-1","total":5,"data":[5]}]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Days[0].Date != "2026-10-01" || h.Days[0].Bytes != 15 || h.Days[1].Bytes != 20 {
+		t.Fatalf("days %+v", h.Days)
+	}
+	if len(h.Nodes) != 2 || h.Nodes[0].Name != "NL-1" || h.Nodes[0].Bytes != 30 {
+		t.Fatalf("nodes %+v", h.Nodes)
+	}
+	if _, err := parseBandwidthHistory([]byte(`{}`)); err == nil {
+		t.Fatal("expected error for missing response")
+	}
+}
+
+func TestGetUserBandwidthHistoryQuery(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/api/bandwidth-stats/users/u-1" || q.Get("start") != "2026-09-19" || q.Get("end") != "2026-10-18" || q.Get("topNodesLimit") != "5" {
+			t.Fatalf("%s?%s", r.URL.Path, r.URL.RawQuery)
+		}
+		_, _ = w.Write([]byte(`{"response":{"categories":["2026-10-18"],"sparklineData":[7],"topNodes":[]}}`))
+	}))
+	defer srv.Close()
+	end := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	h, err := NewClient(srv.URL, "tok").GetUserBandwidthHistory(context.Background(), User{UUID: "u-1"}, end.AddDate(0, 0, -29), end, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.Days) != 1 || h.Days[0].Bytes != 7 || len(h.Nodes) != 0 {
+		t.Fatalf("%+v", h)
+	}
+}
@@ -94,17 +94,22 @@ func (c *Client) doGET(ctx context.Context, path string) ([]byte, int, error) {
 // User — минимальные поля пользователя Remnawave.
 // ID — основной идентификатор (3.2.3). UUID опционален и нужен только
 // для bandwidth path на 2.7.4: там endpoint принимает UUID, не numeric id.
+// SubRevokedAt и LastTrafficResetAt пусты, если API их не вернул.
 type User struct {
-	ID       int64
-	UUID     string
-	Username string
+	ID                 int64
+	UUID               string
+	Username           string
+	SubRevokedAt       time.Time
+	LastTrafficResetAt time.Time
 }
 
 type getUserByUsernameResponse struct {
 	Response *struct {
-		ID       int64  `json:"id"`
-		UUID     string `json:"uuid"`
-		Username string `json:"username"`
+		ID                 int64   `json:"id"`
+		UUID               string  `json:"uuid"`
+		Username           string  `json:"username"`
+		SubRevokedAt       *string `json:"subRevokedAt"`
+		LastTrafficResetAt *string `json:"lastTrafficResetAt"`
 	} `json:"response"`
 }
 
@@ -140,9 +145,11 @@ func (c *Client) GetUserByUsername(ctx context.Context, username string) (*User,
 	}
 
 	return &User{
-		ID:       resp.ID,
-		UUID:     strings.TrimSpace(resp.UUID),
-		Username: gotName,
+		ID:                 resp.ID,
+		UUID:               strings.TrimSpace(resp.UUID),
+		Username:           gotName,
+		SubRevokedAt:       parseAPITime(derefString(resp.SubRevokedAt)),
+		LastTrafficResetAt: parseAPITime(derefString(resp.LastTrafficResetAt)),
 	}, nil
 }
 
@@ -0,0 +1,179 @@
+package remnawave
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HWIDDevice — устройство, привязанное к пользователю Remnawave (лимит HWID).
+type HWIDDevice struct {
+	HWID        string
+	Platform    string
+	OSVersion   string
+	DeviceModel string
+	UserAgent   string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// doJSON выполняет запрос с JSON-телом; не-2xx возвращается ошибкой, как в doGET.
+func (c *Client) doJSON(ctx context.Context, method, path string, payload any) ([]byte, int, error) {
+	var rd io.Reader
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return nil, 0, err
+		}
+		rd = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, rd)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp.StatusCode, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg := truncateBody(strings.TrimSpace(string(body)), 200)
+		return body, resp.StatusCode, fmt.Errorf("remnawave HTTP %d: %s", resp.StatusCode, msg)
+	}
+	return body, resp.StatusCode, nil
+}
+
+// hwidUserBody — идентификатор пользователя в теле HWID-запросов: userUuid (2.7.4), иначе userId (3.2.3).
+func hwidUserBody(user User) (map[string]any, error) {
+	if uuid := strings.TrimSpace(user.UUID); uuid != "" {
+		return map[string]any{"userUuid": uuid}, nil
+	}
+	if user.ID > 0 {
+		return map[string]any{"userId": user.ID}, nil
+	}
+	return nil, fmt.Errorf("remnawave: missing user identity")
+}
+
+// GetUserHWIDDevices выполняет GET /api/hwid/devices/{uuid|id}.
+func (c *Client) GetUserHWIDDevices(ctx context.Context, user User) ([]HWIDDevice, error) {
+	if c == nil {
+		return nil, fmt.Errorf("remnawave: nil client")
+	}
+	id, err := bandwidthUserPathID(user)
+	if err != nil {
+		return nil, err
+	}
+	body, _, err := c.doGET(ctx, "/api/hwid/devices/"+id)
+	if err != nil {
+		return nil, err
+	}
+	return parseHWIDDevices(body)
+}
+
+// DeleteUserHWIDDevice выполняет POST /api/hwid/devices/delete и возвращает оставшиеся устройства.
+func (c *Client) DeleteUserHWIDDevice(ctx context.Context, user User, hwid string) ([]HWIDDevice, error) {
+	if c == nil {
+		return nil, fmt.Errorf("remnawave: nil client")
+	}
+	hwid = strings.TrimSpace(hwid)
+	if hwid == "" {
+		return nil, fmt.Errorf("remnawave: empty hwid")
+	}
+	payload, err := hwidUserBody(user)
+	if err != nil {
+		return nil, err
+	}
+	payload["hwid"] = hwid
+	body, _, err := c.doJSON(ctx, http.MethodPost, "/api/hwid/devices/delete", payload)
+	if err != nil {
+		return nil, err
+	}
+	return parseHWIDDevices(body)
+}
+
+// DeleteAllUserHWIDDevices выполняет POST /api/hwid/devices/delete-all.
+func (c *Client) DeleteAllUserHWIDDevices(ctx context.Context, user User) error {
+	if c == nil {
+		return fmt.Errorf("remnawave: nil client")
+	}
+	payload, err := hwidUserBody(user)
+	if err != nil {
+		return err
+	}
+	_, _, err = c.doJSON(ctx, http.MethodPost, "/api/hwid/devices/delete-all", payload)
+	return err
+}
+
+type hwidDevicesResponse struct {
+	Response *struct {
+		Devices []struct {
+			HWID        string  `json:"hwid"`
+			Platform    *string `json:"platform"`
+			OSVersion   *string `json:"osVersion"`
+			DeviceModel *string `json:"deviceModel"`
+			UserAgent   *string `json:"userAgent"`
+			CreatedAt   string  `json:"createdAt"`
+			UpdatedAt   string  `json:"updatedAt"`
+		} `json:"devices"`
+	} `json:"response"`
+}
+
+// parseHWIDDevices разбирает response.devices; устройства без hwid пропускаются.
+func parseHWIDDevices(body []byte) ([]HWIDDevice, error) {
+	var root hwidDevicesResponse
+	if err := json.Unmarshal(body, &root); err != nil {
+		return nil, fmt.Errorf("remnawave hwid json: %w", err)
+	}
+	if root.Response == nil {
+		return nil, fmt.Errorf("remnawave hwid: missing response object")
+	}
+	out := make([]HWIDDevice, 0, len(root.Response.Devices))
+	for _, d := range root.Response.Devices {
+		hwid := strings.TrimSpace(d.HWID)
+		if hwid == "" {
+			continue
+		}
+		out = append(out, HWIDDevice{
+			HWID:        hwid,
+			Platform:    strings.TrimSpace(derefString(d.Platform)),
+			OSVersion:   strings.TrimSpace(derefString(d.OSVersion)),
+			DeviceModel: strings.TrimSpace(derefString(d.DeviceModel)),
+			UserAgent:   strings.TrimSpace(derefString(d.UserAgent)),
+			CreatedAt:   parseAPITime(d.CreatedAt),
+			UpdatedAt:   parseAPITime(d.UpdatedAt),
+		})
+	}
+	return out, nil
+}
+
+func derefString(p *string) string {
+	if p == nil {
+		return ""
+	}
+	return *p
+}
+
+// parseAPITime — ISO 8601 из API; нераспознанное значение даёт нулевое время.
+func parseAPITime(s string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(s))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
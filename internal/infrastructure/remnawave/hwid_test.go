@@ -0,0 +1,119 @@
+package remnawave
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const hwidDevicesJSON = `{"response":{"total":2,"devices":[
+{"hwid":"hw-1","userUuid":"u-1","platform":"iOS","osVersion":"17.4","deviceModel":"iPhone15,2","userAgent":"Happ/3.1","createdAt":"2026-09-01T10:00:00.000Z","updatedAt":"2026-09-02T10:00:00.000Z"},
+{"hwid":"hw-2","userUuid":"u-1","platform":null,"osVersion":null,"deviceModel":null,"userAgent":null,"createdAt":"bad","updatedAt":""},
+{"hwid":"","userUuid":"u-1"}
+]}}`
+
+func TestGetUserHWIDDevices(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/api/hwid/devices/u-1" {
+			t.Fatalf("%s %s", r.Method, r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			t.Fatalf("auth %q", r.Header.Get("Authorization"))
+		}
+		_, _ = w.Write([]byte(hwidDevicesJSON))
+	}))
+	defer srv.Close()
+	c := NewClient(srv.URL, "tok")
+	devs, err := c.GetUserHWIDDevices(context.Background(), User{ID: 42, UUID: "u-1", Username: "us_42"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devs) != 2 {
+		t.Fatalf("devices=%+v", devs)
+	}
+	if devs[0].HWID != "hw-1" || devs[0].Platform != "iOS" || devs[0].DeviceModel != "iPhone15,2" || devs[0].CreatedAt.IsZero() {
+		t.Fatalf("dev0=%+v", devs[0])
+	}
+	if devs[1].Platform != "" || !devs[1].CreatedAt.IsZero() {
+		t.Fatalf("dev1=%+v", devs[1])
+	}
+}
+
+func TestGetUserHWIDDevicesNumericID(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/hwid/devices/42" {
+			t.Fatalf("path %q", r.URL.Path)
+		}
+		_, _ = w.Write([]byte(`{"response":{"total":0,"devices":[]}}`))
+	}))
+	defer srv.Close()
+	devs, err := NewClient(srv.URL, "tok").GetUserHWIDDevices(context.Background(), User{ID: 42})
+	if err != nil || len(devs) != 0 {
+		t.Fatalf("devs=%v err=%v", devs, err)
+	}
+}
+
+func TestDeleteUserHWIDDevice(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/hwid/devices/delete" {
+			t.Fatalf("%s %s", r.Method, r.URL.Path)
+		}
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if body["userUuid"] != "u-1" || body["hwid"] != "hw-2" {
+			t.Fatalf("body=%v", body)
+		}
+		_, _ = w.Write([]byte(`{"response":{"total":1,"devices":[{"hwid":"hw-1"}]}}`))
+	}))
+	defer srv.Close()
+	devs, err := NewClient(srv.URL, "tok").DeleteUserHWIDDevice(context.Background(), User{ID: 42, UUID: "u-1"}, " hw-2 ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devs) != 1 || devs[0].HWID != "hw-1" {
+		t.Fatalf("devs=%+v", devs)
+	}
+	if _, err := NewClient(srv.URL, "tok").DeleteUserHWIDDevice(context.Background(), User{ID: 42}, ""); err == nil {
+		t.Fatal("expected error for empty hwid")
+	}
+}
+
+func TestDeleteAllUserHWIDDevices(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/hwid/devices/delete-all" {
+			t.Fatalf("%s %s", r.Method, r.URL.Path)
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		_, _ = w.Write([]byte(`{"response":{"total":0,"devices":[]}}`))
+	}))
+	defer srv.Close()
+	if err := NewClient(srv.URL, "tok").DeleteAllUserHWIDDevices(context.Background(), User{ID: 42}); err != nil {
+		t.Fatal(err)
+	}
+	if got["userId"] != float64(42) {
+		t.Fatalf("body=%v", got)
+	}
+}
+
+func TestHWIDDevicesHTTPError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"not found"}`, http.StatusNotFound)
+	}))
+	defer srv.Close()
+	c := NewClient(srv.URL, "tok")
+	if _, err := c.GetUserHWIDDevices(context.Background(), User{UUID: "u-1"}); err == nil {
+		t.Fatal("expected error")
+	}
+	if err := c.DeleteAllUserHWIDDevices(context.Background(), User{UUID: "u-1"}); err == nil {
+		t.Fatal("expected error")
+	}
+	var nilClient *Client
+	if _, err := nilClient.GetUserHWIDDevices(context.Background(), User{ID: 1}); err == nil {
+		t.Fatal("expected nil client error")
+	}
+}
@@ -0,0 +1,93 @@
+package remnawave
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Node — нода Remnawave: состояние подключения, страна, онлайн и трафик.
+type Node struct {
+	UUID              string
+	Name              string
+	CountryCode       string
+	IsConnected       bool
+	IsDisabled        bool
+	IsXrayRunning     bool
+	UsersOnline       int
+	TrafficUsedBytes  int64
+	TrafficLimitBytes int64
+	LastStatusMessage string
+}
+
+// Online — нода подключена к панели и не выключена вручную.
+func (n Node) Online() bool {
+	return n.IsConnected && !n.IsDisabled
+}
+
+type nodesResponse struct {
+	Response []struct {
+		UUID              string  `json:"uuid"`
+		Name              string  `json:"name"`
+		CountryCode       string  `json:"countryCode"`
+		IsConnected       bool    `json:"isConnected"`
+		IsDisabled        bool    `json:"isDisabled"`
+		IsXrayRunning     *bool   `json:"isXrayRunning"`
+		UsersOnline       *int    `json:"usersOnline"`
+		TrafficUsedBytes  *int64  `json:"trafficUsedBytes"`
+		TrafficLimitBytes *int64  `json:"trafficLimitBytes"`
+		LastStatusMessage *string `json:"lastStatusMessage"`
+	} `json:"response"`
+}
+
+// GetNodes выполняет GET /api/nodes.
+func (c *Client) GetNodes(ctx context.Context) ([]Node, error) {
+	if c == nil {
+		return nil, fmt.Errorf("remnawave: nil client")
+	}
+	body, _, err := c.doGET(ctx, "/api/nodes")
+	if err != nil {
+		return nil, err
+	}
+	return parseNodes(body)
+}
+
+// parseNodes разбирает response[]; ноды без имени пропускаются. isXrayRunning отсутствует
+// в старых версиях API — тогда считается, что xray работает у подключённой ноды.
+func parseNodes(body []byte) ([]Node, error) {
+	var root nodesResponse
+	if err := json.Unmarshal(body, &root); err != nil {
+		return nil, fmt.Errorf("remnawave nodes json: %w", err)
+	}
+	out := make([]Node, 0, len(root.Response))
+	for _, n := range root.Response {
+		name := strings.TrimSpace(n.Name)
+		if name == "" {
+			continue
+		}
+		node := Node{
+			UUID:              strings.TrimSpace(n.UUID),
+			Name:              name,
+			CountryCode:       strings.ToUpper(strings.TrimSpace(n.CountryCode)),
+			IsConnected:       n.IsConnected,
+			IsDisabled:        n.IsDisabled,
+			IsXrayRunning:     n.IsConnected,
+			LastStatusMessage: strings.TrimSpace(derefString(n.LastStatusMessage)),
+		}
+		if n.IsXrayRunning != nil {
+			node.IsXrayRunning = *n.IsXrayRunning
+		}
+		if n.UsersOnline != nil {
+			node.UsersOnline = *n.UsersOnline
+		}
+		if n.TrafficUsedBytes != nil {
+			node.TrafficUsedBytes = *n.TrafficUsedBytes
+		}
+		if n.TrafficLimitBytes != nil {
+			node.TrafficLimitBytes = *n.TrafficLimitBytes
+		}
+		out = append(out, node)
+	}
+	return out, nil
+}
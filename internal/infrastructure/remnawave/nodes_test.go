@@ -0,0 +1,35 @@
+package remnawave
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetNodes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/api/nodes" {
+			t.Fatalf("%s %s", r.Method, r.URL.Path)
+		}
+		_, _ = w.Write([]byte(`{"response":[
+{"uuid":"n-1","name":"NL-1","countryCode":"nl","isConnected":true,"isDisabled":false,"isXrayRunning":true,"usersOnline":12,"trafficUsedBytes":1073741824,"trafficLimitBytes":null,"lastStatusMessage":null},
+{"uuid":"n-2","name":"DE-1","countryCode":"DE","isConnected":false,"isDisabled":false,"usersOnline":null,"lastStatusMessage":"connection refused"},
+{"uuid":"n-3","name":" ","isConnected":true}
+]}`))
+	}))
+	defer srv.Close()
+	nodes, err := NewClient(srv.URL, "tok").GetNodes(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 2 {
+		t.Fatalf("nodes=%+v", nodes)
+	}
+	if n := nodes[0]; n.CountryCode != "NL" || !n.Online() || n.UsersOnline != 12 || n.TrafficUsedBytes != 1<<30 || n.TrafficLimitBytes != 0 {
+		t.Fatalf("node0=%+v", n)
+	}
+	if n := nodes[1]; n.Online() || n.IsXrayRunning || n.LastStatusMessage != "connection refused" {
+		t.Fatalf("node1=%+v", n)
+	}
+}
@@ -31,7 +31,10 @@ type UserService struct {
 	BaseServiceID int    `json:"service_id"`
 	Category      string `json:"category"`
 	ConfigRaw     string `json:"config"`
-	KeyMarzban    UserKeyMarzban
+	// Next — user_service.next: услуга, на которую SHM переключит эту по окончании периода; -1 — не продлевать.
+	Next        int             `json:"next,omitempty"`
+	SettingsRaw json.RawMessage `json:"settings,omitempty"`
+	KeyMarzban  UserKeyMarzban
 }
 
 type UserRegistrationRequest struct {
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// UserServiceAutoRenewKey — user_service.settings.auto_renew: выбор пользователя в боте или кабинете.
+const UserServiceAutoRenewKey = "auto_renew"
+
+// UserServiceNextNoRenew — user_service.next = -1: SHM не продлевает услугу по окончании периода.
+const UserServiceNextNoRenew = -1
+
+// AutoRenew — продлит ли SHM услугу по окончании периода. Без явного выбора — да (так SHM ведёт себя по умолчанию).
+func (us *UserService) AutoRenew() bool {
+	if us == nil || us.Next == UserServiceNextNoRenew {
+		return false
+	}
+	var settings struct {
+		AutoRenew *bool `json:"auto_renew"`
+	}
+	if len(us.SettingsRaw) == 0 || json.Unmarshal(us.SettingsRaw, &settings) != nil || settings.AutoRenew == nil {
+		return true
+	}
+	return *settings.AutoRenew
+}
+
+// CostValue — user_service.cost (в SHM строка) числом; 0, если стоимость не указана или не разбирается.
+func (us *UserService) CostValue() float64 {
+	if us == nil {
+		return 0
+	}
+	s := strings.TrimSpace(strings.ReplaceAll(us.Cost, ",", "."))
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f <= 0 || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0
+	}
+	return math.Round(f*100) / 100
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestUserServiceAutoRenew(t *testing.T) {
+	for _, tc := range []struct {
+		raw  string
+		want bool
+	}{
+		{`{"user_service_id":1}`, true},
+		{`{"user_service_id":1,"settings":{"auto_renew":false}}`, false},
+		{`{"user_service_id":1,"settings":{"auto_renew":true}}`, true},
+		{`{"user_service_id":1,"next":-1,"settings":{"auto_renew":true}}`, false},
+		{`{"user_service_id":1,"next":null,"settings":null}`, true},
+	} {
+		var us UserService
+		if err := json.Unmarshal([]byte(tc.raw), &us); err != nil {
+			t.Fatalf("%s: %v", tc.raw, err)
+		}
+		if got := us.AutoRenew(); got != tc.want {
+			t.Fatalf("%s: got %v", tc.raw, got)
+		}
+	}
+}
+
+func TestUserServiceCostValue(t *testing.T) {
+	for cost, want := range map[string]float64{"450": 450, " 99,90 ": 99.9, "": 0, "-5": 0, "abc": 0} {
+		if got := (&UserService{Cost: cost}).CostValue(); got != want {
+			t.Fatalf("%q: got %v want %v", cost, got, want)
+		}
+	}
+}
@@ -10,11 +10,13 @@ import (
 var ErrEmptyUserServiceTopConfig = errors.New("empty user service config")
 
 // UserServiceTopConfigRemnawave — фрагмент user_service.config.remnawave.
+// TrafficResetAllowed — тариф разрешает пользователю самостоятельный сброс трафика.
 type UserServiceTopConfigRemnawave struct {
 	InternalSquadName    string `json:"internal_squad_name"`
 	TrafficLimitBytes    int64  `json:"traffic_limit_bytes"`
 	TrafficLimitStrategy string `json:"traffic_limit_strategy"`
 	HWIDDeviceLimit      int    `json:"hwid_device_limit"`
+	TrafficResetAllowed  bool   `json:"traffic_reset_allowed"`
 }
 
 // UserServiceTopConfig — верхний user_service.config (сырой JSON из API).
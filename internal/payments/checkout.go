@@ -0,0 +1,242 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// CheckoutSignatureHeader — подпись webhook: "t=<unix>,v1=<hex hmac-sha256(t.payload)>".
+	CheckoutSignatureHeader = "Stripe-Signature"
+	// CheckoutEventCompleted — сессия оплачена (payment_status=paid).
+	CheckoutEventCompleted = "checkout.session.completed"
+
+	defaultCheckoutAPIBaseURL = "https://api.stripe.com"
+	checkoutWebhookTolerance  = 5 * time.Minute
+)
+
+// Ключи metadata сессии: по ним webhook находит пользователя, услугу и бренд.
+const (
+	CheckoutMetaBrandID    = "brand_id"
+	CheckoutMetaUserID     = "user_id"
+	CheckoutMetaServiceID  = "service_id"
+	CheckoutMetaPublicCode = "public_code"
+)
+
+var (
+	// ErrCheckoutSignature — подпись webhook отсутствует, не совпадает или устарела.
+	ErrCheckoutSignature = errors.New("checkout webhook signature is invalid")
+	// ErrCheckoutNotConfigured — не задан secret_key провайдера.
+	ErrCheckoutNotConfigured = errors.New("card checkout is not configured")
+)
+
+// CheckoutParams — одна позиция каталога в валюте международной цены.
+type CheckoutParams struct {
+	PublicCode    string
+	ProductName   string
+	Currency      string
+	AmountCents   int64
+	CustomerEmail string
+	SuccessURL    string
+	CancelURL     string
+	Metadata      map[string]string
+	// IdempotencyKey — повтор запроса с тем же ключом не создаёт вторую сессию.
+	IdempotencyKey string
+}
+
+// CheckoutSession — сессия оплаты (ответ API и data.object в webhook).
+type CheckoutSession struct {
+	ID                string            `json:"id"`
+	URL               string            `json:"url"`
+	Status            string            `json:"status"`
+	PaymentStatus     string            `json:"payment_status"`
+	Currency          string            `json:"currency"`
+	AmountTotal       int64             `json:"amount_total"`
+	ClientReferenceID string            `json:"client_reference_id"`
+	Metadata          map[string]string `json:"metadata"`
+}
+
+// Paid — деньги получены; только такую сессию можно зачислять.
+func (s CheckoutSession) Paid() bool {
+	return s.PaymentStatus == "paid"
+}
+
+// CheckoutEvent — конверт webhook.
+type CheckoutEvent struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Created int64  `json:"created"`
+	Data    struct {
+		Object CheckoutSession `json:"object"`
+	} `json:"data"`
+}
+
+// CheckoutClient — создание сессии оплаты картой (в тестах — локальный stub-сервер).
+type CheckoutClient interface {
+	CreateCheckoutSession(ctx context.Context, p CheckoutParams) (*CheckoutSession, error)
+}
+
+// HTTPCheckoutClient — Checkout Session API в стиле Stripe: POST /v1/checkout/sessions (form-encoded, Bearer).
+type HTTPCheckoutClient struct {
+	BaseURL    string
+	SecretKey  string
+	HTTPClient *http.Client
+}
+
+// NewHTTPCheckoutClient — клиент с таймаутом 15 с; пустой baseURL → api.stripe.com.
+func NewHTTPCheckoutClient(baseURL, secretKey string) *HTTPCheckoutClient {
+	base := strings.TrimRight(strings.TrimSpace(baseURL), "/")
+	if base == "" {
+		base = defaultCheckoutAPIBaseURL
+	}
+	return &HTTPCheckoutClient{
+		BaseURL:    base,
+		SecretKey:  strings.TrimSpace(secretKey),
+		HTTPClient: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// CreateCheckoutSession создаёт сессию mode=payment с одной позицией.
+func (c *HTTPCheckoutClient) CreateCheckoutSession(ctx context.Context, p CheckoutParams) (*CheckoutSession, error) {
+	if c == nil || c.SecretKey == "" {
+		return nil, ErrCheckoutNotConfigured
+	}
+	if p.AmountCents <= 0 {
+		return nil, errors.New("checkout amount must be positive")
+	}
+	currency := strings.ToLower(strings.TrimSpace(p.Currency))
+	if currency == "" {
+		return nil, errors.New("checkout currency is empty")
+	}
+	if strings.TrimSpace(p.SuccessURL) == "" || strings.TrimSpace(p.CancelURL) == "" {
+		return nil, errors.New("checkout return urls are empty")
+	}
+	name := strings.TrimSpace(p.ProductName)
+	if name == "" {
+		name = p.PublicCode
+	}
+
+	form := url.Values{}
+	form.Set("mode", "payment")
+	form.Set("success_url", p.SuccessURL)
+	form.Set("cancel_url", p.CancelURL)
+	form.Set("line_items[0][quantity]", "1")
+	form.Set("line_items[0][price_data][currency]", currency)
+	form.Set("line_items[0][price_data][unit_amount]", strconv.FormatInt(p.AmountCents, 10))
+	form.Set("line_items[0][price_data][product_data][name]", name)
+	if e := strings.TrimSpace(p.CustomerEmail); e != "" {
+		form.Set("customer_email", e)
+	}
+	if uid := p.Metadata[CheckoutMetaUserID]; uid != "" {
+		form.Set("client_reference_id", uid)
+	}
+	for k, v := range p.Metadata {
+		form.Set("metadata["+k+"]", v)
+		// metadata платежа — чтобы привязка была видна и в карточке платежа у провайдера.
+		form.Set("payment_intent_data[metadata]["+k+"]", v)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/v1/checkout/sessions", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.SecretKey)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if k := strings.TrimSpace(p.IdempotencyKey); k != "" {
+		req.Header.Set("Idempotency-Key", k)
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("checkout session: API status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var out CheckoutSession
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, fmt.Errorf("decode checkout session: %w", err)
+	}
+	if out.ID == "" || out.URL == "" {
+		return nil, errors.New("checkout session: empty id or url")
+	}
+	return &out, nil
+}
+
+// SignCheckoutPayload — значение заголовка подписи для payload в момент ts (stub-сервер и тесты).
+func SignCheckoutPayload(secret string, payload []byte, ts time.Time) string {
+	t := strconv.FormatInt(ts.Unix(), 10)
+	return "t=" + t + ",v1=" + checkoutHMAC(secret, t, payload)
+}
+
+func checkoutHMAC(secret, t string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyCheckoutWebhook проверяет подпись (любая из v1) и окно ±5 минут вокруг now, затем разбирает событие.
+func VerifyCheckoutWebhook(payload []byte, header, secret string, now time.Time) (*CheckoutEvent, error) {
+	secret = strings.TrimSpace(secret)
+	if secret == "" {
+		return nil, ErrCheckoutNotConfigured
+	}
+	var t string
+	var sigs []string
+	for _, part := range strings.Split(header, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch k {
+		case "t":
+			t = v
+		case "v1":
+			sigs = append(sigs, v)
+		}
+	}
+	sec, err := strconv.ParseInt(t, 10, 64)
+	if err != nil || len(sigs) == 0 {
+		return nil, ErrCheckoutSignature
+	}
+	want := checkoutHMAC(secret, t, payload)
+	matched := false
+	for _, s := range sigs {
+		if hmac.Equal([]byte(strings.ToLower(s)), []byte(want)) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return nil, ErrCheckoutSignature
+	}
+	if d := now.Sub(time.Unix(sec, 0)); d > checkoutWebhookTolerance || d < -checkoutWebhookTolerance {
+		return nil, ErrCheckoutSignature
+	}
+	var ev CheckoutEvent
+	if err := json.Unmarshal(payload, &ev); err != nil {
+		return nil, fmt.Errorf("decode checkout event: %w", err)
+	}
+	return &ev, nil
+}
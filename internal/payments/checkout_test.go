@@ -0,0 +1,100 @@
+package payments_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/payments"
+	"github.com/ryabkov82/vpnbot/internal/payments/checkouttest"
+)
+
+func TestHTTPCheckoutClient_CreateSession(t *testing.T) {
+	stub := checkouttest.NewServer("sk_test")
+	defer stub.Close()
+	c := payments.NewHTTPCheckoutClient(stub.URL, "sk_test")
+
+	params := payments.CheckoutParams{
+		PublicCode:  "premium-1m",
+		ProductName: "Premium 1 month",
+		Currency:    "USD",
+		AmountCents: 499,
+		SuccessURL:  "https://example.com/payment/return?status=success",
+		CancelURL:   "https://example.com/account",
+		Metadata: map[string]string{
+			payments.CheckoutMetaBrandID:    "vff",
+			payments.CheckoutMetaUserID:     "42",
+			payments.CheckoutMetaServiceID:  "7",
+			payments.CheckoutMetaPublicCode: "premium-1m",
+		},
+		IdempotencyKey: "k1",
+	}
+	sess, err := c.CreateCheckoutSession(context.Background(), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sess.ID == "" || sess.URL == "" || sess.AmountTotal != 499 || sess.Currency != "usd" {
+		t.Fatalf("%+v", sess)
+	}
+	again, err := c.CreateCheckoutSession(context.Background(), params)
+	if err != nil || again.ID != sess.ID {
+		t.Fatalf("idempotent retry: %+v %v", again, err)
+	}
+	got := stub.Sessions()
+	if len(got) != 1 || got[0].Metadata[payments.CheckoutMetaBrandID] != "vff" || got[0].ClientReferenceID != "42" || got[0].ProductName != "Premium 1 month" {
+		t.Fatalf("sessions %+v", got)
+	}
+
+	if _, err := payments.NewHTTPCheckoutClient(stub.URL, "sk_wrong").CreateCheckoutSession(context.Background(), params); err == nil {
+		t.Fatal("wrong key must fail")
+	}
+	if _, err := payments.NewHTTPCheckoutClient(stub.URL, "").CreateCheckoutSession(context.Background(), params); !errors.Is(err, payments.ErrCheckoutNotConfigured) {
+		t.Fatalf("empty key: %v", err)
+	}
+}
+
+func TestVerifyCheckoutWebhook(t *testing.T) {
+	stub := checkouttest.NewServer("sk_test")
+	defer stub.Close()
+	sess, err := payments.NewHTTPCheckoutClient(stub.URL, "sk_test").CreateCheckoutSession(context.Background(), payments.CheckoutParams{
+		Currency: "usd", AmountCents: 100, SuccessURL: "https://x/s", CancelURL: "https://x/c",
+		Metadata: map[string]string{payments.CheckoutMetaUserID: "1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1_800_000_000, 0)
+	payload, sig, err := stub.CompletedEvent(sess.ID, "whsec", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ev, err := payments.VerifyCheckoutWebhook(payload, sig, "whsec", now.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.Type != payments.CheckoutEventCompleted || ev.Data.Object.ID != sess.ID || !ev.Data.Object.Paid() {
+		t.Fatalf("%+v", ev)
+	}
+
+	tampered := append([]byte(nil), payload...)
+	tampered[len(tampered)-2] = ' '
+
+	cases := map[string]struct {
+		payload []byte
+		header  string
+		secret  string
+		now     time.Time
+	}{
+		"wrong secret": {payload, sig, "other", now},
+		"tampered":     {tampered, sig, "whsec", now},
+		"stale":        {payload, sig, "whsec", now.Add(10 * time.Minute)},
+		"no header":    {payload, "", "whsec", now},
+	}
+	for name, c := range cases {
+		if _, err := payments.VerifyCheckoutWebhook(c.payload, c.header, c.secret, c.now); !errors.Is(err, payments.ErrCheckoutSignature) {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+}
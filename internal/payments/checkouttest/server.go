@@ -0,0 +1,141 @@
+// Package checkouttest — локальный stub Checkout Session API для тестов (аналог httptest для провайдера карт).
+package checkouttest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/payments"
+)
+
+// Session — созданная через stub сессия и параметры запроса.
+type Session struct {
+	payments.CheckoutSession
+	ProductName    string
+	CustomerEmail  string
+	SuccessURL     string
+	CancelURL      string
+	IdempotencyKey string
+}
+
+// Server — POST /v1/checkout/sessions с проверкой Bearer-ключа; сессии хранятся в памяти.
+type Server struct {
+	*httptest.Server
+	SecretKey string
+
+	mu       sync.Mutex
+	sessions []Session
+	byKey    map[string]int
+}
+
+// NewServer запускает stub; закрывается через Close (обычно t.Cleanup).
+func NewServer(secretKey string) *Server {
+	s := &Server{SecretKey: secretKey, byKey: map[string]int{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/v1/checkout/sessions" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer "+s.SecretKey {
+		http.Error(w, `{"error":{"message":"invalid api key"}}`, http.StatusUnauthorized)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, `{"error":{"message":"bad form"}}`, http.StatusBadRequest)
+		return
+	}
+	amount, err := strconv.ParseInt(r.PostForm.Get("line_items[0][price_data][unit_amount]"), 10, 64)
+	if err != nil || amount <= 0 || r.PostForm.Get("mode") != "payment" {
+		http.Error(w, `{"error":{"message":"invalid line item"}}`, http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := r.Header.Get("Idempotency-Key")
+	if i, ok := s.byKey[key]; ok && key != "" {
+		writeJSON(w, s.sessions[i].CheckoutSession)
+		return
+	}
+	meta := map[string]string{}
+	for k, v := range r.PostForm {
+		if name, ok := strings.CutPrefix(k, "metadata["); ok && len(v) > 0 {
+			meta[strings.TrimSuffix(name, "]")] = v[0]
+		}
+	}
+	id := fmt.Sprintf("cs_test_%d", len(s.sessions)+1)
+	sess := Session{
+		CheckoutSession: payments.CheckoutSession{
+			ID:                id,
+			URL:               s.URL + "/pay/" + id,
+			Status:            "open",
+			PaymentStatus:     "unpaid",
+			Currency:          r.PostForm.Get("line_items[0][price_data][currency]"),
+			AmountTotal:       amount,
+			ClientReferenceID: r.PostForm.Get("client_reference_id"),
+			Metadata:          meta,
+		},
+		ProductName:    r.PostForm.Get("line_items[0][price_data][product_data][name]"),
+		CustomerEmail:  r.PostForm.Get("customer_email"),
+		SuccessURL:     r.PostForm.Get("success_url"),
+		CancelURL:      r.PostForm.Get("cancel_url"),
+		IdempotencyKey: key,
+	}
+	s.sessions = append(s.sessions, sess)
+	if key != "" {
+		s.byKey[key] = len(s.sessions) - 1
+	}
+	writeJSON(w, sess.CheckoutSession)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// Sessions — копия созданных сессий в порядке создания.
+func (s *Server) Sessions() []Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Session(nil), s.sessions...)
+}
+
+// CompletedEvent — тело checkout.session.completed для сессии id (оплачена) и подпись для webhookSecret.
+func (s *Server) CompletedEvent(id, webhookSecret string, at time.Time) (payload []byte, signature string, err error) {
+	s.mu.Lock()
+	var sess *payments.CheckoutSession
+	for i := range s.sessions {
+		if s.sessions[i].ID == id {
+			cp := s.sessions[i].CheckoutSession
+			sess = &cp
+		}
+	}
+	s.mu.Unlock()
+	if sess == nil {
+		return nil, "", fmt.Errorf("checkouttest: unknown session %q", id)
+	}
+	sess.Status = "complete"
+	sess.PaymentStatus = "paid"
+	payload, err = EventPayload("evt_"+id, payments.CheckoutEventCompleted, *sess, at)
+	if err != nil {
+		return nil, "", err
+	}
+	return payload, payments.SignCheckoutPayload(webhookSecret, payload, at), nil
+}
+
+// EventPayload — JSON события webhook с сессией в data.object.
+func EventPayload(eventID, eventType string, sess payments.CheckoutSession, at time.Time) ([]byte, error) {
+	ev := payments.CheckoutEvent{ID: eventID, Type: eventType, Created: at.Unix()}
+	ev.Data.Object = sess
+	return json.Marshal(ev)
+}
@@ -0,0 +1,299 @@
+package payments
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"strings"
+
+	"github.com/ryabkov82/vpnbot/internal/config"
+)
+
+// Встроенные провайдеры (ключи SHM pay_systems).
+const (
+	ProviderYooKassa    = "yookassa"
+	ProviderCryptoCloud = "cryptocloud"
+)
+
+const (
+	defaultMinAmount = 50
+	defaultMaxAmount = 10000
+	defaultLocale    = "ru"
+)
+
+// ErrUnknownProvider — провайдер не настроен или недоступен активному бренду.
+var ErrUnknownProvider = errors.New("payment provider is not available")
+
+// PaymentRequest — параметры ссылки на создание платежа в SHM.
+type PaymentRequest struct {
+	BaseURL string
+	UserID  int
+	Amount  float64
+	TS      int64
+	BrandID string
+}
+
+// Provider — способ пополнения баланса.
+type Provider interface {
+	ID() string
+	// DisplayName и Description — подписи для кнопок и карточек; неизвестная локаль → ru.
+	DisplayName(locale string) string
+	Description(locale string) string
+	Currencies() []string
+	AmountLimits() (min, max float64)
+	SupportsLocale(locale string) bool
+	SupportsBrand(brandID string) bool
+	// PaymentURL — ссылка, открыв которую пользователь попадает на оплату.
+	PaymentURL(req PaymentRequest) (string, error)
+}
+
+// AmountAllowed — сумма в пределах провайдера и не точнее копеек.
+func AmountAllowed(p Provider, amount float64) bool {
+	if math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return false
+	}
+	min, max := p.AmountLimits()
+	if amount < min || amount > max {
+		return false
+	}
+	norm := math.Round(amount*100) / 100
+	return math.Abs(amount-norm) < 1e-9
+}
+
+// FitAmount — сумма доплаты для провайдера: меньше минимума поднимается до минимума (остаток останется на балансе),
+// больше максимума — false.
+func FitAmount(p Provider, amount float64) (float64, bool) {
+	min, _ := p.AmountLimits()
+	if amount < min {
+		amount = min
+	}
+	amount = math.Round(amount*100) / 100
+	return amount, AmountAllowed(p, amount)
+}
+
+// shmProvider — платёжная система SHM: GET <base><path>?action=create&ps=…
+type shmProvider struct {
+	id           string
+	path         string
+	paySystem    string
+	names        map[string]string
+	descriptions map[string]string
+	currencies   []string
+	min, max     float64
+	locales      []string
+	brands       []string
+	sendBrandID  bool
+}
+
+func (p *shmProvider) ID() string                       { return p.id }
+func (p *shmProvider) Currencies() []string             { return append([]string(nil), p.currencies...) }
+func (p *shmProvider) AmountLimits() (float64, float64) { return p.min, p.max }
+
+func (p *shmProvider) DisplayName(locale string) string {
+	if n := localized(p.names, locale); n != "" {
+		return n
+	}
+	return p.id
+}
+
+func (p *shmProvider) Description(locale string) string {
+	return localized(p.descriptions, locale)
+}
+
+func (p *shmProvider) SupportsLocale(locale string) bool {
+	return len(p.locales) == 0 || containsFold(p.locales, locale)
+}
+
+func (p *shmProvider) SupportsBrand(brandID string) bool {
+	return len(p.brands) == 0 || containsFold(p.brands, strings.TrimSpace(brandID))
+}
+
+// PaymentURL — fail-closed: пустой ps или невалидный brand_id (для SendBrandID) дают ошибку, а не ссылку.
+func (p *shmProvider) PaymentURL(req PaymentRequest) (string, error) {
+	if !p.SupportsBrand(req.BrandID) {
+		return "", fmt.Errorf("%w: %s for brand %q", ErrUnknownProvider, p.id, req.BrandID)
+	}
+	if p.sendBrandID && !config.IsValidBrandID(req.BrandID) {
+		return "", fmt.Errorf("payment provider %s: brand id %q is invalid", p.id, req.BrandID)
+	}
+	raw, err := buildSHMPaymentURL(req.BaseURL, p.path, p.paySystem, req.UserID, req.Amount, req.TS)
+	if err != nil || !p.sendBrandID {
+		return raw, err
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("brand_id", strings.TrimSpace(req.BrandID))
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+func localized(m map[string]string, locale string) string {
+	if v := strings.TrimSpace(m[strings.ToLower(strings.TrimSpace(locale))]); v != "" {
+		return v
+	}
+	return strings.TrimSpace(m[defaultLocale])
+}
+
+func containsFold(list []string, v string) bool {
+	for _, s := range list {
+		if strings.EqualFold(strings.TrimSpace(s), v) {
+			return true
+		}
+	}
+	return false
+}
+
+// builtinProviders — описания, которые раньше были зашиты в web и бот.
+func builtinProviders(yooKassaPaySystem string) map[string]config.PaymentProvider {
+	return map[string]config.PaymentProvider{
+		ProviderYooKassa: {
+			ID:           ProviderYooKassa,
+			PaySystem:    yooKassaPaySystem,
+			Path:         shmYooKassaPath,
+			Names:        map[string]string{"ru": "Банковская карта", "en": "Bank card"},
+			Descriptions: map[string]string{"ru": "Оплата картой через текущий платежный шлюз", "en": "Card payment via the current payment gateway"},
+			Currencies:   []string{"RUB"},
+			Locales:      []string{"ru"},
+			SendBrandID:  true,
+		},
+		ProviderCryptoCloud: {
+			ID:           ProviderCryptoCloud,
+			PaySystem:    "cryptocloud",
+			Path:         shmCryptoCloudPath,
+			Names:        map[string]string{"ru": "Криптовалюта", "en": "Cryptocurrency"},
+			Descriptions: map[string]string{"ru": "Оплата через Trybit: USDT, TON и другие доступные валюты", "en": "Payment via Trybit: USDT, TON and other available currencies"},
+			Currencies:   []string{"USDT", "TON"},
+		},
+	}
+}
+
+// newSHMProvider дополняет конфиг провайдера встроенным описанием с тем же id и значениями по умолчанию.
+func newSHMProvider(c config.PaymentProvider, builtin map[string]config.PaymentProvider) (*shmProvider, error) {
+	id := strings.TrimSpace(c.ID)
+	if id == "" {
+		return nil, errors.New("payment provider id is empty")
+	}
+	base, isBuiltin := builtin[id]
+	pick := func(v, def string) string {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+		return strings.TrimSpace(def)
+	}
+	p := &shmProvider{
+		id:           id,
+		path:         pick(c.Path, pick(base.Path, "/shm/pay_systems/"+id+".cgi")),
+		paySystem:    pick(c.PaySystem, base.PaySystem),
+		names:        c.Names,
+		descriptions: c.Descriptions,
+		currencies:   c.Currencies,
+		min:          c.MinAmount,
+		max:          c.MaxAmount,
+		locales:      c.Locales,
+		brands:       c.Brands,
+		sendBrandID:  c.SendBrandID || base.SendBrandID,
+	}
+	// У встроенного провайдера ps берётся из бренда (yookassa_pay_system) — пустой не подменяем id.
+	if p.paySystem == "" && !isBuiltin {
+		p.paySystem = id
+	}
+	if len(p.names) == 0 {
+		p.names = base.Names
+	}
+	if len(p.descriptions) == 0 {
+		p.descriptions = base.Descriptions
+	}
+	if len(p.currencies) == 0 {
+		p.currencies = base.Currencies
+	}
+	if len(p.currencies) == 0 {
+		p.currencies = []string{"RUB"}
+	}
+	if len(p.locales) == 0 {
+		p.locales = base.Locales
+	}
+	if p.min <= 0 {
+		p.min = defaultMinAmount
+	}
+	if p.max <= 0 {
+		p.max = defaultMaxAmount
+	}
+	return p, nil
+}
+
+// Registry — провайдеры активного бренда в порядке показа.
+type Registry struct {
+	providers []Provider
+}
+
+// NewRegistry собирает реестр; повторный id заменяет предыдущий.
+func NewRegistry(providers ...Provider) *Registry {
+	r := &Registry{}
+	for _, p := range providers {
+		if p == nil {
+			continue
+		}
+		if i := r.index(p.ID()); i >= 0 {
+			r.providers[i] = p
+			continue
+		}
+		r.providers = append(r.providers, p)
+	}
+	return r
+}
+
+// RegistryFromConfig — реестр из brand.payment_providers; пустой список — YooKassa и CryptoCloud.
+func RegistryFromConfig(cfg *config.Config) (*Registry, error) {
+	brand := cfg.EffectiveBrand()
+	builtin := builtinProviders(brand.YooKassaPaySystem)
+	list := brand.PaymentProviders
+	if len(list) == 0 {
+		list = []config.PaymentProvider{{ID: ProviderYooKassa}, {ID: ProviderCryptoCloud}}
+	}
+	out := make([]Provider, 0, len(list))
+	for _, c := range list {
+		p, err := newSHMProvider(c, builtin)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return NewRegistry(out...), nil
+}
+
+func (r *Registry) index(id string) int {
+	for i, p := range r.providers {
+		if p.ID() == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// Get — провайдер по id, если он доступен бренду brandID.
+func (r *Registry) Get(id, brandID string) (Provider, error) {
+	if r != nil {
+		if i := r.index(strings.TrimSpace(id)); i >= 0 && r.providers[i].SupportsBrand(brandID) {
+			return r.providers[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownProvider, id)
+}
+
+// Available — провайдеры для бренда и локали (кнопки в web и боте); первый — выбор по умолчанию.
+func (r *Registry) Available(brandID, locale string) []Provider {
+	if r == nil {
+		return nil
+	}
+	var out []Provider
+	for _, p := range r.providers {
+		if p.SupportsBrand(brandID) && p.SupportsLocale(locale) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
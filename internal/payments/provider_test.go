@@ -0,0 +1,136 @@
+package payments
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/ryabkov82/vpnbot/internal/config"
+)
+
+func registryTestCfg(providers ...config.PaymentProvider) *config.Config {
+	cfg := &config.Config{}
+	cfg.Brand.ID = "vff"
+	cfg.Brand.YooKassaPaySystem = "yookassa_vff"
+	cfg.Brand.PaymentProviders = providers
+	return cfg
+}
+
+func providerIDs(list []Provider) []string {
+	var ids []string
+	for _, p := range list {
+		ids = append(ids, p.ID())
+	}
+	return ids
+}
+
+func TestRegistryFromConfig_DefaultsMatchLegacyFlow(t *testing.T) {
+	reg, err := RegistryFromConfig(registryTestCfg())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := providerIDs(reg.Available("vff", "ru")); len(got) != 2 || got[0] != ProviderYooKassa || got[1] != ProviderCryptoCloud {
+		t.Fatalf("ru: %v", got)
+	}
+	if got := providerIDs(reg.Available("vff", "en")); len(got) != 1 || got[0] != ProviderCryptoCloud {
+		t.Fatalf("en: %v", got)
+	}
+
+	yk, err := reg.Get(ProviderYooKassa, "vff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := yk.PaymentURL(PaymentRequest{BaseURL: "https://bill.example", UserID: 7, Amount: 150, TS: 1, BrandID: "vff"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy, _ := BuildYooKassaPaymentURL("https://bill.example", 7, 150, 1, "yookassa_vff", "vff")
+	if raw != legacy {
+		t.Fatalf("yookassa url %s, legacy %s", raw, legacy)
+	}
+	if yk.DisplayName("en") != "Bank card" || yk.DisplayName("de") != "Банковская карта" {
+		t.Fatalf("names: %q %q", yk.DisplayName("en"), yk.DisplayName("de"))
+	}
+
+	cc, _ := reg.Get(ProviderCryptoCloud, "vff")
+	raw, err = cc.PaymentURL(PaymentRequest{BaseURL: "https://bill.example", UserID: 7, Amount: 150, TS: 1, BrandID: "vff"})
+	legacy, _ = BuildCryptoCloudPaymentURL("https://bill.example", 7, 150, 1)
+	if err != nil || raw != legacy {
+		t.Fatalf("cryptocloud url %s (%v), legacy %s", raw, err, legacy)
+	}
+}
+
+func TestRegistryFromConfig_CustomSHMPaySystem(t *testing.T) {
+	reg, err := RegistryFromConfig(registryTestCfg(
+		config.PaymentProvider{ID: "cryptocloud"},
+		config.PaymentProvider{ID: "paymaster", Names: map[string]string{"ru": "СБП"}, MinAmount: 100, MaxAmount: 3000, Brands: []string{"fc"}},
+		config.PaymentProvider{ID: "stars", PaySystem: "tg_stars", Locales: []string{"ru"}},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := providerIDs(reg.Available("vff", "ru")); len(got) != 2 || got[0] != "cryptocloud" || got[1] != "stars" {
+		t.Fatalf("vff ru: %v", got)
+	}
+	if _, err := reg.Get("paymaster", "vff"); !errors.Is(err, ErrUnknownProvider) {
+		t.Fatalf("paymaster for vff: %v", err)
+	}
+	if _, err := reg.Get(ProviderYooKassa, "vff"); !errors.Is(err, ErrUnknownProvider) {
+		t.Fatalf("yookassa not configured: %v", err)
+	}
+
+	pm, err := reg.Get("paymaster", "fc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pm.DisplayName("en") != "СБП" || !AmountAllowed(pm, 100) || AmountAllowed(pm, 99.99) || AmountAllowed(pm, 3000.5) || AmountAllowed(pm, 150.001) {
+		t.Fatalf("paymaster limits/names")
+	}
+	stars, _ := reg.Get("stars", "vff")
+	raw, err := stars.PaymentURL(PaymentRequest{BaseURL: "https://bill.example/", UserID: 3, Amount: 60, TS: 5, BrandID: "vff"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(raw)
+	if u.Path != "/shm/pay_systems/stars.cgi" || u.Query().Get("ps") != "tg_stars" || u.Query().Get("amount") != "60" || u.Query().Has("brand_id") {
+		t.Fatalf("stars url: %s", raw)
+	}
+	if c := stars.Currencies(); len(c) != 1 || c[0] != "RUB" {
+		t.Fatalf("currencies: %v", c)
+	}
+}
+
+func TestYooKassaProviderFailsClosed(t *testing.T) {
+	reg, _ := RegistryFromConfig(registryTestCfg())
+	yk, err := reg.Get(ProviderYooKassa, "Bad ID")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := yk.PaymentURL(PaymentRequest{BaseURL: "https://h", UserID: 1, Amount: 100, BrandID: "Bad ID"}); err == nil {
+		t.Fatal("invalid brand id must fail")
+	}
+	cfg := registryTestCfg()
+	cfg.Brand.YooKassaPaySystem = ""
+	reg, _ = RegistryFromConfig(cfg)
+	yk, _ = reg.Get(ProviderYooKassa, "vff")
+	if _, err := yk.PaymentURL(PaymentRequest{BaseURL: "https://h", UserID: 1, Amount: 100, BrandID: "vff"}); err == nil {
+		t.Fatal("empty yookassa pay system must fail")
+	}
+}
+
+func TestFitAmount(t *testing.T) {
+	reg, err := RegistryFromConfig(registryTestCfg(config.PaymentProvider{ID: "paymaster", MinAmount: 100, MaxAmount: 2000}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _ := reg.Get("paymaster", "vff")
+	for _, c := range []struct {
+		in, want float64
+		ok       bool
+	}{{60, 100, true}, {150.555, 150.56, true}, {2500, 2500, false}} {
+		got, ok := FitAmount(p, c.in)
+		if got != c.want || ok != c.ok {
+			t.Fatalf("%v: %v %v", c.in, got, ok)
+		}
+	}
+}
@@ -0,0 +1,395 @@
+// Package proxylink разбирает URI ключей прокси (vless, vmess, trojan, ss, hysteria2),
+// которые Marzban отдаёт в links подписки.
+package proxylink
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Protocol — протокол ключа.
+type Protocol string
+
+const (
+	ProtocolVLESS       Protocol = "vless"
+	ProtocolVMess       Protocol = "vmess"
+	ProtocolTrojan      Protocol = "trojan"
+	ProtocolShadowsocks Protocol = "shadowsocks"
+	ProtocolHysteria2   Protocol = "hysteria2"
+)
+
+// Значения Security.
+const (
+	SecurityNone    = "none"
+	SecurityTLS     = "tls"
+	SecurityReality = "reality"
+)
+
+var (
+	// ErrUnsupportedScheme — схема URI не относится к поддерживаемым протоколам.
+	ErrUnsupportedScheme = errors.New("proxylink: unsupported scheme")
+	// ErrMalformed — URI не удалось разобрать.
+	ErrMalformed = errors.New("proxylink: malformed link")
+)
+
+// Reality — параметры REALITY (pbk/sid/spx).
+type Reality struct {
+	PublicKey string `json:"public_key,omitempty"`
+	ShortID   string `json:"short_id,omitempty"`
+	SpiderX   string `json:"spider_x,omitempty"`
+}
+
+// Link — разобранный ключ.
+type Link struct {
+	Protocol Protocol `json:"protocol"`
+	Server   string   `json:"server"`
+	Port     int      `json:"port"`
+	// UserID — uuid для vless/vmess, пароль для trojan/ss/hysteria2.
+	UserID string `json:"-"`
+	// Method — шифр Shadowsocks.
+	Method string `json:"method,omitempty"`
+	Flow   string `json:"flow,omitempty"`
+
+	// Transport — tcp, ws, grpc, h2, httpupgrade, xhttp, kcp, quic; для hysteria2 всегда udp.
+	Transport   string `json:"transport"`
+	Path        string `json:"path,omitempty"`
+	Host        string `json:"host,omitempty"`
+	ServiceName string `json:"service_name,omitempty"`
+	HeaderType  string `json:"header_type,omitempty"`
+
+	Security    string   `json:"security"`
+	SNI         string   `json:"sni,omitempty"`
+	Fingerprint string   `json:"fingerprint,omitempty"`
+	ALPN        []string `json:"alpn,omitempty"`
+	Insecure    bool     `json:"insecure,omitempty"`
+	Reality     *Reality `json:"reality,omitempty"`
+
+	// Plugin — plugin SIP002 для ss (как есть); Obfs — obfs для hysteria2.
+	Plugin       string `json:"plugin,omitempty"`
+	Obfs         string `json:"obfs,omitempty"`
+	ObfsPassword string `json:"-"`
+
+	// Remark — подпись из #fragment (для vmess — поле ps), обычно локация сервера.
+	Remark string `json:"remark,omitempty"`
+	Raw    string `json:"-"`
+}
+
+// Parse разбирает один URI ключа.
+func Parse(raw string) (*Link, error) {
+	s := strings.TrimSpace(raw)
+	i := strings.Index(s, "://")
+	if i <= 0 {
+		return nil, fmt.Errorf("%w: no scheme", ErrMalformed)
+	}
+	var (
+		l   *Link
+		err error
+	)
+	switch strings.ToLower(s[:i]) {
+	case "vless":
+		l, err = parseURLStyle(s, ProtocolVLESS)
+	case "trojan":
+		l, err = parseURLStyle(s, ProtocolTrojan)
+	case "hysteria2", "hy2":
+		l, err = parseURLStyle(s, ProtocolHysteria2)
+	case "vmess":
+		l, err = parseVMess(s[i+3:])
+	case "ss":
+		l, err = parseShadowsocks(s[i+3:])
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedScheme, s[:i])
+	}
+	if err != nil {
+		return nil, err
+	}
+	l.Raw = s
+	return l, nil
+}
+
+// Label — человекочитаемое название ключа: «VLESS Reality TCP», «VMess WS TLS», «Shadowsocks».
+func (l *Link) Label() string {
+	parts := []string{protocolTitle(l.Protocol)}
+	switch l.Protocol {
+	case ProtocolShadowsocks, ProtocolHysteria2:
+		return parts[0]
+	}
+	if l.Security == SecurityReality {
+		parts = append(parts, "Reality")
+	}
+	parts = append(parts, transportTitle(l.Transport))
+	if l.Security == SecurityTLS {
+		parts = append(parts, "TLS")
+	}
+	return strings.Join(parts, " ")
+}
+
+func protocolTitle(p Protocol) string {
+	switch p {
+	case ProtocolVLESS:
+		return "VLESS"
+	case ProtocolVMess:
+		return "VMess"
+	case ProtocolTrojan:
+		return "Trojan"
+	case ProtocolShadowsocks:
+		return "Shadowsocks"
+	case ProtocolHysteria2:
+		return "Hysteria2"
+	}
+	return strings.ToUpper(string(p))
+}
+
+func transportTitle(t string) string {
+	switch t {
+	case "", "tcp", "raw":
+		return "TCP"
+	case "ws":
+		return "WS"
+	case "grpc", "gun":
+		return "gRPC"
+	case "h2", "http":
+		return "H2"
+	case "httpupgrade":
+		return "HTTPUpgrade"
+	case "xhttp", "splithttp":
+		return "XHTTP"
+	case "kcp", "mkcp":
+		return "mKCP"
+	case "quic":
+		return "QUIC"
+	}
+	return strings.ToUpper(t)
+}
+
+// parseURLStyle — vless/trojan/hysteria2: userinfo@host:port?query#remark.
+func parseURLStyle(s string, p Protocol) (*Link, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	if u.User == nil || u.User.Username() == "" {
+		return nil, fmt.Errorf("%w: missing user", ErrMalformed)
+	}
+	port, err := parsePort(u.Port())
+	if err != nil {
+		return nil, err
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("%w: missing host", ErrMalformed)
+	}
+	userID := u.User.Username()
+	if pw, ok := u.User.Password(); ok && p == ProtocolHysteria2 {
+		// hysteria2 допускает auth вида user:pass.
+		userID += ":" + pw
+	}
+	q := u.Query()
+	l := &Link{
+		Protocol:    p,
+		Server:      u.Hostname(),
+		Port:        port,
+		UserID:      userID,
+		Flow:        q.Get("flow"),
+		Transport:   strings.ToLower(q.Get("type")),
+		Path:        q.Get("path"),
+		Host:        q.Get("host"),
+		ServiceName: q.Get("serviceName"),
+		HeaderType:  q.Get("headerType"),
+		Security:    strings.ToLower(q.Get("security")),
+		SNI:         q.Get("sni"),
+		Fingerprint: q.Get("fp"),
+		ALPN:        splitALPN(q.Get("alpn")),
+		Insecure:    truthy(q.Get("allowInsecure")) || truthy(q.Get("insecure")),
+		Remark:      u.Fragment,
+	}
+	if l.SNI == "" {
+		l.SNI = q.Get("peer")
+	}
+	switch p {
+	case ProtocolHysteria2:
+		l.Transport = "udp"
+		l.Security = SecurityTLS
+		l.Obfs = q.Get("obfs")
+		l.ObfsPassword = q.Get("obfs-password")
+	case ProtocolTrojan:
+		if l.Security == "" {
+			l.Security = SecurityTLS
+		}
+	}
+	if l.Transport == "" {
+		l.Transport = "tcp"
+	}
+	if l.Security == "" {
+		l.Security = SecurityNone
+	}
+	if l.Security == SecurityReality {
+		l.Reality = &Reality{PublicKey: q.Get("pbk"), ShortID: q.Get("sid"), SpiderX: q.Get("spx")}
+	}
+	return l, nil
+}
+
+// vmessJSON — формат v2rayN: base64(JSON).
+type vmessJSON struct {
+	PS   string          `json:"ps"`
+	Add  string          `json:"add"`
+	Port json.RawMessage `json:"port"`
+	ID   string          `json:"id"`
+	Net  string          `json:"net"`
+	Type string          `json:"type"`
+	Host string          `json:"host"`
+	Path string          `json:"path"`
+	TLS  string          `json:"tls"`
+	SNI  string          `json:"sni"`
+	ALPN string          `json:"alpn"`
+	FP   string          `json:"fp"`
+}
+
+func parseVMess(body string) (*Link, error) {
+	if j := strings.IndexByte(body, '#'); j >= 0 {
+		body = body[:j]
+	}
+	dec, err := decodeBase64(body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: vmess base64: %v", ErrMalformed, err)
+	}
+	var v vmessJSON
+	if err := json.Unmarshal(dec, &v); err != nil {
+		return nil, fmt.Errorf("%w: vmess json: %v", ErrMalformed, err)
+	}
+	port, err := parsePort(strings.Trim(string(v.Port), `"`))
+	if err != nil {
+		return nil, err
+	}
+	if v.Add == "" || v.ID == "" {
+		return nil, fmt.Errorf("%w: vmess without add/id", ErrMalformed)
+	}
+	l := &Link{
+		Protocol:    ProtocolVMess,
+		Server:      v.Add,
+		Port:        port,
+		UserID:      v.ID,
+		Transport:   strings.ToLower(v.Net),
+		Path:        v.Path,
+		Host:        v.Host,
+		HeaderType:  v.Type,
+		Security:    strings.ToLower(v.TLS),
+		SNI:         v.SNI,
+		Fingerprint: v.FP,
+		ALPN:        splitALPN(v.ALPN),
+		Remark:      v.PS,
+	}
+	if l.Transport == "" {
+		l.Transport = "tcp"
+	}
+	if l.Transport == "grpc" {
+		l.ServiceName, l.Path = v.Path, ""
+	}
+	if l.Security == "" {
+		l.Security = SecurityNone
+	}
+	return l, nil
+}
+
+// parseShadowsocks поддерживает SIP002 (ss://base64(method:pass)@host:port или
+// ss://method:pass@host:port с percent-encoding) и legacy ss://base64(method:pass@host:port).
+func parseShadowsocks(body string) (*Link, error) {
+	remark := ""
+	if j := strings.IndexByte(body, '#'); j >= 0 {
+		remark, _ = url.PathUnescape(body[j+1:])
+		body = body[:j]
+	}
+	query := ""
+	if j := strings.IndexByte(body, '?'); j >= 0 {
+		query = body[j+1:]
+		body = body[:j]
+	}
+	body = strings.TrimSuffix(body, "/")
+
+	var userinfo, hostport string
+	if at := strings.LastIndexByte(body, '@'); at >= 0 {
+		userinfo, hostport = body[:at], body[at+1:]
+		if dec, err := decodeBase64(userinfo); err == nil && strings.Contains(string(dec), ":") {
+			userinfo = string(dec)
+		} else if un, err := url.PathUnescape(userinfo); err == nil {
+			userinfo = un
+		}
+	} else {
+		dec, err := decodeBase64(body)
+		if err != nil {
+			return nil, fmt.Errorf("%w: ss base64: %v", ErrMalformed, err)
+		}
+		at := strings.LastIndexByte(string(dec), '@')
+		if at < 0 {
+			return nil, fmt.Errorf("%w: ss without host", ErrMalformed)
+		}
+		userinfo, hostport = string(dec[:at]), string(dec[at+1:])
+	}
+	method, password, ok := strings.Cut(userinfo, ":")
+	if !ok || method == "" {
+		return nil, fmt.Errorf("%w: ss without method", ErrMalformed)
+	}
+	host, portStr, err := net.SplitHostPort(hostport)
+	if err != nil || host == "" {
+		return nil, fmt.Errorf("%w: ss host: %v", ErrMalformed, err)
+	}
+	port, err := parsePort(portStr)
+	if err != nil {
+		return nil, err
+	}
+	l := &Link{
+		Protocol:  ProtocolShadowsocks,
+		Server:    host,
+		Port:      port,
+		UserID:    password,
+		Method:    strings.ToLower(method),
+		Transport: "tcp",
+		Security:  SecurityNone,
+		Remark:    remark,
+	}
+	if query != "" {
+		if q, err := url.ParseQuery(query); err == nil {
+			l.Plugin = q.Get("plugin")
+		}
+	}
+	return l, nil
+}
+
+func parsePort(s string) (int, error) {
+	p, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || p <= 0 || p > 65535 {
+		return 0, fmt.Errorf("%w: bad port %q", ErrMalformed, s)
+	}
+	return p, nil
+}
+
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimRight(s, "=")
+	if strings.ContainsAny(s, "-_") {
+		return base64.RawURLEncoding.DecodeString(s)
+	}
+	return base64.RawStdEncoding.DecodeString(s)
+}
+
+func splitALPN(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func truthy(s string) bool {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "1", "true", "yes":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package proxylink
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func TestParse_VLESSReality(t *testing.T) {
+	l, err := Parse("vless://b831381d-6324-4d53-ad4f-8cda48b30811@nl.example.com:443?security=reality&type=tcp&flow=xtls-rprx-vision&sni=www.google.com&fp=chrome&pbk=PUBKEY&sid=ab12#%F0%9F%87%B3%F0%9F%87%B1%20Netherlands")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Protocol != ProtocolVLESS || l.Server != "nl.example.com" || l.Port != 443 {
+		t.Fatalf("%+v", l)
+	}
+	if l.Security != SecurityReality || l.Reality == nil || l.Reality.PublicKey != "PUBKEY" || l.Reality.ShortID != "ab12" {
+		t.Fatalf("reality: %+v", l)
+	}
+	if l.Flow != "xtls-rprx-vision" || l.SNI != "www.google.com" || l.Fingerprint != "chrome" {
+		t.Fatalf("params: %+v", l)
+	}
+	if l.Remark != "🇳🇱 Netherlands" {
+		t.Fatalf("remark %q", l.Remark)
+	}
+	if got := l.Label(); got != "VLESS Reality TCP" {
+		t.Fatalf("label %q", got)
+	}
+}
+
+func TestParse_VLESSGRPCTLS(t *testing.T) {
+	l, err := Parse("vless://id@1.2.3.4:8443?security=tls&type=grpc&serviceName=svc&alpn=h2,http/1.1#DE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Transport != "grpc" || l.ServiceName != "svc" || len(l.ALPN) != 2 {
+		t.Fatalf("%+v", l)
+	}
+	if got := l.Label(); got != "VLESS gRPC TLS" {
+		t.Fatalf("label %q", got)
+	}
+}
+
+func TestParse_VMess(t *testing.T) {
+	js := `{"v":"2","ps":"Германия","add":"de.example.com","port":443,"id":"uuid-1","aid":"0","net":"ws","type":"none","host":"cdn.example.com","path":"/vm","tls":"tls","sni":"de.example.com"}`
+	l, err := Parse("vmess://" + base64.StdEncoding.EncodeToString([]byte(js)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Protocol != ProtocolVMess || l.Port != 443 || l.Path != "/vm" || l.Host != "cdn.example.com" || l.Remark != "Германия" {
+		t.Fatalf("%+v", l)
+	}
+	if got := l.Label(); got != "VMess WS TLS" {
+		t.Fatalf("label %q", got)
+	}
+}
+
+func TestParse_Trojan(t *testing.T) {
+	l, err := Parse("trojan://secret@tr.example.com:443?type=ws&path=%2Ftr&sni=tr.example.com#FI")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.UserID != "secret" || l.Security != SecurityTLS || l.Path != "/tr" || l.Remark != "FI" {
+		t.Fatalf("%+v", l)
+	}
+	if got := l.Label(); got != "Trojan WS TLS" {
+		t.Fatalf("label %q", got)
+	}
+}
+
+func TestParse_ShadowsocksSIP002(t *testing.T) {
+	l, err := Parse("ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpzM2NyZXQ@ss.example.com:8388/?plugin=obfs-local%3Bobfs%3Dhttp#Latvia")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Method != "chacha20-ietf-poly1305" || l.UserID != "s3cret" || l.Port != 8388 || l.Plugin != "obfs-local;obfs=http" || l.Remark != "Latvia" {
+		t.Fatalf("%+v", l)
+	}
+	if got := l.Label(); got != "Shadowsocks" {
+		t.Fatalf("label %q", got)
+	}
+}
+
+func TestParse_ShadowsocksPlainUserinfo(t *testing.T) {
+	l, err := Parse("ss://2022-blake3-aes-128-gcm:a%2Bb%3D@[2001:db8::1]:443#v6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Method != "2022-blake3-aes-128-gcm" || l.UserID != "a+b=" || l.Server != "2001:db8::1" {
+		t.Fatalf("%+v", l)
+	}
+}
+
+func TestParse_ShadowsocksLegacy(t *testing.T) {
+	l, err := Parse("ss://YWVzLTI1Ni1nY206cHdAMS4yLjMuNDo4Mzg4#Old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Method != "aes-256-gcm" || l.UserID != "pw" || l.Server != "1.2.3.4" || l.Port != 8388 || l.Remark != "Old" {
+		t.Fatalf("%+v", l)
+	}
+}
+
+func TestParse_Hysteria2(t *testing.T) {
+	for _, raw := range []string{
+		"hysteria2://pass@hy.example.com:4443/?sni=hy.example.com&obfs=salamander&insecure=1#PL",
+		"hy2://pass@hy.example.com:4443?sni=hy.example.com&obfs=salamander&insecure=1#PL",
+	} {
+		l, err := Parse(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if l.Protocol != ProtocolHysteria2 || l.UserID != "pass" || !l.Insecure || l.Obfs != "salamander" || l.Transport != "udp" {
+			t.Fatalf("%s: %+v", raw, l)
+		}
+		if got := l.Label(); got != "Hysteria2" {
+			t.Fatalf("label %q", got)
+		}
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	if _, err := Parse("wireguard://x@h:1"); !errors.Is(err, ErrUnsupportedScheme) {
+		t.Fatalf("want unsupported, got %v", err)
+	}
+	for _, raw := range []string{
+		"",
+		"vless://host:443",
+		"vless://id@host:0",
+		"vmess://not-base64!",
+		"ss://bm9jb2xvbg@host:1",
+		"trojan://pw@:443",
+	} {
+		if _, err := Parse(raw); !errors.Is(err, ErrMalformed) {
+			t.Fatalf("%q: want malformed, got %v", raw, err)
+		}
+	}
+}
@@ -0,0 +1,357 @@
+package proxylink
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RenderBase64 — классическая v2ray-подписка: base64 списка URI через \n.
+func RenderBase64(raws []string) []byte {
+	var lines []string
+	for _, r := range raws {
+		if r = strings.TrimSpace(r); r != "" {
+			lines = append(lines, r)
+		}
+	}
+	return []byte(base64.StdEncoding.EncodeToString([]byte(strings.Join(lines, "\n"))))
+}
+
+// proxyNames — уникальные имена прокси: remark, иначе Label; повторы получают суффикс « 2», « 3»…
+func proxyNames(links []*Link) []string {
+	seen := make(map[string]int, len(links))
+	out := make([]string, len(links))
+	for i, l := range links {
+		base := strings.TrimSpace(l.Remark)
+		if base == "" {
+			base = l.Label()
+		}
+		seen[base]++
+		name := base
+		if n := seen[base]; n > 1 {
+			name = base + " " + strconv.Itoa(n)
+		}
+		out[i] = name
+	}
+	return out
+}
+
+// RenderClashMeta — профиль Clash Meta (mihomo): proxies, группа выбора с названием профиля и MATCH.
+// Ключи, не выражаемые в Clash, пропускаются и возвращаются в skipped.
+func RenderClashMeta(links []*Link, title string) (out []byte, skipped []*Link) {
+	var ok []*Link
+	for _, l := range links {
+		if clashSupported(l) {
+			ok = append(ok, l)
+		} else {
+			skipped = append(skipped, l)
+		}
+	}
+	names := proxyNames(ok)
+	group := strings.TrimSpace(title)
+	if group == "" {
+		group = "Proxy"
+	}
+
+	var b strings.Builder
+	b.WriteString("mixed-port: 7890\nallow-lan: false\nmode: rule\nlog-level: warning\n\nproxies:")
+	if len(ok) == 0 {
+		b.WriteString(" []")
+	}
+	b.WriteByte('\n')
+	for i, l := range ok {
+		writeClashProxy(&b, names[i], l)
+	}
+	b.WriteString("\nproxy-groups:\n")
+	fmt.Fprintf(&b, "  - name: %s\n    type: select\n    proxies:\n", yamlString(group))
+	for _, n := range names {
+		fmt.Fprintf(&b, "      - %s\n", yamlString(n))
+	}
+	b.WriteString("      - DIRECT\n")
+	fmt.Fprintf(&b, "\nrules:\n  - %s\n", yamlString("MATCH,"+group))
+	return []byte(b.String()), skipped
+}
+
+func clashSupported(l *Link) bool {
+	if l.Protocol == ProtocolShadowsocks && l.Plugin != "" {
+		return false
+	}
+	switch l.Transport {
+	case "tcp", "raw", "ws", "grpc", "h2", "http", "httpupgrade", "udp":
+		return true
+	}
+	return false
+}
+
+// yamlString — двойные кавычки JSON являются корректным YAML-скаляром.
+func yamlString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+// yamlPlain — значение без кавычек (тип прокси, network).
+type yamlPlain string
+
+func writeClashProxy(b *strings.Builder, name string, l *Link) {
+	kv := func(indent, k string, v any) {
+		fmt.Fprintf(b, "%s%s: ", indent, k)
+		switch x := v.(type) {
+		case string:
+			b.WriteString(yamlString(x))
+		case yamlPlain:
+			b.WriteString(string(x))
+		case []string:
+			parts := make([]string, len(x))
+			for i, s := range x {
+				parts[i] = yamlString(s)
+			}
+			b.WriteString("[" + strings.Join(parts, ", ") + "]")
+		default:
+			fmt.Fprint(b, x)
+		}
+		b.WriteByte('\n')
+	}
+	fmt.Fprintf(b, "  - name: %s\n", yamlString(name))
+	const in = "    "
+	kv(in, "server", l.Server)
+	kv(in, "port", l.Port)
+	switch l.Protocol {
+	case ProtocolVLESS:
+		kv(in, "type", yamlPlain("vless"))
+		kv(in, "uuid", l.UserID)
+		if l.Flow != "" {
+			kv(in, "flow", l.Flow)
+		}
+	case ProtocolVMess:
+		kv(in, "type", yamlPlain("vmess"))
+		kv(in, "uuid", l.UserID)
+		kv(in, "alterId", 0)
+		kv(in, "cipher", "auto")
+	case ProtocolTrojan:
+		kv(in, "type", yamlPlain("trojan"))
+		kv(in, "password", l.UserID)
+	case ProtocolShadowsocks:
+		kv(in, "type", yamlPlain("ss"))
+		kv(in, "cipher", l.Method)
+		kv(in, "password", l.UserID)
+	case ProtocolHysteria2:
+		kv(in, "type", yamlPlain("hysteria2"))
+		kv(in, "password", l.UserID)
+		if l.Obfs != "" {
+			kv(in, "obfs", l.Obfs)
+			kv(in, "obfs-password", l.ObfsPassword)
+		}
+	}
+	kv(in, "udp", true)
+
+	if l.Protocol != ProtocolShadowsocks && l.Protocol != ProtocolHysteria2 {
+		if net := clashNetwork(l.Transport); net != "tcp" {
+			kv(in, "network", yamlPlain(net))
+		}
+		switch l.Transport {
+		case "ws", "httpupgrade":
+			b.WriteString(in + "ws-opts:\n")
+			kv(in+"  ", "path", orDefault(l.Path, "/"))
+			if l.Host != "" {
+				b.WriteString(in + "  headers:\n")
+				kv(in+"    ", "Host", l.Host)
+			}
+			if l.Transport == "httpupgrade" {
+				kv(in+"  ", "v2ray-http-upgrade", true)
+			}
+		case "grpc":
+			b.WriteString(in + "grpc-opts:\n")
+			kv(in+"  ", "grpc-service-name", l.ServiceName)
+		case "h2", "http":
+			b.WriteString(in + "h2-opts:\n")
+			kv(in+"  ", "path", orDefault(l.Path, "/"))
+			if l.Host != "" {
+				kv(in+"  ", "host", []string{l.Host})
+			}
+		}
+	}
+
+	if l.Security == SecurityTLS || l.Security == SecurityReality {
+		if l.Protocol == ProtocolVLESS || l.Protocol == ProtocolVMess {
+			kv(in, "tls", true)
+			if l.SNI != "" {
+				kv(in, "servername", l.SNI)
+			}
+		} else if l.SNI != "" {
+			kv(in, "sni", l.SNI)
+		}
+		if len(l.ALPN) > 0 {
+			kv(in, "alpn", l.ALPN)
+		}
+		if l.Fingerprint != "" {
+			kv(in, "client-fingerprint", l.Fingerprint)
+		}
+		if l.Insecure {
+			kv(in, "skip-cert-verify", true)
+		}
+	}
+	if l.Security == SecurityReality && l.Reality != nil {
+		b.WriteString(in + "reality-opts:\n")
+		kv(in+"  ", "public-key", l.Reality.PublicKey)
+		if l.Reality.ShortID != "" {
+			kv(in+"  ", "short-id", l.Reality.ShortID)
+		}
+	}
+}
+
+func clashNetwork(t string) string {
+	switch t {
+	case "ws", "httpupgrade":
+		return "ws"
+	case "grpc":
+		return "grpc"
+	case "h2", "http":
+		return "h2"
+	}
+	return "tcp"
+}
+
+func orDefault(s, def string) string {
+	if strings.TrimSpace(s) == "" {
+		return def
+	}
+	return s
+}
+
+// RenderSingBox — конфигурация sing-box: outbounds с selector (tag = название профиля) и direct, TUN-inbound.
+// Ключи, не выражаемые в sing-box, пропускаются и возвращаются в skipped.
+func RenderSingBox(links []*Link, title string) (out []byte, skipped []*Link, err error) {
+	var (
+		ok        []*Link
+		outbounds []map[string]any
+	)
+	for _, l := range links {
+		if singBoxSupported(l) {
+			ok = append(ok, l)
+		} else {
+			skipped = append(skipped, l)
+		}
+	}
+	names := proxyNames(ok)
+	for i, l := range ok {
+		outbounds = append(outbounds, singBoxOutbound(names[i], l))
+	}
+	group := strings.TrimSpace(title)
+	if group == "" {
+		group = "proxy"
+	}
+	members := append(append([]string{}, names...), "direct")
+	head := []map[string]any{
+		{"type": "selector", "tag": group, "outbounds": members, "default": firstOr(names, "direct")},
+	}
+	outbounds = append(head, outbounds...)
+	outbounds = append(outbounds, map[string]any{"type": "direct", "tag": "direct"})
+	cfg := map[string]any{
+		"log": map[string]any{"level": "warn"},
+		"inbounds": []map[string]any{
+			{"type": "tun", "tag": "tun-in", "address": []string{"172.19.0.1/30"}, "auto_route": true, "strict_route": true},
+		},
+		"outbounds": outbounds,
+		"route":     map[string]any{"final": group, "auto_detect_interface": true},
+	}
+	out, err = json.MarshalIndent(cfg, "", "  ")
+	return out, skipped, err
+}
+
+func firstOr(s []string, def string) string {
+	if len(s) > 0 {
+		return s[0]
+	}
+	return def
+}
+
+func singBoxSupported(l *Link) bool {
+	if l.Protocol == ProtocolShadowsocks && l.Plugin != "" {
+		return false
+	}
+	switch l.Transport {
+	case "tcp", "raw", "ws", "grpc", "h2", "http", "httpupgrade", "quic", "udp":
+		return true
+	}
+	return false
+}
+
+func singBoxOutbound(tag string, l *Link) map[string]any {
+	o := map[string]any{"tag": tag, "server": l.Server, "server_port": l.Port}
+	switch l.Protocol {
+	case ProtocolVLESS:
+		o["type"] = "vless"
+		o["uuid"] = l.UserID
+		if l.Flow != "" {
+			o["flow"] = l.Flow
+		}
+	case ProtocolVMess:
+		o["type"] = "vmess"
+		o["uuid"] = l.UserID
+		o["security"] = "auto"
+		o["alter_id"] = 0
+	case ProtocolTrojan:
+		o["type"] = "trojan"
+		o["password"] = l.UserID
+	case ProtocolShadowsocks:
+		o["type"] = "shadowsocks"
+		o["method"] = l.Method
+		o["password"] = l.UserID
+	case ProtocolHysteria2:
+		o["type"] = "hysteria2"
+		o["password"] = l.UserID
+		if l.Obfs != "" {
+			o["obfs"] = map[string]any{"type": l.Obfs, "password": l.ObfsPassword}
+		}
+	}
+
+	if l.Protocol != ProtocolShadowsocks && l.Protocol != ProtocolHysteria2 {
+		switch l.Transport {
+		case "ws":
+			t := map[string]any{"type": "ws", "path": orDefault(l.Path, "/")}
+			if l.Host != "" {
+				t["headers"] = map[string]string{"Host": l.Host}
+			}
+			o["transport"] = t
+		case "httpupgrade":
+			t := map[string]any{"type": "httpupgrade", "path": orDefault(l.Path, "/")}
+			if l.Host != "" {
+				t["host"] = l.Host
+			}
+			o["transport"] = t
+		case "grpc":
+			o["transport"] = map[string]any{"type": "grpc", "service_name": l.ServiceName}
+		case "h2", "http":
+			t := map[string]any{"type": "http", "path": orDefault(l.Path, "/")}
+			if l.Host != "" {
+				t["host"] = []string{l.Host}
+			}
+			o["transport"] = t
+		case "quic":
+			o["transport"] = map[string]any{"type": "quic"}
+		}
+	}
+
+	if l.Security == SecurityTLS || l.Security == SecurityReality {
+		tls := map[string]any{"enabled": true}
+		if l.SNI != "" {
+			tls["server_name"] = l.SNI
+		}
+		if l.Insecure {
+			tls["insecure"] = true
+		}
+		if len(l.ALPN) > 0 {
+			tls["alpn"] = l.ALPN
+		}
+		if l.Fingerprint != "" {
+			tls["utls"] = map[string]any{"enabled": true, "fingerprint": l.Fingerprint}
+		}
+		if l.Security == SecurityReality && l.Reality != nil {
+			tls["reality"] = map[string]any{"enabled": true, "public_key": l.Reality.PublicKey, "short_id": l.Reality.ShortID}
+		}
+		o["tls"] = tls
+	}
+	return o
+}
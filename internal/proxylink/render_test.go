@@ -0,0 +1,111 @@
+package proxylink
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func mustParseAll(t *testing.T, raws ...string) []*Link {
+	t.Helper()
+	var out []*Link
+	for _, r := range raws {
+		l, err := Parse(r)
+		if err != nil {
+			t.Fatalf("%s: %v", r, err)
+		}
+		out = append(out, l)
+	}
+	return out
+}
+
+var renderSample = []string{
+	"vless://uuid-1@nl.example:443?security=reality&type=tcp&flow=xtls-rprx-vision&sni=www.google.com&fp=chrome&pbk=PUB&sid=ab#NL",
+	"vless://uuid-1@nl2.example:443?security=reality&type=tcp&pbk=PUB#NL",
+	"trojan://pw@de.example:443?type=grpc&serviceName=svc&sni=de.example#DE",
+	"ss://YWVzLTI1Ni1nY206cHdAMS4yLjMuNDo4Mzg4#LV",
+	"vless://uuid-1@x.example:443?security=tls&type=xhttp#XH",
+}
+
+func TestRenderBase64(t *testing.T) {
+	out := RenderBase64([]string{" a://1 ", "", "b://2"})
+	dec, err := base64.StdEncoding.DecodeString(string(out))
+	if err != nil || string(dec) != "a://1\nb://2" {
+		t.Fatalf("%q %v", dec, err)
+	}
+}
+
+func TestRenderClashMeta(t *testing.T) {
+	out, skipped := RenderClashMeta(mustParseAll(t, renderSample...), "VFF VPN")
+	y := string(out)
+	if len(skipped) != 1 || skipped[0].Remark != "XH" {
+		t.Fatalf("skipped: %+v", skipped)
+	}
+	for _, want := range []string{
+		`  - name: "NL"`,
+		`  - name: "NL 2"`,
+		`    type: vless`,
+		`    flow: "xtls-rprx-vision"`,
+		`    reality-opts:`,
+		`      public-key: "PUB"`,
+		`    client-fingerprint: "chrome"`,
+		`    network: grpc`,
+		`      grpc-service-name: "svc"`,
+		`    sni: "de.example"`,
+		`    cipher: "aes-256-gcm"`,
+		`  - name: "VFF VPN"`,
+		`  - "MATCH,VFF VPN"`,
+	} {
+		if !strings.Contains(y, want) {
+			t.Fatalf("missing %q in:\n%s", want, y)
+		}
+	}
+	if strings.Contains(y, "XH") {
+		t.Fatalf("unsupported link rendered:\n%s", y)
+	}
+}
+
+func TestRenderClashMeta_Empty(t *testing.T) {
+	out, _ := RenderClashMeta(nil, "")
+	if !strings.Contains(string(out), "proxies: []") || !strings.Contains(string(out), `"MATCH,Proxy"`) {
+		t.Fatalf("%s", out)
+	}
+}
+
+func TestRenderSingBox(t *testing.T) {
+	out, skipped, err := RenderSingBox(mustParseAll(t, renderSample...), "VFF VPN")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped) != 1 {
+		t.Fatalf("skipped %d", len(skipped))
+	}
+	var cfg struct {
+		Outbounds []map[string]any `json:"outbounds"`
+		Route     map[string]any   `json:"route"`
+	}
+	if err := json.Unmarshal(out, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Outbounds) != 6 || cfg.Outbounds[0]["type"] != "selector" || cfg.Outbounds[0]["tag"] != "VFF VPN" || cfg.Route["final"] != "VFF VPN" {
+		t.Fatalf("%s", out)
+	}
+	vl := cfg.Outbounds[1]
+	tls, _ := vl["tls"].(map[string]any)
+	reality, _ := tls["reality"].(map[string]any)
+	if vl["type"] != "vless" || vl["server_port"] != float64(443) || reality["public_key"] != "PUB" || tls["server_name"] != "www.google.com" {
+		t.Fatalf("vless: %+v", vl)
+	}
+	tr := cfg.Outbounds[3]
+	transport, _ := tr["transport"].(map[string]any)
+	if tr["type"] != "trojan" || transport["service_name"] != "svc" {
+		t.Fatalf("trojan: %+v", tr)
+	}
+	if ss := cfg.Outbounds[4]; ss["type"] != "shadowsocks" || ss["method"] != "aes-256-gcm" || ss["tls"] != nil {
+		t.Fatalf("ss: %+v", ss)
+	}
+	if cfg.Outbounds[5]["type"] != "direct" {
+		t.Fatalf("last: %+v", cfg.Outbounds[5])
+	}
+}
@@ -0,0 +1,209 @@
+// Package receipts — квитанции по платежам и месячные выписки (HTML и PDF) для пользователя и бухгалтерии.
+package receipts
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/models"
+)
+
+// DateLayout — формат дат в документах (время московское, как в SHM).
+const DateLayout = "02.01.2006 15:04"
+
+// Payment — поступление с уже разобранной датой (SHM хранит её строкой в московском времени).
+type Payment struct {
+	Pay    models.UserPay
+	PaidAt time.Time
+}
+
+// Withdrawal — списание за услугу с разобранной датой.
+type Withdrawal struct {
+	Item        models.WithdrawItem
+	WithdrawnAt time.Time
+}
+
+// Field — строка «подпись: значение» в шапке документа.
+type Field struct {
+	Label string
+	Value string
+}
+
+// Table — таблица документа; Total — итоговая строка (пусто — без итога).
+type Table struct {
+	Title   string
+	Columns []string
+	Rows    [][]string
+	Empty   string
+	Total   string
+}
+
+// Document — содержимое квитанции или выписки; из него рендерятся HTML и PDF.
+type Document struct {
+	Title    string
+	Brand    string
+	Fields   []Field
+	Tables   []Table
+	Footer   string
+	Filename string // без расширения
+}
+
+// User — кому выдан документ.
+type User struct {
+	ID    int
+	Login string
+}
+
+func (u User) String() string {
+	if l := strings.TrimSpace(u.Login); l != "" {
+		return fmt.Sprintf("%d (%s)", u.ID, l)
+	}
+	return strconv.Itoa(u.ID)
+}
+
+// ConsumedBy распределяет списания по поступлениям в порядке FIFO: каждое списание оплачивается
+// из самого раннего поступления с остатком. Возвращает списания, оплаченные из платежа payID;
+// Item.Total в них — только часть, пришедшаяся на этот платёж.
+func ConsumedBy(pays []Payment, withdrawals []Withdrawal, payID int) []Withdrawal {
+	ps := append([]Payment(nil), pays...)
+	sort.SliceStable(ps, func(i, j int) bool { return ps[i].PaidAt.Before(ps[j].PaidAt) })
+	ws := append([]Withdrawal(nil), withdrawals...)
+	sort.SliceStable(ws, func(i, j int) bool { return ws[i].WithdrawnAt.Before(ws[j].WithdrawnAt) })
+
+	left := make([]float64, len(ps))
+	for i := range ps {
+		left[i] = math.Max(ps[i].Pay.Money, 0)
+	}
+	var out []Withdrawal
+	next := 0
+	for _, w := range ws {
+		need := w.Item.Total
+		for need > 0.005 && next < len(ps) {
+			if ps[next].PaidAt.After(w.WithdrawnAt) {
+				// Списание раньше следующего поступления — оплачено из бонусов или в долг; дальше не ищем.
+				break
+			}
+			take := math.Min(need, left[next])
+			if take > 0.005 && ps[next].Pay.ID == payID {
+				part := w
+				part.Item.Total = math.Round(take*100) / 100
+				out = append(out, part)
+			}
+			need -= take
+			left[next] -= take
+			if left[next] <= 0.005 {
+				next++
+			}
+		}
+	}
+	return out
+}
+
+// Receipt — квитанция по одному поступлению и списаниям, оплаченным из него.
+func Receipt(brand string, user User, p Payment, consumed []Withdrawal, issuedAt time.Time) *Document {
+	d := &Document{
+		Title: fmt.Sprintf("Квитанция № %d-%d", user.ID, p.Pay.ID),
+		Brand: brand,
+		Fields: []Field{
+			{"Пользователь", user.String()},
+			{"Дата платежа (МСК)", p.PaidAt.Format(DateLayout)},
+			{"Сумма", money(p.Pay.Money)},
+			{"Платёжная система", paySystem(p.Pay.PaySystemID)},
+		},
+		Footer:   "Сформировано " + issuedAt.Format(DateLayout) + " (МСК).",
+		Filename: fmt.Sprintf("receipt-%d", p.Pay.ID),
+	}
+	if k := strings.TrimSpace(p.Pay.UniqKey); k != "" {
+		d.Fields = append(d.Fields, Field{"Идентификатор платежа", k})
+	}
+	d.Tables = append(d.Tables, withdrawalsTable("Оплачено из этого платежа", consumed))
+	return d
+}
+
+// Statement — выписка за месяц month (любой момент месяца в московском времени).
+func Statement(brand string, user User, month time.Time, pays []Payment, withdrawals []Withdrawal, issuedAt time.Time) *Document {
+	from := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, month.Location())
+	to := from.AddDate(0, 1, 0)
+	in := func(t time.Time) bool { return !t.Before(from) && t.Before(to) }
+
+	payTable := Table{Title: "Поступления", Columns: []string{"Дата (МСК)", "Платёжная система", "Сумма"}, Empty: "Поступлений нет."}
+	var paid float64
+	for _, p := range sortedPayments(pays) {
+		if !in(p.PaidAt) {
+			continue
+		}
+		paid += p.Pay.Money
+		payTable.Rows = append(payTable.Rows, []string{p.PaidAt.Format(DateLayout), paySystem(p.Pay.PaySystemID), money(p.Pay.Money)})
+	}
+	payTable.Total = money(paid)
+
+	var monthWithdrawals []Withdrawal
+	for _, w := range withdrawals {
+		if in(w.WithdrawnAt) {
+			monthWithdrawals = append(monthWithdrawals, w)
+		}
+	}
+	wTable := withdrawalsTable("Списания", monthWithdrawals)
+
+	return &Document{
+		Title: "Выписка за " + monthName(from),
+		Brand: brand,
+		Fields: []Field{
+			{"Пользователь", user.String()},
+			{"Период (МСК)", from.Format("02.01.2006") + " — " + to.AddDate(0, 0, -1).Format("02.01.2006")},
+			{"Поступило", money(paid)},
+			{"Списано", wTable.Total},
+		},
+		Tables:   []Table{payTable, wTable},
+		Footer:   "Сформировано " + issuedAt.Format(DateLayout) + " (МСК).",
+		Filename: "statement-" + from.Format("2006-01"),
+	}
+}
+
+func withdrawalsTable(title string, list []Withdrawal) Table {
+	t := Table{Title: title, Columns: []string{"Дата (МСК)", "Услуга", "Период", "Сумма"}, Empty: "Списаний нет."}
+	sorted := append([]Withdrawal(nil), list...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].WithdrawnAt.Before(sorted[j].WithdrawnAt) })
+	var total float64
+	for _, w := range sorted {
+		total += w.Item.Total
+		t.Rows = append(t.Rows, []string{w.WithdrawnAt.Format(DateLayout), strings.TrimSpace(w.Item.Name), months(w.Item.Months), money(w.Item.Total)})
+	}
+	t.Total = money(total)
+	return t
+}
+
+func sortedPayments(pays []Payment) []Payment {
+	out := append([]Payment(nil), pays...)
+	sort.SliceStable(out, func(i, j int) bool { return out[i].PaidAt.Before(out[j].PaidAt) })
+	return out
+}
+
+// money — сумма в документе; «руб.» вместо ₽, чтобы символ был и в PDF со стандартным шрифтом.
+func money(v float64) string {
+	return strings.Replace(models.FormatRubAmount(v), "₽", "руб.", 1)
+}
+
+func paySystem(id string) string {
+	if id = strings.TrimSpace(id); id != "" {
+		return id
+	}
+	return "—"
+}
+
+func months(m float64) string {
+	if m <= 0 {
+		return "—"
+	}
+	return strconv.FormatFloat(m, 'f', -1, 64) + " мес."
+}
+
+var monthNames = [...]string{"январь", "февраль", "март", "апрель", "май", "июнь", "июль", "август", "сентябрь", "октябрь", "ноябрь", "декабрь"}
+
+func monthName(t time.Time) string {
+	return fmt.Sprintf("%s %d", monthNames[t.Month()-1], t.Year())
+}
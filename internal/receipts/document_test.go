@@ -0,0 +1,95 @@
+package receipts
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/models"
+)
+
+var msk = time.FixedZone("MSK", 3*3600)
+
+func at(day, hour int) time.Time { return time.Date(2026, 10, day, hour, 0, 0, 0, msk) }
+
+func testPays() []Payment {
+	return []Payment{
+		{Pay: models.UserPay{ID: 1, Money: 300, PaySystemID: "yookassa"}, PaidAt: at(1, 10)},
+		{Pay: models.UserPay{ID: 2, Money: 500, PaySystemID: "card"}, PaidAt: at(5, 10)},
+	}
+}
+
+func testWithdrawals() []Withdrawal {
+	return []Withdrawal{
+		{Item: models.WithdrawItem{WithdrawID: 10, Name: "VPN 1m", Months: 1, Total: 200}, WithdrawnAt: at(1, 11)},
+		{Item: models.WithdrawItem{WithdrawID: 11, Name: "VPN 1m", Months: 1, Total: 200}, WithdrawnAt: at(6, 11)},
+	}
+}
+
+func TestConsumedBy_FIFO(t *testing.T) {
+	first := ConsumedBy(testPays(), testWithdrawals(), 1)
+	if len(first) != 2 || first[0].Item.Total != 200 || first[1].Item.Total != 100 {
+		t.Fatalf("pay 1: %+v", first)
+	}
+	second := ConsumedBy(testPays(), testWithdrawals(), 2)
+	if len(second) != 1 || second[0].Item.WithdrawID != 11 || second[0].Item.Total != 100 {
+		t.Fatalf("pay 2: %+v", second)
+	}
+	// Списание до платежа этим платежом не оплачено.
+	early := []Withdrawal{{Item: models.WithdrawItem{Total: 50}, WithdrawnAt: at(1, 9)}}
+	if got := ConsumedBy(testPays(), early, 1); len(got) != 0 {
+		t.Fatalf("early: %+v", got)
+	}
+}
+
+func TestStatement_FiltersMonth(t *testing.T) {
+	pays := append(testPays(), Payment{Pay: models.UserPay{ID: 3, Money: 999}, PaidAt: time.Date(2026, 11, 1, 0, 0, 0, 0, msk)})
+	d := Statement("VPN for Friends", User{ID: 42, Login: "@42"}, at(15, 0), pays, testWithdrawals(), at(18, 9))
+	if d.Filename != "statement-2026-10" || d.Title != "Выписка за октябрь 2026" {
+		t.Fatalf("%q %q", d.Filename, d.Title)
+	}
+	if len(d.Tables[0].Rows) != 2 || d.Tables[0].Total != "800 руб." || d.Tables[1].Total != "400 руб." {
+		t.Fatalf("%+v", d.Tables)
+	}
+}
+
+func TestHTML_Escapes(t *testing.T) {
+	d := Receipt("<b>Brand</b>", User{ID: 42}, testPays()[0], nil, at(18, 9))
+	out, err := HTML(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(out)
+	if strings.Contains(s, "<b>Brand</b>") || !strings.Contains(s, "&lt;b&gt;Brand") {
+		t.Fatal("brand must be escaped")
+	}
+	if !strings.Contains(s, "Квитанция № 42-1") || !strings.Contains(s, "01.10.2026 10:00") || !strings.Contains(s, "Списаний нет.") {
+		t.Fatal(s)
+	}
+}
+
+func TestPDF_Structure(t *testing.T) {
+	p := testPays()[0]
+	d := Receipt("VPN (Friends)", User{ID: 42, Login: "@42"}, p, ConsumedBy(testPays(), testWithdrawals(), 1), at(18, 9))
+	out, err := PDF(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(out, []byte("%PDF-1.4")) || !bytes.HasSuffix(out, []byte("%%EOF\n")) {
+		t.Fatal("header/trailer")
+	}
+	i := bytes.LastIndex(out, []byte("startxref\n"))
+	off, err := strconv.Atoi(strings.TrimSpace(strings.SplitN(string(out[i+len("startxref\n"):]), "\n", 2)[0]))
+	if err != nil || !bytes.HasPrefix(out[off:], []byte("xref\n")) {
+		t.Fatalf("startxref %d %v", off, err)
+	}
+	// «Квитанция» в cp1251 и экранирование скобок.
+	if !bytes.Contains(out, encodeCP1251("Квитанция № 42-1")) || !bytes.Contains(out, []byte(`VPN \(Friends\)`)) {
+		t.Fatal("content")
+	}
+	if string(encodeCP1251("ЁёЯя€")) != "\xa8\xb8\xdf\xff?" {
+		t.Fatal("cp1251")
+	}
+}
@@ -0,0 +1,68 @@
+package receipts
+
+import (
+	"bytes"
+	"html/template"
+)
+
+var htmlTemplate = template.Must(template.New("receipt").Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}} — {{.Brand}}</title>
+<style>
+body { font-family: Arial, Helvetica, sans-serif; color: #111; max-width: 760px; margin: 32px auto; padding: 0 16px; }
+h1 { font-size: 22px; margin: 0 0 4px; }
+.brand { color: #555; margin-bottom: 24px; }
+dl { display: grid; grid-template-columns: max-content 1fr; gap: 6px 16px; margin: 0 0 24px; }
+dt { color: #555; }
+dd { margin: 0; font-weight: 600; }
+h2 { font-size: 16px; margin: 24px 0 8px; }
+table { width: 100%; border-collapse: collapse; font-size: 14px; }
+th, td { border-bottom: 1px solid #ddd; padding: 6px 4px; text-align: left; }
+td:last-child, th:last-child { text-align: right; white-space: nowrap; }
+tfoot td { font-weight: 700; border-bottom: none; }
+.empty { color: #777; }
+footer { margin-top: 32px; color: #777; font-size: 12px; }
+@media print { body { margin: 0 auto; } }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<div class="brand">{{.Brand}}</div>
+<dl>
+{{- range .Fields}}
+<dt>{{.Label}}</dt><dd>{{.Value}}</dd>
+{{- end}}
+</dl>
+{{- range .Tables}}
+<h2>{{.Title}}</h2>
+{{- if .Rows}}
+<table>
+<thead><tr>{{range .Columns}}<th>{{.}}</th>{{end}}</tr></thead>
+<tbody>
+{{- range .Rows}}
+<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{- end}}
+</tbody>
+{{- if .Total}}
+<tfoot><tr><td colspan="{{len .Columns}}">Итого: {{.Total}}</td></tr></tfoot>
+{{- end}}
+</table>
+{{- else}}
+<p class="empty">{{.Empty}}</p>
+{{- end}}
+{{- end}}
+<footer>{{.Footer}}</footer>
+</body>
+</html>
+`))
+
+// HTML — документ как самостоятельная HTML-страница (печатается браузером).
+func HTML(d *Document) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := htmlTemplate.Execute(&buf, d); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
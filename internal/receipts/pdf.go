@@ -0,0 +1,275 @@
+package receipts
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// PDF без внешних зависимостей: стандартные шрифты Helvetica и однобайтовая кодировка cp1251
+// (кириллица через /Differences с именами глифов Adobe). Шрифты не встраиваются — файл маленький,
+// просмотрщики подставляют системный Helvetica/Arial с кириллицей.
+
+const (
+	pdfPageWidth  = 595.0 // A4, pt
+	pdfPageHeight = 842.0
+	pdfMargin     = 50.0
+)
+
+// pdfGlyphWidth — средняя ширина символа Helvetica в долях кегля (для переноса и выравнивания вправо).
+const pdfGlyphWidth = 0.55
+
+type pdfLine struct {
+	x, y  float64
+	size  float64
+	bold  bool
+	text  string
+	right bool // x — правый край
+}
+
+type pdfWriter struct {
+	pages [][]pdfLine
+	y     float64
+}
+
+func (w *pdfWriter) newPage() {
+	w.pages = append(w.pages, nil)
+	w.y = pdfPageHeight - pdfMargin
+}
+
+// advance сдвигает курсор на h и начинает новую страницу, если строка не помещается.
+func (w *pdfWriter) advance(h float64) {
+	if w.y-h < pdfMargin {
+		w.newPage()
+	}
+	w.y -= h
+}
+
+func (w *pdfWriter) text(x float64, size float64, bold bool, s string) {
+	w.pages[len(w.pages)-1] = append(w.pages[len(w.pages)-1], pdfLine{x: x, y: w.y, size: size, bold: bold, text: s})
+}
+
+func (w *pdfWriter) textRight(x float64, size float64, bold bool, s string) {
+	w.pages[len(w.pages)-1] = append(w.pages[len(w.pages)-1], pdfLine{x: x, y: w.y, size: size, bold: bold, text: s, right: true})
+}
+
+// fit обрезает строку до ширины width при кегле size.
+func fit(s string, width, size float64) string {
+	max := int(width / (size * pdfGlyphWidth))
+	r := []rune(s)
+	if max <= 1 || len(r) <= max {
+		return s
+	}
+	return string(r[:max-1]) + "…"
+}
+
+// PDF — документ в формате PDF 1.4 (A4, несколько страниц при длинных таблицах).
+func PDF(d *Document) ([]byte, error) {
+	w := &pdfWriter{}
+	w.newPage()
+	right := pdfPageWidth - pdfMargin
+	width := right - pdfMargin
+
+	w.advance(20)
+	w.text(pdfMargin, 18, true, d.Title)
+	w.advance(18)
+	w.text(pdfMargin, 11, false, d.Brand)
+	w.advance(10)
+	for _, f := range d.Fields {
+		w.advance(16)
+		w.text(pdfMargin, 10, false, f.Label+":")
+		w.text(pdfMargin+150, 10, true, fit(f.Value, width-150, 10))
+	}
+
+	for _, t := range d.Tables {
+		w.advance(28)
+		w.text(pdfMargin, 13, true, t.Title)
+		if len(t.Rows) == 0 {
+			w.advance(16)
+			w.text(pdfMargin, 10, false, t.Empty)
+			continue
+		}
+		cols := columnsX(len(t.Columns), width)
+		row := func(cells []string, bold bool) {
+			w.advance(15)
+			for i, c := range cells {
+				if i == len(cells)-1 {
+					w.textRight(right, 9, bold, c)
+					continue
+				}
+				w.text(pdfMargin+cols[i], 9, bold, fit(c, cols[i+1]-cols[i]-6, 9))
+			}
+		}
+		row(t.Columns, true)
+		for _, r := range t.Rows {
+			row(r, false)
+		}
+		if t.Total != "" {
+			w.advance(17)
+			w.textRight(right, 10, true, "Итого: "+t.Total)
+		}
+	}
+	if d.Footer != "" {
+		w.advance(30)
+		w.text(pdfMargin, 8, false, d.Footer)
+	}
+	return w.bytes(d.Title), nil
+}
+
+// columnsX — левые края колонок: первая (дата) узкая, последняя (сумма) выравнивается вправо.
+func columnsX(n int, width float64) []float64 {
+	xs := make([]float64, n+1)
+	if n <= 1 {
+		xs[n] = width
+		return xs
+	}
+	xs[1] = 100
+	rest := (width - 100 - 90) / float64(maxInt(n-2, 1))
+	for i := 2; i < n; i++ {
+		xs[i] = xs[i-1] + rest
+	}
+	xs[n] = width
+	return xs
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func (w *pdfWriter) bytes(title string) []byte {
+	var objs []string
+	add := func(s string) int {
+		objs = append(objs, s)
+		return len(objs)
+	}
+	catalog := add("") // заполняется после Pages
+	pages := add("")
+	enc := add("<< /Type /Encoding /BaseEncoding /WinAnsiEncoding /Differences [" + pdfDifferences() + "] >>")
+	f1 := add(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding %d 0 R >>", enc))
+	f2 := add(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding %d 0 R >>", enc))
+	var kids []string
+	for _, lines := range w.pages {
+		var content bytes.Buffer
+		for _, l := range lines {
+			font := "F1"
+			if l.bold {
+				font = "F2"
+			}
+			x := l.x
+			if l.right {
+				x -= float64(len([]rune(l.text))) * l.size * pdfGlyphWidth
+			}
+			fmt.Fprintf(&content, "BT /%s %s Tf %s %s Td (%s) Tj ET\n", font, num(l.size), num(x), num(l.y), pdfEscape(encodeCP1251(l.text)))
+		}
+		stream := add(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+		page := add(fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Resources << /Font << /F1 %d 0 R /F2 %d 0 R >> >> /Contents %d 0 R >>",
+			pages, num(pdfPageWidth), num(pdfPageHeight), f1, f2, stream))
+		kids = append(kids, fmt.Sprintf("%d 0 R", page))
+	}
+	objs[pages-1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids))
+	objs[catalog-1] = fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pages)
+	info := add("<< /Title " + pdfUTF16(title) + " /Producer (vpnbot) >>")
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objs))
+	for i, o := range objs {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, o)
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objs)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objs)+1, catalog, info, xref)
+	return out.Bytes()
+}
+
+func num(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+// encodeCP1251 — строка в байтах cp1251; символы вне кодировки заменяются на «?».
+func encodeCP1251(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r >= 0x20 && r < 0x7f:
+			out = append(out, byte(r))
+		case r >= 'А' && r <= 'я':
+			out = append(out, byte(0xC0+(r-'А')))
+		case r == 'Ё':
+			out = append(out, 0xA8)
+		case r == 'ё':
+			out = append(out, 0xB8)
+		case r == '№':
+			out = append(out, 0xB9)
+		case r == '«':
+			out = append(out, 0xAB)
+		case r == '»':
+			out = append(out, 0xBB)
+		case r == '—':
+			out = append(out, 0x97)
+		case r == '–':
+			out = append(out, 0x96)
+		case r == '…':
+			out = append(out, 0x85)
+		case r == ' ':
+			out = append(out, ' ')
+		default:
+			out = append(out, '?')
+		}
+	}
+	return out
+}
+
+// pdfDifferences — имена глифов для кириллицы cp1251 (остальное совпадает с WinAnsi).
+func pdfDifferences() string {
+	var b strings.Builder
+	b.WriteString("168 /afii10023 184 /afii10071 /afii61352 192")
+	for k := 0; k < 32; k++ {
+		fmt.Fprintf(&b, " /afii%d", 10017+k+boolInt(k >= 6))
+	}
+	for k := 0; k < 32; k++ {
+		fmt.Fprintf(&b, " /afii%d", 10065+k+boolInt(k >= 6))
+	}
+	return b.String()
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func pdfEscape(b []byte) string {
+	var out strings.Builder
+	for _, c := range b {
+		switch c {
+		case '(', ')', '\\':
+			out.WriteByte('\\')
+			out.WriteByte(c)
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return out.String()
+}
+
+// pdfUTF16 — строка метаданных (Info) в UTF-16BE с BOM.
+func pdfUTF16(s string) string {
+	var b strings.Builder
+	b.WriteString("<FEFF")
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", u)
+	}
+	b.WriteString(">")
+	return b.String()
+}
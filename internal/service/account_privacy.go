@@ -0,0 +1,291 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/attribution"
+	"github.com/ryabkov82/vpnbot/internal/models"
+	"github.com/ryabkov82/vpnbot/internal/webuser"
+)
+
+// UserDataExportProfile — основные поля SHM-пользователя.
+type UserDataExportProfile struct {
+	UserID  int     `json:"user_id"`
+	Login   string  `json:"login"`
+	Login2  string  `json:"login2,omitempty"`
+	Balance float64 `json:"balance"`
+	BrandID string  `json:"brand_id"`
+}
+
+// UserDataExportIdentities — привязанные способы входа.
+type UserDataExportIdentities struct {
+	WebEmail         string `json:"web_email,omitempty"`
+	WebSource        string `json:"web_source,omitempty"`
+	TelegramChatID   int64  `json:"telegram_chat_id,omitempty"`
+	TelegramUsername string `json:"telegram_username,omitempty"`
+}
+
+// UserDataExportService — услуга без внутренних ключей подключения.
+type UserDataExportService struct {
+	UserServiceID int    `json:"user_service_id"`
+	ServiceID     int    `json:"service_id"`
+	Name          string `json:"name"`
+	Category      string `json:"category"`
+	Status        string `json:"status"`
+	Expire        string `json:"expire"`
+	Period        string `json:"period"`
+	Cost          string `json:"cost"`
+}
+
+// UserDataExport — выгрузка данных пользователя по запросу (privacy request).
+type UserDataExport struct {
+	ExportedAt  string                   `json:"exported_at"`
+	Profile     UserDataExportProfile    `json:"profile"`
+	Identities  UserDataExportIdentities `json:"identities"`
+	Attribution *attribution.Record      `json:"attribution,omitempty"`
+	Settings    json.RawMessage          `json:"settings"`
+	Services    []UserDataExportService  `json:"services"`
+	Payments    []models.UserPay         `json:"payments"`
+	Withdrawals []models.WithdrawItem    `json:"withdrawals"`
+}
+
+// ExportUserData собирает профиль, settings, identities, услуги, платежи, списания и first-touch attribution.
+func (s *Service) ExportUserData(userID int) (*UserDataExport, error) {
+	if userID <= 0 {
+		return nil, errors.New("invalid user id")
+	}
+	u, err := s.apiClient.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, ErrUserNotFound
+	}
+	_, rawSettings, err := s.apiClient.FetchAdminUserRowRaw(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(rawSettings) == 0 {
+		rawSettings = json.RawMessage("{}")
+	}
+	services, err := s.apiClient.GetUserServices(userID)
+	if err != nil {
+		return nil, fmt.Errorf("export services: %w", err)
+	}
+	pays, err := s.apiClient.GetUserPays(userID)
+	if err != nil {
+		return nil, fmt.Errorf("export pays: %w", err)
+	}
+	withdrawals, err := s.apiClient.GetUserWithdrawals(userID)
+	if err != nil {
+		return nil, fmt.Errorf("export withdrawals: %w", err)
+	}
+
+	out := &UserDataExport{
+		ExportedAt: time.Now().UTC().Format(time.RFC3339),
+		Profile: UserDataExportProfile{
+			UserID:  u.ID,
+			Login:   u.Login,
+			Login2:  u.Login2,
+			Balance: u.Balance,
+			BrandID: strings.TrimSpace(u.Settings.BrandID),
+		},
+		Identities: UserDataExportIdentities{
+			WebEmail:         strings.TrimSpace(u.Settings.Web.Email),
+			WebSource:        strings.TrimSpace(u.Settings.Web.Source),
+			TelegramChatID:   u.Settings.Telegram.ChatID,
+			TelegramUsername: strings.TrimSpace(u.Settings.Telegram.Username),
+		},
+		Attribution: u.Settings.Attribution,
+		Settings:    rawSettings,
+		Services:    make([]UserDataExportService, 0, len(services)),
+		Payments:    models.VisibleUserPays(pays),
+		Withdrawals: withdrawals,
+	}
+	if out.Payments == nil {
+		out.Payments = []models.UserPay{}
+	}
+	if out.Withdrawals == nil {
+		out.Withdrawals = []models.WithdrawItem{}
+	}
+	for i := range services {
+		us := &services[i]
+		out.Services = append(out.Services, UserDataExportService{
+			UserServiceID: us.ServiceID,
+			ServiceID:     us.BaseServiceID,
+			Name:          us.Name,
+			Category:      us.Category,
+			Status:        us.Status,
+			Expire:        us.Expire,
+			Period:        us.Period,
+			Cost:          us.Cost,
+		})
+	}
+	slog.Info("account data export", "brand_id", s.activeBrandID(), "user_id", userID,
+		"services", len(out.Services), "payments", len(out.Payments), "withdrawals", len(out.Withdrawals))
+	return out, nil
+}
+
+// Шаги удаления аккаунта (settings.deletion.steps и лог).
+const (
+	AccountDeletionStepRequested       = "requested"
+	AccountDeletionStepCancelService   = "cancel_service"
+	AccountDeletionStepAnonymize       = "anonymize"
+	AccountDeletionStepRevokeSessions  = "revoke_sessions"
+	AccountDeletionStatusOK            = "ok"
+	AccountDeletionStatusFailed        = "failed"
+	accountDeletionAnonymizedLoginStem = "deleted_"
+)
+
+// AccountDeletionStep — запись audit trail удаления.
+type AccountDeletionStep struct {
+	Step   string `json:"step"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	At     string `json:"at"`
+}
+
+// AccountDeletionReport — итог удаления для ответа, уведомления поддержки и аудита.
+type AccountDeletionReport struct {
+	UserID        int                   `json:"user_id"`
+	OriginalLogin string                `json:"original_login"`
+	Email         string                `json:"email"`
+	Steps         []AccountDeletionStep `json:"steps"`
+	FailedSteps   int                   `json:"failed_steps"`
+}
+
+func (r *AccountDeletionReport) record(userID int, step, status, detail string) {
+	r.Steps = append(r.Steps, AccountDeletionStep{
+		Step:   step,
+		Status: status,
+		Detail: detail,
+		At:     time.Now().UTC().Format(time.RFC3339),
+	})
+	if status == AccountDeletionStatusFailed {
+		r.FailedSteps++
+	}
+	slog.Info("account deletion step", "user_id", userID, "step", step, "status", status, "detail", detail)
+}
+
+// DeleteUserAccount — самостоятельное удаление аккаунта (email подтверждён на уровне web).
+// Удаляет услуги пользователя, обезличивает settings.web/settings.telegram и освобождает login/login2
+// (старые сессии кабинета и поиск по chat id перестают находить запись). Баланс и платежи остаются в SHM
+// для бухгалтерии. Ошибки отдельных услуг фиксируются в audit trail и не прерывают удаление.
+func (s *Service) DeleteUserAccount(userID int, email string) (*AccountDeletionReport, error) {
+	if userID <= 0 {
+		return nil, errors.New("invalid user id")
+	}
+	brandID := s.activeBrandID()
+	if brandID == "" {
+		return nil, ErrActiveBrandIDRequired
+	}
+	normEmail, err := webuser.NormalizeEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	u, err := s.apiClient.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, ErrUserNotFound
+	}
+	stored, err := webuser.NormalizeEmail(u.Settings.Web.Email)
+	if err != nil || stored != normEmail {
+		return nil, ErrWebEmailNotLinked
+	}
+	webLogin, err := canonicalWebLoginFromEmail(normEmail, s.webLoginPrefix())
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureWebUserMembership(u, brandID, webLogin); err != nil {
+		return nil, err
+	}
+
+	report := &AccountDeletionReport{UserID: userID, OriginalLogin: u.Login, Email: normEmail}
+	report.record(userID, AccountDeletionStepRequested, AccountDeletionStatusOK, "")
+
+	services, err := s.apiClient.GetUserServices(userID)
+	if err != nil {
+		report.record(userID, AccountDeletionStepCancelService, AccountDeletionStatusFailed, "list: "+err.Error())
+	}
+	for i := range services {
+		usID := strconv.Itoa(services[i].ServiceID)
+		if err := s.apiClient.DeleteUserService(userID, usID); err != nil {
+			report.record(userID, AccountDeletionStepCancelService, AccountDeletionStatusFailed, usID+": "+err.Error())
+			continue
+		}
+		report.record(userID, AccountDeletionStepCancelService, AccountDeletionStatusOK, usID)
+	}
+
+	// Settings пишутся целиком: перечитываем их под общим замком settings, как saveSettingsKey.
+	mu := s.settingsLocks.forUser(userID)
+	mu.Lock()
+	settingsObj, err := s.loadSettingsMap(userID)
+	if err != nil {
+		mu.Unlock()
+		return report, err
+	}
+	settingsObj["web"] = map[string]interface{}{"deleted": true}
+	if _, ok := settingsObj["telegram"]; ok {
+		settingsObj["telegram"] = map[string]interface{}{"deleted": true}
+	}
+	delete(settingsObj, "merge_journal")
+	delete(settingsObj, "web_unlinked")
+	settingsObj["brand_id"] = brandID
+
+	// Audit trail уходит вместе с обезличиванием одним запросом, поэтому anonymize в нём выполнен вместе с записью.
+	// revoke_sessions дописывается только после проверки, что login освобождён.
+	persisted := append(append([]AccountDeletionStep{}, report.Steps...),
+		AccountDeletionStep{Step: AccountDeletionStepAnonymize, Status: AccountDeletionStatusOK, Detail: "settings.web, settings.telegram", At: time.Now().UTC().Format(time.RFC3339)},
+	)
+	settingsObj["deletion"] = map[string]interface{}{
+		"steps":        persisted,
+		"failed_steps": report.FailedSteps,
+	}
+
+	anonLogin := accountDeletionAnonymizedLoginStem + strconv.Itoa(userID)
+	err = s.apiClient.PostAdminUserUpdateFields(userID, map[string]interface{}{
+		"login":    anonLogin,
+		"login2":   "",
+		"settings": settingsObj,
+	})
+	mu.Unlock()
+	if err != nil {
+		report.record(userID, AccountDeletionStepAnonymize, AccountDeletionStatusFailed, err.Error())
+		return report, err
+	}
+	report.record(userID, AccountDeletionStepAnonymize, AccountDeletionStatusOK, "settings.web, settings.telegram")
+
+	var revokeErr error
+	switch still, err := s.apiClient.GetUserByLogin(u.Login); {
+	case err != nil:
+		report.record(userID, AccountDeletionStepRevokeSessions, AccountDeletionStatusFailed, "not verified: "+err.Error())
+	case still != nil && still.ID == userID:
+		report.record(userID, AccountDeletionStepRevokeSessions, AccountDeletionStatusFailed, "login not released")
+		revokeErr = ErrWebEmailLoginNotUpdated
+	default:
+		report.record(userID, AccountDeletionStepRevokeSessions, AccountDeletionStatusOK, "login, login2")
+	}
+	audit := map[string]interface{}{
+		"steps":        report.Steps,
+		"failed_steps": report.FailedSteps,
+	}
+	if revokeErr == nil {
+		audit["completed_at"] = time.Now().UTC().Format(time.RFC3339)
+	}
+	if err := s.saveSettingsKey(userID, "deletion", audit); err != nil {
+		slog.Error("account deletion: save audit", "user_id", userID, "err", err)
+	}
+	if revokeErr != nil {
+		return report, revokeErr
+	}
+	slog.Info("account deleted", "brand_id", brandID, "user_id", userID, "failed_steps", report.FailedSteps)
+	return report, nil
+}
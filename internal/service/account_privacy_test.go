@@ -0,0 +1,91 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func newPrivacyFixture(t *testing.T) (*fakeSHMUsers, *Service) {
+	t.Helper()
+	wl := webLoginFor(t, "me@privacy.test")
+	fake, acl := newFakeSHMUsers(t,
+		`{"user_id":70,"login":"@70","login2":"`+wl+`","balance":42.5,"settings":{"brand_id":"vff","telegram":{"chat_id":70,"username":"me","first_name":"Me"},"web":{"email":"me@privacy.test","source":"telegram_link"},"attribution":{"version":1,"first_touch":{"registration_channel":"telegram","registration_domain":"t.me"}}}}`,
+	)
+	fake.setRows(t, "service", 70, `[{"user_service_id":11,"service_id":3,"user_id":70,"name":"VPN","status":"ACTIVE","expire":"2026-12-01","config":"secret"},{"user_service_id":12,"service_id":3,"user_id":70,"name":"VPN old","status":"BLOCK"}]`)
+	fake.setRows(t, "pay", 70, `[{"id":1,"user_id":70,"money":500,"pay_system_id":"yookassa"}]`)
+	fake.setRows(t, "withdraw", 70, `[{"withdraw_id":5,"user_id":70,"name":"VPN","total":199}]`)
+	return fake, NewService(acl, testServiceBrand())
+}
+
+func TestExportUserData(t *testing.T) {
+	_, svc := newPrivacyFixture(t)
+	exp, err := svc.ExportUserData(70)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp.Profile.Login != "@70" || exp.Profile.Balance != 42.5 || exp.Identities.WebEmail != "me@privacy.test" || exp.Identities.TelegramChatID != 70 {
+		t.Fatalf("profile/identities %#v %#v", exp.Profile, exp.Identities)
+	}
+	if len(exp.Services) != 2 || len(exp.Payments) != 1 || len(exp.Withdrawals) != 1 || exp.Attribution == nil {
+		t.Fatalf("export %#v", exp)
+	}
+	raw, _ := json.Marshal(exp)
+	if strings.Contains(string(raw), `"config":"secret"`) {
+		t.Fatalf("export must not leak service config: %s", raw)
+	}
+}
+
+func TestDeleteUserAccount(t *testing.T) {
+	fake, svc := newPrivacyFixture(t)
+	if _, err := svc.DeleteUserAccount(70, "other@privacy.test"); !errors.Is(err, ErrWebEmailNotLinked) {
+		t.Fatalf("wrong email: %v", err)
+	}
+	rep, err := svc.DeleteUserAccount(70, "me@privacy.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.FailedSteps != 0 || rep.OriginalLogin != "@70" || len(rep.Steps) != 5 {
+		t.Fatalf("report %#v", rep)
+	}
+	if d := fake.deletedServices(); len(d) != 2 {
+		t.Fatalf("deleted services %v", d)
+	}
+	row := fake.row(70)
+	if row["login"] != "deleted_70" || row["login2"] != "" {
+		t.Fatalf("logins %#v", row)
+	}
+	st := row["settings"].(map[string]interface{})
+	raw, _ := json.Marshal(st)
+	if strings.Contains(string(raw), "me@privacy.test") || strings.Contains(string(raw), `"username"`) {
+		t.Fatalf("settings not anonymized: %s", raw)
+	}
+	if st["attribution"] == nil || st["deletion"] == nil {
+		t.Fatalf("attribution/audit missing: %s", raw)
+	}
+	if audit := st["deletion"].(map[string]interface{}); audit["completed_at"] == nil || len(audit["steps"].([]interface{})) != 5 {
+		t.Fatalf("audit %#v", audit)
+	}
+	if u, err := svc.GetUser(70); err != nil || u != nil {
+		t.Fatalf("bot must not find deleted user: %#v err=%v", u, err)
+	}
+	if _, err := svc.DeleteUserAccount(70, "me@privacy.test"); !errors.Is(err, ErrWebEmailNotLinked) {
+		t.Fatalf("repeat delete: %v", err)
+	}
+}
+
+func TestDeleteUserAccount_AuditRecordsUnreleasedLogin(t *testing.T) {
+	fake, svc := newPrivacyFixture(t)
+	fake.keepLogin = true
+	rep, err := svc.DeleteUserAccount(70, "me@privacy.test")
+	if !errors.Is(err, ErrWebEmailLoginNotUpdated) || rep.FailedSteps != 1 {
+		t.Fatalf("%#v %v", rep, err)
+	}
+	audit := fake.row(70)["settings"].(map[string]interface{})["deletion"].(map[string]interface{})
+	steps := audit["steps"].([]interface{})
+	last := steps[len(steps)-1].(map[string]interface{})
+	if audit["completed_at"] != nil || audit["failed_steps"] != 1.0 || last["step"] != AccountDeletionStepRevokeSessions || last["status"] != AccountDeletionStatusFailed {
+		t.Fatalf("audit %#v", audit)
+	}
+}
@@ -344,9 +344,14 @@ func TestGetUser_FCWrongTelegramID(t *testing.T) {
 }
 
 func TestGetUser_FCNoFallbackToVFFLogin(t *testing.T) {
-	var seen []string
+	var seen, seen2 []string
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		m := decodeAdminUserFilter(t, r.URL.Query().Get("filter"))
+		if login2, ok := m["login2"].(string); ok {
+			seen2 = append(seen2, login2)
+			writeUsersJSON(w)
+			return
+		}
 		login, _ := m["login"].(string)
 		seen = append(seen, login)
 		// Даже если бы искали @123 — ответ «не найден».
@@ -367,6 +372,9 @@ func TestGetUser_FCNoFallbackToVFFLogin(t *testing.T) {
 	if len(seen) != 1 || seen[0] != "@fc_123" {
 		t.Fatalf("lookups=%v, want only @fc_123", seen)
 	}
+	if len(seen2) != 1 || seen2[0] != "@fc_123" {
+		t.Fatalf("login2 lookups=%v, want only @fc_123 (reverse-linked web user)", seen2)
+	}
 }
 
 func TestRegisterAndLookup_SameTelegramIDTwoBrands(t *testing.T) {
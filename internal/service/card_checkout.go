@@ -0,0 +1,222 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"time"
+)
+
+// cardCheckoutsSettingsKey — settings.card_checkouts: {"<session_id>": {"status": "...", "user_service_id": N, "at": "..."}}.
+// Отметка в SHM делает обработку webhook идемпотентной и после рестарта.
+const cardCheckoutsSettingsKey = "card_checkouts"
+
+// Стадии обработки оплаченной сессии.
+const (
+	CardCheckoutCredited = "credited"
+	CardCheckoutOrdering = "ordering"
+	CardCheckoutOrdered  = "ordered"
+	// CardCheckoutReview — оплата не сходится с каталогом: услуга не заказана, сессию разбирает поддержка
+	// (GET /api/admin/card-checkouts). Если валюта совпала, оплаченное уже зачислено на баланс.
+	CardCheckoutReview = "review"
+)
+
+// Причина ручного разбора сессии (CardCheckoutResult.Reason).
+const (
+	CardCheckoutReasonService  = "service_mismatch"  // услуги нет, public_code сменился или нет цены
+	CardCheckoutReasonCurrency = "currency_mismatch" // валюта оплаты не совпадает с каталогом — пересчитать нельзя
+	CardCheckoutReasonAmount   = "amount_short"      // оплачено меньше цены услуги
+)
+
+// CardCheckoutPayment — оплаченная сессия карты: кому зачислить и какую услугу заказать.
+type CardCheckoutPayment struct {
+	SessionID   string
+	UserID      int
+	ServiceID   int
+	PublicCode  string
+	Currency    string
+	AmountCents int64
+	PaySystem   string
+}
+
+// CardCheckoutResult — итог обработки; Duplicate — сессия уже была обработана раньше.
+type CardCheckoutResult struct {
+	Status        string  `json:"status"`
+	Credited      float64 `json:"credited"`
+	UserServiceID int     `json:"user_service_id,omitempty"`
+	Reason        string  `json:"reason,omitempty"`
+	Duplicate     bool    `json:"duplicate,omitempty"`
+}
+
+// CardCheckoutUniqKey — user/pay.uniq_key зачисления по сессии.
+func CardCheckoutUniqKey(sessionID string) string {
+	return "card:" + sessionID
+}
+
+// FulfillCardCheckout зачисляет фактически оплаченную сумму в рублях по курсу каталога
+// (service.cost × оплачено / international_amount_cents) и заказывает услугу. Если пересчитать нельзя
+// (другая валюта, услуга не совпадает с каталогом) или оплачено меньше цены, сессия сохраняется в статусе
+// «review» без заказа. Повтор по той же сессии не зачисляет и не заказывает второй раз: платёж ищется по uniq_key,
+// заказ отмечается в settings.card_checkouts до вызова ServiceOrder. Сессия, застрявшая в «ordering»
+// (сбой между заказом и отметкой), повторно не заказывается — её разбирают вручную.
+func (s *Service) FulfillCardCheckout(p CardCheckoutPayment) (*CardCheckoutResult, error) {
+	p.SessionID = strings.TrimSpace(p.SessionID)
+	if p.SessionID == "" || p.UserID <= 0 || p.ServiceID <= 0 {
+		return nil, errors.New("invalid card checkout payment")
+	}
+	s.cardCheckoutMu.Lock()
+	defer s.cardCheckoutMu.Unlock()
+
+	settingsObj, err := s.loadSettingsMap(p.UserID)
+	if err != nil {
+		return nil, err
+	}
+	records, _ := settingsObj[cardCheckoutsSettingsKey].(map[string]interface{})
+	if records == nil {
+		records = map[string]interface{}{}
+	}
+	if prev, ok := records[p.SessionID].(map[string]interface{}); ok {
+		status, _ := prev["status"].(string)
+		switch status {
+		case CardCheckoutOrdered, CardCheckoutOrdering, CardCheckoutReview:
+			usID, _ := prev["user_service_id"].(float64)
+			credited, _ := prev["credited"].(float64)
+			reason, _ := prev["reason"].(string)
+			return &CardCheckoutResult{Status: status, Credited: credited, UserServiceID: int(usID), Reason: reason, Duplicate: true}, nil
+		}
+	}
+
+	svc, err := s.apiClient.GetServiceByID(p.ServiceID)
+	if err != nil {
+		return nil, fmt.Errorf("card checkout service lookup: %w", err)
+	}
+	credit, reason := 0.0, ""
+	switch {
+	case svc == nil || svc.Config == nil || !strings.EqualFold(strings.TrimSpace(svc.Config.Pricing.PublicCode), strings.TrimSpace(p.PublicCode)) || svc.Cost <= 0:
+		reason = CardCheckoutReasonService
+	case !strings.EqualFold(svc.Config.Pricing.InternationalCurrency, p.Currency) || svc.Config.Pricing.InternationalAmountCents <= 0:
+		reason = CardCheckoutReasonCurrency
+	default:
+		pricing := svc.Config.Pricing
+		credit = math.Round(svc.Cost*float64(p.AmountCents)/float64(pricing.InternationalAmountCents)*100) / 100
+		if pricing.InternationalAmountCents != p.AmountCents {
+			// Цена могла измениться после создания сессии: зачисляем оплаченное, а не цену каталога.
+			slog.Warn("card checkout amount differs from catalog",
+				"session_id", p.SessionID, "service_id", p.ServiceID, "paid", p.AmountCents,
+				"catalog", pricing.InternationalAmountCents, "currency", pricing.InternationalCurrency, "credit", credit, "cost", svc.Cost)
+		}
+		if credit < math.Round(svc.Cost*100)/100 {
+			reason = CardCheckoutReasonAmount
+		}
+	}
+	if reason != "" {
+		return s.holdCardCheckoutForReview(p, records, credit, reason)
+	}
+
+	if err := s.creditCardCheckout(p, credit); err != nil {
+		return nil, err
+	}
+	records[p.SessionID] = cardCheckoutRecord(CardCheckoutOrdering, credit, 0)
+	if err := s.saveSettingsKey(p.UserID, cardCheckoutsSettingsKey, records); err != nil {
+		return nil, err
+	}
+
+	us, orderErr := s.ServiceOrderByUserID(p.UserID, p.ServiceID)
+	status, usID := CardCheckoutOrdered, 0
+	if orderErr != nil {
+		// Деньги уже на балансе: повтор webhook снова попробует заказать услугу.
+		status = CardCheckoutCredited
+	} else if us != nil {
+		usID = us.ServiceID
+	}
+	records[p.SessionID] = cardCheckoutRecord(status, credit, usID)
+	if err := s.saveSettingsKey(p.UserID, cardCheckoutsSettingsKey, records); err != nil {
+		slog.Error("card checkout: save status", "session_id", p.SessionID, "user_id", p.UserID, "status", status, "err", err)
+	}
+	if orderErr != nil {
+		return nil, fmt.Errorf("card checkout order: %w", orderErr)
+	}
+	return &CardCheckoutResult{Status: status, Credited: credit, UserServiceID: usID}, nil
+}
+
+// holdCardCheckoutForReview зачисляет оплаченное (если его удалось пересчитать в рубли) и сохраняет сессию
+// в settings.card_checkouts со статусом «review»: услуга не заказывается, повтор webhook ничего не меняет.
+func (s *Service) holdCardCheckoutForReview(p CardCheckoutPayment, records map[string]interface{}, credit float64, reason string) (*CardCheckoutResult, error) {
+	if credit > 0 {
+		if err := s.creditCardCheckout(p, credit); err != nil {
+			return nil, err
+		}
+	}
+	rec := cardCheckoutRecord(CardCheckoutReview, credit, 0)
+	rec["reason"] = reason
+	rec["service_id"] = p.ServiceID
+	rec["public_code"] = p.PublicCode
+	rec["paid_currency"] = strings.ToUpper(p.Currency)
+	rec["paid_amount_cents"] = p.AmountCents
+	records[p.SessionID] = rec
+	if err := s.saveSettingsKey(p.UserID, cardCheckoutsSettingsKey, records); err != nil {
+		return nil, err
+	}
+	slog.Error("card checkout needs manual review", "session_id", p.SessionID, "user_id", p.UserID, "service_id", p.ServiceID,
+		"reason", reason, "paid", p.AmountCents, "paid_currency", p.Currency, "credited", credit)
+	return &CardCheckoutResult{Status: CardCheckoutReview, Credited: credit, Reason: reason}, nil
+}
+
+// CardCheckoutRecords — settings.card_checkouts пользователя (для поддержки): session_id → запись.
+func (s *Service) CardCheckoutRecords(userID int) (map[string]interface{}, error) {
+	if userID <= 0 {
+		return nil, errors.New("invalid user id")
+	}
+	settingsObj, err := s.loadSettingsMap(userID)
+	if err != nil {
+		return nil, err
+	}
+	records, _ := settingsObj[cardCheckoutsSettingsKey].(map[string]interface{})
+	if records == nil {
+		records = map[string]interface{}{}
+	}
+	return records, nil
+}
+
+// creditCardCheckout зачисляет платёж, если платежа с uniq_key сессии ещё нет.
+func (s *Service) creditCardCheckout(p CardCheckoutPayment, credit float64) error {
+	key := CardCheckoutUniqKey(p.SessionID)
+	pays, err := s.apiClient.GetUserPays(p.UserID)
+	if err != nil {
+		return err
+	}
+	for _, pay := range pays {
+		if pay.UniqKey == key {
+			return nil
+		}
+	}
+	return s.apiClient.AddUserPayment(p.UserID, credit, p.PaySystem, key, map[string]interface{}{
+		"session_id":   p.SessionID,
+		"public_code":  p.PublicCode,
+		"currency":     strings.ToUpper(p.Currency),
+		"amount_cents": p.AmountCents,
+	})
+}
+
+func cardCheckoutRecord(status string, credit float64, userServiceID int) map[string]interface{} {
+	rec := map[string]interface{}{
+		"status":   status,
+		"credited": credit,
+		"at":       time.Now().UTC().Format(time.RFC3339),
+	}
+	if userServiceID > 0 {
+		rec["user_service_id"] = userServiceID
+	}
+	return rec
+}
+
+// loadSettingsMap — settings пользователя как map (read-modify-write без потери неизвестных полей).
+func (s *Service) loadSettingsMap(userID int) (map[string]interface{}, error) {
+	_, rawSettings, err := s.apiClient.FetchAdminUserRowRaw(userID)
+	if err != nil {
+		return nil, err
+	}
+	return mergeSettingsJSONToMap(rawSettings)
+}
@@ -0,0 +1,51 @@
+package service
+
+import "testing"
+
+const cardCheckoutCatalogJSON = `{"service_id":7,"allow_to_order":1,"cost":450,"category":"vpn-mz-test","name":"Premium 1m",` +
+	`"config":{"pricing":{"public_code":"premium-1m","international_enabled":true,"international_currency":"USD","international_amount_cents":500}}}`
+
+func TestFulfillCardCheckout_CreditsWhatWasPaid(t *testing.T) {
+	fake, client := newFakeSHMUsers(t, `{"user_id":42,"login":"@42","settings":{}}`)
+	fake.setCatalog(t, cardCheckoutCatalogJSON)
+	s := NewService(client, orderBrandCfg("vff", "vpn-mz-test"))
+	pay := func(session, currency string, cents int64) *CardCheckoutResult {
+		t.Helper()
+		res, err := s.FulfillCardCheckout(CardCheckoutPayment{SessionID: session, UserID: 42, ServiceID: 7,
+			PublicCode: "premium-1m", Currency: currency, AmountCents: cents, PaySystem: "card_checkout"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	// Цена снизилась после создания сессии: зачисляется оплаченное, услуга заказывается.
+	if res := pay("cs_more", "usd", 600); res.Status != CardCheckoutOrdered || res.Credited != 540 || fake.orderCount() != 1 {
+		t.Fatalf("%+v orders=%d", res, fake.orderCount())
+	}
+
+	// Оплачено меньше цены: оплаченное на балансе, услуга не заказана, сессия ждёт разбора.
+	res := pay("cs_short", "USD", 400)
+	if res.Status != CardCheckoutReview || res.Reason != CardCheckoutReasonAmount || res.Credited != 360 || fake.orderCount() != 1 {
+		t.Fatalf("%+v orders=%d", res, fake.orderCount())
+	}
+	if again := pay("cs_short", "USD", 400); !again.Duplicate || again.Status != CardCheckoutReview || len(fake.payRows(42)) != 2 {
+		t.Fatalf("%+v pays=%v", again, fake.payRows(42))
+	}
+
+	// Другую валюту по курсу каталога не пересчитать: ничего не зачислено, запись видна поддержке.
+	if res := pay("cs_eur", "EUR", 500); res.Status != CardCheckoutReview || res.Reason != CardCheckoutReasonCurrency || res.Credited != 0 {
+		t.Fatalf("%+v", res)
+	}
+	if bal := fake.row(42)["balance"]; bal != 900.0 || len(fake.payRows(42)) != 2 {
+		t.Fatalf("balance %v pays=%v", bal, fake.payRows(42))
+	}
+	records, err := s.CardCheckoutRecords(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, _ := records["cs_eur"].(map[string]interface{})
+	if rec["status"] != CardCheckoutReview || rec["paid_currency"] != "EUR" || rec["paid_amount_cents"] != 500.0 || len(records) != 3 {
+		t.Fatalf("%+v", records)
+	}
+}
@@ -0,0 +1,424 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/infrastructure/api"
+)
+
+// fakeSHMUsers — минимальный in-memory /shm/v1/admin/user (GET по filter user_id/login/login2, POST/PUT update)
+// плюс /shm/v1/admin/user/service (GET, DELETE), /shm/v1/admin/user/pay (по user_id, uniq_key или date >=) и /shm/v1/admin/user/service/withdraw по user_id,
+// каталог /shm/v1/admin/service, зачисление PUT /shm/v1/admin/user/payment (меняет balance строки), баланс
+// /shm/v1/template/getUserBalance, заказ PUT /shm/v1/admin/service/order, изменение POST /shm/v1/admin/user/service,
+// продление PUT /shm/v1/admin/user/service/prolongate (списывает cost, +1 месяц к expire) и пустое хранилище ключей.
+// Строки хранятся как JSON-объекты, чтобы неизвестные поля settings сохранялись как в SHM.
+type fakeSHMUsers struct {
+	mu       sync.Mutex
+	rows     map[int]map[string]interface{}
+	posts    []map[string]interface{}
+	services map[int][]interface{}
+	pays     map[int][]interface{}
+	withdraw map[int][]interface{}
+	deleted  []string
+	catalog  map[int]interface{}
+	orders   []map[string]interface{}
+	// failOrders — PUT /admin/service/order отвечает 500.
+	failOrders bool
+	prolongs   int
+	// chargeOrders — PUT /admin/service/order списывает с баланса cost услуги каталога, как SHM при оплате заказа.
+	chargeOrders bool
+	// orderReturns — PUT /admin/service/order ничего не заказывает и отдаёт эту услугу (как SHM с check_exists_unpaid,
+	// когда у пользователя уже есть неоплаченная).
+	orderReturns map[string]interface{}
+	// refundOnDelete — DELETE существующей услуги возвращает эту сумму на баланс и уменьшает на неё
+	// последнее списание по услуге (возврат SHM за неиспользованный период).
+	refundOnDelete float64
+	// keepLogin — POST /admin/user молча не меняет login (SHM не применил новое значение).
+	keepLogin bool
+	// dupPayKeys — PUT /admin/user/payment принимает повтор uniq_key (уникальность ключа в SHM не гарантирована).
+	dupPayKeys bool
+	// payNow — дата, которую PUT /admin/user/payment пишет в user/pay.date (по умолчанию time.Now).
+	payNow func() time.Time
+}
+
+func newFakeSHMUsers(t *testing.T, rowsJSON ...string) (*fakeSHMUsers, *api.APIClient) {
+	t.Helper()
+	f := &fakeSHMUsers{
+		rows:     map[int]map[string]interface{}{},
+		services: map[int][]interface{}{},
+		pays:     map[int][]interface{}{},
+		withdraw: map[int][]interface{}{},
+		catalog:  map[int]interface{}{},
+	}
+	for _, raw := range rowsJSON {
+		var row map[string]interface{}
+		if err := json.Unmarshal([]byte(raw), &row); err != nil {
+			t.Fatalf("row %s: %v", raw, err)
+		}
+		f.rows[int(row["user_id"].(float64))] = row
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if strings.HasPrefix(r.URL.Path, "/shm/v1/storage/manage/") {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = io.WriteString(w, `{}`)
+			return
+		}
+		switch r.URL.Path {
+		case "/shm/v1/admin/user":
+		case "/shm/v1/admin/service":
+			flt := decodeFilter(t, r.URL.Query().Get("filter"))
+			id, _ := flt["service_id"].(float64)
+			out := []interface{}{}
+			if row, ok := f.catalog[int(id)]; ok {
+				out = append(out, row)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": out})
+			return
+		case "/shm/v1/admin/user/payment":
+			var body map[string]interface{}
+			raw, _ := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+			if err := json.Unmarshal(raw, &body); err != nil {
+				t.Fatalf("payment body: %v", err)
+			}
+			uid := int(body["user_id"].(float64))
+			// uniq_key уникален во всей таблице платежей SHM, не только у пользователя.
+			for _, rows := range f.pays {
+				if f.dupPayKeys {
+					break
+				}
+				for _, row := range rows {
+					if k, _ := row.(map[string]interface{})["uniq_key"].(string); k != "" && k == body["uniq_key"] {
+						http.Error(w, "duplicate uniq_key", http.StatusConflict)
+						return
+					}
+				}
+			}
+			// id платежа в SHM сквозной по всем пользователям.
+			n := 0
+			for _, rows := range f.pays {
+				n += len(rows)
+			}
+			body["id"] = float64(n + 1)
+			payNow := time.Now
+			if f.payNow != nil {
+				payNow = f.payNow
+			}
+			body["date"] = payNow().In(time.FixedZone("MSK", 3*60*60)).Format("2006-01-02 15:04:05")
+			f.pays[uid] = append(f.pays[uid], body)
+			if row := f.rows[uid]; row != nil {
+				bal, _ := row["balance"].(float64)
+				row["balance"] = bal + body["money"].(float64)
+			}
+			w.WriteHeader(http.StatusOK)
+			return
+		case "/shm/v1/template/getUserBalance":
+			uid, _ := strconv.Atoi(r.URL.Query().Get("uid"))
+			bal := 0.0
+			if row := f.rows[uid]; row != nil {
+				bal, _ = row["balance"].(float64)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"user_id": uid, "balance": bal})
+			return
+		case "/shm/v1/admin/service/order":
+			if f.failOrders {
+				http.Error(w, "order failed", http.StatusInternalServerError)
+				return
+			}
+			var body map[string]interface{}
+			raw, _ := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+			if err := json.Unmarshal(raw, &body); err != nil {
+				t.Fatalf("order body: %v", err)
+			}
+			f.orders = append(f.orders, body)
+			uid := int(body["user_id"].(float64))
+			if f.orderReturns != nil {
+				w.Header().Set("Content-Type", "application/json")
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": []interface{}{f.orderReturns}})
+				return
+			}
+			if cat, ok := f.catalog[int(body["service_id"].(float64))].(map[string]interface{}); ok && f.chargeOrders {
+				bal, _ := f.rows[uid]["balance"].(float64)
+				f.rows[uid]["balance"] = bal - cat["cost"].(float64)
+			}
+			us := map[string]interface{}{
+				"user_service_id": float64(900 + len(f.orders)),
+				"service_id":      body["service_id"],
+				"user_id":         float64(uid),
+				"status":          "ACTIVE",
+			}
+			f.services[uid] = append(f.services[uid], us)
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": []interface{}{us}})
+			return
+		case "/shm/v1/admin/user/service/prolongate":
+			var body map[string]interface{}
+			raw, _ := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+			if err := json.Unmarshal(raw, &body); err != nil {
+				t.Fatalf("prolong body: %v", err)
+			}
+			f.prolongs++
+			us := f.userServiceLocked(int(body["user_id"].(float64)), body["user_service_id"].(float64))
+			if us == nil {
+				http.NotFound(w, r)
+				return
+			}
+			row := f.rows[int(body["user_id"].(float64))]
+			cost, _ := strconv.ParseFloat(fmt.Sprint(us["cost"]), 64)
+			bal, _ := row["balance"].(float64)
+			if bal < cost {
+				http.Error(w, "not enough money", http.StatusPaymentRequired)
+				return
+			}
+			row["balance"] = bal - cost
+			exp, _ := time.Parse("2006-01-02 15:04:05", fmt.Sprint(us["expire"]))
+			us["expire"] = exp.AddDate(0, 1, 0).Format("2006-01-02 15:04:05")
+			us["status"] = "ACTIVE"
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": []interface{}{us}})
+			return
+		case "/shm/v1/admin/user/service", "/shm/v1/admin/user/pay", "/shm/v1/admin/user/service/withdraw":
+			if r.Method == http.MethodPost && r.URL.Path == "/shm/v1/admin/user/service" {
+				var body map[string]interface{}
+				raw, _ := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+				if err := json.Unmarshal(raw, &body); err != nil {
+					t.Fatalf("user service body: %v", err)
+				}
+				us := f.userServiceLocked(int(body["user_id"].(float64)), body["user_service_id"].(float64))
+				if us == nil {
+					http.NotFound(w, r)
+					return
+				}
+				for k, v := range body {
+					us[k] = v
+				}
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+			if r.Method == http.MethodDelete {
+				uid, _ := strconv.Atoi(r.URL.Query().Get("user_id"))
+				usID := r.URL.Query().Get("user_service_id")
+				f.deleted = append(f.deleted, usID)
+				kept := f.services[uid][:0]
+				for _, row := range f.services[uid] {
+					if id, _ := row.(map[string]interface{})["user_service_id"].(float64); strconv.Itoa(int(id)) != usID {
+						kept = append(kept, row)
+					}
+				}
+				if len(kept) < len(f.services[uid]) && f.refundOnDelete > 0 {
+					bal, _ := f.rows[uid]["balance"].(float64)
+					f.rows[uid]["balance"] = bal + f.refundOnDelete
+					for i := len(f.withdraw[uid]) - 1; i >= 0; i-- {
+						w := f.withdraw[uid][i].(map[string]interface{})
+						if id, _ := w["user_service_id"].(float64); strconv.Itoa(int(id)) == usID {
+							w["total"] = w["total"].(float64) - f.refundOnDelete
+							break
+						}
+					}
+				}
+				f.services[uid] = kept
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+			flt := decodeFilter(t, r.URL.Query().Get("filter"))
+			if key, ok := flt["uniq_key"].(string); ok && r.URL.Path == "/shm/v1/admin/user/pay" {
+				out := []interface{}{}
+				for _, rows := range f.pays {
+					for _, row := range rows {
+						if k, _ := row.(map[string]interface{})["uniq_key"].(string); k == key {
+							out = append(out, row)
+						}
+					}
+				}
+				w.Header().Set("Content-Type", "application/json")
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": out})
+				return
+			}
+			if since, ok := flt["date"].(map[string]interface{}); ok && r.URL.Path == "/shm/v1/admin/user/pay" {
+				out := []interface{}{}
+				for uid, rows := range f.pays {
+					for _, row := range rows {
+						m := row.(map[string]interface{})
+						ps, _ := flt["pay_system_id"].(string)
+						if d, _ := m["date"].(string); d >= since[">="].(string) && (ps == "" || m["pay_system_id"] == ps) {
+							m["user_id"] = float64(uid)
+							out = append(out, m)
+						}
+					}
+				}
+				w.Header().Set("Content-Type", "application/json")
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": out})
+				return
+			}
+			id, _ := flt["user_id"].(float64)
+			src := f.services
+			switch r.URL.Path {
+			case "/shm/v1/admin/user/pay":
+				src = f.pays
+			case "/shm/v1/admin/user/service/withdraw":
+				src = f.withdraw
+			}
+			out := src[int(id)]
+			if usID, ok := flt["user_service_id"].(string); ok && r.URL.Path == "/shm/v1/admin/user/service" {
+				out = nil
+				for _, row := range src[int(id)] {
+					if v, _ := row.(map[string]interface{})["user_service_id"].(float64); strconv.Itoa(int(v)) == usID {
+						out = append(out, row)
+					}
+				}
+			}
+			if out == nil {
+				out = []interface{}{}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": out})
+			return
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			flt := decodeFilter(t, r.URL.Query().Get("filter"))
+			var out []map[string]interface{}
+			for _, row := range f.rows {
+				match := true
+				for k, v := range flt {
+					if k == "user_id" {
+						if id, _ := row["user_id"].(float64); id != v.(float64) {
+							match = false
+						}
+						continue
+					}
+					if got, _ := row[k].(string); got != v {
+						match = false
+					}
+				}
+				if match {
+					out = append(out, row)
+				}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": out})
+		case http.MethodPost, http.MethodPut:
+			raw, _ := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+			var body map[string]interface{}
+			if err := json.Unmarshal(raw, &body); err != nil {
+				t.Fatalf("update body: %v", err)
+			}
+			f.posts = append(f.posts, body)
+			id := int(body["user_id"].(float64))
+			row := f.rows[id]
+			if row == nil {
+				http.NotFound(w, r)
+				return
+			}
+			for k, v := range body {
+				if k != "user_id" && !(k == "login" && f.keepLogin) {
+					row[k] = v
+				}
+			}
+			w.WriteHeader(http.StatusOK)
+		default:
+			http.Error(w, "method", http.StatusMethodNotAllowed)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return f, &api.APIClient{ServerURL: srv.URL, HTTPClient: srv.Client()}
+}
+
+func (f *fakeSHMUsers) postCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.posts)
+}
+
+func (f *fakeSHMUsers) row(id int) map[string]interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rows[id]
+}
+
+// setRows задаёт ответ /admin/user/service (kind="service"), /admin/user/pay ("pay")
+// или /admin/user/service/withdraw ("withdraw") для userID.
+func (f *fakeSHMUsers) setRows(t *testing.T, kind string, userID int, arrayJSON string) {
+	t.Helper()
+	var rows []interface{}
+	if err := json.Unmarshal([]byte(arrayJSON), &rows); err != nil {
+		t.Fatalf("%s rows: %v", kind, err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch kind {
+	case "pay":
+		f.pays[userID] = rows
+	case "withdraw":
+		f.withdraw[userID] = rows
+	default:
+		f.services[userID] = rows
+	}
+}
+
+// setCatalog задаёт услугу каталога (GET /admin/service по service_id).
+func (f *fakeSHMUsers) setCatalog(t *testing.T, serviceJSON string) {
+	t.Helper()
+	var row map[string]interface{}
+	if err := json.Unmarshal([]byte(serviceJSON), &row); err != nil {
+		t.Fatalf("catalog row: %v", err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.catalog[int(row["service_id"].(float64))] = row
+}
+
+func (f *fakeSHMUsers) orderCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.orders)
+}
+
+func (f *fakeSHMUsers) payRows(userID int) []interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]interface{}(nil), f.pays[userID]...)
+}
+
+func (f *fakeSHMUsers) deletedServices() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.deleted...)
+}
+
+// userServiceLocked — строка /admin/user/service по user_id и user_service_id (f.mu уже захвачен).
+func (f *fakeSHMUsers) userServiceLocked(userID int, userServiceID float64) map[string]interface{} {
+	for _, row := range f.services[userID] {
+		if us := row.(map[string]interface{}); us["user_service_id"] == userServiceID {
+			return us
+		}
+	}
+	return nil
+}
+
+func (f *fakeSHMUsers) userService(userID int, userServiceID float64) map[string]interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.userServiceLocked(userID, userServiceID)
+}
+
+func (f *fakeSHMUsers) prolongCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.prolongs
+}
@@ -0,0 +1,569 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// giftsSettingsKey — settings.gifts покупателя: подарки, которые он оплатил.
+const giftsSettingsKey = "gifts"
+
+// Статус подарка. Каждый переход сохраняется в SHM до платежа или заказа, которые он начинает.
+const (
+	GiftDebiting    = "debiting"     // списываем стоимость с покупателя
+	GiftDebitFailed = "debit_failed" // списание не прошло, подарка нет
+	GiftPending     = "pending"      // оплачен, ждёт получателя
+	GiftClaiming    = "claiming"     // получатель найден, зачисляем ему стоимость
+	GiftOrdering    = "ordering"     // деньги у получателя, заказываем услугу
+	GiftClaimed     = "claimed"
+	GiftOrderFailed = "order_failed" // деньги у получателя, заказ не удался — повторяется при следующем нажатии
+	// GiftExistingUnpaid — деньги у получателя, но SHM вернул его ранее заказанную неоплаченную услугу
+	// (check_exists_unpaid) или новую в NOT PAID: подарок не оформлен, повтор не поможет — разбирает поддержка.
+	GiftExistingUnpaid = "existing_unpaid"
+	GiftRefunding      = "refunding"
+	GiftRefunded       = "refunded"
+)
+
+const (
+	// giftStartPrefix — start-параметр ссылки подарка: gift_<user_id покупателя>_<id>.
+	giftStartPrefix = "gift_"
+	// giftDebitGrace — сколько ждать ответа SHM по списанию, прежде чем выяснять его итог по uniq_key.
+	giftDebitGrace = 10 * time.Minute
+	// giftsRetention / giftsMax — сколько завершённых подарков хранить в settings.
+	giftsRetention = 90 * 24 * time.Hour
+	giftsMax       = 30
+)
+
+var (
+	// ErrGiftNotFound — подарка нет (или ссылка повреждена).
+	ErrGiftNotFound = errors.New("gift not found")
+	// ErrGiftExpired — срок забрать подарок вышел, деньги возвращаются покупателю.
+	ErrGiftExpired = errors.New("gift expired")
+	// ErrGiftTaken — подарок уже получил другой пользователь.
+	ErrGiftTaken = errors.New("gift already claimed")
+	// ErrGiftNotForYou — подарок адресован другому Telegram-пользователю.
+	ErrGiftNotForYou = errors.New("gift is addressed to another user")
+	// ErrGiftOwn — покупатель не может забрать собственный подарок.
+	ErrGiftOwn = errors.New("cannot claim own gift")
+	// ErrGiftInsufficientBalance — на балансе покупателя меньше стоимости услуги.
+	ErrGiftInsufficientBalance = errors.New("insufficient balance for gift")
+)
+
+// giftNow — текущее время (подменяется в тестах).
+var giftNow = time.Now
+
+// Gift — подарок услуги, оплаченный с баланса покупателя. Получатель задаётся заранее
+// (username или Telegram ID из контакта) либо не задаётся — тогда подарок заберёт первый по ссылке.
+type Gift struct {
+	ID                string    `json:"id"`
+	ServiceID         int       `json:"service_id"`
+	ServiceName       string    `json:"service_name"`
+	Amount            float64   `json:"amount"`
+	RecipientUsername string    `json:"recipient_username,omitempty"`
+	RecipientChatID   int64     `json:"recipient_chat_id,omitempty"`
+	Status            string    `json:"status"`
+	CreatedAt         time.Time `json:"created_at"`
+	ExpiresAt         time.Time `json:"expires_at"`
+	RecipientUserID   int       `json:"recipient_user_id,omitempty"`
+	UserServiceID     int       `json:"user_service_id,omitempty"`
+	ClaimedAt         string    `json:"claimed_at,omitempty"`
+	RefundedAt        string    `json:"refunded_at,omitempty"`
+}
+
+// Open — подарок ещё можно забрать или вернуть (деньги списаны, но никому не выданы).
+func (g Gift) Open() bool {
+	return g.Status == GiftPending || g.Status == GiftDebiting || g.Status == GiftRefunding
+}
+
+// GiftRequest — покупка подарка; TTL и PaySystem — из config.Gifts.
+type GiftRequest struct {
+	BuyerID           int
+	ServiceID         int
+	RecipientUsername string
+	RecipientChatID   int64
+	TTL               time.Duration
+	PaySystem         string
+}
+
+// GiftClaimRequest — получение подарка пользователем RecipientUserID (уже зарегистрированным в SHM).
+type GiftClaimRequest struct {
+	BuyerID           int
+	GiftID            string
+	RecipientUserID   int
+	RecipientUsername string
+	RecipientChatID   int64
+	PaySystem         string
+}
+
+// GiftStartParam — start-параметр ссылки t.me/<bot>?start=… для подарка.
+func GiftStartParam(buyerID int, giftID string) string {
+	return giftStartPrefix + strconv.Itoa(buyerID) + "_" + giftID
+}
+
+// ParseGiftStartParam разбирает start-параметр подарка; ok=false — это не ссылка подарка.
+func ParseGiftStartParam(payload string) (buyerID int, giftID string, ok bool) {
+	rest, found := strings.CutPrefix(strings.TrimSpace(payload), giftStartPrefix)
+	if !found {
+		return 0, "", false
+	}
+	idStr, giftID, found := strings.Cut(rest, "_")
+	buyerID, err := strconv.Atoi(idStr)
+	if !found || err != nil || buyerID <= 0 || giftID == "" {
+		return 0, "", false
+	}
+	return buyerID, giftID, true
+}
+
+// NormalizeTelegramUsername — username без @ в нижнем регистре (сравнение без учёта регистра, как в Telegram).
+func NormalizeTelegramUsername(v string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(v), "@"))
+}
+
+// CreateGift списывает стоимость услуги с баланса покупателя и сохраняет подарок в settings.gifts.
+// Услуга проверяется по категории бренда так же, как при заказе.
+func (s *Service) CreateGift(req GiftRequest) (*Gift, error) {
+	if req.BuyerID <= 0 || req.TTL <= 0 {
+		return nil, errors.New("invalid gift request")
+	}
+	if err := s.ensureServiceAllowedForOrder(req.ServiceID); err != nil {
+		return nil, err
+	}
+	svc, err := s.apiClient.GetServiceByID(req.ServiceID)
+	if err != nil {
+		return nil, fmt.Errorf("gift service lookup: %w", err)
+	}
+	if svc == nil || svc.Cost <= 0 {
+		return nil, &ServiceCategoryDeniedError{ServiceID: req.ServiceID}
+	}
+	id, err := newGiftID()
+	if err != nil {
+		return nil, err
+	}
+	now := giftNow().UTC()
+	g := Gift{
+		ID:                id,
+		ServiceID:         svc.ServiceID,
+		ServiceName:       strings.TrimSpace(svc.Name),
+		Amount:            math.Round(svc.Cost*100) / 100,
+		RecipientUsername: NormalizeTelegramUsername(req.RecipientUsername),
+		RecipientChatID:   req.RecipientChatID,
+		Status:            GiftDebiting,
+		CreatedAt:         now,
+		ExpiresAt:         now.Add(req.TTL),
+	}
+
+	s.giftMu.Lock()
+	defer s.giftMu.Unlock()
+	bal, err := s.apiClient.GetUserBalance(req.BuyerID)
+	if err != nil {
+		return nil, fmt.Errorf("gift balance: %w", err)
+	}
+	if bal == nil || bal.Balance+0.005 < g.Amount {
+		return nil, ErrGiftInsufficientBalance
+	}
+	list, err := s.loadGifts(req.BuyerID)
+	if err != nil {
+		return nil, err
+	}
+	list = append(list, g)
+	if err := s.saveGifts(req.BuyerID, list); err != nil {
+		return nil, err
+	}
+	i := len(list) - 1
+
+	err = s.giftPayment(req.BuyerID, -g.Amount, req.PaySystem, s.giftPayKey(g.ID, "debit"), map[string]interface{}{
+		"gift": g.ID, "service_id": g.ServiceID, "kind": "debit",
+	})
+	if err != nil {
+		list[i].Status = GiftDebitFailed
+		if saveErr := s.saveGifts(req.BuyerID, list); saveErr != nil {
+			slog.Error("gift: save record", "user_id", req.BuyerID, "gift", g.ID, "err", saveErr)
+		}
+		return nil, fmt.Errorf("gift debit: %w", err)
+	}
+	list[i].Status = GiftPending
+	if err := s.saveGifts(req.BuyerID, list); err != nil {
+		// Деньги уже списаны: запись «debiting» разрешится по uniq_key при получении или возврате.
+		slog.Error("gift: save record", "user_id", req.BuyerID, "gift", g.ID, "status", GiftPending, "err", err)
+	}
+	s.pendingGiftBuyers[req.BuyerID] = struct{}{}
+	slog.Info("gift created", "brand_id", s.activeBrandID(), "user_id", req.BuyerID, "gift", g.ID, "service_id", g.ServiceID, "amount", g.Amount)
+	out := list[i]
+	return &out, nil
+}
+
+// PeekGift — подарок покупателя buyerID без изменений (карточка подарка до нажатия «Получить»).
+func (s *Service) PeekGift(buyerID int, giftID string) (*Gift, error) {
+	if buyerID <= 0 {
+		return nil, ErrGiftNotFound
+	}
+	s.giftMu.Lock()
+	defer s.giftMu.Unlock()
+	list, err := s.loadGifts(buyerID)
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range list {
+		if g.ID == giftID && g.Status != GiftDebitFailed {
+			return &g, nil
+		}
+	}
+	return nil, ErrGiftNotFound
+}
+
+// ClaimGift выдаёт подарок получателю: зачисляет ему стоимость платежом с uniq_key подарка
+// и заказывает услугу. Повтор тем же получателем безопасен: зачисление не повторяется,
+// несостоявшийся заказ пробуется снова.
+func (s *Service) ClaimGift(req GiftClaimRequest) (*Gift, error) {
+	if req.BuyerID <= 0 || req.RecipientUserID <= 0 {
+		return nil, errors.New("invalid gift claim")
+	}
+	if req.RecipientUserID == req.BuyerID {
+		return nil, ErrGiftOwn
+	}
+	s.giftMu.Lock()
+	defer s.giftMu.Unlock()
+	list, err := s.loadGifts(req.BuyerID)
+	if err != nil {
+		return nil, err
+	}
+	i := giftIndex(list, req.GiftID)
+	if i < 0 {
+		return nil, ErrGiftNotFound
+	}
+	g := &list[i]
+	if g.RecipientUserID != 0 && g.RecipientUserID != req.RecipientUserID {
+		return nil, ErrGiftTaken
+	}
+	if g.Status == GiftDebiting {
+		if err := s.resolveGiftDebitLocked(g); err != nil {
+			return nil, err
+		}
+	}
+	switch g.Status {
+	case GiftClaimed, GiftOrdering, GiftExistingUnpaid:
+		out := *g
+		return &out, nil
+	case GiftClaiming, GiftOrderFailed:
+		// Продолжаем с места сбоя.
+	case GiftPending:
+		if !giftNow().Before(g.ExpiresAt) {
+			// Деньги вернёт ближайший проход возвратов.
+			s.pendingGiftBuyers[req.BuyerID] = struct{}{}
+			return nil, ErrGiftExpired
+		}
+		if !giftRecipientAllowed(*g, req) {
+			return nil, ErrGiftNotForYou
+		}
+		g.Status = GiftClaiming
+		g.RecipientUserID = req.RecipientUserID
+		g.ClaimedAt = giftNow().UTC().Format(time.RFC3339)
+		if err := s.saveGifts(req.BuyerID, list); err != nil {
+			return nil, err
+		}
+	case GiftRefunding, GiftRefunded:
+		return nil, ErrGiftExpired
+	default:
+		return nil, ErrGiftNotFound
+	}
+
+	if g.Status == GiftClaiming {
+		err := s.giftPayment(req.RecipientUserID, g.Amount, req.PaySystem, s.giftPayKey(g.ID, "claim"), map[string]interface{}{
+			"gift": g.ID, "service_id": g.ServiceID, "kind": "claim", "gifted_by": req.BuyerID,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("gift credit: %w", err)
+		}
+	}
+	// Отметка «ordering» до заказа: сбой между заказом и сохранением не приведёт ко второй услуге.
+	g.Status = GiftOrdering
+	if err := s.saveGifts(req.BuyerID, list); err != nil {
+		return nil, err
+	}
+	us, orderErr := s.ServiceOrderByUserID(req.RecipientUserID, g.ServiceID)
+	if orderErr != nil || us == nil {
+		slog.Error("gift: ServiceOrderByUserID", "user_id", req.RecipientUserID, "gift", g.ID, "service_id", g.ServiceID, "err", orderErr)
+		g.Status = GiftOrderFailed
+	} else if !orderedAsRequested(us, g.ServiceID) {
+		slog.Error("gift: order returned another or unpaid service", "user_id", req.RecipientUserID, "gift", g.ID, "service_id", g.ServiceID,
+			"returned_user_service_id", us.ServiceID, "returned_service_id", us.BaseServiceID, "status", us.Status)
+		g.Status, g.UserServiceID = GiftExistingUnpaid, us.ServiceID
+	} else {
+		g.Status, g.UserServiceID = GiftClaimed, us.ServiceID
+	}
+	if err := s.saveGifts(req.BuyerID, list); err != nil {
+		slog.Error("gift: save record", "user_id", req.BuyerID, "gift", g.ID, "status", g.Status, "err", err)
+	}
+	s.forgetGiftBuyerLocked(req.BuyerID, list)
+	slog.Info("gift claimed", "brand_id", s.activeBrandID(), "user_id", req.BuyerID, "gift", g.ID, "recipient_user_id", req.RecipientUserID, "status", g.Status)
+	out := *g
+	return &out, nil
+}
+
+// RefundExpiredGifts возвращает покупателю деньги за незабранные подарки с истёкшим сроком.
+// Возвращает подарки, возвращённые в этом проходе (для уведомления покупателя).
+func (s *Service) RefundExpiredGifts(buyerID int, paySystem string) ([]Gift, error) {
+	if buyerID <= 0 {
+		return nil, errors.New("invalid user id")
+	}
+	s.giftMu.Lock()
+	defer s.giftMu.Unlock()
+	list, err := s.loadGifts(buyerID)
+	if err != nil {
+		return nil, err
+	}
+	now := giftNow()
+	var refunded []Gift
+	for i := range list {
+		g := &list[i]
+		if g.Status == GiftDebiting && now.Sub(g.CreatedAt) >= giftDebitGrace {
+			if err := s.resolveGiftDebitLocked(g); err != nil {
+				return refunded, err
+			}
+			if err := s.saveGifts(buyerID, list); err != nil {
+				return refunded, err
+			}
+		}
+		if !(g.Status == GiftPending && !now.Before(g.ExpiresAt)) && g.Status != GiftRefunding {
+			continue
+		}
+		g.Status = GiftRefunding
+		if err := s.saveGifts(buyerID, list); err != nil {
+			return refunded, err
+		}
+		err := s.giftPayment(buyerID, g.Amount, paySystem, s.giftPayKey(g.ID, "refund"), map[string]interface{}{
+			"gift": g.ID, "service_id": g.ServiceID, "kind": "refund",
+		})
+		if err != nil {
+			return refunded, fmt.Errorf("gift refund: %w", err)
+		}
+		g.Status = GiftRefunded
+		g.RefundedAt = now.UTC().Format(time.RFC3339)
+		if err := s.saveGifts(buyerID, list); err != nil {
+			slog.Error("gift: save record", "user_id", buyerID, "gift", g.ID, "status", GiftRefunded, "err", err)
+		}
+		slog.Info("gift refunded", "brand_id", s.activeBrandID(), "user_id", buyerID, "gift", g.ID, "amount", g.Amount)
+		refunded = append(refunded, *g)
+	}
+	s.forgetGiftBuyerLocked(buyerID, list)
+	return refunded, nil
+}
+
+// UserGifts — подарки покупателя, новые сначала. Открытые подарки снова попадают в очередь возвратов.
+func (s *Service) UserGifts(buyerID int) ([]Gift, error) {
+	if buyerID <= 0 {
+		return nil, errors.New("invalid user id")
+	}
+	s.giftMu.Lock()
+	defer s.giftMu.Unlock()
+	list, err := s.loadGifts(buyerID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Gift, 0, len(list))
+	for i := len(list) - 1; i >= 0; i-- {
+		if list[i].Status == GiftDebitFailed {
+			continue
+		}
+		if list[i].Open() {
+			s.pendingGiftBuyers[buyerID] = struct{}{}
+		}
+		out = append(out, list[i])
+	}
+	return out, nil
+}
+
+// PendingGiftBuyers — покупатели с открытыми подарками: созданными в этом процессе или найденными DiscoverGiftBuyers.
+func (s *Service) PendingGiftBuyers() []int {
+	s.giftMu.Lock()
+	defer s.giftMu.Unlock()
+	out := make([]int, 0, len(s.pendingGiftBuyers))
+	for id := range s.pendingGiftBuyers {
+		out = append(out, id)
+	}
+	sort.Ints(out)
+	return out
+}
+
+// giftDiscoveryMargin — сколько после срока подарка ещё искать его списание: возврат мог не пройти сразу.
+const giftDiscoveryMargin = 7 * 24 * time.Hour
+
+// DiscoverGiftBuyers восстанавливает очередь возвратов после рестарта: кандидаты — списания подарков бренда
+// (uniq_key gift:<бренд>:<id>:debit, pay_system_id paySystem) за срок ttl плюс неделя, открытость подарков
+// проверяется по settings.gifts покупателя. Успешный проход выполняется один раз за процесс — дальше очередь
+// пополняют CreateGift, ClaimGift и UserGifts.
+func (s *Service) DiscoverGiftBuyers(paySystem string, ttl time.Duration) error {
+	s.giftMu.Lock()
+	done := s.giftBuyersDiscovered
+	s.giftMu.Unlock()
+	if done {
+		return nil
+	}
+	if strings.TrimSpace(paySystem) == "" {
+		paySystem = "gift"
+	}
+	since := giftNow().Add(-ttl - giftDiscoveryMargin).In(shmMoscow).Format(shmPayDateLayout)
+	pays, err := s.apiClient.ListUserPaysSince(since, paySystem)
+	if err != nil {
+		return err
+	}
+	prefix := "gift:" + s.activeBrandID() + ":"
+	buyers := map[int]struct{}{}
+	for _, p := range pays {
+		if p.Money < 0 && strings.HasPrefix(p.UniqKey, prefix) && strings.HasSuffix(p.UniqKey, ":debit") {
+			buyers[p.UserID] = struct{}{}
+		}
+	}
+	ids := make([]int, 0, len(buyers))
+	for id := range buyers {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	s.giftMu.Lock()
+	defer s.giftMu.Unlock()
+	for _, buyerID := range ids {
+		list, err := s.loadGifts(buyerID)
+		if err != nil {
+			return fmt.Errorf("user %d: %w", buyerID, err)
+		}
+		for _, g := range list {
+			if g.Open() {
+				s.pendingGiftBuyers[buyerID] = struct{}{}
+				break
+			}
+		}
+	}
+	s.giftBuyersDiscovered = true
+	return nil
+}
+
+func (s *Service) forgetGiftBuyerLocked(buyerID int, list []Gift) {
+	for _, g := range list {
+		if g.Open() {
+			return
+		}
+	}
+	delete(s.pendingGiftBuyers, buyerID)
+}
+
+// resolveGiftDebitLocked — итог списания, ответ на которое потерян: платёж с uniq_key есть → pending.
+func (s *Service) resolveGiftDebitLocked(g *Gift) error {
+	p, err := s.apiClient.FindUserPayByUniqKey(s.giftPayKey(g.ID, "debit"))
+	if err != nil {
+		return err
+	}
+	if p != nil {
+		g.Status = GiftPending
+	} else {
+		g.Status = GiftDebitFailed
+	}
+	return nil
+}
+
+func giftRecipientAllowed(g Gift, req GiftClaimRequest) bool {
+	if g.RecipientChatID != 0 {
+		return g.RecipientChatID == req.RecipientChatID
+	}
+	if g.RecipientUsername != "" {
+		return g.RecipientUsername == NormalizeTelegramUsername(req.RecipientUsername)
+	}
+	return true
+}
+
+func giftIndex(list []Gift, id string) int {
+	for i := range list {
+		if list[i].ID == id && id != "" {
+			return i
+		}
+	}
+	return -1
+}
+
+// giftPayKey — uniq_key платежа подарка: gift:<brand>:<id>:debit|claim|refund.
+func (s *Service) giftPayKey(giftID, kind string) string {
+	return "gift:" + s.activeBrandID() + ":" + giftID + ":" + kind
+}
+
+// giftPayment проводит платёж с uniq_key один раз: money < 0 — списание, > 0 — зачисление.
+// Если ответ SHM потерян, итог выясняется поиском платежа по ключу.
+func (s *Service) giftPayment(userID int, money float64, paySystem, key string, comment map[string]interface{}) error {
+	if p, err := s.apiClient.FindUserPayByUniqKey(key); err != nil || p != nil {
+		return err
+	}
+	if strings.TrimSpace(paySystem) == "" {
+		paySystem = "gift"
+	}
+	var err error
+	if money < 0 {
+		err = s.apiClient.DebitUserBalance(userID, -money, paySystem, key, comment)
+	} else {
+		err = s.apiClient.AddUserPayment(userID, money, paySystem, key, comment)
+	}
+	if err == nil {
+		return nil
+	}
+	if p, findErr := s.apiClient.FindUserPayByUniqKey(key); findErr == nil && p != nil {
+		return nil
+	}
+	return err
+}
+
+func (s *Service) loadGifts(userID int) ([]Gift, error) {
+	settingsObj, err := s.loadSettingsMap(userID)
+	if err != nil {
+		return nil, err
+	}
+	var list []Gift
+	if raw, ok := settingsObj[giftsSettingsKey]; ok && raw != nil {
+		b, err := json.Marshal(raw)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(b, &list); err != nil {
+			// Здесь хранятся деньги покупателя — повреждённый список не перезаписываем.
+			return nil, fmt.Errorf("settings.gifts: %w", err)
+		}
+	}
+	return list, nil
+}
+
+// saveGifts пишет settings.gifts; завершённые подарки старше 90 дней и сверх 30 последних отбрасываются,
+// открытые хранятся всегда.
+func (s *Service) saveGifts(userID int, list []Gift) error {
+	cutoff := giftNow().Add(-giftsRetention)
+	closed := 0
+	for _, g := range list {
+		if !g.Open() && g.Status != GiftClaiming && g.Status != GiftOrdering {
+			closed++
+		}
+	}
+	kept := make([]Gift, 0, len(list))
+	for _, g := range list {
+		if !g.Open() && g.Status != GiftClaiming && g.Status != GiftOrdering {
+			if closed > giftsMax || g.CreatedAt.Before(cutoff) {
+				closed--
+				continue
+			}
+		}
+		kept = append(kept, g)
+	}
+	return s.saveSettingsKey(userID, giftsSettingsKey, kept)
+}
+
+func newGiftID() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)), nil
+}
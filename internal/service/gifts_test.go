@@ -0,0 +1,192 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func stubGiftNow(t *testing.T, now *time.Time) {
+	t.Helper()
+	prev := giftNow
+	giftNow = func() time.Time { return *now }
+	t.Cleanup(func() { giftNow = prev })
+}
+
+func giftTestService(t *testing.T, buyerBalance float64) (*fakeSHMUsers, *Service) {
+	t.Helper()
+	fake, client := newFakeSHMUsers(t,
+		`{"user_id":42,"login":"@42","settings":{}}`,
+		`{"user_id":43,"login":"@43","settings":{}}`,
+		`{"user_id":44,"login":"@44","settings":{}}`,
+	)
+	fake.rows[42]["balance"] = buyerBalance
+	fake.setCatalog(t, orderIntentCatalogJSON)
+	return fake, NewService(client, orderBrandCfg("vff", "vpn-mz-test"))
+}
+
+func TestCreateGift_DebitsBuyerOnce(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	stubGiftNow(t, &now)
+	fake, s := giftTestService(t, 300)
+
+	req := GiftRequest{BuyerID: 42, ServiceID: 7, RecipientUsername: "@Friend", TTL: 72 * time.Hour, PaySystem: "gift"}
+	if _, err := s.CreateGift(req); !errors.Is(err, ErrGiftInsufficientBalance) || len(fake.payRows(42)) != 0 {
+		t.Fatalf("err=%v pays=%v", err, fake.payRows(42))
+	}
+	fake.rows[42]["balance"] = 1000.0
+	g, err := s.CreateGift(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Status != GiftPending || g.Amount != 450 || g.RecipientUsername != "friend" || !g.ExpiresAt.Equal(now.Add(72*time.Hour)) {
+		t.Fatalf("%+v", g)
+	}
+	pays := fake.payRows(42)
+	if len(pays) != 1 || pays[0].(map[string]interface{})["money"] != -450.0 || pays[0].(map[string]interface{})["uniq_key"] != "gift:vff:"+g.ID+":debit" {
+		t.Fatalf("%+v", pays)
+	}
+	if got := s.PendingGiftBuyers(); len(got) != 1 || got[0] != 42 {
+		t.Fatalf("pending %v", got)
+	}
+	if buyer, id, ok := ParseGiftStartParam(GiftStartParam(42, g.ID)); !ok || buyer != 42 || id != g.ID {
+		t.Fatalf("start param %d %q %v", buyer, id, ok)
+	}
+}
+
+func TestClaimGift_OrdersForRecipientOnce(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	stubGiftNow(t, &now)
+	fake, s := giftTestService(t, 1000)
+	g, err := s.CreateGift(GiftRequest{BuyerID: 42, ServiceID: 7, RecipientUsername: "friend", TTL: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claim := GiftClaimRequest{BuyerID: 42, GiftID: g.ID, RecipientUserID: 43, RecipientUsername: "Friend", RecipientChatID: 43}
+	for _, tc := range []struct {
+		req  GiftClaimRequest
+		want error
+	}{
+		{GiftClaimRequest{BuyerID: 42, GiftID: g.ID, RecipientUserID: 42, RecipientUsername: "friend"}, ErrGiftOwn},
+		{GiftClaimRequest{BuyerID: 42, GiftID: g.ID, RecipientUserID: 44, RecipientUsername: "stranger"}, ErrGiftNotForYou},
+		{GiftClaimRequest{BuyerID: 42, GiftID: "nope", RecipientUserID: 43}, ErrGiftNotFound},
+	} {
+		if _, err := s.ClaimGift(tc.req); !errors.Is(err, tc.want) {
+			t.Fatalf("%+v: %v", tc.req, err)
+		}
+	}
+
+	fake.failOrders = true
+	got, err := s.ClaimGift(claim)
+	if err != nil || got.Status != GiftOrderFailed || fake.orderCount() != 0 {
+		t.Fatalf("%+v %v", got, err)
+	}
+	fake.failOrders = false
+	got, err = s.ClaimGift(claim)
+	if err != nil || got.Status != GiftClaimed || got.UserServiceID != 901 || got.RecipientUserID != 43 {
+		t.Fatalf("%+v %v", got, err)
+	}
+	if got, err := s.ClaimGift(claim); err != nil || got.Status != GiftClaimed || fake.orderCount() != 1 {
+		t.Fatalf("repeat claim must not order again: %+v %v orders=%d", got, err, fake.orderCount())
+	}
+	if pays := fake.payRows(43); len(pays) != 1 || pays[0].(map[string]interface{})["money"] != 450.0 {
+		t.Fatalf("recipient pays %+v", pays)
+	}
+	if _, err := s.ClaimGift(GiftClaimRequest{BuyerID: 42, GiftID: g.ID, RecipientUserID: 44, RecipientUsername: "friend"}); !errors.Is(err, ErrGiftTaken) {
+		t.Fatalf("err=%v", err)
+	}
+	if len(s.PendingGiftBuyers()) != 0 {
+		t.Fatal("claimed gift must leave the refund queue")
+	}
+}
+
+func TestClaimGift_ExistingUnpaidServiceIsNotClaimed(t *testing.T) {
+	fake, s := giftTestService(t, 1000)
+	g, err := s.CreateGift(GiftRequest{BuyerID: 42, ServiceID: 7, TTL: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.orderReturns = map[string]interface{}{"user_service_id": 777.0, "service_id": 3.0, "user_id": 43.0, "status": "NOT PAID"}
+	claim := GiftClaimRequest{BuyerID: 42, GiftID: g.ID, RecipientUserID: 43, RecipientChatID: 43}
+	got, err := s.ClaimGift(claim)
+	if err != nil || got.Status != GiftExistingUnpaid || got.UserServiceID != 777 {
+		t.Fatalf("%+v %v", got, err)
+	}
+	if got, err := s.ClaimGift(claim); err != nil || got.Status != GiftExistingUnpaid || fake.orderCount() != 1 {
+		t.Fatalf("repeat must not order again: %+v %v orders=%d", got, err, fake.orderCount())
+	}
+}
+
+func TestRefundExpiredGifts(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	stubGiftNow(t, &now)
+	fake, s := giftTestService(t, 1000)
+	g, err := s.CreateGift(GiftRequest{BuyerID: 42, ServiceID: 7, TTL: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refunded, err := s.RefundExpiredGifts(42, "gift"); err != nil || len(refunded) != 0 {
+		t.Fatalf("early refund %+v %v", refunded, err)
+	}
+
+	now = now.Add(time.Hour)
+	if _, err := s.ClaimGift(GiftClaimRequest{BuyerID: 42, GiftID: g.ID, RecipientUserID: 43}); !errors.Is(err, ErrGiftExpired) {
+		t.Fatalf("err=%v", err)
+	}
+	refunded, err := s.RefundExpiredGifts(42, "gift")
+	if err != nil || len(refunded) != 1 || refunded[0].Status != GiftRefunded {
+		t.Fatalf("%+v %v", refunded, err)
+	}
+	if again, err := s.RefundExpiredGifts(42, "gift"); err != nil || len(again) != 0 {
+		t.Fatalf("second pass %+v %v", again, err)
+	}
+	if bal := fake.row(42)["balance"]; bal != 1000.0 {
+		t.Fatalf("balance after refund %v", bal)
+	}
+	if len(s.PendingGiftBuyers()) != 0 || fake.orderCount() != 0 {
+		t.Fatal("refunded gift must leave the queue without orders")
+	}
+	list, err := s.UserGifts(42)
+	if err != nil || len(list) != 1 || list[0].RefundedAt == "" {
+		t.Fatalf("%+v %v", list, err)
+	}
+}
+
+func TestDiscoverGiftBuyers_RefundsAfterRestart(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	stubGiftNow(t, &now)
+	fake, before := giftTestService(t, 1000)
+	fake.payNow = func() time.Time { return now }
+	g, err := before.CreateGift(GiftRequest{BuyerID: 42, ServiceID: 7, TTL: time.Hour, PaySystem: "gift"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Списание чужого бренда и обычное пополнение покупателями подарков не делают.
+	if err := before.apiClient.DebitUserBalance(43, 10, "gift", "gift:other:x:debit", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Рестарт: очередь возвратов в памяти пуста.
+	now = now.Add(2 * time.Hour)
+	s := NewService(before.apiClient, orderBrandCfg("vff", "vpn-mz-test"))
+	if err := s.DiscoverGiftBuyers("gift", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.PendingGiftBuyers(); len(got) != 1 || got[0] != 42 {
+		t.Fatalf("pending after restart %v", got)
+	}
+	refunded, err := s.RefundExpiredGifts(42, "gift")
+	if err != nil || len(refunded) != 1 || refunded[0].ID != g.ID {
+		t.Fatalf("%+v %v", refunded, err)
+	}
+	if bal := fake.row(42)["balance"]; bal != 1000.0 {
+		t.Fatalf("balance after refund %v", bal)
+	}
+
+	// Следующий рестарт возвращённый подарок в очередь не поднимает.
+	again := NewService(before.apiClient, orderBrandCfg("vff", "vpn-mz-test"))
+	if err := again.DiscoverGiftBuyers("gift", time.Hour); err != nil || len(again.PendingGiftBuyers()) != 0 {
+		t.Fatalf("%v %v", err, again.PendingGiftBuyers())
+	}
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/models"
+)
+
+// Виды записей журнала баланса.
+const (
+	LedgerPay      = "pay"
+	LedgerWithdraw = "withdraw"
+)
+
+// LedgerEntry — движение по балансу: поступление (+) или списание за услугу (−).
+type LedgerEntry struct {
+	Kind   string
+	At     time.Time
+	Amount float64
+	// BalanceAfter — баланс после операции; считается назад от текущего баланса SHM.
+	BalanceAfter float64
+	Pay          *models.UserPay
+	Withdrawal   *models.WithdrawItem
+}
+
+// Ledger — журнал баланса (новые записи сначала).
+type Ledger struct {
+	Balance float64
+	Entries []LedgerEntry
+}
+
+// UserWithdrawals — страница списаний пользователя (новые сначала) и их общее число.
+// Сортировка по дате делается здесь: порядок строк SHM не гарантирован.
+func (s *Service) UserWithdrawals(userID, limit, offset int) ([]models.WithdrawItem, int, error) {
+	_, _, withdrawals, err := s.moneyHistory(userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	sort.SliceStable(withdrawals, func(i, j int) bool { return withdrawals[i].WithdrawnAt.After(withdrawals[j].WithdrawnAt) })
+	total := len(withdrawals)
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > total {
+		offset = total
+	}
+	end := total
+	if limit > 0 && offset+limit < total {
+		end = offset + limit
+	}
+	out := make([]models.WithdrawItem, 0, end-offset)
+	for _, w := range withdrawals[offset:end] {
+		out = append(out, w.Item)
+	}
+	return out, total, nil
+}
+
+// UserLedger объединяет платежи и списания в одну шкалу с балансом после каждой операции.
+// Баланс восстанавливается назад от текущего: последняя запись совпадает с балансом в SHM,
+// а ручные корректировки и бонусы вне журнала сдвигают только более ранние строки.
+func (s *Service) UserLedger(userID int) (*Ledger, error) {
+	u, pays, withdrawals, err := s.moneyHistory(userID)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]LedgerEntry, 0, len(pays)+len(withdrawals))
+	for i := range pays {
+		entries = append(entries, LedgerEntry{Kind: LedgerPay, At: pays[i].PaidAt, Amount: pays[i].Pay.Money, Pay: &pays[i].Pay})
+	}
+	for i := range withdrawals {
+		entries = append(entries, LedgerEntry{Kind: LedgerWithdraw, At: withdrawals[i].WithdrawnAt, Amount: -withdrawals[i].Item.Total, Withdrawal: &withdrawals[i].Item})
+	}
+	// Новые сначала; при равном времени поступление считается раньше списания, которое оно оплатило.
+	sort.SliceStable(entries, func(i, j int) bool {
+		if !entries[i].At.Equal(entries[j].At) {
+			return entries[i].At.After(entries[j].At)
+		}
+		return entries[i].Kind == LedgerWithdraw && entries[j].Kind == LedgerPay
+	})
+	balance := u.Balance
+	for i := range entries {
+		entries[i].BalanceAfter = math.Round(balance*100) / 100
+		balance -= entries[i].Amount
+	}
+	return &Ledger{Balance: u.Balance, Entries: entries}, nil
+}
@@ -0,0 +1,53 @@
+package service
+
+import "testing"
+
+func newLedgerFixture(t *testing.T) *Service {
+	t.Helper()
+	fake, client := newFakeSHMUsers(t, `{"user_id":42,"login":"@42","balance":101,"settings":{}}`)
+	fake.setRows(t, "pay", 42, `[{"id":5,"user_id":42,"money":300,"date":"2026-10-01 12:00:00"},{"id":6,"user_id":42,"money":100,"date":"2026-10-05 12:00:00"}]`)
+	fake.setRows(t, "withdraw", 42, `[{"withdraw_id":2,"user_id":42,"name":"VPN","total":199,"bonus":50,"withdraw_date":"2026-10-03 12:00:00"},{"withdraw_id":1,"user_id":42,"name":"VPN","total":100,"withdraw_date":"2026-10-01 12:00:00"},{"withdraw_id":3,"user_id":42,"name":"bad date","total":1,"withdraw_date":"?"}]`)
+	return NewService(client, testServiceBrand())
+}
+
+func TestUserWithdrawals_NewestFirstPaged(t *testing.T) {
+	s := newLedgerFixture(t)
+	page, total, err := s.UserWithdrawals(42, 1, 0)
+	if err != nil || total != 2 || len(page) != 1 || page[0].WithdrawID != 2 || page[0].Bonus != 50 {
+		t.Fatalf("%+v total=%d %v", page, total, err)
+	}
+	page, _, _ = s.UserWithdrawals(42, 1, 1)
+	if len(page) != 1 || page[0].WithdrawID != 1 {
+		t.Fatalf("page 2: %+v", page)
+	}
+	if page, _, _ := s.UserWithdrawals(42, 10, 5); len(page) != 0 {
+		t.Fatalf("past end: %+v", page)
+	}
+}
+
+func TestUserLedger_RunningBalance(t *testing.T) {
+	s := newLedgerFixture(t)
+	l, err := s.UserLedger(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		kind    string
+		amount  float64
+		balance float64
+	}{
+		{LedgerPay, 100, 101},
+		{LedgerWithdraw, -199, 1},
+		{LedgerWithdraw, -100, 200},
+		{LedgerPay, 300, 300},
+	}
+	if len(l.Entries) != len(want) {
+		t.Fatalf("%+v", l.Entries)
+	}
+	for i, w := range want {
+		e := l.Entries[i]
+		if e.Kind != w.kind || e.Amount != w.amount || e.BalanceAfter != w.balance {
+			t.Fatalf("entry %d: %+v want %+v", i, e, w)
+		}
+	}
+}
@@ -9,3 +9,19 @@ var (
 	ErrWebLogin2NotPersisted      = errors.New("web_login2_not_persisted")
 	ErrTelegramChatMismatch       = errors.New("telegram_chat_mismatch")
 )
+
+// Ошибки обратной привязки Telegram к web-пользователю (deep link из кабинета).
+var (
+	ErrTelegramAlreadyLinked      = errors.New("telegram_already_linked")
+	ErrTelegramUsedByOtherAccount = errors.New("telegram_used_by_other_account")
+	ErrTelegramLogin2NotPersisted = errors.New("telegram_login2_not_persisted")
+	ErrWebUserEmailMissing        = errors.New("web_user_email_missing")
+)
+
+// Ошибки смены и отвязки web-email (личный кабинет).
+var (
+	ErrWebEmailUnchanged       = errors.New("web_email_unchanged")
+	ErrWebEmailNotLinked       = errors.New("web_email_not_linked")
+	ErrWebEmailUnlinkPrimary   = errors.New("web_email_unlink_primary")
+	ErrWebEmailLoginNotUpdated = errors.New("web_email_login_not_updated")
+)
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/ryabkov82/vpnbot/internal/infrastructure/api"
+	"github.com/ryabkov82/vpnbot/internal/models"
+)
+
+// telegramLinkConflictError классифицирует занятость канонического Telegram login (login/login2) при
+// обратной привязке: same userID → nil; тот же бренд → ErrTelegramUsedByOtherAccount; иначе mismatch.
+func telegramLinkConflictError(found *models.User, selfUserID int, activeBrandID, canonicalTG string) error {
+	if found == nil || found.ID == selfUserID {
+		return nil
+	}
+	if userBelongsToBrand(found, activeBrandID, canonicalTG) {
+		return ErrTelegramUsedByOtherAccount
+	}
+	logIdentityMismatch("telegram_login_taken", found.Login, strings.TrimSpace(found.Settings.BrandID), 0, found.Settings.Telegram.ChatID)
+	return ErrUserIdentityMismatch
+}
+
+// LinkTelegramForWebUser записывает settings.telegram на web-пользователя SHM и выставляет
+// login2=<канонический Telegram login>, чтобы бот находил этот аккаунт по chat id.
+// Fail-closed: уже существующий Telegram-пользователь для этого chat id, занятый login2 или другая
+// привязка не перезаписываются — нужна ручная обработка поддержкой.
+func (s *Service) LinkTelegramForWebUser(userID int, tg models.TelegramInfo) (*models.User, error) {
+	if userID <= 0 {
+		return nil, errors.New("invalid user id")
+	}
+	chatID := tg.ChatID
+	if chatID <= 0 {
+		return nil, errors.New("invalid telegram chat id")
+	}
+	brandID := s.activeBrandID()
+	if brandID == "" {
+		return nil, ErrActiveBrandIDRequired
+	}
+
+	uVerify, err := s.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if uVerify == nil {
+		return nil, ErrUserNotFound
+	}
+	email := strings.TrimSpace(uVerify.Settings.Web.Email)
+	if email == "" {
+		return nil, ErrWebUserEmailMissing
+	}
+	webLogin, err := canonicalWebLoginFromEmail(email, s.webLoginPrefix())
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureWebUserMembership(uVerify, brandID, webLogin); err != nil {
+		return nil, err
+	}
+
+	canonicalTG := telegramSHMLogin(brandID, chatID)
+	if prev := uVerify.Settings.Telegram.ChatID; prev > 0 {
+		if prev != chatID {
+			return nil, ErrTelegramAlreadyLinked
+		}
+		if strings.TrimSpace(uVerify.Login) == canonicalTG || strings.TrimSpace(uVerify.Login2) == canonicalTG {
+			return uVerify, nil
+		}
+	}
+	if l2 := strings.TrimSpace(uVerify.Login2); l2 != "" && l2 != canonicalTG {
+		return nil, ErrTelegramAlreadyLinked
+	}
+
+	byLogin, err := s.apiClient.GetUserByLogin(canonicalTG)
+	if err != nil {
+		return nil, err
+	}
+	if err := telegramLinkConflictError(byLogin, userID, brandID, canonicalTG); err != nil {
+		return nil, err
+	}
+	byLogin2, err := s.apiClient.GetUserByLogin2(canonicalTG)
+	if err != nil {
+		return nil, err
+	}
+	if byLogin2 != nil && byLogin2.ID != userID {
+		if webUserBelongsToBrand(byLogin2, brandID, canonicalWebLoginOrEmpty(byLogin2, s.webLoginPrefix())) {
+			return nil, ErrTelegramUsedByOtherAccount
+		}
+		logIdentityMismatch("telegram_login2_taken", byLogin2.Login, strings.TrimSpace(byLogin2.Settings.BrandID), chatID, byLogin2.Settings.Telegram.ChatID)
+		return nil, ErrUserIdentityMismatch
+	}
+
+	// Settings пишутся целиком: читаем их под общим замком settings, как saveSettingsKey.
+	mu := s.settingsLocks.forUser(userID)
+	mu.Lock()
+	defer mu.Unlock()
+	loginSHM, rawSettings, err := s.apiClient.FetchAdminUserRowRaw(userID)
+	if err != nil {
+		return nil, err
+	}
+	if loginSHM == "" && (len(rawSettings) == 0 || string(rawSettings) == "null") {
+		return nil, ErrUserNotFound
+	}
+	settingsObj, err := mergeSettingsJSONToMap(rawSettings)
+	if err != nil {
+		return nil, err
+	}
+
+	var tgBlock map[string]interface{}
+	switch t := settingsObj["telegram"].(type) {
+	case map[string]interface{}:
+		tgBlock = t
+	default:
+		tgBlock = map[string]interface{}{}
+	}
+	if prev, ok := tgBlock["chat_id"].(float64); ok && prev > 0 && int64(prev) != chatID {
+		return nil, ErrTelegramAlreadyLinked
+	}
+	tgBlock["chat_id"] = chatID
+	tgBlock["user_id"] = tg.UserID
+	tgBlock["username"] = tg.Username
+	tgBlock["login"] = tg.Login
+	tgBlock["first_name"] = tg.FirstName
+	tgBlock["last_name"] = tg.LastName
+	tgBlock["language_code"] = tg.LanguageCode
+	tgBlock["is_premium"] = tg.IsPremium
+	settingsObj["telegram"] = tgBlock
+	settingsObj["brand_id"] = brandID
+
+	updated, err := s.apiClient.PostAdminUserUpdateSettings(userID, canonicalTG, settingsObj)
+	if err != nil {
+		if errors.Is(err, api.ErrLogin2NotPersistedSHM) {
+			return nil, ErrTelegramLogin2NotPersisted
+		}
+		return nil, err
+	}
+	if updated == nil {
+		return nil, errors.New("link telegram: shm update returned empty user")
+	}
+	if strings.TrimSpace(updated.Login) == "" {
+		updated.Login = loginSHM
+	}
+	if updated.Settings.Telegram.ChatID == 0 {
+		updated.Settings.Telegram = tg
+	}
+	if strings.TrimSpace(updated.Settings.BrandID) == "" {
+		updated.Settings.BrandID = brandID
+	}
+	return updated, nil
+}
+
+// canonicalWebLoginOrEmpty — канонический web login по settings.web.email пользователя (пусто, если email нет).
+func canonicalWebLoginOrEmpty(u *models.User, prefix string) string {
+	if u == nil || strings.TrimSpace(u.Settings.Web.Email) == "" {
+		return ""
+	}
+	login, err := canonicalWebLoginFromEmail(u.Settings.Web.Email, prefix)
+	if err != nil {
+		return ""
+	}
+	return login
+}
+
+// getTelegramLinkedWebUser — web-пользователь, к которому привязан Telegram через login2=<канонический login>.
+func (s *Service) getTelegramLinkedWebUser(brandID string, chatID int64, canonicalTG string) (*models.User, error) {
+	user, err := s.apiClient.GetUserByLogin2(canonicalTG)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, nil
+	}
+	if user.Settings.Telegram.ChatID != chatID {
+		logIdentityMismatch("telegram_chat_id", user.Login, strings.TrimSpace(user.Settings.BrandID), chatID, user.Settings.Telegram.ChatID)
+		return nil, ErrUserIdentityMismatch
+	}
+	if !webUserBelongsToBrand(user, brandID, canonicalWebLoginOrEmpty(user, s.webLoginPrefix())) {
+		logIdentityMismatch("web_brand_id", user.Login, strings.TrimSpace(user.Settings.BrandID), chatID, user.Settings.Telegram.ChatID)
+		return nil, ErrUserIdentityMismatch
+	}
+	return user, nil
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ryabkov82/vpnbot/internal/models"
+	"github.com/ryabkov82/vpnbot/internal/webuser"
+)
+
+func webLoginFor(t *testing.T, email string) string {
+	t.Helper()
+	l, err := webuser.WebLoginFromEmailWithPrefix(email, "web_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestLinkTelegramForWebUser_Success(t *testing.T) {
+	wl := webLoginFor(t, "web@tg.test")
+	fake, acl := newFakeSHMUsers(t,
+		`{"user_id":50,"login":"`+wl+`","settings":{"brand_id":"vff","web":{"email":"web@tg.test","source":"google"},"attribution":{"v":1}}}`,
+	)
+	svc := NewService(acl, testServiceBrand())
+	got, err := svc.LinkTelegramForWebUser(50, models.TelegramInfo{ChatID: 777, Username: "friend", UserID: "777"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.Login2 != "@777" || got.Settings.Telegram.ChatID != 777 {
+		t.Fatalf("got %#v", got)
+	}
+	row := fake.row(50)
+	st := row["settings"].(map[string]interface{})
+	if st["attribution"] == nil || st["web"] == nil {
+		t.Fatalf("settings not preserved: %#v", st)
+	}
+	if tg := st["telegram"].(map[string]interface{}); tg["chat_id"].(float64) != 777 || tg["username"] != "friend" {
+		t.Fatalf("telegram block %#v", tg)
+	}
+
+	// Бот теперь находит web-аккаунт по chat id.
+	u, err := svc.GetUser(777)
+	if err != nil || u == nil || u.ID != 50 {
+		t.Fatalf("GetUser after link: %#v err=%v", u, err)
+	}
+
+	// Повтор — идемпотентно, без нового POST.
+	before := fake.postCount()
+	if _, err := svc.LinkTelegramForWebUser(50, models.TelegramInfo{ChatID: 777}); err != nil {
+		t.Fatal(err)
+	}
+	if fake.postCount() != before {
+		t.Fatalf("idempotent relink must not POST")
+	}
+}
+
+func TestLinkTelegramForWebUser_ExistingTelegramUserConflict(t *testing.T) {
+	wl := webLoginFor(t, "web@tg.test")
+	fake, acl := newFakeSHMUsers(t,
+		`{"user_id":50,"login":"`+wl+`","settings":{"brand_id":"vff","web":{"email":"web@tg.test"}}}`,
+		`{"user_id":60,"login":"@777","settings":{"brand_id":"vff","telegram":{"chat_id":777}}}`,
+	)
+	svc := NewService(acl, testServiceBrand())
+	if _, err := svc.LinkTelegramForWebUser(50, models.TelegramInfo{ChatID: 777}); !errors.Is(err, ErrTelegramUsedByOtherAccount) {
+		t.Fatalf("want ErrTelegramUsedByOtherAccount, got %v", err)
+	}
+	if fake.postCount() != 0 {
+		t.Fatal("conflict must not write")
+	}
+}
+
+func TestLinkTelegramForWebUser_OtherChatAlreadyLinked(t *testing.T) {
+	wl := webLoginFor(t, "web@tg.test")
+	fake, acl := newFakeSHMUsers(t,
+		`{"user_id":50,"login":"`+wl+`","login2":"@111","settings":{"brand_id":"vff","web":{"email":"web@tg.test"},"telegram":{"chat_id":111}}}`,
+	)
+	svc := NewService(acl, testServiceBrand())
+	if _, err := svc.LinkTelegramForWebUser(50, models.TelegramInfo{ChatID: 777}); !errors.Is(err, ErrTelegramAlreadyLinked) {
+		t.Fatalf("want ErrTelegramAlreadyLinked, got %v", err)
+	}
+	if fake.postCount() != 0 {
+		t.Fatal("must not write")
+	}
+}
+
+func TestLinkTelegramForWebUser_OtherBrandMismatch(t *testing.T) {
+	wl := webLoginFor(t, "web@tg.test")
+	_, acl := newFakeSHMUsers(t,
+		`{"user_id":50,"login":"`+wl+`","settings":{"brand_id":"fc","web":{"email":"web@tg.test"}}}`,
+	)
+	svc := NewService(acl, testServiceBrand())
+	if _, err := svc.LinkTelegramForWebUser(50, models.TelegramInfo{ChatID: 777}); !errors.Is(err, ErrUserIdentityMismatch) {
+		t.Fatalf("want ErrUserIdentityMismatch, got %v", err)
+	}
+}
+
+func TestLinkTelegramForWebUser_NoWebEmail(t *testing.T) {
+	_, acl := newFakeSHMUsers(t, `{"user_id":50,"login":"@50","settings":{"brand_id":"vff"}}`)
+	svc := NewService(acl, testServiceBrand())
+	if _, err := svc.LinkTelegramForWebUser(50, models.TelegramInfo{ChatID: 777}); !errors.Is(err, ErrWebUserEmailMissing) {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func TestGetUser_LinkedWebUserWrongChatMismatch(t *testing.T) {
+	wl := webLoginFor(t, "web@tg.test")
+	_, acl := newFakeSHMUsers(t,
+		`{"user_id":50,"login":"`+wl+`","login2":"@777","settings":{"brand_id":"vff","web":{"email":"web@tg.test"},"telegram":{"chat_id":999}}}`,
+	)
+	svc := NewService(acl, testServiceBrand())
+	if u, err := svc.GetUser(777); u != nil || !errors.Is(err, ErrUserIdentityMismatch) {
+		t.Fatalf("got %#v err=%v", u, err)
+	}
+}
@@ -91,6 +91,10 @@ func (s *Service) LinkWebEmailForTelegramUser(userID int, telegramChatID int64,
 		return nil, err
 	}
 
+	// Settings пишутся целиком: читаем их под общим замком settings, как saveSettingsKey.
+	mu := s.settingsLocks.forUser(userID)
+	mu.Lock()
+	defer mu.Unlock()
 	loginSHM, rawSettings, err := s.apiClient.FetchAdminUserRowRaw(userID)
 	if err != nil {
 		return nil, err
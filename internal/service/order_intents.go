@@ -0,0 +1,110 @@
+package service
+
+import (
+	"errors"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Стадии намерения заказа (PaymentIntent.OrderStatus).
+const (
+	OrderIntentAwaiting = "awaiting_payment"
+	OrderIntentOrdering = "ordering"
+	OrderIntentOrdered  = "ordered"
+	// OrderIntentExistingUnpaid — SHM вернул ранее заказанную неоплаченную услугу (check_exists_unpaid), новая не создана.
+	OrderIntentExistingUnpaid = "existing_unpaid"
+	OrderIntentFailed         = "order_failed"
+	// OrderIntentExpired — оплата не пришла, пришла слишком поздно или услугу уже заказали по другой ссылке.
+	OrderIntentExpired = "expired"
+)
+
+// orderIntentMaxAge — позже этого срока после выдачи ссылки услуга автоматически не заказывается:
+// деньги остаются на балансе, пользователь решает сам.
+const orderIntentMaxAge = 24 * time.Hour
+
+// CreateOrderIntent сохраняет намерение оплатить недостающую сумму amount и заказать услугу serviceID
+// после зачисления. Категория услуги проверяется сразу и ещё раз перед заказом.
+func (s *Service) CreateOrderIntent(userID, serviceID int, serviceName string, amount float64, provider, brandID string, ts int64) (*PaymentIntent, error) {
+	if serviceID <= 0 {
+		return nil, errors.New("invalid service id")
+	}
+	if err := s.ensureServiceAllowedForOrder(serviceID); err != nil {
+		return nil, err
+	}
+	return s.createPaymentIntent(userID, PaymentIntent{
+		Amount:      amount,
+		Provider:    provider,
+		BrandID:     brandID,
+		TS:          ts,
+		ServiceID:   serviceID,
+		ServiceName: strings.TrimSpace(serviceName),
+		OrderStatus: OrderIntentAwaiting,
+	})
+}
+
+// FulfillOrderIntents сопоставляет намерения с платежами SHM и заказывает услуги по зачисленным намерениям заказа.
+// Стадия «ordering» пишется в SHM до ServiceOrder, поэтому параллельный или повторный проход
+// (бот, страница возврата) второй заказ не создаст. После рестарта пользователь попадает в фоновый проход
+// через DiscoverPaymentIntentUsers. Возвращает список (новые сначала).
+func (s *Service) FulfillOrderIntents(userID int) ([]PaymentIntent, error) {
+	s.paymentIntentsMu.Lock()
+	defer s.paymentIntentsMu.Unlock()
+	list, err := s.resolvePaymentIntentsLocked(userID)
+	if err != nil {
+		return nil, err
+	}
+	now := paymentIntentNow()
+	for i := range list {
+		in := &list[i]
+		if in.ServiceID <= 0 || in.OrderStatus != OrderIntentAwaiting {
+			continue
+		}
+		switch {
+		case in.Status == PaymentIntentFailed:
+			in.OrderStatus = OrderIntentExpired
+		case in.Status != PaymentIntentCredited:
+			continue
+		case now.Sub(in.CreatedAt) > orderIntentMaxAge:
+			in.OrderStatus = OrderIntentExpired
+		default:
+			in.OrderStatus = OrderIntentOrdering
+			if err := s.savePaymentIntents(userID, list); err != nil {
+				in.OrderStatus = OrderIntentAwaiting
+				return nil, err
+			}
+			s.orderIntentLocked(userID, in)
+			// Повторные ссылки на ту же услугу (двойной клик, возврат к оплате) второй заказ не создают:
+			// если по ним тоже придут деньги, они останутся на балансе.
+			for j := range list {
+				if j != i && list[j].ServiceID == in.ServiceID && list[j].OrderStatus == OrderIntentAwaiting {
+					list[j].OrderStatus = OrderIntentExpired
+				}
+			}
+		}
+		if err := s.savePaymentIntents(userID, list); err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	return list, nil
+}
+
+// orderIntentLocked заказывает услугу намерения (с проверкой категории бренда) и записывает итог в in.
+func (s *Service) orderIntentLocked(userID int, in *PaymentIntent) {
+	us, err := s.ServiceOrderByUserID(userID, in.ServiceID)
+	if err != nil || us == nil {
+		slog.Error("order intent: ServiceOrderByUserID", "user_id", userID, "intent", in.ID, "service_id", in.ServiceID, "err", err)
+		in.OrderStatus = OrderIntentFailed
+		return
+	}
+	in.UserServiceID = us.ServiceID
+	in.OrderStatus = OrderIntentOrdered
+	if us.BaseServiceID > 0 && us.BaseServiceID != in.ServiceID {
+		in.OrderStatus = OrderIntentExistingUnpaid
+		if name := strings.TrimSpace(us.Name); name != "" {
+			in.ServiceName = name
+		}
+	}
+}
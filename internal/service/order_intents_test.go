@@ -0,0 +1,146 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+const orderIntentCatalogJSON = `{"service_id":7,"allow_to_order":1,"cost":450,"category":"vpn-mz-test","name":"Premium 1m"}`
+
+func TestFulfillOrderIntents_OrdersOnceAfterCredit(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	stubPaymentIntentNow(t, &now)
+	fake, client := newFakeSHMUsers(t, `{"user_id":42,"login":"@42","settings":{}}`)
+	fake.setCatalog(t, orderIntentCatalogJSON)
+	s := NewService(client, orderBrandCfg("vff", "vpn-mz-test"))
+
+	in, err := s.CreateOrderIntent(42, 7, "Premium 1m", 300, "yookassa", "vff", now.Unix())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Minute)
+	again, err := s.CreateOrderIntent(42, 7, "Premium 1m", 300, "yookassa", "vff", now.Unix())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.FulfillOrderIntents(42); err != nil || fake.orderCount() != 0 {
+		t.Fatalf("before pay: %v orders=%d", err, fake.orderCount())
+	}
+	if claimed, _ := s.ClaimCreditedPaymentIntents(42); len(claimed) != 0 {
+		t.Fatalf("claimed before pay: %+v", claimed)
+	}
+
+	fake.setRows(t, "pay", 42, `[{"id":5,"user_id":42,"money":300,"date":"2026-10-18 12:05:00"}]`)
+	list, err := s.FulfillOrderIntents(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list[1].ID != in.ID || list[1].OrderStatus != OrderIntentOrdered || list[1].UserServiceID != 901 {
+		t.Fatalf("%+v", list[1])
+	}
+	if list[0].ID != again.ID || list[0].OrderStatus != OrderIntentExpired {
+		t.Fatalf("second link for the same service must not order: %+v", list[0])
+	}
+	if _, err := s.FulfillOrderIntents(42); err != nil || fake.orderCount() != 1 {
+		t.Fatalf("second pass must not order again: %v orders=%d", err, fake.orderCount())
+	}
+	claimed, err := s.ClaimCreditedPaymentIntents(42)
+	if err != nil || len(claimed) != 1 || claimed[0].OrderStatus != OrderIntentOrdered {
+		t.Fatalf("claim %+v %v", claimed, err)
+	}
+}
+
+func TestFulfillOrderIntents_FailedAndStale(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	stubPaymentIntentNow(t, &now)
+	fake, client := newFakeSHMUsers(t, `{"user_id":42,"login":"@42","settings":{}}`)
+	fake.setCatalog(t, orderIntentCatalogJSON)
+	s := NewService(client, orderBrandCfg("vff", "vpn-mz-test"))
+
+	if _, err := s.CreateOrderIntent(42, 7, "Premium 1m", 300, "yookassa", "vff", now.Unix()); err != nil {
+		t.Fatal(err)
+	}
+	fake.setRows(t, "pay", 42, `[{"id":5,"user_id":42,"money":300,"date":"2026-10-18 12:05:00"}]`)
+	fake.failOrders = true
+	list, err := s.FulfillOrderIntents(42)
+	if err != nil || list[0].OrderStatus != OrderIntentFailed {
+		t.Fatalf("%+v %v", list, err)
+	}
+	fake.failOrders = false
+	if list, _ := s.FulfillOrderIntents(42); list[0].OrderStatus != OrderIntentFailed || fake.orderCount() != 0 {
+		t.Fatalf("failed order is not retried automatically: %+v", list[0])
+	}
+
+	// Зачисление, обнаруженное через сутки после ссылки (например, после рестарта), услугу не заказывает.
+	now = now.Add(time.Minute)
+	if _, err := s.CreateOrderIntent(42, 7, "Premium 1m", 150, "yookassa", "vff", now.Unix()); err != nil {
+		t.Fatal(err)
+	}
+	fake.setRows(t, "pay", 42, `[{"id":5,"user_id":42,"money":300,"date":"2026-10-18 12:05:00"},{"id":6,"user_id":42,"money":150,"date":"2026-10-18 12:10:00"}]`)
+	now = now.Add(25 * time.Hour)
+	list, err = s.FulfillOrderIntents(42)
+	if err != nil || list[0].Status != PaymentIntentCredited || list[0].OrderStatus != OrderIntentExpired || fake.orderCount() != 0 {
+		t.Fatalf("%+v %v orders=%d", list[0], err, fake.orderCount())
+	}
+}
+
+func TestDiscoverPaymentIntentUsers_AfterRestart(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	stubPaymentIntentNow(t, &now)
+	fake, client := newFakeSHMUsers(t,
+		`{"user_id":42,"login":"@42","settings":{}}`,
+		`{"user_id":43,"login":"@43","settings":{}}`,
+		`{"user_id":44,"login":"@44","settings":{}}`,
+	)
+	fake.setCatalog(t, orderIntentCatalogJSON)
+	before := NewService(client, orderBrandCfg("vff", "vpn-mz-test"))
+	if _, err := before.CreateOrderIntent(42, 7, "Premium 1m", 300, "yookassa", "vff", now.Unix()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := before.CreatePaymentIntent(43, 200, "yookassa", "vff", now.Unix()); err != nil {
+		t.Fatal(err)
+	}
+	fake.setRows(t, "pay", 42, `[{"id":5,"money":300,"date":"2026-10-18 12:05:00"}]`)
+
+	// Рестарт: список ожидающих в памяти пуст, намерения лежат в settings.
+	now = now.Add(10 * time.Minute)
+	s := NewService(client, orderBrandCfg("vff", "vpn-mz-test"))
+	if err := s.DiscoverPaymentIntentUsers(); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.PendingPaymentIntentUsers(); len(got) != 1 || got[0] != 42 {
+		t.Fatalf("pending after restart %v", got)
+	}
+
+	// Ссылку, выданную до рестарта, оплатили после него; платёж без намерения пользователя в список не добавляет.
+	now = now.Add(time.Minute)
+	fake.setRows(t, "pay", 43, `[{"id":6,"money":200,"date":"2026-10-18 12:11:00"}]`)
+	fake.setRows(t, "pay", 44, `[{"id":7,"money":500,"date":"2026-10-18 12:11:00"}]`)
+	if err := s.DiscoverPaymentIntentUsers(); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.PendingPaymentIntentUsers(); len(got) != 2 || got[0] != 42 || got[1] != 43 {
+		t.Fatalf("pending %v", got)
+	}
+	if list, err := s.FulfillOrderIntents(42); err != nil || list[0].OrderStatus != OrderIntentOrdered || fake.orderCount() != 1 {
+		t.Fatalf("%+v %v orders=%d", list, err, fake.orderCount())
+	}
+	if claimed, err := s.ClaimCreditedPaymentIntents(43); err != nil || len(claimed) != 1 || claimed[0].Amount != 200 {
+		t.Fatalf("%+v %v", claimed, err)
+	}
+}
+
+func TestCreateOrderIntent_RejectsOtherCategory(t *testing.T) {
+	fake, client := newFakeSHMUsers(t, `{"user_id":42,"login":"@42","settings":{}}`)
+	fake.setCatalog(t, `{"service_id":7,"allow_to_order":1,"cost":450,"category":"other"}`)
+	s := NewService(client, orderBrandCfg("vff", "vpn-mz-test"))
+	_, err := s.CreateOrderIntent(42, 7, "x", 300, "yookassa", "vff", time.Now().Unix())
+	var denied *ServiceCategoryDeniedError
+	if !errors.As(err, &denied) {
+		t.Fatalf("err=%v", err)
+	}
+	if _, ok := fake.row(42)["settings"].(map[string]interface{})["payment_intents"]; ok {
+		t.Fatal("intent must not be stored")
+	}
+}
@@ -68,6 +68,19 @@ func (s *Service) GetOwnedUserServiceByUserID(userID int, userServiceID string)
 	return us, nil
 }
 
+// GetUserServiceByID — услуга по user_service_id без ownership-проверки: только для серверных событий
+// (webhook Remnawave), где владелец определяется по самой услуге. Иначе ErrUserServiceUnavailable.
+func (s *Service) GetUserServiceByID(userServiceID int) (*models.UserService, error) {
+	us, err := s.apiClient.GetUserServiceByID(userServiceID)
+	if err != nil {
+		if errors.Is(err, api.ErrUserServiceUnavailable) {
+			return nil, ErrUserServiceUnavailable
+		}
+		return nil, err
+	}
+	return us, nil
+}
+
 // GetOwnedUserServiceByTelegramID находит SHM-пользователя по Telegram chat id и возвращает
 // принадлежащую ему услугу. Отсутствие пользователя → ErrUserNotFound.
 func (s *Service) GetOwnedUserServiceByTelegramID(telegramChatID int64, userServiceID string) (*models.UserService, *models.User, error) {
@@ -0,0 +1,344 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/models"
+)
+
+// paymentIntentsSettingsKey — settings.payment_intents: последние ссылки на оплату, выданные пользователю.
+const paymentIntentsSettingsKey = "payment_intents"
+
+// Статус намерения оплаты.
+const (
+	PaymentIntentProcessing = "processing"
+	PaymentIntentCredited   = "credited"
+	PaymentIntentFailed     = "failed"
+)
+
+const (
+	// paymentIntentTimeout — без платежа за это время намерение считается неуспешным.
+	paymentIntentTimeout = time.Hour
+	// paymentIntentClockSkew — платёж может быть записан SHM чуть раньше нашей отметки времени.
+	paymentIntentClockSkew = 2 * time.Minute
+	paymentIntentRetention = 7 * 24 * time.Hour
+	paymentIntentsMax      = 20
+)
+
+// shmPayDateLayout — user/pay.date в SHM (московское время).
+const shmPayDateLayout = "2006-01-02 15:04:05"
+
+var shmMoscow = time.FixedZone("MSK", 3*60*60)
+
+// paymentIntentNow — текущее время (подменяется в тестах).
+var paymentIntentNow = time.Now
+
+// PaymentIntent — ссылка на пополнение, выданная vpnbot: кто, сколько, через какого провайдера.
+// Статус определяется сопоставлением с user/pay по сумме и окну времени.
+type PaymentIntent struct {
+	ID         string    `json:"id"`
+	Amount     float64   `json:"amount"`
+	Provider   string    `json:"provider"`
+	BrandID    string    `json:"brand_id,omitempty"`
+	TS         int64     `json:"ts"`
+	CreatedAt  time.Time `json:"created_at"`
+	Status     string    `json:"status"`
+	PayID      int       `json:"pay_id,omitempty"`
+	CreditedAt string    `json:"credited_at,omitempty"`
+	Notified   bool      `json:"notified,omitempty"`
+
+	// Намерение заказа: после зачисления услуга ServiceID заказывается автоматически (см. FulfillOrderIntents).
+	ServiceID     int    `json:"service_id,omitempty"`
+	ServiceName   string `json:"service_name,omitempty"`
+	OrderStatus   string `json:"order_status,omitempty"`
+	UserServiceID int    `json:"user_service_id,omitempty"`
+}
+
+// CreatePaymentIntent сохраняет намерение оплаты в settings.payment_intents (не более 20 последних за 7 дней)
+// и добавляет пользователя в список ожидающих подтверждения.
+func (s *Service) CreatePaymentIntent(userID int, amount float64, provider, brandID string, ts int64) (*PaymentIntent, error) {
+	return s.createPaymentIntent(userID, PaymentIntent{Amount: amount, Provider: provider, BrandID: brandID, TS: ts})
+}
+
+func (s *Service) createPaymentIntent(userID int, intent PaymentIntent) (*PaymentIntent, error) {
+	amount := intent.Amount
+	if userID <= 0 || amount <= 0 || math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return nil, errors.New("invalid payment intent")
+	}
+	id, err := newPaymentIntentID()
+	if err != nil {
+		return nil, err
+	}
+	intent.ID = id
+	intent.Amount = math.Round(amount*100) / 100
+	intent.Provider = strings.TrimSpace(intent.Provider)
+	intent.BrandID = strings.TrimSpace(intent.BrandID)
+	intent.CreatedAt = paymentIntentNow().UTC()
+	intent.Status = PaymentIntentProcessing
+
+	s.paymentIntentsMu.Lock()
+	defer s.paymentIntentsMu.Unlock()
+	list, err := s.loadPaymentIntents(userID)
+	if err != nil {
+		return nil, err
+	}
+	list = append(list, intent)
+	if err := s.savePaymentIntents(userID, list); err != nil {
+		return nil, err
+	}
+	if s.pendingIntentUsers == nil {
+		s.pendingIntentUsers = map[int]struct{}{}
+	}
+	s.pendingIntentUsers[userID] = struct{}{}
+	return &intent, nil
+}
+
+// PaymentIntents сопоставляет намерения пользователя с платежами SHM, сохраняет изменившиеся статусы
+// и возвращает список (новые сначала).
+func (s *Service) PaymentIntents(userID int) ([]PaymentIntent, error) {
+	s.paymentIntentsMu.Lock()
+	defer s.paymentIntentsMu.Unlock()
+	list, err := s.resolvePaymentIntentsLocked(userID)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	return list, nil
+}
+
+// ClaimCreditedPaymentIntents возвращает зачисленные намерения, о которых ещё не сообщали, и отмечает их
+// как сообщённые (отметка до отправки — повторного сообщения после сбоя не будет).
+func (s *Service) ClaimCreditedPaymentIntents(userID int) ([]PaymentIntent, error) {
+	s.paymentIntentsMu.Lock()
+	defer s.paymentIntentsMu.Unlock()
+	list, err := s.resolvePaymentIntentsLocked(userID)
+	if err != nil {
+		return nil, err
+	}
+	var out []PaymentIntent
+	pending := false
+	for i := range list {
+		in := &list[i]
+		switch {
+		case in.Status == PaymentIntentProcessing || (in.Status == PaymentIntentCredited && in.OrderStatus == OrderIntentAwaiting):
+			// Для намерения заказа сообщаем итог заказа, а не только зачисление.
+			pending = true
+		case in.Status == PaymentIntentCredited && !in.Notified:
+			in.Notified = true
+			out = append(out, *in)
+		}
+	}
+	if len(out) > 0 {
+		if err := s.savePaymentIntents(userID, list); err != nil {
+			return nil, err
+		}
+	}
+	if !pending {
+		delete(s.pendingIntentUsers, userID)
+	}
+	return out, nil
+}
+
+// PendingPaymentIntentUsers — пользователи с незавершёнными намерениями: созданными в этом процессе
+// или найденными DiscoverPaymentIntentUsers.
+func (s *Service) PendingPaymentIntentUsers() []int {
+	s.paymentIntentsMu.Lock()
+	defer s.paymentIntentsMu.Unlock()
+	out := make([]int, 0, len(s.pendingIntentUsers))
+	for id := range s.pendingIntentUsers {
+		out = append(out, id)
+	}
+	sort.Ints(out)
+	return out
+}
+
+// paymentIntentDiscoveryWindow — за какой срок первый после старта проход смотрит платежи: намерение заказа
+// исполняется не позже orderIntentMaxAge, а оплата засчитывается в течение paymentIntentTimeout.
+const paymentIntentDiscoveryWindow = orderIntentMaxAge + paymentIntentTimeout
+
+// DiscoverPaymentIntentUsers добавляет в список ожидающих плательщиков из новых user/pay, у которых
+// в settings.payment_intents есть незавершённые намерения. Список в памяти после рестарта пуст — так находятся
+// ссылки, выданные до него: и уже оплаченные (первый проход смотрит платежи за 25 ч), и оплаченные позже.
+func (s *Service) DiscoverPaymentIntentUsers() error {
+	now := paymentIntentNow()
+	s.paymentIntentsMu.Lock()
+	since := s.intentScanSince
+	s.paymentIntentsMu.Unlock()
+	if from := now.Add(-paymentIntentDiscoveryWindow); since.Before(from) {
+		since = from
+	}
+	pays, err := s.apiClient.ListUserPaysSince(since.Add(-paymentIntentClockSkew).In(shmMoscow).Format(shmPayDateLayout), "")
+	if err != nil {
+		return err
+	}
+	payers := map[int]struct{}{}
+	for _, p := range pays {
+		if p.Money > 0 {
+			payers[p.UserID] = struct{}{}
+		}
+	}
+	ids := make([]int, 0, len(payers))
+	for id := range payers {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	s.paymentIntentsMu.Lock()
+	defer s.paymentIntentsMu.Unlock()
+	var firstErr error
+	for _, userID := range ids {
+		if _, ok := s.pendingIntentUsers[userID]; ok {
+			continue
+		}
+		list, err := s.loadPaymentIntents(userID)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("user %d: %w", userID, err)
+			}
+			continue
+		}
+		if paymentIntentsUnfinished(list) {
+			s.pendingIntentUsers[userID] = struct{}{}
+		}
+	}
+	if firstErr != nil {
+		// Окно не сдвигаем: следующий проход просмотрит эти платежи снова (но не дальше 25 ч назад).
+		return firstErr
+	}
+	s.intentScanSince = now
+	return nil
+}
+
+// paymentIntentsUnfinished — есть намерение, ещё ждущее зачисления, заказа или сообщения пользователю.
+func paymentIntentsUnfinished(list []PaymentIntent) bool {
+	for _, in := range list {
+		if in.Status == PaymentIntentProcessing || (in.Status == PaymentIntentCredited && (in.OrderStatus == OrderIntentAwaiting || !in.Notified)) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolvePaymentIntentsLocked — обновляет статусы processing по user/pay; изменения сохраняются в SHM.
+func (s *Service) resolvePaymentIntentsLocked(userID int) ([]PaymentIntent, error) {
+	list, err := s.loadPaymentIntents(userID)
+	if err != nil {
+		return nil, err
+	}
+	hasProcessing := false
+	for _, in := range list {
+		if in.Status == PaymentIntentProcessing {
+			hasProcessing = true
+		}
+	}
+	if !hasProcessing {
+		return list, nil
+	}
+	pays, err := s.apiClient.GetUserPays(userID)
+	if err != nil {
+		return nil, err
+	}
+	if matchPaymentIntents(list, pays, paymentIntentNow()) {
+		if err := s.savePaymentIntents(userID, list); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// matchPaymentIntents — processing → credited, если есть положительный платёж на ту же сумму
+// в окне [created_at−2 мин, created_at+1 ч], ещё не привязанный к другому намерению; иначе по истечении
+// окна → failed. Раньше созданное намерение забирает более ранний платёж. Возвращает true при изменениях.
+func matchPaymentIntents(list []PaymentIntent, pays []models.UserPay, now time.Time) bool {
+	used := map[int]bool{}
+	for _, in := range list {
+		if in.PayID > 0 {
+			used[in.PayID] = true
+		}
+	}
+	order := make([]int, len(list))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return list[order[a]].CreatedAt.Before(list[order[b]].CreatedAt) })
+	sortedPays := append([]models.UserPay(nil), pays...)
+	sort.SliceStable(sortedPays, func(a, b int) bool { return sortedPays[a].ID < sortedPays[b].ID })
+
+	changed := false
+	for _, i := range order {
+		in := &list[i]
+		if in.Status != PaymentIntentProcessing {
+			continue
+		}
+		from := in.CreatedAt.Add(-paymentIntentClockSkew)
+		to := in.CreatedAt.Add(paymentIntentTimeout)
+		for _, p := range sortedPays {
+			if used[p.ID] || p.Money <= 0 || math.Abs(p.Money-in.Amount) >= 0.005 {
+				continue
+			}
+			at, err := time.ParseInLocation(shmPayDateLayout, strings.TrimSpace(p.Date), shmMoscow)
+			if err != nil || at.Before(from) || at.After(to) {
+				continue
+			}
+			in.Status, in.PayID, in.CreditedAt = PaymentIntentCredited, p.ID, p.Date
+			used[p.ID] = true
+			changed = true
+			break
+		}
+		if in.Status == PaymentIntentProcessing && now.After(to) {
+			in.Status = PaymentIntentFailed
+			changed = true
+		}
+	}
+	return changed
+}
+
+func (s *Service) loadPaymentIntents(userID int) ([]PaymentIntent, error) {
+	settingsObj, err := s.loadSettingsMap(userID)
+	if err != nil {
+		return nil, err
+	}
+	var list []PaymentIntent
+	if raw, ok := settingsObj[paymentIntentsSettingsKey]; ok && raw != nil {
+		b, err := json.Marshal(raw)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(b, &list); err != nil {
+			// Повреждённый список не блокирует оплату: начинаем заново.
+			list = nil
+		}
+	}
+	return list, nil
+}
+
+// savePaymentIntents обрезает список (7 дней, 20 записей) и пишет settings.payment_intents.
+func (s *Service) savePaymentIntents(userID int, list []PaymentIntent) error {
+	cutoff := paymentIntentNow().Add(-paymentIntentRetention)
+	kept := make([]PaymentIntent, 0, len(list))
+	for _, in := range list {
+		if in.CreatedAt.After(cutoff) {
+			kept = append(kept, in)
+		}
+	}
+	if len(kept) > paymentIntentsMax {
+		kept = kept[len(kept)-paymentIntentsMax:]
+	}
+	return s.saveSettingsKey(userID, paymentIntentsSettingsKey, kept)
+}
+
+func newPaymentIntentID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
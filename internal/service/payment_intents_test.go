@@ -0,0 +1,91 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/config"
+	"github.com/ryabkov82/vpnbot/internal/models"
+)
+
+func stubPaymentIntentNow(t *testing.T, now *time.Time) {
+	t.Helper()
+	prev := paymentIntentNow
+	paymentIntentNow = func() time.Time { return *now }
+	t.Cleanup(func() { paymentIntentNow = prev })
+}
+
+func TestMatchPaymentIntents(t *testing.T) {
+	created := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC) // 12:00 MSK
+	list := []PaymentIntent{
+		{ID: "a", Amount: 300, CreatedAt: created, Status: PaymentIntentProcessing},
+		{ID: "b", Amount: 300, CreatedAt: created.Add(time.Minute), Status: PaymentIntentProcessing},
+		{ID: "c", Amount: 500, CreatedAt: created, Status: PaymentIntentProcessing},
+		{ID: "d", Amount: 150, CreatedAt: created.Add(-3 * time.Hour), Status: PaymentIntentProcessing},
+	}
+	pays := []models.UserPay{
+		{ID: 10, Money: 300, Date: "2026-10-18 11:00:00"}, // раньше окна
+		{ID: 11, Money: 300, Date: "2026-10-18 12:03:00"},
+		{ID: 12, Money: -300, Date: "2026-10-18 12:04:00"},
+		{ID: 13, Money: 500.001, Date: "2026-10-18 13:30:00"}, // позже окна
+	}
+	if !matchPaymentIntents(list, pays, created.Add(10*time.Minute)) {
+		t.Fatal("expected changes")
+	}
+	want := map[string]string{"a": PaymentIntentCredited, "b": PaymentIntentProcessing, "c": PaymentIntentProcessing, "d": PaymentIntentFailed}
+	for _, in := range list {
+		if in.Status != want[in.ID] {
+			t.Fatalf("%s: %s", in.ID, in.Status)
+		}
+	}
+	if list[0].PayID != 11 || list[0].CreditedAt != "2026-10-18 12:03:00" {
+		t.Fatalf("a: %+v", list[0])
+	}
+}
+
+func TestPaymentIntents_CreateResolveAndClaimOnce(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	stubPaymentIntentNow(t, &now)
+	fake, client := newFakeSHMUsers(t, `{"user_id":7,"login":"@7","settings":{"telegram":{"chat_id":77}}}`)
+	s := NewService(client, config.BrandConfig{})
+
+	in, err := s.CreatePaymentIntent(7, 299.999, "yookassa", "vff", now.Unix())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if in.Amount != 300 || in.Status != PaymentIntentProcessing || in.ID == "" {
+		t.Fatalf("%+v", in)
+	}
+	if got := s.PendingPaymentIntentUsers(); len(got) != 1 || got[0] != 7 {
+		t.Fatalf("pending %v", got)
+	}
+
+	claimed, err := s.ClaimCreditedPaymentIntents(7)
+	if err != nil || len(claimed) != 0 {
+		t.Fatalf("before pay: %v %v", claimed, err)
+	}
+
+	fake.setRows(t, "pay", 7, `[{"id":55,"user_id":7,"money":300,"date":"2026-10-18 12:01:30","pay_system_id":"yookassa"}]`)
+	list, err := s.PaymentIntents(7)
+	if err != nil || len(list) != 1 || list[0].Status != PaymentIntentCredited || list[0].PayID != 55 {
+		t.Fatalf("%+v %v", list, err)
+	}
+	claimed, err = s.ClaimCreditedPaymentIntents(7)
+	if err != nil || len(claimed) != 1 || claimed[0].ID != in.ID {
+		t.Fatalf("claim: %+v %v", claimed, err)
+	}
+	if again, _ := s.ClaimCreditedPaymentIntents(7); len(again) != 0 {
+		t.Fatalf("second claim: %+v", again)
+	}
+	if got := s.PendingPaymentIntentUsers(); len(got) != 0 {
+		t.Fatalf("pending after credit %v", got)
+	}
+	settings := fake.row(7)["settings"].(map[string]interface{})
+	if _, ok := settings["telegram"]; !ok {
+		t.Fatal("existing settings must be preserved")
+	}
+	saved := settings["payment_intents"].([]interface{})[0].(map[string]interface{})
+	if saved["status"] != PaymentIntentCredited || saved["notified"] != true {
+		t.Fatalf("saved %v", saved)
+	}
+}
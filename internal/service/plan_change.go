@@ -0,0 +1,494 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/models"
+)
+
+// planChangesSettingsKey — settings.plan_changes: журнал смен тарифа пользователя (аудит и продолжение после сбоя).
+const planChangesSettingsKey = "plan_changes"
+
+// Статус смены тарифа. Каждый переход сохраняется в SHM до шага, который он начинает.
+const (
+	PlanChangeCrediting    = "crediting"     // зачисляем остаток текущей услуги
+	PlanChangeOrdering     = "ordering"      // заказываем новую услугу
+	PlanChangeRetiring     = "retiring"      // удаляем старую услугу
+	PlanChangeRetireFailed = "retire_failed" // новая услуга есть, старую удалить не удалось — повторяется при следующем запросе
+	PlanChangeDone         = "done"
+	PlanChangeReverted     = "reverted" // заказ не удался или не оплачен, зачисление отменено, старая услуга не тронута
+)
+
+// planChangesMax — сколько завершённых записей хранить в settings (незавершённые хранятся всегда).
+const planChangesMax = 30
+
+var (
+	// ErrPlanChangeDisabled — смена тарифа выключена в конфиге.
+	ErrPlanChangeDisabled = errors.New("plan change disabled")
+	// ErrPlanChangeUnavailable — текущая услуга не активна или целевой тариф совпадает с текущим.
+	ErrPlanChangeUnavailable = errors.New("plan change unavailable for this service")
+	// ErrPlanChangeStale — с момента расчёта услуга изменилась (продлена, сменила статус).
+	ErrPlanChangeStale = errors.New("service changed since plan change quote")
+	// ErrPlanChangeInsufficientBalance — остатка и баланса не хватает на новый тариф.
+	ErrPlanChangeInsufficientBalance = errors.New("insufficient balance for plan change")
+	// ErrPlanChangeInProgress — для услуги уже идёт смена на другой тариф.
+	ErrPlanChangeInProgress = errors.New("another plan change is in progress")
+	// ErrPlanChangeOrderFailed — новую оплаченную услугу заказать не удалось (в том числе SHM вернул
+	// неоплаченную); зачисление отменено.
+	ErrPlanChangeOrderFailed = errors.New("plan change order failed")
+	// ErrPlanChangeRetireFailed — новая услуга заказана, старую удалить не удалось; повтор запроса доделает.
+	ErrPlanChangeRetireFailed = errors.New("plan change retire failed")
+)
+
+// planChangeNow — текущее время (подменяется в тестах).
+var planChangeNow = time.Now
+
+// PlanChangeQuote — расчёт смены тарифа: стоимость неиспользованного времени текущей услуги и цена нового тарифа.
+type PlanChangeQuote struct {
+	UserServiceID int
+	FromServiceID int
+	FromName      string
+	ToServiceID   int
+	ToName        string
+	// Expire — окончание текущей услуги как в SHM; ChangePlan сверяет его с актуальным.
+	Expire    string
+	Remaining time.Duration
+	Credit    float64
+	Cost      float64
+	Balance   float64
+}
+
+// Due — сколько не хватает на балансе после зачисления остатка.
+func (q PlanChangeQuote) Due() float64 {
+	if d := q.Cost - q.Credit - q.Balance; d > 0.005 {
+		return math.Round(d*100) / 100
+	}
+	return 0
+}
+
+// Leftover — часть остатка, которая останется на балансе после оплаты нового тарифа (переход на более дешёвый).
+func (q PlanChangeQuote) Leftover() float64 {
+	if d := q.Credit - q.Cost; d > 0.005 {
+		return math.Round(d*100) / 100
+	}
+	return 0
+}
+
+// PlanChange — запись журнала смены тарифа (settings.plan_changes).
+type PlanChange struct {
+	ID               string  `json:"id"`
+	UserServiceID    int     `json:"user_service_id"`
+	FromServiceID    int     `json:"from_service_id"`
+	ToServiceID      int     `json:"to_service_id"`
+	ToName           string  `json:"to_name"`
+	Expire           string  `json:"expire"`
+	Credit           float64 `json:"credit"`
+	Cost             float64 `json:"cost"`
+	Status           string  `json:"status"`
+	NewUserServiceID int     `json:"new_user_service_id,omitempty"`
+	// RetireWithdrawn — сумма списаний SHM по старой услуге перед её удалением; SHMRefund — сколько SHM
+	// сам вернул на баланс при удалении (эта часть Credit отменена платежом …:shm_refund).
+	RetireWithdrawn float64   `json:"retire_withdrawn,omitempty"`
+	SHMRefund       float64   `json:"shm_refund,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+func (p PlanChange) open() bool {
+	return p.Status == PlanChangeCrediting || p.Status == PlanChangeOrdering ||
+		p.Status == PlanChangeRetiring || p.Status == PlanChangeRetireFailed
+}
+
+// PlanChangeRequest — смена тарифа услуги UserServiceID на ServiceID; Expire — из расчёта, PaySystem — из config.PlanChange.
+type PlanChangeRequest struct {
+	UserID        int
+	UserServiceID int
+	ServiceID     int
+	Expire        string
+	PaySystem     string
+}
+
+// QuotePlanChange — расчёт смены тарифа без изменений в SHM.
+func (s *Service) QuotePlanChange(userID, userServiceID, serviceID int) (*PlanChangeQuote, error) {
+	us, err := s.GetOwnedUserServiceByUserID(userID, strconv.Itoa(userServiceID))
+	if err != nil {
+		return nil, err
+	}
+	return s.planChangeQuote(us, serviceID)
+}
+
+// ChangePlan зачисляет остаток текущей услуги, заказывает новую и удаляет старую. Шаги записываются
+// в settings.plan_changes; повтор того же запроса после сбоя продолжает с прерванного шага.
+func (s *Service) ChangePlan(req PlanChangeRequest) (*PlanChange, error) {
+	if req.UserID <= 0 || req.UserServiceID <= 0 || req.ServiceID <= 0 {
+		return nil, errors.New("invalid plan change request")
+	}
+	s.planChangeMu.Lock()
+	defer s.planChangeMu.Unlock()
+	list, err := s.loadPlanChanges(req.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	i := -1
+	for k := range list {
+		if list[k].UserServiceID == req.UserServiceID && list[k].open() {
+			if list[k].ToServiceID != req.ServiceID {
+				return nil, ErrPlanChangeInProgress
+			}
+			i = k
+		}
+	}
+	if i < 0 {
+		us, err := s.GetOwnedUserServiceByUserID(req.UserID, strconv.Itoa(req.UserServiceID))
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(us.Expire) != strings.TrimSpace(req.Expire) {
+			return nil, ErrPlanChangeStale
+		}
+		q, err := s.planChangeQuote(us, req.ServiceID)
+		if err != nil {
+			return nil, err
+		}
+		if q.Due() > 0 {
+			return nil, ErrPlanChangeInsufficientBalance
+		}
+		id, err := newPlanChangeID()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, PlanChange{
+			ID:            id,
+			UserServiceID: q.UserServiceID,
+			FromServiceID: q.FromServiceID,
+			ToServiceID:   q.ToServiceID,
+			ToName:        q.ToName,
+			Expire:        q.Expire,
+			Credit:        q.Credit,
+			Cost:          q.Cost,
+			Status:        PlanChangeCrediting,
+			CreatedAt:     planChangeNow().UTC(),
+		})
+		i = len(list) - 1
+		if err := s.savePlanChanges(req.UserID, list); err != nil {
+			return nil, err
+		}
+	}
+	pc := &list[i]
+
+	if pc.Status == PlanChangeCrediting {
+		if pc.Credit > 0 {
+			err := s.giftPayment(req.UserID, pc.Credit, planChangePaySystem(req.PaySystem), s.planChangePayKey(pc.ID, "credit"), map[string]interface{}{
+				"plan_change": pc.ID, "user_service_id": pc.UserServiceID, "service_id": pc.ToServiceID, "kind": "credit",
+			})
+			if err != nil {
+				return nil, fmt.Errorf("plan change credit: %w", err)
+			}
+		}
+		// Отметка «ordering» до заказа: сбой между заказом и сохранением не приведёт ко второй услуге.
+		pc.Status = PlanChangeOrdering
+		if err := s.savePlanChanges(req.UserID, list); err != nil {
+			return nil, err
+		}
+		us, orderErr := s.ServiceOrderByUserID(req.UserID, pc.ToServiceID)
+		if orderErr != nil || us == nil {
+			slog.Error("plan change: ServiceOrderByUserID", "user_id", req.UserID, "plan_change", pc.ID, "service_id", pc.ToServiceID, "err", orderErr)
+			return nil, s.revertPlanChange(req, pc, list)
+		}
+		if !orderedAsRequested(us, pc.ToServiceID) {
+			// Старую активную услугу удалять нельзя: взамен нет оплаченной новой.
+			slog.Error("plan change: order returned another or unpaid service", "user_id", req.UserID, "plan_change", pc.ID,
+				"service_id", pc.ToServiceID, "returned_user_service_id", us.ServiceID, "returned_service_id", us.BaseServiceID, "status", us.Status)
+			pc.NewUserServiceID = us.ServiceID
+			return nil, s.revertPlanChange(req, pc, list)
+		}
+		pc.Status, pc.NewUserServiceID = PlanChangeRetiring, us.ServiceID
+		if err := s.savePlanChanges(req.UserID, list); err != nil {
+			slog.Error("plan change: save record", "user_id", req.UserID, "plan_change", pc.ID, "status", pc.Status, "err", err)
+		}
+	}
+	if pc.Status == PlanChangeOrdering {
+		// Заказ мог пройти без сохранённого итога — автоматически не повторяем.
+		return nil, ErrPlanChangeInProgress
+	}
+
+	if err := s.retirePlanChangeService(req.UserID, pc, list, req.PaySystem); err != nil {
+		slog.Error("plan change: retire old service", "user_id", req.UserID, "plan_change", pc.ID, "user_service_id", pc.UserServiceID, "err", err)
+		pc.Status = PlanChangeRetireFailed
+		if saveErr := s.savePlanChanges(req.UserID, list); saveErr != nil {
+			slog.Error("plan change: save record", "user_id", req.UserID, "plan_change", pc.ID, "status", pc.Status, "err", saveErr)
+		}
+		return nil, ErrPlanChangeRetireFailed
+	}
+	pc.Status = PlanChangeDone
+	if err := s.savePlanChanges(req.UserID, list); err != nil {
+		slog.Error("plan change: save record", "user_id", req.UserID, "plan_change", pc.ID, "status", pc.Status, "err", err)
+	}
+	slog.Info("plan changed", "brand_id", s.activeBrandID(), "user_id", req.UserID, "plan_change", pc.ID,
+		"user_service_id", pc.UserServiceID, "from_service_id", pc.FromServiceID, "to_service_id", pc.ToServiceID,
+		"new_user_service_id", pc.NewUserServiceID, "credit", pc.Credit, "shm_refund", pc.SHMRefund, "cost", pc.Cost)
+	out := *pc
+	return &out, nil
+}
+
+// revertPlanChange отменяет зачисление остатка и закрывает запись как «reverted»: старая услуга не тронута.
+// Возвращает ErrPlanChangeOrderFailed; если отмена не прошла, запись остаётся в «ordering» для поддержки.
+func (s *Service) revertPlanChange(req PlanChangeRequest, pc *PlanChange, list []PlanChange) error {
+	if pc.Credit > 0 {
+		err := s.giftPayment(req.UserID, -pc.Credit, planChangePaySystem(req.PaySystem), s.planChangePayKey(pc.ID, "reverse"), map[string]interface{}{
+			"plan_change": pc.ID, "user_service_id": pc.UserServiceID, "kind": "reverse",
+		})
+		if err != nil {
+			slog.Error("plan change: reverse credit", "user_id", req.UserID, "plan_change", pc.ID, "err", err)
+			return ErrPlanChangeOrderFailed
+		}
+	}
+	pc.Status = PlanChangeReverted
+	if err := s.savePlanChanges(req.UserID, list); err != nil {
+		slog.Error("plan change: save record", "user_id", req.UserID, "plan_change", pc.ID, "status", pc.Status, "err", err)
+	}
+	return ErrPlanChangeOrderFailed
+}
+
+// retirePlanChangeService удаляет старую услугу; уже удалённая (нет в SHM или в процессе удаления) считается снятой.
+// SHM при удалении активной услуги может сам вернуть на баланс неиспользованную часть списания — тогда остаток
+// оплачен дважды, и эта часть Credit отменяется (reversePlanChangeSHMRefund). Возврат SHM — меньшее из роста баланса
+// и уменьшения списаний SHM по услуге за время удаления: пополнение в тот же момент за возврат не принимается.
+// Если удаление прошло в прерванной попытке, баланс уже не сравнить — возврат оценивается по списаниям.
+func (s *Service) retirePlanChangeService(userID int, pc *PlanChange, list []PlanChange, paySystem string) error {
+	usID := strconv.Itoa(pc.UserServiceID)
+	us, err := s.GetOwnedUserServiceByUserID(userID, usID)
+	gone := errors.Is(err, ErrUserServiceUnavailable)
+	if err != nil && !gone {
+		return err
+	}
+	if !gone {
+		st := strings.TrimSpace(us.Status)
+		gone = st == "PROGRESS" || st == "REMOVED"
+	}
+	if gone {
+		if pc.Credit <= 0 || pc.RetireWithdrawn <= 0 || pc.SHMRefund > 0 {
+			return nil
+		}
+		withdrawn, err := s.planChangeWithdrawn(userID, pc.UserServiceID)
+		if err != nil {
+			return err
+		}
+		slog.Warn("plan change: old service removed in an interrupted attempt, SHM refund estimated from withdrawals",
+			"user_id", userID, "plan_change", pc.ID, "user_service_id", pc.UserServiceID)
+		return s.reversePlanChangeSHMRefund(userID, pc, pc.RetireWithdrawn-withdrawn, paySystem)
+	}
+	if pc.Credit <= 0 {
+		return s.apiClient.DeleteUserService(userID, usID)
+	}
+
+	withdrawn, err := s.planChangeWithdrawn(userID, pc.UserServiceID)
+	if err != nil {
+		return err
+	}
+	before, err := s.apiClient.GetUserBalance(userID)
+	if err != nil {
+		return err
+	}
+	pc.RetireWithdrawn = withdrawn
+	if err := s.savePlanChanges(userID, list); err != nil {
+		return err
+	}
+	if err := s.apiClient.DeleteUserService(userID, usID); err != nil {
+		return err
+	}
+	after, err := s.apiClient.GetUserBalance(userID)
+	if err != nil {
+		return err
+	}
+	if withdrawn, err = s.planChangeWithdrawn(userID, pc.UserServiceID); err != nil {
+		return err
+	}
+	return s.reversePlanChangeSHMRefund(userID, pc, math.Min(after.Balance-before.Balance, pc.RetireWithdrawn-withdrawn), paySystem)
+}
+
+// planChangeWithdrawn — сумма списаний SHM (withdraw.total) по услуге пользователя.
+func (s *Service) planChangeWithdrawn(userID, userServiceID int) (float64, error) {
+	items, err := s.apiClient.GetUserWithdrawals(userID)
+	if err != nil {
+		return 0, fmt.Errorf("plan change withdrawals: %w", err)
+	}
+	total := 0.0
+	for _, w := range items {
+		if w.UserServiceID == int64(userServiceID) {
+			total += w.Total
+		}
+	}
+	return math.Round(total*100) / 100, nil
+}
+
+// reversePlanChangeSHMRefund списывает refund (не больше Credit), который SHM вернул при удалении старой услуги,
+// платежом с uniq_key plan:<brand>:<id>:shm_refund — повтор не спишет дважды.
+func (s *Service) reversePlanChangeSHMRefund(userID int, pc *PlanChange, refund float64, paySystem string) error {
+	refund = math.Round(math.Min(refund, pc.Credit)*100) / 100
+	if refund < 0.01 {
+		return nil
+	}
+	err := s.giftPayment(userID, -refund, planChangePaySystem(paySystem), s.planChangePayKey(pc.ID, "shm_refund"), map[string]interface{}{
+		"plan_change": pc.ID, "user_service_id": pc.UserServiceID, "kind": "shm_refund",
+	})
+	if err != nil {
+		return fmt.Errorf("plan change: reverse SHM refund: %w", err)
+	}
+	pc.SHMRefund = refund
+	slog.Info("plan change: SHM refunded the old service, credit reduced", "user_id", userID, "plan_change", pc.ID,
+		"user_service_id", pc.UserServiceID, "shm_refund", refund)
+	return nil
+}
+
+// planChangeQuote — расчёт для активной услуги us и целевого тарифа serviceID той же категории бренда.
+func (s *Service) planChangeQuote(us *models.UserService, serviceID int) (*PlanChangeQuote, error) {
+	if strings.TrimSpace(us.Status) != "ACTIVE" || serviceID == us.BaseServiceID {
+		return nil, ErrPlanChangeUnavailable
+	}
+	if err := s.ensureServiceAllowedForOrder(serviceID); err != nil {
+		return nil, err
+	}
+	svc, err := s.apiClient.GetServiceByID(serviceID)
+	if err != nil {
+		return nil, fmt.Errorf("plan change service lookup: %w", err)
+	}
+	if svc == nil || svc.Cost <= 0 {
+		return nil, &ServiceCategoryDeniedError{ServiceID: serviceID}
+	}
+	bal, err := s.apiClient.GetUserBalance(us.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("plan change balance: %w", err)
+	}
+	cost := us.CostValue()
+	if cost <= 0 && us.BaseServiceID > 0 {
+		if cur, err := s.apiClient.GetServiceByID(us.BaseServiceID); err == nil && cur != nil {
+			cost = cur.Cost
+		}
+	}
+	credit, remaining := prorateUserService(us, cost, planChangeNow())
+	q := &PlanChangeQuote{
+		UserServiceID: us.ServiceID,
+		FromServiceID: us.BaseServiceID,
+		FromName:      us.Name,
+		ToServiceID:   svc.ServiceID,
+		ToName:        strings.TrimSpace(svc.Name),
+		Expire:        strings.TrimSpace(us.Expire),
+		Remaining:     remaining,
+		Credit:        credit,
+		Cost:          math.Round(svc.Cost*100) / 100,
+	}
+	if bal != nil {
+		q.Balance = bal.Balance
+	}
+	return q, nil
+}
+
+// prorateUserService — стоимость неиспользованной части оплаченного периода (с округлением вниз до копейки)
+// и оставшееся время. Начало периода — Expire минус Period; без разборчивых дат остаток нулевой.
+func prorateUserService(us *models.UserService, cost float64, now time.Time) (float64, time.Duration) {
+	expire, ok := parseSHMTime(us.Expire)
+	if !ok || cost <= 0 || !now.Before(expire) {
+		return 0, 0
+	}
+	start, ok := shmPeriodStart(expire, us.Period)
+	if !ok || !start.Before(expire) {
+		return 0, 0
+	}
+	remaining := expire.Sub(now)
+	total := expire.Sub(start)
+	if remaining > total {
+		remaining = total
+	}
+	credit := math.Floor(cost*float64(remaining)/float64(total)*100) / 100
+	return credit, remaining
+}
+
+// shmPeriodStart — начало периода, заканчивающегося в expire. Period в SHM — «месяцы.ддчч»:
+// целая часть — месяцы, первые две цифры дробной — дни, следующие две — часы («1» — месяц, «0.0012» — 12 часов).
+func shmPeriodStart(expire time.Time, period string) (time.Time, bool) {
+	whole, frac, _ := strings.Cut(strings.TrimSpace(strings.ReplaceAll(period, ",", ".")), ".")
+	months, err := strconv.Atoi(whole)
+	if err != nil || months < 0 || len(frac) > 4 {
+		return time.Time{}, false
+	}
+	frac = (frac + "0000")[:4]
+	days, err1 := strconv.Atoi(frac[:2])
+	hours, err2 := strconv.Atoi(frac[2:])
+	if err1 != nil || err2 != nil || months+days+hours == 0 {
+		return time.Time{}, false
+	}
+	return expire.AddDate(0, -months, -days).Add(-time.Duration(hours) * time.Hour), true
+}
+
+func planChangePaySystem(ps string) string {
+	if strings.TrimSpace(ps) == "" {
+		return "plan_change"
+	}
+	return ps
+}
+
+// planChangePayKey — uniq_key платежа смены тарифа: plan:<brand>:<id>:credit|reverse|shm_refund.
+func (s *Service) planChangePayKey(id, kind string) string {
+	return "plan:" + s.activeBrandID() + ":" + id + ":" + kind
+}
+
+// UserPlanChanges — журнал смен тарифа пользователя (новые в конце).
+func (s *Service) UserPlanChanges(userID int) ([]PlanChange, error) {
+	return s.loadPlanChanges(userID)
+}
+
+func (s *Service) loadPlanChanges(userID int) ([]PlanChange, error) {
+	settingsObj, err := s.loadSettingsMap(userID)
+	if err != nil {
+		return nil, err
+	}
+	var list []PlanChange
+	if raw, ok := settingsObj[planChangesSettingsKey]; ok && raw != nil {
+		b, err := json.Marshal(raw)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(b, &list); err != nil {
+			// Незавершённая смена — это деньги пользователя: повреждённый журнал не перезаписываем.
+			return nil, fmt.Errorf("settings.plan_changes: %w", err)
+		}
+	}
+	return list, nil
+}
+
+// savePlanChanges пишет settings.plan_changes; из завершённых записей хранятся 30 последних.
+func (s *Service) savePlanChanges(userID int, list []PlanChange) error {
+	closed := 0
+	for _, p := range list {
+		if !p.open() {
+			closed++
+		}
+	}
+	kept := make([]PlanChange, 0, len(list))
+	for _, p := range list {
+		if !p.open() && closed > planChangesMax {
+			closed--
+			continue
+		}
+		kept = append(kept, p)
+	}
+	return s.saveSettingsKey(userID, planChangesSettingsKey, kept)
+}
+
+func newPlanChangeID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+const planChangeServiceJSON = `{"user_service_id":501,"service_id":3,"user_id":42,"status":"ACTIVE","category":"vpn-mz-test","expire":"2026-11-01 10:00:00","period":"1","cost":"310","name":"Basic 1m"}`
+
+func planChangeTestService(t *testing.T, balance float64) (*fakeSHMUsers, *Service) {
+	t.Helper()
+	prev := planChangeNow
+	// 17.10 10:00 МСК: до конца периода 01.10–01.11 осталось 15 дней из 31.
+	planChangeNow = func() time.Time { return time.Date(2026, 10, 17, 7, 0, 0, 0, time.UTC) }
+	t.Cleanup(func() { planChangeNow = prev })
+	return renewalTestService(t, balance, planChangeServiceJSON)
+}
+
+func TestQuotePlanChange_Prorates(t *testing.T) {
+	fake, s := planChangeTestService(t, 100)
+	fake.setCatalog(t, `{"service_id":99,"allow_to_order":1,"cost":100,"category":"other","name":"Other"}`)
+	q, err := s.QuotePlanChange(42, 501, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Credit != 150 || q.Cost != 450 || q.Remaining != 15*24*time.Hour || q.Due() != 200 || q.Leftover() != 0 || q.ToName != "Premium 1m" {
+		t.Fatalf("%+v due=%v", q, q.Due())
+	}
+	if _, err := s.QuotePlanChange(42, 501, 3); !errors.Is(err, ErrPlanChangeUnavailable) {
+		t.Fatalf("same plan: %v", err)
+	}
+	if _, err := s.QuotePlanChange(42, 501, 99); !errors.Is(err, ErrServiceCategoryDenied) {
+		t.Fatalf("foreign plan: %v", err)
+	}
+	if _, err := s.QuotePlanChange(43, 501, 7); !errors.Is(err, ErrUserServiceUnavailable) {
+		t.Fatalf("foreign service: %v", err)
+	}
+}
+
+func TestShmPeriodStart(t *testing.T) {
+	exp := time.Date(2026, 11, 1, 10, 0, 0, 0, time.UTC)
+	for period, want := range map[string]time.Time{
+		"1":      time.Date(2026, 10, 1, 10, 0, 0, 0, time.UTC),
+		"12":     time.Date(2025, 11, 1, 10, 0, 0, 0, time.UTC),
+		"0.10":   time.Date(2026, 10, 22, 10, 0, 0, 0, time.UTC),
+		"0.0012": time.Date(2026, 10, 31, 22, 0, 0, 0, time.UTC),
+		"1.01":   time.Date(2026, 9, 30, 10, 0, 0, 0, time.UTC),
+	} {
+		if got, ok := shmPeriodStart(exp, period); !ok || !got.Equal(want) {
+			t.Errorf("%q: %v %v", period, got, ok)
+		}
+	}
+	for _, period := range []string{"", "0", "x", "1.12345"} {
+		if _, ok := shmPeriodStart(exp, period); ok {
+			t.Errorf("%q accepted", period)
+		}
+	}
+}
+
+func TestChangePlan_CreditsOrdersAndRetires(t *testing.T) {
+	fake, s := planChangeTestService(t, 300)
+	req := PlanChangeRequest{UserID: 42, UserServiceID: 501, ServiceID: 7, Expire: "2026-11-01 10:00:00"}
+
+	pc, err := s.ChangePlan(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pc.Status != PlanChangeDone || pc.NewUserServiceID != 901 || pc.Credit != 150 || fake.orderCount() != 1 {
+		t.Fatalf("%+v orders=%d", pc, fake.orderCount())
+	}
+	if d := fake.deletedServices(); len(d) != 1 || d[0] != "501" {
+		t.Fatalf("deleted %v", d)
+	}
+	pays := fake.payRows(42)
+	if len(pays) != 1 {
+		t.Fatalf("pays %v", pays)
+	}
+	if p := pays[0].(map[string]interface{}); p["money"] != 150.0 || p["uniq_key"] != "plan:vff:"+pc.ID+":credit" || p["pay_system_id"] != "plan_change" {
+		t.Fatalf("%+v", p)
+	}
+	list, err := s.UserPlanChanges(42)
+	if err != nil || len(list) != 1 || list[0].Status != PlanChangeDone {
+		t.Fatalf("%+v %v", list, err)
+	}
+
+	if _, err := s.ChangePlan(req); !errors.Is(err, ErrUserServiceUnavailable) || fake.orderCount() != 1 {
+		t.Fatalf("repeat: %v orders=%d", err, fake.orderCount())
+	}
+}
+
+func TestChangePlan_RejectsStaleAndShortBalance(t *testing.T) {
+	fake, s := planChangeTestService(t, 100)
+	if _, err := s.ChangePlan(PlanChangeRequest{UserID: 42, UserServiceID: 501, ServiceID: 7, Expire: "2026-11-01 10:00:00"}); !errors.Is(err, ErrPlanChangeInsufficientBalance) {
+		t.Fatalf("short balance: %v", err)
+	}
+	if _, err := s.ChangePlan(PlanChangeRequest{UserID: 42, UserServiceID: 501, ServiceID: 7, Expire: "2026-10-01 10:00:00"}); !errors.Is(err, ErrPlanChangeStale) {
+		t.Fatalf("stale: %v", err)
+	}
+	if fake.orderCount() != 0 || len(fake.payRows(42)) != 0 {
+		t.Fatalf("orders=%d pays=%v", fake.orderCount(), fake.payRows(42))
+	}
+}
+
+func TestChangePlan_OrderFailureReversesCredit(t *testing.T) {
+	fake, s := planChangeTestService(t, 300)
+	fake.failOrders = true
+	if _, err := s.ChangePlan(PlanChangeRequest{UserID: 42, UserServiceID: 501, ServiceID: 7, Expire: "2026-11-01 10:00:00"}); !errors.Is(err, ErrPlanChangeOrderFailed) {
+		t.Fatalf("%v", err)
+	}
+	if bal := fake.row(42)["balance"]; bal != 300.0 {
+		t.Fatalf("balance %v", bal)
+	}
+	if len(fake.deletedServices()) != 0 || fake.userService(42, 501) == nil {
+		t.Fatal("old service must stay")
+	}
+	list, _ := s.UserPlanChanges(42)
+	if len(list) != 1 || list[0].Status != PlanChangeReverted {
+		t.Fatalf("%+v", list)
+	}
+}
+
+func TestChangePlan_ExistingUnpaidServiceKeepsOldService(t *testing.T) {
+	fake, s := planChangeTestService(t, 300)
+	fake.orderReturns = map[string]interface{}{"user_service_id": 777.0, "service_id": 3.0, "user_id": 42.0, "status": "NOT PAID"}
+	if _, err := s.ChangePlan(PlanChangeRequest{UserID: 42, UserServiceID: 501, ServiceID: 7, Expire: "2026-11-01 10:00:00"}); !errors.Is(err, ErrPlanChangeOrderFailed) {
+		t.Fatalf("%v", err)
+	}
+	if bal := fake.row(42)["balance"]; bal != 300.0 {
+		t.Fatalf("credit must be reversed, balance %v", bal)
+	}
+	if len(fake.deletedServices()) != 0 || fake.userService(42, 501) == nil {
+		t.Fatal("old service must stay")
+	}
+	list, _ := s.UserPlanChanges(42)
+	if len(list) != 1 || list[0].Status != PlanChangeReverted || list[0].NewUserServiceID != 777 {
+		t.Fatalf("%+v", list)
+	}
+}
+
+func TestChangePlan_BalanceAfterSHMRefundOnDelete(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		refund    float64
+		withdraws string
+		want      float64
+		reversed  float64
+	}{
+		{name: "no SHM refund", withdraws: `[{"withdraw_id":1,"user_service_id":501,"total":310}]`},
+		{name: "SHM refunds the unused period", refund: 150, withdraws: `[{"withdraw_id":1,"user_service_id":501,"total":310}]`, reversed: 150},
+		{name: "SHM refunds by its own formula", refund: 140, withdraws: `[{"withdraw_id":1,"user_service_id":501,"total":310}]`, reversed: 140},
+		// Баланс вырос, а списания по услуге не изменились — это не возврат SHM (например, пополнение).
+		{name: "balance grows without refund", refund: 50, withdraws: `[]`, want: 50},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake, s := planChangeTestService(t, 300)
+			fake.chargeOrders = true
+			fake.refundOnDelete = tc.refund
+			fake.setRows(t, "withdraw", 42, tc.withdraws)
+
+			pc, err := s.ChangePlan(PlanChangeRequest{UserID: 42, UserServiceID: 501, ServiceID: 7, Expire: "2026-11-01 10:00:00"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// 300 на балансе + 150 за остаток − 450 за новый тариф: остаток оплачивается ровно один раз.
+			if bal := fake.row(42)["balance"]; bal != tc.want {
+				t.Fatalf("balance %v, want %v", bal, tc.want)
+			}
+			if pc.Status != PlanChangeDone || pc.Credit != 150 || pc.SHMRefund != tc.reversed {
+				t.Fatalf("%+v", pc)
+			}
+			pays := fake.payRows(42)
+			if tc.reversed == 0 {
+				if len(pays) != 1 {
+					t.Fatalf("pays %v", pays)
+				}
+				return
+			}
+			if len(pays) != 2 {
+				t.Fatalf("pays %v", pays)
+			}
+			if p := pays[1].(map[string]interface{}); p["money"] != -tc.reversed || p["uniq_key"] != "plan:vff:"+pc.ID+":shm_refund" {
+				t.Fatalf("%+v", p)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package service
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestGenerateQRCodePNG_Size(t *testing.T) {
+	b, err := GenerateQRCodePNG("https://sub.example/s", 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := img.Bounds().Dx(); w != 512 {
+		t.Fatalf("width %d", w)
+	}
+}
+
+func TestGenerateQRCodeSVG(t *testing.T) {
+	b, err := GenerateQRCodeSVG("https://sub.example/s", 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(b)
+	if !strings.HasPrefix(s, `<svg xmlns="http://www.w3.org/2000/svg" width="300" height="300"`) ||
+		!strings.Contains(s, "h1v1h-1z") || !strings.HasSuffix(s, "</svg>") {
+		t.Fatalf("%.200s", s)
+	}
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/models"
+	"github.com/ryabkov82/vpnbot/internal/receipts"
+)
+
+// ErrPaymentNotFound — платёж не найден у пользователя (или скрыт как отменённый нулевой).
+var ErrPaymentNotFound = errors.New("payment not found")
+
+// receiptNow — время формирования документа; подменяется в тестах.
+var receiptNow = time.Now
+
+// PaymentReceipt — квитанция по платежу payID пользователя userID со списаниями, оплаченными из него (FIFO).
+func (s *Service) PaymentReceipt(userID, payID int) (*receipts.Document, error) {
+	u, pays, withdrawals, err := s.moneyHistory(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range pays {
+		if p.Pay.ID == payID {
+			return receipts.Receipt(s.brand.Name, receiptUser(u), p, receipts.ConsumedBy(pays, withdrawals, payID), receiptNow().In(shmMoscow)), nil
+		}
+	}
+	return nil, ErrPaymentNotFound
+}
+
+// MonthlyStatement — выписка за календарный месяц month (границы по московскому времени SHM).
+func (s *Service) MonthlyStatement(userID int, month time.Time) (*receipts.Document, error) {
+	u, pays, withdrawals, err := s.moneyHistory(userID)
+	if err != nil {
+		return nil, err
+	}
+	return receipts.Statement(s.brand.Name, receiptUser(u), month.In(shmMoscow), pays, withdrawals, receiptNow().In(shmMoscow)), nil
+}
+
+func receiptUser(u *models.User) receipts.User {
+	return receipts.User{ID: u.ID, Login: u.Login}
+}
+
+// moneyHistory — пользователь, видимые платежи и списания с датами в московском времени
+// (квитанции, выписки, журнал баланса). Строки с неразборчивой датой пропускаются: на шкалу времени их не поставить.
+func (s *Service) moneyHistory(userID int) (*models.User, []receipts.Payment, []receipts.Withdrawal, error) {
+	if userID <= 0 {
+		return nil, nil, nil, errors.New("invalid user id")
+	}
+	u, err := s.apiClient.GetUserByID(userID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if u == nil {
+		return nil, nil, nil, ErrUserNotFound
+	}
+	pays, err := s.apiClient.GetUserPays(userID)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("money history pays: %w", err)
+	}
+	items, err := s.apiClient.GetUserWithdrawals(userID)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("money history withdrawals: %w", err)
+	}
+
+	var payments []receipts.Payment
+	for _, p := range models.VisibleUserPays(pays) {
+		if at, ok := parseSHMTime(p.Date); ok {
+			payments = append(payments, receipts.Payment{Pay: p, PaidAt: at})
+		}
+	}
+	var withdrawals []receipts.Withdrawal
+	for _, w := range items {
+		date := w.WithdrawDate
+		if strings.TrimSpace(date) == "" {
+			date = w.CreateDate
+		}
+		if at, ok := parseSHMTime(date); ok {
+			withdrawals = append(withdrawals, receipts.Withdrawal{Item: w, WithdrawnAt: at})
+		}
+	}
+	return u, payments, withdrawals, nil
+}
+
+func parseSHMTime(v string) (time.Time, bool) {
+	at, err := time.ParseInLocation(shmPayDateLayout, strings.TrimSpace(v), shmMoscow)
+	return at, err == nil
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPaymentReceiptAndStatement(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	orig := receiptNow
+	receiptNow = func() time.Time { return now }
+	t.Cleanup(func() { receiptNow = orig })
+
+	fake, client := newFakeSHMUsers(t, `{"user_id":42,"login":"@42","settings":{}}`)
+	fake.setRows(t, "pay", 42, `[{"id":5,"user_id":42,"money":300,"pay_system_id":"yookassa","date":"2026-10-01 12:00:00"},{"id":6,"user_id":42,"money":0,"date":"2026-10-02 12:00:00","comment":{"status":"canceled"}}]`)
+	fake.setRows(t, "withdraw", 42, `[{"withdraw_id":1,"user_id":42,"name":"VPN","total":199,"months":1,"withdraw_date":"2026-10-01 12:01:00"},{"withdraw_id":2,"user_id":42,"name":"VPN","total":199,"months":1,"withdraw_date":"2026-09-30 23:59:00"}]`)
+	s := NewService(client, testServiceBrand())
+
+	d, err := s.PaymentReceipt(42, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Brand != "Test Brand" || d.Fields[1].Value != "01.10.2026 12:00" || len(d.Tables[0].Rows) != 1 || d.Footer != "Сформировано 18.10.2026 12:00 (МСК)." {
+		t.Fatalf("%+v", d)
+	}
+	if _, err := s.PaymentReceipt(42, 99); !errors.Is(err, ErrPaymentNotFound) {
+		t.Fatalf("err=%v", err)
+	}
+
+	// Граница месяца — по Москве: 2026-09-30 23:59 МСК в октябрь не попадает.
+	st, err := s.MonthlyStatement(42, time.Date(2026, 10, 1, 0, 0, 0, 0, shmMoscow))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(st.Tables[1].Rows) != 1 || st.Tables[1].Total != "199 руб." {
+		t.Fatalf("%+v", st.Tables[1])
+	}
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/models"
+)
+
+var (
+	// ErrRenewalUnavailable — услугу нельзя продлить: статус без следующего периода или неизвестна стоимость.
+	ErrRenewalUnavailable = errors.New("service cannot be renewed")
+	// ErrRenewalStale — с момента предпросмотра услуга изменилась (уже продлена или сменила статус).
+	ErrRenewalStale = errors.New("service changed since renewal preview")
+	// ErrRenewalInsufficientBalance — баланса не хватает на следующий период.
+	ErrRenewalInsufficientBalance = errors.New("insufficient balance for renewal")
+)
+
+// RenewalPreview — следующее продление услуги: когда и сколько спишет SHM и хватает ли баланса.
+type RenewalPreview struct {
+	UserServiceID int
+	Name          string
+	Status        string
+	// Expire — дата окончания как в SHM; RenewUserService сверяет её, чтобы повторное нажатие не продлило дважды.
+	Expire       string
+	NextChargeAt time.Time // нулевое — дата неизвестна
+	Amount       float64
+	Balance      float64
+	AutoRenew    bool
+}
+
+// Renewable — у услуги есть следующий период: она активна или заблокирована за неуплату, стоимость известна.
+func (p RenewalPreview) Renewable() bool {
+	return (p.Status == "ACTIVE" || p.Status == "BLOCK") && p.Amount > 0
+}
+
+// Shortfall — сколько не хватает на балансе до стоимости следующего периода.
+func (p RenewalPreview) Shortfall() float64 {
+	if d := p.Amount - p.Balance; d > 0.005 {
+		return math.Round(d*100) / 100
+	}
+	return 0
+}
+
+// Covered — баланса хватает на следующий период.
+func (p RenewalPreview) Covered() bool {
+	return p.Shortfall() == 0
+}
+
+// BuildRenewalPreview — предпросмотр без обращений к SHM: стоимость периода amount и баланс уже известны вызывающему.
+func BuildRenewalPreview(us *models.UserService, amount, balance float64) RenewalPreview {
+	p := RenewalPreview{
+		UserServiceID: us.ServiceID,
+		Name:          us.Name,
+		Status:        strings.TrimSpace(us.Status),
+		Expire:        strings.TrimSpace(us.Expire),
+		Amount:        amount,
+		Balance:       balance,
+		AutoRenew:     us.AutoRenew(),
+	}
+	if at, ok := parseSHMTime(us.Expire); ok {
+		p.NextChargeAt = at
+	}
+	return p
+}
+
+// RenewalPreview — предпросмотр продления услуги пользователя userID.
+func (s *Service) RenewalPreview(userID, userServiceID int) (*RenewalPreview, error) {
+	us, err := s.GetOwnedUserServiceByUserID(userID, strconv.Itoa(userServiceID))
+	if err != nil {
+		return nil, err
+	}
+	return s.renewalPreview(us)
+}
+
+// SetAutoRenew сохраняет выбор пользователя в user_service.settings.auto_renew. Выключение дополнительно ставит
+// next = -1, чтобы SHM не продлил услугу сам; включение снимает только этот запрет (переход на другую услугу не трогается).
+func (s *Service) SetAutoRenew(userID, userServiceID int, enabled bool) (*RenewalPreview, error) {
+	s.renewalMu.Lock()
+	defer s.renewalMu.Unlock()
+	us, err := s.GetOwnedUserServiceByUserID(userID, strconv.Itoa(userServiceID))
+	if err != nil {
+		return nil, err
+	}
+	// SHM заменяет settings услуги целиком: нечитаемые settings не перезаписываем пустым объектом.
+	settings := map[string]interface{}{}
+	if raw := bytes.TrimSpace(us.SettingsRaw); len(raw) > 0 && !bytes.Equal(raw, []byte("null")) {
+		if err := json.Unmarshal(raw, &settings); err != nil {
+			return nil, fmt.Errorf("auto renew: user service %d settings: %w", us.ServiceID, err)
+		}
+		if settings == nil {
+			settings = map[string]interface{}{}
+		}
+	}
+	settings[models.UserServiceAutoRenewKey] = enabled
+	fields := map[string]interface{}{"settings": settings}
+	switch {
+	case !enabled:
+		fields["next"] = models.UserServiceNextNoRenew
+		us.Next = models.UserServiceNextNoRenew
+	case us.Next == models.UserServiceNextNoRenew:
+		fields["next"] = nil
+		us.Next = 0
+	}
+	if err := s.apiClient.UpdateUserService(userID, us.ServiceID, fields); err != nil {
+		return nil, fmt.Errorf("auto renew update: %w", err)
+	}
+	if us.SettingsRaw, err = json.Marshal(settings); err != nil {
+		return nil, err
+	}
+	slog.Info("auto renew changed", "brand_id", s.activeBrandID(), "user_id", userID, "user_service_id", us.ServiceID, "enabled", enabled)
+	return s.renewalPreview(us)
+}
+
+// RenewUserService продлевает услугу на следующий период с баланса. expectedExpire — Expire из предпросмотра:
+// если услуга с тех пор изменилась, возвращается ErrRenewalStale и деньги не списываются.
+func (s *Service) RenewUserService(userID, userServiceID int, expectedExpire string) (*RenewalPreview, error) {
+	s.renewalMu.Lock()
+	defer s.renewalMu.Unlock()
+	usKey := strconv.Itoa(userServiceID)
+	us, err := s.GetOwnedUserServiceByUserID(userID, usKey)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(us.Expire) != strings.TrimSpace(expectedExpire) {
+		return nil, ErrRenewalStale
+	}
+	before, err := s.renewalPreview(us)
+	if err != nil {
+		return nil, err
+	}
+	if !before.Renewable() {
+		return nil, ErrRenewalUnavailable
+	}
+	if !before.Covered() {
+		return nil, ErrRenewalInsufficientBalance
+	}
+	if _, err := s.apiClient.ProlongUserService(userID, us.ServiceID); err != nil {
+		return nil, fmt.Errorf("renew user service: %w", err)
+	}
+	slog.Info("user service renewed", "brand_id", s.activeBrandID(), "user_id", userID, "user_service_id", us.ServiceID,
+		"amount", before.Amount, "expire_before", before.Expire)
+
+	us, err = s.GetOwnedUserServiceByUserID(userID, usKey)
+	if err != nil {
+		return nil, err
+	}
+	return s.renewalPreview(us)
+}
+
+// renewalPreview дозагружает баланс и, если у user_service нет своей стоимости, цену из каталога.
+func (s *Service) renewalPreview(us *models.UserService) (*RenewalPreview, error) {
+	bal, err := s.apiClient.GetUserBalance(us.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("renewal balance: %w", err)
+	}
+	var balance float64
+	if bal != nil {
+		balance = bal.Balance
+	}
+	amount := us.CostValue()
+	if amount <= 0 && us.BaseServiceID > 0 {
+		if svc, err := s.apiClient.GetServiceByID(us.BaseServiceID); err == nil && svc != nil && svc.Cost > 0 {
+			amount = math.Round(svc.Cost*100) / 100
+		}
+	}
+	p := BuildRenewalPreview(us, amount, balance)
+	return &p, nil
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"errors"
+	"testing"
+)
+
+func renewalTestService(t *testing.T, balance float64, serviceJSON string) (*fakeSHMUsers, *Service) {
+	t.Helper()
+	fake, client := newFakeSHMUsers(t, `{"user_id":42,"login":"@42","settings":{}}`)
+	fake.rows[42]["balance"] = balance
+	fake.setCatalog(t, orderIntentCatalogJSON)
+	fake.setRows(t, "service", 42, "["+serviceJSON+"]")
+	return fake, NewService(client, orderBrandCfg("vff", "vpn-mz-test"))
+}
+
+func TestSetAutoRenew_PersistsSettingsAndNext(t *testing.T) {
+	fake, s := renewalTestService(t, 100,
+		`{"user_service_id":501,"service_id":7,"user_id":42,"status":"ACTIVE","category":"vpn-mz-test","expire":"2026-11-01 10:00:00","cost":"450","settings":{"note":"keep"}}`)
+
+	p, err := s.SetAutoRenew(42, 501, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.AutoRenew || p.Amount != 450 || p.Covered() || p.Shortfall() != 350 || p.NextChargeAt.IsZero() {
+		t.Fatalf("%+v", p)
+	}
+	us := fake.userService(42, 501)
+	settings := us["settings"].(map[string]interface{})
+	if us["next"] != -1.0 || settings["auto_renew"] != false || settings["note"] != "keep" {
+		t.Fatalf("%+v", us)
+	}
+
+	p, err = s.SetAutoRenew(42, 501, true)
+	if err != nil || !p.AutoRenew {
+		t.Fatalf("%+v %v", p, err)
+	}
+	if us := fake.userService(42, 501); us["next"] != nil || us["settings"].(map[string]interface{})["auto_renew"] != true {
+		t.Fatalf("%+v", us)
+	}
+	if _, err := s.SetAutoRenew(43, 501, false); !errors.Is(err, ErrUserServiceUnavailable) {
+		t.Fatalf("foreign service: %v", err)
+	}
+}
+
+func TestSetAutoRenew_UnreadableSettingsAreNotReplaced(t *testing.T) {
+	fake, s := renewalTestService(t, 100,
+		`{"user_service_id":501,"service_id":7,"user_id":42,"status":"ACTIVE","category":"vpn-mz-test","expire":"2026-11-01 10:00:00","settings":"legacy"}`)
+
+	if _, err := s.SetAutoRenew(42, 501, false); err == nil {
+		t.Fatal("expected error")
+	}
+	if us := fake.userService(42, 501); us["settings"] != "legacy" || us["next"] != nil {
+		t.Fatalf("%+v", us)
+	}
+}
+
+func TestRenewUserService_ChargesOncePerPreview(t *testing.T) {
+	fake, s := renewalTestService(t, 300,
+		`{"user_service_id":501,"service_id":7,"user_id":42,"status":"ACTIVE","category":"vpn-mz-test","expire":"2026-11-01 10:00:00"}`)
+
+	p, err := s.RenewalPreview(42, 501)
+	if err != nil || p.Amount != 450 || !p.Renewable() || !p.AutoRenew {
+		t.Fatalf("catalog price: %+v %v", p, err)
+	}
+	if _, err := s.RenewUserService(42, 501, p.Expire); !errors.Is(err, ErrRenewalInsufficientBalance) || fake.prolongCount() != 0 {
+		t.Fatalf("err=%v prolongs=%d", err, fake.prolongCount())
+	}
+
+	fake.userService(42, 501)["cost"] = "200"
+	got, err := s.RenewUserService(42, 501, p.Expire)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Expire != "2026-12-01 10:00:00" || got.Balance != 100 || fake.prolongCount() != 1 {
+		t.Fatalf("%+v prolongs=%d", got, fake.prolongCount())
+	}
+	if _, err := s.RenewUserService(42, 501, p.Expire); !errors.Is(err, ErrRenewalStale) || fake.prolongCount() != 1 {
+		t.Fatalf("repeat with old preview: err=%v prolongs=%d", err, fake.prolongCount())
+	}
+}
+
+func TestRenewUserService_NotRenewableStatus(t *testing.T) {
+	fake, s := renewalTestService(t, 1000,
+		`{"user_service_id":501,"service_id":7,"user_id":42,"status":"NOT PAID","category":"vpn-mz-test","expire":"","cost":"450"}`)
+	if _, err := s.RenewUserService(42, 501, ""); !errors.Is(err, ErrRenewalUnavailable) || fake.prolongCount() != 0 {
+		t.Fatalf("err=%v", err)
+	}
+}
@@ -23,8 +23,8 @@ var (
 	ErrUserNotFound = errors.New("user not found")
 	// ErrServiceCategoryDenied — заказ услуги запрещён из‑за category / identity brand boundary.
 	ErrServiceCategoryDenied = errors.New("service category denied")
-	// ErrAttributionWrongChannel — RegisterUserWithAttribution принимает только telegram channel.
-	ErrAttributionWrongChannel = errors.New("attribution registration channel must be telegram")
+	// ErrAttributionWrongChannel — RegisterUserWithAttribution принимает только telegram и gift (регистрация в боте).
+	ErrAttributionWrongChannel = errors.New("attribution registration channel must be telegram or gift")
 )
 
 // ServiceCategoryDeniedError — внутренняя ошибка fail-closed category guard перед ServiceOrder.
@@ -50,6 +50,28 @@ type Service struct {
 	trialCacheMu       sync.RWMutex
 	trialEligibleUntil map[int64]time.Time
 	trialMu            sync.RWMutex
+	// trafficAlertsMu сериализует read-modify-write settings.traffic_alerts.
+	trafficAlertsMu sync.Mutex
+	// cardCheckoutMu сериализует обработку оплат картой (settings.card_checkouts).
+	cardCheckoutMu sync.Mutex
+	// paymentIntentsMu сериализует settings.payment_intents; pendingIntentUsers — кого проверять на зачисление,
+	// intentScanSince — до какого момента просмотрены платежи SHM (DiscoverPaymentIntentUsers).
+	paymentIntentsMu   sync.Mutex
+	pendingIntentUsers map[int]struct{}
+	intentScanSince    time.Time
+	// voucherMu сериализует активацию кодов в процессе (между процессами — uniq_key платежа в SHM).
+	voucherMu sync.Mutex
+	// giftMu сериализует settings.gifts; pendingGiftBuyers — чьи подарки ждут получателя (для возвратов),
+	// giftBuyersDiscovered — очередь уже восстановлена по списаниям в SHM (DiscoverGiftBuyers).
+	giftMu               sync.Mutex
+	pendingGiftBuyers    map[int]struct{}
+	giftBuyersDiscovered bool
+	// renewalMu сериализует изменение автопродления и продление с баланса (повторное нажатие ждёт первое).
+	renewalMu sync.Mutex
+	// planChangeMu сериализует settings.plan_changes (смена тарифа с пересчётом).
+	planChangeMu sync.Mutex
+	// settingsLocks — общий замок записи settings пользователя (saveSettingsKey).
+	settingsLocks settingsLocks
 }
 
 // NewService создаёт use-case слой с активным брендом процесса (web-login prefix и source).
@@ -61,6 +83,8 @@ func NewService(apiClient *api.APIClient, brand config.BrandConfig) *Service {
 		brand:              effectiveServiceBrand(brand),
 		trialTakenCache:    make(map[int64]bool),
 		trialEligibleUntil: make(map[int64]time.Time),
+		pendingIntentUsers: make(map[int]struct{}),
+		pendingGiftBuyers:  make(map[int]struct{}),
 	}
 }
 
@@ -125,7 +149,8 @@ func (s *Service) GetUser(chatID int64) (*models.User, error) {
 		return nil, err
 	}
 	if user == nil {
-		return nil, nil
+		// Web-аккаунт с обратной привязкой Telegram (login2 = канонический Telegram login).
+		return s.getTelegramLinkedWebUser(brandID, chatID, login)
 	}
 	if user.Settings.Telegram.ChatID != chatID {
 		logIdentityMismatch("telegram_chat_id", user.Login, strings.TrimSpace(user.Settings.BrandID), chatID, user.Settings.Telegram.ChatID)
@@ -153,7 +178,9 @@ func (s *Service) RegisterUserWithAttribution(user models.UserRegistrationReques
 	if !record.Valid() {
 		return ErrAttributionRequired
 	}
-	if record.FirstTouch.RegistrationChannel != attribution.RegistrationChannelTelegram {
+	switch record.FirstTouch.RegistrationChannel {
+	case attribution.RegistrationChannelTelegram, attribution.RegistrationChannelGift:
+	default:
 		return ErrAttributionWrongChannel
 	}
 	return s.registerUserCore(user, &record)
@@ -251,11 +278,24 @@ func (s *Service) GetUserKeyMarzban(telegramChatID int64, serviceID string) (*mo
 	if err != nil {
 		return nil, err
 	}
+	return s.userKeyMarzban(user.ID, us)
+}
+
+// GetUserKeyMarzbanByUserID — ключи Marzban услуги SHM-пользователя (web-кабинет) с проверкой владения.
+func (s *Service) GetUserKeyMarzbanByUserID(userID int, userServiceID string) (*models.UserKeyMarzban, error) {
+	us, err := s.GetOwnedUserServiceByUserID(userID, userServiceID)
+	if err != nil {
+		return nil, err
+	}
+	return s.userKeyMarzban(userID, us)
+}
+
+func (s *Service) userKeyMarzban(userID int, us *models.UserService) (*models.UserKeyMarzban, error) {
 	if us.KeyMarzban.SubscriptionURL != "" || len(us.KeyMarzban.Links) > 0 {
 		k := us.KeyMarzban
 		return &k, nil
 	}
-	return s.apiClient.GetUserKeyMarzban(user.ID, us.ServiceID)
+	return s.apiClient.GetUserKeyMarzban(userID, us.ServiceID)
 }
 
 func (s *Service) DeleteUserService(telegramChatID int64, serviceID string) error {
@@ -266,8 +306,13 @@ func (s *Service) DeleteUserService(telegramChatID int64, serviceID string) erro
 	return s.apiClient.DeleteUserService(user.ID, serviceID)
 }
 
-// generateQRCode создает QR-код из текста и возвращает PNG в виде []byte
+// GenerateQRCode создает QR-код из текста и возвращает PNG 256×256 в виде []byte
 func GenerateQRCode(text string) ([]byte, error) {
+	return GenerateQRCodePNG(text, 256)
+}
+
+// GenerateQRCodePNG — QR-код (коррекция High) в PNG заданного размера в пикселях.
+func GenerateQRCodePNG(text string, size int) ([]byte, error) {
 	// Генерируем QR-код с высоким уровнем коррекции ошибок (High)
 	qr, err := qrcode.New(text, qrcode.High)
 	if err != nil {
@@ -276,7 +321,7 @@ func GenerateQRCode(text string) ([]byte, error) {
 
 	// Получаем PNG-изображение
 	var buf bytes.Buffer
-	err = png.Encode(&buf, qr.Image(256)) // 256 - размер в пикселях
+	err = png.Encode(&buf, qr.Image(size))
 	if err != nil {
 		return nil, err
 	}
@@ -284,6 +329,28 @@ func GenerateQRCode(text string) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// GenerateQRCodeSVG — тот же QR-код в SVG: модули одним path, viewBox в модулях, размер — width/height.
+func GenerateQRCodeSVG(text string, size int) ([]byte, error) {
+	qr, err := qrcode.New(text, qrcode.High)
+	if err != nil {
+		return nil, err
+	}
+	bitmap := qr.Bitmap()
+	n := len(bitmap)
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, size, size, n, n)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, n, n)
+	for y, row := range bitmap {
+		for x, on := range row {
+			if on {
+				fmt.Fprintf(&b, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+	b.WriteString(`"/></svg>`)
+	return b.Bytes(), nil
+}
+
 func (s *Service) GetServices() ([]models.Service, error) {
 
 	return s.apiClient.GetServices()
@@ -327,6 +394,12 @@ func (s *Service) ServiceOrderByUserID(userID int, serviceID int) (*models.UserS
 	return s.apiClient.ServiceOrder(userID, serviceID)
 }
 
+// orderedAsRequested — заказ дал оплаченную услугу serviceID. ServiceOrder шлёт check_exists_unpaid, поэтому SHM
+// может вернуть ранее заказанную неоплаченную услугу другого тарифа, а новый заказ без денег остаётся NOT PAID.
+func orderedAsRequested(us *models.UserService, serviceID int) bool {
+	return us != nil && us.BaseServiceID == serviceID && strings.EqualFold(strings.TrimSpace(us.Status), "ACTIVE")
+}
+
 // ensureServiceAllowedForOrder повторно читает услугу из SHM и fail-closed сверяет category
 // активного бренда непосредственно перед mutation ServiceOrder.
 func (s *Service) ensureServiceAllowedForOrder(serviceID int) error {
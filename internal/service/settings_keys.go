@@ -0,0 +1,39 @@
+package service
+
+import "sync"
+
+// settingsLockStripes — число замков settings: пользователь попадает в полосу userID % settingsLockStripes.
+const settingsLockStripes = 64
+
+// settingsLocks — общие для всех фич замки записи settings (по полосам user_id).
+type settingsLocks [settingsLockStripes]sync.Mutex
+
+func (l *settingsLocks) forUser(userID int) *sync.Mutex {
+	i := userID % settingsLockStripes
+	if i < 0 {
+		i = -i
+	}
+	return &l[i]
+}
+
+// saveSettingsKey записывает в settings пользователя только key (nil — удалить ключ). Settings перечитываются
+// из SHM непосредственно перед записью под общим per-user замком, поэтому одновременная запись другой фичи
+// (подарки, намерения оплаты, смена тарифа…) не затирается устаревшей копией. Ключ фичи сериализует её собственный mutex.
+// Все остальные записи settings целиком (привязка email и Telegram, смена email, удаление аккаунта, merge) берут
+// тот же замок и перечитывают settings под ним. Замок действует в пределах процесса: бот и кабинет работают в одном,
+// а отдельные команды (shm-user-merge) от гонки с ними не защищены.
+func (s *Service) saveSettingsKey(userID int, key string, value interface{}) error {
+	mu := s.settingsLocks.forUser(userID)
+	mu.Lock()
+	defer mu.Unlock()
+	settingsObj, err := s.loadSettingsMap(userID)
+	if err != nil {
+		return err
+	}
+	if value == nil {
+		delete(settingsObj, key)
+	} else {
+		settingsObj[key] = value
+	}
+	return s.apiClient.PostAdminUserUpdateFields(userID, map[string]interface{}{"settings": settingsObj})
+}
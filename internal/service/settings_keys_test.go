@@ -0,0 +1,54 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSaveSettingsKey_KeepsOtherFeatures(t *testing.T) {
+	fake, client := newFakeSHMUsers(t, `{"user_id":42,"login":"@42","settings":{"telegram":{"chat_id":42}}}`)
+	s := NewService(client, orderBrandCfg("vff", "vpn-mz-test"))
+
+	// Подарки прочитаны до того, как другая фича записала намерение оплаты: запись подарков его не затирает.
+	gifts, err := s.loadGifts(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreatePaymentIntent(42, 300, "yookassa", "vff", 1); err != nil {
+		t.Fatal(err)
+	}
+	gifts = append(gifts, Gift{ID: "g1", Status: GiftPending, CreatedAt: time.Now().UTC()})
+	if err := s.saveGifts(42, gifts); err != nil {
+		t.Fatal(err)
+	}
+	settings := fake.row(42)["settings"].(map[string]interface{})
+	if settings[paymentIntentsSettingsKey] == nil || settings[giftsSettingsKey] == nil || settings["telegram"] == nil {
+		t.Fatalf("%+v", settings)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := s.saveSettingsKey(42, fmt.Sprintf("k%d", i), i); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	settings = fake.row(42)["settings"].(map[string]interface{})
+	for i := 0; i < 16; i++ {
+		if settings[fmt.Sprintf("k%d", i)] == nil {
+			t.Fatalf("k%d lost: %+v", i, settings)
+		}
+	}
+	if err := s.saveSettingsKey(42, "k0", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fake.row(42)["settings"].(map[string]interface{})["k0"]; ok {
+		t.Fatal("nil value must delete the key")
+	}
+}
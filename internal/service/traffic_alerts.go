@@ -0,0 +1,55 @@
+package service
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/models"
+)
+
+// trafficAlertsSettingsKey — settings.traffic_alerts: {"<user_service_id>": {"period": "...", "threshold": 80, "at": "..."}}.
+// Хранится в SHM, чтобы рестарт бота не приводил к повторным уведомлениям.
+const trafficAlertsSettingsKey = "traffic_alerts"
+
+// ListActiveUserServices — все активные услуги бренда (для фоновых задач).
+func (s *Service) ListActiveUserServices() ([]models.UserService, error) {
+	return s.apiClient.ListActiveUserServices()
+}
+
+// ClaimTrafficAlert атомарно для одного процесса отмечает, что по услуге в периоде period отправлено уведомление
+// о пороге threshold (%). Возвращает false, если в этом периоде уже отмечен такой же или более высокий порог.
+// Отметка пишется до отправки: при сбое доставки повторной рассылки не будет.
+func (s *Service) ClaimTrafficAlert(userID, userServiceID int, period string, threshold int) (bool, error) {
+	if userID <= 0 || userServiceID <= 0 || period == "" || threshold <= 0 {
+		return false, errors.New("invalid traffic alert claim")
+	}
+	s.trafficAlertsMu.Lock()
+	defer s.trafficAlertsMu.Unlock()
+
+	settingsObj, err := s.loadSettingsMap(userID)
+	if err != nil {
+		return false, err
+	}
+	alerts, _ := settingsObj[trafficAlertsSettingsKey].(map[string]interface{})
+	if alerts == nil {
+		alerts = map[string]interface{}{}
+	}
+	key := strconv.Itoa(userServiceID)
+	if prev, ok := alerts[key].(map[string]interface{}); ok {
+		prevPeriod, _ := prev["period"].(string)
+		prevThreshold, _ := prev["threshold"].(float64)
+		if prevPeriod == period && int(prevThreshold) >= threshold {
+			return false, nil
+		}
+	}
+	alerts[key] = map[string]interface{}{
+		"period":    period,
+		"threshold": threshold,
+		"at":        time.Now().UTC().Format(time.RFC3339),
+	}
+	if err := s.saveSettingsKey(userID, trafficAlertsSettingsKey, alerts); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/ryabkov82/vpnbot/internal/config"
+)
+
+func TestClaimTrafficAlert_DedupPerPeriod(t *testing.T) {
+	fake, client := newFakeSHMUsers(t, `{"user_id":7,"login":"@7","settings":{"telegram":{"chat_id":77}}}`)
+	s := NewService(client, config.BrandConfig{})
+
+	steps := []struct {
+		period    string
+		threshold int
+		want      bool
+	}{
+		{"2026-10-01", 80, true},
+		{"2026-10-01", 80, false},
+		{"2026-10-01", 100, true},
+		{"2026-10-01", 80, false},
+		{"2026-11-01", 80, true},
+	}
+	for i, st := range steps {
+		got, err := s.ClaimTrafficAlert(7, 501, st.period, st.threshold)
+		if err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		if got != st.want {
+			t.Fatalf("step %d: claim=%v want %v", i, got, st.want)
+		}
+	}
+	if fake.postCount() != 3 {
+		t.Fatalf("posts=%d", fake.postCount())
+	}
+	settings := fake.row(7)["settings"].(map[string]interface{})
+	if _, ok := settings["telegram"]; !ok {
+		t.Fatal("existing settings must be preserved")
+	}
+	rec := settings["traffic_alerts"].(map[string]interface{})["501"].(map[string]interface{})
+	if rec["period"] != "2026-11-01" || rec["threshold"].(float64) != 80 {
+		t.Fatalf("record %v", rec)
+	}
+}
@@ -0,0 +1,404 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/attribution"
+	"github.com/ryabkov82/vpnbot/internal/models"
+)
+
+// Ошибки admin merge дублей Telegram/web (support tooling).
+var (
+	ErrMergeSameUser            = errors.New("merge: users must differ")
+	ErrMergeInvalidSurvivor     = errors.New("merge: survivor must be one of the merged users")
+	ErrMergeIdentityUnsupported = errors.New("merge: need one telegram and one web user of the active brand")
+	ErrMergeSurvivorOccupied    = errors.New("merge: survivor already has another login2 or identity block")
+	ErrMergeNeedsForce          = errors.New("merge: absorbed user still has balance/services/pays; use force")
+	ErrMergeLoginNotReleased    = errors.New("merge: absorbed login was not released by shm")
+)
+
+// Виды идентичности SHM-пользователя в рамках активного бренда.
+const (
+	MergeIdentityTelegram = "telegram"
+	MergeIdentityWeb      = "web"
+)
+
+// UserMergeRequest — запрос на слияние двух SHM-пользователей.
+// SurvivorID = 0 → выжившего выбирает SuggestMergeSurvivor. DryRun → только план без записи.
+type UserMergeRequest struct {
+	UserAID    int
+	UserBID    int
+	SurvivorID int
+	DryRun     bool
+	Force      bool
+	Operator   string
+}
+
+// UserMergeService — краткая строка услуги для предпросмотра.
+type UserMergeService struct {
+	UserServiceID int    `json:"user_service_id"`
+	Name          string `json:"name"`
+	Status        string `json:"status"`
+	Expire        string `json:"expire"`
+}
+
+// UserMergeSide — предпросмотр одного пользователя.
+type UserMergeSide struct {
+	UserID         int                 `json:"user_id"`
+	Login          string              `json:"login"`
+	Login2         string              `json:"login2,omitempty"`
+	BrandID        string              `json:"brand_id"`
+	Identity       string              `json:"identity"`
+	WebEmail       string              `json:"web_email,omitempty"`
+	TelegramChatID int64               `json:"telegram_chat_id,omitempty"`
+	Balance        float64             `json:"balance"`
+	Services       []UserMergeService  `json:"services"`
+	PaysCount      int                 `json:"pays_count"`
+	PaysTotal      float64             `json:"pays_total"`
+	Attribution    *attribution.Record `json:"attribution,omitempty"`
+
+	identityLogin string
+	settings      map[string]interface{}
+}
+
+// UserMergePlan — что будет сделано: identity absorbed → survivor, absorbed login освобождается.
+type UserMergePlan struct {
+	Survivor            UserMergeSide `json:"survivor"`
+	Absorbed            UserMergeSide `json:"absorbed"`
+	MovedIdentity       string        `json:"moved_identity"`
+	SurvivorLogin2      string        `json:"survivor_login2"`
+	AbsorbedLoginAfter  string        `json:"absorbed_login_after"`
+	SurvivorAttribution string        `json:"survivor_attribution"`
+	Warnings            []string      `json:"warnings"`
+}
+
+// UserMergeResult — план и факт применения.
+type UserMergeResult struct {
+	Plan    UserMergePlan `json:"plan"`
+	DryRun  bool          `json:"dry_run"`
+	Applied bool          `json:"applied"`
+}
+
+// mergedAbsorbedLogin — служебный login освобождённой записи (уникален по паре id).
+func mergedAbsorbedLogin(absorbedID, survivorID int) string {
+	return fmt.Sprintf("merged_%d_to_%d", absorbedID, survivorID)
+}
+
+// SuggestMergeSurvivor — выживает запись с большим числом услуг, затем с большим балансом,
+// при равенстве — Telegram-пользователь (бот ищет по каноническому login).
+func SuggestMergeSurvivor(a, b UserMergeSide) int {
+	if len(a.Services) != len(b.Services) {
+		if len(a.Services) > len(b.Services) {
+			return a.UserID
+		}
+		return b.UserID
+	}
+	if a.Balance != b.Balance {
+		if a.Balance > b.Balance {
+			return a.UserID
+		}
+		return b.UserID
+	}
+	if b.Identity == MergeIdentityTelegram && a.Identity != MergeIdentityTelegram {
+		return b.UserID
+	}
+	return a.UserID
+}
+
+func settingsBlock(settings map[string]interface{}, key string) map[string]interface{} {
+	if m, ok := settings[key].(map[string]interface{}); ok {
+		return m
+	}
+	return nil
+}
+
+// loadMergeSide читает пользователя, его settings (raw), услуги и платежи и определяет вид идентичности.
+// Запись, уже поглощённая этим survivor (settings.merged_into), распознаётся по сохранённому login — для повтора после сбоя.
+func (s *Service) loadMergeSide(userID, survivorHint int) (*UserMergeSide, error) {
+	u, err := s.apiClient.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, ErrUserNotFound
+	}
+	_, raw, err := s.apiClient.FetchAdminUserRowRaw(userID)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := mergeSettingsJSONToMap(raw)
+	if err != nil {
+		return nil, err
+	}
+	side := &UserMergeSide{
+		UserID:         u.ID,
+		Login:          strings.TrimSpace(u.Login),
+		Login2:         strings.TrimSpace(u.Login2),
+		BrandID:        strings.TrimSpace(u.Settings.BrandID),
+		WebEmail:       strings.TrimSpace(u.Settings.Web.Email),
+		TelegramChatID: u.Settings.Telegram.ChatID,
+		Balance:        u.Balance,
+		Attribution:    u.Settings.Attribution,
+		identityLogin:  strings.TrimSpace(u.Login),
+		settings:       settings,
+	}
+
+	brandID := s.activeBrandID()
+	if mi := settingsBlock(settings, "merged_into"); mi != nil && survivorHint > 0 {
+		if id, _ := mi["user_id"].(float64); int(id) == survivorHint {
+			if l, _ := mi["login"].(string); strings.TrimSpace(l) != "" {
+				side.identityLogin = strings.TrimSpace(l)
+			}
+			if idn, _ := mi["identity"].(string); idn == MergeIdentityTelegram || idn == MergeIdentityWeb {
+				side.Identity = idn
+			}
+			if block, _ := mi["block"].(map[string]interface{}); block != nil {
+				if chat, _ := block["chat_id"].(float64); chat > 0 {
+					side.TelegramChatID = int64(chat)
+				}
+				if email, _ := block["email"].(string); email != "" {
+					side.WebEmail = strings.TrimSpace(email)
+				}
+			}
+		}
+	}
+	if side.Identity == "" {
+		identityUser := *u
+		identityUser.Login = side.identityLogin
+		switch {
+		case side.TelegramChatID > 0 && side.identityLogin == telegramSHMLogin(brandID, side.TelegramChatID) &&
+			userBelongsToBrand(&identityUser, brandID, side.identityLogin):
+			side.Identity = MergeIdentityTelegram
+		case side.WebEmail != "" && side.identityLogin == canonicalWebLoginOrEmpty(u, s.webLoginPrefix()) &&
+			webUserBelongsToBrand(&identityUser, brandID, side.identityLogin):
+			side.Identity = MergeIdentityWeb
+		default:
+			logIdentityMismatch("merge_identity", u.Login, side.BrandID, 0, side.TelegramChatID)
+			return nil, ErrMergeIdentityUnsupported
+		}
+	}
+
+	services, err := s.apiClient.GetUserServices(userID)
+	if err != nil {
+		return nil, err
+	}
+	side.Services = make([]UserMergeService, 0, len(services))
+	for i := range services {
+		side.Services = append(side.Services, UserMergeService{
+			UserServiceID: services[i].ServiceID,
+			Name:          services[i].Name,
+			Status:        services[i].Status,
+			Expire:        services[i].Expire,
+		})
+	}
+	pays, err := s.apiClient.GetUserPays(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range models.VisibleUserPays(pays) {
+		side.PaysCount++
+		side.PaysTotal += p.Money
+	}
+	return side, nil
+}
+
+// PlanUserMerge строит план слияния без записи в SHM.
+func (s *Service) PlanUserMerge(req UserMergeRequest) (*UserMergePlan, error) {
+	if req.UserAID <= 0 || req.UserBID <= 0 {
+		return nil, errors.New("invalid user id")
+	}
+	if req.UserAID == req.UserBID {
+		return nil, ErrMergeSameUser
+	}
+	if req.SurvivorID != 0 && req.SurvivorID != req.UserAID && req.SurvivorID != req.UserBID {
+		return nil, ErrMergeInvalidSurvivor
+	}
+	if s.activeBrandID() == "" {
+		return nil, ErrActiveBrandIDRequired
+	}
+
+	a, err := s.loadMergeSide(req.UserAID, req.SurvivorID)
+	if err != nil {
+		return nil, err
+	}
+	b, err := s.loadMergeSide(req.UserBID, req.SurvivorID)
+	if err != nil {
+		return nil, err
+	}
+	if a.Identity == b.Identity {
+		return nil, ErrMergeIdentityUnsupported
+	}
+
+	survivorID := req.SurvivorID
+	if survivorID == 0 {
+		survivorID = SuggestMergeSurvivor(*a, *b)
+	}
+	surv, abs := a, b
+	if survivorID == b.UserID {
+		surv, abs = b, a
+	}
+
+	plan := &UserMergePlan{
+		Survivor:           *surv,
+		Absorbed:           *abs,
+		MovedIdentity:      abs.Identity,
+		SurvivorLogin2:     abs.identityLogin,
+		AbsorbedLoginAfter: mergedAbsorbedLogin(abs.UserID, surv.UserID),
+		Warnings:           []string{},
+	}
+
+	if surv.Login2 != "" && surv.Login2 != abs.identityLogin {
+		return nil, ErrMergeSurvivorOccupied
+	}
+	switch abs.Identity {
+	case MergeIdentityWeb:
+		if surv.WebEmail != "" && !strings.EqualFold(surv.WebEmail, abs.WebEmail) {
+			return nil, ErrMergeSurvivorOccupied
+		}
+	case MergeIdentityTelegram:
+		if surv.TelegramChatID > 0 && surv.TelegramChatID != abs.TelegramChatID {
+			return nil, ErrMergeSurvivorOccupied
+		}
+	}
+
+	switch {
+	case surv.Attribution != nil:
+		plan.SurvivorAttribution = "kept"
+	case abs.Attribution != nil:
+		plan.SurvivorAttribution = "absent; absorbed attribution stays in merge journal only"
+	default:
+		plan.SurvivorAttribution = "absent"
+	}
+	if abs.Balance != 0 {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("absorbed balance %s is not moved", models.FormatRubAmount(abs.Balance)))
+	}
+	if len(abs.Services) > 0 {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("absorbed has %d service(s) that are not moved", len(abs.Services)))
+	}
+	if abs.PaysCount > 0 {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("absorbed has %d payment(s) that stay on the absorbed user", abs.PaysCount))
+	}
+	return plan, nil
+}
+
+// MergeUsers — admin merge: переносит identity (login2 + settings.web/settings.telegram) поглощаемой записи
+// на выжившую и освобождает login поглощаемой. Баланс, услуги и платежи не переносятся (только предупреждения;
+// без Force применение блокируется). Attribution выжившей записи не меняется. Журнал пишется в
+// settings.merge_journal выжившей и settings.merged_into поглощённой записи, плюс структурный лог.
+func (s *Service) MergeUsers(req UserMergeRequest) (*UserMergeResult, error) {
+	plan, err := s.PlanUserMerge(req)
+	if err != nil {
+		return nil, err
+	}
+	res := &UserMergeResult{Plan: *plan, DryRun: req.DryRun}
+	if req.DryRun {
+		return res, nil
+	}
+	if len(plan.Warnings) > 0 && !req.Force {
+		return res, ErrMergeNeedsForce
+	}
+
+	surv, abs := plan.Survivor, plan.Absorbed
+	at := time.Now().UTC().Format(time.RFC3339)
+	operator := strings.TrimSpace(req.Operator)
+	if operator == "" {
+		operator = "admin"
+	}
+	movedBlock := settingsBlock(abs.settings, abs.Identity)
+
+	// 1) Освобождаем identity поглощаемой записи (пропускается при повторе после частичного сбоя).
+	if abs.Login != plan.AbsorbedLoginAfter {
+		// Settings пишутся целиком: перечитываем их под общим замком settings, как saveSettingsKey.
+		mu := s.settingsLocks.forUser(abs.UserID)
+		mu.Lock()
+		absSettings, err := s.loadSettingsMap(abs.UserID)
+		if err != nil {
+			mu.Unlock()
+			return res, err
+		}
+		delete(absSettings, abs.Identity)
+		absSettings["merged_into"] = map[string]interface{}{
+			"user_id":  surv.UserID,
+			"login":    abs.identityLogin,
+			"identity": abs.Identity,
+			"block":    movedBlock,
+			"at":       at,
+			"operator": operator,
+		}
+		err = s.apiClient.PostAdminUserUpdateFields(abs.UserID, map[string]interface{}{
+			"login":    plan.AbsorbedLoginAfter,
+			"login2":   "",
+			"settings": absSettings,
+		})
+		mu.Unlock()
+		if err != nil {
+			return res, err
+		}
+		released, err := s.apiClient.GetUserByLogin(abs.identityLogin)
+		if err != nil {
+			return res, err
+		}
+		if released != nil && released.ID == abs.UserID {
+			return res, ErrMergeLoginNotReleased
+		}
+	} else if mi := settingsBlock(abs.settings, "merged_into"); mi != nil {
+		movedBlock, _ = mi["block"].(map[string]interface{})
+	}
+
+	// 2) Переносим identity на выжившую запись и дописываем журнал. Attribution не трогаем.
+	mu := s.settingsLocks.forUser(surv.UserID)
+	mu.Lock()
+	defer mu.Unlock()
+	survSettings, err := s.loadSettingsMap(surv.UserID)
+	if err != nil {
+		return res, err
+	}
+	if movedBlock != nil {
+		survSettings[abs.Identity] = movedBlock
+	}
+	journal, _ := survSettings["merge_journal"].([]interface{})
+	for _, e := range journal {
+		if m, _ := e.(map[string]interface{}); m != nil {
+			if id, _ := m["absorbed_user_id"].(float64); int(id) == abs.UserID {
+				// Повтор уже завершённого merge: journal не дублируем.
+				res.Applied = true
+				return res, nil
+			}
+		}
+	}
+	entry := map[string]interface{}{
+		"at":                at,
+		"operator":          operator,
+		"absorbed_user_id":  abs.UserID,
+		"absorbed_login":    abs.identityLogin,
+		"moved_identity":    abs.Identity,
+		"absorbed_balance":  abs.Balance,
+		"absorbed_services": len(abs.Services),
+		"absorbed_pays":     abs.PaysCount,
+		"forced":            len(plan.Warnings) > 0,
+	}
+	if a, ok := abs.settings["attribution"]; ok && a != nil {
+		entry["absorbed_attribution"] = a
+	}
+	survSettings["merge_journal"] = append(journal, entry)
+	survSettings["brand_id"] = s.activeBrandID()
+
+	if _, err := s.apiClient.PostAdminUserUpdateSettings(surv.UserID, plan.SurvivorLogin2, survSettings); err != nil {
+		return res, err
+	}
+	res.Applied = true
+
+	slog.Info("shm user merge",
+		"brand_id", s.activeBrandID(),
+		"survivor_user_id", surv.UserID,
+		"absorbed_user_id", abs.UserID,
+		"moved_identity", abs.Identity,
+		"forced", len(plan.Warnings) > 0,
+		"operator", operator,
+	)
+	return res, nil
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"errors"
+	"testing"
+)
+
+func newMergeFixture(t *testing.T) (*fakeSHMUsers, *Service, string) {
+	t.Helper()
+	wl := webLoginFor(t, "dup@merge.test")
+	fake, acl := newFakeSHMUsers(t,
+		`{"user_id":10,"login":"@555","balance":0,"settings":{"brand_id":"vff","telegram":{"chat_id":555,"username":"dup"},"attribution":{"version":1,"first_touch":{"registration_channel":"telegram","registration_domain":"t.me","utm_source":"tg_ads"}}}}`,
+		`{"user_id":20,"login":"`+wl+`","balance":150,"settings":{"brand_id":"vff","web":{"email":"dup@merge.test","source":"google"},"attribution":{"version":1,"first_touch":{"registration_channel":"web","registration_domain":"vpn.test","utm_source":"seo"}}}}`,
+	)
+	fake.setRows(t, "service", 20, `[{"user_service_id":7,"user_id":20,"name":"VPN","status":"ACTIVE","expire":"2026-12-01"}]`)
+	fake.setRows(t, "pay", 20, `[{"id":1,"user_id":20,"money":150,"pay_system_id":"yookassa"}]`)
+	return fake, NewService(acl, testServiceBrand()), wl
+}
+
+func TestMergeUsers_DryRunSuggestsWebSurvivor(t *testing.T) {
+	fake, svc, wl := newMergeFixture(t)
+	res, err := svc.MergeUsers(UserMergeRequest{UserAID: 10, UserBID: 20, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := res.Plan
+	if p.Survivor.UserID != 20 || p.Absorbed.UserID != 10 || p.MovedIdentity != MergeIdentityTelegram {
+		t.Fatalf("plan %#v", p)
+	}
+	if p.SurvivorLogin2 != "@555" || p.AbsorbedLoginAfter != "merged_10_to_20" || p.Survivor.Login != wl {
+		t.Fatalf("plan logins %#v", p)
+	}
+	if len(p.Survivor.Services) != 1 || p.Survivor.PaysCount != 1 || len(p.Warnings) != 0 {
+		t.Fatalf("plan preview %#v", p)
+	}
+	if res.Applied || fake.postCount() != 0 {
+		t.Fatal("dry run must not write")
+	}
+}
+
+func TestMergeUsers_ApplyMovesTelegramIdentity(t *testing.T) {
+	fake, svc, _ := newMergeFixture(t)
+	res, err := svc.MergeUsers(UserMergeRequest{UserAID: 10, UserBID: 20, Operator: "support:anna"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Applied {
+		t.Fatal("expected applied")
+	}
+
+	abs := fake.row(10)
+	if abs["login"] != "merged_10_to_20" || abs["login2"] != "" {
+		t.Fatalf("absorbed row %#v", abs)
+	}
+	absSt := abs["settings"].(map[string]interface{})
+	if absSt["telegram"] != nil {
+		t.Fatalf("telegram block must move off absorbed: %#v", absSt)
+	}
+	mi := absSt["merged_into"].(map[string]interface{})
+	if mi["user_id"].(float64) != 20 || mi["login"] != "@555" {
+		t.Fatalf("merged_into %#v", mi)
+	}
+
+	surv := fake.row(20)
+	if surv["login2"] != "@555" {
+		t.Fatalf("survivor login2 %#v", surv["login2"])
+	}
+	st := surv["settings"].(map[string]interface{})
+	if tg := st["telegram"].(map[string]interface{}); tg["chat_id"].(float64) != 555 {
+		t.Fatalf("telegram block %#v", tg)
+	}
+	if a := st["attribution"].(map[string]interface{}); a["first_touch"].(map[string]interface{})["utm_source"] != "seo" {
+		t.Fatalf("survivor attribution must be kept: %#v", a)
+	}
+	j := st["merge_journal"].([]interface{})
+	if len(j) != 1 || j[0].(map[string]interface{})["operator"] != "support:anna" {
+		t.Fatalf("journal %#v", j)
+	}
+	if ja := j[0].(map[string]interface{})["absorbed_attribution"].(map[string]interface{}); ja["first_touch"].(map[string]interface{})["utm_source"] != "tg_ads" {
+		t.Fatalf("journal attribution %#v", ja)
+	}
+
+	// Бот находит выжившую запись по chat id.
+	u, err := svc.GetUser(555)
+	if err != nil || u == nil || u.ID != 20 {
+		t.Fatalf("GetUser after merge: %#v err=%v", u, err)
+	}
+
+	// Повтор после успешного merge: план строится из merged_into, записи нет.
+	before := fake.postCount()
+	if _, err := svc.MergeUsers(UserMergeRequest{UserAID: 10, UserBID: 20, SurvivorID: 20}); err != nil {
+		t.Fatalf("repeat merge: %v", err)
+	}
+	if fake.postCount() != before {
+		t.Fatal("repeat merge must not write")
+	}
+}
+
+func TestMergeUsers_AbsorbedWithAssetsNeedsForce(t *testing.T) {
+	fake, svc, _ := newMergeFixture(t)
+	res, err := svc.MergeUsers(UserMergeRequest{UserAID: 10, UserBID: 20, SurvivorID: 10})
+	if !errors.Is(err, ErrMergeNeedsForce) {
+		t.Fatalf("want ErrMergeNeedsForce, got %v", err)
+	}
+	if res == nil || len(res.Plan.Warnings) != 3 || fake.postCount() != 0 {
+		t.Fatalf("res %#v posts=%d", res, fake.postCount())
+	}
+
+	if _, err := svc.MergeUsers(UserMergeRequest{UserAID: 10, UserBID: 20, SurvivorID: 10, Force: true}); err != nil {
+		t.Fatal(err)
+	}
+	surv := fake.row(10)
+	st := surv["settings"].(map[string]interface{})
+	if web := st["web"].(map[string]interface{}); web["email"] != "dup@merge.test" {
+		t.Fatalf("web block %#v", web)
+	}
+	if a := st["attribution"].(map[string]interface{}); a["first_touch"].(map[string]interface{})["utm_source"] != "tg_ads" {
+		t.Fatalf("survivor attribution %#v", a)
+	}
+	u, err := svc.FindUserByWebEmail("dup@merge.test")
+	if err != nil || u == nil || u.ID != 10 {
+		t.Fatalf("FindUserByWebEmail after merge: %#v err=%v", u, err)
+	}
+}
+
+func TestMergeUsers_Rejects(t *testing.T) {
+	_, svc, _ := newMergeFixture(t)
+	if _, err := svc.MergeUsers(UserMergeRequest{UserAID: 10, UserBID: 10}); !errors.Is(err, ErrMergeSameUser) {
+		t.Fatalf("same user: %v", err)
+	}
+
+	_, acl := newFakeSHMUsers(t,
+		`{"user_id":1,"login":"@1","settings":{"brand_id":"vff","telegram":{"chat_id":1}}}`,
+		`{"user_id":2,"login":"@2","settings":{"brand_id":"vff","telegram":{"chat_id":2}}}`,
+		`{"user_id":3,"login":"@3","settings":{"brand_id":"fc","telegram":{"chat_id":3}}}`,
+	)
+	svc2 := NewService(acl, testServiceBrand())
+	if _, err := svc2.MergeUsers(UserMergeRequest{UserAID: 1, UserBID: 2, DryRun: true}); !errors.Is(err, ErrMergeIdentityUnsupported) {
+		t.Fatalf("two telegram users: %v", err)
+	}
+	if _, err := svc2.MergeUsers(UserMergeRequest{UserAID: 1, UserBID: 3, DryRun: true}); !errors.Is(err, ErrMergeIdentityUnsupported) {
+		t.Fatalf("foreign brand: %v", err)
+	}
+}
+
+func TestSuggestMergeSurvivor(t *testing.T) {
+	tg := UserMergeSide{UserID: 1, Identity: MergeIdentityTelegram}
+	web := UserMergeSide{UserID: 2, Identity: MergeIdentityWeb}
+	if got := SuggestMergeSurvivor(web, tg); got != 1 {
+		t.Fatalf("tie → telegram, got %d", got)
+	}
+	web.Balance = 10
+	if got := SuggestMergeSurvivor(tg, web); got != 2 {
+		t.Fatalf("balance → web, got %d", got)
+	}
+	tg.Services = []UserMergeService{{UserServiceID: 1}}
+	if got := SuggestMergeSurvivor(tg, web); got != 1 {
+		t.Fatalf("services win, got %d", got)
+	}
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/models"
+	"github.com/ryabkov82/vpnbot/internal/vouchers"
+)
+
+var (
+	// ErrVouchersDisabled — секрет кодов не настроен.
+	ErrVouchersDisabled = errors.New("vouchers disabled")
+	// ErrVoucherExpired — срок действия кода истёк.
+	ErrVoucherExpired = errors.New("voucher expired")
+	// ErrVoucherUsed — код уже активирован другим пользователем.
+	ErrVoucherUsed = errors.New("voucher already used")
+)
+
+// Статусы активации кода (settings.vouchers[serial].status).
+const (
+	VoucherCredited    = "credited"
+	VoucherOrdering    = "ordering"
+	VoucherOrdered     = "ordered"
+	VoucherOrderFailed = "order_failed"
+	// VoucherExistingUnpaid — номинал на балансе, но SHM вернул ранее заказанную неоплаченную услугу
+	// (check_exists_unpaid) или новую в NOT PAID: услуга по коду не оформлена, повтор не поможет — разбирает поддержка.
+	VoucherExistingUnpaid = "existing_unpaid"
+)
+
+const vouchersSettingsKey = "vouchers"
+
+// voucherNow — часы проверки срока; подменяются в тестах.
+var voucherNow = time.Now
+
+// VoucherRedeemRequest — активация кода пользователем; Secret и PaySystem — из config.Vouchers.
+type VoucherRedeemRequest struct {
+	UserID    int
+	Code      string
+	Secret    string
+	PaySystem string
+}
+
+// VoucherRedemption — итог активации.
+type VoucherRedemption struct {
+	Status        string
+	Kind          vouchers.Kind
+	Amount        float64
+	ServiceID     int
+	ServiceName   string
+	UserServiceID int
+	// Duplicate — код уже был активирован этим же пользователем (повторный запрос).
+	Duplicate bool
+}
+
+// RedeemVoucher активирует код: зачисляет номинал (или стоимость услуги) платежом с uniq_key кода
+// и для кода на услугу заказывает её. Однократность не полагается на отказ SHM принять повтор uniq_key:
+// код принадлежит самому раннему платежу с этим ключом, опоздавший параллельный платёж сразу списывается обратно.
+// Повтор тем же пользователем возвращает прежний итог с Duplicate, а несостоявшийся заказ услуги пробует снова.
+func (s *Service) RedeemVoucher(req VoucherRedeemRequest) (*VoucherRedemption, error) {
+	if req.UserID <= 0 {
+		return nil, errors.New("invalid user id")
+	}
+	codec, err := vouchers.NewCodec(req.Secret)
+	if err != nil {
+		return nil, ErrVouchersDisabled
+	}
+	brandID := s.activeBrandID()
+	v, err := codec.Decode(brandID, req.Code)
+	if err != nil {
+		return nil, err
+	}
+	if v.Expired(voucherNow()) {
+		return nil, ErrVoucherExpired
+	}
+
+	s.voucherMu.Lock()
+	defer s.voucherMu.Unlock()
+
+	out := &VoucherRedemption{Status: VoucherCredited, Kind: v.Kind, Amount: float64(v.Value)}
+	if v.Kind == vouchers.KindService {
+		if err := s.ensureServiceAllowedForOrder(v.Value); err != nil {
+			return nil, err
+		}
+		svc, err := s.apiClient.GetServiceByID(v.Value)
+		if err != nil {
+			return nil, fmt.Errorf("voucher service lookup: %w", err)
+		}
+		if svc == nil {
+			return nil, &ServiceCategoryDeniedError{ServiceID: v.Value}
+		}
+		if svc.Cost <= 0 {
+			// Зачислять нечего, а без платежа с uniq_key код нельзя погасить однократно.
+			return nil, fmt.Errorf("voucher service %d has no cost", v.Value)
+		}
+		out.ServiceID, out.ServiceName = svc.ServiceID, strings.TrimSpace(svc.Name)
+		out.Amount = math.Round(svc.Cost*100) / 100
+	}
+
+	key := v.UniqKey(brandID)
+	dup, err := s.creditVoucher(req.UserID, key, out.Amount, req.PaySystem, map[string]interface{}{
+		"voucher":    v.Serial(),
+		"kind":       v.Kind.String(),
+		"value":      v.Value,
+		"expires_on": v.ExpiresOn.Format(time.DateOnly),
+	})
+	if err != nil {
+		return nil, err
+	}
+	out.Duplicate = dup
+
+	settingsObj, err := s.loadSettingsMap(req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	records, _ := settingsObj[vouchersSettingsKey].(map[string]interface{})
+	if records == nil {
+		records = map[string]interface{}{}
+	}
+	serial := v.Serial()
+	if prev, ok := records[serial].(map[string]interface{}); ok {
+		status, _ := prev["status"].(string)
+		usID, _ := prev["user_service_id"].(float64)
+		if status == VoucherOrdered || status == VoucherOrdering || status == VoucherExistingUnpaid || v.Kind == vouchers.KindBalance {
+			out.Status, out.UserServiceID, out.Duplicate = status, int(usID), true
+			return out, nil
+		}
+	}
+	save := func(status string, usID int) error {
+		rec := map[string]interface{}{"status": status, "amount": out.Amount, "kind": v.Kind.String(), "at": voucherNow().UTC().Format(time.RFC3339)}
+		if out.ServiceID > 0 {
+			rec["service_id"] = out.ServiceID
+		}
+		if usID > 0 {
+			rec["user_service_id"] = usID
+		}
+		records[serial] = rec
+		return s.saveSettingsKey(req.UserID, vouchersSettingsKey, records)
+	}
+
+	if v.Kind == vouchers.KindBalance {
+		if err := save(VoucherCredited, 0); err != nil {
+			slog.Error("voucher: save record", "user_id", req.UserID, "voucher", serial, "err", err)
+		}
+		slog.Info("voucher redeemed", "brand_id", brandID, "user_id", req.UserID, "voucher", serial, "amount", out.Amount)
+		return out, nil
+	}
+
+	// Отметка «ordering» до заказа: сбой между заказом и сохранением не приведёт ко второй услуге.
+	if err := save(VoucherOrdering, 0); err != nil {
+		return nil, err
+	}
+	us, orderErr := s.ServiceOrderByUserID(req.UserID, out.ServiceID)
+	out.Status = VoucherOrdered
+	if orderErr != nil || us == nil {
+		// Деньги уже на балансе; повторная активация тем же кодом снова попробует заказать услугу.
+		slog.Error("voucher: ServiceOrderByUserID", "user_id", req.UserID, "voucher", serial, "service_id", out.ServiceID, "err", orderErr)
+		out.Status = VoucherOrderFailed
+	} else if !orderedAsRequested(us, out.ServiceID) {
+		slog.Error("voucher: order returned another or unpaid service", "user_id", req.UserID, "voucher", serial, "service_id", out.ServiceID,
+			"returned_user_service_id", us.ServiceID, "returned_service_id", us.BaseServiceID, "status", us.Status)
+		out.Status, out.UserServiceID = VoucherExistingUnpaid, us.ServiceID
+	} else {
+		out.UserServiceID = us.ServiceID
+	}
+	if err := save(out.Status, out.UserServiceID); err != nil {
+		slog.Error("voucher: save record", "user_id", req.UserID, "voucher", serial, "status", out.Status, "err", err)
+	}
+	slog.Info("voucher redeemed", "brand_id", brandID, "user_id", req.UserID, "voucher", serial, "service_id", out.ServiceID, "status", out.Status)
+	return out, nil
+}
+
+// creditVoucher зачисляет платёж с uniq_key кода. true — платёж этого пользователя уже был;
+// ErrVoucherUsed — ключ занят платежом другого пользователя (в том числе при гонке с параллельной активацией).
+// Владелец кода — самый ранний (меньший id) платёж с ключом: voucherMu сериализует активации только в процессе,
+// поэтому после зачисления платежи с ключом перечитываются, и проигравший гонку списывает свой платёж обратно.
+func (s *Service) creditVoucher(userID int, key string, amount float64, paySystem string, comment map[string]interface{}) (bool, error) {
+	if strings.TrimSpace(paySystem) == "" {
+		paySystem = "voucher"
+	}
+	owner := func() (bool, error) {
+		pays, err := s.apiClient.FindUserPaysByUniqKey(key)
+		if err != nil || len(pays) == 0 {
+			return false, err
+		}
+		if pays[0].UserID == userID {
+			return true, nil
+		}
+		for _, p := range pays[1:] {
+			if p.UserID == userID && p.Money > 0 {
+				if err := s.revokeVoucherPay(p, key, paySystem); err != nil {
+					return false, err
+				}
+			}
+		}
+		return false, ErrVoucherUsed
+	}
+	if found, err := owner(); err != nil || found {
+		return found, err
+	}
+	addErr := s.apiClient.AddUserPayment(userID, amount, paySystem, key, comment)
+	found, err := owner()
+	if err != nil {
+		return false, err
+	}
+	if addErr == nil {
+		return false, nil
+	}
+	if found {
+		return true, nil
+	}
+	return false, fmt.Errorf("voucher credit: %w", addErr)
+}
+
+// revokeVoucherPay списывает платёж, проигравший гонку за код; ключ …:revoke:<pay id> не даёт списать дважды.
+func (s *Service) revokeVoucherPay(p models.UserPay, key, paySystem string) error {
+	revokeKey := fmt.Sprintf("%s:revoke:%d", key, p.ID)
+	if done, err := s.apiClient.FindUserPayByUniqKey(revokeKey); err != nil || done != nil {
+		return err
+	}
+	slog.Warn("voucher: revoke concurrent credit", "user_id", p.UserID, "pay_id", p.ID, "uniq_key", key, "amount", p.Money)
+	if err := s.apiClient.DebitUserBalance(p.UserID, p.Money, paySystem, revokeKey, map[string]interface{}{"voucher_revoke": key, "pay_id": p.ID}); err != nil {
+		return fmt.Errorf("voucher revoke: %w", err)
+	}
+	return nil
+}
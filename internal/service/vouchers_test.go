@@ -0,0 +1,185 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/vouchers"
+)
+
+const testVoucherSecret = "0123456789abcdef0123456789abcdef"
+
+func testVoucherCode(t *testing.T, brandID string, kind vouchers.Kind, value int, expires string) string {
+	t.Helper()
+	c, err := vouchers.NewCodec(testVoucherSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp, _ := vouchers.ParseExpiry(expires)
+	code, err := c.Encode(brandID, vouchers.Voucher{Kind: kind, Value: value, ExpiresOn: exp, Batch: 77})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func stubVoucherNow(t *testing.T, now time.Time) {
+	t.Helper()
+	orig := voucherNow
+	voucherNow = func() time.Time { return now }
+	t.Cleanup(func() { voucherNow = orig })
+}
+
+func TestRedeemVoucher_BalanceSingleUse(t *testing.T) {
+	stubVoucherNow(t, time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC))
+	fake, client := newFakeSHMUsers(t, `{"user_id":42,"login":"@42","settings":{}}`, `{"user_id":43,"login":"@43","settings":{}}`)
+	s := NewService(client, orderBrandCfg("vff", "vpn-mz-test"))
+	code := testVoucherCode(t, "vff", vouchers.KindBalance, 300, "2026-12-31")
+
+	got, err := s.RedeemVoucher(VoucherRedeemRequest{UserID: 42, Code: code, Secret: testVoucherSecret})
+	if err != nil || got.Status != VoucherCredited || got.Amount != 300 || got.Duplicate {
+		t.Fatalf("%+v %v", got, err)
+	}
+	pays := fake.payRows(42)
+	if len(pays) != 1 || pays[0].(map[string]interface{})["uniq_key"] != "voucher:vff:00004d-0000" || pays[0].(map[string]interface{})["pay_system_id"] != "voucher" {
+		t.Fatalf("%+v", pays)
+	}
+	again, err := s.RedeemVoucher(VoucherRedeemRequest{UserID: 42, Code: code, Secret: testVoucherSecret})
+	if err != nil || !again.Duplicate || len(fake.payRows(42)) != 1 {
+		t.Fatalf("retry: %+v %v", again, err)
+	}
+	if _, err := s.RedeemVoucher(VoucherRedeemRequest{UserID: 43, Code: code, Secret: testVoucherSecret}); !errors.Is(err, ErrVoucherUsed) {
+		t.Fatalf("other user: %v", err)
+	}
+}
+
+func TestRedeemVoucher_ConcurrentAcrossProcesses(t *testing.T) {
+	stubVoucherNow(t, time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC))
+	for _, dupKeys := range []bool{false, true} {
+		fake, client := newFakeSHMUsers(t, `{"user_id":42,"login":"@42","settings":{}}`, `{"user_id":43,"login":"@43","settings":{}}`)
+		fake.dupPayKeys = dupKeys
+		code := testVoucherCode(t, "vff", vouchers.KindBalance, 300, "2026-12-31")
+		// Разные Service — как бот и кабинет в разных процессах: общий только SHM.
+		var wg sync.WaitGroup
+		errs := make([]error, 2)
+		for i, uid := range []int{42, 43} {
+			wg.Add(1)
+			go func(i, uid int) {
+				defer wg.Done()
+				_, errs[i] = NewService(client, orderBrandCfg("vff", "vpn-mz-test")).RedeemVoucher(VoucherRedeemRequest{UserID: uid, Code: code, Secret: testVoucherSecret})
+			}(i, uid)
+		}
+		wg.Wait()
+		ok := 0
+		for _, err := range errs {
+			if err == nil {
+				ok++
+			} else if !errors.Is(err, ErrVoucherUsed) {
+				t.Fatalf("dup=%v unexpected: %v", dupKeys, err)
+			}
+		}
+		bal42, _ := fake.row(42)["balance"].(float64)
+		bal43, _ := fake.row(43)["balance"].(float64)
+		if ok != 1 || bal42+bal43 != 300 {
+			t.Fatalf("dup=%v ok=%d balances=%v/%v", dupKeys, ok, bal42, bal43)
+		}
+	}
+}
+
+func TestRedeemVoucher_LateDuplicateCreditIsRevoked(t *testing.T) {
+	stubVoucherNow(t, time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC))
+	fake, client := newFakeSHMUsers(t, `{"user_id":42,"login":"@42","balance":0,"settings":{}}`, `{"user_id":43,"login":"@43","balance":0,"settings":{}}`)
+	fake.dupPayKeys = true
+	s := NewService(client, orderBrandCfg("vff", "vpn-mz-test"))
+	code := testVoucherCode(t, "vff", vouchers.KindBalance, 300, "2026-12-31")
+
+	if _, err := s.RedeemVoucher(VoucherRedeemRequest{UserID: 43, Code: code, Secret: testVoucherSecret}); err != nil {
+		t.Fatal(err)
+	}
+	// Параллельная активация в другом процессе успела зачислить платёж до проверки владельца.
+	if err := client.AddUserPayment(42, 300, "voucher", "voucher:vff:00004d-0000", nil); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := s.RedeemVoucher(VoucherRedeemRequest{UserID: 42, Code: code, Secret: testVoucherSecret}); !errors.Is(err, ErrVoucherUsed) {
+			t.Fatalf("attempt %d: %v", i, err)
+		}
+	}
+	if bal, _ := fake.row(42)["balance"].(float64); bal != 0 || len(fake.payRows(42)) != 2 {
+		t.Fatalf("loser balance=%v pays=%+v", bal, fake.payRows(42))
+	}
+	if bal, _ := fake.row(43)["balance"].(float64); bal != 300 {
+		t.Fatalf("owner balance=%v", bal)
+	}
+}
+
+func TestRedeemVoucher_ServiceOrdersOnce(t *testing.T) {
+	stubVoucherNow(t, time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC))
+	fake, client := newFakeSHMUsers(t, `{"user_id":42,"login":"@42","settings":{}}`)
+	fake.setCatalog(t, orderIntentCatalogJSON)
+	s := NewService(client, orderBrandCfg("vff", "vpn-mz-test"))
+	code := testVoucherCode(t, "vff", vouchers.KindService, 7, "2026-12-31")
+
+	fake.failOrders = true
+	got, err := s.RedeemVoucher(VoucherRedeemRequest{UserID: 42, Code: code, Secret: testVoucherSecret})
+	if err != nil || got.Status != VoucherOrderFailed || got.Amount != 450 {
+		t.Fatalf("%+v %v", got, err)
+	}
+	fake.failOrders = false
+	got, err = s.RedeemVoucher(VoucherRedeemRequest{UserID: 42, Code: code, Secret: testVoucherSecret})
+	if err != nil || got.Status != VoucherOrdered || got.UserServiceID != 901 || !got.Duplicate || len(fake.payRows(42)) != 1 {
+		t.Fatalf("retry after failed order: %+v %v", got, err)
+	}
+	got, err = s.RedeemVoucher(VoucherRedeemRequest{UserID: 42, Code: code, Secret: testVoucherSecret})
+	if err != nil || got.Status != VoucherOrdered || fake.orderCount() != 1 {
+		t.Fatalf("third: %+v %v orders=%d", got, err, fake.orderCount())
+	}
+}
+
+func TestRedeemVoucher_ExistingUnpaidServiceIsNotOrdered(t *testing.T) {
+	stubVoucherNow(t, time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC))
+	fake, client := newFakeSHMUsers(t, `{"user_id":42,"login":"@42","settings":{}}`)
+	fake.setCatalog(t, orderIntentCatalogJSON)
+	// check_exists_unpaid: SHM отдаёт ранее заказанную неоплаченную услугу другого тарифа.
+	fake.orderReturns = map[string]interface{}{"user_service_id": 777.0, "service_id": 3.0, "user_id": 42.0, "status": "NOT PAID"}
+	s := NewService(client, orderBrandCfg("vff", "vpn-mz-test"))
+	code := testVoucherCode(t, "vff", vouchers.KindService, 7, "2026-12-31")
+
+	got, err := s.RedeemVoucher(VoucherRedeemRequest{UserID: 42, Code: code, Secret: testVoucherSecret})
+	if err != nil || got.Status != VoucherExistingUnpaid || got.UserServiceID != 777 || got.Amount != 450 {
+		t.Fatalf("%+v %v", got, err)
+	}
+	again, err := s.RedeemVoucher(VoucherRedeemRequest{UserID: 42, Code: code, Secret: testVoucherSecret})
+	if err != nil || again.Status != VoucherExistingUnpaid || !again.Duplicate || fake.orderCount() != 1 || len(fake.payRows(42)) != 1 {
+		t.Fatalf("retry: %+v %v orders=%d", again, err, fake.orderCount())
+	}
+}
+
+func TestRedeemVoucher_Rejects(t *testing.T) {
+	stubVoucherNow(t, time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC))
+	fake, client := newFakeSHMUsers(t, `{"user_id":42,"login":"@42","settings":{}}`)
+	fake.setCatalog(t, `{"service_id":8,"allow_to_order":1,"cost":450,"category":"other"}`)
+	s := NewService(client, orderBrandCfg("vff", "vpn-mz-test"))
+
+	cases := []struct {
+		name   string
+		req    VoucherRedeemRequest
+		target error
+	}{
+		{"disabled", VoucherRedeemRequest{UserID: 42, Code: "x"}, ErrVouchersDisabled},
+		{"typo", VoucherRedeemRequest{UserID: 42, Code: "00000-00000-00000-00000-00001", Secret: testVoucherSecret}, vouchers.ErrMalformed},
+		{"other brand", VoucherRedeemRequest{UserID: 42, Code: testVoucherCode(t, "fc", vouchers.KindBalance, 300, "2026-12-31"), Secret: testVoucherSecret}, vouchers.ErrInvalid},
+		{"expired", VoucherRedeemRequest{UserID: 42, Code: testVoucherCode(t, "vff", vouchers.KindBalance, 300, "2026-10-17"), Secret: testVoucherSecret}, ErrVoucherExpired},
+		{"category", VoucherRedeemRequest{UserID: 42, Code: testVoucherCode(t, "vff", vouchers.KindService, 8, "2026-12-31"), Secret: testVoucherSecret}, ErrServiceCategoryDenied},
+	}
+	for _, tc := range cases {
+		if _, err := s.RedeemVoucher(tc.req); !errors.Is(err, tc.target) {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+	}
+	if len(fake.payRows(42)) != 0 {
+		t.Fatal("nothing must be credited")
+	}
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/ryabkov82/vpnbot/internal/infrastructure/api"
+	"github.com/ryabkov82/vpnbot/internal/models"
+	"github.com/ryabkov82/vpnbot/internal/webuser"
+)
+
+// webEmailIdentity — как web-email закреплён за пользователем: основной login (чистый web)
+// или login2 (Telegram-пользователь с привязанным кабинетом).
+type webEmailIdentity struct {
+	user      *models.User
+	email     string
+	webLogin  string
+	isPrimary bool
+}
+
+// loadWebEmailIdentity проверяет, что у userID сейчас привязан currentEmail в namespace WebUserLoginPrefix бренда.
+func (s *Service) loadWebEmailIdentity(userID int, currentEmail string) (*webEmailIdentity, error) {
+	if userID <= 0 {
+		return nil, errors.New("invalid user id")
+	}
+	brandID := s.activeBrandID()
+	if brandID == "" {
+		return nil, ErrActiveBrandIDRequired
+	}
+	normCur, err := webuser.NormalizeEmail(currentEmail)
+	if err != nil {
+		return nil, err
+	}
+	u, err := s.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, ErrUserNotFound
+	}
+	stored, err := webuser.NormalizeEmail(u.Settings.Web.Email)
+	if err != nil || stored != normCur {
+		return nil, ErrWebEmailNotLinked
+	}
+	webLogin, err := canonicalWebLoginFromEmail(normCur, s.webLoginPrefix())
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureWebUserMembership(u, brandID, webLogin); err != nil {
+		return nil, err
+	}
+	return &webEmailIdentity{
+		user:      u,
+		email:     normCur,
+		webLogin:  webLogin,
+		isPrimary: strings.TrimSpace(u.Login) == webLogin,
+	}, nil
+}
+
+// ChangeWebEmail меняет привязанный email: settings.web.email и web login (login у чистого web-пользователя,
+// login2 у Telegram-пользователя) обновляются одним запросом к SHM. Новый web login проверяется на занятость
+// по login и login2 в namespace WebUserLoginPrefix бренда.
+func (s *Service) ChangeWebEmail(userID int, currentEmail, newEmail string) (*models.User, error) {
+	id, err := s.loadWebEmailIdentity(userID, currentEmail)
+	if err != nil {
+		return nil, err
+	}
+	normNew, err := webuser.NormalizeEmail(newEmail)
+	if err != nil {
+		return nil, err
+	}
+	if normNew == id.email {
+		return nil, ErrWebEmailUnchanged
+	}
+	brandID := s.activeBrandID()
+	newLogin, err := canonicalWebLoginFromEmail(normNew, s.webLoginPrefix())
+	if err != nil {
+		return nil, err
+	}
+
+	byLogin, err := s.apiClient.GetUserByLogin(newLogin)
+	if err != nil {
+		return nil, err
+	}
+	if err := webLoginConflictError(byLogin, userID, brandID, newLogin); err != nil {
+		return nil, err
+	}
+	byLogin2, err := s.apiClient.GetUserByLogin2(newLogin)
+	if err != nil {
+		return nil, err
+	}
+	if err := webLoginConflictError(byLogin2, userID, brandID, newLogin); err != nil {
+		return nil, err
+	}
+
+	// Settings пишутся целиком: перечитываем их под общим замком settings, как saveSettingsKey.
+	mu := s.settingsLocks.forUser(userID)
+	mu.Lock()
+	defer mu.Unlock()
+	settingsObj, err := s.loadSettingsMap(userID)
+	if err != nil {
+		return nil, err
+	}
+	webBlock, _ := settingsObj["web"].(map[string]interface{})
+	if webBlock == nil {
+		webBlock = map[string]interface{}{}
+	}
+	webBlock["email"] = normNew
+	webBlock["previous_email"] = id.email
+	webBlock["email_changed_at"] = time.Now().UTC().Format(time.RFC3339)
+	settingsObj["web"] = webBlock
+	settingsObj["brand_id"] = brandID
+
+	if id.isPrimary {
+		if err := s.apiClient.PostAdminUserUpdateFields(userID, map[string]interface{}{
+			"login":    newLogin,
+			"settings": settingsObj,
+		}); err != nil {
+			return nil, err
+		}
+		updated, err := s.apiClient.GetUserByLogin(newLogin)
+		if err != nil {
+			return nil, err
+		}
+		if updated == nil || updated.ID != userID {
+			return nil, ErrWebEmailLoginNotUpdated
+		}
+		slog.Info("web email changed", "brand_id", brandID, "user_id", userID, "mode", "login")
+		return updated, nil
+	}
+
+	updated, err := s.apiClient.PostAdminUserUpdateSettings(userID, newLogin, settingsObj)
+	if err != nil {
+		if errors.Is(err, api.ErrLogin2NotPersistedSHM) {
+			return nil, ErrWebLogin2NotPersisted
+		}
+		return nil, err
+	}
+	if updated == nil {
+		return nil, errors.New("change web email: shm update returned empty user")
+	}
+	slog.Info("web email changed", "brand_id", brandID, "user_id", userID, "mode", "login2")
+	return updated, nil
+}
+
+// UnlinkWebEmail снимает web-email с Telegram-пользователя: login2 (только если он из web namespace бренда)
+// и settings.web удаляются. У чистого web-пользователя email — основной login, отвязка запрещена.
+func (s *Service) UnlinkWebEmail(userID int, currentEmail string) (*models.User, error) {
+	id, err := s.loadWebEmailIdentity(userID, currentEmail)
+	if err != nil {
+		return nil, err
+	}
+	if id.isPrimary || id.user.Settings.Telegram.ChatID <= 0 {
+		return nil, ErrWebEmailUnlinkPrimary
+	}
+	if strings.TrimSpace(id.user.Login2) != id.webLogin {
+		return nil, ErrWebEmailNotLinked
+	}
+	brandID := s.activeBrandID()
+	canonicalTG := telegramSHMLogin(brandID, id.user.Settings.Telegram.ChatID)
+	if strings.TrimSpace(id.user.Login) != canonicalTG {
+		logIdentityMismatch("unlink_telegram_login", id.user.Login, strings.TrimSpace(id.user.Settings.BrandID), id.user.Settings.Telegram.ChatID, id.user.Settings.Telegram.ChatID)
+		return nil, ErrUserIdentityMismatch
+	}
+
+	// Settings пишутся целиком: перечитываем их под общим замком settings, как saveSettingsKey.
+	mu := s.settingsLocks.forUser(userID)
+	mu.Lock()
+	defer mu.Unlock()
+	settingsObj, err := s.loadSettingsMap(userID)
+	if err != nil {
+		return nil, err
+	}
+	delete(settingsObj, "web")
+	settingsObj["web_unlinked"] = map[string]interface{}{
+		"email": id.email,
+		"at":    time.Now().UTC().Format(time.RFC3339),
+	}
+	settingsObj["brand_id"] = brandID
+
+	if err := s.apiClient.PostAdminUserUpdateFields(userID, map[string]interface{}{
+		"login2":   "",
+		"settings": settingsObj,
+	}); err != nil {
+		return nil, err
+	}
+	still, err := s.apiClient.GetUserByLogin2(id.webLogin)
+	if err != nil {
+		return nil, err
+	}
+	if still != nil && still.ID == userID {
+		return nil, ErrWebEmailLoginNotUpdated
+	}
+	updated, err := s.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("web email unlinked", "brand_id", brandID, "user_id", userID)
+	return updated, nil
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestChangeWebEmail_PrimaryWebUserUpdatesLogin(t *testing.T) {
+	oldLogin := webLoginFor(t, "old@change.test")
+	newLogin := webLoginFor(t, "new@change.test")
+	fake, acl := newFakeSHMUsers(t,
+		`{"user_id":30,"login":"`+oldLogin+`","settings":{"brand_id":"vff","web":{"email":"old@change.test","source":"google"},"attribution":{"version":1}}}`,
+	)
+	svc := NewService(acl, testServiceBrand())
+	u, err := svc.ChangeWebEmail(30, "Old@Change.test", "new@change.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Login != newLogin || u.Settings.Web.Email != "new@change.test" {
+		t.Fatalf("updated %#v", u)
+	}
+	st := fake.row(30)["settings"].(map[string]interface{})
+	web := st["web"].(map[string]interface{})
+	if web["previous_email"] != "old@change.test" || web["source"] != "google" || st["attribution"] == nil {
+		t.Fatalf("settings %#v", st)
+	}
+	if fake.postCount() != 1 {
+		t.Fatalf("want single atomic update, got %d", fake.postCount())
+	}
+	if got, err := svc.FindUserByWebEmail("old@change.test"); err != nil || got != nil {
+		t.Fatalf("old email still resolves: %#v err=%v", got, err)
+	}
+}
+
+func TestChangeWebEmail_TelegramUserUpdatesLogin2(t *testing.T) {
+	oldLogin := webLoginFor(t, "old@change.test")
+	newLogin := webLoginFor(t, "new@change.test")
+	fake, acl := newFakeSHMUsers(t,
+		`{"user_id":31,"login":"@31","login2":"`+oldLogin+`","settings":{"brand_id":"vff","telegram":{"chat_id":31},"web":{"email":"old@change.test"}}}`,
+	)
+	svc := NewService(acl, testServiceBrand())
+	u, err := svc.ChangeWebEmail(31, "old@change.test", "new@change.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Login != "@31" || u.Login2 != newLogin || fake.row(31)["login"] != "@31" {
+		t.Fatalf("updated %#v", u)
+	}
+}
+
+func TestChangeWebEmail_Rejects(t *testing.T) {
+	oldLogin := webLoginFor(t, "old@change.test")
+	takenLogin := webLoginFor(t, "taken@change.test")
+	fake, acl := newFakeSHMUsers(t,
+		`{"user_id":30,"login":"`+oldLogin+`","settings":{"brand_id":"vff","web":{"email":"old@change.test"}}}`,
+		`{"user_id":40,"login":"@40","login2":"`+takenLogin+`","settings":{"brand_id":"vff","telegram":{"chat_id":40},"web":{"email":"taken@change.test"}}}`,
+	)
+	svc := NewService(acl, testServiceBrand())
+	if _, err := svc.ChangeWebEmail(30, "old@change.test", "taken@change.test"); !errors.Is(err, ErrWebEmailUsedByOtherAccount) {
+		t.Fatalf("taken: %v", err)
+	}
+	if _, err := svc.ChangeWebEmail(30, "old@change.test", "OLD@change.test"); !errors.Is(err, ErrWebEmailUnchanged) {
+		t.Fatalf("unchanged: %v", err)
+	}
+	if _, err := svc.ChangeWebEmail(30, "stale@change.test", "fresh@change.test"); !errors.Is(err, ErrWebEmailNotLinked) {
+		t.Fatalf("stale current email: %v", err)
+	}
+	if fake.postCount() != 0 {
+		t.Fatal("rejected change must not write")
+	}
+}
+
+func TestUnlinkWebEmail(t *testing.T) {
+	wl := webLoginFor(t, "linked@change.test")
+	fake, acl := newFakeSHMUsers(t,
+		`{"user_id":31,"login":"@31","login2":"`+wl+`","settings":{"brand_id":"vff","telegram":{"chat_id":31},"web":{"email":"linked@change.test"}}}`,
+		`{"user_id":32,"login":"`+webLoginFor(t, "solo@change.test")+`","settings":{"brand_id":"vff","web":{"email":"solo@change.test"}}}`,
+	)
+	svc := NewService(acl, testServiceBrand())
+	if _, err := svc.UnlinkWebEmail(32, "solo@change.test"); !errors.Is(err, ErrWebEmailUnlinkPrimary) {
+		t.Fatalf("primary: %v", err)
+	}
+	u, err := svc.UnlinkWebEmail(31, "linked@change.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Login2 != "" || u.Settings.Web.Email != "" || u.Settings.Telegram.ChatID != 31 {
+		t.Fatalf("after unlink %#v", u)
+	}
+	if st := fake.row(31)["settings"].(map[string]interface{}); st["web_unlinked"] == nil {
+		t.Fatalf("settings %#v", st)
+	}
+	if got, err := svc.FindUserByWebEmail("linked@change.test"); err != nil || got != nil {
+		t.Fatalf("unlinked email still resolves: %#v err=%v", got, err)
+	}
+}
@@ -0,0 +1,80 @@
+package vouchers
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// BatchSpec — параметры партии кодов.
+type BatchSpec struct {
+	Kind      Kind
+	Value     int
+	ExpiresOn time.Time
+	Count     int
+	// Batch — номер партии; 0 — случайный. Номер входит в серийный номер, поэтому
+	// повторная партия с тем же номером выпустит те же коды.
+	Batch uint32
+}
+
+// Mint выпускает партию: коды с номерами 0..Count-1 внутри партии.
+func (c *Codec) Mint(brandID string, spec BatchSpec) ([]Voucher, []string, error) {
+	if spec.Count <= 0 || spec.Count > MaxBatchSize {
+		return nil, nil, fmt.Errorf("batch size must be 1..%d", MaxBatchSize)
+	}
+	if spec.Batch == 0 {
+		b, err := randomBatch()
+		if err != nil {
+			return nil, nil, err
+		}
+		spec.Batch = b
+	}
+	list := make([]Voucher, 0, spec.Count)
+	codes := make([]string, 0, spec.Count)
+	for i := 0; i < spec.Count; i++ {
+		v := Voucher{Kind: spec.Kind, Value: spec.Value, ExpiresOn: spec.ExpiresOn, Batch: spec.Batch, Index: uint16(i)}
+		code, err := c.Encode(brandID, v)
+		if err != nil {
+			return nil, nil, err
+		}
+		list = append(list, v)
+		codes = append(codes, code)
+	}
+	return list, codes, nil
+}
+
+func randomBatch() (uint32, error) {
+	var b [4]byte
+	for {
+		if _, err := rand.Read(b[:]); err != nil {
+			return 0, fmt.Errorf("random batch: %w", err)
+		}
+		if n := binary.BigEndian.Uint32(b[:]) & MaxBatch; n != 0 {
+			return n, nil
+		}
+	}
+}
+
+// WriteCSV выгружает партию: code, brand, kind, value, expires_on, serial.
+func WriteCSV(w io.Writer, brandID string, list []Voucher, codes []string) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"code", "brand", "kind", "value", "expires_on", "serial"}); err != nil {
+		return err
+	}
+	for i, v := range list {
+		if err := cw.Write([]string{codes[i], brandID, v.Kind.String(), strconv.Itoa(v.Value), v.ExpiresOn.In(moscow).Format(time.DateOnly), v.Serial()}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ParseExpiry — дата YYYY-MM-DD как последний день действия по Москве.
+func ParseExpiry(s string) (time.Time, error) {
+	return time.ParseInLocation(time.DateOnly, s, moscow)
+}
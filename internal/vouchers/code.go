@@ -0,0 +1,252 @@
+// Package vouchers — подарочные коды на баланс или услугу. Код сам несёт номинал, срок и серийный номер
+// и подписан HMAC секретом бренда, поэтому хранить выпущенные коды не нужно: однократность гасится
+// в SHM через uniq_key платежа (см. UniqKey).
+package vouchers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Kind — что даёт код.
+type Kind uint8
+
+const (
+	// KindBalance — Value рублей на баланс.
+	KindBalance Kind = 0
+	// KindService — услуга Value (service_id) из категории бренда.
+	KindService Kind = 1
+)
+
+func (k Kind) String() string {
+	if k == KindService {
+		return "service"
+	}
+	return "balance"
+}
+
+// ParseKind — «balance» или «service».
+func ParseKind(s string) (Kind, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "balance":
+		return KindBalance, nil
+	case "service":
+		return KindService, nil
+	}
+	return 0, fmt.Errorf("unknown voucher kind %q", s)
+}
+
+// Ограничения полей кода.
+const (
+	MaxValue     = 1<<23 - 1
+	MaxBatch     = 1<<24 - 1
+	MaxBatchSize = 1 << 16
+	// MinSecretLen — минимальная длина секрета подписи.
+	MinSecretLen = 32
+)
+
+var (
+	// ErrMalformed — код не разбирается или не сходится контрольный символ (опечатка).
+	ErrMalformed = errors.New("voucher code malformed")
+	// ErrInvalid — подпись не сходится: код подделан или выпущен для другого бренда.
+	ErrInvalid = errors.New("voucher code invalid")
+	// ErrNoSecret — секрет подписи не задан или короче MinSecretLen.
+	ErrNoSecret = errors.New("voucher secret is not configured")
+)
+
+// moscow — срок действия считается до конца дня по Москве (как даты SHM).
+var moscow = time.FixedZone("MSK", 3*3600)
+
+// epoch — день 0 для срока действия в коде.
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, moscow)
+
+// Voucher — содержимое кода.
+type Voucher struct {
+	Kind  Kind
+	Value int // рубли (KindBalance) или service_id (KindService)
+	// ExpiresOn — последний день действия (включительно, МСК).
+	ExpiresOn time.Time
+	Batch     uint32 // 24 бита
+	Index     uint16
+}
+
+// Serial — серийный номер кода: партия и номер в партии.
+func (v Voucher) Serial() string {
+	return fmt.Sprintf("%06x-%04x", v.Batch, v.Index)
+}
+
+// UniqKey — user/pay.uniq_key зачисления по коду; SHM не примет второй платёж с тем же ключом.
+func (v Voucher) UniqKey(brandID string) string {
+	return "voucher:" + strings.TrimSpace(brandID) + ":" + v.Serial()
+}
+
+// Expired — срок действия истёк к моменту now.
+func (v Voucher) Expired(now time.Time) bool {
+	y, m, d := v.ExpiresOn.In(moscow).Date()
+	return !now.Before(time.Date(y, m, d+1, 0, 0, 0, 0, moscow))
+}
+
+// Codec выпускает и проверяет коды одним секретом.
+type Codec struct {
+	secret []byte
+}
+
+// NewCodec — codec с секретом подписи (не короче MinSecretLen).
+func NewCodec(secret string) (*Codec, error) {
+	secret = strings.TrimSpace(secret)
+	if len(secret) < MinSecretLen {
+		return nil, ErrNoSecret
+	}
+	return &Codec{secret: []byte(secret)}, nil
+}
+
+const (
+	payloadLen = 10
+	macLen     = 5
+)
+
+// Encode — код вида XXXXX-XXXXX-XXXXX-XXXXX-XXXXX для бренда brandID.
+func (c *Codec) Encode(brandID string, v Voucher) (string, error) {
+	if v.Value <= 0 || v.Value > MaxValue || v.Batch > MaxBatch || v.Kind > KindService {
+		return "", fmt.Errorf("voucher fields out of range: %+v", v)
+	}
+	days := int(v.ExpiresOn.In(moscow).Sub(epoch).Hours() / 24)
+	if days < 0 || days > 0xffff {
+		return "", fmt.Errorf("voucher expiry out of range: %s", v.ExpiresOn.Format(time.DateOnly))
+	}
+	raw := make([]byte, payloadLen, payloadLen+macLen)
+	kv := uint32(v.Kind)<<23 | uint32(v.Value)
+	raw[0], raw[1], raw[2] = byte(kv>>16), byte(kv>>8), byte(kv)
+	binary.BigEndian.PutUint16(raw[3:5], uint16(days))
+	raw[5], raw[6], raw[7] = byte(v.Batch>>16), byte(v.Batch>>8), byte(v.Batch)
+	binary.BigEndian.PutUint16(raw[8:10], v.Index)
+	raw = append(raw, c.mac(brandID, raw)...)
+
+	body := encodeBase32(raw)
+	body += string(alphabet[luhnCheck(body)])
+	var b strings.Builder
+	for i, r := range body {
+		if i > 0 && i%5 == 0 {
+			b.WriteByte('-')
+		}
+		b.WriteRune(r)
+	}
+	return b.String(), nil
+}
+
+// Decode проверяет контрольный символ и подпись кода для бренда brandID. Регистр, пробелы и дефисы
+// не важны; O читается как 0, I и L — как 1.
+func (c *Codec) Decode(brandID, code string) (Voucher, error) {
+	norm := Normalize(code)
+	if len(norm) != 25 {
+		return Voucher{}, ErrMalformed
+	}
+	body, check := norm[:24], norm[24]
+	if sum := luhnCheck(body); sum < 0 || strings.IndexByte(alphabet, check) != sum {
+		return Voucher{}, ErrMalformed
+	}
+	raw, ok := decodeBase32(body)
+	if !ok {
+		return Voucher{}, ErrMalformed
+	}
+	payload, sig := raw[:payloadLen], raw[payloadLen:]
+	if !hmac.Equal(sig, c.mac(brandID, payload)) {
+		return Voucher{}, ErrInvalid
+	}
+	kv := uint32(payload[0])<<16 | uint32(payload[1])<<8 | uint32(payload[2])
+	days := binary.BigEndian.Uint16(payload[3:5])
+	return Voucher{
+		Kind:      Kind(kv >> 23),
+		Value:     int(kv & MaxValue),
+		ExpiresOn: epoch.AddDate(0, 0, int(days)),
+		Batch:     uint32(payload[5])<<16 | uint32(payload[6])<<8 | uint32(payload[7]),
+		Index:     binary.BigEndian.Uint16(payload[8:10]),
+	}, nil
+}
+
+func (c *Codec) mac(brandID string, payload []byte) []byte {
+	m := hmac.New(sha256.New, c.secret)
+	m.Write([]byte("vpnbot-voucher-v1|" + strings.TrimSpace(brandID) + "|"))
+	m.Write(payload)
+	return m.Sum(nil)[:macLen]
+}
+
+// alphabet — base32 Крокфорда (без I, L, O, U).
+const alphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// Normalize — код без разделителей в верхнем регистре с заменой похожих символов.
+func Normalize(code string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(code) {
+		switch r {
+		case '-', ' ', '\t':
+			continue
+		case 'O':
+			r = '0'
+		case 'I', 'L':
+			r = '1'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func encodeBase32(raw []byte) string {
+	var b strings.Builder
+	var acc uint32
+	bits := 0
+	for _, x := range raw {
+		acc = acc<<8 | uint32(x)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			b.WriteByte(alphabet[(acc>>bits)&31])
+		}
+	}
+	if bits > 0 {
+		b.WriteByte(alphabet[(acc<<(5-bits))&31])
+	}
+	return b.String()
+}
+
+func decodeBase32(s string) ([]byte, bool) {
+	out := make([]byte, 0, len(s)*5/8)
+	var acc uint32
+	bits := 0
+	for i := 0; i < len(s); i++ {
+		d := strings.IndexByte(alphabet, s[i])
+		if d < 0 {
+			return nil, false
+		}
+		acc = acc<<5 | uint32(d)
+		bits += 5
+		if bits >= 8 {
+			bits -= 8
+			out = append(out, byte(acc>>bits))
+		}
+	}
+	return out, true
+}
+
+// luhnCheck — контрольный символ Luhn mod 32: ловит любую одиночную замену и большинство перестановок соседних символов.
+// Символ вне алфавита даёт -1.
+func luhnCheck(s string) int {
+	const n = len(alphabet)
+	factor, sum := 2, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		d := strings.IndexByte(alphabet, s[i])
+		if d < 0 {
+			return -1
+		}
+		add := factor * d
+		add = add/n + add%n
+		sum += add
+		factor = 3 - factor
+	}
+	return (n - sum%n) % n
+}
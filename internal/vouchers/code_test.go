@@ -0,0 +1,106 @@
+package vouchers
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func TestCodec_RoundTrip(t *testing.T) {
+	c, err := NewCodec(testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp, _ := ParseExpiry("2026-12-31")
+	in := Voucher{Kind: KindService, Value: 7, ExpiresOn: exp, Batch: 0xabcdef, Index: 513}
+	code, err := c.Encode("vff", in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(code) != 29 || strings.Count(code, "-") != 4 {
+		t.Fatalf("format %q", code)
+	}
+	got, err := c.Decode("vff", strings.ToLower(strings.ReplaceAll(code, "-", " ")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Kind != KindService || got.Value != 7 || got.Batch != 0xabcdef || got.Index != 513 || !got.ExpiresOn.Equal(exp) {
+		t.Fatalf("%+v", got)
+	}
+	if got.UniqKey("vff") != "voucher:vff:abcdef-0201" {
+		t.Fatal(got.UniqKey("vff"))
+	}
+	if _, err := c.Decode("fc", code); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("other brand: %v", err)
+	}
+	other, _ := NewCodec(strings.Repeat("z", 32))
+	if _, err := other.Decode("vff", code); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("other secret: %v", err)
+	}
+}
+
+func TestCodec_Typos(t *testing.T) {
+	c, _ := NewCodec(testSecret)
+	exp, _ := ParseExpiry("2026-12-31")
+	code, _ := c.Encode("vff", Voucher{Kind: KindBalance, Value: 500, ExpiresOn: exp, Batch: 1})
+	norm := Normalize(code)
+	for i := 0; i < len(norm); i++ {
+		for _, r := range alphabet {
+			if byte(r) == norm[i] {
+				continue
+			}
+			typo := norm[:i] + string(r) + norm[i+1:]
+			if _, err := c.Decode("vff", typo); !errors.Is(err, ErrMalformed) {
+				t.Fatalf("substitution at %d not caught by checksum: %v", i, err)
+			}
+		}
+	}
+	if _, err := c.Decode("vff", code[:10]); !errors.Is(err, ErrMalformed) {
+		t.Fatal(err)
+	}
+	if _, err := NewCodec("short"); !errors.Is(err, ErrNoSecret) {
+		t.Fatal(err)
+	}
+}
+
+func TestVoucher_ExpiredAtEndOfMoscowDay(t *testing.T) {
+	exp, _ := ParseExpiry("2026-10-18")
+	v := Voucher{ExpiresOn: exp}
+	if v.Expired(time.Date(2026, 10, 18, 20, 59, 0, 0, time.UTC)) {
+		t.Fatal("23:59 MSK is still valid")
+	}
+	if !v.Expired(time.Date(2026, 10, 18, 21, 0, 0, 0, time.UTC)) {
+		t.Fatal("00:00 MSK next day is expired")
+	}
+}
+
+func TestMintAndCSV(t *testing.T) {
+	c, _ := NewCodec(testSecret)
+	exp, _ := ParseExpiry("2026-12-31")
+	list, codes, err := c.Mint("vff", BatchSpec{Kind: KindBalance, Value: 300, ExpiresOn: exp, Count: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if seen[code] {
+			t.Fatal("duplicate code")
+		}
+		seen[code] = true
+	}
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, "vff", list, codes); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 || lines[0] != "code,brand,kind,value,expires_on,serial" || !strings.HasPrefix(lines[1], codes[0]+",vff,balance,300,2026-12-31,") {
+		t.Fatalf("%q", lines)
+	}
+	if _, _, err := c.Mint("vff", BatchSpec{Kind: KindBalance, Value: 300, ExpiresOn: exp, Count: MaxBatchSize + 1}); err == nil {
+		t.Fatal("expected size error")
+	}
+}